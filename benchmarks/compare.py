@@ -0,0 +1,72 @@
+#!/usr/bin/env python3
+
+# Run the benchmarks in this directory with a number of GC implementations and
+# print a comparison table.
+#
+# Usage: benchmarks/compare.py [-target=wasi] [-tinygo=tinygo] [gc...]
+#
+# Every gc is a -gc= option, or extalloc_leaking for the extalloc GC without
+# automatic collection cycles (see src/runtime/gc_extalloc_leaking.go).
+
+import re
+import subprocess
+import sys
+
+# Flags for GC variants that aren't a -gc= option by themselves.
+GC_FLAGS = {
+    'extalloc_leaking': ['-gc=extalloc', '-tags=extalloc_leaking'],
+}
+
+BENCH_RE = re.compile(r'^(Benchmark\S+?)(?:-\d+)?\s+(\d+)\s+([\d.]+) ns/op(?:\s+(\d+) B/op\s+(\d+) allocs/op)?')
+
+def run(tinygo, target, gc):
+    gcflags = GC_FLAGS.get(gc, ['-gc=' + gc])
+    cmd = [tinygo, 'test', '-target=' + target] + gcflags + ['-run=^$', '-bench=.', './benchmarks']
+    print(' '.join(cmd), file=sys.stderr)
+    output = subprocess.run(cmd, stdout=subprocess.PIPE, stderr=subprocess.STDOUT, text=True)
+    if output.returncode != 0:
+        print(output.stdout, file=sys.stderr)
+        raise SystemExit('benchmarks failed with %s' % ' '.join(gcflags))
+    results = {}
+    for line in output.stdout.splitlines():
+        match = BENCH_RE.match(line)
+        if match:
+            results[match.group(1)] = float(match.group(3))
+    return results
+
+def main():
+    tinygo = 'tinygo'
+    target = 'wasi'
+    gcs = []
+    for arg in sys.argv[1:]:
+        if arg.startswith('-target='):
+            target = arg[len('-target='):]
+        elif arg.startswith('-tinygo='):
+            tinygo = arg[len('-tinygo='):]
+        else:
+            gcs.append(arg)
+    if not gcs:
+        gcs = ['extalloc', 'extalloc_leaking', 'conservative']
+
+    results = {gc: run(tinygo, target, gc) for gc in gcs}
+    benchmarks = sorted(set(name for r in results.values() for name in r))
+
+    # Print ns/op per GC, relative to the first GC in the list.
+    base = gcs[0]
+    header = '%-24s' % ('ns/op (-target=%s)' % target) + ''.join(' | %20s' % gc for gc in gcs)
+    print(header)
+    print('-' * len(header))
+    for name in benchmarks:
+        line = '%-24s' % name
+        for gc in gcs:
+            value = results[gc].get(name)
+            if value is None:
+                line += ' | %20s' % '-'
+            elif gc == base or not results[base].get(name):
+                line += ' | %20.0f' % value
+            else:
+                line += ' | %12.0f (%+5.0f%%)' % (value, (value / results[base][name] - 1) * 100)
+        print(line)
+
+if __name__ == '__main__':
+    main()
@@ -0,0 +1,43 @@
+package benchmarks
+
+import (
+	"strconv"
+	"testing"
+)
+
+// BenchmarkMapChurn inserts and deletes keys in a map, like a runtime caching
+// storage values (overlay changes) during block execution.
+func BenchmarkMapChurn(b *testing.B) {
+	keys := make([]string, 1024)
+	for i := range keys {
+		keys[i] = "storage:" + strconv.Itoa(i*7919)
+	}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		m := make(map[string][]byte)
+		for j, key := range keys {
+			m[key] = make([]byte, 8+j%32)
+		}
+		for j := 0; j < len(keys); j += 2 {
+			delete(m, keys[j])
+		}
+		for j := 0; j < len(keys); j += 3 {
+			m[keys[j]] = append(m[keys[j]], byte(j))
+		}
+	}
+}
+
+// BenchmarkMapIntKeys measures a map with integer keys that stays live across
+// iterations, so that the GC has to scan it.
+func BenchmarkMapIntKeys(b *testing.B) {
+	m := make(map[uint64]uint64)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		for j := uint64(0); j < 256; j++ {
+			key := uint64(i)*256 + j
+			m[key%4096] += key
+		}
+	}
+}
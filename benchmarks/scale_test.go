@@ -0,0 +1,189 @@
+package benchmarks
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"testing"
+)
+
+// A minimal SCALE codec, enough to resemble what a blockchain runtime does
+// when decoding extrinsics and encoding storage values.
+// https://docs.substrate.io/reference/scale-codec/
+
+var errShortBuffer = errors.New("scale: short buffer")
+
+func appendCompact(buf []byte, n uint64) []byte {
+	switch {
+	case n < 1<<6:
+		return append(buf, byte(n)<<2)
+	case n < 1<<14:
+		return binary.LittleEndian.AppendUint16(buf, uint16(n)<<2|0b01)
+	case n < 1<<30:
+		return binary.LittleEndian.AppendUint32(buf, uint32(n)<<2|0b10)
+	default:
+		// Big integer mode, always using 8 bytes here.
+		buf = append(buf, (8-4)<<2|0b11)
+		return binary.LittleEndian.AppendUint64(buf, n)
+	}
+}
+
+func readCompact(buf []byte) (uint64, []byte, error) {
+	if len(buf) == 0 {
+		return 0, nil, errShortBuffer
+	}
+	switch buf[0] & 0b11 {
+	case 0b00:
+		return uint64(buf[0] >> 2), buf[1:], nil
+	case 0b01:
+		if len(buf) < 2 {
+			return 0, nil, errShortBuffer
+		}
+		return uint64(binary.LittleEndian.Uint16(buf) >> 2), buf[2:], nil
+	case 0b10:
+		if len(buf) < 4 {
+			return 0, nil, errShortBuffer
+		}
+		return uint64(binary.LittleEndian.Uint32(buf) >> 2), buf[4:], nil
+	default:
+		if len(buf) < 9 {
+			return 0, nil, errShortBuffer
+		}
+		return binary.LittleEndian.Uint64(buf[1:]), buf[9:], nil
+	}
+}
+
+// transfer is a typical runtime call: a destination account, an amount and
+// some opaque call data.
+type transfer struct {
+	Dest   [32]byte
+	Amount uint64
+	Nonce  uint32
+	Data   []byte
+}
+
+func (t *transfer) encode(buf []byte) []byte {
+	buf = append(buf, t.Dest[:]...)
+	buf = appendCompact(buf, t.Amount)
+	buf = binary.LittleEndian.AppendUint32(buf, t.Nonce)
+	buf = appendCompact(buf, uint64(len(t.Data)))
+	return append(buf, t.Data...)
+}
+
+func (t *transfer) decode(buf []byte) ([]byte, error) {
+	if len(buf) < 32 {
+		return nil, errShortBuffer
+	}
+	copy(t.Dest[:], buf)
+	amount, buf, err := readCompact(buf[32:])
+	if err != nil {
+		return nil, err
+	}
+	t.Amount = amount
+	if len(buf) < 4 {
+		return nil, errShortBuffer
+	}
+	t.Nonce = binary.LittleEndian.Uint32(buf)
+	n, buf, err := readCompact(buf[4:])
+	if err != nil {
+		return nil, err
+	}
+	if uint64(len(buf)) < n {
+		return nil, errShortBuffer
+	}
+	t.Data = append([]byte(nil), buf[:n]...) // decoded values own their memory
+	return buf[n:], nil
+}
+
+func makeTransfers(n int) []transfer {
+	transfers := make([]transfer, n)
+	for i := range transfers {
+		transfers[i].Dest[0] = byte(i)
+		transfers[i].Amount = uint64(i) * 1_000_003
+		transfers[i].Nonce = uint32(i)
+		transfers[i].Data = make([]byte, i%97)
+	}
+	return transfers
+}
+
+func TestSCALERoundTrip(t *testing.T) {
+	for _, n := range []uint64{0, 63, 64, 1<<14 - 1, 1 << 14, 1<<30 - 1, 1 << 30, 1<<64 - 1} {
+		buf := appendCompact(nil, n)
+		value, rest, err := readCompact(buf)
+		if err != nil || value != n || len(rest) != 0 {
+			t.Errorf("compact %d: got %d, %d bytes left, err %v", n, value, len(rest), err)
+		}
+	}
+	for _, original := range makeTransfers(100) {
+		var decoded transfer
+		rest, err := decoded.decode(original.encode(nil))
+		if err != nil || len(rest) != 0 {
+			t.Fatalf("could not decode transfer %d: %v", original.Nonce, err)
+		}
+		if decoded.Dest != original.Dest || decoded.Amount != original.Amount || decoded.Nonce != original.Nonce || string(decoded.Data) != string(original.Data) {
+			t.Errorf("transfer %d changed after a round trip", original.Nonce)
+		}
+	}
+}
+
+// BenchmarkSCALEEncode encodes a block worth of calls into a fresh buffer.
+func BenchmarkSCALEEncode(b *testing.B) {
+	transfers := makeTransfers(256)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		var buf []byte
+		buf = appendCompact(buf, uint64(len(transfers)))
+		for j := range transfers {
+			buf = transfers[j].encode(buf)
+		}
+	}
+}
+
+// BenchmarkSCALEEncodeBuffer encodes a block worth of calls field by field
+// into a bytes.Buffer, like most encoders do. The buffer starts out empty and
+// is grown many times, so this mostly measures how well byte slices grow.
+func BenchmarkSCALEEncodeBuffer(b *testing.B) {
+	transfers := makeTransfers(256)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		var buf bytes.Buffer
+		var scratch [9]byte
+		buf.Write(appendCompact(scratch[:0], uint64(len(transfers))))
+		for j := range transfers {
+			t := &transfers[j]
+			buf.Write(t.Dest[:])
+			buf.Write(appendCompact(scratch[:0], t.Amount))
+			buf.Write(binary.LittleEndian.AppendUint32(scratch[:0], t.Nonce))
+			buf.Write(appendCompact(scratch[:0], uint64(len(t.Data))))
+			buf.Write(t.Data)
+		}
+	}
+}
+
+// BenchmarkSCALEDecode decodes a block worth of calls, allocating the decoded
+// values like a runtime would.
+func BenchmarkSCALEDecode(b *testing.B) {
+	var encoded []byte
+	transfers := makeTransfers(256)
+	encoded = appendCompact(encoded, uint64(len(transfers)))
+	for j := range transfers {
+		encoded = transfers[j].encode(encoded)
+	}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		n, buf, err := readCompact(encoded)
+		if err != nil {
+			b.Fatal(err)
+		}
+		decoded := make([]transfer, n)
+		for j := range decoded {
+			buf, err = decoded[j].decode(buf)
+			if err != nil {
+				b.Fatal(err)
+			}
+		}
+	}
+}
@@ -0,0 +1,80 @@
+package benchmarks
+
+import (
+	"encoding/binary"
+	"testing"
+)
+
+// trieNode is a node in a hexary (radix 16) trie, similar in shape to the
+// Merkle-Patricia tries used for blockchain state. Hashing is left out, as
+// the point is to exercise pointer-heavy allocations.
+type trieNode struct {
+	children [16]*trieNode
+	value    []byte
+}
+
+func (n *trieNode) insert(key, value []byte) {
+	for _, b := range key {
+		for _, nibble := range [2]byte{b >> 4, b & 0xf} {
+			if n.children[nibble] == nil {
+				n.children[nibble] = &trieNode{}
+			}
+			n = n.children[nibble]
+		}
+	}
+	n.value = value
+}
+
+func (n *trieNode) get(key []byte) []byte {
+	for _, b := range key {
+		for _, nibble := range [2]byte{b >> 4, b & 0xf} {
+			n = n.children[nibble]
+			if n == nil {
+				return nil
+			}
+		}
+	}
+	return n.value
+}
+
+func trieKey(i int) []byte {
+	var key [32]byte
+	binary.LittleEndian.PutUint64(key[:], uint64(i)*0x9e3779b97f4a7c15)
+	return key[:]
+}
+
+func TestTrie(t *testing.T) {
+	root := &trieNode{}
+	for i := 0; i < 100; i++ {
+		root.insert(trieKey(i), []byte{byte(i)})
+	}
+	for i := 0; i < 100; i++ {
+		if value := root.get(trieKey(i)); len(value) != 1 || value[0] != byte(i) {
+			t.Errorf("unexpected value for key %d: %v", i, value)
+		}
+	}
+	if value := root.get(trieKey(100)); value != nil {
+		t.Errorf("unexpected value for missing key: %v", value)
+	}
+}
+
+// BenchmarkTrie builds a trie of 32-byte keys and looks up every key.
+func BenchmarkTrie(b *testing.B) {
+	keys := make([][]byte, 256)
+	for i := range keys {
+		keys[i] = trieKey(i)
+	}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		root := &trieNode{}
+		for j, key := range keys {
+			root.insert(key, key[:j%32])
+		}
+		for _, key := range keys {
+			if root.get(key) == nil {
+				b.Fatal("key not found")
+			}
+		}
+	}
+}
@@ -12,8 +12,10 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"go/token"
 	"go/types"
 	"hash/crc32"
+	"io"
 	"io/fs"
 	"math/bits"
 	"os"
@@ -27,6 +29,7 @@ import (
 	"github.com/gofrs/flock"
 	"github.com/tinygo-org/tinygo/compileopts"
 	"github.com/tinygo-org/tinygo/compiler"
+	"github.com/tinygo-org/tinygo/compiler/llvmutil"
 	"github.com/tinygo-org/tinygo/goenv"
 	"github.com/tinygo-org/tinygo/interp"
 	"github.com/tinygo-org/tinygo/loader"
@@ -67,12 +70,16 @@ type BuildResult struct {
 // a cache key of compiled packages. It should contain all the information that
 // goes into a compiled package to avoid using stale data.
 //
-// Right now it's still important to include a hash of every import, because a
-// dependency might have a public constant that this package uses and thus this
-// package will need to be recompiled if that constant changes. In the future,
-// the type data should be serialized to disk which can then be used as cache
-// key, avoiding the need for recompiling all dependencies when only the
-// implementation of an imported package changes.
+// Imported packages are included by their interface ID (see
+// packageInterfaceID) instead of their action ID. A dependency might have a
+// public constant or type that this package uses, so this package needs to be
+// recompiled if those change, but not when only the implementation of the
+// dependency changes.
+//
+// The runtime is the exception: the compiler lays out unexported runtime types
+// (like the map iterator) in every package, and which of those are used
+// depends on options like -maps. Therefore every package also includes the
+// full action ID of the runtime.
 type packageAction struct {
 	ImportPath       string
 	CompilerBuildID  string
@@ -82,11 +89,24 @@ type packageAction struct {
 	CFlags           []string
 	FileHashes       map[string]string // hash of every file that's part of the package
 	EmbeddedFiles    map[string]string // hash of all the //go:embed files in the package
-	Imports          map[string]string // map from imported package to action ID hash
+	Imports          map[string]string // map from imported package to interface ID
+	RuntimeInterface string            // interface ID of the runtime package
+	Maps             string            // map implementation of the runtime (-maps), which determines the hashmap layout
 	OptLevel         string            // LLVM optimization level (O0, O1, O2, Os, Oz)
 	UndefinedGlobals []string          // globals that are left as external globals (no initializer)
 }
 
+// hash returns the action ID: a hash of all the parameters of the package
+// build, which is used as the cache key of the compiled package.
+func (action *packageAction) hash() (string, error) {
+	buf, err := json.Marshal(action)
+	if err != nil {
+		return "", err // shouldn't happen
+	}
+	hash := sha512.Sum512_224(buf)
+	return hex.EncodeToString(hash[:]), nil
+}
+
 // Build performs a single package to executable Go build. It takes in a package
 // name, an output path, and set of compile options and from that it manages the
 // whole compilation process.
@@ -114,10 +134,17 @@ func Build(pkgName, outpath, tmpdir string, config *compileopts.Config) (BuildRe
 		cacheDir = tmpdir
 	}
 
-	// Create default global values.
+	// Create default global values. The build information is also stored in
+	// the program, for runtime.Version and debug.ReadBuildInfo.
+	buildInfo := newBuildInfo(config)
+	goVersion, _ := goenv.GorootVersionString()
 	globalValues := map[string]map[string]string{
 		"runtime": {
-			"buildVersion": goenv.Version(),
+			"buildVersion": buildInfo.runtimeVersion(),
+		},
+		"runtime/debug": {
+			"buildGoVersion": goVersion,
+			"buildSettings":  buildInfo.runtimeSettings(config),
 		},
 		"testing": {},
 	}
@@ -199,7 +226,15 @@ func Build(pkgName, outpath, tmpdir string, config *compileopts.Config) (BuildRe
 		DefaultStackSize:   config.StackSize(),
 		MaxStackAlloc:      config.MaxStackAlloc(),
 		NeedsStackObjects:  config.NeedsStackObjects(),
+		SingleThreaded:     config.SingleThreaded(),
 		Debug:              !config.Options.SkipDWARF, // emit DWARF except when -internal-nodwarf is passed
+		LowerFmt:           config.Options.LowerFmt,
+		ConstantTime:       config.Options.ConstantTime,
+		FPDeterministic:    config.Options.FPDeterministic,
+		StrictExportABI:    config.StrictExportABI(),
+		PolkaVM:            config.PolkaVM(),
+		BoundsMessages:     config.BoundsMessages(),
+		ForceInline:        config.Options.InlineBudget != 0,
 	}
 
 	// Load the target machine, which is the LLVM object that contains all
@@ -227,18 +262,49 @@ func Build(pkgName, outpath, tmpdir string, config *compileopts.Config) (BuildRe
 		// If there is no module root, just the regular root.
 		result.ModuleRoot = lprogram.MainPkg().Root
 	}
+	globalValues["runtime/debug"]["buildPath"] = lprogram.MainPkg().ImportPath
+	globalValues["runtime/debug"]["buildModule"] = lprogram.MainPkg().Module.Path
 	err = lprogram.Parse()
 	if err != nil {
 		return result, err
 	}
 
+	if config.Options.Reproducible {
+		// Store file names in the debug information like the go tool does
+		// with -trimpath: relative to the standard library or module root.
+		compilerConfig.TrimPaths = map[string]string{
+			filepath.Join(goenv.Get("GOROOT"), "src"):     "",
+			filepath.Join(goenv.Get("TINYGOROOT"), "src"): "",
+		}
+		for _, pkg := range lprogram.Sorted() {
+			if pkg.Module.Dir == "" {
+				continue
+			}
+			modulePath := pkg.Module.Path
+			if pkg.Module.Version != "" {
+				modulePath += "@" + pkg.Module.Version
+			}
+			compilerConfig.TrimPaths[pkg.Module.Dir] = modulePath
+		}
+		if mainPkg := lprogram.MainPkg(); mainPkg.Module.Dir == "" {
+			compilerConfig.TrimPaths[mainPkg.Dir] = mainPkg.ImportPath
+		}
+	}
+
 	// Create the *ssa.Program. This does not yet build the entire SSA of the
 	// program so it's pretty fast and doesn't need to be parallelized.
 	program := lprogram.LoadSSA()
 
+	// Build the SSA for all packages. The go/ssa package builds every package
+	// in a separate goroutine. This must be finished before any package is
+	// compiled, as building a package may add functions (like wrappers and
+	// generic instances) that are compiled as part of another package.
+	program.Build()
+
 	// Add jobs to compile each package.
 	// Packages that have a cache hit will not be compiled again.
 	var packageJobs []*compileJob
+	packageInterfaceJobs := make(map[string]*compileJob)
 	packageActionIDJobs := make(map[string]*compileJob)
 
 	var embedFileObjects []*compileJob
@@ -292,11 +358,11 @@ func Build(pkgName, outpath, tmpdir string, config *compileopts.Config) (BuildRe
 			embedFileObjects = append(embedFileObjects, job)
 		}
 
-		// Action ID jobs need to know the action ID of all the jobs the package
-		// imports.
+		// Action ID jobs need to know the interface ID of all the packages the
+		// package imports.
 		var importedPackages []*compileJob
 		for _, imported := range pkg.Pkg.Imports() {
-			job, ok := packageActionIDJobs[imported.Path()]
+			job, ok := packageInterfaceJobs[imported.Path()]
 			if !ok {
 				return result, fmt.Errorf("package %s imports %s but couldn't find dependency", pkg.ImportPath, imported.Path())
 			}
@@ -304,6 +370,16 @@ func Build(pkgName, outpath, tmpdir string, config *compileopts.Config) (BuildRe
 			actionIDDependencies = append(actionIDDependencies, job)
 		}
 
+		// Runtime checks may be disabled and soft-float may be enabled for
+		// individual packages.
+		pkgCompilerConfig := compilerConfig
+		if !config.PanicChecks(pkg.ImportPath) || config.SoftFloat(pkg.ImportPath) {
+			c := *compilerConfig
+			c.NoPanicChecks = !config.PanicChecks(pkg.ImportPath)
+			c.SoftFloat = config.SoftFloat(pkg.ImportPath)
+			pkgCompilerConfig = &c
+		}
+
 		// Create a job that will calculate the action ID for a package compile
 		// job. The action ID is the cache key that is used for caching this
 		// package.
@@ -317,13 +393,14 @@ func Build(pkgName, outpath, tmpdir string, config *compileopts.Config) (BuildRe
 					ImportPath:       pkg.ImportPath,
 					CompilerBuildID:  string(compilerBuildID),
 					LLVMVersion:      llvm.Version,
-					Config:           compilerConfig,
+					Config:           pkgCompilerConfig,
 					CFlags:           pkg.CFlags,
 					FileHashes:       make(map[string]string, len(pkg.FileHashes)),
 					EmbeddedFiles:    make(map[string]string, len(allFiles)),
 					Imports:          make(map[string]string, len(pkg.Pkg.Imports())),
 					OptLevel:         optLevel,
 					UndefinedGlobals: undefinedGlobals,
+					Maps:             config.Maps(),
 				}
 				for filePath, hash := range pkg.FileHashes {
 					actionID.FileHashes[filePath] = hex.EncodeToString(hash)
@@ -334,21 +411,32 @@ func Build(pkgName, outpath, tmpdir string, config *compileopts.Config) (BuildRe
 				for i, imported := range pkg.Pkg.Imports() {
 					actionID.Imports[imported.Path()] = importedPackages[i].result
 				}
-				buf, err := json.Marshal(actionID)
-				if err != nil {
-					return err // shouldn't happen
+				if runtimeJob := packageInterfaceJobs["runtime"]; runtimeJob != nil && pkg.ImportPath != "runtime" {
+					actionID.RuntimeInterface = runtimeJob.result
 				}
-				hash := sha512.Sum512_224(buf)
-				job.result = hex.EncodeToString(hash[:])
+				result, err := actionID.hash()
+				job.result = result
+				return err
+			},
+		}
+
+		// Create a job that calculates the interface ID of this package, which
+		// is used in the action ID of the packages that import it.
+		packageInterfaceJob := &compileJob{
+			description:  "calculate interface ID for package " + pkg.ImportPath,
+			dependencies: importedPackages,
+			run: func(job *compileJob) error {
+				imports := make(map[string]string, len(importedPackages))
+				for i, imported := range pkg.Pkg.Imports() {
+					imports[imported.Path()] = importedPackages[i].result
+				}
+				job.result = packageInterfaceID(pkg, imports)
 				return nil
 			},
 		}
+		packageInterfaceJobs[pkg.ImportPath] = packageInterfaceJob
 		packageActionIDJobs[pkg.ImportPath] = packageActionIDJob
 
-		// Build the SSA for the given package.
-		ssaPkg := program.Package(pkg.Pkg)
-		ssaPkg.Build()
-
 		// Now create the job to actually build the package. It will exit early
 		// if the package is already compiled.
 		job := &compileJob{
@@ -367,7 +455,7 @@ func Build(pkgName, outpath, tmpdir string, config *compileopts.Config) (BuildRe
 
 				// Compile AST to IR. The compiler.CompilePackage function will
 				// build the SSA as needed.
-				mod, errs := compiler.CompilePackage(pkg.ImportPath, pkg, program.Package(pkg.Pkg), machine, compilerConfig, config.DumpSSA())
+				mod, errs := compiler.CompilePackage(pkg.ImportPath, pkg, program.Package(pkg.Pkg), machine, pkgCompilerConfig, config.DumpSSA())
 				defer mod.Context().Dispose()
 				defer mod.Dispose()
 				if errs != nil {
@@ -455,6 +543,14 @@ func Build(pkgName, outpath, tmpdir string, config *compileopts.Config) (BuildRe
 					return errors.New("verification error after interpreting " + pkgInit.Name())
 				}
 
+				// Lower floating point operations for -soft-float, before
+				// the optimizer combines them into intrinsics.
+				if pkgCompilerConfig.SoftFloat {
+					if errs := transform.LowerSoftFloat(mod); len(errs) != 0 {
+						return newMultiError(errs)
+					}
+				}
+
 				transform.OptimizePackage(mod, config)
 
 				// Serialize the LLVM module as a bitcode file.
@@ -493,6 +589,18 @@ func Build(pkgName, outpath, tmpdir string, config *compileopts.Config) (BuildRe
 		packageJobs = append(packageJobs, job)
 	}
 
+	// The action ID of every package includes the interface ID of the runtime
+	// (see packageAction), as the compiler inserts calls to the runtime in
+	// every package. This doesn't create a cycle, as the interface ID of the
+	// runtime only depends on the interface ID of the packages it imports.
+	if runtimeJob := packageInterfaceJobs["runtime"]; runtimeJob != nil {
+		for path, job := range packageActionIDJobs {
+			if path != "runtime" {
+				job.dependencies = append(job.dependencies, runtimeJob)
+			}
+		}
+	}
+
 	// Add job that links and optimizes all packages together.
 	var mod llvm.Module
 	defer func() {
@@ -503,6 +611,10 @@ func Build(pkgName, outpath, tmpdir string, config *compileopts.Config) (BuildRe
 		}
 	}()
 	var stackSizeLoads []string
+	var traceFunctionNames []string
+	var softFloatCallers []string
+	var coverageBlocks []transform.CoverageBlock
+	var coldMod llvm.Module
 	programJob := &compileJob{
 		description:  "link+optimize packages (LTO)",
 		dependencies: packageJobs,
@@ -572,18 +684,136 @@ func Build(pkgName, outpath, tmpdir string, config *compileopts.Config) (BuildRe
 				fmt.Println(mod.String())
 			}
 
+			// Replace hash and signature verification functions with host
+			// functions for -host-hashing and -host-crypto. This must be done
+			// before optimizing, while these functions haven't been inlined
+			// yet.
+			if algorithms := config.HostHashing(); len(algorithms) != 0 {
+				err := transform.ReplaceHashFunctions(mod, algorithms)
+				if err != nil {
+					return err
+				}
+			}
+			if schemes := config.HostCrypto(); len(schemes) != 0 {
+				err := transform.ReplaceCryptoFunctions(mod, schemes)
+				if err != nil {
+					return err
+				}
+			}
+
+			// Remove runtime/hostlog calls below the -log-level= threshold,
+			// also before they get inlined.
+			if config.Options.LogLevel != "" {
+				transform.StripLogCalls(mod, config.Options.LogLevel)
+			}
+
+			// Import the external allocator of -gc=extalloc under the names
+			// configured in the target.
+			if config.GC() == "extalloc" {
+				err := setExtallocImports(mod, config.Target)
+				if err != nil {
+					return err
+				}
+				err = setExtallocBuckets(mod, config.Target)
+				if err != nil {
+					return err
+				}
+			}
+
+			// Use the memcpy, memmove and memset of the runtime instead of
+			// those of the C library, for the mem-routines target property
+			// (or the mem group of go-helpers). The same for the other groups
+			// of go-helpers.
+			if config.MemRoutines() == "deterministic" {
+				err := setRuntimeHelpers(mod, goHelperFunctions["mem"])
+				if err != nil {
+					return err
+				}
+			}
+			for _, group := range config.GoHelpers() {
+				if group == "mem" {
+					continue // see above
+				}
+				err := setRuntimeHelpers(mod, goHelperFunctions[group])
+				if err != nil {
+					return err
+				}
+			}
+
+			// Only keep the exports listed in -exports=, so that the other
+			// exported functions can be removed as dead code.
+			if config.Options.Exports != "" {
+				err := transform.RestrictExports(mod, strings.Split(config.Options.Exports, ","))
+				if err != nil {
+					return err
+				}
+			}
+
+			// Set the size of the redzone that is checked for -redzone.
+			if config.Options.Redzone != 0 {
+				global := mod.NamedGlobal("runtime.wasmRedzoneSize")
+				if !global.IsNil() {
+					global.SetInitializer(llvm.ConstInt(global.GlobalValueType(), uint64(config.Options.Redzone), false))
+				}
+			}
+
+			// Let exported WebAssembly functions record the stack top,
+			// initialize the program and detect re-entrant calls when called
+			// by the host.
+			if strings.HasPrefix(config.Triple(), "wasm32-") {
+				err := transform.AddExportPrologues(mod, config.Options.CrashDump)
+				if err != nil {
+					return err
+				}
+			}
+
+			// Describe the imports and exports of a PolkaVM program for
+			// polkatool, and let exported functions initialize the program.
+			if config.PolkaVM() {
+				err := transform.AddPolkaVMMetadata(mod)
+				if err != nil {
+					return err
+				}
+			}
+
+			// Add coverage counters for -cover, before optimizing so that
+			// every basic block still has its original source location.
+			if config.Options.Cover {
+				coverageBlocks = transform.InstrumentCoverage(mod, coverageFileNames(config, lprogram))
+			}
+
+			// Add coverage counters for fuzzing. Like -trace-calls, this is
+			// done before optimizing so that the counters match the source.
+			if config.Options.TestConfig.FuzzRegexp != "" {
+				transform.InstrumentFuzzCounters(mod)
+			}
+
 			// Run all optimization passes, which are much more effective now
 			// that the optimizer can see the whole program at once.
-			err := optimizeProgram(mod, config, globalValues)
+			var err error
+			traceFunctionNames, err = optimizeProgram(mod, config, lprogram, globalValues)
 			if err != nil {
 				return err
 			}
+			if config.Options.SoftFloat != "" {
+				softFloatCallers = transform.SoftFloatCallers(mod)
+			}
 
 			// Make sure stack sizes are loaded from a separate section so they can be
 			// modified after linking.
 			if config.AutomaticStackSize() {
 				stackSizeLoads = transform.CreateStackSizeLoads(mod, config)
 			}
+
+			// Move error paths to a separate module for -split-cold, which is
+			// linked last. This must be the last change to the module.
+			if config.Options.SplitCold {
+				var err error
+				coldMod, err = transform.SplitColdFunctions(mod)
+				if err != nil {
+					return err
+				}
+			}
 			return nil
 		},
 	}
@@ -591,12 +821,23 @@ func Build(pkgName, outpath, tmpdir string, config *compileopts.Config) (BuildRe
 	// Check whether we only need to create an object file.
 	// If so, we don't need to link anything and will be finished quickly.
 	outext := filepath.Ext(outpath)
+	if strings.HasSuffix(outpath, ".compressed.wasm") {
+		outext = ".compressed.wasm"
+	}
 	if outext == ".o" || outext == ".bc" || outext == ".ll" {
 		// Run jobs to produce the LLVM module.
 		err := runJobs(programJob, config.Options.Semaphore)
 		if err != nil {
 			return result, err
 		}
+		// There is no linker to place the cold code of -split-cold at the
+		// end, so put it back.
+		if config.Options.SplitCold {
+			err := llvm.LinkModules(mod, coldMod)
+			if err != nil {
+				return result, err
+			}
+		}
 		// Generate output.
 		switch outext {
 		case ".o":
@@ -702,6 +943,23 @@ func Build(pkgName, outpath, tmpdir string, config *compileopts.Config) (BuildRe
 	// Add embedded files.
 	linkerDependencies = append(linkerDependencies, embedFileObjects...)
 
+	// Add the cold code of -split-cold at the very end, as the linker places
+	// the code of its inputs in the order they're passed.
+	if config.Options.SplitCold {
+		coldfile := filepath.Join(tmpdir, "cold.o")
+		linkerDependencies = append(linkerDependencies, &compileJob{
+			description:  "generate cold output file",
+			dependencies: []*compileJob{programJob},
+			result:       coldfile,
+			run: func(*compileJob) error {
+				defer coldMod.Dispose()
+				llvmBuf := llvm.WriteThinLTOBitcodeToMemoryBuffer(coldMod)
+				defer llvmBuf.Dispose()
+				return os.WriteFile(coldfile, llvmBuf.Bytes(), 0666)
+			},
+		})
+	}
+
 	// Determine whether the compilation configuration would result in debug
 	// (DWARF) information in the object files.
 	var hasDebug = true
@@ -730,6 +988,8 @@ func Build(pkgName, outpath, tmpdir string, config *compileopts.Config) (BuildRe
 
 	// Create a linker job, which links all object files together and does some
 	// extra stuff that can only be done after linking.
+	var calculatedStacks []string
+	var stackSizes map[string]functionStackSize
 	linkJob := &compileJob{
 		description:  "link",
 		dependencies: linkerDependencies,
@@ -777,8 +1037,6 @@ func Build(pkgName, outpath, tmpdir string, config *compileopts.Config) (BuildRe
 				return &commandError{"failed to link", result.Executable, err}
 			}
 
-			var calculatedStacks []string
-			var stackSizes map[string]functionStackSize
 			if config.Options.PrintStacks || config.AutomaticStackSize() {
 				// Try to determine stack sizes at compile time.
 				// Don't do this by default as it usually doesn't work on
@@ -806,8 +1064,48 @@ func Build(pkgName, outpath, tmpdir string, config *compileopts.Config) (BuildRe
 				}
 			}
 
-			// Run wasm-opt for wasm binaries
-			if arch := strings.Split(config.Triple(), "-")[0]; arch == "wasm32" {
+			return nil
+		},
+	}
+
+	// Map package directories to import paths, for size reports.
+	packagePathMap := make(map[string]string, len(lprogram.Packages))
+	for _, pkg := range lprogram.Sorted() {
+		packagePathMap[pkg.OriginalDir()] = pkg.Pkg.Path()
+		if compilerConfig.TrimPaths != nil {
+			// File names in the debug information are trimmed.
+			packagePathMap[compiler.TrimPath(compilerConfig.TrimPaths, pkg.OriginalDir())] = pkg.Pkg.Path()
+		}
+	}
+	printSizes := config.Options.PrintSizes == "short" || config.Options.PrintSizes == "full" || config.Options.PrintSizes == "json"
+
+	// Post-process wasm binaries. This is split in several jobs, so that
+	// reading the sizes before wasm-opt can run at the same time as wasm-opt.
+	executableJob := linkJob // job that produces the final executable
+	var sizesBeforeWasmOpt *programSize
+	if arch := strings.Split(config.Triple(), "-")[0]; arch == "wasm32" {
+		finishDependencies := []*compileJob{}
+		if printSizes {
+			// Remember the sizes before wasm-opt, to report how effective it
+			// was.
+			finishDependencies = append(finishDependencies, &compileJob{
+				description:  "load sizes before wasm-opt",
+				dependencies: []*compileJob{linkJob},
+				run: func(*compileJob) error {
+					sizes, err := loadProgramSize(result.Executable, packagePathMap)
+					sizesBeforeWasmOpt = sizes
+					return err
+				},
+			})
+		}
+
+		// Run wasm-opt. It writes to a separate file, as the linker output
+		// may still be read by other jobs.
+		wasmOptJob := &compileJob{
+			description:  "wasm-opt",
+			dependencies: []*compileJob{linkJob},
+			result:       filepath.Join(tmpdir, "main.opt.wasm"),
+			run: func(job *compileJob) error {
 				optLevel, _, _ := config.OptLevel()
 				opt := "-" + optLevel
 
@@ -816,35 +1114,166 @@ func Build(pkgName, outpath, tmpdir string, config *compileopts.Config) (BuildRe
 				if config.Scheduler() == "asyncify" {
 					args = append(args, "--asyncify")
 				}
+				args = append(args, config.Target.WasmOptFlags...)
 
 				args = append(args,
 					opt,
 					"-g",
 					result.Executable,
-					"--output", result.Executable,
+					"--output", job.result,
 				)
 
 				cmd := exec.Command(goenv.Get("WASMOPT"), args...)
 				cmd.Stdout = os.Stdout
 				cmd.Stderr = os.Stderr
+				if config.Options.Reproducible {
+					// Run all passes on a single thread, in a fixed order,
+					// so that the result doesn't depend on the number of
+					// cores of the build machine.
+					cmd.Env = append(os.Environ(), "BINARYEN_CORES=1")
+				} else if sema := config.Options.Semaphore; sema != nil {
+					// Use as many threads as jobs are allowed to run in
+					// parallel (-j), wasm-opt is usually the only job left.
+					cmd.Env = append(os.Environ(), "BINARYEN_CORES="+strconv.Itoa(cap(sema)))
+				}
 
 				err := cmd.Run()
 				if err != nil {
 					return fmt.Errorf("wasm-opt failed: %w", err)
 				}
-			}
+				return nil
+			},
+		}
+		finishDependencies = append(finishDependencies, wasmOptJob)
+
+		executableJob = &compileJob{
+			description:  "finish wasm file",
+			dependencies: finishDependencies,
+			run: func(*compileJob) error {
+				err := os.Rename(wasmOptJob.result, result.Executable)
+				if err != nil {
+					return err
+				}
+
+				// Run the post-link passes of the target, and -icf and
+				// -compress-data.
+				passes := config.Target.WasmPasses[:len(config.Target.WasmPasses):len(config.Target.WasmPasses)]
+				if config.Options.ICF {
+					passes = append(passes, "icf")
+				}
+				if config.Options.CompressData {
+					passes = append(passes, "compress-data")
+				}
+				if len(passes) != 0 {
+					err = RunWasmPasses(result.Executable, passes)
+					if err != nil {
+						return fmt.Errorf("wasm-passes: %w", err)
+					}
+				}
+
+				// Put the hot functions first for -function-order. This
+				// needs the names of the name section for a profile, so
+				// it must happen before they're rewritten.
+				err = reorderWasmFunctions(result.Executable, config.Options.FunctionOrder, config.Options.FunctionProfile)
+				if err != nil {
+					return fmt.Errorf("-function-order: %w", err)
+				}
+
+				// Make sure that the precompiled libraries don't use features
+				// that the target disables, like bulk-memory in a wasi-libc
+				// that was built with it.
+				if disabled := disabledWasmFeatures(config.Features()); len(disabled) != 0 {
+					err = checkWasmFeatures(result.Executable, disabled)
+					if err != nil {
+						return err
+					}
+				}
+
+				// Demangle or strip the function names in the name section, if
+				// requested. By default the name section is left as the linker
+				// wrote it.
+				if config.WasmNames() != "keep" {
+					err = rewriteWasmNames(result.Executable, config.WasmNames())
+					if err != nil {
+						return fmt.Errorf("could not update name section: %w", err)
+					}
+				}
+
+				// Record how the file was built, for `tinygo inspect`.
+				err = addBuildInfoSection(result.Executable, buildInfo)
+				if err != nil {
+					return fmt.Errorf("could not add build information: %w", err)
+				}
+
+				// Add the function names for the IDs used by -trace-calls.
+				if config.Options.TraceCalls {
+					err = addTraceNamesSection(result.Executable, traceFunctionNames)
+					if err != nil {
+						return fmt.Errorf("could not add trace names: %w", err)
+					}
+				}
+
+				// Describe the counters added by -cover.
+				if config.Options.Cover {
+					err = addCoverageSection(result.Executable, coverageBlocks)
+					if err != nil {
+						return fmt.Errorf("could not add coverage section: %w", err)
+					}
+				}
+
+				// Write the debug build of -debug-output, and strip the
+				// debug information from the output.
+				if config.Options.DebugOutput != "" {
+					err = writeDebugOutput(result.Executable, config.Options.DebugOutput)
+					if err != nil {
+						return fmt.Errorf("could not write debug output: %w", err)
+					}
+				}
 
+				// Add the custom sections computed by -section-hook, which
+				// may depend on everything above.
+				if config.Options.SectionHook != "" {
+					err = runSectionHook(result.Executable, config.Options.SectionHook)
+					if err != nil {
+						return err
+					}
+				}
+
+				// Check that the host will be able to find the entry points
+				// it needs.
+				if len(config.Target.RequiredExports) != 0 {
+					err = checkRequiredExports(result.Executable, config.Target.RequiredExports)
+					if err != nil {
+						return err
+					}
+				}
+				return nil
+			},
+		}
+	}
+
+	// Report on the final executable: code size, size budgets and stack sizes.
+	reportJob := &compileJob{
+		description:  "report",
+		dependencies: []*compileJob{executableJob},
+		run: func(*compileJob) error {
 			// Print code size if requested.
-			if config.Options.PrintSizes == "short" || config.Options.PrintSizes == "full" {
-				packagePathMap := make(map[string]string, len(lprogram.Packages))
-				for _, pkg := range lprogram.Sorted() {
-					packagePathMap[pkg.OriginalDir()] = pkg.Pkg.Path()
+			if printSizes {
+				sizesPath := result.Executable
+				if config.Options.DebugOutput != "" {
+					// The output itself has no debug information anymore.
+					sizesPath = config.Options.DebugOutput
 				}
-				sizes, err := loadProgramSize(result.Executable, packagePathMap)
+				sizes, err := loadProgramSize(sizesPath, packagePathMap)
 				if err != nil {
 					return err
 				}
-				if config.Options.PrintSizes == "short" {
+				if config.Options.PrintSizes == "json" {
+					err := writeSizeReport(os.Stdout, sizes, sizesBeforeWasmOpt)
+					if err != nil {
+						return err
+					}
+				} else if config.Options.PrintSizes == "short" {
 					fmt.Printf("   code    data     bss |   flash     ram\n")
 					fmt.Printf("%7d %7d %7d | %7d %7d\n", sizes.Code+sizes.ROData, sizes.Data, sizes.BSS, sizes.Flash(), sizes.RAM())
 				} else {
@@ -859,6 +1288,71 @@ func Build(pkgName, outpath, tmpdir string, config *compileopts.Config) (BuildRe
 					}
 					fmt.Printf("------------------------------- | --------------- | -------\n")
 					fmt.Printf("%7d %7d %7d %7d | %7d %7d | total\n", sizes.Code, sizes.ROData, sizes.Data, sizes.BSS, sizes.Code+sizes.ROData+sizes.Data, sizes.Data+sizes.BSS)
+					if config.Options.PanicChecks != "" {
+						// Make it clear in the report which packages were
+						// affected, so reports of two builds can be compared.
+						var disabled []string
+						for _, pkg := range lprogram.Sorted() {
+							if !config.PanicChecks(pkg.ImportPath) {
+								disabled = append(disabled, pkg.ImportPath)
+							}
+						}
+						fmt.Printf("runtime checks disabled in: %s\n", strings.Join(disabled, ", "))
+					}
+					if config.Options.SoftFloat != "" {
+						// Show what soft-float costs: the functions that call
+						// the routines (which may have been inlined into other
+						// packages) and the code size of the routines.
+						var packages []string
+						for _, pkg := range lprogram.Sorted() {
+							if config.SoftFloat(pkg.ImportPath) {
+								packages = append(packages, pkg.ImportPath)
+							}
+						}
+						fmt.Printf("soft-float in: %s\n", strings.Join(packages, ", "))
+						fmt.Printf("soft-float called from: %s\n", strings.Join(softFloatCallers, ", "))
+						var routinesSize uint64
+						for _, fn := range sizes.Functions {
+							if transform.IsSoftFloatRoutine(fn.Name) {
+								routinesSize += fn.Size
+							}
+						}
+						fmt.Printf("soft-float routines: %d bytes of code\n", routinesSize)
+					}
+					if sizesBeforeWasmOpt != nil {
+						before := sizesBeforeWasmOpt
+						fmt.Printf("%7d %7d %7d %7d | %7d %7d | total before wasm-opt\n", before.Code, before.ROData, before.Data, before.BSS, before.Flash(), before.RAM())
+					}
+					if len(sizes.Functions) != 0 {
+						fmt.Printf("\n   code | function\n")
+						fmt.Printf("------- | --------\n")
+						for _, fn := range sizes.Functions {
+							fmt.Printf("%7d | %s\n", fn.Size, fn.Name)
+						}
+						if size, count := mapKeyFunctionsSize(sizes.Functions); count != 0 {
+							fmt.Printf("\nmap hash/equal: %d bytes in %d functions, shared by all map types\n", size, count)
+						}
+					}
+				}
+			}
+
+			// Guard against binary size regressions.
+			if config.Options.SizeCompare != "" {
+				if !strings.HasPrefix(config.Triple(), "wasm32-") {
+					return errors.New("-size-compare is only supported for WebAssembly")
+				}
+				err := printSizeComparison(os.Stdout, config.Options.SizeCompare, result.Executable)
+				if err != nil {
+					return err
+				}
+			}
+			if config.Options.SizeBudget != 0 {
+				if !strings.HasPrefix(config.Triple(), "wasm32-") {
+					return errors.New("-size-budget is only supported for WebAssembly")
+				}
+				err := checkSizeBudget(result.Executable, config.Options.SizeBudget)
+				if err != nil {
+					return err
 				}
 			}
 
@@ -874,7 +1368,7 @@ func Build(pkgName, outpath, tmpdir string, config *compileopts.Config) (BuildRe
 	// Run all jobs to compile and link the program.
 	// Do this now (instead of after elf-to-hex and similar conversions) as it
 	// is simpler and cannot be parallelized.
-	err = runJobs(linkJob, config.Options.Semaphore)
+	err = runJobs(reportJob, config.Options.Semaphore)
 	if err != nil {
 		return result, err
 	}
@@ -907,6 +1401,31 @@ func Build(pkgName, outpath, tmpdir string, config *compileopts.Config) (BuildRe
 		if err != nil {
 			return result, err
 		}
+	case "compressed-wasm":
+		// Compressed runtime, as stored on-chain by Substrate based chains.
+		if !strings.HasPrefix(config.Triple(), "wasm32-") {
+			return result, errors.New("compressed WebAssembly output is only supported for WebAssembly")
+		}
+		result.Binary = filepath.Join(tmpdir, "main"+outext)
+		err := writeCompressedWasm(result.Executable, result.Binary)
+		if err != nil {
+			return result, err
+		}
+	case "gzip":
+		result.Binary = filepath.Join(tmpdir, "main"+outext)
+		err := writeGzipFile(result.Executable, result.Binary)
+		if err != nil {
+			return result, err
+		}
+	case "polkavm":
+		if !config.PolkaVM() {
+			return result, errors.New("PolkaVM output is only supported for PolkaVM targets")
+		}
+		result.Binary = filepath.Join(tmpdir, "main"+outext)
+		err := linkPolkaVM(config, result.Executable, result.Binary)
+		if err != nil {
+			return result, err
+		}
 	case "nrf-dfu":
 		// special format for nrfutil for Nordic chips
 		result.Binary = filepath.Join(tmpdir, "main"+outext)
@@ -1030,10 +1549,26 @@ func createEmbedObjectFile(data, hexSum, sourceFile, sourceDir, tmpdir string, c
 // optimizeProgram runs a series of optimizations and transformations that are
 // needed to convert a program to its final form. Some transformations are not
 // optional and must be run as the compiler expects them to run.
-func optimizeProgram(mod llvm.Module, config *compileopts.Config, globalValues map[string]map[string]string) error {
-	err := interp.Run(mod, config.Options.InterpTimeout, config.DumpSSA())
+//
+// With -trace-calls, it returns the names of the traced functions, indexed by
+// their function ID.
+func optimizeProgram(mod llvm.Module, config *compileopts.Config, lprogram *loader.Program, globalValues map[string]map[string]string) ([]string, error) {
+	runtimeInits, err := interp.Run(mod, config.Options.InterpTimeout, config.DumpSSA())
 	if err != nil {
-		return err
+		return nil, err
+	}
+	if config.Options.PrintInits {
+		printRuntimeInits(os.Stderr, runtimeInits)
+	}
+	if config.Options.StrictInit && len(runtimeInits) != 0 {
+		var errs []error
+		for _, init := range runtimeInits {
+			// Keep the traceback, but explain why this is an error.
+			strictErr := *init
+			strictErr.Err = fmt.Errorf("package initializer cannot be evaluated at compile time (-strict-init): %w", init.Err)
+			errs = append(errs, &strictErr)
+		}
+		return nil, newMultiError(errs)
 	}
 	if config.VerifyIR() {
 		// Only verify if we really need it.
@@ -1044,26 +1579,162 @@ func optimizeProgram(mod llvm.Module, config *compileopts.Config, globalValues m
 		// easily costing a few hundred milliseconds. Therefore, only do it when
 		// specifically requested.
 		if err := llvm.VerifyModule(mod, llvm.PrintMessageAction); err != nil {
-			return errors.New("verification error after interpreting runtime.initAll")
+			return nil, errors.New("verification error after interpreting runtime.initAll")
+		}
+	}
+
+	// Measure the package initializers that are left for -report-init.
+	if config.Options.ReportInit {
+		err := transform.InstrumentInits(mod)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// Count the calls to imported functions for -host-call-stats. Like
+	// -report-init, this is done after interp so that only calls made at
+	// runtime are counted.
+	if config.Options.HostCallStats {
+		err := transform.InstrumentHostCalls(mod)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// Instrument all functions for -trace-calls. Like -report-init, this is
+	// done after interp so that functions that only ran at compile time aren't
+	// traced, and before optimizing so that inlined functions are still traced.
+	var traceFunctionNames []string
+	if config.Options.TraceCalls {
+		traceFunctionNames, err = transform.InstrumentCalls(mod)
+		if err != nil {
+			return nil, err
 		}
 	}
 
 	// Insert values from -ldflags="-X ..." into the IR.
 	err = setGlobalValues(mod, globalValues)
 	if err != nil {
-		return err
+		return nil, err
+	}
+
+	// Blocking channel operations are allowed without a scheduler, but will
+	// deadlock if they cannot complete immediately. Point out a deterministic
+	// alternative for those in the program itself.
+	if config.Scheduler() == "none" {
+		goroot := make(map[string]bool)
+		for _, pkg := range lprogram.Sorted() {
+			if pkg.Goroot {
+				goroot[pkg.ImportPath] = true
+			}
+		}
+		transform.ReportBlockingChannelOps(mod, goroot, func(pos token.Position, msg string) {
+			fmt.Fprintln(os.Stderr, pos.String()+": "+msg)
+		})
 	}
 
 	// Run most of the whole-program optimizations (including the whole
 	// O0/O1/O2/Os/Oz optimization pipeline).
 	errs := transform.Optimize(mod, config)
 	if len(errs) > 0 {
-		return newMultiError(errs)
+		return nil, newMultiError(errs)
 	}
 	if err := llvm.VerifyModule(mod, llvm.PrintMessageAction); err != nil {
-		return errors.New("verification failure after LLVM optimization passes")
+		return nil, errors.New("verification failure after LLVM optimization passes")
 	}
 
+	// Fold outlined functions (//go:outline) with identical code.
+	if merged := transform.MergeOutlinedFunctions(mod); len(merged) != 0 && config.Options.PrintSizes == "full" {
+		printMergedFunctions(os.Stdout, merged)
+	}
+
+	// Fold identical functions, if requested.
+	if config.Options.MergeFunctions {
+		merged, err := transform.MergeFunctions(mod)
+		if err != nil {
+			return nil, err
+		}
+		if config.Options.PrintSizes == "full" {
+			printMergedFunctions(os.Stdout, merged)
+		}
+	}
+
+	// Explain why functions are part of the binary, if requested.
+	if config.Options.WhyLive != "" || config.Options.PrintRetained {
+		err := printRetainers(os.Stdout, mod, config.Options.WhyLive, config.Options.PrintRetained)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return traceFunctionNames, nil
+}
+
+// printRuntimeInits prints the package initializers that could not be
+// evaluated at compile time (-print-runtime-init), with the reason why.
+func printRuntimeInits(w io.Writer, runtimeInits []*interp.Error) {
+	fmt.Fprintf(w, "%d package initializers run at runtime\n", len(runtimeInits))
+	for _, init := range runtimeInits {
+		fmt.Fprintf(w, "%s: %s: %s\n", init.Pos, init.ImportPath, init.Err)
+	}
+}
+
+// printMergedFunctions prints the functions that were folded into an identical
+// function by -merge-functions, with the number of instructions saved.
+func printMergedFunctions(w io.Writer, merged []transform.MergedFunction) {
+	total := 0
+	for _, fn := range merged {
+		total += fn.Instructions
+	}
+	fmt.Fprintf(w, "merged %d identical functions, saving %d instructions\n", len(merged), total)
+	for _, fn := range merged {
+		fmt.Fprintf(w, "%7d | %s\n", fn.Instructions, fn.Name)
+	}
+}
+
+// printRetainers prints the chain of references from a root (like an exported
+// function) to the given symbol (-why-live=) or to every function in the
+// program (-print-retained).
+func printRetainers(w io.Writer, mod llvm.Module, whyLive string, printAll bool) error {
+	retainers := transform.FindRetainers(mod)
+	formatChain := func(chain []llvm.Value) string {
+		var names []string
+		for i := len(chain) - 1; i >= 0; i-- {
+			names = append(names, chain[i].Name())
+		}
+		if len(names) == 1 {
+			return names[0] + " (root)"
+		}
+		return strings.Join(names, " <- ")
+	}
+	if whyLive != "" {
+		value := mod.NamedFunction(whyLive)
+		if value.IsNil() {
+			value = mod.NamedGlobal(whyLive)
+		}
+		if value.IsNil() {
+			return fmt.Errorf("-why-live: %s is not part of the program", whyLive)
+		}
+		chain := retainers.Chain(value)
+		if chain == nil {
+			fmt.Fprintf(w, "%s is not referenced by any root\n", whyLive)
+		} else {
+			fmt.Fprintln(w, formatChain(chain))
+		}
+	}
+	if printAll {
+		var lines []string
+		for _, value := range retainers.Live() {
+			if value.IsAFunction().IsNil() || value.IsDeclaration() {
+				continue
+			}
+			lines = append(lines, formatChain(retainers.Chain(value)))
+		}
+		sort.Strings(lines)
+		for _, line := range lines {
+			fmt.Fprintln(w, line)
+		}
+	}
 	return nil
 }
 
@@ -1132,6 +1803,143 @@ func setGlobalValues(mod llvm.Module, globals map[string]map[string]string) erro
 	return nil
 }
 
+// setExtallocImports changes the module and name under which the malloc and
+// free functions of the extalloc GC are imported, according to the
+// extalloc-malloc and extalloc-free target properties. Without these
+// properties, they are imported as env.extalloc and env.extfree, or on targets
+// that link wasi-libc, they are the malloc and free functions of wasi-libc.
+// The import module is always set explicitly, so that the linker doesn't
+// complain about undefined symbols.
+//
+// The functions are also renamed to the import name, so that they can be
+// provided by a C file linked into the program instead of by the host (for
+// example, to use the wasi-libc malloc and free).
+//
+// The function that returns the heap limit is imported in the same way if the
+// extalloc-limit-fn target property is set. Otherwise it is defined to return
+// the extalloc-limit property, which is zero (no limit) if it isn't set.
+func setExtallocImports(mod llvm.Module, spec *compileopts.TargetSpec) error {
+	for _, imp := range []struct {
+		function string
+		libcName string
+		property string
+		value    string
+	}{
+		{"extalloc", "malloc", "extalloc-malloc", spec.ExtallocMalloc},
+		{"extfree", "free", "extalloc-free", spec.ExtallocFree},
+	} {
+		if imp.value == "" && spec.Libc == "wasi-libc" {
+			// The runtime doesn't override the wasi-libc allocator when
+			// using the extalloc GC, so it can be used directly.
+			imp.value = "env." + imp.libcName
+		} else if imp.value == "" {
+			imp.value = "env." + imp.function
+		}
+		err := setExtallocImport(mod, imp.function, imp.property, imp.value)
+		if err != nil {
+			return err
+		}
+	}
+
+	if spec.ExtallocLimitFn != "" {
+		return setExtallocImport(mod, "extlimit", "extalloc-limit-fn", spec.ExtallocLimitFn)
+	}
+	fn := mod.NamedFunction("extlimit")
+	if fn.IsNil() {
+		return nil // heap limit not used
+	}
+	fn.RemoveStringAttributeAtIndex(-1, "wasm-import-module")
+	fn.RemoveStringAttributeAtIndex(-1, "wasm-import-name")
+	fn.SetLinkage(llvm.InternalLinkage)
+	fn.SetVisibility(llvm.DefaultVisibility)
+	ctx := mod.Context()
+	builder := ctx.NewBuilder()
+	defer builder.Dispose()
+	builder.SetInsertPointAtEnd(ctx.AddBasicBlock(fn, "entry"))
+	builder.CreateRet(llvm.ConstInt(fn.GlobalValueType().ReturnType(), spec.ExtallocLimit, false))
+	return nil
+}
+
+// setExtallocBuckets tells the extalloc GC how the external allocator rounds up
+// allocations, according to the extalloc-bucket and extalloc-header target
+// properties. The GC uses this to give large objects the whole bucket
+// and to report the memory that is actually reserved.
+func setExtallocBuckets(mod llvm.Module, spec *compileopts.TargetSpec) error {
+	if spec.ExtallocBucket&(spec.ExtallocBucket-1) != 0 {
+		return fmt.Errorf("target property extalloc-bucket: expected a power of two, got %d", spec.ExtallocBucket)
+	}
+	for _, global := range []struct {
+		name  string
+		value uint64
+	}{
+		{"runtime.extallocBucket", spec.ExtallocBucket},
+		{"runtime.extallocHeader", spec.ExtallocHeader},
+	} {
+		g := mod.NamedGlobal(global.name)
+		if g.IsNil() || global.value == 0 {
+			continue
+		}
+		g.SetInitializer(llvm.ConstInt(g.GlobalValueType(), global.value, false))
+	}
+	return nil
+}
+
+// goHelperFunctions lists the C library functions of each group of the
+// go-helpers target property.
+var goHelperFunctions = map[string][]string{
+	"mem":    {"memcpy", "memmove", "memset"},
+	"string": {"strlen", "memcmp", "bcmp"},
+	"math":   {"exp", "exp2", "log", "expf", "exp2f", "logf"},
+}
+
+// setRuntimeHelpers prepares C library functions implemented by the runtime
+// for linking, for the mem-routines and go-helpers target properties. The
+// backend only emits calls to some of them when it lowers memory intrinsics,
+// and the C library may call them, so they must be kept until then. They also
+// must not be optimized as if they were the C library functions: their loops
+// would be replaced with calls to themselves.
+func setRuntimeHelpers(mod llvm.Module, names []string) error {
+	noBuiltins := mod.Context().CreateStringAttribute("no-builtins", "")
+	for _, name := range names {
+		fn := mod.NamedFunction(name)
+		if fn.IsNil() || fn.IsDeclaration() {
+			return fmt.Errorf("%s is not defined by the runtime", name)
+		}
+		fn.AddFunctionAttr(noBuiltins)
+		llvmutil.AppendToGlobal(mod, "llvm.used", fn)
+	}
+	return nil
+}
+
+// setExtallocImport imports the given runtime function of the extalloc GC
+// under the import in value, which is in the form module.name.
+func setExtallocImport(mod llvm.Module, function, property, value string) error {
+	module, name, ok := strings.Cut(value, ".")
+	if !ok || module == "" || name == "" {
+		return fmt.Errorf("target property %s: expected an import in the form module.name, got %#v", property, value)
+	}
+	fn := mod.NamedFunction(function)
+	if fn.IsNil() {
+		return nil // function not used
+	}
+	if existing := mod.NamedFunction(name); !existing.IsNil() && existing != fn {
+		// Already declared (or defined) elsewhere, use that function.
+		if existing.GlobalValueType() != fn.GlobalValueType() {
+			return fmt.Errorf("target property %s: %s has a different signature than expected", property, name)
+		}
+		fn.ReplaceAllUsesWith(existing)
+		fn.EraseFromParentAsFunction()
+		return nil
+	}
+	fn.SetName(name)
+	ctx := mod.Context()
+	fn.RemoveStringAttributeAtIndex(-1, "wasm-import-module")
+	fn.RemoveStringAttributeAtIndex(-1, "wasm-import-name")
+	fn.AddFunctionAttr(ctx.CreateStringAttribute("wasm-import-module", module))
+	fn.AddFunctionAttr(ctx.CreateStringAttribute("wasm-import-name", name))
+	return nil
+}
+
 // functionStackSizes keeps stack size information about a single function
 // (usually a goroutine).
 type functionStackSize struct {
@@ -0,0 +1,225 @@
+package builder
+
+// This file adds the tinygo.build custom section to WebAssembly files. It
+// records how a file was built: the compiler and LLVM version, the target and
+// the options that affect the generated code. This makes it possible for an
+// auditor to check how a given WebAssembly blob was produced, with
+// `tinygo inspect`.
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/google/shlex"
+	"github.com/tinygo-org/tinygo/compileopts"
+	"github.com/tinygo-org/tinygo/goenv"
+	"tinygo.org/x/go-llvm"
+)
+
+// Name of the custom section with the build information.
+const buildInfoSectionName = "tinygo.build"
+
+// BuildInfo describes how a WebAssembly file was built. It is stored as JSON in
+// the tinygo.build custom section. It doesn't contain any paths or timestamps,
+// so that it doesn't get in the way of reproducible builds.
+type BuildInfo struct {
+	Version       string   `json:"version"`         // TinyGo version
+	Fork          string   `json:"fork,omitempty"`  // version of the LimeChain fork
+	LLVMVersion   string   `json:"llvm"`            // LLVM version
+	Target        string   `json:"target"`          // -target flag, or GOOS/GOARCH
+	GC            string   `json:"gc"`              // GC in use
+	Scheduler     string   `json:"scheduler"`       // scheduler in use
+	Opt           string   `json:"opt"`             // optimization level
+	PanicStrategy string   `json:"panic"`           // panic strategy
+	Flags         []string `json:"flags,omitempty"` // other flags that affect the output
+}
+
+// newBuildInfo returns the build information for the given configuration.
+func newBuildInfo(config *compileopts.Config) BuildInfo {
+	target := config.Options.Target
+	if target == "" {
+		target = config.GOOS() + "/" + config.GOARCH()
+	}
+	optLevel, _, _ := config.OptLevel()
+	options := config.Options
+	var flags []string
+	addFlag := func(enabled bool, flag string) {
+		if enabled {
+			flags = append(flags, flag)
+		}
+	}
+	addFlag(len(options.Tags) != 0, "-tags="+strings.Join(options.Tags, ","))
+	addFlag(options.Maps != "", "-maps="+options.Maps)
+	addFlag(options.PanicChecks != "", "-panic-checks="+options.PanicChecks)
+	addFlag(options.HostHashing != "", "-host-hashing="+options.HostHashing)
+	addFlag(options.HostCrypto != "", "-host-crypto="+options.HostCrypto)
+	addFlag(options.LogLevel != "", "-log-level="+options.LogLevel)
+	addFlag(options.StackSize != 0, fmt.Sprintf("-stack-size=%d", options.StackSize))
+	addFlag(options.YieldPoints != 0, fmt.Sprintf("-yield-points=%d", options.YieldPoints))
+	addFlag(options.InlineBudget != 0, fmt.Sprintf("-inline-budget=%d", options.InlineBudget))
+	addFlag(options.FunctionOrder != "" && options.FunctionOrder != "source", "-function-order="+options.FunctionOrder)
+	addFlag(options.FunctionProfile != "", "-function-profile="+filepath.Base(options.FunctionProfile))
+	addFlag(config.WasmNames() != "keep", "-names="+config.WasmNames())
+	addFlag(options.LowerFmt, "-lower-fmt")
+	addFlag(options.FPDeterministic, "-fp-deterministic")
+	addFlag(options.SoftFloat != "", "-soft-float="+options.SoftFloat)
+	addFlag(options.MergeFunctions, "-merge-functions")
+	addFlag(options.SplitCold, "-split-cold")
+	addFlag(options.ICF, "-icf")
+	addFlag(options.CompressData, "-compress-data")
+	addFlag(options.SectionHook != "", "-section-hook="+sectionHookName(options.SectionHook))
+	addFlag(options.DebugOutput != "", "-debug-output="+filepath.Base(options.DebugOutput))
+	addFlag(options.CrashDump, "-crash-dump")
+	addFlag(options.HostCallStats, "-host-call-stats")
+	addFlag(options.Redzone != 0, fmt.Sprintf("-redzone=%d", options.Redzone))
+	addFlag(options.Selfcheck, "-selfcheck")
+	addFlag(options.HeapSnapshot, "-heap-snapshot")
+	addFlag(options.Exports != "", "-exports="+options.Exports)
+	addFlag(options.InterfaceGC != "", "-interface-gc="+options.InterfaceGC)
+	addFlag(options.TraceCalls, "-trace-calls")
+	addFlag(options.ReportInit, "-report-init")
+	addFlag(options.HostArgs, "-host-args")
+	addFlag(options.HostTicks, "-host-ticks")
+	addFlag(options.HostInput, "-host-input")
+	addFlag(options.Cover, "-cover")
+	addFlag(options.Reproducible, "-reproducible")
+	addFlag(!options.Debug, "-no-debug")
+	var globals []string
+	for pkgPath, values := range options.GlobalValues {
+		for name := range values {
+			// Only the names, the values may be secret.
+			globals = append(globals, "-X="+pkgPath+"."+name)
+		}
+	}
+	sort.Strings(globals)
+	flags = append(flags, globals...)
+	return BuildInfo{
+		Version:       goenv.Version(),
+		Fork:          goenv.ForkVersion(),
+		LLVMVersion:   llvm.Version,
+		Target:        target,
+		GC:            config.GC(),
+		Scheduler:     config.Scheduler(),
+		Opt:           optLevel,
+		PanicStrategy: config.PanicStrategy(),
+		Flags:         flags,
+	}
+}
+
+// sectionHookName returns the file name of the command of a -section-hook, for
+// the build information. The full command line usually contains paths, which
+// differ between machines.
+func sectionHookName(hook string) string {
+	args, err := shlex.Split(hook)
+	if err != nil || len(args) == 0 {
+		return ""
+	}
+	return filepath.Base(args[0])
+}
+
+// addBuildInfoSection adds the tinygo.build custom section to the WebAssembly
+// file at the given path.
+func addBuildInfoSection(path string, info BuildInfo) error {
+	payload, err := json.Marshal(info)
+	if err != nil {
+		return err
+	}
+	return updateWasmFile(path, func(sections []wasmSection) ([]wasmSection, error) {
+		return append(sections, wasmSection{
+			id:      wasmSectionCustom,
+			name:    buildInfoSectionName,
+			payload: payload,
+		}), nil
+	})
+}
+
+// ReadBuildInfo reads the build information from the WebAssembly file at the
+// given path.
+func ReadBuildInfo(path string) (*BuildInfo, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	sections, err := readWasmSections(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	info, err := readBuildInfoSection(sections)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	if info == nil {
+		return nil, errors.New(path + ": no build information, the file was not built by TinyGo or the section was stripped")
+	}
+	return info, nil
+}
+
+// readBuildInfoSection parses the tinygo.build custom section. It returns nil
+// if there is no such section.
+func readBuildInfoSection(sections []wasmSection) (*BuildInfo, error) {
+	for _, section := range sections {
+		if section.id != wasmSectionCustom || section.name != buildInfoSectionName {
+			continue
+		}
+		info := &BuildInfo{}
+		if err := json.Unmarshal(section.payload, info); err != nil {
+			return nil, fmt.Errorf("invalid %s section: %w", buildInfoSectionName, err)
+		}
+		return info, nil
+	}
+	return nil, nil
+}
+
+// String formats the build information in a human readable way, one property
+// per line.
+func (info *BuildInfo) String() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "version:   %s\n", info.Version)
+	if info.Fork != "" {
+		fmt.Fprintf(&b, "fork:      %s\n", info.Fork)
+	}
+	fmt.Fprintf(&b, "llvm:      %s\n", info.LLVMVersion)
+	fmt.Fprintf(&b, "target:    %s\n", info.Target)
+	fmt.Fprintf(&b, "gc:        %s\n", info.GC)
+	fmt.Fprintf(&b, "scheduler: %s\n", info.Scheduler)
+	fmt.Fprintf(&b, "opt:       %s\n", info.Opt)
+	fmt.Fprintf(&b, "panic:     %s\n", info.PanicStrategy)
+	if len(info.Flags) != 0 {
+		fmt.Fprintf(&b, "flags:     %s\n", strings.Join(info.Flags, " "))
+	}
+	return b.String()
+}
+
+// runtimeVersion returns the string returned by runtime.Version in the
+// program, like "0.31.2-limechain.1 gc=leaking target=wasm-unknown".
+func (info *BuildInfo) runtimeVersion() string {
+	return info.Fork + " gc=" + info.GC + " target=" + info.Target
+}
+
+// runtimeSettings returns the build settings returned by debug.ReadBuildInfo
+// in the program, as key=value pairs on separate lines. The keys are the same
+// as in the Go toolchain where possible.
+func (info *BuildInfo) runtimeSettings(config *compileopts.Config) string {
+	settings := []string{
+		"-compiler=tinygo",
+		"GOOS=" + config.GOOS(),
+		"GOARCH=" + config.GOARCH(),
+		"tinygo.version=" + info.Version,
+		"tinygo.fork=" + info.Fork,
+		"tinygo.llvm=" + info.LLVMVersion,
+		"tinygo.target=" + info.Target,
+		"tinygo.gc=" + info.GC,
+		"tinygo.scheduler=" + info.Scheduler,
+		"tinygo.opt=" + info.Opt,
+		"tinygo.panic=" + info.PanicStrategy,
+	}
+	if len(info.Flags) != 0 {
+		settings = append(settings, "tinygo.flags="+strings.Join(info.Flags, " "))
+	}
+	return strings.Join(settings, "\n")
+}
@@ -0,0 +1,80 @@
+package builder
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/tinygo-org/tinygo/compileopts"
+)
+
+func TestBuildInfoSection(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test.wasm")
+	if err := os.WriteFile(path, makeTestWasmModule(), 0o666); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ReadBuildInfo(path); err == nil {
+		t.Error("expected an error for a file without build information")
+	}
+
+	info := BuildInfo{
+		Version:       "0.31.2",
+		Fork:          "0.31.2-limechain.1",
+		LLVMVersion:   "17.0.1",
+		Target:        "polkawasm-wasi",
+		GC:            "extalloc",
+		Scheduler:     "none",
+		Opt:           "z",
+		PanicStrategy: "trap",
+		Flags:         []string{"-reproducible", "-no-debug"},
+	}
+	if err := addBuildInfoSection(path, info); err != nil {
+		t.Fatal("could not add build information:", err)
+	}
+	read, err := ReadBuildInfo(path)
+	if err != nil {
+		t.Fatal("could not read build information:", err)
+	}
+	if !reflect.DeepEqual(*read, info) {
+		t.Errorf("unexpected build information: %+v", *read)
+	}
+	expected := "version:   0.31.2\n" +
+		"fork:      0.31.2-limechain.1\n" +
+		"llvm:      17.0.1\n" +
+		"target:    polkawasm-wasi\n" +
+		"gc:        extalloc\n" +
+		"scheduler: none\n" +
+		"opt:       z\n" +
+		"panic:     trap\n" +
+		"flags:     -reproducible -no-debug\n"
+	if s := read.String(); s != expected {
+		t.Errorf("unexpected formatting:\n%s", s)
+	}
+}
+
+func TestBuildInfoFlags(t *testing.T) {
+	// Flags with their default value aren't recorded, and flags with a path
+	// only record the file name.
+	config := &compileopts.Config{
+		Options: &compileopts.Options{
+			Opt:             "z",
+			Debug:           true,
+			WasmNames:       "keep",
+			FunctionProfile: "/home/user/profiles/order.txt",
+			SectionHook:     `"/home/user/hooks/sign hook.go" -key /home/user/key.pem`,
+			DebugOutput:     "/home/user/build/debug.wasm",
+		},
+		Target: &compileopts.TargetSpec{GOOS: "linux", GOARCH: "arm"},
+	}
+	expected := []string{"-function-profile=order.txt", "-section-hook=sign hook.go", "-debug-output=debug.wasm"}
+	if flags := newBuildInfo(config).Flags; !reflect.DeepEqual(flags, expected) {
+		t.Errorf("unexpected flags:\nexpected: %q\nactual:   %q", expected, flags)
+	}
+
+	config.Options.WasmNames = "strip"
+	expected = append([]string{"-function-profile=order.txt", "-names=strip"}, expected[1:]...)
+	if flags := newBuildInfo(config).Flags; !reflect.DeepEqual(flags, expected) {
+		t.Errorf("unexpected flags:\nexpected: %q\nactual:   %q", expected, flags)
+	}
+}
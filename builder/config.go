@@ -1,10 +1,14 @@
 package builder
 
 import (
+	"errors"
 	"fmt"
+	"strconv"
+	"strings"
 
 	"github.com/tinygo-org/tinygo/compileopts"
 	"github.com/tinygo-org/tinygo/goenv"
+	"tinygo.org/x/go-llvm"
 )
 
 // NewConfig builds a new Config object from a set of compiler options. It also
@@ -17,6 +21,157 @@ func NewConfig(options *compileopts.Options) (*compileopts.Config, error) {
 		return nil, err
 	}
 
+	if options.TraceCalls && !strings.HasPrefix(spec.Triple, "wasm32-") {
+		return nil, errors.New("-trace-calls is only supported for WebAssembly")
+	}
+	if options.ReportInit && !strings.HasPrefix(spec.Triple, "wasm32-") {
+		return nil, errors.New("-report-init is only supported for WebAssembly")
+	}
+	if options.HostHashing != "" && !strings.HasPrefix(spec.Triple, "wasm32-") {
+		return nil, errors.New("-host-hashing is only supported for WebAssembly")
+	}
+	if options.HostCrypto != "" && !strings.HasPrefix(spec.Triple, "wasm32-") {
+		return nil, errors.New("-host-crypto is only supported for WebAssembly")
+	}
+	if options.Cover && !strings.HasPrefix(spec.Triple, "wasm32-") {
+		return nil, errors.New("-cover is only supported for WebAssembly")
+	}
+	if options.Cover && options.Reproducible {
+		return nil, errors.New("-cover can't be used with -reproducible")
+	}
+	if options.Cover && !options.Debug {
+		return nil, errors.New("-cover needs debug information, it can't be used with -no-debug")
+	}
+	if options.SplitCold && !strings.HasPrefix(spec.Triple, "wasm32-") {
+		return nil, errors.New("-split-cold is only supported for WebAssembly")
+	}
+	if options.ICF && !strings.HasPrefix(spec.Triple, "wasm32-") {
+		return nil, errors.New("-icf is only supported for WebAssembly")
+	}
+	if options.CompressData && !strings.HasPrefix(spec.Triple, "wasm32-") {
+		return nil, errors.New("-compress-data is only supported for WebAssembly")
+	}
+	if options.SectionHook != "" && !strings.HasPrefix(spec.Triple, "wasm32-") {
+		return nil, errors.New("-section-hook is only supported for WebAssembly")
+	}
+	if options.DebugOutput != "" && !strings.HasPrefix(spec.Triple, "wasm32-") {
+		return nil, errors.New("-debug-output is only supported for WebAssembly")
+	}
+	if options.DebugOutput != "" && !options.Debug {
+		return nil, errors.New("-debug-output needs debug information, it can't be used with -no-debug")
+	}
+	if options.CrashDump && !strings.HasPrefix(spec.Triple, "wasm32-") {
+		return nil, errors.New("-crash-dump is only supported for WebAssembly")
+	}
+	if options.HostCallStats && !strings.HasPrefix(spec.Triple, "wasm32-") {
+		return nil, errors.New("-host-call-stats is only supported for WebAssembly")
+	}
+	if options.Redzone != 0 && !strings.HasPrefix(spec.Triple, "wasm32-") {
+		return nil, errors.New("-redzone is only supported for WebAssembly")
+	}
+	if stackSize := wasmStackSize(spec.LDFlags); options.Redzone != 0 && uint64(options.Redzone) >= stackSize {
+		// The redzone is part of the system stack, so it must leave some of
+		// the stack for the program to use.
+		return nil, fmt.Errorf("-redzone=%d must be smaller than the system stack of %d bytes", options.Redzone, stackSize)
+	}
+	if options.Selfcheck && !strings.HasPrefix(spec.Triple, "wasm32-") {
+		return nil, errors.New("-selfcheck is only supported for WebAssembly")
+	}
+	if options.Exports != "" && !strings.HasPrefix(spec.Triple, "wasm32-") {
+		return nil, errors.New("-exports is only supported for WebAssembly")
+	}
+	if options.GCDiff && !strings.HasPrefix(spec.Triple, "wasm32-") {
+		return nil, errors.New("-gc-diff is only supported for WebAssembly")
+	}
+	if options.FPDeterministic && !strings.HasPrefix(spec.Triple, "wasm32-") {
+		return nil, errors.New("-fp-deterministic is only supported for WebAssembly")
+	}
+	if options.SoftFloat != "" && !strings.HasPrefix(spec.Triple, "wasm32-") {
+		return nil, errors.New("-soft-float is only supported for WebAssembly")
+	}
+	if options.InlineBudget != 0 && !strings.HasPrefix(spec.Triple, "wasm32-") {
+		return nil, errors.New("-inline-budget is only supported for WebAssembly")
+	}
+	if options.FunctionOrder != "" && options.FunctionOrder != "source" && !strings.HasPrefix(spec.Triple, "wasm32-") {
+		return nil, errors.New("-function-order is only supported for WebAssembly")
+	}
+	if options.FunctionOrder != "" && options.FunctionOrder != "source" && options.DebugOutput != "" {
+		return nil, errors.New("-function-order=" + options.FunctionOrder + " can't be used with -debug-output, it removes the debug information")
+	}
+	if options.HostArgs && !hasBuildTag(spec.BuildTags, "wasm_unknown") {
+		return nil, errors.New("-host-args is only supported for wasm-unknown targets, other targets read their arguments from the system")
+	}
+	if options.HostTicks && !hasBuildTag(spec.BuildTags, "wasm_unknown") {
+		return nil, errors.New("-host-ticks is only supported for wasm-unknown targets, other targets have a clock")
+	}
+	if options.HostInput && !hasBuildTag(spec.BuildTags, "wasm_unknown") {
+		return nil, errors.New("-host-input is only supported for wasm-unknown targets, other targets read their input from the system")
+	}
+	if (options.GC == "extalloc" || options.GC == "" && spec.GC == "extalloc") && !strings.HasPrefix(spec.Triple, "wasm32-") && spec.BinaryFormat != "polkavm" {
+		return nil, errors.New("-gc=extalloc is only supported for WebAssembly and PolkaVM")
+	}
+	if spec.BinaryFormat == "polkavm" && options.GC != "" && options.GC != "extalloc" {
+		// The heap is only grown through polkavm_malloc, see runtime_polkavm.go.
+		return nil, errors.New("PolkaVM targets only support -gc=extalloc")
+	}
+	if spec.BinaryFormat == "polkavm" {
+		// LLVM generates code for RV32E (and the ilp32e ABI) from LLVM 18.
+		// Older versions fail with "Codegen not yet implemented for RV32E".
+		major, _ := strconv.Atoi(strings.Split(llvm.Version, ".")[0])
+		if major < 18 {
+			return nil, fmt.Errorf("PolkaVM targets need LLVM 18 or later, this TinyGo uses LLVM %s", llvm.Version)
+		}
+	}
+	if hasBuildTag(spec.BuildTags, "tinygo.threads") {
+		// Only the extalloc GC locks its data structures, and the schedulers
+		// and heap snapshots don't know about other workers.
+		if options.GC != "" && options.GC != "extalloc" || options.GC == "" && spec.GC != "extalloc" {
+			return nil, errors.New("threads are only supported with -gc=extalloc")
+		}
+		if options.Scheduler != "" && options.Scheduler != "none" {
+			return nil, errors.New("threads are only supported with -scheduler=none")
+		}
+		if options.HeapSnapshot {
+			return nil, errors.New("-heap-snapshot is not supported with threads")
+		}
+	}
+	if spec.MemRoutines != "" && spec.MemRoutines != "libc" && spec.MemRoutines != "deterministic" {
+		return nil, fmt.Errorf("target property mem-routines: unknown value %#v, expected libc or deterministic", spec.MemRoutines)
+	}
+	if spec.MemRoutines == "deterministic" && !strings.HasPrefix(spec.Triple, "wasm32-") {
+		return nil, errors.New("target property mem-routines: deterministic is only supported for WebAssembly")
+	}
+	for _, group := range spec.GoHelpers {
+		if _, ok := goHelperFunctions[group]; !ok {
+			return nil, fmt.Errorf("target property go-helpers: unknown group %#v, expected mem, string or math", group)
+		}
+		if !strings.HasPrefix(spec.Triple, "wasm32-") {
+			return nil, errors.New("target property go-helpers is only supported for WebAssembly")
+		}
+		if group == "mem" && spec.MemRoutines == "libc" {
+			return nil, errors.New("target property go-helpers: the mem group conflicts with mem-routines set to libc")
+		}
+	}
+	if options.HeapSnapshot && options.GC != "extalloc" && !(options.GC == "" && spec.GC == "extalloc") {
+		return nil, errors.New("-heap-snapshot is only supported with -gc=extalloc")
+	}
+	if options.HeapSnapshot && spec.Libc == "wasi-libc" && spec.ExtallocMalloc == "" {
+		// The state of the wasi-libc allocator is stored in the globals, which
+		// would be rolled back without the memory it manages.
+		return nil, errors.New("-heap-snapshot needs an external allocator outside of the module, set the extalloc-malloc and extalloc-free target properties")
+	}
+	for _, pass := range spec.WasmPasses {
+		if !strings.HasPrefix(spec.Triple, "wasm32-") {
+			return nil, errors.New("target property wasm-passes is only supported for WebAssembly")
+		}
+		if !IsWasmPass(pass) {
+			return nil, fmt.Errorf("target property wasm-passes: unknown pass %#v", pass)
+		}
+	}
+	if len(spec.WasmOptFlags) != 0 && !strings.HasPrefix(spec.Triple, "wasm32-") {
+		return nil, errors.New("target property wasm-opt-flags is only supported for WebAssembly")
+	}
+
 	if options.OpenOCDCommands != nil {
 		// Override the OpenOCDCommands from the target spec if specified on
 		// the command-line
@@ -40,3 +195,32 @@ func NewConfig(options *compileopts.Options) (*compileopts.Config, error) {
 		TestConfig:     options.TestConfig,
 	}, nil
 }
+
+// hasBuildTag returns whether tag is one of the given build tags.
+func hasBuildTag(tags []string, tag string) bool {
+	for _, t := range tags {
+		if t == tag {
+			return true
+		}
+	}
+	return false
+}
+
+// wasmStackSize returns the size of the system stack of a WebAssembly program,
+// as set with the -z stack-size linker flag. wasm-ld uses a single page (64kB)
+// if the flag isn't set.
+func wasmStackSize(ldflags []string) uint64 {
+	size := uint64(65536)
+	for i, flag := range ldflags {
+		if flag == "-z" && i+1 < len(ldflags) {
+			flag = "-z" + ldflags[i+1]
+		}
+		if !strings.HasPrefix(flag, "-zstack-size=") {
+			continue
+		}
+		if n, err := strconv.ParseUint(flag[len("-zstack-size="):], 0, 64); err == nil {
+			size = n
+		}
+	}
+	return size
+}
@@ -0,0 +1,19 @@
+package builder
+
+import "testing"
+
+func TestWasmStackSize(t *testing.T) {
+	for _, tc := range []struct {
+		ldflags []string
+		size    uint64
+	}{
+		{[]string{"--stack-first", "--no-entry"}, 65536},
+		{[]string{"--stack-first", "-zstack-size=131072"}, 131072},
+		{[]string{"-z", "stack-size=0x8000", "--stack-first"}, 32768},
+		{[]string{"-z", "--no-entry"}, 65536},
+	} {
+		if size := wasmStackSize(tc.ldflags); size != tc.size {
+			t.Errorf("%q: expected a stack size of %d, got %d", tc.ldflags, tc.size, size)
+		}
+	}
+}
@@ -0,0 +1,102 @@
+package builder
+
+// This file connects -cover to the build: it decides which source files get
+// coverage counters and describes the counters in the tinygo.coverage custom
+// section, which the host uses to turn the counters into a coverage profile.
+//
+// The tinygo.coverage section starts with a vector of file names (as they
+// appear in a coverage profile: the import path followed by the file name),
+// followed by a vector of blocks, one for each counter. Each block consists of
+// a file index, start line, start column, end line, end column and number of
+// statements, all as unsigned LEB128 values.
+
+import (
+	"path/filepath"
+	"strings"
+
+	"github.com/tinygo-org/tinygo/compileopts"
+	"github.com/tinygo-org/tinygo/loader"
+	"github.com/tinygo-org/tinygo/transform"
+)
+
+// coverageFileNames returns a function for transform.InstrumentCoverage that
+// selects the files to cover. These are the non-test files in the packages
+// matching -coverpkg, or in the main package if no -coverpkg flag was given.
+func coverageFileNames(config *compileopts.Config, lprogram *loader.Program) func(path string) (string, bool) {
+	var patterns []string
+	if config.Options.CoverPackages != "" {
+		patterns = strings.Split(config.Options.CoverPackages, ",")
+	}
+	mainDir := lprogram.MainPkg().OriginalDir()
+	importPaths := make(map[string]string) // map from directory to import path
+	for _, pkg := range lprogram.Sorted() {
+		dir := pkg.OriginalDir()
+		importPath := strings.TrimSuffix(pkg.ImportPath, ".test")
+		if patterns == nil && dir != mainDir || patterns != nil && !matchPackagePatterns(patterns, importPath) {
+			continue
+		}
+		if _, ok := importPaths[dir]; !ok {
+			importPaths[dir] = importPath
+		}
+	}
+	return func(path string) (string, bool) {
+		if strings.HasSuffix(path, "_test.go") {
+			return "", false
+		}
+		importPath, ok := importPaths[filepath.Dir(path)]
+		if !ok {
+			return "", false
+		}
+		return importPath + "/" + filepath.Base(path), true
+	}
+}
+
+// matchPackagePatterns returns whether the import path matches one of the
+// patterns. A pattern is either an import path, or an import path followed by
+// "/..." to also match all packages below it. The pattern "all" matches all
+// packages.
+func matchPackagePatterns(patterns []string, importPath string) bool {
+	for _, pattern := range patterns {
+		pattern = strings.TrimSpace(pattern)
+		if pattern == "all" || pattern == importPath {
+			return true
+		}
+		if strings.HasSuffix(pattern, "/...") {
+			prefix := strings.TrimSuffix(pattern, "/...")
+			if importPath == prefix || strings.HasPrefix(importPath, prefix+"/") {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// addCoverageSection adds the tinygo.coverage custom section that describes
+// the coverage counters.
+func addCoverageSection(path string, blocks []transform.CoverageBlock) error {
+	return updateWasmFile(path, func(sections []wasmSection) ([]wasmSection, error) {
+		var files []string
+		fileIndices := make(map[string]int)
+		for _, block := range blocks {
+			if _, ok := fileIndices[block.File]; !ok {
+				fileIndices[block.File] = len(files)
+				files = append(files, block.File)
+			}
+		}
+		payload := appendULEB128(nil, uint64(len(files)))
+		for _, file := range files {
+			payload = appendWasmName(payload, file)
+		}
+		payload = appendULEB128(payload, uint64(len(blocks)))
+		for _, block := range blocks {
+			for _, value := range []uint32{uint32(fileIndices[block.File]), block.StartLine, block.StartCol, block.EndLine, block.EndCol, block.NumStmts} {
+				payload = appendULEB128(payload, uint64(value))
+			}
+		}
+		return append(sections, wasmSection{
+			id:      wasmSectionCustom,
+			name:    "tinygo.coverage",
+			payload: payload,
+		}), nil
+	})
+}
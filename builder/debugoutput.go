@@ -0,0 +1,138 @@
+package builder
+
+// This file implements the -debug-output flag, which writes a companion debug
+// build next to the release build. Both are created from the same linked
+// module, so that they contain exactly the same code and a trap in the release
+// build can be symbolized with the debug build.
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// debugMapping is the mapping file written next to the debug build. It lists
+// the functions of the release build with their offsets, so that an offset in
+// a trap report can be resolved to a function without any other tool.
+type debugMapping struct {
+	Code              string                 `json:"code"`              // SHA-256 of the code section, the same in both builds
+	ReleaseCodeOffset int                    `json:"releaseCodeOffset"` // file offset of the code section contents
+	DebugCodeOffset   int                    `json:"debugCodeOffset"`   // same, in the debug build (DWARF addresses are relative to it)
+	Functions         []debugMappingFunction `json:"functions"`
+}
+
+// debugMappingFunction is a single function in the code section.
+type debugMappingFunction struct {
+	Index  uint32 `json:"index"`
+	Name   string `json:"name,omitempty"`
+	Offset int    `json:"offset"` // file offset of the body in the release build
+	Size   int    `json:"size"`   // size of the body, excluding its size prefix
+}
+
+// isWasmDebugSection returns whether the section only contains information
+// for debugging, which is removed from the release build.
+func isWasmDebugSection(section wasmSection) bool {
+	if section.id != wasmSectionCustom {
+		return false
+	}
+	return section.name == "name" || section.name == "sourceMappingURL" || strings.HasPrefix(section.name, ".debug_")
+}
+
+// writeDebugOutput writes the WebAssembly file at path to debugPath, and then
+// removes the names and DWARF debug information from the file at path. It also
+// writes the mapping file, which has the same name as debugPath but with a
+// .map.json extension.
+func writeDebugOutput(path, debugPath string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	sections, err := readWasmSections(data)
+	if err != nil {
+		return err
+	}
+	var releaseSections []wasmSection
+	for _, section := range sections {
+		if !isWasmDebugSection(section) {
+			releaseSections = append(releaseSections, section)
+		}
+	}
+	debug := writeWasmSections(sections)
+	release := writeWasmSections(releaseSections)
+
+	mapping := debugMapping{
+		ReleaseCodeOffset: wasmCodeOffset(releaseSections),
+		DebugCodeOffset:   wasmCodeOffset(sections),
+	}
+	names, err := readWasmFunctionNames(sections)
+	if err != nil {
+		return err
+	}
+	numImports, err := countWasmFunctionImports(sections)
+	if err != nil {
+		return err
+	}
+	for _, section := range releaseSections {
+		if section.id != wasmSectionCode {
+			continue
+		}
+		sum := sha256.Sum256(section.payload)
+		mapping.Code = hex.EncodeToString(sum[:])
+
+		// Read the size prefixes directly, as the linker may have padded them.
+		data := section.payload
+		count, n, err := decodeULEB128(data)
+		if err != nil {
+			return err
+		}
+		offset := n
+		for i := uint64(0); i < count; i++ {
+			size, n, err := decodeULEB128(data[offset:])
+			if err != nil {
+				return err
+			}
+			offset += n
+			if uint64(len(data)-offset) < size {
+				return errors.New("function body extends beyond the end of the code section")
+			}
+			index := numImports + uint32(i)
+			mapping.Functions = append(mapping.Functions, debugMappingFunction{
+				Index:  index,
+				Name:   names[index],
+				Offset: mapping.ReleaseCodeOffset + offset,
+				Size:   int(size),
+			})
+			offset += int(size)
+		}
+	}
+	mappingData, err := json.MarshalIndent(mapping, "", "\t")
+	if err != nil {
+		return err
+	}
+
+	mappingPath := strings.TrimSuffix(debugPath, filepath.Ext(debugPath)) + ".map.json"
+	err = os.WriteFile(mappingPath, append(mappingData, '\n'), 0666)
+	if err != nil {
+		return err
+	}
+	err = os.WriteFile(debugPath, debug, 0666)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, release, 0666)
+}
+
+// wasmCodeOffset returns the offset of the contents of the code section in the
+// module as written by writeWasmSections, or -1 if there is no code section.
+func wasmCodeOffset(sections []wasmSection) int {
+	for i, section := range sections {
+		if section.id == wasmSectionCode {
+			return len(writeWasmSections(sections[:i])) + 1 + len(appendULEB128(nil, uint64(len(section.payload))))
+		}
+	}
+	return -1
+}
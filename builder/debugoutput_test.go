@@ -0,0 +1,88 @@
+package builder
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestWriteDebugOutput(t *testing.T) {
+	// A module with two functions, a name section and debug information. The
+	// size of the first body is padded, like the linker does.
+	var functionNames []byte
+	functionNames = appendULEB128(functionNames, 2)
+	functionNames = appendWasmName(appendULEB128(functionNames, 0), "main.foo")
+	functionNames = appendWasmName(appendULEB128(functionNames, 1), "main.bar")
+	names := append([]byte{wasmNameFunction}, appendULEB128(nil, uint64(len(functionNames)))...)
+	names = append(names, functionNames...)
+	code := []byte{2, 0x82, 0x80, 0x80, 0x80, 0, 0, 0x0b, 3, 0, 0x01, 0x0b}
+	sections := []wasmSection{
+		{id: wasmSectionType, payload: []byte{1, 0x60, 0, 0}},
+		{id: wasmSectionFunction, payload: []byte{2, 0, 0}},
+		{id: wasmSectionCode, payload: code},
+		{id: wasmSectionCustom, name: ".debug_info", payload: []byte{1, 2, 3}},
+		{id: wasmSectionCustom, name: "name", payload: names},
+		{id: wasmSectionCustom, name: "producers", payload: []byte{0}},
+	}
+	dir := t.TempDir()
+	path := filepath.Join(dir, "main.wasm")
+	debugPath := filepath.Join(dir, "main.debug.wasm")
+	if err := os.WriteFile(path, writeWasmSections(sections), 0o666); err != nil {
+		t.Fatal(err)
+	}
+	if err := writeDebugOutput(path, debugPath); err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	release, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	debug, err := os.ReadFile(debugPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(debug, writeWasmSections(sections)) {
+		t.Error("debug output is different from the original")
+	}
+	releaseSections, err := readWasmSections(release)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var sectionNames []string
+	for _, section := range releaseSections {
+		sectionNames = append(sectionNames, section.name)
+	}
+	if len(releaseSections) != 4 || releaseSections[3].name != "producers" {
+		t.Errorf("unexpected sections in release output: %q", sectionNames)
+	}
+
+	mappingData, err := os.ReadFile(filepath.Join(dir, "main.debug.map.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var mapping debugMapping
+	if err := json.Unmarshal(mappingData, &mapping); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(release[mapping.ReleaseCodeOffset:][:len(code)], code) {
+		t.Errorf("release code offset %d is wrong", mapping.ReleaseCodeOffset)
+	}
+	if !bytes.Equal(debug[mapping.DebugCodeOffset:][:len(code)], code) {
+		t.Errorf("debug code offset %d is wrong", mapping.DebugCodeOffset)
+	}
+	expected := []debugMappingFunction{
+		{Index: 0, Name: "main.foo", Offset: mapping.ReleaseCodeOffset + 6, Size: 2},
+		{Index: 1, Name: "main.bar", Offset: mapping.ReleaseCodeOffset + 9, Size: 3},
+	}
+	if len(mapping.Functions) != len(expected) {
+		t.Fatalf("expected %d functions, got %d", len(expected), len(mapping.Functions))
+	}
+	for i, fn := range mapping.Functions {
+		if fn != expected[i] {
+			t.Errorf("function %d: expected %+v, got %+v", i, expected[i], fn)
+		}
+	}
+}
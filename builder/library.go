@@ -132,6 +132,10 @@ func (l *Library) load(config *compileopts.Config, tmpdir string) (job *compileJ
 	}
 
 	remapDir := filepath.Join(os.TempDir(), "tinygo-"+l.name)
+	if config.Options.Reproducible {
+		// The temporary directory differs between machines.
+		remapDir = "/tmp/tinygo-" + l.name
+	}
 	dir := filepath.Join(tmpdir, "build-lib-"+l.name)
 	err = os.Mkdir(dir, 0777)
 	if err != nil {
@@ -143,6 +147,7 @@ func (l *Library) load(config *compileopts.Config, tmpdir string) (job *compileJ
 	// reproducible. Otherwise the temporary directory is stored in the archive
 	// itself, which varies each run.
 	args := append(l.cflags(target, headerPath), "-c", "-Oz", "-gdwarf-4", "-ffunction-sections", "-fdata-sections", "-Wno-macro-redefined", "--target="+target, "-fdebug-prefix-map="+dir+"="+remapDir)
+	args = append(args, config.FilePrefixMapFlags()...)
 	resourceDir := goenv.ClangResourceDir(false)
 	if resourceDir != "" {
 		args = append(args, "-resource-dir="+resourceDir)
@@ -0,0 +1,182 @@
+package builder
+
+// This file calculates the interface ID of a package: a hash of everything in
+// a package that can affect the compiled code of packages that import it. It
+// is used in the cache key of importing packages instead of the action ID of
+// the imported package, so that a change to the implementation of a package
+// (for example, selecting a different GC in the runtime with a build tag)
+// doesn't invalidate the cached bitcode of every package that imports it.
+
+import (
+	"crypto/sha512"
+	"encoding/hex"
+	"fmt"
+	"go/ast"
+	"go/token"
+	"go/types"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/tinygo-org/tinygo/loader"
+)
+
+// packageInterfaceID returns the interface ID of the given package. The
+// imports map contains the interface ID of every imported package.
+//
+// The interface ID covers the exported declarations (including the values of
+// constants) and the layout and method
+// sets of all types they refer to, the pragmas of exported functions and
+// variables (which determine the symbol names and calling conventions used by
+// importing packages) and, for packages that declare generic functions or
+// types, the complete source as importing packages instantiate those.
+//
+// The unexported functions and types that the compiler itself relies on, like
+// runtime.alloc or runtime.hashmapIterator, are not part of the interface ID.
+// They are kept in sync with the compiler, which is covered by its build ID,
+// and have the same signature and layout with every GC. The hashmap layout
+// does depend on -maps, which is part of the action ID instead, see
+// packageAction.
+func packageInterfaceID(pkg *loader.Package, imports map[string]string) string {
+	h := sha512.New512_224()
+	fmt.Fprintf(h, "package %s\n", pkg.ImportPath)
+	importPaths := make([]string, 0, len(imports))
+	for path := range imports {
+		importPaths = append(importPaths, path)
+	}
+	sort.Strings(importPaths)
+	for _, path := range importPaths {
+		fmt.Fprintf(h, "import %s %s\n", path, imports[path])
+	}
+
+	qualifier := func(p *types.Package) string {
+		return p.Path()
+	}
+	seen := make(map[*types.TypeName]bool)
+	var visit func(t types.Type)
+	visit = func(t types.Type) {
+		switch t := t.(type) {
+		case *types.Named:
+			for i := 0; i < t.TypeArgs().Len(); i++ {
+				visit(t.TypeArgs().At(i))
+			}
+			obj := t.Origin().Obj()
+			if obj.Pkg() != pkg.Pkg || seen[obj] {
+				// Types from other packages are covered by the interface ID
+				// of those packages.
+				return
+			}
+			seen[obj] = true
+			fmt.Fprintf(h, "%s %s\n", types.ObjectString(obj, qualifier), types.TypeString(t.Origin().Underlying(), qualifier))
+			for i := 0; i < t.Origin().NumMethods(); i++ {
+				method := t.Origin().Method(i)
+				fmt.Fprintf(h, "method %s\n", types.ObjectString(method, qualifier))
+				visit(method.Type())
+			}
+			visit(t.Origin().Underlying())
+		case *types.Pointer:
+			visit(t.Elem())
+		case *types.Slice:
+			visit(t.Elem())
+		case *types.Array:
+			visit(t.Elem())
+		case *types.Chan:
+			visit(t.Elem())
+		case *types.Map:
+			visit(t.Key())
+			visit(t.Elem())
+		case *types.Struct:
+			for i := 0; i < t.NumFields(); i++ {
+				visit(t.Field(i).Type())
+			}
+		case *types.Interface:
+			for i := 0; i < t.NumMethods(); i++ {
+				visit(t.Method(i).Type())
+			}
+		case *types.Signature:
+			visit(t.Params())
+			visit(t.Results())
+		case *types.Tuple:
+			for i := 0; i < t.Len(); i++ {
+				visit(t.At(i).Type())
+			}
+		}
+	}
+
+	generic := false
+	scope := pkg.Pkg.Scope()
+	for _, name := range scope.Names() {
+		obj := scope.Lookup(name)
+		switch obj := obj.(type) {
+		case *types.Func:
+			if obj.Type().(*types.Signature).TypeParams().Len() != 0 {
+				generic = true
+			}
+		case *types.TypeName:
+			if named, ok := obj.Type().(*types.Named); ok && named.TypeParams().Len() != 0 {
+				generic = true
+			}
+		}
+		if !obj.Exported() {
+			continue
+		}
+		fmt.Fprintf(h, "%s\n", types.ObjectString(obj, qualifier))
+		if c, ok := obj.(*types.Const); ok {
+			// ObjectString doesn't include the value of a constant.
+			fmt.Fprintf(h, "value %s\n", c.Val().ExactString())
+		}
+		visit(obj.Type())
+	}
+
+	for _, file := range pkg.Files {
+		for _, decl := range file.Decls {
+			switch decl := decl.(type) {
+			case *ast.FuncDecl:
+				if decl.Name.IsExported() {
+					writePragmas(h, decl.Name.Name, decl.Doc)
+				}
+			case *ast.GenDecl:
+				if decl.Tok != token.VAR {
+					continue
+				}
+				for _, spec := range decl.Specs {
+					spec := spec.(*ast.ValueSpec)
+					for _, name := range spec.Names {
+						if name.IsExported() {
+							writePragmas(h, name.Name, decl.Doc)
+							writePragmas(h, name.Name, spec.Doc)
+						}
+					}
+				}
+			}
+		}
+	}
+
+	if generic {
+		// Generic functions and types are instantiated in the importing
+		// package, so their implementation is part of the interface.
+		filePaths := make([]string, 0, len(pkg.FileHashes))
+		for path := range pkg.FileHashes {
+			filePaths = append(filePaths, path)
+		}
+		sort.Strings(filePaths)
+		for _, path := range filePaths {
+			fmt.Fprintf(h, "file %s %x\n", path, pkg.FileHashes[path])
+		}
+	}
+
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// writePragmas writes the //go: and //export pragmas in the given doc comment
+// to w.
+func writePragmas(w io.Writer, name string, doc *ast.CommentGroup) {
+	if doc == nil {
+		return
+	}
+	for _, comment := range doc.List {
+		if strings.HasPrefix(comment.Text, "//go:") || strings.HasPrefix(comment.Text, "//export ") {
+			fmt.Fprintf(w, "pragma %s %s\n", name, comment.Text)
+		}
+	}
+}
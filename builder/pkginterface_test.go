@@ -0,0 +1,157 @@
+package builder
+
+import (
+	"crypto/sha256"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"testing"
+
+	"github.com/tinygo-org/tinygo/loader"
+)
+
+// testInterfaceID returns the interface ID of a package with the given import
+// path, consisting of a single file with the given source.
+func testInterfaceID(t *testing.T, importPath, src string) string {
+	t.Helper()
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, "p.go", src, parser.ParseComments)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pkg, err := (&types.Config{}).Check(importPath, fset, []*ast.File{file}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	hash := sha256.Sum256([]byte(src))
+	return packageInterfaceID(&loader.Package{
+		PackageJSON: loader.PackageJSON{ImportPath: importPath},
+		Files:       []*ast.File{file},
+		FileHashes:  map[string][]byte{"p.go": hash[:]},
+		Pkg:         pkg,
+	}, nil)
+}
+
+func TestPackageInterfaceID(t *testing.T) {
+	interfaceID := func(src string) string {
+		t.Helper()
+		return testInterfaceID(t, "example.com/p", src)
+	}
+
+	base := interfaceID(`package p
+const Size = 4
+type T struct{ x inner }
+type inner struct{ a int }
+func (t *T) Get() int { return t.x.a }
+func New() *T { return helper() }
+func helper() *T { return &T{} }
+`)
+	for _, tc := range []struct {
+		name    string
+		src     string
+		changed bool
+	}{
+		{"unexported function", `package p
+const Size = 4
+type T struct{ x inner }
+type inner struct{ a int }
+func (t *T) Get() int { return t.x.a + 1 }
+func New() *T { return helper() }
+func helper() *T { return &T{x: inner{a: 3}} }
+func unused() {}
+`, false},
+		{"exported signature", `package p
+const Size = 4
+type T struct{ x inner }
+type inner struct{ a int }
+func (t *T) Get() int { return t.x.a }
+func New(n int) *T { return helper() }
+func helper() *T { return &T{} }
+`, true},
+		{"unexported layout", `package p
+const Size = 4
+type T struct{ x inner }
+type inner struct{ a, b int }
+func (t *T) Get() int { return t.x.a }
+func New() *T { return helper() }
+func helper() *T { return &T{} }
+`, true},
+		{"pragma", `package p
+const Size = 4
+type T struct{ x inner }
+type inner struct{ a int }
+func (t *T) Get() int { return t.x.a }
+//go:linkname New p.newT
+func New() *T { return helper() }
+func helper() *T { return &T{} }
+`, true},
+		{"constant value", `package p
+const Size = 8
+type T struct{ x inner }
+type inner struct{ a int }
+func (t *T) Get() int { return t.x.a }
+func New() *T { return helper() }
+func helper() *T { return &T{} }
+`, true},
+	} {
+		if changed := interfaceID(tc.src) != base; changed != tc.changed {
+			t.Errorf("%s: expected the interface ID to change: %v, got: %v", tc.name, tc.changed, changed)
+		}
+	}
+
+	// The implementation of generic functions is part of the interface.
+	generic := interfaceID(`package p
+func Max[N int | float64](a, b N) N { if a > b { return a }; return b }
+`)
+	if generic == interfaceID(`package p
+func Max[N int | float64](a, b N) N { if a >= b { return a }; return b }
+`) {
+		t.Error("generic implementation: expected the interface ID to change")
+	}
+}
+
+func TestPackageActionIDGC(t *testing.T) {
+	// A runtime with two different GCs: the GC-specific code is different, but
+	// alloc has the same signature.
+	conservative := testInterfaceID(t, "runtime", `package runtime
+type hashmap struct{ count uintptr }
+func alloc(size uintptr, layout *byte) *byte { markRoots(); return nil }
+func markRoots() {}
+func GC() { markRoots() }
+`)
+	extalloc := testInterfaceID(t, "runtime", `package runtime
+type hashmap struct{ count uintptr }
+var extallocLimit uintptr
+func alloc(size uintptr, layout *byte) *byte { extallocRetry(size); return nil }
+func extallocRetry(size uintptr) bool { return size < extallocLimit }
+func GC() {}
+`)
+	if conservative != extalloc {
+		t.Error("expected the interface ID of the runtime to be the same with every GC")
+	}
+
+	// Switching the GC doesn't change the action ID of other packages, but
+	// switching the map implementation does.
+	actionID := func(runtimeInterface, maps string) string {
+		t.Helper()
+		action := packageAction{
+			ImportPath:       "example.com/p",
+			FileHashes:       map[string]string{"p.go": "1234"},
+			Imports:          map[string]string{"runtime": runtimeInterface},
+			RuntimeInterface: runtimeInterface,
+			Maps:             maps,
+		}
+		id, err := action.hash()
+		if err != nil {
+			t.Fatal(err)
+		}
+		return id
+	}
+	if actionID(conservative, "buckets") != actionID(extalloc, "buckets") {
+		t.Error("expected the action ID to stay the same when switching -gc")
+	}
+	if actionID(extalloc, "buckets") == actionID(extalloc, "compact") {
+		t.Error("expected the action ID to change when switching -maps")
+	}
+}
@@ -0,0 +1,32 @@
+package builder
+
+import (
+	"os"
+	"os/exec"
+
+	"github.com/tinygo-org/tinygo/compileopts"
+	"github.com/tinygo-org/tinygo/goenv"
+)
+
+// linkPolkaVM converts the ELF file at infile to a PolkaVM program blob with
+// polkatool, which finds the imports and exports through the metadata added by
+// transform.AddPolkaVMMetadata. Debug information is kept with -debug.
+func linkPolkaVM(config *compileopts.Config, infile, outfile string) error {
+	args := []string{"link"}
+	if !config.Debug() {
+		args = append(args, "--strip")
+	}
+	args = append(args, "--output", outfile, infile)
+	polkatool := goenv.Get("POLKATOOL")
+	if config.Options.PrintCommands != nil {
+		config.Options.PrintCommands(polkatool, args...)
+	}
+	cmd := exec.Command(polkatool, args...)
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	err := cmd.Run()
+	if err != nil {
+		return &commandError{"failed to convert to a PolkaVM program", infile, err}
+	}
+	return nil
+}
@@ -0,0 +1,83 @@
+package builder
+
+// This file implements the -section-hook flag, which runs a user provided
+// program after linking to compute custom sections for the final WebAssembly
+// file. This can be used to embed runtime metadata, signatures or provenance
+// attestations that depend on the linked code.
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/google/shlex"
+	"github.com/tinygo-org/tinygo/goenv"
+)
+
+// runSectionHook runs the given hook command with the path of the WebAssembly
+// file as its last argument, and adds the custom sections it returns to that
+// file. If the command is a Go file, it is run with `go run`.
+//
+// The hook must write a JSON object to stdout that maps section names to the
+// base64 encoded section contents, which is what encoding/json produces for a
+// map[string][]byte. Existing sections with the same name are replaced. The
+// new sections are added at the end, in order of their name.
+func runSectionHook(path, hook string) error {
+	args, err := shlex.Split(hook)
+	if err != nil {
+		return fmt.Errorf("-section-hook: %w", err)
+	}
+	if len(args) == 0 {
+		return errors.New("-section-hook: no command")
+	}
+	if strings.HasSuffix(args[0], ".go") {
+		args = append([]string{filepath.Join(goenv.Get("GOROOT"), "bin", "go"), "run"}, args...)
+	}
+	args = append(args, path)
+	cmd := exec.Command(args[0], args[1:]...)
+	var stdout bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("-section-hook: %s failed: %w", hook, err)
+	}
+	var contents map[string][]byte
+	if err := json.Unmarshal(stdout.Bytes(), &contents); err != nil {
+		return fmt.Errorf("-section-hook: could not parse output of %s: %w", hook, err)
+	}
+	if len(contents) == 0 {
+		return nil
+	}
+	var names []string
+	for name := range contents {
+		if name == "" {
+			return fmt.Errorf("-section-hook: %s returned a section without a name", hook)
+		}
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return updateWasmFile(path, func(sections []wasmSection) ([]wasmSection, error) {
+		var result []wasmSection
+		for _, section := range sections {
+			if _, ok := contents[section.name]; ok && section.id == wasmSectionCustom {
+				continue // replaced below
+			}
+			result = append(result, section)
+		}
+		for _, name := range names {
+			result = append(result, wasmSection{
+				id:      wasmSectionCustom,
+				name:    name,
+				payload: contents[name],
+			})
+		}
+		return result, nil
+	})
+}
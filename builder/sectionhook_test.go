@@ -0,0 +1,63 @@
+package builder
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+)
+
+func TestSectionHook(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("test uses a shell script")
+	}
+	dir := t.TempDir()
+	path := filepath.Join(dir, "test.wasm")
+	module := writeWasmSections([]wasmSection{
+		{id: wasmSectionType, payload: []byte{0}},
+		{id: wasmSectionCustom, name: "metadata", payload: []byte("old")},
+		{id: wasmSectionCustom, name: "name", payload: []byte{}},
+	})
+	if err := os.WriteFile(path, module, 0o666); err != nil {
+		t.Fatal(err)
+	}
+
+	// The hook checks that it gets the path to the module, and returns two
+	// sections.
+	hook := filepath.Join(dir, "hook.sh")
+	script := "#!/bin/sh\ntest -f \"$2\" || exit 1\necho '{\"metadata\": \"AQID\", \"attestation\": \"\"}'\n"
+	if err := os.WriteFile(hook, []byte(script), 0o777); err != nil {
+		t.Fatal(err)
+	}
+	if err := runSectionHook(path, hook+" arg"); err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sections, err := readWasmSections(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []wasmSection{
+		{id: wasmSectionType, payload: []byte{0}},
+		{id: wasmSectionCustom, name: "name", payload: []byte{}},
+		{id: wasmSectionCustom, name: "attestation", payload: []byte{}},
+		{id: wasmSectionCustom, name: "metadata", payload: []byte{1, 2, 3}},
+	}
+	if len(sections) != len(expected) {
+		t.Fatalf("expected %d sections, got %d", len(expected), len(sections))
+	}
+	for i, section := range sections {
+		if section.id != expected[i].id || section.name != expected[i].name || !bytes.Equal(section.payload, expected[i].payload) {
+			t.Errorf("section %d: expected %v, got %v", i, expected[i], section)
+		}
+	}
+
+	if err := runSectionHook(path, "false"); err == nil {
+		t.Error("expected an error for a failing hook")
+	}
+}
@@ -0,0 +1,133 @@
+package builder
+
+// This file implements the -size-budget and -size-compare flags, which guard
+// against binary size regressions.
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sort"
+)
+
+// checkSizeBudget returns an error if the WebAssembly file at the given path is
+// larger than the budget (in bytes). Custom sections such as the name section
+// and debug information are not counted, as they are stripped before a binary
+// is deployed.
+func checkSizeBudget(path string, budget uint64) error {
+	size, err := wasmDeployedSize(path)
+	if err != nil {
+		return err
+	}
+	if uint64(size) > budget {
+		return fmt.Errorf("size of %d bytes (without custom sections) exceeds -size-budget of %d bytes by %d bytes", size, budget, uint64(size)-budget)
+	}
+	return nil
+}
+
+// wasmDeployedSize returns the size of the WebAssembly file at the given path
+// without its custom sections: the size of the file as it is deployed.
+func wasmDeployedSize(path string) (int64, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return 0, err
+	}
+	sections, err := readWasmSections(data)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", path, err)
+	}
+	var kept []wasmSection
+	for _, section := range sections {
+		if section.id != wasmSectionCustom {
+			kept = append(kept, section)
+		}
+	}
+	return int64(len(writeWasmSections(kept))), nil
+}
+
+// printSizeComparison compares the WebAssembly file at newPath against the
+// baseline at oldPath. It prints the difference in size, counted the same way
+// as -size-budget, and if the size changed, a per-function breakdown of all
+// functions that changed in size. Functions are matched by name, so both files
+// need a name section.
+func printSizeComparison(w io.Writer, oldPath, newPath string) error {
+	oldSize, err := wasmDeployedSize(oldPath)
+	if err != nil {
+		return fmt.Errorf("could not read baseline %s: %w", oldPath, err)
+	}
+	newSize, err := wasmDeployedSize(newPath)
+	if err != nil {
+		return err
+	}
+	oldFunctions, err := loadWasmFunctionSizes(oldPath)
+	if err != nil {
+		return fmt.Errorf("could not read baseline %s: %w", oldPath, err)
+	}
+	newFunctions, err := loadWasmFunctionSizes(newPath)
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(w, "size: %d -> %d bytes (%+d) compared to %s\n", oldSize, newSize, newSize-oldSize, oldPath)
+	if oldSize == newSize {
+		return nil
+	}
+
+	diffs := diffFunctionSizes(oldFunctions, newFunctions)
+	if len(diffs) == 0 {
+		return nil
+	}
+	fmt.Fprintf(w, "    old     new    diff | function\n")
+	fmt.Fprintf(w, "----------------------- | --------\n")
+	for _, diff := range diffs {
+		fmt.Fprintf(w, "%7d %7d %+7d | %s\n", diff.Old, diff.New, diff.New-diff.Old, diff.Name)
+	}
+	return nil
+}
+
+// functionSizeDiff is the change in size of a single function between two
+// builds. A size of zero means the function doesn't exist in that build.
+type functionSizeDiff struct {
+	Name     string
+	Old, New int64
+}
+
+// diffFunctionSizes returns all functions that differ in size between the two
+// lists, sorted by the largest change first.
+func diffFunctionSizes(oldFunctions, newFunctions []functionSize) []functionSizeDiff {
+	sizes := make(map[string]*functionSizeDiff)
+	var diffs []*functionSizeDiff
+	get := func(name string) *functionSizeDiff {
+		diff := sizes[name]
+		if diff == nil {
+			diff = &functionSizeDiff{Name: name}
+			sizes[name] = diff
+			diffs = append(diffs, diff)
+		}
+		return diff
+	}
+	for _, fn := range oldFunctions {
+		get(fn.Name).Old += int64(fn.Size)
+	}
+	for _, fn := range newFunctions {
+		get(fn.Name).New += int64(fn.Size)
+	}
+
+	var result []functionSizeDiff
+	for _, diff := range diffs {
+		if diff.Old != diff.New {
+			result = append(result, *diff)
+		}
+	}
+	sort.SliceStable(result, func(i, j int) bool {
+		return abs64(result[i].New-result[i].Old) > abs64(result[j].New-result[j].Old)
+	})
+	return result
+}
+
+func abs64(n int64) int64 {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
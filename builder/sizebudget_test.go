@@ -0,0 +1,69 @@
+package builder
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestCheckSizeBudget(t *testing.T) {
+	// The test module with a data section, and a large custom section that
+	// isn't counted.
+	sections, err := readWasmSections(makeTestWasmModule())
+	if err != nil {
+		t.Fatal(err)
+	}
+	sections = append(sections,
+		wasmSection{id: wasmSectionData, payload: []byte{1, 0, 0x41, 0, 0x0b, 3, 'a', 'b', 'c'}},
+		wasmSection{id: wasmSectionCustom, name: ".debug_info", payload: make([]byte, 1000)},
+	)
+	path := filepath.Join(t.TempDir(), "test.wasm")
+	err = os.WriteFile(path, writeWasmSections(sections), 0666)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var deployedSections []wasmSection
+	for _, section := range sections {
+		if section.id != wasmSectionCustom {
+			deployedSections = append(deployedSections, section)
+		}
+	}
+	size := int64(len(writeWasmSections(deployedSections)))
+	if deployed, err := wasmDeployedSize(path); err != nil || deployed != size {
+		t.Fatalf("unexpected size without custom sections: %d (expected %d, error %v)", deployed, size, err)
+	}
+	if err := checkSizeBudget(path, uint64(size)); err != nil {
+		t.Error("unexpected error for a binary within budget:", err)
+	}
+	err = checkSizeBudget(path, uint64(size)-1)
+	if err == nil {
+		t.Fatal("expected an error for a binary over budget")
+	}
+	expected := fmt.Sprintf("size of %d bytes (without custom sections) exceeds -size-budget of %d bytes by 1 bytes", size, size-1)
+	if err.Error() != expected {
+		t.Errorf("unexpected error message:\nexpected: %s\nactual:   %s", expected, err)
+	}
+}
+
+func TestDiffFunctionSizes(t *testing.T) {
+	oldFunctions := []functionSize{
+		{Name: "main.main", Size: 100},
+		{Name: "runtime.alloc", Size: 50},
+		{Name: "main.removed", Size: 10},
+	}
+	newFunctions := []functionSize{
+		{Name: "main.main", Size: 120},
+		{Name: "runtime.alloc", Size: 50},
+		{Name: "main.added", Size: 30},
+	}
+	expected := []functionSizeDiff{
+		{Name: "main.added", Old: 0, New: 30},
+		{Name: "main.main", Old: 100, New: 120},
+		{Name: "main.removed", Old: 10, New: 0},
+	}
+	if diffs := diffFunctionSizes(oldFunctions, newFunctions); !reflect.DeepEqual(diffs, expected) {
+		t.Errorf("unexpected diff:\nexpected: %v\nactual:   %v", expected, diffs)
+	}
+}
@@ -7,6 +7,7 @@ import (
 	"debug/macho"
 	"debug/pe"
 	"encoding/binary"
+	"encoding/json"
 	"fmt"
 	"io"
 	"os"
@@ -24,11 +25,12 @@ const sizesDebug = false
 
 // programSize contains size statistics per package of a compiled program.
 type programSize struct {
-	Packages map[string]packageSize
-	Code     uint64
-	ROData   uint64
-	Data     uint64
-	BSS      uint64
+	Packages  map[string]packageSize
+	Functions []functionSize // only available for WebAssembly
+	Code      uint64
+	ROData    uint64
+	Data      uint64
+	BSS       uint64
 }
 
 // sortedPackageNames returns the list of package names (ProgramSize.Packages)
@@ -71,6 +73,81 @@ func (ps *packageSize) RAM() uint64 {
 	return ps.Data + ps.BSS
 }
 
+// functionSize is the size of a single function body in the code section of a
+// WebAssembly module.
+type functionSize struct {
+	Name string `json:"name"`
+	Size uint64 `json:"size"`
+}
+
+// sizeTotals is the JSON representation of the size of a program or package.
+type sizeTotals struct {
+	Code   uint64 `json:"code"`
+	ROData uint64 `json:"rodata"`
+	Data   uint64 `json:"data"`
+	BSS    uint64 `json:"bss"`
+	Flash  uint64 `json:"flash"`
+	RAM    uint64 `json:"ram"`
+}
+
+// sizeReport is the JSON output of -size=json.
+type sizeReport struct {
+	sizeTotals
+	Packages  []packageSizeReport `json:"packages"`
+	Functions []functionSize      `json:"functions,omitempty"`
+
+	// Size of the map hash and equality functions, which are shared by all
+	// map types.
+	MapKeyCode uint64 `json:"mapKeyCode,omitempty"`
+
+	// Sizes of the linked WebAssembly file before running wasm-opt, to see
+	// how much wasm-opt was able to save.
+	BeforeWasmOpt *sizeTotals `json:"beforeWasmOpt,omitempty"`
+}
+
+type packageSizeReport struct {
+	Name string `json:"name"`
+	sizeTotals
+}
+
+// writeSizeReport writes the sizes of the program as JSON to w. The
+// beforeWasmOpt parameter may be nil.
+func writeSizeReport(w io.Writer, sizes, beforeWasmOpt *programSize) error {
+	totals := func(code, rodata, data, bss uint64) sizeTotals {
+		return sizeTotals{
+			Code:   code,
+			ROData: rodata,
+			Data:   data,
+			BSS:    bss,
+			Flash:  code + rodata + data,
+			RAM:    data + bss,
+		}
+	}
+	report := sizeReport{
+		sizeTotals: totals(sizes.Code, sizes.ROData, sizes.Data, sizes.BSS),
+		Packages:   []packageSizeReport{},
+		Functions:  sizes.Functions,
+	}
+	report.MapKeyCode, _ = mapKeyFunctionsSize(sizes.Functions)
+	for _, name := range sizes.sortedPackageNames() {
+		pkg := sizes.Packages[name]
+		report.Packages = append(report.Packages, packageSizeReport{
+			Name:       name,
+			sizeTotals: totals(pkg.Code, pkg.ROData, pkg.Data, pkg.BSS),
+		})
+	}
+	if beforeWasmOpt != nil {
+		before := totals(beforeWasmOpt.Code, beforeWasmOpt.ROData, beforeWasmOpt.Data, beforeWasmOpt.BSS)
+		report.BeforeWasmOpt = &before
+	}
+	data, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "%s\n", data)
+	return err
+}
+
 // A mapping of a single chunk of code or data to a file path.
 type addressLine struct {
 	Address    uint64
@@ -378,6 +455,7 @@ func loadProgramSize(path string, packagePathMap map[string]string) (*programSiz
 
 	// Load the binary file, which could be in a number of file formats.
 	var sections []memorySection
+	var functions []functionSize
 	if file, err := elf.NewFile(f); err == nil {
 		var codeAlignment uint64
 		switch file.Machine {
@@ -693,6 +771,13 @@ func loadProgramSize(path string, packagePathMap map[string]string) (*programSiz
 			}
 		}
 
+		// WebAssembly functions are clearly separated, so it's possible to
+		// also report the size of each function.
+		functions, err = loadWasmFunctionSizes(path)
+		if err != nil {
+			return nil, err
+		}
+
 		var linearMemorySize uint64
 		for _, section := range file.Sections {
 			switch section := section.(type) {
@@ -814,7 +899,8 @@ func loadProgramSize(path string, packagePathMap map[string]string) (*programSiz
 
 	// ...and summarize the results.
 	program := &programSize{
-		Packages: sizes,
+		Packages:  sizes,
+		Functions: functions,
 	}
 	for _, pkg := range sizes {
 		program.Code += pkg.Code
@@ -825,6 +911,75 @@ func loadProgramSize(path string, packagePathMap map[string]string) (*programSiz
 	return program, nil
 }
 
+// loadWasmFunctionSizes returns the size of each function in the given
+// WebAssembly file, sorted from large to small. Functions are named after the
+// name section, if present.
+func loadWasmFunctionSizes(path string) ([]functionSize, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	sections, err := readWasmSections(data)
+	if err != nil {
+		return nil, err
+	}
+	numImports, err := countWasmFunctionImports(sections)
+	if err != nil {
+		return nil, fmt.Errorf("could not read import section: %w", err)
+	}
+	names, err := readWasmFunctionNames(sections)
+	if err != nil {
+		return nil, fmt.Errorf("could not read name section: %w", err)
+	}
+	bodySizes, err := readWasmFunctionBodySizes(sections)
+	if err != nil {
+		return nil, fmt.Errorf("could not read code section: %w", err)
+	}
+	functions := make([]functionSize, len(bodySizes))
+	for i, size := range bodySizes {
+		index := numImports + uint32(i)
+		name, ok := names[index]
+		if !ok {
+			name = fmt.Sprintf("func[%d]", index)
+		}
+		functions[i] = functionSize{Name: name, Size: size}
+	}
+	sort.SliceStable(functions, func(i, j int) bool {
+		return functions[i].Size > functions[j].Size
+	})
+	return functions, nil
+}
+
+// mapKeyFunctions are the runtime functions that hash and compare map keys.
+// The compiler doesn't generate these per key type: all maps share the routines
+// for binary, string or interface keys, selected by the alg parameter of
+// runtime.hashmapMake. They are listed separately in the size report, to show
+// how much code is shared by all map types.
+var mapKeyFunctions = map[string]bool{
+	"runtime.memequal":                true,
+	"runtime.hash32":                  true,
+	"runtime.hashmapStringEqual":      true,
+	"runtime.hashmapStringHash":       true,
+	"runtime.hashmapStringPtrHash":    true,
+	"runtime.hashmapFloat32Hash":      true,
+	"runtime.hashmapFloat64Hash":      true,
+	"runtime.hashmapInterfaceEqual":   true,
+	"runtime.hashmapInterfaceHash":    true,
+	"runtime.hashmapInterfacePtrHash": true,
+}
+
+// mapKeyFunctionsSize returns the total size and the number of map hash and
+// equality functions in the given list of functions.
+func mapKeyFunctionsSize(functions []functionSize) (size uint64, count int) {
+	for _, fn := range functions {
+		if mapKeyFunctions[fn.Name] {
+			size += fn.Size
+			count++
+		}
+	}
+	return size, count
+}
+
 // readSection determines for each byte in this section to which package it
 // belongs. It reports this usage through the addSize callback.
 func readSection(section memorySection, addresses []addressLine, addSize func(string, uint64, bool), packagePathMap map[string]string) {
@@ -90,3 +90,17 @@ func TestBinarySize(t *testing.T) {
 		})
 	}
 }
+
+func TestMapKeyFunctionsSize(t *testing.T) {
+	functions := []functionSize{
+		{Name: "main.main", Size: 100},
+		{Name: "runtime.hash32", Size: 40},
+		{Name: "runtime.hashmapStringPtrHash", Size: 12},
+		{Name: "runtime.hashmapBinarySet", Size: 30},
+		{Name: "runtime.memequal", Size: 20},
+	}
+	size, count := mapKeyFunctionsSize(functions)
+	if size != 72 || count != 3 {
+		t.Errorf("expected 72 bytes in 3 functions, got %d bytes in %d functions", size, count)
+	}
+}
@@ -0,0 +1,380 @@
+package builder
+
+// This file contains a minimal WebAssembly binary reader and writer. It only
+// knows about the section structure of a module, which is enough to add,
+// remove or replace (custom) sections after linking without having to
+// understand the code inside.
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+)
+
+// The magic number and version at the start of every WebAssembly module.
+var wasmPreamble = []byte{0x00, 'a', 's', 'm', 0x01, 0x00, 0x00, 0x00}
+
+// Section IDs that are used while post-processing WebAssembly files.
+const (
+	wasmSectionCustom    = 0
+	wasmSectionType      = 1
+	wasmSectionImport    = 2
+	wasmSectionFunction  = 3
+	wasmSectionMemory    = 5
+	wasmSectionGlobal    = 6
+	wasmSectionExport    = 7
+	wasmSectionStart     = 8
+	wasmSectionElement   = 9
+	wasmSectionCode      = 10
+	wasmSectionData      = 11
+	wasmSectionDataCount = 12
+)
+
+// wasmSection is a single section in a WebAssembly module.
+type wasmSection struct {
+	id      byte
+	name    string // only set for custom sections
+	payload []byte // section contents (excluding the name of custom sections)
+}
+
+// readWasmSections splits a WebAssembly module into its sections.
+func readWasmSections(data []byte) ([]wasmSection, error) {
+	if !bytes.HasPrefix(data, wasmPreamble) {
+		return nil, errors.New("not a WebAssembly module")
+	}
+	data = data[len(wasmPreamble):]
+	var sections []wasmSection
+	for len(data) != 0 {
+		id := data[0]
+		size, n, err := decodeULEB128(data[1:])
+		if err != nil {
+			return nil, fmt.Errorf("could not read size of section %d: %w", id, err)
+		}
+		start := 1 + n
+		if uint64(len(data)-start) < size {
+			return nil, fmt.Errorf("section %d extends beyond the end of the file", id)
+		}
+		section := wasmSection{
+			id:      id,
+			payload: data[start : start+int(size)],
+		}
+		if id == wasmSectionCustom {
+			name, n, err := readWasmName(section.payload)
+			if err != nil {
+				return nil, fmt.Errorf("could not read custom section name: %w", err)
+			}
+			section.name = name
+			section.payload = section.payload[n:]
+		}
+		sections = append(sections, section)
+		data = data[start+int(size):]
+	}
+	return sections, nil
+}
+
+// writeWasmSections serializes the given sections as a WebAssembly module.
+func writeWasmSections(sections []wasmSection) []byte {
+	buf := append([]byte{}, wasmPreamble...)
+	for _, section := range sections {
+		payload := section.payload
+		if section.id == wasmSectionCustom {
+			payload = append(appendWasmName(nil, section.name), payload...)
+		}
+		buf = append(buf, section.id)
+		buf = appendULEB128(buf, uint64(len(payload)))
+		buf = append(buf, payload...)
+	}
+	return buf
+}
+
+// updateWasmFile reads the WebAssembly file at the given path, lets update
+// modify its list of sections, and writes the result back to the same path.
+func updateWasmFile(path string, update func([]wasmSection) ([]wasmSection, error)) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	sections, err := readWasmSections(data)
+	if err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+	sections, err = update(sections)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, writeWasmSections(sections), 0666)
+}
+
+// wasmExport is an exported function: its export name and function index.
+type wasmExport struct {
+	name  string
+	index uint32
+}
+
+// readWasmExports returns the names of all exported functions, indexed by
+// function index. If a function is exported under several names, only one of
+// them is returned: use readWasmFunctionExports to get all of them.
+func readWasmExports(sections []wasmSection) (map[uint32]string, error) {
+	list, err := readWasmFunctionExports(sections)
+	if err != nil {
+		return nil, err
+	}
+	exports := make(map[uint32]string, len(list))
+	for _, export := range list {
+		exports[export.index] = export.name
+	}
+	return exports, nil
+}
+
+// readWasmFunctionExports returns all exported functions, in the order of the
+// export section.
+func readWasmFunctionExports(sections []wasmSection) ([]wasmExport, error) {
+	var exports []wasmExport
+	for _, section := range sections {
+		if section.id != wasmSectionExport {
+			continue
+		}
+		data := section.payload
+		count, n, err := decodeULEB128(data)
+		if err != nil {
+			return nil, err
+		}
+		data = data[n:]
+		for i := uint64(0); i < count; i++ {
+			name, n, err := readWasmName(data)
+			if err != nil {
+				return nil, err
+			}
+			data = data[n:]
+			if len(data) == 0 {
+				return nil, errors.New("unexpected end of export section")
+			}
+			kind := data[0]
+			index, n, err := decodeULEB128(data[1:])
+			if err != nil {
+				return nil, err
+			}
+			data = data[1+n:]
+			if kind == 0 { // function export
+				exports = append(exports, wasmExport{name: name, index: uint32(index)})
+			}
+		}
+	}
+	return exports, nil
+}
+
+// addTraceNamesSection adds the tinygo.trace_names custom section, which maps
+// the function IDs used by -trace-calls to function names. It contains a
+// vector of names, in the same format as other vectors in WebAssembly.
+func addTraceNamesSection(path string, names []string) error {
+	return updateWasmFile(path, func(sections []wasmSection) ([]wasmSection, error) {
+		payload := appendULEB128(nil, uint64(len(names)))
+		for _, name := range names {
+			payload = appendWasmName(payload, demangleGoSymbol(name))
+		}
+		return append(sections, wasmSection{
+			id:      wasmSectionCustom,
+			name:    "tinygo.trace_names",
+			payload: payload,
+		}), nil
+	})
+}
+
+// countWasmFunctionImports returns the number of imported functions. Imported
+// functions come first in the function index space, before the functions
+// defined in the code section.
+func countWasmFunctionImports(sections []wasmSection) (uint32, error) {
+	types, err := readWasmFunctionImportTypes(sections)
+	return uint32(len(types)), err
+}
+
+// readWasmFunctionImportTypes returns the type index of each imported
+// function.
+func readWasmFunctionImportTypes(sections []wasmSection) ([]uint32, error) {
+	var typeIndices []uint32
+	for _, section := range sections {
+		if section.id != wasmSectionImport {
+			continue
+		}
+		data := section.payload
+		count, n, err := decodeULEB128(data)
+		if err != nil {
+			return nil, err
+		}
+		data = data[n:]
+		for i := uint64(0); i < count; i++ {
+			// Skip the module and field name.
+			for j := 0; j < 2; j++ {
+				_, n, err := readWasmName(data)
+				if err != nil {
+					return nil, err
+				}
+				data = data[n:]
+			}
+			if len(data) < 2 {
+				return nil, errors.New("unexpected end of import section")
+			}
+			kind := data[0]
+			data = data[1:]
+			switch kind {
+			case 0: // function: type index
+				var typeIndex uint64
+				typeIndex, n, err = decodeULEB128(data)
+				typeIndices = append(typeIndices, uint32(typeIndex))
+			case 1: // table: element type and limits
+				n, err = skipWasmLimits(data[1:])
+				n++
+			case 2: // memory: limits
+				n, err = skipWasmLimits(data)
+			case 3: // global: value type and mutability
+				n = 2
+			case 4: // tag: attribute and type index
+				_, n, err = decodeULEB128(data[1:])
+				n++
+			default:
+				return nil, fmt.Errorf("unknown import kind %d", kind)
+			}
+			if err != nil {
+				return nil, err
+			}
+			if n > len(data) {
+				return nil, errors.New("unexpected end of import section")
+			}
+			data = data[n:]
+		}
+	}
+	return typeIndices, nil
+}
+
+// skipWasmLimits returns the size of the limits at the start of buf.
+func skipWasmLimits(buf []byte) (int, error) {
+	_, _, _, n, err := readWasmLimits(buf)
+	return n, err
+}
+
+// readWasmLimits reads the limits at the start of buf. It returns the minimum,
+// the maximum (if present) and the size of the limits in bytes.
+func readWasmLimits(buf []byte) (min, max uint64, hasMax bool, size int, err error) {
+	if len(buf) == 0 {
+		return 0, 0, false, 0, errors.New("unexpected end of limits")
+	}
+	flags := buf[0]
+	min, n, err := decodeULEB128(buf[1:])
+	if err != nil {
+		return 0, 0, false, 0, err
+	}
+	size = 1 + n
+	if flags&1 != 0 { // maximum is present
+		max, n, err = decodeULEB128(buf[size:])
+		if err != nil {
+			return 0, 0, false, 0, err
+		}
+		hasMax = true
+		size += n
+	}
+	return min, max, hasMax, size, nil
+}
+
+// readWasmFunctionNames returns the function names from the name section,
+// indexed by function index.
+func readWasmFunctionNames(sections []wasmSection) (map[uint32]string, error) {
+	names := make(map[uint32]string)
+	for _, section := range sections {
+		if section.id != wasmSectionCustom || section.name != "name" {
+			continue
+		}
+		_, err := rewriteNameSection(section.payload, func(index uint32, name string) (string, bool) {
+			names[index] = name
+			return name, true
+		}, false)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return names, nil
+}
+
+// readWasmFunctionBodySizes returns the size in bytes of every function body
+// in the code section, including the size prefix of each body.
+func readWasmFunctionBodySizes(sections []wasmSection) ([]uint64, error) {
+	var sizes []uint64
+	for _, section := range sections {
+		if section.id != wasmSectionCode {
+			continue
+		}
+		data := section.payload
+		count, n, err := decodeULEB128(data)
+		if err != nil {
+			return nil, err
+		}
+		data = data[n:]
+		for i := uint64(0); i < count; i++ {
+			size, n, err := decodeULEB128(data)
+			if err != nil {
+				return nil, err
+			}
+			if uint64(len(data)-n) < size {
+				return nil, errors.New("function body extends beyond the end of the code section")
+			}
+			sizes = append(sizes, uint64(n)+size)
+			data = data[n+int(size):]
+		}
+	}
+	return sizes, nil
+}
+
+// decodeULEB128 decodes an unsigned LEB128 number. It returns the value and
+// the number of bytes read.
+func decodeULEB128(buf []byte) (value uint64, n int, err error) {
+	var shift uint
+	for {
+		if n >= len(buf) {
+			return 0, 0, errors.New("unexpected end of LEB128 number")
+		}
+		b := buf[n]
+		n++
+		value |= uint64(b&0x7f) << shift
+		if b&0x80 == 0 {
+			return value, n, nil
+		}
+		shift += 7
+		if shift >= 64 {
+			return 0, 0, errors.New("LEB128 number too large")
+		}
+	}
+}
+
+// appendULEB128 appends the unsigned LEB128 encoding of value to buf.
+func appendULEB128(buf []byte, value uint64) []byte {
+	for {
+		b := byte(value & 0x7f)
+		value >>= 7
+		if value != 0 {
+			b |= 0x80
+		}
+		buf = append(buf, b)
+		if value == 0 {
+			return buf
+		}
+	}
+}
+
+// readWasmName reads a length-prefixed UTF-8 string as used throughout the
+// WebAssembly binary format.
+func readWasmName(buf []byte) (string, int, error) {
+	length, n, err := decodeULEB128(buf)
+	if err != nil {
+		return "", 0, err
+	}
+	if uint64(len(buf)-n) < length {
+		return "", 0, errors.New("name extends beyond the end of the section")
+	}
+	return string(buf[n : n+int(length)]), n + int(length), nil
+}
+
+// appendWasmName appends a length-prefixed string to buf.
+func appendWasmName(buf []byte, name string) []byte {
+	buf = appendULEB128(buf, uint64(len(name)))
+	return append(buf, name...)
+}
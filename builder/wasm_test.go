@@ -0,0 +1,76 @@
+package builder
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// makeTestWasmModule returns a small WebAssembly module with two imports (one
+// function, one memory), two functions in the code section and a name section
+// that names only the first of those.
+func makeTestWasmModule() []byte {
+	var imports []byte
+	imports = appendULEB128(imports, 2)
+	imports = appendWasmName(imports, "env")
+	imports = appendWasmName(imports, "log")
+	imports = append(imports, 0, 0) // function with type 0
+	imports = appendWasmName(imports, "env")
+	imports = appendWasmName(imports, "memory")
+	imports = append(imports, 2, 1, 1, 2) // memory with min 1 and max 2 pages
+
+	var code []byte
+	code = appendULEB128(code, 2)
+	code = append(code, 2, 0, 0x0b)             // no locals, end
+	code = append(code, 4, 0, 0x01, 0x01, 0x0b) // no locals, nop, nop, end
+
+	var functions []byte
+	functions = appendULEB128(functions, 1)
+	functions = appendULEB128(functions, 1)
+	functions = appendWasmName(functions, "main.main")
+	var names []byte
+	names = append(names, wasmNameFunction)
+	names = appendULEB128(names, uint64(len(functions)))
+	names = append(names, functions...)
+
+	return writeWasmSections([]wasmSection{
+		{id: wasmSectionImport, payload: imports},
+		{id: wasmSectionCode, payload: code},
+		{id: wasmSectionCustom, name: "name", payload: names},
+	})
+}
+
+func TestLoadWasmFunctionSizes(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test.wasm")
+	err := os.WriteFile(path, makeTestWasmModule(), 0666)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	functions, err := loadWasmFunctionSizes(path)
+	if err != nil {
+		t.Fatal("could not load function sizes:", err)
+	}
+	expected := []functionSize{
+		{Name: "func[2]", Size: 5},
+		{Name: "main.main", Size: 3},
+	}
+	if !reflect.DeepEqual(functions, expected) {
+		t.Errorf("unexpected function sizes:\nexpected: %v\nactual:   %v", expected, functions)
+	}
+}
+
+func TestWasmSectionsRoundTrip(t *testing.T) {
+	data := makeTestWasmModule()
+	sections, err := readWasmSections(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(sections) != 3 || sections[2].name != "name" {
+		t.Fatalf("unexpected sections: %v", sections)
+	}
+	if out := writeWasmSections(sections); !reflect.DeepEqual(out, data) {
+		t.Errorf("module changed after reading and writing:\nexpected: %x\nactual:   %x", data, out)
+	}
+}
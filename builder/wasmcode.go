@@ -0,0 +1,261 @@
+package builder
+
+// This file decodes the instructions in the code section of a WebAssembly
+// module. It doesn't interpret them: it only knows how long every instruction
+// is and which WebAssembly feature it needs, which is enough to check or
+// rewrite individual instructions in a function body.
+
+import (
+	"errors"
+	"fmt"
+)
+
+// wasmInstruction is a single decoded instruction.
+type wasmInstruction struct {
+	opcode  uint32 // opcode, with the prefix byte (if any) in the upper bits
+	size    int    // size in bytes, including all immediates
+	feature string // feature that is needed beyond the MVP, if any
+}
+
+// Prefix bytes of multi-byte opcodes.
+const (
+	wasmPrefixMisc    = 0xfc
+	wasmPrefixSIMD    = 0xfd
+	wasmPrefixThreads = 0xfe
+)
+
+// wasmFunctionBody is a function body in the code section, split into the
+// local declarations and the instructions.
+type wasmFunctionBody struct {
+	locals []byte
+	code   []byte
+}
+
+// readWasmFunctionBodies returns the bodies of all functions in the given
+// code section payload.
+func readWasmFunctionBodies(data []byte) ([]wasmFunctionBody, error) {
+	count, n, err := decodeULEB128(data)
+	if err != nil {
+		return nil, err
+	}
+	data = data[n:]
+	var bodies []wasmFunctionBody
+	for i := uint64(0); i < count; i++ {
+		size, n, err := decodeULEB128(data)
+		if err != nil {
+			return nil, err
+		}
+		if uint64(len(data)-n) < size {
+			return nil, errors.New("function body extends beyond the end of the code section")
+		}
+		body := data[n : n+int(size)]
+		data = data[n+int(size):]
+
+		// Skip the local declarations: a vector of (count, type) pairs.
+		localsSize := 0
+		numGroups, n, err := decodeULEB128(body)
+		if err != nil {
+			return nil, err
+		}
+		localsSize += n
+		for j := uint64(0); j < numGroups; j++ {
+			_, n, err := decodeULEB128(body[localsSize:])
+			if err != nil {
+				return nil, err
+			}
+			localsSize += n + 1 // count and value type
+		}
+		if localsSize > len(body) {
+			return nil, errors.New("unexpected end of local declarations")
+		}
+		bodies = append(bodies, wasmFunctionBody{
+			locals: body[:localsSize],
+			code:   body[localsSize:],
+		})
+	}
+	return bodies, nil
+}
+
+// appendWasmFunctionBodies encodes the function bodies as a code section
+// payload.
+func appendWasmFunctionBodies(buf []byte, bodies []wasmFunctionBody) []byte {
+	buf = appendULEB128(buf, uint64(len(bodies)))
+	for _, body := range bodies {
+		buf = appendULEB128(buf, uint64(len(body.locals)+len(body.code)))
+		buf = append(buf, body.locals...)
+		buf = append(buf, body.code...)
+	}
+	return buf
+}
+
+// decodeWasmInstruction decodes the instruction at the start of code.
+func decodeWasmInstruction(code []byte) (wasmInstruction, error) {
+	if len(code) == 0 {
+		return wasmInstruction{}, errors.New("unexpected end of function body")
+	}
+	inst := wasmInstruction{opcode: uint32(code[0]), size: 1}
+	var err error
+	// Helpers to skip immediates.
+	skipULEB := func() {
+		if err != nil {
+			return
+		}
+		var n int
+		_, n, err = decodeULEB128(code[inst.size:])
+		inst.size += n
+	}
+	skipBytes := func(n int) {
+		if err == nil && inst.size+n > len(code) {
+			err = errors.New("unexpected end of function body")
+		}
+		inst.size += n
+	}
+	skipBlockType := func() {
+		if inst.size >= len(code) {
+			err = errors.New("unexpected end of function body")
+			return
+		}
+		b := code[inst.size]
+		if b == 0x40 || wasmValueTypeNames[b] != "" {
+			inst.size++
+			return
+		}
+		// Type index, encoded as a signed LEB128 number. Only positive
+		// numbers are valid, so it can be decoded as unsigned.
+		inst.feature = "multivalue"
+		skipULEB()
+	}
+
+	switch op := code[0]; {
+	case op == 0x02 || op == 0x03 || op == 0x04: // block, loop, if
+		skipBlockType()
+	case op == 0x06: // try
+		skipBlockType()
+		inst.feature = "exception-handling"
+	case op == 0x07 || op == 0x08 || op == 0x09 || op == 0x18: // catch, throw, rethrow, delegate
+		inst.feature = "exception-handling"
+		skipULEB()
+	case op == 0x19: // catch_all
+		inst.feature = "exception-handling"
+	case op == 0x0c || op == 0x0d: // br, br_if
+		skipULEB()
+	case op == 0x0e: // br_table
+		var count uint64
+		var n int
+		count, n, err = decodeULEB128(code[1:])
+		inst.size += n
+		for i := uint64(0); i <= count && err == nil; i++ {
+			skipULEB()
+		}
+	case op == 0x10: // call
+		skipULEB()
+	case op == 0x11: // call_indirect
+		skipULEB()
+		if err == nil && inst.size < len(code) && code[inst.size] != 0 {
+			inst.feature = "reference-types" // table other than table 0
+		}
+		skipULEB()
+	case op == 0x12: // return_call
+		inst.feature = "tail-call"
+		skipULEB()
+	case op == 0x13: // return_call_indirect
+		inst.feature = "tail-call"
+		skipULEB()
+		skipULEB()
+	case op == 0x1c: // select with types
+		inst.feature = "reference-types"
+		var count uint64
+		var n int
+		count, n, err = decodeULEB128(code[1:])
+		inst.size += n
+		skipBytes(int(count))
+	case op >= 0x20 && op <= 0x24: // local.get/set/tee, global.get/set
+		skipULEB()
+	case op == 0x25 || op == 0x26: // table.get, table.set
+		inst.feature = "reference-types"
+		skipULEB()
+	case op >= 0x28 && op <= 0x3e: // loads and stores: alignment and offset
+		skipULEB()
+		skipULEB()
+	case op == 0x3f || op == 0x40: // memory.size, memory.grow
+		skipULEB()
+	case op == 0x41 || op == 0x42: // i32.const, i64.const
+		skipULEB()
+	case op == 0x43: // f32.const
+		skipBytes(4)
+	case op == 0x44: // f64.const
+		skipBytes(8)
+	case op >= 0xc0 && op <= 0xc4: // extend8_s etc
+		inst.feature = "sign-ext"
+	case op == 0xd0: // ref.null
+		inst.feature = "reference-types"
+		skipBytes(1)
+	case op == 0xd1: // ref.is_null
+		inst.feature = "reference-types"
+	case op == 0xd2: // ref.func
+		inst.feature = "reference-types"
+		skipULEB()
+	case op == wasmPrefixMisc:
+		var sub uint64
+		var n int
+		sub, n, err = decodeULEB128(code[1:])
+		inst.size += n
+		inst.opcode = wasmPrefixMisc<<24 | uint32(sub)
+		switch {
+		case sub <= 7: // saturating float to int conversions
+			inst.feature = "nontrapping-fptoint"
+		case sub == 8: // memory.init
+			inst.feature = "bulk-memory"
+			skipULEB()
+			skipULEB()
+		case sub == 9: // data.drop
+			inst.feature = "bulk-memory"
+			skipULEB()
+		case sub == 10: // memory.copy
+			inst.feature = "bulk-memory"
+			skipULEB()
+			skipULEB()
+		case sub == 11: // memory.fill
+			inst.feature = "bulk-memory"
+			skipULEB()
+		case sub == 12 || sub == 14: // table.init, table.copy
+			inst.feature = "bulk-memory"
+			skipULEB()
+			skipULEB()
+		case sub == 13: // elem.drop
+			inst.feature = "bulk-memory"
+			skipULEB()
+		case sub >= 15 && sub <= 17: // table.grow, table.size, table.fill
+			inst.feature = "reference-types"
+			skipULEB()
+		default:
+			return wasmInstruction{}, fmt.Errorf("unknown instruction 0xfc %d", sub)
+		}
+	case op == wasmPrefixThreads:
+		var sub uint64
+		var n int
+		sub, n, err = decodeULEB128(code[1:])
+		inst.size += n
+		inst.opcode = wasmPrefixThreads<<24 | uint32(sub)
+		inst.feature = "atomics"
+		if sub == 3 { // atomic.fence
+			skipBytes(1)
+		} else {
+			skipULEB()
+			skipULEB()
+		}
+	case op == wasmPrefixSIMD:
+		return wasmInstruction{}, errors.New("SIMD instructions are not supported")
+	case op <= 0x01 || op == 0x05 || op == 0x0b || op == 0x0f || op == 0x1a || op == 0x1b || (op >= 0x45 && op <= 0xbf):
+		// Instructions without immediates.
+	default:
+		return wasmInstruction{}, fmt.Errorf("unknown instruction 0x%02x", op)
+	}
+	if err != nil {
+		return wasmInstruction{}, err
+	}
+	if inst.size > len(code) {
+		return wasmInstruction{}, errors.New("unexpected end of function body")
+	}
+	return inst, nil
+}
@@ -0,0 +1,508 @@
+package builder
+
+// This file implements compression of data segments in linked WebAssembly
+// modules. String tables, type information and other read-only data usually
+// compress well, which matters for hosts that store or transfer the module
+// (like a blockchain) more than it matters how long it takes to start.
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// minCompressedDataSize is the size from which a data segment is compressed.
+// Smaller segments don't save enough to pay for the decompression code.
+const minCompressedDataSize = 256
+
+// wasmDecompressorSize is about the size of the code that is added to the
+// decompression function for each compressed segment.
+const wasmDecompressorSize = 96
+
+// wasmDataSegment is a single segment of the data section.
+type wasmDataSegment struct {
+	flags  uint64
+	memory uint64 // memory index, if flags == 2
+	offset []byte // offset expression of active segments, including the end
+	data   []byte
+}
+
+// compressWasmData is the compress-data post-link pass. It compresses the
+// large active data segments of the module, and adds a start function that
+// decompresses them into linear memory when the module is instantiated,
+// before any exported function can run.
+//
+// The compressed segments are placed at the end of the initial memory, which
+// is grown to make room for them. The start function clears this memory again
+// after decompressing, so that the heap finds it zeroed like fresh memory.
+//
+// Modules with an imported or shared memory are left as-is: their memory may
+// already be in use by the time the start function runs.
+func compressWasmData(sections []wasmSection) ([]wasmSection, error) {
+	memoryIndex, dataIndex := -1, -1
+	for i, section := range sections {
+		switch section.id {
+		case wasmSectionMemory:
+			memoryIndex = i
+		case wasmSectionData:
+			dataIndex = i
+		}
+	}
+	if memoryIndex < 0 || dataIndex < 0 {
+		return sections, nil // no memory of its own, or nothing to compress
+	}
+	memory := sections[memoryIndex].payload
+	if len(memory) < 2 || memory[0] != 1 || memory[1] > 1 {
+		return sections, nil // shared or 64-bit memory
+	}
+	minPages, maxPages, hasMax, _, err := readWasmLimits(memory[1:])
+	if err != nil {
+		return nil, err
+	}
+	segments, err := readWasmDataSegments(sections[dataIndex].payload)
+	if err != nil {
+		return nil, err
+	}
+
+	// Compress all segments for which it's worth it, and place the compressed
+	// data after each other at the end of the initial memory.
+	const pageSize = 65536
+	blobStart := minPages * pageSize
+	blobEnd := blobStart
+	var decompress []byte
+	for i, segment := range segments {
+		offset, ok := wasmConstantOffset(segment)
+		if !ok || len(segment.data) < minCompressedDataSize {
+			continue
+		}
+		if offset+uint64(len(segment.data)) > blobStart {
+			return nil, fmt.Errorf("data segment %d is outside of the initial memory", i)
+		}
+		compressed := compressLZ(segment.data)
+		if len(compressed)+wasmDecompressorSize >= len(segment.data) {
+			continue
+		}
+		decompress = appendWasmDecompressor(decompress, blobEnd, blobEnd+uint64(len(compressed)), offset)
+		segments[i].offset = appendWasmI32Const(nil, blobEnd)
+		segments[i].offset = append(segments[i].offset, 0x0b) // end
+		segments[i].data = compressed
+		blobEnd += uint64(len(compressed))
+	}
+	if blobEnd == blobStart {
+		return sections, nil // nothing was compressed
+	}
+	blobEnd = (blobEnd + 3) &^ 3 // cleared 4 bytes at a time
+	newMinPages := (blobEnd + pageSize - 1) / pageSize
+	if blobEnd > 1<<32 || (hasMax && newMinPages > maxPages) {
+		return sections, nil // the compressed data doesn't fit
+	}
+
+	// Clear the compressed data again:
+	//
+	//	local.set $src (i32.const blobStart)
+	//	loop
+	//	  i32.store (local.get $src) (i32.const 0)
+	//	  br_if 0 (i32.lt_u (local.tee $src (i32.add (local.get $src) (i32.const 4))) (i32.const blobEnd))
+	//	end
+	decompress = appendWasmI32Const(decompress, blobStart)
+	decompress = append(decompress, 0x21, 0, 0x03, 0x40, 0x20, 0, 0x41, 0, 0x36, 2, 0, 0x20, 0, 0x41, 4, 0x6a, 0x22, 0)
+	decompress = appendWasmI32Const(decompress, blobEnd)
+	decompress = append(decompress, 0x49, 0x0d, 0, 0x0b)
+
+	// Add the decompression function as the start function. If there already
+	// is one, it's called after decompressing.
+	numImports, err := countWasmFunctionImports(sections)
+	if err != nil {
+		return nil, err
+	}
+	hasStart := false
+	for _, section := range sections {
+		if section.id == wasmSectionStart {
+			hasStart = true
+			decompress = append(decompress, 0x10) // call
+			decompress = append(decompress, section.payload...)
+		}
+	}
+	decompress = append(decompress, 0x0b) // end
+	var result []wasmSection
+	for _, section := range sections {
+		if section.id == wasmSectionCustom && strings.HasPrefix(section.name, ".debug_") {
+			continue // refers to offsets in the code section
+		}
+		if !hasStart && section.id != wasmSectionCustom && wasmSectionOrder(section.id) > wasmSectionOrder(wasmSectionStart) {
+			// Add the start section in the right place.
+			hasStart = true
+			result = append(result, wasmSection{id: wasmSectionStart})
+		}
+		result = append(result, section)
+	}
+	var typeIndex, functionIndex uint64
+	for i := range result {
+		section := &result[i]
+		switch section.id {
+		case wasmSectionType:
+			section.payload, typeIndex, err = addWasmFunctionType(section.payload, []byte{0x60, 0, 0}) // () -> ()
+		case wasmSectionFunction:
+			section.payload, functionIndex, err = appendWasmVectorItem(section.payload, appendULEB128(nil, typeIndex))
+			functionIndex += uint64(numImports)
+		case wasmSectionMemory:
+			section.payload = appendULEB128(nil, 1)
+			if hasMax {
+				section.payload = append(section.payload, 1)
+				section.payload = appendULEB128(section.payload, newMinPages)
+				section.payload = appendULEB128(section.payload, maxPages)
+			} else {
+				section.payload = append(section.payload, 0)
+				section.payload = appendULEB128(section.payload, newMinPages)
+			}
+		case wasmSectionStart:
+			section.payload = appendULEB128(nil, functionIndex)
+		case wasmSectionCode:
+			var bodies []wasmFunctionBody
+			bodies, err = readWasmFunctionBodies(section.payload)
+			bodies = append(bodies, wasmFunctionBody{
+				locals: []byte{1, 6, 0x7f}, // 6 locals of type i32
+				code:   decompress,
+			})
+			section.payload = appendWasmFunctionBodies(nil, bodies)
+		case wasmSectionData:
+			section.payload = appendWasmDataSegments(nil, segments)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	return result, nil
+}
+
+// appendWasmDecompressor appends the code to decompress the data from src to
+// srcEnd into memory at dst. The data was compressed with compressLZ. In
+// WebAssembly text format, with locals $src, $srcEnd, $dst, $token, $len and
+// $from:
+//
+//	local.set $src (i32.const src)
+//	local.set $srcEnd (i32.const srcEnd)
+//	local.set $dst (i32.const dst)
+//	block
+//	  loop
+//	    br_if 1 (i32.ge_u (local.get $src) (local.get $srcEnd))
+//	    local.set $token (i32.load8_u (local.get $src))
+//	    local.set $src (i32.add (local.get $src) (i32.const 1))
+//	    if (i32.lt_u (local.get $token) (i32.const 0x80))
+//	      ;; literal bytes
+//	      local.set $len (i32.add (local.get $token) (i32.const 1))
+//	      local.set $from (local.get $src)
+//	      local.set $src (i32.add (local.get $src) (local.get $len))
+//	    else
+//	      ;; match
+//	      local.set $len (i32.add (i32.and (local.get $token) (i32.const 0x7f)) (i32.const 3))
+//	      local.set $from (i32.sub (local.get $dst) (i32.load16_u (local.get $src)))
+//	      local.set $src (i32.add (local.get $src) (i32.const 2))
+//	    end
+//	    loop
+//	      i32.store8 (local.get $dst) (i32.load8_u (local.get $from))
+//	      local.set $dst (i32.add (local.get $dst) (i32.const 1))
+//	      local.set $from (i32.add (local.get $from) (i32.const 1))
+//	      br_if 0 (local.tee $len (i32.sub (local.get $len) (i32.const 1)))
+//	    end
+//	    br 0
+//	  end
+//	end
+func appendWasmDecompressor(code []byte, src, srcEnd, dst uint64) []byte {
+	const (
+		localSrc = iota
+		localSrcEnd
+		localDst
+		localToken
+		localLen
+		localFrom
+	)
+	code = appendWasmI32Const(code, src)
+	code = append(code, 0x21, localSrc)
+	code = appendWasmI32Const(code, srcEnd)
+	code = append(code, 0x21, localSrcEnd)
+	code = appendWasmI32Const(code, dst)
+	code = append(code, 0x21, localDst)
+	return append(code,
+		0x02, 0x40, // block
+		0x03, 0x40, // loop
+		0x20, localSrc, 0x20, localSrcEnd, 0x4f, 0x0d, 1, // br_if 1 (i32.ge_u ...)
+		0x20, localSrc, 0x2d, 0, 0, 0x21, localToken, // i32.load8_u
+		0x20, localSrc, 0x41, 1, 0x6a, 0x21, localSrc,
+		0x20, localToken, 0x41, 0x80, 0x01, 0x49, 0x04, 0x40, // if (i32.lt_u ...)
+		0x20, localToken, 0x41, 1, 0x6a, 0x21, localLen,
+		0x20, localSrc, 0x21, localFrom,
+		0x20, localSrc, 0x20, localLen, 0x6a, 0x21, localSrc,
+		0x05, // else
+		0x20, localToken, 0x41, 0xff, 0x00, 0x71, 0x41, 3, 0x6a, 0x21, localLen,
+		0x20, localDst, 0x20, localSrc, 0x2f, 0, 0, 0x6b, 0x21, localFrom, // i32.load16_u
+		0x20, localSrc, 0x41, 2, 0x6a, 0x21, localSrc,
+		0x0b,       // end
+		0x03, 0x40, // loop
+		0x20, localDst, 0x20, localFrom, 0x2d, 0, 0, 0x3a, 0, 0, // i32.store8
+		0x20, localDst, 0x41, 1, 0x6a, 0x21, localDst,
+		0x20, localFrom, 0x41, 1, 0x6a, 0x21, localFrom,
+		0x20, localLen, 0x41, 1, 0x6b, 0x22, localLen, 0x0d, 0, // br_if 0 (local.tee ...)
+		0x0b,    // end
+		0x0c, 0, // br 0
+		0x0b, 0x0b, // end, end
+	)
+}
+
+// compressLZ compresses data with a simple LZ77 variant, that is easy to
+// decompress in a few bytes of WebAssembly code. The compressed data is a
+// sequence of:
+//
+//   - a byte 0x00-0x7f, followed by 1-128 literal bytes
+//   - a byte 0x80-0xff for a match of 3-130 bytes, followed by the 16-bit
+//     little endian distance back to the start of the match
+//
+// Matches are found greedily, using hash chains over the next 3 bytes.
+func compressLZ(data []byte) []byte {
+	const (
+		minMatch    = 3
+		maxMatch    = 0x7f + minMatch
+		maxLiterals = 0x80
+		maxDistance = 0xffff
+		maxChain    = 64
+		hashBits    = 14
+	)
+	head := make([]int, 1<<hashBits)
+	for i := range head {
+		head[i] = -1
+	}
+	prev := make([]int, len(data))
+	hash := func(i int) uint32 {
+		v := uint32(data[i]) | uint32(data[i+1])<<8 | uint32(data[i+2])<<16
+		return (v * 2654435761) >> (32 - hashBits)
+	}
+	insert := func(i int) {
+		if i+minMatch <= len(data) {
+			h := hash(i)
+			prev[i] = head[h]
+			head[h] = i
+		}
+	}
+
+	var compressed []byte
+	literals := 0 // start of the literal bytes that haven't been written yet
+	flushLiterals := func(end int) {
+		for literals < end {
+			n := end - literals
+			if n > maxLiterals {
+				n = maxLiterals
+			}
+			compressed = append(compressed, byte(n-1))
+			compressed = append(compressed, data[literals:literals+n]...)
+			literals += n
+		}
+	}
+	for i := 0; i < len(data); {
+		bestLength, bestDistance := 0, 0
+		if i+minMatch <= len(data) {
+			limit := len(data) - i
+			if limit > maxMatch {
+				limit = maxMatch
+			}
+			chain := 0
+			for j := head[hash(i)]; j >= 0 && i-j <= maxDistance && chain < maxChain; j = prev[j] {
+				length := 0
+				for length < limit && data[j+length] == data[i+length] {
+					length++
+				}
+				if length > bestLength {
+					bestLength, bestDistance = length, i-j
+					if length == limit {
+						break
+					}
+				}
+				chain++
+			}
+		}
+		if bestLength < minMatch {
+			insert(i)
+			i++
+			continue
+		}
+		flushLiterals(i)
+		compressed = append(compressed, byte(0x80|(bestLength-minMatch)), byte(bestDistance), byte(bestDistance>>8))
+		for end := i + bestLength; i < end; i++ {
+			insert(i)
+		}
+		literals = i
+	}
+	flushLiterals(len(data))
+	return compressed
+}
+
+// readWasmDataSegments reads all segments of the data section payload.
+func readWasmDataSegments(data []byte) ([]wasmDataSegment, error) {
+	count, n, err := decodeULEB128(data)
+	if err != nil {
+		return nil, err
+	}
+	data = data[n:]
+	var segments []wasmDataSegment
+	for i := uint64(0); i < count; i++ {
+		var segment wasmDataSegment
+		segment.flags, n, err = decodeULEB128(data)
+		if err != nil {
+			return nil, err
+		}
+		data = data[n:]
+		if segment.flags > 2 {
+			return nil, fmt.Errorf("unknown data segment kind %d", segment.flags)
+		}
+		if segment.flags == 2 {
+			segment.memory, n, err = decodeULEB128(data)
+			if err != nil {
+				return nil, err
+			}
+			data = data[n:]
+		}
+		if segment.flags != 1 { // active segment
+			n, err := readWasmExpressionReferences(data, nil)
+			if err != nil {
+				return nil, err
+			}
+			segment.offset = data[:n]
+			data = data[n:]
+		}
+		size, n, err := decodeULEB128(data)
+		if err != nil {
+			return nil, err
+		}
+		data = data[n:]
+		if uint64(len(data)) < size {
+			return nil, errors.New("unexpected end of data section")
+		}
+		segment.data = data[:size]
+		data = data[size:]
+		segments = append(segments, segment)
+	}
+	return segments, nil
+}
+
+// appendWasmDataSegments appends the payload of a data section with the given
+// segments to buf.
+func appendWasmDataSegments(buf []byte, segments []wasmDataSegment) []byte {
+	buf = appendULEB128(buf, uint64(len(segments)))
+	for _, segment := range segments {
+		buf = appendULEB128(buf, segment.flags)
+		if segment.flags == 2 {
+			buf = appendULEB128(buf, segment.memory)
+		}
+		buf = append(buf, segment.offset...)
+		buf = appendULEB128(buf, uint64(len(segment.data)))
+		buf = append(buf, segment.data...)
+	}
+	return buf
+}
+
+// wasmConstantOffset returns the offset of an active data segment in memory
+// 0, if it is a constant.
+func wasmConstantOffset(segment wasmDataSegment) (uint64, bool) {
+	if segment.flags == 1 || segment.memory != 0 || len(segment.offset) < 3 || segment.offset[0] != 0x41 { // i32.const
+		return 0, false
+	}
+	value, n, err := decodeSLEB128(segment.offset[1:])
+	if err != nil || 1+n != len(segment.offset)-1 {
+		return 0, false
+	}
+	return uint64(uint32(value)), true
+}
+
+// appendWasmI32Const appends an i32.const instruction for the given address.
+func appendWasmI32Const(code []byte, value uint64) []byte {
+	return appendSLEB128(append(code, 0x41), int64(int32(uint32(value))))
+}
+
+// addWasmFunctionType returns the index of the given function type in the type
+// section payload, adding it if it doesn't exist yet.
+func addWasmFunctionType(payload, functionType []byte) ([]byte, uint64, error) {
+	count, n, err := decodeULEB128(payload)
+	if err != nil {
+		return nil, 0, err
+	}
+	data := payload[n:]
+	for i := uint64(0); i < count; i++ {
+		if len(data) == 0 || data[0] != 0x60 {
+			return nil, 0, errors.New("unknown type in type section")
+		}
+		size := 1
+		for j := 0; j < 2; j++ { // parameters and results
+			numTypes, n, err := decodeULEB128(data[size:])
+			if err != nil {
+				return nil, 0, err
+			}
+			size += n + int(numTypes)
+		}
+		if size > len(data) {
+			return nil, 0, errors.New("unexpected end of type section")
+		}
+		if string(data[:size]) == string(functionType) {
+			return payload, i, nil
+		}
+		data = data[size:]
+	}
+	return appendWasmVectorItem(payload, functionType)
+}
+
+// appendWasmVectorItem adds an item at the end of a section payload that
+// consists of a single vector, like the type or function section. It returns
+// the new payload and the index of the item.
+func appendWasmVectorItem(payload, item []byte) ([]byte, uint64, error) {
+	count, n, err := decodeULEB128(payload)
+	if err != nil {
+		return nil, 0, err
+	}
+	result := appendULEB128(nil, count+1)
+	result = append(result, payload[n:]...)
+	return append(result, item...), count, nil
+}
+
+// wasmSectionOrder returns the position of a (non-custom) section in a module.
+// Sections are ordered by their ID, except for the data count section which
+// comes before the code section.
+func wasmSectionOrder(id byte) int {
+	if id == wasmSectionDataCount {
+		return wasmSectionCode*2 - 1
+	}
+	return int(id) * 2
+}
+
+// decodeSLEB128 decodes a signed LEB128 number. It returns the value and the
+// number of bytes read.
+func decodeSLEB128(buf []byte) (value int64, n int, err error) {
+	var shift uint
+	for {
+		if n >= len(buf) {
+			return 0, 0, errors.New("unexpected end of LEB128 number")
+		}
+		b := buf[n]
+		n++
+		value |= int64(b&0x7f) << shift
+		shift += 7
+		if b&0x80 == 0 {
+			if shift < 64 && b&0x40 != 0 {
+				value |= -1 << shift // sign extend
+			}
+			return value, n, nil
+		}
+		if shift >= 64 {
+			return 0, 0, errors.New("LEB128 number too large")
+		}
+	}
+}
+
+// appendSLEB128 appends the signed LEB128 encoding of value to buf.
+func appendSLEB128(buf []byte, value int64) []byte {
+	for {
+		b := byte(value & 0x7f)
+		value >>= 7
+		if (value == 0 && b&0x40 == 0) || (value == -1 && b&0x40 != 0) {
+			return append(buf, b)
+		}
+		buf = append(buf, b|0x80)
+	}
+}
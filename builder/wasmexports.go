@@ -0,0 +1,170 @@
+package builder
+
+// This file checks the required-exports property of a target: a list of
+// functions that the host expects the WebAssembly module to export. For
+// example, a Substrate runtime must export Core_version and
+// Core_execute_block with the signature (i32, i32) -> i64. Checking this at
+// build time gives a much clearer error than the host refusing to load the
+// module.
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+)
+
+// Names of WebAssembly value types, as used in the required-exports property.
+var wasmValueTypeNames = map[byte]string{
+	0x7f: "i32",
+	0x7e: "i64",
+	0x7d: "f32",
+	0x7c: "f64",
+	0x7b: "v128",
+	0x70: "funcref",
+	0x6f: "externref",
+}
+
+// checkRequiredExports checks whether the WebAssembly file at the given path
+// exports all the given functions. Each entry is a function name, optionally
+// followed by a signature like "(i32,i32)->i64" that the export must have.
+func checkRequiredExports(path string, required []string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	sections, err := readWasmSections(data)
+	if err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+	exports, err := readWasmFunctionExports(sections)
+	if err != nil {
+		return fmt.Errorf("could not read exports: %w", err)
+	}
+	signatures, err := readWasmFunctionSignatures(sections)
+	if err != nil {
+		return fmt.Errorf("could not read function types: %w", err)
+	}
+	exportSignatures := make(map[string]string)
+	for _, export := range exports {
+		if int(export.index) < len(signatures) {
+			exportSignatures[export.name] = signatures[export.index]
+		}
+	}
+	return matchRequiredExports(exportSignatures, required)
+}
+
+// matchRequiredExports checks the required exports against the signatures of
+// the exported functions, indexed by export name.
+func matchRequiredExports(exportSignatures map[string]string, required []string) error {
+	var missing, mismatched []string
+	for _, entry := range required {
+		name, signature := entry, ""
+		if index := strings.IndexByte(entry, '('); index >= 0 {
+			name, signature = entry[:index], strings.ReplaceAll(entry[index:], " ", "")
+		}
+		actual, ok := exportSignatures[name]
+		if !ok {
+			missing = append(missing, name)
+		} else if signature != "" && signature != actual {
+			mismatched = append(mismatched, fmt.Sprintf("%s has signature %s, expected %s", name, actual, signature))
+		}
+	}
+	if len(missing) == 0 && len(mismatched) == 0 {
+		return nil
+	}
+	sort.Strings(missing)
+	sort.Strings(mismatched)
+	var msg []string
+	if len(missing) != 0 {
+		msg = append(msg, "missing required exports: "+strings.Join(missing, ", "))
+	}
+	msg = append(msg, mismatched...)
+	return errors.New(strings.Join(msg, "\n"))
+}
+
+// readWasmFunctionSignatures returns the signature of each function in the
+// function index space (imports first), formatted like "(i32,i32)->i64".
+func readWasmFunctionSignatures(sections []wasmSection) ([]string, error) {
+	var types []string
+	var typeIndices []uint32
+	importTypes, err := readWasmFunctionImportTypes(sections)
+	if err != nil {
+		return nil, err
+	}
+	typeIndices = append(typeIndices, importTypes...)
+	for _, section := range sections {
+		switch section.id {
+		case wasmSectionType:
+			types, err = readWasmTypes(section.payload)
+		case wasmSectionFunction:
+			data := section.payload
+			var count uint64
+			var n int
+			count, n, err = decodeULEB128(data)
+			data = data[n:]
+			for i := uint64(0); i < count && err == nil; i++ {
+				var typeIndex uint64
+				typeIndex, n, err = decodeULEB128(data)
+				data = data[n:]
+				typeIndices = append(typeIndices, uint32(typeIndex))
+			}
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	signatures := make([]string, len(typeIndices))
+	for i, typeIndex := range typeIndices {
+		if int(typeIndex) >= len(types) {
+			return nil, fmt.Errorf("function %d has invalid type index %d", i, typeIndex)
+		}
+		signatures[i] = types[typeIndex]
+	}
+	return signatures, nil
+}
+
+// readWasmTypes reads the function types in the type section.
+func readWasmTypes(data []byte) ([]string, error) {
+	count, n, err := decodeULEB128(data)
+	if err != nil {
+		return nil, err
+	}
+	data = data[n:]
+	var types []string
+	for i := uint64(0); i < count; i++ {
+		if len(data) == 0 || data[0] != 0x60 {
+			return nil, errors.New("expected function type in type section")
+		}
+		data = data[1:]
+		var lists [2][]string // parameters and results
+		for j := range lists {
+			length, n, err := decodeULEB128(data)
+			if err != nil {
+				return nil, err
+			}
+			data = data[n:]
+			if uint64(len(data)) < length {
+				return nil, errors.New("unexpected end of type section")
+			}
+			for _, valueType := range data[:length] {
+				name, ok := wasmValueTypeNames[valueType]
+				if !ok {
+					return nil, fmt.Errorf("unknown value type 0x%x", valueType)
+				}
+				lists[j] = append(lists[j], name)
+			}
+			data = data[length:]
+		}
+		signature := "(" + strings.Join(lists[0], ",") + ")"
+		if len(lists[1]) == 1 {
+			signature += "->" + lists[1][0]
+		} else if len(lists[1]) > 1 {
+			signature += "->(" + strings.Join(lists[1], ",") + ")"
+		}
+		types = append(types, signature)
+	}
+	return types, nil
+}
@@ -0,0 +1,62 @@
+package builder
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestCheckRequiredExports(t *testing.T) {
+	// Build a module with two types, one imported function and two defined
+	// functions, which are exported under three names.
+	var types []byte
+	types = appendULEB128(types, 2)
+	types = append(types, 0x60, 2, 0x7f, 0x7f, 1, 0x7e) // (i32, i32) -> i64
+	types = append(types, 0x60, 0, 0)                   // () -> ()
+	var imports []byte
+	imports = appendULEB128(imports, 1)
+	imports = appendWasmName(imports, "env")
+	imports = appendWasmName(imports, "log")
+	imports = append(imports, 0, 1) // function with type 1
+	var functions []byte
+	functions = append(functions, 2, 0, 1) // two functions with type 0 and 1
+	var exports []byte
+	exports = appendULEB128(exports, 3)
+	exports = appendWasmName(exports, "Core_version")
+	exports = append(exports, 0, 1) // function 1
+	exports = appendWasmName(exports, "Metadata_metadata")
+	exports = append(exports, 0, 1) // also function 1, as merged by -icf
+	exports = appendWasmName(exports, "_start")
+	exports = append(exports, 0, 2) // function 2
+	data := writeWasmSections([]wasmSection{
+		{id: wasmSectionType, payload: types},
+		{id: wasmSectionImport, payload: imports},
+		{id: wasmSectionFunction, payload: functions},
+		{id: wasmSectionExport, payload: exports},
+	})
+	path := filepath.Join(t.TempDir(), "test.wasm")
+	err := os.WriteFile(path, data, 0666)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tc := range []struct {
+		required []string
+		err      string
+	}{
+		{[]string{"Core_version", "_start"}, ""},
+		{[]string{"Core_version(i32, i32)->i64", "_start()"}, ""},
+		{[]string{"Metadata_metadata(i32,i32)->i64", "Core_version"}, ""},
+		{[]string{"Core_version", "Core_execute_block", "Core_initialize_block"}, "missing required exports: Core_execute_block, Core_initialize_block"},
+		{[]string{"Core_version(i32)->i64", "log"}, "missing required exports: log\nCore_version has signature (i32,i32)->i64, expected (i32)->i64"},
+	} {
+		err := checkRequiredExports(path, tc.required)
+		msg := ""
+		if err != nil {
+			msg = err.Error()
+		}
+		if msg != tc.err {
+			t.Errorf("checkRequiredExports(%q):\nexpected: %q\nactual:   %q", tc.required, tc.err, msg)
+		}
+	}
+}
@@ -0,0 +1,343 @@
+package builder
+
+// This file implements identical code folding (ICF) for linked WebAssembly
+// modules. Generic instantiations, compiler generated wrappers and functions
+// from C libraries often compile to the exact same code, which the IR level
+// -merge-functions flag can't always see.
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// foldIdenticalWasmFunctions is the icf post-link pass. It finds functions
+// with the same type, locals and code, and redirects all direct calls to one
+// of them. Functions are compared after redirecting their own calls, so that
+// functions that only differ in which of two identical functions they call
+// are folded as well.
+//
+// Removing a function from the module would mean renumbering all functions
+// after it. Instead, the body of a folded function is replaced with a single
+// unreachable instruction, which takes just a few bytes.
+//
+// Functions of which the address is taken (they're in a table or used in a
+// ref.func instruction), exported functions and the start function keep their
+// body, as the address of two different functions must not compare equal.
+// Calls to them are still redirected.
+//
+// Debug information (DWARF) refers to offsets in the code section, so it is
+// removed when any function is changed.
+func foldIdenticalWasmFunctions(sections []wasmSection) ([]wasmSection, error) {
+	numImports, err := countWasmFunctionImports(sections)
+	if err != nil {
+		return nil, err
+	}
+	var typeIndices []uint64
+	var bodies []wasmFunctionBody
+	codeSection := -1
+	for i, section := range sections {
+		switch section.id {
+		case wasmSectionFunction:
+			typeIndices, err = readWasmFunctionTypeIndices(section.payload)
+		case wasmSectionCode:
+			codeSection = i
+			bodies, err = readWasmFunctionBodies(section.payload)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	if codeSection < 0 || len(bodies) != len(typeIndices) {
+		return sections, nil // nothing to fold
+	}
+	keep, err := readWasmReferencedFunctions(sections)
+	if err != nil {
+		return nil, err
+	}
+
+	// Start with every function as its own representative, and merge groups
+	// of identical functions until nothing changes anymore.
+	canonical := make([]uint32, int(numImports)+len(bodies))
+	for i := range canonical {
+		canonical[i] = uint32(i)
+	}
+	folded := false
+	for changed := true; changed; {
+		changed = false
+		groups := make(map[string][]uint32)
+		var keys []string
+		for i, body := range bodies {
+			code, err := redirectWasmCalls(body.code, canonical)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", wasmFunctionDescription(sections, i), err)
+			}
+			key := string(appendULEB128(nil, typeIndices[i])) + string(body.locals) + string(code)
+			if _, ok := groups[key]; !ok {
+				keys = append(keys, key)
+			}
+			groups[key] = append(groups[key], numImports+uint32(i))
+		}
+		for _, key := range keys {
+			group := groups[key]
+			if len(group) < 2 {
+				continue
+			}
+			// Prefer a function that must be kept anyway.
+			representative := group[0]
+			for _, index := range group {
+				if keep[index] {
+					representative = index
+					break
+				}
+			}
+			for _, index := range group {
+				if canonical[index] != representative {
+					canonical[index] = representative
+					changed = true
+					folded = true
+				}
+			}
+		}
+	}
+	if !folded {
+		return sections, nil
+	}
+
+	for i := range bodies {
+		index := numImports + uint32(i)
+		if canonical[index] != index && !keep[index] {
+			bodies[i] = wasmFunctionBody{
+				locals: []byte{0},          // no locals
+				code:   []byte{0x00, 0x0b}, // unreachable, end
+			}
+			continue
+		}
+		bodies[i].code, err = redirectWasmCalls(bodies[i].code, canonical)
+		if err != nil {
+			return nil, err // already checked above
+		}
+	}
+
+	var result []wasmSection
+	for i, section := range sections {
+		if section.id == wasmSectionCustom && strings.HasPrefix(section.name, ".debug_") {
+			continue
+		}
+		if i == codeSection {
+			section.payload = appendWasmFunctionBodies(nil, bodies)
+		}
+		result = append(result, section)
+	}
+	return result, nil
+}
+
+// readWasmFunctionTypeIndices returns the type index of every function in
+// the function section payload.
+func readWasmFunctionTypeIndices(data []byte) ([]uint64, error) {
+	count, n, err := decodeULEB128(data)
+	if err != nil {
+		return nil, err
+	}
+	data = data[n:]
+	var typeIndices []uint64
+	for i := uint64(0); i < count; i++ {
+		typeIndex, n, err := decodeULEB128(data)
+		if err != nil {
+			return nil, err
+		}
+		data = data[n:]
+		typeIndices = append(typeIndices, typeIndex)
+	}
+	return typeIndices, nil
+}
+
+// redirectWasmCalls returns the code with the target of every call (and
+// return_call) instruction replaced with its canonical function.
+func redirectWasmCalls(code []byte, canonical []uint32) ([]byte, error) {
+	var result []byte
+	for offset := 0; offset < len(code); {
+		inst, err := decodeWasmInstruction(code[offset:])
+		if err != nil {
+			return nil, err
+		}
+		if inst.opcode == 0x10 || inst.opcode == 0x12 { // call, return_call
+			index, _, err := decodeULEB128(code[offset+1:])
+			if err != nil {
+				return nil, err
+			}
+			if index >= uint64(len(canonical)) {
+				return nil, fmt.Errorf("call to unknown function %d", index)
+			}
+			result = append(result, code[offset])
+			result = appendULEB128(result, uint64(canonical[index]))
+		} else {
+			result = append(result, code[offset:offset+inst.size]...)
+		}
+		offset += inst.size
+	}
+	return result, nil
+}
+
+// readWasmReferencedFunctions returns the functions that are referenced other
+// than by a direct call: exported functions, the start function, and functions
+// in an element segment or ref.func instruction (in the code, a global
+// initializer or an element expression).
+func readWasmReferencedFunctions(sections []wasmSection) (map[uint32]bool, error) {
+	referenced := make(map[uint32]bool)
+	exports, err := readWasmExports(sections)
+	if err != nil {
+		return nil, err
+	}
+	for index := range exports {
+		referenced[index] = true
+	}
+	for _, section := range sections {
+		switch section.id {
+		case wasmSectionStart:
+			index, _, err := decodeULEB128(section.payload)
+			if err != nil {
+				return nil, err
+			}
+			referenced[uint32(index)] = true
+		case wasmSectionGlobal:
+			err = readWasmGlobalReferences(section.payload, referenced)
+		case wasmSectionElement:
+			err = readWasmElementReferences(section.payload, referenced)
+		case wasmSectionCode:
+			var bodies []wasmFunctionBody
+			bodies, err = readWasmFunctionBodies(section.payload)
+			for _, body := range bodies {
+				if err == nil {
+					_, err = readWasmExpressionReferences(body.code, referenced)
+				}
+			}
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	return referenced, nil
+}
+
+// readWasmGlobalReferences adds the functions used in the initializers of the
+// global section to referenced.
+func readWasmGlobalReferences(data []byte, referenced map[uint32]bool) error {
+	count, n, err := decodeULEB128(data)
+	if err != nil {
+		return err
+	}
+	data = data[n:]
+	for i := uint64(0); i < count; i++ {
+		if len(data) < 2 {
+			return errors.New("unexpected end of global section")
+		}
+		data = data[2:] // value type and mutability
+		n, err := readWasmExpressionReferences(data, referenced)
+		if err != nil {
+			return err
+		}
+		data = data[n:]
+	}
+	return nil
+}
+
+// readWasmElementReferences adds the functions in all element segments to
+// referenced.
+func readWasmElementReferences(data []byte, referenced map[uint32]bool) error {
+	count, n, err := decodeULEB128(data)
+	if err != nil {
+		return err
+	}
+	data = data[n:]
+	for i := uint64(0); i < count; i++ {
+		flags, n, err := decodeULEB128(data)
+		if err != nil {
+			return err
+		}
+		data = data[n:]
+		if flags > 7 {
+			return fmt.Errorf("unknown element segment kind %d", flags)
+		}
+		if flags&2 != 0 && flags&1 == 0 {
+			// Explicit table index.
+			_, n, err := decodeULEB128(data)
+			if err != nil {
+				return err
+			}
+			data = data[n:]
+		}
+		if flags&1 == 0 {
+			// Offset expression of an active segment.
+			n, err := readWasmExpressionReferences(data, referenced)
+			if err != nil {
+				return err
+			}
+			data = data[n:]
+		}
+		if flags&3 != 0 {
+			// Element kind or reference type.
+			if len(data) == 0 {
+				return errors.New("unexpected end of element section")
+			}
+			data = data[1:]
+		}
+		numElements, n, err := decodeULEB128(data)
+		if err != nil {
+			return err
+		}
+		data = data[n:]
+		for j := uint64(0); j < numElements; j++ {
+			if flags&4 == 0 {
+				// Function index.
+				index, n, err := decodeULEB128(data)
+				if err != nil {
+					return err
+				}
+				data = data[n:]
+				referenced[uint32(index)] = true
+			} else {
+				// Expression, like ref.func.
+				n, err := readWasmExpressionReferences(data, referenced)
+				if err != nil {
+					return err
+				}
+				data = data[n:]
+			}
+		}
+	}
+	return nil
+}
+
+// readWasmExpressionReferences adds the functions used in ref.func
+// instructions to referenced, which may be nil to only get the size. It reads
+// up to the end of the expression (or of code, for a function body) and
+// returns its size.
+func readWasmExpressionReferences(code []byte, referenced map[uint32]bool) (int, error) {
+	depth := 0
+	for offset := 0; offset < len(code); {
+		inst, err := decodeWasmInstruction(code[offset:])
+		if err != nil {
+			return 0, err
+		}
+		switch inst.opcode {
+		case 0x02, 0x03, 0x04, 0x06: // block, loop, if, try
+			depth++
+		case 0x0b: // end
+			if depth == 0 {
+				return offset + inst.size, nil
+			}
+			depth--
+		case 0xd2: // ref.func
+			index, _, err := decodeULEB128(code[offset+1:])
+			if err != nil {
+				return 0, err
+			}
+			if referenced != nil {
+				referenced[uint32(index)] = true
+			}
+		}
+		offset += inst.size
+	}
+	return 0, errors.New("unexpected end of expression")
+}
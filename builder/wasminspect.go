@@ -0,0 +1,373 @@
+package builder
+
+// This file implements `tinygo inspect`, which shows what is inside a
+// WebAssembly module: its sections and their sizes, imports, exports, memory
+// limits and the build information added by TinyGo. It can also check a
+// module against the constraints of a target, such as the exports and imports
+// that a Polkadot host expects.
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/tinygo-org/tinygo/compileopts"
+)
+
+// Names of the known sections, indexed by section ID.
+var wasmSectionNames = []string{
+	"custom", "type", "import", "function", "table", "memory", "global",
+	"export", "start", "element", "code", "data", "datacount", "tag",
+}
+
+// Names of the kinds of imports and exports.
+var wasmExternalKindNames = []string{"func", "table", "memory", "global", "tag"}
+
+// WasmInfo describes the contents of a WebAssembly module.
+type WasmInfo struct {
+	BuildInfo *BuildInfo        `json:"build,omitempty"` // nil if not built by TinyGo
+	Sections  []WasmSectionInfo `json:"sections"`
+	Imports   []WasmExternal    `json:"imports"`
+	Exports   []WasmExternal    `json:"exports"`
+	Memory    *WasmMemory       `json:"memory,omitempty"` // nil if the module has no memory
+}
+
+// WasmSectionInfo is a single section of a WebAssembly module.
+type WasmSectionInfo struct {
+	ID   byte   `json:"id"`
+	Name string `json:"name"` // section name, or the name of a custom section
+	Size int    `json:"size"` // size of the section contents in bytes
+}
+
+// WasmExternal is an imported or exported function, table, memory, global or
+// tag.
+type WasmExternal struct {
+	Module    string `json:"module,omitempty"` // only set for imports
+	Name      string `json:"name"`
+	Kind      string `json:"kind"`
+	Signature string `json:"signature,omitempty"` // only set for functions
+}
+
+// WasmMemory describes the linear memory of a module. The limits are in
+// 64kB pages.
+type WasmMemory struct {
+	Import string `json:"import,omitempty"` // module.name if the memory is imported
+	Export string `json:"export,omitempty"` // export name, if any
+	Min    uint64 `json:"min"`
+	Max    uint64 `json:"max,omitempty"` // only set if there is a maximum
+	HasMax bool   `json:"hasMax"`
+}
+
+// InspectWasm reads the WebAssembly file at the given path and describes its
+// contents.
+func InspectWasm(path string) (*WasmInfo, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	sections, err := readWasmSections(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	info, err := inspectWasmSections(sections)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return info, nil
+}
+
+func inspectWasmSections(sections []wasmSection) (*WasmInfo, error) {
+	info := &WasmInfo{}
+	var err error
+	info.BuildInfo, err = readBuildInfoSection(sections)
+	if err != nil {
+		return nil, err
+	}
+	signatures, err := readWasmFunctionSignatures(sections)
+	if err != nil {
+		return nil, fmt.Errorf("could not read function types: %w", err)
+	}
+	for _, section := range sections {
+		s := WasmSectionInfo{ID: section.id, Size: len(section.payload)}
+		if section.id == wasmSectionCustom {
+			s.Name = section.name
+			s.Size += len(appendWasmName(nil, section.name))
+		} else if int(section.id) < len(wasmSectionNames) {
+			s.Name = wasmSectionNames[section.id]
+		} else {
+			s.Name = fmt.Sprintf("unknown(%d)", section.id)
+		}
+		info.Sections = append(info.Sections, s)
+
+		switch section.id {
+		case wasmSectionImport:
+			err = readWasmImports(info, section.payload, signatures)
+		case wasmSectionMemory:
+			err = readWasmMemories(info, section.payload)
+		case wasmSectionExport:
+			err = readWasmExportList(info, section.payload, signatures)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("could not read %s section: %w", wasmSectionNames[section.id], err)
+		}
+	}
+	return info, nil
+}
+
+// readWasmImports adds the imports in the given import section to info.
+func readWasmImports(info *WasmInfo, data []byte, signatures []string) error {
+	count, n, err := decodeULEB128(data)
+	if err != nil {
+		return err
+	}
+	data = data[n:]
+	var funcIndex int
+	for i := uint64(0); i < count; i++ {
+		var names [2]string // module and field name
+		for j := range names {
+			names[j], n, err = readWasmName(data)
+			if err != nil {
+				return err
+			}
+			data = data[n:]
+		}
+		if len(data) < 2 {
+			return errors.New("unexpected end of import section")
+		}
+		kind := data[0]
+		data = data[1:]
+		if int(kind) >= len(wasmExternalKindNames) {
+			return fmt.Errorf("unknown import kind %d", kind)
+		}
+		imp := WasmExternal{Module: names[0], Name: names[1], Kind: wasmExternalKindNames[kind]}
+		switch kind {
+		case 0: // function: type index
+			_, n, err = decodeULEB128(data)
+			if funcIndex < len(signatures) {
+				imp.Signature = signatures[funcIndex]
+			}
+			funcIndex++
+		case 1: // table: element type and limits
+			n, err = skipWasmLimits(data[1:])
+			n++
+		case 2: // memory: limits
+			memory := &WasmMemory{Import: names[0] + "." + names[1]}
+			memory.Min, memory.Max, memory.HasMax, n, err = readWasmLimits(data)
+			info.Memory = memory
+		case 3: // global: value type and mutability
+			n = 2
+		case 4: // tag: attribute and type index
+			_, n, err = decodeULEB128(data[1:])
+			n++
+		}
+		if err != nil {
+			return err
+		}
+		if n > len(data) {
+			return errors.New("unexpected end of import section")
+		}
+		data = data[n:]
+		info.Imports = append(info.Imports, imp)
+	}
+	return nil
+}
+
+// readWasmMemories reads the memory defined in the given memory section.
+// Modules have at most one memory without the multi-memory proposal, so only
+// the first one is used.
+func readWasmMemories(info *WasmInfo, data []byte) error {
+	count, n, err := decodeULEB128(data)
+	if err != nil || count == 0 {
+		return err
+	}
+	memory := &WasmMemory{}
+	memory.Min, memory.Max, memory.HasMax, _, err = readWasmLimits(data[n:])
+	if err != nil {
+		return err
+	}
+	if info.Memory == nil {
+		info.Memory = memory
+	}
+	return nil
+}
+
+// readWasmExportList adds the exports in the given export section to info.
+func readWasmExportList(info *WasmInfo, data []byte, signatures []string) error {
+	count, n, err := decodeULEB128(data)
+	if err != nil {
+		return err
+	}
+	data = data[n:]
+	for i := uint64(0); i < count; i++ {
+		name, n, err := readWasmName(data)
+		if err != nil {
+			return err
+		}
+		data = data[n:]
+		if len(data) == 0 {
+			return errors.New("unexpected end of export section")
+		}
+		kind := data[0]
+		index, n, err := decodeULEB128(data[1:])
+		if err != nil {
+			return err
+		}
+		data = data[1+n:]
+		if int(kind) >= len(wasmExternalKindNames) {
+			return fmt.Errorf("unknown export kind %d", kind)
+		}
+		exp := WasmExternal{Name: name, Kind: wasmExternalKindNames[kind]}
+		switch kind {
+		case 0: // function
+			if index < uint64(len(signatures)) {
+				exp.Signature = signatures[index]
+			}
+		case 2: // memory
+			if info.Memory != nil && index == 0 {
+				info.Memory.Export = name
+			}
+		}
+		info.Exports = append(info.Exports, exp)
+	}
+	return nil
+}
+
+// String formats the module information in a human readable way.
+func (info *WasmInfo) String() string {
+	var b strings.Builder
+	if info.BuildInfo != nil {
+		b.WriteString(info.BuildInfo.String())
+	} else {
+		b.WriteString("no build information\n")
+	}
+
+	b.WriteString("\nsections:\n")
+	w := tabwriter.NewWriter(&b, 0, 8, 2, ' ', 0)
+	total := 0
+	for _, section := range info.Sections {
+		name := section.Name
+		if section.ID == wasmSectionCustom {
+			name = "custom " + name
+		}
+		fmt.Fprintf(w, "  %s\t%d\n", name, section.Size)
+		total += section.Size
+	}
+	fmt.Fprintf(w, "  total\t%d\n", total)
+	w.Flush()
+
+	b.WriteString("\nmemory:\n")
+	if memory := info.Memory; memory == nil {
+		b.WriteString("  none\n")
+	} else {
+		limits := fmt.Sprintf("min %d pages", memory.Min)
+		if memory.HasMax {
+			limits += fmt.Sprintf(", max %d pages", memory.Max)
+		} else {
+			limits += ", no max"
+		}
+		if memory.Import != "" {
+			limits += ", imported from " + memory.Import
+		}
+		if memory.Export != "" {
+			limits += ", exported as " + memory.Export
+		}
+		fmt.Fprintf(&b, "  %s\n", limits)
+	}
+
+	for _, list := range []struct {
+		title     string
+		externals []WasmExternal
+	}{
+		{"imports", info.Imports},
+		{"exports", info.Exports},
+	} {
+		fmt.Fprintf(&b, "\n%s:\n", list.title)
+		if len(list.externals) == 0 {
+			b.WriteString("  none\n")
+		}
+		w := tabwriter.NewWriter(&b, 0, 8, 2, ' ', 0)
+		for _, ext := range list.externals {
+			name := ext.Name
+			if ext.Module != "" {
+				name = ext.Module + "." + ext.Name
+			}
+			if ext.Signature != "" {
+				fmt.Fprintf(w, "  %s\t%s\t%s\n", ext.Kind, name, ext.Signature)
+			} else {
+				fmt.Fprintf(w, "  %s\t%s\n", ext.Kind, name)
+			}
+		}
+		w.Flush()
+	}
+	return b.String()
+}
+
+// CheckWasmTarget checks whether the module satisfies the constraints of the
+// given target: the exports it requires, the import modules that the host
+// provides and how the memory is shared with the host.
+func CheckWasmTarget(info *WasmInfo, spec *compileopts.TargetSpec) error {
+	var problems []string
+
+	exportSignatures := make(map[string]string)
+	for _, exp := range info.Exports {
+		if exp.Kind == "func" {
+			exportSignatures[exp.Name] = exp.Signature
+		}
+	}
+	if err := matchRequiredExports(exportSignatures, spec.RequiredExports); err != nil {
+		problems = append(problems, err.Error())
+	}
+
+	// All targets provide the "env" module. Only WASI targets provide the
+	// WASI functions.
+	hostModules := map[string]bool{"env": true}
+	for _, tag := range spec.BuildTags {
+		if tag == "wasi" {
+			hostModules["wasi_snapshot_preview1"] = true
+		}
+	}
+	var unknownImports []string
+	for _, imp := range info.Imports {
+		if !hostModules[imp.Module] {
+			unknownImports = append(unknownImports, imp.Module+"."+imp.Name)
+		}
+	}
+	if len(unknownImports) != 0 {
+		sort.Strings(unknownImports)
+		problems = append(problems, "imports from modules not provided by the host: "+strings.Join(unknownImports, ", "))
+	}
+
+	importMemory := false
+	for _, flag := range spec.LDFlags {
+		if flag == "--import-memory" {
+			importMemory = true
+		}
+	}
+	switch {
+	case info.Memory == nil:
+		problems = append(problems, "module has no memory")
+	case importMemory && !strings.HasPrefix(info.Memory.Import, "env."):
+		problems = append(problems, "memory must be imported from the env module")
+	case !importMemory && info.Memory.Import != "":
+		problems = append(problems, "memory must not be imported, but is imported from "+info.Memory.Import)
+	case !importMemory && info.Memory.Export == "":
+		problems = append(problems, "memory must be exported")
+	}
+
+	// The datacount section is only emitted for bulk memory operations.
+	if strings.Contains(spec.Features, "-bulk-memory") {
+		for _, section := range info.Sections {
+			if section.ID == wasmSectionDataCount {
+				problems = append(problems, "module uses bulk memory operations, which the target doesn't support")
+			}
+		}
+	}
+
+	if len(problems) != 0 {
+		return errors.New(strings.Join(problems, "\n"))
+	}
+	return nil
+}
@@ -0,0 +1,64 @@
+package builder
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/tinygo-org/tinygo/compileopts"
+)
+
+func TestInspectWasm(t *testing.T) {
+	sections, err := readWasmSections(makeTestWasmModule())
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Add a type for the imported function, which the test module lacks.
+	sections = append([]wasmSection{{id: wasmSectionType, payload: []byte{1, 0x60, 1, 0x7f, 0}}}, sections...)
+	info, err := inspectWasmSections(sections)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.BuildInfo != nil {
+		t.Error("unexpected build information")
+	}
+	var names []string
+	for _, section := range info.Sections {
+		names = append(names, section.Name)
+	}
+	if s := strings.Join(names, " "); s != "type import code name" {
+		t.Errorf("unexpected sections: %s", s)
+	}
+	if len(info.Imports) != 2 || info.Imports[0] != (WasmExternal{Module: "env", Name: "log", Kind: "func", Signature: "(i32)"}) || info.Imports[1].Kind != "memory" {
+		t.Errorf("unexpected imports: %+v", info.Imports)
+	}
+	if info.Memory == nil || *info.Memory != (WasmMemory{Import: "env.memory", Min: 1, Max: 2, HasMax: true}) {
+		t.Errorf("unexpected memory: %+v", info.Memory)
+	}
+
+	for _, tc := range []struct {
+		spec compileopts.TargetSpec
+		err  string
+	}{
+		{compileopts.TargetSpec{LDFlags: []string{"--import-memory"}}, ""},
+		{compileopts.TargetSpec{LDFlags: []string{"--import-memory"}, RequiredExports: []string{"_start"}}, "missing required exports: _start"},
+		{compileopts.TargetSpec{}, "memory must not be imported, but is imported from env.memory"},
+	} {
+		err := CheckWasmTarget(info, &tc.spec)
+		msg := ""
+		if err != nil {
+			msg = err.Error()
+		}
+		if msg != tc.err {
+			t.Errorf("CheckWasmTarget(%+v):\nexpected: %q\nactual:   %q", tc.spec, tc.err, msg)
+		}
+	}
+
+	info.Imports = append(info.Imports, WasmExternal{Module: "wasi_snapshot_preview1", Name: "fd_write", Kind: "func"})
+	err = CheckWasmTarget(info, &compileopts.TargetSpec{LDFlags: []string{"--import-memory"}, BuildTags: []string{"wasm_unknown"}})
+	if err == nil || err.Error() != "imports from modules not provided by the host: wasi_snapshot_preview1.fd_write" {
+		t.Errorf("unexpected error for a WASI import: %v", err)
+	}
+	if err := CheckWasmTarget(info, &compileopts.TargetSpec{LDFlags: []string{"--import-memory"}, BuildTags: []string{"wasi"}}); err != nil {
+		t.Errorf("unexpected error for a WASI target: %v", err)
+	}
+}
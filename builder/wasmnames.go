@@ -0,0 +1,221 @@
+package builder
+
+// This file implements the -names= flag, which controls the WebAssembly name
+// section independently of DWARF debug information.
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// Subsection IDs of the name section.
+// https://webassembly.github.io/spec/core/appendix/custom.html#name-section
+const (
+	wasmNameModule   = 0
+	wasmNameFunction = 1
+)
+
+// LLVM appends a numeric suffix to make symbol names unique. Such a suffix can
+// never be part of a Go identifier.
+var llvmUniqueSuffixRegexp = regexp.MustCompile(`\.[0-9]+$`)
+
+// rewriteWasmNames updates the name section of the given WebAssembly file
+// according to the -names= flag:
+//
+//	demangle:      keep all names, with function names demangled to the form
+//	               used by the gc toolchain (runtime.alloc, bytes.(*Buffer).Write)
+//	               so that profiles and traps look familiar
+//	exported-only: only keep the names of exported functions
+//	strip:         remove the name section entirely
+//
+// With the default of -names=keep, the name section is left as the linker
+// wrote it and this function isn't called.
+func rewriteWasmNames(path, mode string) error {
+	return updateWasmFile(path, func(sections []wasmSection) ([]wasmSection, error) {
+		exports, err := readWasmExports(sections)
+		if err != nil {
+			return nil, fmt.Errorf("could not read exports: %w", err)
+		}
+		var result []wasmSection
+		for _, section := range sections {
+			if section.id != wasmSectionCustom || section.name != "name" {
+				result = append(result, section)
+				continue
+			}
+			if mode == "strip" {
+				continue
+			}
+			payload, err := rewriteNameSection(section.payload, wasmNameRewriter(mode, exports), mode == "demangle")
+			if err != nil {
+				return nil, fmt.Errorf("could not rewrite name section: %w", err)
+			}
+			section.payload = payload
+			result = append(result, section)
+		}
+		return result, nil
+	})
+}
+
+// wasmNameRewriter returns the callback for rewriteNameSection that implements
+// the given -names= mode, for a module with the given exported functions.
+func wasmNameRewriter(mode string, exports map[uint32]string) func(index uint32, name string) (string, bool) {
+	return func(index uint32, name string) (string, bool) {
+		if mode == "demangle" {
+			return demangleGoSymbol(name), true
+		}
+		_, ok := exports[index]
+		return name, ok
+	}
+}
+
+// rewriteNameSection rewrites the function names subsection of a name section
+// through the rename callback, which may also drop a name by returning false.
+// All other subsections (local names etc) are kept if keepOther is true and
+// removed otherwise, except for the module name which is always kept.
+func rewriteNameSection(data []byte, rename func(index uint32, name string) (string, bool), keepOther bool) ([]byte, error) {
+	var out []byte
+	for len(data) != 0 {
+		id := data[0]
+		size, n, err := decodeULEB128(data[1:])
+		if err != nil {
+			return nil, err
+		}
+		start := 1 + n
+		if uint64(len(data)-start) < size {
+			return nil, fmt.Errorf("name subsection %d extends beyond the end of the section", id)
+		}
+		contents := data[start : start+int(size)]
+		data = data[start+int(size):]
+
+		switch {
+		case id == wasmNameFunction:
+			count, n, err := decodeULEB128(contents)
+			if err != nil {
+				return nil, err
+			}
+			contents = contents[n:]
+			var entries []byte
+			var numEntries uint64
+			for i := uint64(0); i < count; i++ {
+				index, n, err := decodeULEB128(contents)
+				if err != nil {
+					return nil, err
+				}
+				contents = contents[n:]
+				name, n, err := readWasmName(contents)
+				if err != nil {
+					return nil, err
+				}
+				contents = contents[n:]
+				if name, ok := rename(uint32(index), name); ok {
+					entries = appendULEB128(entries, index)
+					entries = appendWasmName(entries, name)
+					numEntries++
+				}
+			}
+			subsection := appendULEB128(nil, numEntries)
+			subsection = append(subsection, entries...)
+			out = append(out, id)
+			out = appendULEB128(out, uint64(len(subsection)))
+			out = append(out, subsection...)
+		case id == wasmNameModule || keepOther:
+			out = append(out, id)
+			out = appendULEB128(out, size)
+			out = append(out, contents...)
+		}
+	}
+	return out, nil
+}
+
+// demangleGoSymbol converts a TinyGo link name into the function name the gc
+// toolchain would use for the same function, so that stack traces and
+// profiles look familiar. Names that don't look like Go symbols are returned
+// unchanged. Some examples:
+//
+//	runtime.alloc                 -> runtime.alloc
+//	(*bytes.Buffer).Write         -> bytes.(*Buffer).Write
+//	(time.Duration).String        -> time.Duration.String
+//	main.main$1                   -> main.main.func1
+//	(*main.T).Method$bound        -> main.(*T).Method-fm
+//	runtime.runtimePanicAt.1      -> runtime.runtimePanicAt
+func demangleGoSymbol(name string) string {
+	name = llvmUniqueSuffixRegexp.ReplaceAllString(name, "")
+
+	// Move the package path out of the receiver type, which is where the gc
+	// toolchain puts it.
+	if strings.HasPrefix(name, "(") {
+		if end := matchingParen(name); end > 0 {
+			recv := name[1:end]
+			ptr := ""
+			if strings.HasPrefix(recv, "*") {
+				ptr = "*"
+				recv = recv[1:]
+			}
+			typeName := recv
+			if i := strings.IndexByte(typeName, '['); i >= 0 {
+				typeName = typeName[:i] // ignore type parameters
+			}
+			if sep := strings.LastIndexByte(typeName, '.'); sep >= 0 {
+				pkg, typ := recv[:sep], recv[sep+1:]
+				if ptr != "" {
+					typ = "(" + ptr + typ + ")"
+				}
+				name = pkg + "." + typ + name[end+1:]
+			}
+		}
+	}
+
+	// Closures and method values use a $ suffix in TinyGo.
+	parts := strings.Split(name, "$")
+	name = parts[0]
+	closures := 0
+	for _, part := range parts[1:] {
+		switch {
+		case part == "bound":
+			name += "-fm"
+		case isDecimal(part):
+			if closures == 0 {
+				name += ".func" + part
+			} else {
+				name += "." + part
+			}
+			closures++
+		default:
+			// Other compiler-generated functions, like $invoke wrappers.
+			name += "$" + part
+		}
+	}
+	return name
+}
+
+// matchingParen returns the index of the parenthesis that closes the one at
+// the start of s, or -1 if there is none.
+func matchingParen(s string) int {
+	depth := 0
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '(':
+			depth++
+		case ')':
+			depth--
+			if depth == 0 {
+				return i
+			}
+		}
+	}
+	return -1
+}
+
+// isDecimal returns whether s is a non-empty string of decimal digits.
+func isDecimal(s string) bool {
+	if s == "" {
+		return false
+	}
+	for _, c := range s {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+	return true
+}
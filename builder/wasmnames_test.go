@@ -0,0 +1,84 @@
+package builder
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestDemangleGoSymbol(t *testing.T) {
+	for _, tc := range []struct {
+		in, out string
+	}{
+		{"runtime.alloc", "runtime.alloc"},
+		{"malloc", "malloc"},
+		{"(*bytes.Buffer).Write", "bytes.(*Buffer).Write"},
+		{"(time.Duration).String", "time.Duration.String"},
+		{"(*github.com/foo/bar.T).Method", "github.com/foo/bar.(*T).Method"},
+		{"(*main.List[main.Item]).Len", "main.(*List[main.Item]).Len"},
+		{"main.main$1", "main.main.func1"},
+		{"main.main$1$2", "main.main.func1.2"},
+		{"(*main.T).Method$bound", "main.(*T).Method-fm"},
+		{"interface:{String:func:{}{basic:string}}.String$invoke", "interface:{String:func:{}{basic:string}}.String$invoke"},
+		{"runtime.runtimePanicAt.1", "runtime.runtimePanicAt"},
+	} {
+		if got := demangleGoSymbol(tc.in); got != tc.out {
+			t.Errorf("demangleGoSymbol(%q): expected %q, got %q", tc.in, tc.out, got)
+		}
+	}
+}
+
+func TestRewriteNameSection(t *testing.T) {
+	// Build a name section with a module name, two function names and a
+	// (fake) local names subsection.
+	var functions []byte
+	functions = appendULEB128(functions, 2)
+	functions = appendULEB128(functions, 0)
+	functions = appendWasmName(functions, "main.main$1")
+	functions = appendULEB128(functions, 1)
+	functions = appendWasmName(functions, "(*main.T).Run")
+	var section []byte
+	section = append(section, wasmNameModule)
+	section = appendULEB128(section, uint64(len(appendWasmName(nil, "mod"))))
+	section = appendWasmName(section, "mod")
+	section = append(section, wasmNameFunction)
+	section = appendULEB128(section, uint64(len(functions)))
+	section = append(section, functions...)
+	section = append(section, 2, 1, 0)
+
+	exports := map[uint32]string{1: "run"}
+	for _, tc := range []struct {
+		mode      string
+		functions []string
+		locals    bool
+	}{
+		{"demangle", []string{"main.main.func1", "main.(*T).Run"}, true},
+		{"exported-only", []string{"(*main.T).Run"}, false},
+	} {
+		out, err := rewriteNameSection(section, wasmNameRewriter(tc.mode, exports), tc.mode == "demangle")
+		if err != nil {
+			t.Fatalf("%s: %v", tc.mode, err)
+		}
+		var expected []byte
+		expected = append(expected, section[:1+1+4]...) // module name subsection
+		var names []byte
+		names = appendULEB128(names, uint64(len(tc.functions)))
+		for _, name := range tc.functions {
+			index := uint64(0)
+			if strings.HasSuffix(name, ".Run") {
+				index = 1
+			}
+			names = appendULEB128(names, index)
+			names = appendWasmName(names, name)
+		}
+		expected = append(expected, wasmNameFunction)
+		expected = appendULEB128(expected, uint64(len(names)))
+		expected = append(expected, names...)
+		if tc.locals {
+			expected = append(expected, 2, 1, 0)
+		}
+		if !bytes.Equal(out, expected) {
+			t.Errorf("%s: unexpected name section:\nexpected: %x\nactual:   %x", tc.mode, expected, out)
+		}
+	}
+}
@@ -0,0 +1,594 @@
+package builder
+
+// This file implements the -function-order flag, which changes the order of
+// the functions in the code section. Hosts that compile a module while it is
+// streamed in, or in tiers (like wasmtime and its baseline compiler), can
+// start running it sooner when the functions that are needed first come
+// first, and the code that is rarely needed comes last.
+//
+// The order is one of:
+//
+//	source:    the order of the linker, which follows the source code (the
+//	           default)
+//	callgraph: the functions reachable from the start function and the
+//	           exports, depth first in the order they're called, followed by
+//	           the functions that are only called on error paths
+//	profile:   the functions in the -function-profile file, most called
+//	           first, followed by the rest in callgraph order
+//
+// The profile is a text file with one function per line: the number of calls
+// and the function name (as in the name section, mangled or demangled),
+// separated by a space. Empty lines and lines starting with # are ignored.
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// reorderWasmFunctions changes the order of the functions in the WebAssembly
+// file at the given path according to the -function-order flag.
+func reorderWasmFunctions(path, order, profilePath string) error {
+	if order == "" || order == "source" {
+		return nil
+	}
+	var profile map[string]uint64
+	if order == "profile" {
+		var err error
+		profile, err = readFunctionProfile(profilePath)
+		if err != nil {
+			return err
+		}
+	}
+	return updateWasmFile(path, func(sections []wasmSection) ([]wasmSection, error) {
+		order, err := wasmFunctionOrder(sections, profile)
+		if err != nil {
+			return nil, err
+		}
+		return renumberWasmFunctions(sections, order)
+	})
+}
+
+// readFunctionProfile reads the call counts of a -function-profile file,
+// indexed by function name.
+func readFunctionProfile(path string) (map[string]uint64, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	profile := make(map[string]uint64)
+	scanner := bufio.NewScanner(f)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		countText, name, ok := strings.Cut(text, " ")
+		count, err := strconv.ParseUint(countText, 10, 64)
+		if !ok || err != nil {
+			return nil, fmt.Errorf("%s:%d: expected a call count and a function name", path, line)
+		}
+		profile[strings.TrimSpace(name)] += count
+	}
+	return profile, scanner.Err()
+}
+
+// wasmCall is a direct call in a function body.
+type wasmCall struct {
+	callee uint32 // function index
+	cold   bool   // followed by unreachable, like a call to runtime._panic
+}
+
+// wasmFunctionOrder returns the new order of the functions defined in the
+// module, as indices into the code section. Without a profile, this is the
+// callgraph order.
+func wasmFunctionOrder(sections []wasmSection, profile map[string]uint64) ([]uint32, error) {
+	numImports, err := countWasmFunctionImports(sections)
+	if err != nil {
+		return nil, err
+	}
+	var bodies []wasmFunctionBody
+	var roots []uint32
+	for _, section := range sections {
+		switch section.id {
+		case wasmSectionCode:
+			bodies, err = readWasmFunctionBodies(section.payload)
+		case wasmSectionStart:
+			var index uint64
+			index, _, err = decodeULEB128(section.payload)
+			roots = append(roots, uint32(index))
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	referenced, err := readWasmReferencedFunctions(sections)
+	if err != nil {
+		return nil, err
+	}
+
+	// Find all direct calls.
+	calls := make([][]wasmCall, len(bodies))
+	for i, body := range bodies {
+		for offset := 0; offset < len(body.code); {
+			inst, err := decodeWasmInstruction(body.code[offset:])
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", wasmFunctionDescription(sections, i), err)
+			}
+			if inst.opcode == 0x10 || inst.opcode == 0x12 { // call, return_call
+				index, _, err := decodeULEB128(body.code[offset+1:])
+				if err != nil {
+					return nil, err
+				}
+				if index >= uint64(numImports)+uint64(len(bodies)) {
+					return nil, fmt.Errorf("%s: call to unknown function %d", wasmFunctionDescription(sections, i), index)
+				}
+				next := offset + inst.size
+				cold := inst.opcode == 0x10 && next < len(body.code) && body.code[next] == 0x00 // unreachable
+				calls[i] = append(calls[i], wasmCall{callee: uint32(index), cold: cold})
+			}
+			offset += inst.size
+		}
+	}
+
+	// A function is cold when it is only called right before an unreachable
+	// instruction, or from other cold functions, like in
+	// transform.SplitColdFunctions.
+	cold := make([]bool, len(bodies))
+	for changed := true; changed; {
+		changed = false
+		hot := make([]bool, len(bodies))
+		called := make([]bool, len(bodies))
+		for i := range bodies {
+			for _, call := range calls[i] {
+				if call.callee < numImports {
+					continue
+				}
+				callee := call.callee - numImports
+				called[callee] = true
+				if !call.cold && !cold[i] {
+					hot[callee] = true
+				}
+			}
+		}
+		for i := range bodies {
+			if !cold[i] && called[i] && !hot[i] && !referenced[numImports+uint32(i)] {
+				cold[i] = true
+				changed = true
+			}
+		}
+	}
+
+	var order []uint32
+	placed := make([]bool, len(bodies))
+	place := func(i uint32) {
+		if !placed[i] {
+			placed[i] = true
+			order = append(order, i)
+		}
+	}
+
+	// Place the functions of the profile first.
+	if profile != nil {
+		names, err := readWasmFunctionNames(sections)
+		if err != nil {
+			return nil, err
+		}
+		if len(names) == 0 {
+			return nil, errors.New("-function-order=profile needs the function names of the name section")
+		}
+		counts := make([]uint64, len(bodies))
+		var profiled []uint32
+		for i := range bodies {
+			name := names[numImports+uint32(i)]
+			counts[i] = profile[name]
+			if demangled := demangleGoSymbol(name); demangled != name {
+				counts[i] += profile[demangled]
+			}
+			if counts[i] != 0 {
+				profiled = append(profiled, uint32(i))
+			}
+		}
+		sort.SliceStable(profiled, func(a, b int) bool {
+			return counts[profiled[a]] > counts[profiled[b]]
+		})
+		for _, i := range profiled {
+			place(i)
+		}
+	}
+
+	// Then walk the call graph depth first, starting at the start function,
+	// the exports and the functions in a table. Cold functions are left for
+	// the end.
+	var indices []uint32
+	for index := range referenced {
+		indices = append(indices, index)
+	}
+	sort.Slice(indices, func(a, b int) bool {
+		return indices[a] < indices[b]
+	})
+	roots = append(roots, indices...)
+	visited := make([]bool, len(bodies))
+	var visit func(index uint32)
+	visit = func(index uint32) {
+		if index < numImports {
+			return
+		}
+		i := index - numImports
+		if visited[i] || cold[i] {
+			return
+		}
+		visited[i] = true
+		place(i)
+		for _, call := range calls[i] {
+			visit(call.callee)
+		}
+	}
+	for _, index := range roots {
+		visit(index)
+	}
+
+	// Functions that weren't reached (which should be rare) keep their order,
+	// followed by the cold functions.
+	for i := range bodies {
+		if !cold[i] {
+			place(uint32(i))
+		}
+	}
+	for i := range bodies {
+		place(uint32(i))
+	}
+	return order, nil
+}
+
+// renumberWasmFunctions moves the functions defined in the module to the
+// given order and updates all references to them: calls, ref.func
+// instructions, exports, the start function, element segments and the name
+// section. Debug information (DWARF) refers to offsets in the code section, so
+// it is removed.
+func renumberWasmFunctions(sections []wasmSection, order []uint32) ([]wasmSection, error) {
+	numImports, err := countWasmFunctionImports(sections)
+	if err != nil {
+		return nil, err
+	}
+	mapping := make([]uint32, int(numImports)+len(order))
+	for i := range mapping {
+		mapping[i] = uint32(i)
+	}
+	for newIndex, oldIndex := range order {
+		mapping[numImports+oldIndex] = numImports + uint32(newIndex)
+	}
+
+	var result []wasmSection
+	for _, section := range sections {
+		var err error
+		switch section.id {
+		case wasmSectionFunction:
+			var typeIndices []uint64
+			typeIndices, err = readWasmFunctionTypeIndices(section.payload)
+			if err == nil && len(typeIndices) != len(order) {
+				err = errors.New("function and code section don't match")
+			}
+			if err == nil {
+				section.payload = appendULEB128(nil, uint64(len(order)))
+				for _, i := range order {
+					section.payload = appendULEB128(section.payload, typeIndices[i])
+				}
+			}
+		case wasmSectionCode:
+			var bodies []wasmFunctionBody
+			bodies, err = readWasmFunctionBodies(section.payload)
+			var newBodies []wasmFunctionBody
+			for _, i := range order {
+				if err != nil {
+					break
+				}
+				body := bodies[i]
+				body.code, err = remapWasmFunctionIndices(body.code, mapping)
+				newBodies = append(newBodies, body)
+			}
+			section.payload = appendWasmFunctionBodies(nil, newBodies)
+		case wasmSectionExport:
+			section.payload, err = remapWasmExports(section.payload, mapping)
+		case wasmSectionStart:
+			var index uint64
+			index, _, err = decodeULEB128(section.payload)
+			section.payload = appendULEB128(nil, uint64(mapping[index]))
+		case wasmSectionElement:
+			section.payload, err = remapWasmElements(section.payload, mapping)
+		case wasmSectionGlobal:
+			section.payload, err = remapWasmGlobals(section.payload, mapping)
+		case wasmSectionCustom:
+			if strings.HasPrefix(section.name, ".debug_") {
+				continue
+			}
+			if section.name == "name" {
+				section.payload, err = remapWasmNameSection(section.payload, mapping)
+			}
+		}
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, section)
+	}
+	return result, nil
+}
+
+// remapWasmFunctionIndices returns the code (a function body or an
+// expression) with the function index of every call, return_call and ref.func
+// instruction replaced according to mapping.
+func remapWasmFunctionIndices(code []byte, mapping []uint32) ([]byte, error) {
+	var result []byte
+	for offset := 0; offset < len(code); {
+		inst, err := decodeWasmInstruction(code[offset:])
+		if err != nil {
+			return nil, err
+		}
+		switch inst.opcode {
+		case 0x10, 0x12, 0xd2: // call, return_call, ref.func
+			index, _, err := decodeULEB128(code[offset+1:])
+			if err != nil {
+				return nil, err
+			}
+			if index >= uint64(len(mapping)) {
+				return nil, fmt.Errorf("reference to unknown function %d", index)
+			}
+			result = append(result, code[offset])
+			result = appendULEB128(result, uint64(mapping[index]))
+		default:
+			result = append(result, code[offset:offset+inst.size]...)
+		}
+		offset += inst.size
+	}
+	return result, nil
+}
+
+// remapWasmExpression reads an expression (up to its end instruction) from
+// data, and appends it to buf with the function indices replaced. It returns
+// the new buffer and the size of the expression in data.
+func remapWasmExpression(buf, data []byte, mapping []uint32) ([]byte, int, error) {
+	n, err := readWasmExpressionReferences(data, nil)
+	if err != nil {
+		return nil, 0, err
+	}
+	expr, err := remapWasmFunctionIndices(data[:n], mapping)
+	if err != nil {
+		return nil, 0, err
+	}
+	return append(buf, expr...), n, nil
+}
+
+// remapWasmExports returns the export section payload with the indices of the
+// exported functions replaced.
+func remapWasmExports(data []byte, mapping []uint32) ([]byte, error) {
+	count, n, err := decodeULEB128(data)
+	if err != nil {
+		return nil, err
+	}
+	data = data[n:]
+	result := appendULEB128(nil, count)
+	for i := uint64(0); i < count; i++ {
+		name, n, err := readWasmName(data)
+		if err != nil {
+			return nil, err
+		}
+		data = data[n:]
+		if len(data) == 0 {
+			return nil, errors.New("unexpected end of export section")
+		}
+		kind := data[0]
+		index, n, err := decodeULEB128(data[1:])
+		if err != nil {
+			return nil, err
+		}
+		data = data[1+n:]
+		if kind == 0 { // function export
+			index = uint64(mapping[index])
+		}
+		result = appendWasmName(result, name)
+		result = append(result, kind)
+		result = appendULEB128(result, index)
+	}
+	return result, nil
+}
+
+// remapWasmGlobals returns the global section payload with the function
+// indices in the initializers replaced.
+func remapWasmGlobals(data []byte, mapping []uint32) ([]byte, error) {
+	count, n, err := decodeULEB128(data)
+	if err != nil {
+		return nil, err
+	}
+	data = data[n:]
+	result := appendULEB128(nil, count)
+	for i := uint64(0); i < count; i++ {
+		if len(data) < 2 {
+			return nil, errors.New("unexpected end of global section")
+		}
+		result = append(result, data[:2]...) // value type and mutability
+		data = data[2:]
+		result, n, err = remapWasmExpression(result, data, mapping)
+		if err != nil {
+			return nil, err
+		}
+		data = data[n:]
+	}
+	return result, nil
+}
+
+// remapWasmElements returns the element section payload with the function
+// indices replaced. See readWasmElementReferences for the format.
+func remapWasmElements(data []byte, mapping []uint32) ([]byte, error) {
+	count, n, err := decodeULEB128(data)
+	if err != nil {
+		return nil, err
+	}
+	data = data[n:]
+	result := appendULEB128(nil, count)
+	for i := uint64(0); i < count; i++ {
+		flags, n, err := decodeULEB128(data)
+		if err != nil {
+			return nil, err
+		}
+		data = data[n:]
+		result = appendULEB128(result, flags)
+		if flags > 7 {
+			return nil, fmt.Errorf("unknown element segment kind %d", flags)
+		}
+		if flags&2 != 0 && flags&1 == 0 {
+			// Explicit table index.
+			_, n, err := decodeULEB128(data)
+			if err != nil {
+				return nil, err
+			}
+			result = append(result, data[:n]...)
+			data = data[n:]
+		}
+		if flags&1 == 0 {
+			// Offset expression of an active segment.
+			result, n, err = remapWasmExpression(result, data, mapping)
+			if err != nil {
+				return nil, err
+			}
+			data = data[n:]
+		}
+		if flags&3 != 0 {
+			// Element kind or reference type.
+			if len(data) == 0 {
+				return nil, errors.New("unexpected end of element section")
+			}
+			result = append(result, data[0])
+			data = data[1:]
+		}
+		numElements, n, err := decodeULEB128(data)
+		if err != nil {
+			return nil, err
+		}
+		data = data[n:]
+		result = appendULEB128(result, numElements)
+		for j := uint64(0); j < numElements; j++ {
+			if flags&4 == 0 {
+				// Function index.
+				index, n, err := decodeULEB128(data)
+				if err != nil {
+					return nil, err
+				}
+				if index >= uint64(len(mapping)) {
+					return nil, fmt.Errorf("reference to unknown function %d", index)
+				}
+				data = data[n:]
+				result = appendULEB128(result, uint64(mapping[index]))
+			} else {
+				// Expression, like ref.func.
+				result, n, err = remapWasmExpression(result, data, mapping)
+				if err != nil {
+					return nil, err
+				}
+				data = data[n:]
+			}
+		}
+	}
+	return result, nil
+}
+
+// Subsection ID of the local names in the name section.
+const wasmNameLocal = 2
+
+// remapWasmNameSection returns the name section payload with the function
+// indices of the function names and local names replaced. Both are sorted by
+// function index, as the format requires.
+func remapWasmNameSection(data []byte, mapping []uint32) ([]byte, error) {
+	var out []byte
+	for len(data) != 0 {
+		id := data[0]
+		size, n, err := decodeULEB128(data[1:])
+		if err != nil {
+			return nil, err
+		}
+		start := 1 + n
+		if uint64(len(data)-start) < size {
+			return nil, fmt.Errorf("name subsection %d extends beyond the end of the section", id)
+		}
+		contents := data[start : start+int(size)]
+		data = data[start+int(size):]
+		if id != wasmNameFunction && id != wasmNameLocal {
+			out = append(out, id)
+			out = appendULEB128(out, size)
+			out = append(out, contents...)
+			continue
+		}
+
+		// Both are a vector of a function index followed by something else:
+		// a name, or a vector of local indices and names.
+		type entry struct {
+			index uint32
+			value []byte
+		}
+		count, n, err := decodeULEB128(contents)
+		if err != nil {
+			return nil, err
+		}
+		contents = contents[n:]
+		var entries []entry
+		for i := uint64(0); i < count; i++ {
+			index, n, err := decodeULEB128(contents)
+			if err != nil {
+				return nil, err
+			}
+			if index >= uint64(len(mapping)) {
+				return nil, fmt.Errorf("name of unknown function %d", index)
+			}
+			contents = contents[n:]
+			var size int
+			if id == wasmNameFunction {
+				_, size, err = readWasmName(contents)
+			} else {
+				size, err = skipWasmNameMap(contents)
+			}
+			if err != nil {
+				return nil, err
+			}
+			entries = append(entries, entry{mapping[index], contents[:size]})
+			contents = contents[size:]
+		}
+		sort.Slice(entries, func(a, b int) bool {
+			return entries[a].index < entries[b].index
+		})
+		subsection := appendULEB128(nil, count)
+		for _, e := range entries {
+			subsection = appendULEB128(subsection, uint64(e.index))
+			subsection = append(subsection, e.value...)
+		}
+		out = append(out, id)
+		out = appendULEB128(out, uint64(len(subsection)))
+		out = append(out, subsection...)
+	}
+	return out, nil
+}
+
+// skipWasmNameMap returns the size of a name map (a vector of indices and
+// names) at the start of buf, like the local names of a function.
+func skipWasmNameMap(buf []byte) (int, error) {
+	count, size, err := decodeULEB128(buf)
+	if err != nil {
+		return 0, err
+	}
+	for i := uint64(0); i < count; i++ {
+		_, n, err := decodeULEB128(buf[size:])
+		if err != nil {
+			return 0, err
+		}
+		size += n
+		_, n, err = readWasmName(buf[size:])
+		if err != nil {
+			return 0, err
+		}
+		size += n
+	}
+	return size, nil
+}
@@ -0,0 +1,138 @@
+package builder
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// testOrderModule returns a module with seven functions named main.f0 to
+// main.f6. Function 0 is exported and calls 3 (which calls 5), and calls 1
+// right before a trap. Function 1 calls 4, so both are cold. Function 6 is in
+// a table and function 2 isn't used at all.
+func testOrderModule() []wasmSection {
+	types := []byte{1, 0x60, 0, 0} // () -> ()
+	nop := []byte{0x01, 0x0b}
+	bodies := []wasmFunctionBody{
+		{locals: []byte{0}, code: []byte{0x10, 3, 0x10, 1, 0x00, 0x0b}}, // call 3, call 1, unreachable
+		{locals: []byte{0}, code: []byte{0x10, 4, 0x0b}},                // call 4
+		{locals: []byte{0}, code: nop},
+		{locals: []byte{0}, code: []byte{0x10, 5, 0x0b}}, // call 5
+		{locals: []byte{0}, code: nop},
+		{locals: []byte{0}, code: nop},
+		{locals: []byte{0}, code: nop},
+	}
+	functions := []byte{7, 0, 0, 0, 0, 0, 0, 0}
+	elements := []byte{1, 0, 0x41, 1, 0x0b, 1, 6} // elem (i32.const 1) func 6
+	exports := appendWasmName([]byte{1}, "main")
+	exports = append(exports, 0, 0)
+	names := []byte{7}
+	for i := 0; i < 7; i++ {
+		names = append(names, byte(i))
+		names = appendWasmName(names, "main.f"+string(rune('0'+i)))
+	}
+	nameSection := append([]byte{wasmNameFunction}, appendULEB128(nil, uint64(len(names)))...)
+	nameSection = append(nameSection, names...)
+	return []wasmSection{
+		{id: wasmSectionType, payload: types},
+		{id: wasmSectionFunction, payload: functions},
+		{id: wasmSectionExport, payload: exports},
+		{id: wasmSectionElement, payload: elements},
+		{id: wasmSectionCode, payload: appendWasmFunctionBodies(nil, bodies)},
+		{id: wasmSectionCustom, name: ".debug_info", payload: []byte{1, 2, 3}},
+		{id: wasmSectionCustom, name: "name", payload: nameSection},
+	}
+}
+
+func TestWasmFunctionOrder(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		profile  map[string]uint64
+		expected []uint32
+	}{
+		{"callgraph", nil, []uint32{0, 3, 5, 6, 2, 1, 4}},
+		{"profile", map[string]uint64{"main.f2": 10, "main.f5": 5, "main.unknown": 20}, []uint32{2, 5, 0, 3, 6, 1, 4}},
+	} {
+		order, err := wasmFunctionOrder(testOrderModule(), tc.profile)
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", tc.name, err)
+		}
+		if !reflect.DeepEqual(order, tc.expected) {
+			t.Errorf("%s: expected order %v, got %v", tc.name, tc.expected, order)
+		}
+	}
+}
+
+func TestRenumberWasmFunctions(t *testing.T) {
+	sections, err := renumberWasmFunctions(testOrderModule(), []uint32{0, 3, 5, 6, 2, 1, 4})
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+	if len(sections) != 6 {
+		t.Errorf("expected debug information to be removed, got %d sections", len(sections))
+	}
+
+	// Calls and table entries refer to the new indices.
+	bodies, err := readWasmFunctionBodies(sections[4].payload)
+	if err != nil {
+		t.Fatal(err)
+	}
+	nop := []byte{0x01, 0x0b}
+	for i, expected := range [][]byte{
+		{0x10, 1, 0x10, 5, 0x00, 0x0b},
+		{0x10, 2, 0x0b},
+		nop,
+		nop,
+		nop,
+		{0x10, 6, 0x0b},
+		nop,
+	} {
+		if !bytes.Equal(bodies[i].code, expected) {
+			t.Errorf("function %d: expected % x, got % x", i, expected, bodies[i].code)
+		}
+	}
+	referenced := make(map[uint32]bool)
+	if err := readWasmElementReferences(sections[3].payload, referenced); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(referenced, map[uint32]bool{3: true}) {
+		t.Errorf("expected function 3 in the table, got %v", referenced)
+	}
+
+	// The names moved along with the functions.
+	names, err := readWasmFunctionNames(sections)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for index, expected := range []string{"main.f0", "main.f3", "main.f5", "main.f6", "main.f2", "main.f1", "main.f4"} {
+		if names[uint32(index)] != expected {
+			t.Errorf("function %d: expected name %s, got %s", index, expected, names[uint32(index)])
+		}
+	}
+}
+
+func TestReadFunctionProfile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "calls.txt")
+	err := os.WriteFile(path, []byte("# calls name\n100 main.f1\n\n3 (*main.T).String\n2 main.f1\n"), 0o666)
+	if err != nil {
+		t.Fatal(err)
+	}
+	profile, err := readFunctionProfile(path)
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+	expected := map[string]uint64{"main.f1": 102, "(*main.T).String": 3}
+	if !reflect.DeepEqual(profile, expected) {
+		t.Errorf("expected %v, got %v", expected, profile)
+	}
+
+	err = os.WriteFile(path, []byte("100 main.f1\nmain.f2\n"), 0o666)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := readFunctionProfile(path); err == nil || err.Error() != path+":2: expected a call count and a function name" {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
@@ -0,0 +1,295 @@
+package builder
+
+// This file implements a few WebAssembly post-link passes in Go, which are
+// listed in the wasm-passes target property. They do the same as the wasm-opt
+// passes of the same name, but without depending on an external binary, and
+// they report errors with the function they apply to.
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+)
+
+// wasmPass is a post-link pass. It gets the sections of a module and returns
+// the updated sections.
+type wasmPass func(sections []wasmSection) ([]wasmSection, error)
+
+// wasmPasses contains all passes that can be used in the wasm-passes target
+// property.
+var wasmPasses = map[string]wasmPass{
+	"signext-lowering":      lowerWasmSignExt,
+	"strip-target-features": stripWasmTargetFeatures,
+	"check-mvp":             checkWasmMVP,
+	"icf":                   foldIdenticalWasmFunctions,
+	"compress-data":         compressWasmData,
+}
+
+// IsWasmPass returns whether the given name is a known post-link pass.
+func IsWasmPass(name string) bool {
+	_, ok := wasmPasses[name]
+	return ok
+}
+
+// RunWasmPasses runs the given post-link passes, in order, on the
+// WebAssembly file at the given path.
+func RunWasmPasses(path string, passes []string) error {
+	return updateWasmFile(path, func(sections []wasmSection) ([]wasmSection, error) {
+		for _, name := range passes {
+			pass, ok := wasmPasses[name]
+			if !ok {
+				return nil, fmt.Errorf("unknown pass %s", name)
+			}
+			var err error
+			sections, err = pass(sections)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", name, err)
+			}
+		}
+		return sections, nil
+	})
+}
+
+// Instruction sequences that replace the sign extension instructions: a
+// shift left followed by an arithmetic shift right.
+var wasmSignExtLowering = map[uint32][]byte{
+	0xc0: {0x41, 24, 0x74, 0x41, 24, 0x75}, // i32.extend8_s
+	0xc1: {0x41, 16, 0x74, 0x41, 16, 0x75}, // i32.extend16_s
+	0xc2: {0x42, 56, 0x86, 0x42, 56, 0x87}, // i64.extend8_s
+	0xc3: {0x42, 48, 0x86, 0x42, 48, 0x87}, // i64.extend16_s
+	0xc4: {0x42, 32, 0x86, 0x42, 32, 0x87}, // i64.extend32_s
+}
+
+// lowerWasmSignExt replaces the instructions of the sign-ext feature with
+// equivalent MVP instructions, for hosts that don't support sign-ext. Usually
+// these come from libraries like wasi-libc that were built with sign-ext
+// enabled.
+//
+// Debug information (DWARF) refers to offsets in the code section, so it is
+// removed when any function is changed.
+func lowerWasmSignExt(sections []wasmSection) ([]wasmSection, error) {
+	changed := false
+	for i, section := range sections {
+		if section.id != wasmSectionCode {
+			continue
+		}
+		bodies, err := readWasmFunctionBodies(section.payload)
+		if err != nil {
+			return nil, err
+		}
+		for j, body := range bodies {
+			var code []byte
+			for offset := 0; offset < len(body.code); {
+				inst, err := decodeWasmInstruction(body.code[offset:])
+				if err != nil {
+					return nil, fmt.Errorf("%s: %w", wasmFunctionDescription(sections, j), err)
+				}
+				if replacement, ok := wasmSignExtLowering[inst.opcode]; ok {
+					if code == nil {
+						code = append(code, body.code[:offset]...)
+					}
+					code = append(code, replacement...)
+				} else if code != nil {
+					code = append(code, body.code[offset:offset+inst.size]...)
+				}
+				offset += inst.size
+			}
+			if code != nil {
+				bodies[j].code = code
+				changed = true
+			}
+		}
+		if changed {
+			sections[i].payload = appendWasmFunctionBodies(nil, bodies)
+		}
+	}
+	if !changed {
+		return sections, nil
+	}
+
+	var result []wasmSection
+	for _, section := range sections {
+		if section.id == wasmSectionCustom && strings.HasPrefix(section.name, ".debug_") {
+			continue
+		}
+		if section.id == wasmSectionCustom && section.name == "target_features" {
+			payload, err := removeWasmTargetFeature(section.payload, "sign-ext")
+			if err != nil {
+				return nil, fmt.Errorf("could not update target_features section: %w", err)
+			}
+			section.payload = payload
+		}
+		result = append(result, section)
+	}
+	return result, nil
+}
+
+// removeWasmTargetFeature removes the given feature from the payload of the
+// target_features section. This section is a vector of features, each of
+// which is a prefix byte ('+', '-' or '=') followed by the feature name.
+func removeWasmTargetFeature(data []byte, feature string) ([]byte, error) {
+	count, n, err := decodeULEB128(data)
+	if err != nil {
+		return nil, err
+	}
+	data = data[n:]
+	var features []byte
+	var numFeatures uint64
+	for i := uint64(0); i < count; i++ {
+		if len(data) == 0 {
+			return nil, errors.New("unexpected end of section")
+		}
+		prefix := data[0]
+		name, n, err := readWasmName(data[1:])
+		if err != nil {
+			return nil, err
+		}
+		data = data[1+n:]
+		if name == feature {
+			continue
+		}
+		features = appendWasmName(append(features, prefix), name)
+		numFeatures++
+	}
+	return append(appendULEB128(nil, numFeatures), features...), nil
+}
+
+// stripWasmTargetFeatures removes the target_features section, which lists
+// the features used while compiling the module. Some hosts refuse modules
+// that mention features they don't know about, even if they're not used.
+func stripWasmTargetFeatures(sections []wasmSection) ([]wasmSection, error) {
+	var result []wasmSection
+	for _, section := range sections {
+		if section.id == wasmSectionCustom && section.name == "target_features" {
+			continue
+		}
+		result = append(result, section)
+	}
+	return result, nil
+}
+
+// checkWasmMVP checks that the module only uses instructions from the
+// WebAssembly 1.0 (MVP) specification. For every feature that is used, the
+// error mentions the first function that uses it.
+func checkWasmMVP(sections []wasmSection) ([]wasmSection, error) {
+	uses, err := findWasmFeatureUses(sections)
+	if err != nil {
+		return nil, err
+	}
+	if len(uses) == 0 {
+		return sections, nil
+	}
+	return nil, wasmFeatureUsesError("module is not WebAssembly MVP", uses)
+}
+
+// checkWasmFeatures checks that the WebAssembly file at the given path doesn't
+// use any of the given features. Unlike check-mvp this isn't a pass in
+// wasm-passes: it is run for every target that disables features, because the
+// C library and compiler-rt may be prebuilt with a different set of features
+// than the Go code (see the go-helpers target property).
+func checkWasmFeatures(path string, disabled []string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	sections, err := readWasmSections(data)
+	if err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+	uses, err := findWasmFeatureUses(sections)
+	if err != nil {
+		return err
+	}
+	forbidden := make(map[string]string)
+	for _, feature := range disabled {
+		if use, ok := uses[feature]; ok {
+			forbidden[feature] = use
+		}
+	}
+	if len(forbidden) == 0 {
+		return nil
+	}
+	return wasmFeatureUsesError("module uses features disabled for the target", forbidden)
+}
+
+// disabledWasmFeatures returns the features that are disabled in the given
+// LLVM feature string, like bulk-memory in "+sign-ext,-bulk-memory". Later
+// entries override earlier ones.
+func disabledWasmFeatures(features string) []string {
+	enabled := make(map[string]bool)
+	var names []string
+	for _, feature := range strings.Split(features, ",") {
+		if len(feature) < 2 || feature[0] != '+' && feature[0] != '-' {
+			continue
+		}
+		name := feature[1:]
+		if _, ok := enabled[name]; !ok {
+			names = append(names, name)
+		}
+		enabled[name] = feature[0] == '+'
+	}
+	var disabled []string
+	for _, name := range names {
+		if !enabled[name] {
+			disabled = append(disabled, name)
+		}
+	}
+	return disabled
+}
+
+// findWasmFeatureUses returns the features beyond the MVP that the module uses,
+// with a description of the first use of each.
+func findWasmFeatureUses(sections []wasmSection) (map[string]string, error) {
+	uses := make(map[string]string) // feature -> description of the first use
+	for _, section := range sections {
+		if section.id != wasmSectionCode {
+			continue
+		}
+		bodies, err := readWasmFunctionBodies(section.payload)
+		if err != nil {
+			return nil, err
+		}
+		for i, body := range bodies {
+			for offset := 0; offset < len(body.code); {
+				inst, err := decodeWasmInstruction(body.code[offset:])
+				if err != nil {
+					return nil, fmt.Errorf("%s: %w", wasmFunctionDescription(sections, i), err)
+				}
+				if inst.feature != "" && uses[inst.feature] == "" {
+					uses[inst.feature] = fmt.Sprintf("%s, at offset %d", wasmFunctionDescription(sections, i), offset)
+				}
+				offset += inst.size
+			}
+		}
+	}
+	return uses, nil
+}
+
+// wasmFeatureUsesError returns an error that lists the given feature uses,
+// found with findWasmFeatureUses, below the message.
+func wasmFeatureUsesError(msg string, uses map[string]string) error {
+	var msgs []string
+	for feature, use := range uses {
+		msgs = append(msgs, fmt.Sprintf("uses %s in %s", feature, use))
+	}
+	sort.Strings(msgs)
+	return errors.New(msg + ":\n\t" + strings.Join(msgs, "\n\t"))
+}
+
+// wasmFunctionDescription returns a description of the function with the given
+// index in the code section, for error messages. It includes the function
+// name if the module has a name section.
+func wasmFunctionDescription(sections []wasmSection, index int) string {
+	numImports, err := countWasmFunctionImports(sections)
+	if err != nil {
+		return fmt.Sprintf("function %d in the code section", index)
+	}
+	funcIndex := numImports + uint32(index)
+	names, err := readWasmFunctionNames(sections)
+	if err != nil || names[funcIndex] == "" {
+		return fmt.Sprintf("function %d", funcIndex)
+	}
+	return fmt.Sprintf("function %d (%s)", funcIndex, demangleGoSymbol(names[funcIndex]))
+}
@@ -0,0 +1,282 @@
+package builder
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/tetratelabs/wazero"
+)
+
+func TestDecodeWasmInstruction(t *testing.T) {
+	for _, tc := range []struct {
+		code    []byte
+		size    int
+		feature string
+	}{
+		{[]byte{0x01}, 1, ""},                                     // nop
+		{[]byte{0x02, 0x40}, 2, ""},                               // block
+		{[]byte{0x02, 0x05}, 2, "multivalue"},                     // block with type index
+		{[]byte{0x0e, 2, 0, 1, 2}, 5, ""},                         // br_table
+		{[]byte{0x11, 3, 0}, 3, ""},                               // call_indirect
+		{[]byte{0x28, 2, 0x80, 0x01}, 4, ""},                      // i32.load offset=128
+		{[]byte{0x41, 0xff, 0x7f}, 3, ""},                         // i32.const -1
+		{[]byte{0x44, 0, 0, 0, 0, 0, 0, 0xf0, 0x3f}, 9, ""},       // f64.const 1
+		{[]byte{0xc0}, 1, "sign-ext"},                             // i32.extend8_s
+		{[]byte{0xfc, 0x02}, 2, "nontrapping-fptoint"},            // i32.trunc_sat_f64_s
+		{[]byte{0xfc, 0x0a, 0, 0}, 4, "bulk-memory"},              // memory.copy
+		{[]byte{0xfe, 0x10, 2, 0}, 4, "atomics"},                  // i32.atomic.load
+		{[]byte{0x12, 0x05}, 2, "tail-call"},                      // return_call
+		{[]byte{0x1c, 1, 0x7f}, 3, "reference-types"},             // select (result i32)
+		{[]byte{0x06, 0x40}, 2, "exception-handling"},             // try
+		{[]byte{0x10, 0x80, 0x80, 0x01, 0x0b}, 4, ""},             // call, followed by end
+		{[]byte{0x0c, 0x00, 0x41, 0x00, 0x1a, 0x0b, 0x0b}, 2, ""}, // br, followed by other instructions
+	} {
+		inst, err := decodeWasmInstruction(tc.code)
+		if err != nil {
+			t.Errorf("% x: unexpected error: %v", tc.code, err)
+			continue
+		}
+		if inst.size != tc.size || inst.feature != tc.feature {
+			t.Errorf("% x: expected size %d and feature %q, got size %d and feature %q", tc.code, tc.size, tc.feature, inst.size, inst.feature)
+		}
+	}
+
+	for _, code := range [][]byte{{0x41}, {0x44, 0, 0}, {0xfd, 0x0c}, {0x0a}} {
+		if _, err := decodeWasmInstruction(code); err == nil {
+			t.Errorf("% x: expected an error", code)
+		}
+	}
+}
+
+func TestWasmPasses(t *testing.T) {
+	// A module with a single function that sign-extends its parameter, with
+	// debug information and a target_features section.
+	var types []byte
+	types = appendULEB128(types, 1)
+	types = append(types, 0x60, 1, 0x7f, 1, 0x7f) // (i32) -> i32
+	code := appendWasmFunctionBodies(nil, []wasmFunctionBody{{
+		locals: []byte{0},
+		code:   []byte{0x20, 0, 0xc0, 0x0b}, // local.get 0, i32.extend8_s, end
+	}})
+	var features []byte
+	features = appendULEB128(features, 2)
+	features = appendWasmName(append(features, '+'), "mutable-globals")
+	features = appendWasmName(append(features, '+'), "sign-ext")
+	var functionNames []byte
+	functionNames = appendULEB128(functionNames, 1)
+	functionNames = appendULEB128(functionNames, 0)
+	functionNames = appendWasmName(functionNames, "main.extend")
+	names := append([]byte{wasmNameFunction}, appendULEB128(nil, uint64(len(functionNames)))...)
+	names = append(names, functionNames...)
+	module := writeWasmSections([]wasmSection{
+		{id: wasmSectionType, payload: types},
+		{id: wasmSectionFunction, payload: []byte{1, 0}},
+		{id: wasmSectionCode, payload: code},
+		{id: wasmSectionCustom, name: ".debug_info", payload: []byte{1, 2, 3}},
+		{id: wasmSectionCustom, name: "name", payload: names},
+		{id: wasmSectionCustom, name: "target_features", payload: features},
+	})
+	path := filepath.Join(t.TempDir(), "test.wasm")
+	if err := os.WriteFile(path, module, 0o666); err != nil {
+		t.Fatal(err)
+	}
+
+	err := RunWasmPasses(path, []string{"check-mvp"})
+	if err == nil || !strings.Contains(err.Error(), "uses sign-ext in function 0 (main.extend), at offset 2") {
+		t.Errorf("unexpected error from check-mvp: %v", err)
+	}
+
+	if err := RunWasmPasses(path, []string{"signext-lowering", "check-mvp"}); err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sections, err := readWasmSections(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var sectionNames []string
+	for _, section := range sections {
+		switch {
+		case section.id == wasmSectionCode:
+			bodies, err := readWasmFunctionBodies(section.payload)
+			if err != nil {
+				t.Fatal(err)
+			}
+			expected := []byte{0x20, 0, 0x41, 24, 0x74, 0x41, 24, 0x75, 0x0b}
+			if len(bodies) != 1 || !bytes.Equal(bodies[0].code, expected) {
+				t.Errorf("unexpected code after signext-lowering: % x", bodies[0].code)
+			}
+		case section.name == "target_features":
+			expected := appendWasmName(append([]byte{1}, '+'), "mutable-globals")
+			if !bytes.Equal(section.payload, expected) {
+				t.Errorf("unexpected target features: % x", section.payload)
+			}
+		}
+		sectionNames = append(sectionNames, section.name)
+	}
+	if s := strings.Join(sectionNames, ","); s != ",,,name,target_features" {
+		t.Errorf("unexpected sections after signext-lowering: %s", s)
+	}
+
+	if err := RunWasmPasses(path, []string{"strip-target-features"}); err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+	if data, _ := os.ReadFile(path); bytes.Contains(data, []byte("target_features")) {
+		t.Error("target_features section was not removed")
+	}
+}
+
+func TestCheckWasmFeatures(t *testing.T) {
+	disabled := disabledWasmFeatures("+mutable-globals,-bulk-memory,-sign-ext,+sign-ext,-nontrapping-fptoint")
+	if s := strings.Join(disabled, ","); s != "bulk-memory,nontrapping-fptoint" {
+		t.Errorf("unexpected disabled features: %s", s)
+	}
+
+	// A module with a single function that clears memory with memory.fill.
+	var types []byte
+	types = appendULEB128(types, 1)
+	types = append(types, 0x60, 2, 0x7f, 0x7f, 0) // (i32, i32) -> ()
+	code := appendWasmFunctionBodies(nil, []wasmFunctionBody{{
+		locals: []byte{0},
+		code:   []byte{0x20, 0, 0x41, 0, 0x20, 1, 0xfc, 0x0b, 0, 0x0b}, // memory.fill with local 0, 0, local 1
+	}})
+	module := writeWasmSections([]wasmSection{
+		{id: wasmSectionType, payload: types},
+		{id: wasmSectionFunction, payload: []byte{1, 0}},
+		{id: wasmSectionCode, payload: code},
+	})
+	path := filepath.Join(t.TempDir(), "test.wasm")
+	if err := os.WriteFile(path, module, 0o666); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := checkWasmFeatures(path, []string{"sign-ext"}); err != nil {
+		t.Error("unexpected error:", err)
+	}
+	err := checkWasmFeatures(path, disabled)
+	if err == nil || !strings.Contains(err.Error(), "uses bulk-memory in function 0, at offset 6") {
+		t.Errorf("unexpected error from checkWasmFeatures: %v", err)
+	}
+}
+
+func TestFoldIdenticalWasmFunctions(t *testing.T) {
+	// Functions 0, 1 and 5 are identical, and so are 2 and 3 once their calls
+	// are redirected. Function 5 is in a table, so it is kept and the other
+	// two are folded into it.
+	var types []byte
+	types = appendULEB128(types, 1)
+	types = append(types, 0x60, 1, 0x7f, 1, 0x7f)  // (i32) -> i32
+	addOne := []byte{0x20, 0, 0x41, 1, 0x6a, 0x0b} // local.get 0, i32.const 1, i32.add, end
+	bodies := []wasmFunctionBody{
+		{locals: []byte{0}, code: addOne},
+		{locals: []byte{0}, code: addOne},
+		{locals: []byte{0}, code: []byte{0x20, 0, 0x10, 0, 0x0b}},          // call 0
+		{locals: []byte{0}, code: []byte{0x20, 0, 0x10, 1, 0x0b}},          // call 1
+		{locals: []byte{0}, code: []byte{0x20, 0, 0x10, 3, 0x10, 1, 0x0b}}, // call 3, call 1
+		{locals: []byte{0}, code: addOne},
+	}
+	functions := []byte{6, 0, 0, 0, 0, 0, 0}
+	elements := []byte{1, 0, 0x41, 1, 0x0b, 1, 5} // elem (i32.const 1) func 5
+	exports := appendWasmName([]byte{1}, "main")
+	exports = append(exports, 0, 4)
+	sections := []wasmSection{
+		{id: wasmSectionType, payload: types},
+		{id: wasmSectionFunction, payload: functions},
+		{id: wasmSectionExport, payload: exports},
+		{id: wasmSectionElement, payload: elements},
+		{id: wasmSectionCode, payload: appendWasmFunctionBodies(nil, bodies)},
+		{id: wasmSectionCustom, name: ".debug_info", payload: []byte{1, 2, 3}},
+	}
+
+	sections, err := foldIdenticalWasmFunctions(sections)
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+	if len(sections) != 5 {
+		t.Errorf("expected debug information to be removed, got %d sections", len(sections))
+	}
+	bodies, err = readWasmFunctionBodies(sections[4].payload)
+	if err != nil {
+		t.Fatal(err)
+	}
+	unreachable := []byte{0x00, 0x0b}
+	for i, expected := range [][]byte{
+		unreachable,
+		unreachable,
+		{0x20, 0, 0x10, 5, 0x0b},
+		unreachable,
+		{0x20, 0, 0x10, 2, 0x10, 5, 0x0b},
+		addOne,
+	} {
+		if !bytes.Equal(bodies[i].code, expected) {
+			t.Errorf("function %d: expected % x, got % x", i, expected, bodies[i].code)
+		}
+	}
+}
+
+func TestCompressWasmData(t *testing.T) {
+	// A module with a large data segment that compresses well, partly made of
+	// pseudo-random bytes that don't, and a small segment that is left as-is.
+	var large []byte
+	for i := 0; i < 100; i++ {
+		large = append(large, "runtime error: index out of range\x00"...)
+	}
+	seed := uint32(1)
+	for i := 0; i < 500; i++ {
+		seed = seed*1103515245 + 12345
+		large = append(large, byte(seed>>16))
+	}
+	small := []byte("hello")
+	var data []byte
+	data = appendULEB128(data, 2)
+	data = append(data, 0, 0x41, 0x80, 0x08, 0x0b) // i32.const 1024
+	data = appendULEB128(data, uint64(len(large)))
+	data = append(data, large...)
+	data = append(data, 0, 0x41, 0x80, 0x80, 0x02, 0x0b) // i32.const 32768
+	data = appendULEB128(data, uint64(len(small)))
+	data = append(data, small...)
+	original := []wasmSection{
+		{id: wasmSectionType, payload: []byte{0}},
+		{id: wasmSectionFunction, payload: []byte{0}},
+		{id: wasmSectionMemory, payload: []byte{1, 0, 1}}, // 1 page
+		{id: wasmSectionCode, payload: []byte{0}},
+		{id: wasmSectionData, payload: data},
+	}
+
+	sections, err := compressWasmData(original)
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+	module := writeWasmSections(sections)
+	if size := len(writeWasmSections(original)); len(module) >= size-len(large)/2 {
+		t.Errorf("expected the module to be much smaller than %d bytes, got %d bytes", size, len(module))
+	}
+
+	ctx := context.Background()
+	r := wazero.NewRuntime(ctx)
+	defer r.Close(ctx)
+	mod, err := r.Instantiate(ctx, module)
+	if err != nil {
+		t.Fatal("could not instantiate module:", err)
+	}
+	memory := mod.Memory()
+	if memory.Size() != 2*65536 {
+		t.Errorf("expected 2 pages of memory, got %d bytes", memory.Size())
+	}
+	if buf, _ := memory.Read(1024, uint32(len(large))); !bytes.Equal(buf, large) {
+		t.Error("large data segment was not decompressed correctly")
+	}
+	if buf, _ := memory.Read(32768, uint32(len(small))); !bytes.Equal(buf, small) {
+		t.Error("small data segment was not loaded")
+	}
+	if buf, _ := memory.Read(65536, 65536); !bytes.Equal(buf, make([]byte, 65536)) {
+		t.Error("compressed data was not cleared")
+	}
+}
@@ -0,0 +1,400 @@
+package builder
+
+// This file writes compressed WebAssembly runtimes in the format in which
+// Substrate based blockchains (like Polkadot) store them on-chain: a magic
+// prefix followed by a zstd frame, see the sp-maybe-compressed-blob crate.
+//
+// The zstd encoder is a simple one. It only looks for matches (LZ77), stores
+// literals uncompressed and encodes the sequences with the predefined FSE
+// tables of the format. This results in valid frames that any zstd decoder
+// can read, without depending on cgo or an external tool.
+//
+// For more information about the zstd format, see RFC 8878:
+// https://datatracker.ietf.org/doc/html/rfc8878
+
+import (
+	"compress/gzip"
+	"fmt"
+	"math/bits"
+	"os"
+)
+
+// substrateZstdPrefix is the magic prefix of a compressed runtime.
+var substrateZstdPrefix = []byte{82, 188, 83, 118, 70, 219, 142, 5}
+
+// substrateBombLimit is the maximum size of a runtime after decompression.
+// Substrate refuses to decompress anything larger.
+const substrateBombLimit = 50 * 1024 * 1024
+
+// writeCompressedWasm writes the WebAssembly file at infile as a compressed
+// runtime to outfile.
+func writeCompressedWasm(infile, outfile string) error {
+	data, err := os.ReadFile(infile)
+	if err != nil {
+		return err
+	}
+	if len(data) > substrateBombLimit {
+		return fmt.Errorf("could not compress runtime: %d bytes is more than the limit of %d bytes", len(data), substrateBombLimit)
+	}
+	output := append([]byte{}, substrateZstdPrefix...)
+	output = append(output, compressZstd(data)...)
+	return os.WriteFile(outfile, output, 0666)
+}
+
+// writeGzipFile writes a gzip compressed copy of infile to outfile.
+func writeGzipFile(infile, outfile string) error {
+	data, err := os.ReadFile(infile)
+	if err != nil {
+		return err
+	}
+	f, err := os.Create(outfile)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	w, err := gzip.NewWriterLevel(f, gzip.BestCompression)
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(data); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	return f.Close()
+}
+
+const (
+	zstdMaxBlockSize = 128 * 1024
+	zstdMinMatch     = 3
+	zstdMaxMatch     = 65536
+	zstdMaxDistance  = 1 << 22
+	zstdMaxChain     = 32
+	zstdHashBits     = 16
+)
+
+// The predefined distributions of literal lengths, match lengths and offsets.
+var (
+	zstdLiteralLengthTable = newZstdFSETable(6, []int16{
+		4, 3, 2, 2, 2, 2, 2, 2, 2, 2, 2, 2, 2, 1, 1, 1,
+		2, 2, 2, 2, 2, 2, 2, 2, 2, 3, 2, 1, 1, 1, 1, 1,
+		-1, -1, -1, -1,
+	})
+	zstdMatchLengthTable = newZstdFSETable(6, []int16{
+		1, 4, 3, 2, 2, 2, 2, 2, 2, 1, 1, 1, 1, 1, 1, 1,
+		1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1,
+		1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, -1, -1,
+		-1, -1, -1, -1, -1,
+	})
+	zstdOffsetTable = newZstdFSETable(5, []int16{
+		1, 1, 1, 1, 1, 1, 2, 2, 2, 1, 1, 1, 1, 1, 1, 1,
+		1, 1, 1, 1, 1, 1, 1, 1, -1, -1, -1, -1, -1,
+	})
+)
+
+// Baseline and number of extra bits of each literal length and match length
+// code.
+var (
+	zstdLiteralLengthBase = []uint32{
+		0, 1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15,
+		16, 18, 20, 22, 24, 28, 32, 40, 48, 64, 128, 256, 512, 1024, 2048, 4096,
+		8192, 16384, 32768, 65536,
+	}
+	zstdLiteralLengthBits = []uint{
+		0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0,
+		1, 1, 1, 1, 2, 2, 3, 3, 4, 6, 7, 8, 9, 10, 11, 12,
+		13, 14, 15, 16,
+	}
+	zstdMatchLengthBase = []uint32{
+		3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15, 16, 17, 18,
+		19, 20, 21, 22, 23, 24, 25, 26, 27, 28, 29, 30, 31, 32, 33, 34,
+		35, 37, 39, 41, 43, 47, 51, 59, 67, 83, 99, 131, 259, 515, 1027, 2051,
+		4099, 8195, 16387, 32771, 65539,
+	}
+	zstdMatchLengthBits = []uint{
+		0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0,
+		0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0,
+		1, 1, 1, 1, 2, 2, 3, 3, 4, 4, 5, 7, 8, 9, 10, 11,
+		12, 13, 14, 15, 16,
+	}
+)
+
+// zstdFSETable is the decoding table of an FSE distribution, which is used
+// (backwards) to encode symbols.
+type zstdFSETable struct {
+	accuracyLog uint
+	states      [][]uint16 // states of each symbol
+	nbBits      []uint     // number of bits to read for the next state
+	baseline    []uint16   // baseline of the next state
+}
+
+// newZstdFSETable builds the decoding table of the given normalized
+// distribution, as described in RFC 8878 section 4.1.1.
+func newZstdFSETable(accuracyLog uint, distribution []int16) *zstdFSETable {
+	tableSize := 1 << accuracyLog
+	symbols := make([]int, tableSize)
+
+	// Symbols with a "less than 1" probability get a state at the end.
+	highThreshold := tableSize - 1
+	for symbol, count := range distribution {
+		if count == -1 {
+			symbols[highThreshold] = symbol
+			highThreshold--
+		}
+	}
+
+	// Spread the other symbols over the remaining states.
+	position := 0
+	step := tableSize>>1 + tableSize>>3 + 3
+	for symbol, count := range distribution {
+		for i := 0; i < int(count); i++ {
+			symbols[position] = symbol
+			position = (position + step) & (tableSize - 1)
+			for position > highThreshold {
+				position = (position + step) & (tableSize - 1)
+			}
+		}
+	}
+
+	table := &zstdFSETable{
+		accuracyLog: accuracyLog,
+		states:      make([][]uint16, len(distribution)),
+		nbBits:      make([]uint, tableSize),
+		baseline:    make([]uint16, tableSize),
+	}
+	next := make([]int, len(distribution))
+	for symbol, count := range distribution {
+		next[symbol] = int(count)
+		if count == -1 {
+			next[symbol] = 1
+		}
+	}
+	for state, symbol := range symbols {
+		n := next[symbol]
+		next[symbol]++
+		nbBits := accuracyLog - uint(bits.Len(uint(n))-1)
+		table.nbBits[state] = nbBits
+		table.baseline[state] = uint16(n<<nbBits - tableSize)
+		table.states[symbol] = append(table.states[symbol], uint16(state))
+	}
+	return table
+}
+
+// encode writes the bits to get from the state for the given symbol to the
+// next state, and returns that state for the symbol.
+func (t *zstdFSETable) encode(w *zstdBitWriter, symbol byte, next uint16) uint16 {
+	for _, state := range t.states[symbol] {
+		baseline := t.baseline[state]
+		if next >= baseline && uint(next-baseline) < 1<<t.nbBits[state] {
+			w.addBits(uint64(next-baseline), t.nbBits[state])
+			return state
+		}
+	}
+	panic("zstd: no state to encode symbol") // the states of a symbol cover the whole table
+}
+
+// zstdBitWriter writes the little endian bit stream of the sequences section.
+type zstdBitWriter struct {
+	buf   []byte
+	value uint64
+	nbits uint
+}
+
+func (w *zstdBitWriter) addBits(value uint64, nbits uint) {
+	w.value |= (value & (1<<nbits - 1)) << w.nbits
+	w.nbits += nbits
+	for w.nbits >= 8 {
+		w.buf = append(w.buf, byte(w.value))
+		w.value >>= 8
+		w.nbits -= 8
+	}
+}
+
+// close writes the final 1 bit, that marks the end of the bit stream.
+func (w *zstdBitWriter) close() []byte {
+	w.addBits(1, 1)
+	if w.nbits != 0 {
+		w.buf = append(w.buf, byte(w.value))
+	}
+	return w.buf
+}
+
+// zstdSequence is a number of literal bytes followed by a match.
+type zstdSequence struct {
+	literals uint32
+	match    uint32
+	distance uint32
+}
+
+// compressZstd returns a zstd frame with the compressed data.
+func compressZstd(data []byte) []byte {
+	// Frame header: a single segment, with the content size.
+	output := []byte{0x28, 0xb5, 0x2f, 0xfd}
+	switch size := len(data); {
+	case size < 256:
+		output = append(output, 0x20, byte(size))
+	case size < 65536+256:
+		output = append(output, 0x60, byte(size-256), byte((size-256)>>8))
+	default:
+		output = append(output, 0xa0, byte(size), byte(size>>8), byte(size>>16), byte(size>>24))
+	}
+
+	head := make([]int32, 1<<zstdHashBits)
+	for i := range head {
+		head[i] = -1
+	}
+	prev := make([]int32, len(data))
+	hash := func(i int) uint32 {
+		v := uint32(data[i]) | uint32(data[i+1])<<8 | uint32(data[i+2])<<16
+		return (v * 2654435761) >> (32 - zstdHashBits)
+	}
+	insert := func(i int) {
+		if i+zstdMinMatch <= len(data) {
+			h := hash(i)
+			prev[i] = head[h]
+			head[h] = int32(i)
+		}
+	}
+
+	for start := 0; ; start += zstdMaxBlockSize {
+		end := start + zstdMaxBlockSize
+		if end > len(data) {
+			end = len(data)
+		}
+
+		// Find the matches in this block. They may refer to data in previous
+		// blocks, but must not extend beyond the end of this block.
+		var sequences []zstdSequence
+		var literals []byte
+		literalStart := start
+		for i := start; i < end; {
+			bestLength, bestDistance := 0, 0
+			if i+zstdMinMatch <= end {
+				limit := end - i
+				if limit > zstdMaxMatch {
+					limit = zstdMaxMatch
+				}
+				chain := 0
+				for j := int(head[hash(i)]); j >= 0 && i-j <= zstdMaxDistance && chain < zstdMaxChain; j = int(prev[j]) {
+					length := 0
+					for length < limit && data[j+length] == data[i+length] {
+						length++
+					}
+					if length > bestLength {
+						bestLength, bestDistance = length, i-j
+						if length == limit {
+							break
+						}
+					}
+					chain++
+				}
+			}
+			if bestLength < zstdMinMatch {
+				insert(i)
+				i++
+				continue
+			}
+			sequences = append(sequences, zstdSequence{
+				literals: uint32(i - literalStart),
+				match:    uint32(bestLength),
+				distance: uint32(bestDistance),
+			})
+			literals = append(literals, data[literalStart:i]...)
+			for matchEnd := i + bestLength; i < matchEnd; i++ {
+				insert(i)
+			}
+			literalStart = i
+		}
+		literals = append(literals, data[literalStart:end]...)
+
+		// Use a raw block if compression doesn't help.
+		last := 0
+		if end == len(data) {
+			last = 1
+		}
+		block := appendZstdBlock(nil, literals, sequences)
+		if len(block) < end-start {
+			header := last | 2<<1 | len(block)<<3 // compressed block
+			output = append(output, byte(header), byte(header>>8), byte(header>>16))
+			output = append(output, block...)
+		} else {
+			header := last | (end-start)<<3 // raw block
+			output = append(output, byte(header), byte(header>>8), byte(header>>16))
+			output = append(output, data[start:end]...)
+		}
+		if last != 0 {
+			return output
+		}
+	}
+}
+
+// appendZstdBlock appends the contents of a compressed block to buf.
+func appendZstdBlock(buf, literals []byte, sequences []zstdSequence) []byte {
+	// Literals section, with raw literals.
+	switch n := len(literals); {
+	case n < 32:
+		buf = append(buf, byte(n<<3))
+	case n < 4096:
+		buf = append(buf, byte(1<<2|n<<4), byte(n>>4))
+	default:
+		buf = append(buf, byte(3<<2|n<<4), byte(n>>4), byte(n>>12))
+	}
+	buf = append(buf, literals...)
+
+	// Sequences section header, using the predefined distributions.
+	switch n := len(sequences); {
+	case n == 0:
+		return append(buf, 0)
+	case n < 128:
+		buf = append(buf, byte(n))
+	case n < 0x7f00:
+		buf = append(buf, byte(n>>8+128), byte(n))
+	default:
+		buf = append(buf, 0xff, byte(n-0x7f00), byte((n-0x7f00)>>8))
+	}
+	buf = append(buf, 0) // symbol compression modes
+
+	// Sequences are encoded in reverse, as the decoder reads the bit stream
+	// from the end.
+	llCodes := make([]byte, len(sequences))
+	mlCodes := make([]byte, len(sequences))
+	ofCodes := make([]byte, len(sequences))
+	for i, seq := range sequences {
+		llCodes[i] = zstdLengthCode(zstdLiteralLengthBase, seq.literals)
+		mlCodes[i] = zstdLengthCode(zstdMatchLengthBase, seq.match)
+		ofCodes[i] = byte(bits.Len32(seq.distance+3) - 1)
+	}
+	w := &zstdBitWriter{}
+	addExtraBits := func(i int) {
+		seq := sequences[i]
+		w.addBits(uint64(seq.literals-zstdLiteralLengthBase[llCodes[i]]), zstdLiteralLengthBits[llCodes[i]])
+		w.addBits(uint64(seq.match-zstdMatchLengthBase[mlCodes[i]]), zstdMatchLengthBits[mlCodes[i]])
+		w.addBits(uint64(seq.distance+3), uint(ofCodes[i]))
+	}
+	n := len(sequences) - 1
+	llState := zstdLiteralLengthTable.states[llCodes[n]][0]
+	mlState := zstdMatchLengthTable.states[mlCodes[n]][0]
+	ofState := zstdOffsetTable.states[ofCodes[n]][0]
+	addExtraBits(n)
+	for i := n - 1; i >= 0; i-- {
+		ofState = zstdOffsetTable.encode(w, ofCodes[i], ofState)
+		mlState = zstdMatchLengthTable.encode(w, mlCodes[i], mlState)
+		llState = zstdLiteralLengthTable.encode(w, llCodes[i], llState)
+		addExtraBits(i)
+	}
+	w.addBits(uint64(mlState), zstdMatchLengthTable.accuracyLog)
+	w.addBits(uint64(ofState), zstdOffsetTable.accuracyLog)
+	w.addBits(uint64(llState), zstdLiteralLengthTable.accuracyLog)
+	return append(buf, w.close()...)
+}
+
+// zstdLengthCode returns the literal length or match length code for the
+// given value.
+func zstdLengthCode(base []uint32, value uint32) byte {
+	code := len(base) - 1
+	for base[code] > value {
+		code--
+	}
+	return byte(code)
+}
@@ -0,0 +1,50 @@
+package builder
+
+import (
+	"bytes"
+	"os/exec"
+	"strconv"
+	"testing"
+)
+
+func TestCompressZstd(t *testing.T) {
+	zstd, err := exec.LookPath("zstd")
+	if err != nil {
+		t.Skip("zstd not found:", err)
+	}
+
+	// Inputs of different sizes, partly compressible and partly random, to
+	// cover the different header sizes and both raw and compressed blocks.
+	var text, random []byte
+	for i := 0; len(text) < 300*1024; i++ {
+		text = append(text, "func main"+strconv.Itoa(i%1000)+"() { println(\"hello\") }\n"...)
+	}
+	seed := uint32(1)
+	for i := 0; i < 200*1024; i++ {
+		seed = seed*1103515245 + 12345
+		random = append(random, byte(seed>>16))
+	}
+	for _, data := range [][]byte{
+		nil,
+		[]byte("hello"),
+		text[:1000],
+		text,
+		random,
+		append(append([]byte{}, random[:70000]...), text...),
+	} {
+		compressed := compressZstd(data)
+		cmd := exec.Command(zstd, "-d", "-c")
+		cmd.Stdin = bytes.NewReader(compressed)
+		output, err := cmd.Output()
+		if err != nil {
+			t.Errorf("%d bytes: could not decompress: %v", len(data), err)
+			continue
+		}
+		if !bytes.Equal(output, data) {
+			t.Errorf("%d bytes: decompressed data is different", len(data))
+		}
+	}
+	if compressed := compressZstd(text); len(compressed) > len(text)/4 {
+		t.Errorf("text was compressed to %d bytes, expected less than %d", len(compressed), len(text)/4)
+	}
+}
@@ -83,12 +83,57 @@ func (c *Config) BuildTags() []string {
 	for i := 1; i <= c.GoMinorVersion; i++ {
 		tags = append(tags, fmt.Sprintf("go1.%d", i))
 	}
+	tags = append(tags, "maps."+c.Maps())       // map implementation in the runtime
+	tags = append(tags, "hostlog."+c.HostLog()) // used inside the runtime/hostlog package
+	tags = append(tags, "memroutines."+c.MemRoutines())
+	for _, group := range c.GoHelpers() {
+		tags = append(tags, "gohelpers."+group) // C library functions implemented by the runtime
+	}
+	if c.Options.TraceCalls {
+		tags = append(tags, "tinygo.tracecalls") // function tracing in the runtime
+	}
+	if c.Options.ReportInit {
+		tags = append(tags, "tinygo.reportinit") // package initializer costs in the runtime
+	}
+	if c.TestConfig.CompileTestBinary {
+		tags = append(tags, "tinygo.test") // test entry point in the runtime
+	}
+	if c.Options.Cover {
+		tags = append(tags, "tinygo.cover") // coverage counters in the runtime
+	}
+	if c.Options.CrashDump {
+		tags = append(tags, "tinygo.crashdump") // crash dumps for the host
+	}
+	if c.Options.HostCallStats {
+		tags = append(tags, "tinygo.hostcallstats") // host call statistics for the host
+	}
+	if c.Options.Selfcheck {
+		tags = append(tags, "tinygo.selfcheck") // runtime self-check for the host
+	}
+	if c.Options.HeapSnapshot {
+		tags = append(tags, "tinygo.heapsnapshot") // heap snapshots for the host
+	}
+	if c.Options.GCDiff {
+		tags = append(tags, "tinygo.gcdiff") // memory statistics for the host
+	}
+	if c.Options.HostArgs {
+		tags = append(tags, "tinygo.hostargs") // os.Args and environment from the host
+	}
+	if c.Options.HostTicks {
+		tags = append(tags, "tinygo.hostticks") // time from an instruction counter of the host
+	}
+	if c.Options.HostInput {
+		tags = append(tags, "tinygo.hostinput") // standard input from the host
+	}
+	if c.TestConfig.FuzzRegexp != "" {
+		tags = append(tags, "tinygo.fuzz") // fuzzing driven by the host
+	}
 	tags = append(tags, c.Options.Tags...)
 	return tags
 }
 
 // GC returns the garbage collection strategy in use on this platform. Valid
-// values are "none", "leaking", "conservative" and "precise".
+// values are "none", "leaking", "conservative", "precise" and "extalloc".
 func (c *Config) GC() string {
 	if c.Options.GC != "" {
 		return c.Options.GC
@@ -99,11 +144,20 @@ func (c *Config) GC() string {
 	return "conservative"
 }
 
+// Maps returns the map implementation in use. Valid values are "buckets" (the
+// default) and "compact", which is smaller but slower for large maps.
+func (c *Config) Maps() string {
+	if c.Options.Maps != "" {
+		return c.Options.Maps
+	}
+	return "buckets"
+}
+
 // NeedsStackObjects returns true if the compiler should insert stack objects
 // that can be traced by the garbage collector.
 func (c *Config) NeedsStackObjects() bool {
 	switch c.GC() {
-	case "conservative", "custom", "precise":
+	case "conservative", "custom", "extalloc", "precise":
 		for _, tag := range c.BuildTags() {
 			if tag == "tinygo.wasm" {
 				return true
@@ -129,6 +183,25 @@ func (c *Config) Scheduler() string {
 	return "none"
 }
 
+// SingleThreaded returns whether the program only ever runs on a single thread
+// without interrupts, so that atomic operations can be lowered to plain loads
+// and stores. This is true for WebAssembly without the atomics feature, as all
+// schedulers run goroutines on the same thread there, and for PolkaVM.
+func (c *Config) SingleThreaded() bool {
+	if c.PolkaVM() {
+		return true
+	}
+	if !strings.HasPrefix(c.Triple(), "wasm32-") {
+		return false
+	}
+	for _, feature := range strings.Split(c.Features(), ",") {
+		if feature == "+atomics" {
+			return false
+		}
+	}
+	return true
+}
+
 // Serial returns the serial implementation for this build configuration: uart,
 // usb (meaning USB-CDC), or none.
 func (c *Config) Serial() string {
@@ -141,6 +214,67 @@ func (c *Config) Serial() string {
 	return "none"
 }
 
+// HostLog returns where the runtime/hostlog package writes log messages: print
+// (the builtin print function) or ext (the ext_logging_log host function).
+func (c *Config) HostLog() string {
+	if c.Target.HostLog != "" {
+		return c.Target.HostLog
+	}
+	return "print"
+}
+
+// StrictExportABI returns whether //export functions must only use types that
+// lower to WebAssembly numeric types, according to the strict-export-abi
+// target property.
+func (c *Config) StrictExportABI() bool {
+	return c.Target.StrictExportABI != nil && *c.Target.StrictExportABI && strings.HasPrefix(c.Triple(), "wasm32-")
+}
+
+// BoundsMessages returns whether bounds check panics include the index, length
+// and source position, according to the bounds-messages target property. Only
+// debug builds with -panic=print include them, as they add a call with extra
+// arguments and a string constant to every bounds check.
+func (c *Config) BoundsMessages() bool {
+	return c.Target.BoundsMessages != nil && *c.Target.BoundsMessages && c.Options.Debug && c.PanicStrategy() == "print" && strings.HasPrefix(c.Triple(), "wasm32-")
+}
+
+// PolkaVM returns whether the program is a PolkaVM program, which is converted
+// from an ELF file by polkatool. PolkaVM programs import and export functions
+// through metadata in the ELF file instead of through symbols.
+func (c *Config) PolkaVM() bool {
+	return c.Target.BinaryFormat == "polkavm"
+}
+
+// MemRoutines returns which memcpy, memmove and memset the program uses: libc
+// (the ones of the C library, or the compiler-rt builtins) or deterministic
+// (the ones of the runtime, which execute the same number of instructions for
+// the same length).
+//
+// The memory routines of the runtime are also used when the go-helpers target
+// property includes the mem group.
+func (c *Config) MemRoutines() string {
+	if c.Target.MemRoutines != "" {
+		return c.Target.MemRoutines
+	}
+	for _, group := range c.GoHelpers() {
+		if group == "mem" {
+			return "deterministic"
+		}
+	}
+	return "libc"
+}
+
+// GoHelpers returns the groups of C library functions that the runtime
+// implements in Go, instead of linking them from the C library: mem (memcpy,
+// memmove and memset), string (strlen, memcmp and bcmp) and math (exp, exp2
+// and log, which WebAssembly has no instructions for). This way a target can
+// use a C library that was built with features the target doesn't allow
+// (like bulk memory instructions in wasi-libc), or with implementations that
+// aren't deterministic, without building a separate copy of it.
+func (c *Config) GoHelpers() []string {
+	return c.Target.GoHelpers
+}
+
 // OptLevels returns the optimization level (0-2), size level (0-2), and inliner
 // threshold as used in the LLVM optimization pipeline.
 func (c *Config) OptLevel() (level string, speedLevel, sizeLevel int) {
@@ -169,6 +303,59 @@ func (c *Config) PanicStrategy() string {
 	return c.Options.PanicStrategy
 }
 
+// HostHashing returns the hash algorithms (like "sha256") of which the Go
+// implementation should be replaced with a call to a host function.
+func (c *Config) HostHashing() []string {
+	if c.Options.HostHashing == "" {
+		return nil
+	}
+	algorithms, _ := parseAlgorithmList("-host-hashing", c.Options.HostHashing, validHostHashingOptions) // verified in Options.Verify
+	return algorithms
+}
+
+// HostCrypto returns the signature schemes (like "ed25519") of which the Go
+// verification function should be replaced with a call to a host function.
+func (c *Config) HostCrypto() []string {
+	if c.Options.HostCrypto == "" {
+		return nil
+	}
+	algorithms, _ := parseAlgorithmList("-host-crypto", c.Options.HostCrypto, validHostCryptoOptions) // verified in Options.Verify
+	return algorithms
+}
+
+// PanicChecks returns whether runtime checks (bounds checks, nil checks, etc)
+// should be emitted for the given package. They are enabled by default but can
+// be disabled per package with the -panic-checks flag, where the last
+// matching pattern wins.
+func (c *Config) PanicChecks(pkgPath string) bool {
+	if c.Options.PanicChecks == "" {
+		return true
+	}
+	rules, _ := parsePanicChecks(c.Options.PanicChecks) // verified in Options.Verify
+	enabled := true
+	for _, rule := range rules {
+		if matchPackagePattern(rule.pattern, pkgPath) {
+			enabled = rule.enabled
+		}
+	}
+	return enabled
+}
+
+// SoftFloat returns whether floating point operations in the given package
+// should be lowered to the integer soft-float routines of compiler-rt, which is
+// the case when it matches one of the patterns of the -soft-float flag.
+func (c *Config) SoftFloat(pkgPath string) bool {
+	if c.Options.SoftFloat == "" {
+		return false
+	}
+	for _, pattern := range strings.Split(c.Options.SoftFloat, ",") {
+		if matchPackagePattern(pattern, pkgPath) {
+			return true
+		}
+	}
+	return false
+}
+
 // AutomaticStackSize returns whether goroutine stack sizes should be determined
 // automatically at compile time, if possible. If it is false, no attempt is
 // made.
@@ -237,7 +424,11 @@ func (c *Config) LibcPath(name string) (path string, precompiled bool) {
 	}
 
 	// No precompiled library found. Determine the path name that will be used
-	// in the build cache.
+	// in the build cache. Libraries built for -reproducible are built with
+	// different flags, so they're cached separately.
+	if c.Options.Reproducible {
+		archname += "-reproducible"
+	}
 	return filepath.Join(goenv.Get("GOCACHE"), name+"-"+archname), false
 }
 
@@ -328,6 +519,11 @@ func (c *Config) CFlags(libclang bool) []string {
 		// usually this will be found by developers (not by TinyGo users).
 		panic("unknown libc: " + c.Target.Libc)
 	}
+	if c.Options.FPDeterministic {
+		// Don't fuse multiplications and additions, which rounds differently
+		// depending on whether the target has a fused multiply-add.
+		cflags = append(cflags, "-ffp-contract=off")
+	}
 	// Always emit debug information. It is optionally stripped at link time.
 	cflags = append(cflags, "-gdwarf-4")
 	// Use the same optimization level as TinyGo.
@@ -351,9 +547,23 @@ func (c *Config) CFlags(libclang bool) []string {
 	if c.ABI() != "" {
 		cflags = append(cflags, "-mabi="+c.ABI())
 	}
+	cflags = append(cflags, c.FilePrefixMapFlags()...)
 	return cflags
 }
 
+// FilePrefixMapFlags returns the C compiler flags that replace the location of
+// TinyGo and of the cache directory in debug information and in __FILE__ with
+// fixed paths, for -reproducible. These locations differ between machines.
+func (c *Config) FilePrefixMapFlags() []string {
+	if !c.Options.Reproducible {
+		return nil
+	}
+	return []string{
+		"-ffile-prefix-map=" + goenv.Get("TINYGOROOT") + "=/tinygo",
+		"-ffile-prefix-map=" + goenv.Get("GOCACHE") + "=/cache",
+	}
+}
+
 // LDFlags returns the flags to pass to the linker. A few more flags are needed
 // (like the one for the compiler runtime), but this represents the majority of
 // the flags.
@@ -396,6 +606,17 @@ func (c *Config) Debug() bool {
 	return c.Options.Debug
 }
 
+// WasmNames returns what to do with the WebAssembly name section: keep it as
+// the linker wrote it, demangle the function names, strip the section, or only
+// keep the names of exported functions. The name section is independent of DWARF debug
+// information and is therefore also kept with -no-debug.
+func (c *Config) WasmNames() string {
+	if c.Options.WasmNames == "" {
+		return "keep"
+	}
+	return c.Options.WasmNames
+}
+
 // BinaryFormat returns an appropriate binary format, based on the file
 // extension and the configured binary format in the target JSON file.
 func (c *Config) BinaryFormat(ext string) string {
@@ -422,6 +643,16 @@ func (c *Config) BinaryFormat(ext string) string {
 		// More information:
 		// https://github.com/Microsoft/uf2
 		return "uf2"
+	case ".compressed.wasm":
+		// WebAssembly module with a zstd frame and the magic prefix that
+		// Substrate expects, as used for on-chain runtimes.
+		return "compressed-wasm"
+	case ".gz":
+		// Gzip compressed executable.
+		return "gzip"
+	case ".polkavm":
+		// PolkaVM program blob, converted from the ELF file by polkatool.
+		return "polkavm"
 	case ".zip":
 		if c.Target.BinaryFormat != "" {
 			return c.Target.BinaryFormat
@@ -564,4 +795,7 @@ type TestConfig struct {
 	BenchTime         string
 	BenchMem          bool
 	Shuffle           string
+	FuzzRegexp        string
+	FuzzTime          string
+	CoverProfile      string
 }
@@ -1,6 +1,7 @@
 package compileopts
 
 import (
+	"errors"
 	"fmt"
 	"regexp"
 	"strings"
@@ -8,12 +9,19 @@ import (
 )
 
 var (
-	validGCOptions            = []string{"none", "leaking", "conservative", "custom", "precise"}
+	validGCOptions            = []string{"none", "leaking", "conservative", "custom", "precise", "extalloc"}
 	validSchedulerOptions     = []string{"none", "tasks", "asyncify"}
+	validMapsOptions          = []string{"buckets", "compact"}
 	validSerialOptions        = []string{"none", "uart", "usb", "rtt"}
-	validPrintSizeOptions     = []string{"none", "short", "full"}
+	validPrintSizeOptions     = []string{"none", "short", "full", "json"}
 	validPanicStrategyOptions = []string{"print", "trap"}
 	validOptOptions           = []string{"none", "0", "1", "2", "s", "z"}
+	validWasmNamesOptions     = []string{"keep", "demangle", "strip", "exported-only"}
+	validHostHashingOptions   = []string{"blake2b", "sha256"}
+	validHostCryptoOptions    = []string{"ed25519"}
+	validLogLevelOptions      = []string{"debug", "info", "warn", "error", "off"}
+	validInterfaceGCOptions   = []string{"safe", "unsafe"}
+	validFunctionOrderOptions = []string{"source", "callgraph", "profile"}
 )
 
 // Options contains extra options to give to the compiler. These options are
@@ -29,6 +37,7 @@ type Options struct {
 	GC              string
 	PanicStrategy   string
 	Scheduler       string
+	Maps            string
 	StackSize       uint64 // goroutine stack size (if none could be automatically determined)
 	Serial          string
 	Work            bool // -work flag to print temporary build directory
@@ -41,8 +50,45 @@ type Options struct {
 	Semaphore       chan struct{}                    `json:"-"` // -p flag controls cap
 	Debug           bool
 	PrintSizes      string
+	SizeBudget      uint64         // -size-budget flag, maximum size in bytes, without custom sections
+	SizeCompare     string         // -size-compare flag, baseline binary to compare against
 	PrintAllocs     *regexp.Regexp // regexp string
+	PrintAllocsJSON bool           // -print-allocs=json
 	PrintStacks     bool
+	WhyLive         string // -why-live flag, symbol to explain
+	PrintRetained   bool
+	PrintInits      bool // -print-runtime-init flag, list package initializers that run at runtime
+	StrictInit      bool // -strict-init flag, fail if a package initializer runs at runtime
+	TraceCalls      bool
+	ReportInit      bool   // -report-init flag, measure package initializers that run at runtime (WebAssembly only)
+	GCDiff          bool   // -gc-diff flag, export memory statistics to compare GCs (WebAssembly only)
+	HostArgs        bool   // -host-args flag, read os.Args and the environment from the host (wasm-unknown only)
+	HostTicks       bool   // -host-ticks flag, read the time from an instruction counter of the host (wasm-unknown only)
+	HostInput       bool   // -host-input flag, read os.Stdin from the host (wasm-unknown only)
+	Reproducible    bool   // -reproducible flag, don't store machine-specific paths in the output
+	Cover           bool   // -cover flag, add coverage counters (WebAssembly only)
+	CoverPackages   string // -coverpkg flag, comma separated list of package patterns to cover
+	MergeFunctions  bool
+	SplitCold       bool   // -split-cold flag, move error paths to the end of the code section (WebAssembly only)
+	ICF             bool   // -icf flag, fold identical functions in the linked module (WebAssembly only)
+	CompressData    bool   // -compress-data flag, decompress large data segments at startup (WebAssembly only)
+	SectionHook     string // -section-hook flag, command that returns custom sections to add after linking (WebAssembly only)
+	DebugOutput     string // -debug-output flag, path of the debug build to write next to the stripped output (WebAssembly only)
+	CrashDump       bool   // -crash-dump flag, save the runtime state for the host when trapping (WebAssembly only)
+	HostCallStats   bool   // -host-call-stats flag, count calls and bytes passed per imported function (WebAssembly only)
+	Redzone         uint32 // -redzone flag, bytes at the bottom of the system stack that must stay unused (WebAssembly only)
+	Selfcheck       bool   // -selfcheck flag, export __runtime_selfcheck to verify the runtime state (WebAssembly only)
+	HeapSnapshot    bool   // -heap-snapshot flag, export functions to snapshot and restore the heap (-gc=extalloc only)
+	Exports         string // -exports flag, comma separated list of the only functions to export (WebAssembly only)
+	InterfaceGC     string // -interface-gc flag, which types to consider when lowering interface method calls
+	LowerFmt        bool
+	ConstantTime    bool   // -constanttime flag, report branches, indices and divisions on secret values in //go:constanttime functions
+	FPDeterministic bool   // -fp-deterministic flag, make floating point results independent of the host (WebAssembly only)
+	SoftFloat       string // -soft-float flag, comma separated list of package patterns (WebAssembly only)
+	PanicChecks     string // -panic-checks flag, comma separated list of pattern:on/off
+	HostHashing     string // -host-hashing flag, comma separated list of hash algorithms
+	HostCrypto      string // -host-crypto flag, comma separated list of signature schemes
+	LogLevel        string // -log-level flag, remove runtime/hostlog calls below this level
 	Tags            []string
 	GlobalValues    map[string]map[string]string // map[pkgpath]map[varname]value
 	TestConfig      TestConfig
@@ -53,6 +99,11 @@ type Options struct {
 	Monitor         bool
 	BaudRate        int
 	Timeout         time.Duration
+	WasmNames       string
+	YieldPoints     uint32 // -yield-points flag, loop iterations between host yields (0 to disable)
+	InlineBudget    int    // -inline-budget flag, inlining threshold of the optimizer (0 for the default of the optimization level)
+	FunctionOrder   string // -function-order flag: source, callgraph or profile
+	FunctionProfile string // -function-profile flag, call counts for -function-order=profile
 }
 
 // Verify performs a validation on the given options, raising an error if options are not valid.
@@ -75,6 +126,15 @@ func (o *Options) Verify() error {
 		}
 	}
 
+	if o.Maps != "" {
+		valid := isInArray(validMapsOptions, o.Maps)
+		if !valid {
+			return fmt.Errorf(`invalid maps option '%s': valid values are %s`,
+				o.Maps,
+				strings.Join(validMapsOptions, ", "))
+		}
+	}
+
 	if o.Serial != "" {
 		valid := isInArray(validSerialOptions, o.Serial)
 		if !valid {
@@ -108,6 +168,54 @@ func (o *Options) Verify() error {
 		}
 	}
 
+	if o.PanicChecks != "" {
+		if _, err := parsePanicChecks(o.PanicChecks); err != nil {
+			return err
+		}
+	}
+
+	if o.HostHashing != "" {
+		if _, err := parseAlgorithmList("-host-hashing", o.HostHashing, validHostHashingOptions); err != nil {
+			return err
+		}
+	}
+
+	if o.HostCrypto != "" {
+		if _, err := parseAlgorithmList("-host-crypto", o.HostCrypto, validHostCryptoOptions); err != nil {
+			return err
+		}
+	}
+
+	if o.LogLevel != "" {
+		if !isInArray(validLogLevelOptions, o.LogLevel) {
+			return fmt.Errorf("invalid -log-level=%s: valid values are %s", o.LogLevel, strings.Join(validLogLevelOptions, ", "))
+		}
+	}
+
+	if o.InterfaceGC != "" {
+		if !isInArray(validInterfaceGCOptions, o.InterfaceGC) {
+			return fmt.Errorf("invalid -interface-gc=%s: valid values are %s", o.InterfaceGC, strings.Join(validInterfaceGCOptions, ", "))
+		}
+	}
+
+	if o.WasmNames != "" {
+		if !isInArray(validWasmNamesOptions, o.WasmNames) {
+			return fmt.Errorf("invalid -names=%s: valid values are %s", o.WasmNames, strings.Join(validWasmNamesOptions, ", "))
+		}
+	}
+
+	if o.FunctionOrder != "" {
+		if !isInArray(validFunctionOrderOptions, o.FunctionOrder) {
+			return fmt.Errorf("invalid -function-order=%s: valid values are %s", o.FunctionOrder, strings.Join(validFunctionOrderOptions, ", "))
+		}
+	}
+	if o.FunctionOrder == "profile" && o.FunctionProfile == "" {
+		return errors.New("-function-order=profile needs a -function-profile file")
+	}
+	if o.FunctionProfile != "" && o.FunctionOrder != "profile" {
+		return errors.New("-function-profile can only be used with -function-order=profile")
+	}
+
 	return nil
 }
 
@@ -119,3 +227,74 @@ func isInArray(arr []string, item string) bool {
 	}
 	return false
 }
+
+// panicCheckRule is a single pattern:on/off entry of the -panic-checks flag.
+type panicCheckRule struct {
+	pattern string
+	enabled bool
+}
+
+// parsePanicChecks parses the -panic-checks flag, for example:
+//
+//	github.com/foo/codec/...:off,github.com/foo/codec/unsafe:on
+func parsePanicChecks(s string) ([]panicCheckRule, error) {
+	var rules []panicCheckRule
+	for _, entry := range strings.Split(s, ",") {
+		index := strings.LastIndexByte(entry, ':')
+		if index <= 0 {
+			return nil, fmt.Errorf("invalid -panic-checks entry '%s': expected pattern:on or pattern:off", entry)
+		}
+		pattern, value := entry[:index], entry[index+1:]
+		if value != "on" && value != "off" {
+			return nil, fmt.Errorf("invalid -panic-checks entry '%s': expected pattern:on or pattern:off", entry)
+		}
+		rules = append(rules, panicCheckRule{pattern: pattern, enabled: value == "on"})
+	}
+	return rules, nil
+}
+
+// parseAlgorithmList parses a flag like -host-hashing and returns the
+// algorithms to replace with host functions. Entries are applied in order, and
+// an entry can be prefixed with - to disable an algorithm again, for example:
+//
+//	all,-sha256
+func parseAlgorithmList(flagName, s string, valid []string) ([]string, error) {
+	var algorithms []string
+	for _, entry := range strings.Split(s, ",") {
+		name := strings.TrimPrefix(entry, "-")
+		var names []string
+		if name == "all" {
+			names = valid
+		} else if isInArray(valid, name) {
+			names = []string{name}
+		} else {
+			return nil, fmt.Errorf("invalid %s entry '%s': valid values are all, %s (optionally prefixed with -)", flagName, entry, strings.Join(valid, ", "))
+		}
+		for _, name := range names {
+			enabled := isInArray(algorithms, name)
+			if strings.HasPrefix(entry, "-") && enabled {
+				for i, algorithm := range algorithms {
+					if algorithm == name {
+						algorithms = append(algorithms[:i], algorithms[i+1:]...)
+						break
+					}
+				}
+			} else if !strings.HasPrefix(entry, "-") && !enabled {
+				algorithms = append(algorithms, name)
+			}
+		}
+	}
+	return algorithms, nil
+}
+
+// matchPackagePattern returns whether the import path matches the pattern. A
+// pattern is either a plain import path or contains "..." wildcards like the
+// patterns accepted by the go tool, where "foo/..." also matches "foo".
+func matchPackagePattern(pattern, path string) bool {
+	if strings.HasSuffix(pattern, "/...") && path == pattern[:len(pattern)-len("/...")] {
+		return true
+	}
+	re := "^" + strings.ReplaceAll(regexp.QuoteMeta(pattern), `\.\.\.`, ".*") + "$"
+	matched, _ := regexp.MatchString(re, path)
+	return matched
+}
@@ -2,6 +2,7 @@ package compileopts_test
 
 import (
 	"errors"
+	"strings"
 	"testing"
 
 	"github.com/tinygo-org/tinygo/compileopts"
@@ -9,10 +10,18 @@ import (
 
 func TestVerifyOptions(t *testing.T) {
 
-	expectedGCError := errors.New(`invalid gc option 'incorrect': valid values are none, leaking, conservative, custom, precise`)
+	expectedGCError := errors.New(`invalid gc option 'incorrect': valid values are none, leaking, conservative, custom, precise, extalloc`)
 	expectedSchedulerError := errors.New(`invalid scheduler option 'incorrect': valid values are none, tasks, asyncify`)
-	expectedPrintSizeError := errors.New(`invalid size option 'incorrect': valid values are none, short, full`)
+	expectedMapsError := errors.New(`invalid maps option 'incorrect': valid values are buckets, compact`)
+	expectedPrintSizeError := errors.New(`invalid size option 'incorrect': valid values are none, short, full, json`)
 	expectedPanicStrategyError := errors.New(`invalid panic option 'incorrect': valid values are print, trap`)
+	expectedPanicChecksError := errors.New(`invalid -panic-checks entry 'foo:maybe': expected pattern:on or pattern:off`)
+	expectedHostHashingError := errors.New(`invalid -host-hashing entry 'md5': valid values are all, blake2b, sha256 (optionally prefixed with -)`)
+	expectedHostCryptoError := errors.New(`invalid -host-crypto entry 'rsa': valid values are all, ed25519 (optionally prefixed with -)`)
+	expectedWasmNamesError := errors.New(`invalid -names=incorrect: valid values are keep, demangle, strip, exported-only`)
+	expectedInterfaceGCError := errors.New(`invalid -interface-gc=incorrect: valid values are safe, unsafe`)
+	expectedFunctionOrderError := errors.New(`invalid -function-order=incorrect: valid values are source, callgraph, profile`)
+	expectedFunctionProfileError := errors.New(`-function-order=profile needs a -function-profile file`)
 
 	testCases := []struct {
 		name          string
@@ -73,6 +82,19 @@ func TestVerifyOptions(t *testing.T) {
 				Scheduler: "tasks",
 			},
 		},
+		{
+			name: "InvalidMapsOption",
+			opts: compileopts.Options{
+				Maps: "incorrect",
+			},
+			expectedError: expectedMapsError,
+		},
+		{
+			name: "MapsOptionCompact",
+			opts: compileopts.Options{
+				Maps: "compact",
+			},
+		},
 		{
 			name: "InvalidPrintSizeOption",
 			opts: compileopts.Options{
@@ -98,6 +120,12 @@ func TestVerifyOptions(t *testing.T) {
 				PrintSizes: "full",
 			},
 		},
+		{
+			name: "PrintSizeOptionJSON",
+			opts: compileopts.Options{
+				PrintSizes: "json",
+			},
+		},
 		{
 			name: "InvalidPanicOption",
 			opts: compileopts.Options{
@@ -117,6 +145,92 @@ func TestVerifyOptions(t *testing.T) {
 				PanicStrategy: "trap",
 			},
 		},
+		{
+			name: "InvalidPanicChecksOption",
+			opts: compileopts.Options{
+				PanicChecks: "foo:maybe",
+			},
+			expectedError: expectedPanicChecksError,
+		},
+		{
+			name: "PanicChecksOption",
+			opts: compileopts.Options{
+				PanicChecks: "github.com/foo/...:off,github.com/foo/bar:on",
+			},
+		},
+		{
+			name: "InvalidHostHashingOption",
+			opts: compileopts.Options{
+				HostHashing: "sha256,md5",
+			},
+			expectedError: expectedHostHashingError,
+		},
+		{
+			name: "HostHashingOption",
+			opts: compileopts.Options{
+				HostHashing: "all,-sha256",
+			},
+		},
+		{
+			name: "InvalidHostCryptoOption",
+			opts: compileopts.Options{
+				HostCrypto: "rsa",
+			},
+			expectedError: expectedHostCryptoError,
+		},
+		{
+			name: "HostCryptoOption",
+			opts: compileopts.Options{
+				HostCrypto: "ed25519",
+			},
+		},
+		{
+			name: "InvalidWasmNamesOption",
+			opts: compileopts.Options{
+				WasmNames: "incorrect",
+			},
+			expectedError: expectedWasmNamesError,
+		},
+		{
+			name: "WasmNamesOptionExportedOnly",
+			opts: compileopts.Options{
+				WasmNames: "exported-only",
+			},
+		},
+		{
+			name: "InvalidInterfaceGCOption",
+			opts: compileopts.Options{
+				InterfaceGC: "incorrect",
+			},
+			expectedError: expectedInterfaceGCError,
+		},
+		{
+			name: "InterfaceGCOptionUnsafe",
+			opts: compileopts.Options{
+				InterfaceGC: "unsafe",
+			},
+		},
+		{
+			name: "InvalidFunctionOrderOption",
+			opts: compileopts.Options{
+				FunctionOrder: "incorrect",
+			},
+			expectedError: expectedFunctionOrderError,
+		},
+		{
+			name: "FunctionOrderOptionProfileWithoutFile",
+			opts: compileopts.Options{
+				FunctionOrder: "profile",
+			},
+			expectedError: expectedFunctionProfileError,
+		},
+		{
+			name: "FunctionOrderOptionProfile",
+			opts: compileopts.Options{
+				FunctionOrder:   "profile",
+				FunctionProfile: "calls.txt",
+			},
+		},
 	}
 
 	for _, tc := range testCases {
@@ -130,3 +244,70 @@ func TestVerifyOptions(t *testing.T) {
 		})
 	}
 }
+
+func TestPanicChecks(t *testing.T) {
+	config := &compileopts.Config{
+		Options: &compileopts.Options{
+			PanicChecks: "github.com/foo/codec/...:off,github.com/foo/codec/checked:on,encoding/binary:off",
+		},
+	}
+	for _, tc := range []struct {
+		pkgPath string
+		enabled bool
+	}{
+		{"main", true},
+		{"encoding/binary", false},
+		{"encoding/binary/foo", true},
+		{"github.com/foo/codec", false},
+		{"github.com/foo/codec/scale", false},
+		{"github.com/foo/codec/checked", true},
+		{"github.com/foo/codecs", true},
+	} {
+		if enabled := config.PanicChecks(tc.pkgPath); enabled != tc.enabled {
+			t.Errorf("PanicChecks(%q): expected %v, got %v", tc.pkgPath, tc.enabled, enabled)
+		}
+	}
+}
+
+func TestSoftFloat(t *testing.T) {
+	config := &compileopts.Config{
+		Options: &compileopts.Options{
+			SoftFloat: "github.com/foo/consensus/...,math",
+		},
+	}
+	for _, tc := range []struct {
+		pkgPath string
+		enabled bool
+	}{
+		{"main", false},
+		{"math", true},
+		{"math/bits", false},
+		{"github.com/foo/consensus", true},
+		{"github.com/foo/consensus/fixed", true},
+		{"github.com/foo/consensusx", false},
+	} {
+		if enabled := config.SoftFloat(tc.pkgPath); enabled != tc.enabled {
+			t.Errorf("SoftFloat(%q): expected %v, got %v", tc.pkgPath, tc.enabled, enabled)
+		}
+	}
+}
+
+func TestHostHashing(t *testing.T) {
+	for _, tc := range []struct {
+		option     string
+		algorithms []string
+	}{
+		{"", nil},
+		{"sha256", []string{"sha256"}},
+		{"all", []string{"blake2b", "sha256"}},
+		{"all,-sha256", []string{"blake2b"}},
+		{"sha256,-sha256,blake2b", []string{"blake2b"}},
+	} {
+		config := &compileopts.Config{
+			Options: &compileopts.Options{HostHashing: tc.option},
+		}
+		if algorithms := config.HostHashing(); strings.Join(algorithms, ",") != strings.Join(tc.algorithms, ",") {
+			t.Errorf("HostHashing(%q): expected %v, got %v", tc.option, tc.algorithms, algorithms)
+		}
+	}
+}
@@ -23,7 +23,8 @@ import (
 // https://doc.rust-lang.org/nightly/nightly-rustc/rustc_target/spec/struct.TargetOptions.html
 // https://github.com/shepmaster/rust-arduino-blink-led-no-core-with-cargo/blob/master/blink/arduino.json
 type TargetSpec struct {
-	Inherits         []string `json:"inherits,omitempty"`
+	Inherits         nameList `json:"inherits,omitempty"`
+	Replace          []string `json:"replace,omitempty"` // properties that replace the inherited value instead of appending to it
 	Triple           string   `json:"llvm-target,omitempty"`
 	CPU              string   `json:"cpu,omitempty"`
 	ABI              string   `json:"target-abi,omitempty"` // rougly equivalent to -mabi= flag
@@ -33,10 +34,13 @@ type TargetSpec struct {
 	BuildTags        []string `json:"build-tags,omitempty"`
 	GC               string   `json:"gc,omitempty"`
 	Scheduler        string   `json:"scheduler,omitempty"`
-	Serial           string   `json:"serial,omitempty"` // which serial output to use (uart, usb, none)
+	Serial           string   `json:"serial,omitempty"`  // which serial output to use (uart, usb, none)
+	HostLog          string   `json:"hostlog,omitempty"` // where runtime/hostlog writes messages (print, ext)
 	Linker           string   `json:"linker,omitempty"`
 	RTLib            string   `json:"rtlib,omitempty"` // compiler runtime library (libgcc, compiler-rt)
 	Libc             string   `json:"libc,omitempty"`
+	MemRoutines      string   `json:"mem-routines,omitempty"`         // memcpy, memmove and memset to use (libc, deterministic)
+	GoHelpers        []string `json:"go-helpers,omitempty"`           // groups of C library functions the runtime implements in Go instead (mem, string, math)
 	AutoStackSize    *bool    `json:"automatic-stack-size,omitempty"` // Determine stack size automatically at compile time.
 	DefaultStackSize uint64   `json:"default-stack-size,omitempty"`   // Default stack size if the size couldn't be determined at compile time.
 	CFlags           []string `json:"cflags,omitempty"`
@@ -62,6 +66,42 @@ type TargetSpec struct {
 	JLinkDevice      string   `json:"jlink-device,omitempty"`
 	CodeModel        string   `json:"code-model,omitempty"`
 	RelocationModel  string   `json:"relocation-model,omitempty"`
+	RequiredExports  []string `json:"required-exports,omitempty"`  // functions that must be exported, like "Core_version(i32,i32)->i64" (WebAssembly only)
+	StrictExportABI  *bool    `json:"strict-export-abi,omitempty"` // reject //export functions with types that don't lower to WebAssembly types, like strings (WebAssembly only)
+	BoundsMessages   *bool    `json:"bounds-messages,omitempty"`   // include the index, length and source position in bounds check panics of debug builds (WebAssembly only)
+	ExtallocMalloc   string   `json:"extalloc-malloc,omitempty"`   // import used as malloc by -gc=extalloc, in the form "module.name"
+	ExtallocFree     string   `json:"extalloc-free,omitempty"`     // import used as free by -gc=extalloc, in the form "module.name"
+	ExtallocLimit    uint64   `json:"extalloc-limit,omitempty"`    // maximum number of bytes -gc=extalloc may allocate from the host
+	ExtallocLimitFn  string   `json:"extalloc-limit-fn,omitempty"` // import that returns the maximum number of bytes, in the form "module.name"
+	ExtallocBucket   uint64   `json:"extalloc-bucket,omitempty"`   // smallest power-of-two bucket the external allocator rounds sizes up to, 0 if it has no buckets
+	ExtallocHeader   uint64   `json:"extalloc-header,omitempty"`   // size of the header the external allocator puts in front of every allocation
+	WasmPasses       []string `json:"wasm-passes,omitempty"`       // post-link passes that are run in-process after wasm-opt, like "signext-lowering"
+	WasmOptFlags     []string `json:"wasm-opt-flags,omitempty"`    // extra flags passed to wasm-opt, like "--signext-lowering"
+}
+
+// nameList is a list of targets in the "inherits" property. It may also be
+// written as a single string in JSON, like "inherits": "wasi".
+type nameList []string
+
+func (l *nameList) UnmarshalJSON(data []byte) error {
+	var name string
+	if err := json.Unmarshal(data, &name); err == nil {
+		*l = nameList{name}
+		return nil
+	}
+	return json.Unmarshal(data, (*[]string)(l))
+}
+
+// targetPropertyIndex returns the index of the TargetSpec field with the given
+// JSON name, or -1 if there is no such property.
+func targetPropertyIndex(name string) int {
+	specType := reflect.TypeOf(TargetSpec{})
+	for i := 0; i < specType.NumField(); i++ {
+		if strings.Split(specType.Field(i).Tag.Get("json"), ",")[0] == name {
+			return i
+		}
+	}
+	return -1
 }
 
 // overrideProperties overrides all properties that are set in child into itself using reflection.
@@ -108,11 +148,24 @@ func (spec *TargetSpec) overrideProperties(child *TargetSpec) error {
 
 // load reads a target specification from the JSON in the given io.Reader. It
 // may load more targets specified using the "inherits" property.
+//
+// Unknown properties are an error, so that a typo in a custom target file
+// doesn't silently fall back to the inherited value.
 func (spec *TargetSpec) load(r io.Reader) error {
-	err := json.NewDecoder(r).Decode(spec)
+	decoder := json.NewDecoder(r)
+	decoder.DisallowUnknownFields()
+	err := decoder.Decode(spec)
 	if err != nil {
 		return err
 	}
+	for _, name := range spec.Replace {
+		if name == "inherits" || name == "replace" {
+			return fmt.Errorf("property %s cannot be replaced", name)
+		}
+		if targetPropertyIndex(name) < 0 {
+			return fmt.Errorf("cannot replace unknown property %#v", name)
+		}
+	}
 
 	return nil
 }
@@ -122,32 +175,55 @@ func (spec *TargetSpec) load(r io.Reader) error {
 //   - a relative or absolute path to custom (project specific) target specification .json file;
 //     the Inherits[] could contain the files from target folder (ex. stm32f4disco)
 //     as well as path to custom files (ex. myAwesomeProject.json)
-func (spec *TargetSpec) loadFromGivenStr(str string) error {
+//
+// Relative paths are looked up in dir first (the directory of the inheriting
+// target file, if any), and then in the current working directory. It returns
+// the absolute path of the file that was loaded.
+func (spec *TargetSpec) loadFromGivenStr(str, dir string) (string, error) {
 	path := ""
 	if strings.HasSuffix(str, ".json") {
-		path, _ = filepath.Abs(str)
+		path = str
+		if dir != "" && !filepath.IsAbs(path) {
+			if _, err := os.Stat(filepath.Join(dir, path)); err == nil {
+				path = filepath.Join(dir, path)
+			}
+		}
+		path, _ = filepath.Abs(path)
 	} else {
 		path = filepath.Join(goenv.Get("TINYGOROOT"), "targets", strings.ToLower(str)+".json")
 	}
 	fp, err := os.Open(path)
 	if err != nil {
-		return err
+		return "", err
 	}
 	defer fp.Close()
-	return spec.load(fp)
+	err = spec.load(fp)
+	if err != nil {
+		return "", fmt.Errorf("%s: %w", path, err)
+	}
+	return path, nil
 }
 
-// resolveInherits loads inherited targets, recursively.
-func (spec *TargetSpec) resolveInherits() error {
+// resolveInherits loads inherited targets, recursively. The path is the file
+// that spec was loaded from, and parents contains the files that inherit from
+// it (directly or indirectly), to detect inheritance cycles.
+func (spec *TargetSpec) resolveInherits(path string, parents []string) error {
+	parents = append(parents, path)
+
 	// First create a new spec with all the inherited properties.
 	newSpec := &TargetSpec{}
 	for _, name := range spec.Inherits {
 		subtarget := &TargetSpec{}
-		err := subtarget.loadFromGivenStr(name)
+		subpath, err := subtarget.loadFromGivenStr(name, filepath.Dir(path))
 		if err != nil {
 			return err
 		}
-		err = subtarget.resolveInherits()
+		for _, parent := range parents {
+			if parent == subpath {
+				return fmt.Errorf("inheritance cycle: %s", strings.Join(append(parents, subpath), " -> "))
+			}
+		}
+		err = subtarget.resolveInherits(subpath, parents)
 		if err != nil {
 			return err
 		}
@@ -157,12 +233,21 @@ func (spec *TargetSpec) resolveInherits() error {
 		}
 	}
 
+	// Properties listed in "replace" are not merged with the inherited value
+	// but replace it, even when they're left empty.
+	specValue := reflect.ValueOf(newSpec).Elem()
+	for _, name := range spec.Replace {
+		field := specValue.Field(targetPropertyIndex(name))
+		field.Set(reflect.Zero(field.Type()))
+	}
+
 	// When all properties are loaded, make sure they are properly inherited.
 	err := newSpec.overrideProperties(spec)
 	if err != nil {
-		return err
+		return fmt.Errorf("%s: %w", path, err)
 	}
 	*spec = *newSpec
+	spec.Replace = nil // only applies to the file that lists it
 
 	return nil
 }
@@ -228,13 +313,13 @@ func LoadTarget(options *Options) (*TargetSpec, error) {
 	// See whether there is a target specification for this target (e.g.
 	// Arduino).
 	spec := &TargetSpec{}
-	err := spec.loadFromGivenStr(options.Target)
+	path, err := spec.loadFromGivenStr(options.Target, "")
 	if err != nil {
 		return nil, err
 	}
 	// Successfully loaded this target from a built-in .json file. Make sure
 	// it includes all parents as specified in the "inherits" key.
-	err = spec.resolveInherits()
+	err = spec.resolveInherits(path, nil)
 	if err != nil {
 		return nil, fmt.Errorf("%s : %w", options.Target, err)
 	}
@@ -3,7 +3,10 @@ package compileopts
 import (
 	"errors"
 	"io/fs"
+	"os"
+	"path/filepath"
 	"reflect"
+	"strings"
 	"testing"
 )
 
@@ -81,3 +84,139 @@ func TestOverrideProperties(t *testing.T) {
 	}
 
 }
+
+func TestLoadCustomTarget(t *testing.T) {
+	dir := t.TempDir()
+	writeTarget := func(name, json string) string {
+		t.Helper()
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(json), 0o666); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+
+	// Inherit from a built-in target and from a file next to the target file,
+	// and replace some of the inherited properties.
+	writeTarget("base.json", `{
+		"inherits": "wasi",
+		"required-exports": ["Core_version(i32,i32)->i64"],
+		"emulator": "wazero {}"
+	}`)
+	path := writeTarget("custom.json", `{
+		"inherits": ["base.json"],
+		"replace": ["required-exports", "emulator"],
+		"required-exports": ["validate_block(i32,i32)->i64"],
+		"gc": "leaking",
+		"wasm-opt-flags": ["--signext-lowering"]
+	}`)
+	spec, err := LoadTarget(&Options{Target: path})
+	if err != nil {
+		t.Fatal("could not load custom target:", err)
+	}
+	if !reflect.DeepEqual(spec.RequiredExports, []string{"validate_block(i32,i32)->i64"}) {
+		t.Errorf("unexpected required exports: %v", spec.RequiredExports)
+	}
+	if spec.Emulator != "" {
+		t.Errorf("expected the emulator to be replaced, got %#v", spec.Emulator)
+	}
+	if spec.GC != "leaking" || spec.Triple != "wasm32-unknown-wasi" {
+		t.Errorf("unexpected properties: gc=%s llvm-target=%s", spec.GC, spec.Triple)
+	}
+	if !reflect.DeepEqual(spec.WasmOptFlags, []string{"--signext-lowering"}) {
+		t.Errorf("unexpected wasm-opt flags: %v", spec.WasmOptFlags)
+	}
+
+	for _, tc := range []struct {
+		json string
+		err  string
+	}{
+		{`{"inherits": "wasi", "required-export": ["foo"]}`, `unknown field "required-export"`},
+		{`{"inherits": "wasi", "replace": ["gcc"]}`, `cannot replace unknown property "gcc"`},
+		{`{"inherits": "wasi", "build-tags": ["wasi"]}`, `duplicate value 'wasi' in field BuildTags`},
+		{`{"inherits": "invalid.json"}`, `inheritance cycle`},
+	} {
+		path := writeTarget("invalid.json", tc.json)
+		_, err := LoadTarget(&Options{Target: path})
+		if err == nil || !strings.Contains(err.Error(), tc.err) {
+			t.Errorf("%s: expected error %#v, got: %v", tc.json, tc.err, err)
+		}
+	}
+}
+
+func TestSingleThreaded(t *testing.T) {
+	for _, tc := range []struct {
+		target         string
+		singleThreaded bool
+	}{
+		{"wasm-unknown", true},
+		{"polkawasm-wasi", true},
+		{"wasm-threads", false},
+		{"cortex-m-qemu", false},
+	} {
+		spec, err := LoadTarget(&Options{Target: tc.target})
+		if err != nil {
+			t.Fatal("could not load target:", err)
+		}
+		config := &Config{Options: &Options{}, Target: spec}
+		if config.SingleThreaded() != tc.singleThreaded {
+			t.Errorf("%s: expected SingleThreaded() to be %v", tc.target, tc.singleThreaded)
+		}
+	}
+
+	// The emulator of wasm-unknown-extalloc doesn't support shared memory.
+	spec, err := LoadTarget(&Options{Target: "wasm-threads"})
+	if err != nil {
+		t.Fatal("could not load target:", err)
+	}
+	if spec.Emulator != "" || spec.GC != "extalloc" {
+		t.Errorf("unexpected properties of wasm-threads: emulator=%#v gc=%s", spec.Emulator, spec.GC)
+	}
+}
+
+func TestBoundsMessages(t *testing.T) {
+	for _, tc := range []struct {
+		target         string
+		debug          bool
+		panicStrategy  string
+		boundsMessages bool
+	}{
+		{"polkawasm", true, "print", true},
+		{"polkawasm-wasi", true, "print", true},
+		{"polkawasm", false, "print", false},
+		{"polkawasm", true, "trap", false},
+		{"wasm-unknown", true, "print", false},
+		{"wasi", true, "print", false},
+	} {
+		spec, err := LoadTarget(&Options{Target: tc.target})
+		if err != nil {
+			t.Fatal("could not load target:", err)
+		}
+		config := &Config{Options: &Options{Debug: tc.debug, PanicStrategy: tc.panicStrategy}, Target: spec}
+		if config.BoundsMessages() != tc.boundsMessages {
+			t.Errorf("%s (debug=%v, panic=%s): expected BoundsMessages() to be %v", tc.target, tc.debug, tc.panicStrategy, tc.boundsMessages)
+		}
+	}
+}
+
+func TestPolkawasm(t *testing.T) {
+	// The polkawasm target doesn't link a C library: the runtime implements
+	// everything it would provide.
+	spec, err := LoadTarget(&Options{Target: "polkawasm"})
+	if err != nil {
+		t.Fatal("could not load target:", err)
+	}
+	if spec.Libc != "" {
+		t.Errorf("unexpected C library: %s", spec.Libc)
+	}
+	config := &Config{Options: &Options{}, Target: spec}
+	if config.MemRoutines() != "deterministic" {
+		t.Errorf("unexpected mem-routines: %s", config.MemRoutines())
+	}
+	tags := strings.Join(config.BuildTags(), " ")
+	for _, tag := range []string{"polkawasm", "gohelpers.mem", "gohelpers.string", "gohelpers.math", "gc.extalloc"} {
+		if !strings.Contains(" "+tags+" ", " "+tag+" ") {
+			t.Errorf("missing build tag %s in: %s", tag, tags)
+		}
+	}
+}
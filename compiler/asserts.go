@@ -5,6 +5,7 @@ package compiler
 
 import (
 	"fmt"
+	"go/constant"
 	"go/token"
 	"go/types"
 
@@ -16,7 +17,7 @@ import (
 // slice. This is required by the Go language spec: an index out of bounds must
 // cause a panic.
 // The caller should make sure that index is at least as big as arrayLen.
-func (b *builder) createLookupBoundsCheck(arrayLen, index llvm.Value) {
+func (b *builder) createLookupBoundsCheck(arrayLen, index llvm.Value, pos token.Pos) {
 	if b.info.nobounds {
 		// The //go:nobounds pragma was added to the function to avoid bounds
 		// checking.
@@ -31,6 +32,17 @@ func (b *builder) createLookupBoundsCheck(arrayLen, index llvm.Value) {
 
 	// Now do the bounds check: index >= arrayLen
 	outOfBounds := b.CreateICmp(llvm.IntUGE, index, arrayLen, "")
+	if b.BoundsMessages {
+		// Tell which index was out of range, like the gc toolchain does.
+		b.createRuntimeAssertArgs(outOfBounds, "lookup", "lookupPanicDetails", func() []llvm.Value {
+			return []llvm.Value{
+				b.createInt64(index, true),
+				b.createInt64(arrayLen, false),
+				b.createPosString(pos),
+			}
+		})
+		return
+	}
 	b.createRuntimeAssert(outOfBounds, "lookup", "lookupPanic")
 }
 
@@ -40,8 +52,10 @@ func (b *builder) createLookupBoundsCheck(arrayLen, index llvm.Value) {
 // This function is both used for slicing a slice (low and high have their
 // normal meaning) and for creating a new slice, where 'capacity' means the
 // biggest possible slice capacity, 'low' means len and 'high' means cap. The
-// logic is the same in both cases.
-func (b *builder) createSliceBoundsCheck(capacity, low, high, max llvm.Value, lowType, highType, maxType *types.Basic) {
+// logic is the same in both cases. The position is only used in the panic
+// message with BoundsMessages, and should be token.NoPos when creating a new
+// slice.
+func (b *builder) createSliceBoundsCheck(capacity, low, high, max llvm.Value, lowType, highType, maxType *types.Basic, pos token.Pos) {
 	if b.info.nobounds {
 		// The //go:nobounds pragma was added to the function to avoid bounds
 		// checking.
@@ -74,6 +88,19 @@ func (b *builder) createSliceBoundsCheck(capacity, low, high, max llvm.Value, lo
 	outOfBounds3 := b.CreateICmp(llvm.IntUGT, max, capacity, "slice.maxcap")
 	outOfBounds := b.CreateOr(outOfBounds1, outOfBounds2, "slice.lowmax")
 	outOfBounds = b.CreateOr(outOfBounds, outOfBounds3, "slice.lowcap")
+	if b.BoundsMessages && pos.IsValid() {
+		// Tell which bounds were out of range, like the gc toolchain does.
+		b.createRuntimeAssertArgs(outOfBounds, "slice", "slicePanicDetails", func() []llvm.Value {
+			return []llvm.Value{
+				b.createInt64(low, true),
+				b.createInt64(high, true),
+				b.createInt64(max, true),
+				b.createInt64(capacity, false),
+				b.createPosString(pos),
+			}
+		})
+		return
+	}
 	b.createRuntimeAssert(outOfBounds, "slice", "slicePanic")
 }
 
@@ -166,6 +193,10 @@ func (b *builder) createNilCheck(inst ssa.Value, ptr llvm.Value, blockPrefix str
 	if !ptr.IsAGlobalValue().IsNil() {
 		return
 	}
+	if b.NoPanicChecks {
+		// Disabled for this package with -panic-checks.
+		return
+	}
 
 	switch inst := inst.(type) {
 	case *ssa.Alloc:
@@ -231,6 +262,14 @@ func (b *builder) createDivideByZeroCheck(y llvm.Value) {
 // createRuntimeAssert is a common function to create a new branch on an assert
 // bool, calling an assert func if the assert value is true (1).
 func (b *builder) createRuntimeAssert(assert llvm.Value, blockPrefix, assertFunc string) {
+	b.createRuntimeAssertArgs(assert, blockPrefix, assertFunc, nil)
+}
+
+// createRuntimeAssertArgs is like createRuntimeAssert, but passes the values
+// returned by getArgs to the assert func. getArgs is called while inserting
+// into the fault block, so that any instructions it creates only run when the
+// assert triggers.
+func (b *builder) createRuntimeAssertArgs(assert llvm.Value, blockPrefix, assertFunc string, getArgs func() []llvm.Value) {
 	// Check whether we can resolve this check at compile time.
 	if !assert.IsAConstantInt().IsNil() {
 		val := assert.ZExtValue()
@@ -252,7 +291,11 @@ func (b *builder) createRuntimeAssert(assert llvm.Value, blockPrefix, assertFunc
 
 	// Fail: the assert triggered so panic.
 	b.SetInsertPointAtEnd(faultBlock)
-	b.createRuntimeCall(assertFunc, nil, "")
+	var args []llvm.Value
+	if getArgs != nil {
+		args = getArgs()
+	}
+	b.createRuntimeCall(assertFunc, args, "")
 	b.CreateUnreachable()
 
 	// Ok: assert didn't trigger so continue normally.
@@ -274,3 +317,30 @@ func (b *builder) extendInteger(value llvm.Value, valueType types.Type, targetTy
 	}
 	return value
 }
+
+// createInt64 converts an integer of at most 64 bits to an int64, for use in a
+// panic message.
+func (b *builder) createInt64(value llvm.Value, signed bool) llvm.Value {
+	i64Type := b.ctx.Int64Type()
+	switch {
+	case value.Type().IntTypeWidth() == 64:
+		return value
+	case signed:
+		return b.CreateSExt(value, i64Type, "")
+	default:
+		return b.CreateZExt(value, i64Type, "")
+	}
+}
+
+// createPosString returns a string constant with the given source position,
+// like "main.go:12:5", for use in a panic message. The file name is trimmed
+// like in the debug information.
+func (b *builder) createPosString(pos token.Pos) llvm.Value {
+	s := ""
+	if pos.IsValid() {
+		position := b.program.Fset.Position(pos)
+		position.Filename = TrimPath(b.TrimPaths, position.Filename)
+		s = position.String()
+	}
+	return b.createConst(ssa.NewConst(constant.MakeString(s), types.Typ[types.String]), pos)
+}
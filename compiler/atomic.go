@@ -10,11 +10,21 @@ import (
 // createAtomicOp lowers a sync/atomic function by lowering it as an LLVM atomic
 // operation. It returns the result of the operation, or a zero llvm.Value if
 // the result is void.
+//
+// In single-threaded programs, the operations are lowered to plain loads and
+// stores instead: nothing can happen in between, so they're atomic anyway.
+// This avoids instructions of the WebAssembly atomics feature (or libcalls
+// emulating them) and lets LLVM optimize them like any other memory access.
 func (b *builder) createAtomicOp(name string) llvm.Value {
 	switch name {
 	case "AddInt32", "AddInt64", "AddUint32", "AddUint64", "AddUintptr":
 		ptr := b.getValue(b.fn.Params[0], getPos(b.fn))
 		val := b.getValue(b.fn.Params[1], getPos(b.fn))
+		if b.SingleThreaded {
+			newVal := b.CreateAdd(b.CreateLoad(val.Type(), ptr, ""), val, "")
+			b.CreateStore(newVal, ptr)
+			return newVal
+		}
 		if strings.HasPrefix(b.Triple, "avr") {
 			// AtomicRMW does not work on AVR as intended:
 			// - There are some register allocation issues (fixed by https://reviews.llvm.org/D97127 which is not yet in a usable LLVM release)
@@ -35,18 +45,33 @@ func (b *builder) createAtomicOp(name string) llvm.Value {
 	case "SwapInt32", "SwapInt64", "SwapUint32", "SwapUint64", "SwapUintptr", "SwapPointer":
 		ptr := b.getValue(b.fn.Params[0], getPos(b.fn))
 		val := b.getValue(b.fn.Params[1], getPos(b.fn))
+		if b.SingleThreaded {
+			oldVal := b.CreateLoad(val.Type(), ptr, "")
+			b.CreateStore(val, ptr)
+			return oldVal
+		}
 		oldVal := b.CreateAtomicRMW(llvm.AtomicRMWBinOpXchg, ptr, val, llvm.AtomicOrderingSequentiallyConsistent, true)
 		return oldVal
 	case "CompareAndSwapInt32", "CompareAndSwapInt64", "CompareAndSwapUint32", "CompareAndSwapUint64", "CompareAndSwapUintptr", "CompareAndSwapPointer":
 		ptr := b.getValue(b.fn.Params[0], getPos(b.fn))
 		old := b.getValue(b.fn.Params[1], getPos(b.fn))
 		newVal := b.getValue(b.fn.Params[2], getPos(b.fn))
+		if b.SingleThreaded {
+			// Store the value that is in memory afterwards, to avoid a branch.
+			oldVal := b.CreateLoad(old.Type(), ptr, "")
+			swapped := b.CreateICmp(llvm.IntEQ, oldVal, old, "")
+			b.CreateStore(b.CreateSelect(swapped, newVal, oldVal, ""), ptr)
+			return swapped
+		}
 		tuple := b.CreateAtomicCmpXchg(ptr, old, newVal, llvm.AtomicOrderingSequentiallyConsistent, llvm.AtomicOrderingSequentiallyConsistent, true)
 		swapped := b.CreateExtractValue(tuple, 1, "")
 		return swapped
 	case "LoadInt32", "LoadInt64", "LoadUint32", "LoadUint64", "LoadUintptr", "LoadPointer":
 		ptr := b.getValue(b.fn.Params[0], getPos(b.fn))
 		val := b.CreateLoad(b.getLLVMType(b.fn.Signature.Results().At(0).Type()), ptr, "")
+		if b.SingleThreaded {
+			return val
+		}
 		val.SetOrdering(llvm.AtomicOrderingSequentiallyConsistent)
 		val.SetAlignment(b.targetData.PrefTypeAlignment(val.Type())) // required
 		return val
@@ -54,6 +79,9 @@ func (b *builder) createAtomicOp(name string) llvm.Value {
 		ptr := b.getValue(b.fn.Params[0], getPos(b.fn))
 		val := b.getValue(b.fn.Params[1], getPos(b.fn))
 		store := b.CreateStore(val, ptr)
+		if b.SingleThreaded {
+			return llvm.Value{}
+		}
 		store.SetOrdering(llvm.AtomicOrderingSequentiallyConsistent)
 		store.SetAlignment(b.targetData.PrefTypeAlignment(val.Type())) // required
 		return llvm.Value{}
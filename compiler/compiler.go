@@ -55,7 +55,21 @@ type Config struct {
 	DefaultStackSize   uint64
 	MaxStackAlloc      uint64
 	NeedsStackObjects  bool
+	SingleThreaded     bool // Lower sync/atomic operations to plain loads and stores.
 	Debug              bool // Whether to emit debug information in the LLVM module.
+	NoPanicChecks      bool // Omit bounds checks, nil checks etc in this package (-panic-checks).
+	BoundsMessages     bool // Include the index, length and source position in bounds check panics.
+	LowerFmt           bool // Lower simple fmt.Sprintf and fmt.Errorf calls (-lower-fmt).
+	ConstantTime       bool // Check //go:constanttime functions for timing that depends on secrets (-constanttime).
+	FPDeterministic    bool // Don't use LLVM intrinsics that may be lowered to libm calls (-fp-deterministic).
+	SoftFloat          bool // Don't use any floating point LLVM intrinsics in this package (-soft-float).
+	StrictExportABI    bool // Reject //export functions with types that don't lower to WebAssembly types.
+	PolkaVM            bool // Mark PolkaVM imports and exports (see transform.AddPolkaVMMetadata).
+	ForceInline        bool // Always inline //go:inline functions instead of only hinting (-inline-budget).
+
+	// Directories to replace in file names in the debug information, like
+	// the -trimpath flag of the go tool. Used for -reproducible.
+	TrimPaths map[string]string
 }
 
 // compilerContext contains function-independent data that should still be
@@ -790,7 +804,7 @@ func (c *compilerContext) attachDebugInfoRaw(f *ssa.Function, llvmFn llvm.Value,
 // one.
 func (c *compilerContext) getDIFile(filename string) llvm.Metadata {
 	if _, ok := c.difiles[filename]; !ok {
-		dir, file := filepath.Split(filename)
+		dir, file := filepath.Split(TrimPath(c.TrimPaths, filename))
 		if dir != "" {
 			dir = dir[:len(dir)-1]
 		}
@@ -799,6 +813,29 @@ func (c *compilerContext) getDIFile(filename string) llvm.Metadata {
 	return c.difiles[filename]
 }
 
+// TrimPath replaces the longest directory in trimPaths that contains the given
+// path (or is equal to it) with its replacement, see Config.TrimPaths.
+func TrimPath(trimPaths map[string]string, path string) string {
+	bestDir := ""
+	for dir := range trimPaths {
+		if len(dir) > len(bestDir) && (path == dir || strings.HasPrefix(path, dir+string(filepath.Separator))) {
+			bestDir = dir
+		}
+	}
+	if bestDir == "" {
+		return path
+	}
+	replacement := trimPaths[bestDir]
+	if path == bestDir {
+		return replacement
+	}
+	rel := filepath.ToSlash(path[len(bestDir)+1:])
+	if replacement == "" {
+		return rel
+	}
+	return replacement + "/" + rel
+}
+
 // createPackage builds the LLVM IR for all types, methods, and global variables
 // in the given package.
 func (c *compilerContext) createPackage(irbuilder llvm.Builder, pkg *ssa.Package) {
@@ -830,7 +867,7 @@ func (c *compilerContext) createPackage(irbuilder llvm.Builder, pkg *ssa.Package
 			}
 			// Create the function definition.
 			b := newBuilder(c, irbuilder, member)
-			if _, ok := mathToLLVMMapping[member.RelString(nil)]; ok {
+			if _, ok := mathToLLVMMapping[member.RelString(nil)]; ok && !c.SoftFloat && !(c.FPDeterministic && mathLibmOps[member.RelString(nil)]) {
 				// The body of this function (if there is one) is ignored and
 				// replaced with a LLVM intrinsic call.
 				b.defineMathOp()
@@ -1151,18 +1188,38 @@ func (b *builder) createFunctionStart(intrinsic bool) {
 		// module). But LTO generally optimizes such functions away. Therefore,
 		// exported functions must be explicitly marked as used.
 		llvmutil.AppendToGlobal(b.mod, "llvm.used", b.llvmFn)
+	} else if b.info.exported && b.PolkaVM && b.fn.Pkg.Pkg.Path() != "runtime" {
+		// Functions exported outside the runtime are exported from the
+		// PolkaVM program. The runtime only exports functions for C code, like
+		// malloc.
+		functionAttr := b.ctx.CreateStringAttribute("polkavm-export-name", b.info.linkName)
+		b.llvmFn.AddFunctionAttr(functionAttr)
+		llvmutil.AppendToGlobal(b.mod, "llvm.used", b.llvmFn)
 	}
 
 	// Some functions have a pragma controlling the inlining level.
 	switch b.info.inline {
 	case inlineHint:
-		// Add LLVM inline hint to functions with //go:inline pragma.
-		inline := b.ctx.CreateEnumAttribute(llvm.AttributeKindID("inlinehint"), 0)
+		// Add LLVM inline hint to functions with //go:inline pragma. The
+		// LLVM inliner often ignores the hint at -Oz, so with an explicit
+		// inlining threshold (-inline-budget) force it instead.
+		kind := "inlinehint"
+		if b.ForceInline {
+			kind = "alwaysinline"
+		}
+		inline := b.ctx.CreateEnumAttribute(llvm.AttributeKindID(kind), 0)
 		b.llvmFn.AddFunctionAttr(inline)
 	case inlineNone:
 		// Add LLVM attribute to always avoid inlining this function.
 		noinline := b.ctx.CreateEnumAttribute(llvm.AttributeKindID("noinline"), 0)
 		b.llvmFn.AddFunctionAttr(noinline)
+	case inlineOutline:
+		// Never inline this function and optimize it for size. The string
+		// attribute marks it for transform.MergeOutlinedFunctions.
+		for _, kind := range []string{"noinline", "minsize", "optsize"} {
+			b.llvmFn.AddFunctionAttr(b.ctx.CreateEnumAttribute(llvm.AttributeKindID(kind), 0))
+		}
+		b.llvmFn.AddFunctionAttr(b.ctx.CreateStringAttribute("tinygo-outline", ""))
 	}
 
 	if b.info.interrupt {
@@ -1294,6 +1351,10 @@ func (b *builder) createFunctionStart(intrinsic bool) {
 func (b *builder) createFunction() {
 	b.createFunctionStart(false)
 
+	if b.ConstantTime && b.info.constTime {
+		b.checkConstantTime()
+	}
+
 	// Fill blocks with instructions.
 	for _, block := range b.fn.DomPreorder() {
 		if b.DumpSSA {
@@ -1444,6 +1505,10 @@ func (b *builder) createInstruction(instr ssa.Instruction) {
 
 	switch instr := instr.(type) {
 	case ssa.Value:
+		if isInnerStringConcat(instr) {
+			// Created as part of the outer concatenation.
+			return
+		}
 		if value, err := b.createExpr(instr); err != nil {
 			// This expression could not be parsed. Add the error to the list
 			// of diagnostics and continue with an undef value.
@@ -1857,6 +1922,10 @@ func (b *builder) createFunctionCall(instr *ssa.CallCommon) (llvm.Value, error)
 			return llvm.ConstInt(b.ctx.Int1Type(), supportsRecover, false), nil
 		case name == "runtime/interrupt.New":
 			return b.createInterruptGlobal(instr)
+		case (name == "fmt.Sprintf" || name == "fmt.Errorf") && b.LowerFmt:
+			if result, ok := b.createLoweredFmtCall(instr, name); ok {
+				return result, nil
+			}
 		}
 
 		calleeType, callee = b.getFunction(fn)
@@ -2018,6 +2087,9 @@ func (b *builder) createExpr(expr ssa.Value) (llvm.Value, error) {
 			return buf, nil
 		}
 	case *ssa.BinOp:
+		if isStringConcat(expr) {
+			return b.createStringConcatChain(expr), nil
+		}
 		x := b.getValue(expr.X, getPos(expr))
 		y := b.getValue(expr.Y, getPos(expr))
 		return b.createBinOp(expr.Op, expr.X.Type(), expr.Y.Type(), x, y, expr.Pos())
@@ -2111,7 +2183,7 @@ func (b *builder) createExpr(expr ssa.Value) (llvm.Value, error) {
 
 			// Bounds check.
 			length := b.CreateExtractValue(collection, 1, "len")
-			b.createLookupBoundsCheck(length, index)
+			b.createLookupBoundsCheck(length, index, expr.Pos())
 
 			// Lookup byte
 			buf := b.CreateExtractValue(collection, 0, "")
@@ -2125,7 +2197,7 @@ func (b *builder) createExpr(expr ssa.Value) (llvm.Value, error) {
 
 			// Check bounds.
 			arrayLen := llvm.ConstInt(b.uintptrType, uint64(xType.Len()), false)
-			b.createLookupBoundsCheck(arrayLen, index)
+			b.createLookupBoundsCheck(arrayLen, index, expr.Pos())
 
 			// Can't load directly from array (as index is non-constant), so
 			// have to do it using an alloca+gep+load.
@@ -2178,7 +2250,7 @@ func (b *builder) createExpr(expr ssa.Value) (llvm.Value, error) {
 		index = b.extendInteger(index, expr.Index.Type(), b.uintptrType)
 
 		// Bounds check.
-		b.createLookupBoundsCheck(buflen, index)
+		b.createLookupBoundsCheck(buflen, index, expr.Pos())
 
 		switch expr.X.Type().Underlying().(type) {
 		case *types.Pointer:
@@ -2228,7 +2300,7 @@ func (b *builder) createExpr(expr ssa.Value) (llvm.Value, error) {
 		lenType := expr.Len.Type().Underlying().(*types.Basic)
 		capType := expr.Cap.Type().Underlying().(*types.Basic)
 		maxSizeValue := llvm.ConstInt(b.uintptrType, maxSize, false)
-		b.createSliceBoundsCheck(maxSizeValue, sliceLen, sliceCap, sliceCap, lenType, capType, capType)
+		b.createSliceBoundsCheck(maxSizeValue, sliceLen, sliceCap, sliceCap, lenType, capType, capType, token.NoPos)
 
 		// Allocate the backing array.
 		sliceCapCast, err := b.createConvert(expr.Cap.Type(), types.Typ[types.Uintptr], sliceCap, expr.Pos())
@@ -2337,7 +2409,7 @@ func (b *builder) createExpr(expr ssa.Value) (llvm.Value, error) {
 			}
 
 			b.createNilCheck(expr.X, value, "slice")
-			b.createSliceBoundsCheck(llvmLen, low, high, max, lowType, highType, maxType)
+			b.createSliceBoundsCheck(llvmLen, low, high, max, lowType, highType, maxType, expr.Pos())
 
 			// Truncate ints bigger than uintptr. This is after the bounds
 			// check so it's safe.
@@ -2377,7 +2449,7 @@ func (b *builder) createExpr(expr ssa.Value) (llvm.Value, error) {
 				max = oldCap
 			}
 
-			b.createSliceBoundsCheck(oldCap, low, high, max, lowType, highType, maxType)
+			b.createSliceBoundsCheck(oldCap, low, high, max, lowType, highType, maxType, expr.Pos())
 
 			// Truncate ints bigger than uintptr. This is after the bounds
 			// check so it's safe.
@@ -2421,7 +2493,7 @@ func (b *builder) createExpr(expr ssa.Value) (llvm.Value, error) {
 				high = oldLen
 			}
 
-			b.createSliceBoundsCheck(oldLen, low, high, high, lowType, highType, maxType)
+			b.createSliceBoundsCheck(oldLen, low, high, high, lowType, highType, maxType, expr.Pos())
 
 			// Truncate ints bigger than uintptr. This is after the bounds
 			// check so it's safe.
@@ -179,10 +179,32 @@ func filterIrrelevantIRLines(lines []string) []string {
 
 func TestCompilerErrors(t *testing.T) {
 	t.Parallel()
+	testCompilerErrors(t, &compileopts.Options{
+		Target: "wasm",
+	}, "errors.go")
+}
+
+func TestStrictExportABI(t *testing.T) {
+	t.Parallel()
+	testCompilerErrors(t, &compileopts.Options{
+		Target: "polkawasm",
+	}, "exportabi.go")
+}
+
+func TestConstantTime(t *testing.T) {
+	t.Parallel()
+	testCompilerErrors(t, &compileopts.Options{
+		Target:       "wasm",
+		ConstantTime: true,
+	}, "constanttime.go")
+}
 
+// testCompilerErrors compiles the given file and checks that the compiler
+// reports exactly the errors listed in it, in "// ERROR: " comments.
+func testCompilerErrors(t *testing.T, options *compileopts.Options, file string) {
 	// Read expected errors from the test file.
 	var expectedErrors []string
-	errorsFile, err := os.ReadFile("testdata/errors.go")
+	errorsFile, err := os.ReadFile("testdata/" + file)
 	if err != nil {
 		t.Error(err)
 	}
@@ -194,17 +216,14 @@ func TestCompilerErrors(t *testing.T) {
 	}
 
 	// Compile the Go file with errors.
-	options := &compileopts.Options{
-		Target: "wasm",
-	}
-	_, errs := testCompilePackage(t, options, "errors.go")
+	_, errs := testCompilePackage(t, options, file)
 
 	// Check whether the actual errors match the expected errors.
 	expectedErrorsIdx := 0
 	for _, err := range errs {
 		err := err.(types.Error)
 		position := err.Fset.Position(err.Pos)
-		position.Filename = "errors.go" // don't use a full path
+		position.Filename = file // don't use a full path
 		if expectedErrorsIdx >= len(expectedErrors) || expectedErrors[expectedErrorsIdx] != err.Msg {
 			t.Errorf("unexpected compiler error: %s: %s", position.String(), err.Msg)
 			continue
@@ -235,6 +254,10 @@ func testCompilePackage(t *testing.T, options *compileopts.Options, file string)
 		AutomaticStackSize: config.AutomaticStackSize(),
 		DefaultStackSize:   config.StackSize(),
 		NeedsStackObjects:  config.NeedsStackObjects(),
+		SingleThreaded:     config.SingleThreaded(),
+		ConstantTime:       options.ConstantTime,
+		FPDeterministic:    options.FPDeterministic,
+		StrictExportABI:    config.StrictExportABI(),
 	}
 	machine, err := NewTargetMachine(compilerConfig)
 	if err != nil {
@@ -259,3 +282,26 @@ func testCompilePackage(t *testing.T, options *compileopts.Options, file string)
 	pkg := lprogram.MainPkg()
 	return CompilePackage(file, pkg, program.Package(pkg.Pkg), machine, compilerConfig, false)
 }
+
+func TestTrimPath(t *testing.T) {
+	trimPaths := map[string]string{
+		"/usr/lib/go/src":                      "",
+		"/home/user/project":                   "example.com/project",
+		"/home/user/project/vendor/other@v1.0": "other@v1.0",
+	}
+	for _, tc := range []struct {
+		path     string
+		expected string
+	}{
+		{"/usr/lib/go/src/runtime/proc.go", "runtime/proc.go"},
+		{"/home/user/project/main.go", "example.com/project/main.go"},
+		{"/home/user/project/vendor/other@v1.0/x/x.go", "other@v1.0/x/x.go"},
+		{"/home/user/project", "example.com/project"},
+		{"/home/user/projects/main.go", "/home/user/projects/main.go"},
+		{"/tmp/main.go", "/tmp/main.go"},
+	} {
+		if actual := TrimPath(trimPaths, tc.path); actual != tc.expected {
+			t.Errorf("TrimPath(%q): expected %q, got %q", tc.path, tc.expected, actual)
+		}
+	}
+}
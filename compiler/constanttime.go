@@ -0,0 +1,133 @@
+package compiler
+
+// This file implements the -constanttime flag, which checks that functions
+// annotated with //go:constanttime don't leak secret values through timing.
+// Crypto code that runs inside a WebAssembly module (instead of in the host)
+// must not branch on secret values, index tables with them, or divide them, as
+// the time such operations take depends on the value.
+
+import (
+	"go/token"
+
+	"golang.org/x/tools/go/ssa"
+)
+
+// checkConstantTime reports an error for every operation in the current
+// function of which the timing may depend on a secret value. See
+// findConstantTimeIssues.
+func (b *builder) checkConstantTime() {
+	findConstantTimeIssues(b.fn, func(pos token.Pos, msg string) {
+		if !pos.IsValid() {
+			pos = b.fn.Pos()
+		}
+		b.addError(pos, msg)
+	})
+}
+
+// findConstantTimeIssues calls report for every branch, index and division in
+// fn that depends on a secret value, except divisions of a secret value by a
+// constant. All parameters are secret, as are all values computed from them
+// and all values loaded from memory they point to. The lengths and capacities
+// of slices and strings are not secret, so that loops over the parameters are
+// allowed.
+//
+// This is a simple analysis of a single function: calls to other functions
+// are not checked (they should be annotated themselves), and memory written
+// through pointers other than local variables is not tracked.
+func findConstantTimeIssues(fn *ssa.Function, report func(pos token.Pos, msg string)) {
+	secret := make(map[ssa.Value]bool)
+	for _, param := range fn.Params {
+		secret[param] = true
+	}
+	isSecret := func(operands []*ssa.Value) bool {
+		for _, operand := range operands {
+			if operand != nil && *operand != nil && secret[*operand] {
+				return true
+			}
+		}
+		return false
+	}
+
+	// Propagate secrets until nothing changes, as values may be used before
+	// they're defined (through phi nodes) and memory may be read before it's
+	// written (in loops).
+	for changed := true; changed; {
+		changed = false
+		for _, block := range fn.Blocks {
+			for _, instr := range block.Instrs {
+				if store, ok := instr.(*ssa.Store); ok {
+					// Storing a secret value makes the variable it's stored
+					// in secret, including all other fields and elements.
+					root := constantTimeRoot(store.Addr)
+					if secret[store.Val] && !secret[root] {
+						secret[root] = true
+						changed = true
+					}
+					continue
+				}
+				value, ok := instr.(ssa.Value)
+				if !ok || secret[value] {
+					continue
+				}
+				if call, ok := instr.(*ssa.Call); ok {
+					if builtin, ok := call.Call.Value.(*ssa.Builtin); ok && (builtin.Name() == "len" || builtin.Name() == "cap") {
+						continue
+					}
+				}
+				if isSecret(instr.Operands(nil)) {
+					secret[value] = true
+					changed = true
+				}
+			}
+		}
+	}
+
+	reported := make(map[token.Pos]bool)
+	check := func(pos token.Pos, value ssa.Value, msg string) {
+		if secret[value] && !reported[pos] {
+			reported[pos] = true
+			report(pos, msg)
+		}
+	}
+	for _, block := range fn.Blocks {
+		for _, instr := range block.Instrs {
+			switch instr := instr.(type) {
+			case *ssa.If:
+				check(instr.Cond.Pos(), instr.Cond, "constant time: branch on secret value")
+			case *ssa.IndexAddr:
+				check(instr.Pos(), instr.Index, "constant time: index depends on secret value")
+			case *ssa.Index:
+				check(instr.Pos(), instr.Index, "constant time: index depends on secret value")
+			case *ssa.Lookup:
+				check(instr.Pos(), instr.Index, "constant time: lookup depends on secret value")
+			case *ssa.MapUpdate:
+				check(instr.Pos(), instr.Key, "constant time: lookup depends on secret value")
+			case *ssa.BinOp:
+				if instr.Op != token.QUO && instr.Op != token.REM {
+					continue
+				}
+				if _, ok := instr.Y.(*ssa.Const); !ok {
+					// Division by a constant is turned into a multiplication,
+					// which doesn't depend on the value.
+					check(instr.Pos(), instr.X, "constant time: division of secret value")
+				}
+				check(instr.Pos(), instr.Y, "constant time: division by secret value")
+			}
+		}
+	}
+}
+
+// constantTimeRoot returns the variable that addr points into, by following
+// field and element addresses.
+func constantTimeRoot(addr ssa.Value) ssa.Value {
+	for {
+		switch value := addr.(type) {
+		case *ssa.FieldAddr:
+			addr = value.X
+		case *ssa.IndexAddr:
+			addr = value.X
+		default:
+			return addr
+		}
+	}
+}
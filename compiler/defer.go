@@ -194,6 +194,11 @@ sw a2, 4(a1)
 li a0, 0
 1:`
 		constraints = "={a0},{a1},~{a1},~{a2},~{a3},~{a4},~{a5},~{a6},~{a7},~{s0},~{s1},~{s2},~{s3},~{s4},~{s5},~{s6},~{s7},~{s8},~{s9},~{s10},~{s11},~{t0},~{t1},~{t2},~{t3},~{t4},~{t5},~{t6},~{ra},~{f0},~{f1},~{f2},~{f3},~{f4},~{f5},~{f6},~{f7},~{f8},~{f9},~{f10},~{f11},~{f12},~{f13},~{f14},~{f15},~{f16},~{f17},~{f18},~{f19},~{f20},~{f21},~{f22},~{f23},~{f24},~{f25},~{f26},~{f27},~{f28},~{f29},~{f30},~{f31},~{memory}"
+		if b.ABI == "ilp32e" {
+			// RV32E (used by PolkaVM) only has the registers x0-x15, and no
+			// floating point registers.
+			constraints = "={a0},{a1},~{a1},~{a2},~{a3},~{a4},~{a5},~{s0},~{s1},~{t0},~{t1},~{t2},~{ra},~{memory}"
+		}
 	default:
 		// This case should have been handled by b.supportsRecover().
 		b.addError(b.fn.Pos(), "unknown architecture for defer: "+b.archFamily())
@@ -0,0 +1,227 @@
+package compiler
+
+// This file lowers simple fmt.Sprintf and fmt.Errorf calls to string
+// concatenation (-lower-fmt). This avoids pulling in the reflect-based fmt
+// formatting code when a program only uses fmt for simple formatting.
+
+import (
+	"go/constant"
+	"go/types"
+
+	"golang.org/x/tools/go/ssa"
+	"tinygo.org/x/go-llvm"
+)
+
+// fmtPiece is a part of a format string: either a literal string or a verb
+// that formats the next argument.
+type fmtPiece struct {
+	literal string
+	verb    byte // 0 for literals
+}
+
+// parseSimpleFormat splits a format string into literal strings and verbs. It
+// returns false if the format string contains anything other than %s, %d, %v,
+// %t, %x and %% without flags, width or precision.
+func parseSimpleFormat(format string) ([]fmtPiece, bool) {
+	var pieces []fmtPiece
+	literal := ""
+	for i := 0; i < len(format); i++ {
+		if format[i] != '%' {
+			literal += format[i : i+1]
+			continue
+		}
+		i++
+		if i == len(format) {
+			return nil, false
+		}
+		switch format[i] {
+		case '%':
+			literal += "%"
+		case 's', 'd', 'v', 't', 'x':
+			if literal != "" {
+				pieces = append(pieces, fmtPiece{literal: literal})
+				literal = ""
+			}
+			pieces = append(pieces, fmtPiece{verb: format[i]})
+		default:
+			return nil, false
+		}
+	}
+	if literal != "" {
+		pieces = append(pieces, fmtPiece{literal: literal})
+	}
+	return pieces, true
+}
+
+// getVariadicArgs returns the values stored in the ...interface{} slice of a
+// variadic call, before they were converted to an interface. It returns false
+// if these can't be determined, for example because a slice was passed
+// directly.
+func getVariadicArgs(slice ssa.Value) ([]ssa.Value, bool) {
+	if c, ok := slice.(*ssa.Const); ok && c.IsNil() {
+		return nil, true // no arguments
+	}
+	s, ok := slice.(*ssa.Slice)
+	if !ok || len(*s.Referrers()) != 1 {
+		return nil, false
+	}
+	alloc, ok := s.X.(*ssa.Alloc)
+	if !ok {
+		return nil, false
+	}
+	args := make([]ssa.Value, alloc.Type().(*types.Pointer).Elem().(*types.Array).Len())
+	for _, ref := range *alloc.Referrers() {
+		switch ref := ref.(type) {
+		case *ssa.Slice:
+			if ref != s {
+				return nil, false
+			}
+		case *ssa.IndexAddr:
+			index, ok := ref.Index.(*ssa.Const)
+			if !ok || len(*ref.Referrers()) != 1 {
+				return nil, false
+			}
+			store, ok := (*ref.Referrers())[0].(*ssa.Store)
+			if !ok || store.Addr != ref {
+				return nil, false
+			}
+			itf, ok := store.Val.(*ssa.MakeInterface)
+			if !ok {
+				return nil, false
+			}
+			i := index.Int64()
+			if i < 0 || i >= int64(len(args)) || args[i] != nil {
+				return nil, false
+			}
+			args[i] = itf.X
+		default:
+			return nil, false
+		}
+	}
+	for _, arg := range args {
+		if arg == nil {
+			return nil, false
+		}
+	}
+	return args, true
+}
+
+// createLoweredFmtCall tries to lower a call to fmt.Sprintf or fmt.Errorf to
+// string concatenation. It returns false if the call can't be lowered, in which
+// case nothing has been emitted.
+func (b *builder) createLoweredFmtCall(instr *ssa.CallCommon, name string) (llvm.Value, bool) {
+	format, ok := instr.Args[0].(*ssa.Const)
+	if !ok || format.Value == nil {
+		return llvm.Value{}, false
+	}
+	pieces, ok := parseSimpleFormat(constant.StringVal(format.Value))
+	if !ok {
+		return llvm.Value{}, false
+	}
+	args, ok := getVariadicArgs(instr.Args[1])
+	if !ok {
+		return llvm.Value{}, false
+	}
+
+	// Check that all arguments can be formatted without fmt.
+	numVerbs := 0
+	for _, piece := range pieces {
+		if piece.verb == 0 {
+			continue
+		}
+		if numVerbs >= len(args) || !canLowerFmtVerb(piece.verb, args[numVerbs].Type()) {
+			return llvm.Value{}, false
+		}
+		numVerbs++
+	}
+	if numVerbs != len(args) {
+		return llvm.Value{}, false
+	}
+
+	// Create the resulting string.
+	var parts []llvm.Value
+	argIndex := 0
+	for _, piece := range pieces {
+		var str llvm.Value
+		if piece.verb == 0 {
+			str = b.createConst(ssa.NewConst(constant.MakeString(piece.literal), types.Typ[types.String]), instr.Pos())
+		} else {
+			arg := args[argIndex]
+			argIndex++
+			str = b.createFmtArg(piece.verb, b.getValue(arg, getPos(arg)), arg.Type().Underlying().(*types.Basic))
+		}
+		parts = append(parts, str)
+	}
+	var result llvm.Value
+	if len(parts) == 0 {
+		result = b.createConst(ssa.NewConst(constant.MakeString(""), types.Typ[types.String]), instr.Pos())
+	} else {
+		result = b.createStringConcat(parts)
+	}
+
+	if name == "fmt.Errorf" {
+		// Without %w, fmt.Errorf is the same as errors.New(fmt.Sprintf(...)).
+		result = b.createPackageCall("errors", "New", []llvm.Value{result})
+	}
+	return result, true
+}
+
+// canLowerFmtVerb returns whether a value of the given type can be formatted
+// using the given verb without using fmt.
+func canLowerFmtVerb(verb byte, typ types.Type) bool {
+	basic, ok := typ.Underlying().(*types.Basic)
+	if !ok {
+		return false
+	}
+	if types.NewMethodSet(typ).Len() != 0 {
+		// The type might implement fmt.Stringer, error, or fmt.Formatter.
+		return false
+	}
+	info := basic.Info()
+	switch verb {
+	case 's':
+		return info&types.IsString != 0
+	case 'd', 'x':
+		return info&types.IsInteger != 0
+	case 't':
+		return info&types.IsBoolean != 0
+	case 'v':
+		return info&(types.IsString|types.IsInteger|types.IsBoolean) != 0
+	}
+	return false
+}
+
+// createFmtArg formats a single value for createLoweredFmtCall.
+func (b *builder) createFmtArg(verb byte, value llvm.Value, typ *types.Basic) llvm.Value {
+	info := typ.Info()
+	switch {
+	case info&types.IsString != 0:
+		return value
+	case info&types.IsBoolean != 0:
+		return b.createPackageCall("strconv", "FormatBool", []llvm.Value{value})
+	}
+	base := uint64(10)
+	if verb == 'x' {
+		base = 16
+	}
+	i64 := b.ctx.Int64Type()
+	if info&types.IsUnsigned != 0 {
+		if value.Type().IntTypeWidth() < 64 {
+			value = b.CreateZExt(value, i64, "")
+		}
+		return b.createPackageCall("strconv", "FormatUint", []llvm.Value{value, llvm.ConstInt(b.intType, base, false)})
+	}
+	if value.Type().IntTypeWidth() < 64 {
+		value = b.CreateSExt(value, i64, "")
+	}
+	return b.createPackageCall("strconv", "FormatInt", []llvm.Value{value, llvm.ConstInt(b.intType, base, false)})
+}
+
+// createPackageCall creates a call to a function in a package that is part of
+// the program, like createRuntimeCall does for the runtime.
+func (b *builder) createPackageCall(pkgPath, fnName string, args []llvm.Value) llvm.Value {
+	fn := b.program.ImportedPackage(pkgPath).Members[fnName].(*ssa.Function)
+	fnType, llvmFn := b.getFunction(fn)
+	args = append(args, llvm.Undef(b.dataPtrType)) // unused context parameter
+	return b.createInvoke(fnType, llvmFn, args, "")
+}
@@ -0,0 +1,29 @@
+package compiler
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseSimpleFormat(t *testing.T) {
+	for _, tc := range []struct {
+		format string
+		pieces []fmtPiece
+		ok     bool
+	}{
+		{"", nil, true},
+		{"hello", []fmtPiece{{literal: "hello"}}, true},
+		{"n=%d", []fmtPiece{{literal: "n="}, {verb: 'd'}}, true},
+		{"%s: %v%%", []fmtPiece{{verb: 's'}, {literal: ": "}, {verb: 'v'}, {literal: "%"}}, true},
+		{"%x%t", []fmtPiece{{verb: 'x'}, {verb: 't'}}, true},
+		{"%5d", nil, false},
+		{"%w", nil, false},
+		{"%q", nil, false},
+		{"trailing %", nil, false},
+	} {
+		pieces, ok := parseSimpleFormat(tc.format)
+		if ok != tc.ok || !reflect.DeepEqual(pieces, tc.pieces) {
+			t.Errorf("parseSimpleFormat(%q): expected %v %v, got %v %v", tc.format, tc.pieces, tc.ok, pieces, ok)
+		}
+	}
+}
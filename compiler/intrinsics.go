@@ -121,6 +121,16 @@ var mathToLLVMMapping = map[string]string{
 	"math.Trunc": "llvm.trunc.f64",
 }
 
+// mathLibmOps are the operations of mathToLLVMMapping that have no
+// instruction on most targets: LLVM lowers them to libm calls, which may round
+// differently depending on the C library. With -fp-deterministic, the Go
+// implementations in the math package are used instead.
+var mathLibmOps = map[string]bool{
+	"math.Exp":  true,
+	"math.Exp2": true,
+	"math.Log":  true,
+}
+
 // defineMathOp defines a math function body as a call to a LLVM intrinsic,
 // instead of the regular Go implementation. This allows LLVM to reason about
 // the math operation and (depending on the architecture) allows it to lower the
@@ -156,9 +166,11 @@ func (b *builder) defineMathOp() {
 
 // Implement most math/bits functions.
 //
-// This implements all the functions that operate on bits. It does not yet
-// implement the arithmetic functions (like bits.Add), which also have LLVM
-// intrinsics.
+// This implements all the functions that operate on bits, and the arithmetic
+// functions bits.Add, bits.Sub and bits.Mul (which are implemented using an
+// integer twice the size). The arithmetic functions are heavily used by
+// fixed-width big integer code like math/u256, and the Go implementations are
+// much harder for LLVM to optimize.
 func (b *builder) defineMathBitsIntrinsic() bool {
 	if b.fn.Pkg.Pkg.Path() != "math/bits" {
 		return false
@@ -264,6 +276,43 @@ func (b *builder) defineMathBitsIntrinsic() bool {
 		result := b.createCall(llvmFnType, llvmFn, []llvm.Value{x, x, k}, "")
 		b.CreateRet(result)
 		return true
+	case "Add", "Add32", "Add64", "Sub", "Sub32", "Sub64", "Mul", "Mul32", "Mul64":
+		b.createFunctionStart(true)
+		x := b.getValue(b.fn.Params[0], b.fn.Pos())
+		y := b.getValue(b.fn.Params[1], b.fn.Pos())
+		valueType := x.Type()
+		bits := valueType.IntTypeWidth()
+		wideType := b.ctx.IntType(bits * 2)
+		x = b.CreateZExt(x, wideType, "")
+		y = b.CreateZExt(y, wideType, "")
+		var result, first, second llvm.Value
+		switch {
+		case strings.HasPrefix(name, "Add"):
+			// sum, carryOut = x + y + carry
+			carry := b.CreateZExt(b.getValue(b.fn.Params[2], b.fn.Pos()), wideType, "")
+			result = b.CreateAdd(b.CreateAdd(x, y, ""), carry, "")
+			first = b.CreateTrunc(result, valueType, "sum")
+			second = b.CreateTrunc(b.CreateLShr(result, llvm.ConstInt(wideType, uint64(bits), false), ""), valueType, "carry")
+		case strings.HasPrefix(name, "Sub"):
+			// diff, borrowOut = x - y - borrow
+			// The result is negative (and thus has the top bit set) exactly
+			// when there is a borrow.
+			borrow := b.CreateZExt(b.getValue(b.fn.Params[2], b.fn.Pos()), wideType, "")
+			result = b.CreateSub(b.CreateSub(x, y, ""), borrow, "")
+			first = b.CreateTrunc(result, valueType, "diff")
+			second = b.CreateTrunc(b.CreateLShr(result, llvm.ConstInt(wideType, uint64(bits*2-1), false), ""), valueType, "borrow")
+		default: // Mul
+			// hi, lo = x * y
+			result = b.CreateMul(x, y, "")
+			first = b.CreateTrunc(b.CreateLShr(result, llvm.ConstInt(wideType, uint64(bits), false), ""), valueType, "hi")
+			second = b.CreateTrunc(result, valueType, "lo")
+		}
+		retType := b.getLLVMType(b.fn.Signature.Results())
+		retval := llvm.Undef(retType)
+		retval = b.CreateInsertValue(retval, first, 0, "")
+		retval = b.CreateInsertValue(retval, second, 1, "")
+		b.CreateRet(retval)
+		return true
 	default:
 		return false
 	}
@@ -0,0 +1,87 @@
+package compiler
+
+// This file lowers chains of string concatenations (like a + b + c) to a single
+// call to runtime.stringConcatN, which allocates the resulting string once
+// instead of allocating every intermediate string.
+
+import (
+	"go/token"
+	"go/types"
+
+	"golang.org/x/tools/go/ssa"
+	"tinygo.org/x/go-llvm"
+)
+
+// isStringConcat returns whether the value is a string concatenation (x + y).
+func isStringConcat(v ssa.Value) bool {
+	binop, ok := v.(*ssa.BinOp)
+	if !ok || binop.Op != token.ADD {
+		return false
+	}
+	basic, ok := binop.Type().Underlying().(*types.Basic)
+	return ok && basic.Info()&types.IsString != 0
+}
+
+// isInnerStringConcat returns whether the value is a string concatenation that
+// is only used as an operand of another string concatenation. It isn't created
+// on its own, but as part of the chain of the outer concatenation.
+func isInnerStringConcat(v ssa.Value) bool {
+	if !isStringConcat(v) {
+		return false
+	}
+	referrers := *v.Referrers()
+	if len(referrers) != 1 {
+		return false
+	}
+	parent, ok := referrers[0].(ssa.Value)
+	return ok && isStringConcat(parent)
+}
+
+// createStringConcatChain creates the outermost string concatenation of a
+// chain, including all inner concatenations (see isInnerStringConcat).
+func (b *builder) createStringConcatChain(expr *ssa.BinOp) llvm.Value {
+	var parts []llvm.Value
+	var collect func(v ssa.Value)
+	collect = func(v ssa.Value) {
+		if isInnerStringConcat(v) {
+			binop := v.(*ssa.BinOp)
+			collect(binop.X)
+			collect(binop.Y)
+			return
+		}
+		parts = append(parts, b.getValue(v, getPos(expr)))
+	}
+	collect(expr.X)
+	collect(expr.Y)
+	return b.createStringConcat(parts)
+}
+
+// createStringConcat concatenates all the given strings. Two strings are
+// concatenated with runtime.stringConcat, more strings are stored in an array
+// on the stack and concatenated with a single call to runtime.stringConcatN.
+func (b *builder) createStringConcat(parts []llvm.Value) llvm.Value {
+	switch len(parts) {
+	case 1:
+		return parts[0]
+	case 2:
+		return b.createRuntimeCall("stringConcat", parts, "")
+	}
+
+	partsType := llvm.ArrayType(b.getLLVMRuntimeType("_string"), len(parts))
+	partsAlloca, partsSize := b.createTemporaryAlloca(partsType, "concat.parts.alloca")
+	for i, part := range parts {
+		gep := b.CreateGEP(partsType, partsAlloca, []llvm.Value{
+			llvm.ConstInt(b.ctx.Int32Type(), 0, false),
+			llvm.ConstInt(b.ctx.Int32Type(), uint64(i), false),
+		}, "")
+		b.CreateStore(part, gep)
+	}
+	partsPtr := b.CreateGEP(partsType, partsAlloca, []llvm.Value{
+		llvm.ConstInt(b.ctx.Int32Type(), 0, false),
+		llvm.ConstInt(b.ctx.Int32Type(), 0, false),
+	}, "concat.parts")
+	partsLen := llvm.ConstInt(b.uintptrType, uint64(len(parts)), false)
+	result := b.createRuntimeCall("stringConcatN", []llvm.Value{partsPtr, partsLen}, "")
+	b.emitLifetimeEnd(partsAlloca, partsSize)
+	return result
+}
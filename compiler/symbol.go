@@ -32,6 +32,8 @@ type functionInfo struct {
 	nobounds   bool       // go:nobounds
 	variadic   bool       // go:variadic (CGo only)
 	inline     inlineType // go:inline
+	initEval   bool       // go:compiletimeinit
+	constTime  bool       // go:constanttime
 }
 
 type inlineType int
@@ -45,12 +47,18 @@ const (
 
 	// Inline hint, just like the C inline keyword (signalled using
 	// //go:inline). The compiler will be more likely to inline this function,
-	// but it is not a guarantee.
+	// but it is not a guarantee. On WebAssembly, where the inliner is tuned
+	// for native targets, the function is always inlined instead.
 	inlineHint
 
 	// Don't inline, just like the GCC noinline attribute. Signalled using
 	// //go:noinline.
 	inlineNone
+
+	// Don't inline and optimize for size, for cold code. Identical functions
+	// with this pragma are merged after optimization. Signalled using
+	// //go:outline.
+	inlineOutline
 )
 
 // Values for the allockind attribute. Source:
@@ -162,6 +170,10 @@ func (c *compilerContext) getFunction(fn *ssa.Function) (llvm.Type, llvm.Value)
 		// be modified.
 		llvmFn.AddAttributeAtIndex(2, c.ctx.CreateEnumAttribute(llvm.AttributeKindID("nocapture"), 0))
 		llvmFn.AddAttributeAtIndex(2, c.ctx.CreateEnumAttribute(llvm.AttributeKindID("readonly"), 0))
+	case "runtime.stringConcatN":
+		// Concatenating strings will only read the array of strings.
+		llvmFn.AddAttributeAtIndex(1, c.ctx.CreateEnumAttribute(llvm.AttributeKindID("nocapture"), 0))
+		llvmFn.AddAttributeAtIndex(1, c.ctx.CreateEnumAttribute(llvm.AttributeKindID("readonly"), 0))
 	case "runtime.sliceCopy":
 		// Copying a slice won't capture any of the parameters.
 		llvmFn.AddAttributeAtIndex(1, c.ctx.CreateEnumAttribute(llvm.AttributeKindID("writeonly"), 0))
@@ -189,6 +201,13 @@ func (c *compilerContext) getFunction(fn *ssa.Function) (llvm.Type, llvm.Value)
 		}
 	}
 
+	// Mark the package initializer if it must be evaluated at compile time. The
+	// interp package checks for this attribute.
+	if info.initEval {
+		_, initFn := c.getFunction(fn.Pkg.Func("init"))
+		initFn.AddFunctionAttr(c.ctx.CreateStringAttribute("tinygo-compiletime-init", ""))
+	}
+
 	// External/exported functions may not retain pointer values.
 	// https://golang.org/cmd/cgo/#hdr-Passing_pointers
 	if info.exported {
@@ -201,6 +220,12 @@ func (c *compilerContext) getFunction(fn *ssa.Function) (llvm.Type, llvm.Value)
 
 			llvmFn.AddFunctionAttr(c.ctx.CreateStringAttribute("wasm-import-name", info.wasmName))
 		}
+		if c.PolkaVM && len(fn.Blocks) == 0 && info.wasmModule != "" {
+			// Functions imported with //go:wasmimport are imported from the
+			// PolkaVM host. The import module isn't used. Other declarations
+			// are C functions.
+			llvmFn.AddFunctionAttr(c.ctx.CreateStringAttribute("polkavm-import-name", info.wasmName))
+		}
 		nocaptureKind := llvm.AttributeKindID("nocapture")
 		nocapture := c.ctx.CreateEnumAttribute(nocaptureKind, 0)
 		for i, typ := range paramTypes {
@@ -239,6 +264,11 @@ func (c *compilerContext) getFunctionInfo(f *ssa.Function) functionInfo {
 		// Pick the default linkName.
 		linkName: f.RelString(nil),
 	}
+	if c.NoPanicChecks {
+		// Runtime checks were disabled for this package, which has the same
+		// effect as adding //go:nobounds to every function.
+		info.nobounds = true
+	}
 	// Check for //go: pragmas, which may change the link name (among others).
 	c.parsePragmas(&info, f)
 	c.functionInfos[f] = info
@@ -272,6 +302,9 @@ func (c *compilerContext) parsePragmas(info *functionInfo, f *ssa.Function) {
 				info.linkName = parts[1]
 				info.wasmName = info.linkName
 				info.exported = true
+				if c.StrictExportABI && decl.Body != nil {
+					c.checkWasmABI(f, comment.Text)
+				}
 			case "//go:interrupt":
 				if hasUnsafeImport(f.Pkg.Pkg) {
 					info.interrupt = true
@@ -294,10 +327,24 @@ func (c *compilerContext) parsePragmas(info *functionInfo, f *ssa.Function) {
 				info.exported = true
 				info.wasmModule = parts[1]
 				info.wasmName = parts[2]
+			case "//go:wasmexport":
+				// Export a function from the WebAssembly module under the
+				// given name, like //export but with the signature checks of
+				// //go:wasmimport. This is the same directive as in Go 1.24.
+				if len(parts) != 2 {
+					c.addError(f.Pos(), fmt.Sprintf("%s: expected one WebAssembly export name", comment.Text))
+					continue
+				}
+				c.checkWasmExport(f, comment.Text)
+				info.exported = true
+				info.linkName = parts[1]
+				info.wasmName = parts[1]
 			case "//go:inline":
 				info.inline = inlineHint
 			case "//go:noinline":
 				info.inline = inlineNone
+			case "//go:outline":
+				info.inline = inlineOutline
 			case "//go:linkname":
 				if len(parts) != 3 || parts[1] != f.Name() {
 					continue
@@ -325,6 +372,17 @@ func (c *compilerContext) parsePragmas(info *functionInfo, f *ssa.Function) {
 				if hasUnsafeImport(f.Pkg.Pkg) {
 					info.nobounds = true
 				}
+			case "//go:compiletimeinit":
+				// The package initializer must be evaluated at compile time.
+				// Only valid on init functions, as it applies to the package
+				// as a whole.
+				if strings.HasPrefix(f.Name(), "init#") {
+					info.initEval = true
+				}
+			case "//go:constanttime":
+				// The function handles secret values, so its timing must
+				// not depend on them. Checked with -constanttime.
+				info.constTime = true
 			case "//go:variadic":
 				// The //go:variadic pragma is emitted by the CGo preprocessing
 				// pass for C variadic functions. This includes both explicit
@@ -356,6 +414,31 @@ func (c *compilerContext) checkWasmImport(f *ssa.Function, pragma string) {
 		c.addError(f.Pos(), fmt.Sprintf("can only use //go:wasmimport on declarations"))
 		return
 	}
+	c.checkWasmSignature(f, pragma)
+}
+
+// Check whether this function cannot be used in //go:wasmexport. It will add an
+// error if this is the case. The same types are allowed as for
+// //go:wasmimport.
+func (c *compilerContext) checkWasmExport(f *ssa.Function, pragma string) {
+	if f.Blocks == nil {
+		c.addError(f.Pos(), fmt.Sprintf("can only use //go:wasmexport on definitions"))
+		return
+	}
+	if f.Signature.Recv() != nil {
+		c.addError(f.Pos(), fmt.Sprintf("%s: cannot export a method", pragma))
+		return
+	}
+	if f.TypeParams().Len() != 0 {
+		c.addError(f.Pos(), fmt.Sprintf("%s: cannot export a generic function", pragma))
+		return
+	}
+	c.checkWasmSignature(f, pragma)
+}
+
+// checkWasmSignature checks the parameter and result types of a function with
+// a //go:wasmimport or //go:wasmexport pragma.
+func (c *compilerContext) checkWasmSignature(f *ssa.Function, pragma string) {
 	if f.Signature.Results().Len() > 1 {
 		c.addError(f.Signature.Results().At(1).Pos(), fmt.Sprintf("%s: too many return values", pragma))
 	} else if f.Signature.Results().Len() == 1 {
@@ -392,6 +475,67 @@ func isValidWasmType(typ types.Type, isReturn bool) bool {
 	return false
 }
 
+// Check whether the signature of a function exported using the legacy //export
+// pragma lowers to plain WebAssembly numeric types, on targets with the
+// strict-export-abi property. This is less strict than //go:wasmimport
+// (integers, uintptr, bool and pointers are all allowed as they lower to a
+// single i32 or i64), but rejects types like strings and structs that would be
+// split into multiple parameters or returned indirectly, which doesn't match
+// what the host expects. For each rejected type, a possible alternative is
+// suggested.
+func (c *compilerContext) checkWasmABI(f *ssa.Function, pragma string) {
+	switch c.pkg.Path() {
+	case "runtime", "syscall/js", "internal/task":
+		// Allow the runtime to do whatever it needs to do. For example, the
+		// asyncify scheduler exports methods.
+		return
+	}
+	sig := f.Signature
+	if sig.Recv() != nil {
+		c.addError(f.Pos(), fmt.Sprintf("%s: cannot export a method, use a function that calls the method instead", pragma))
+		return
+	}
+	if sig.Results().Len() > 1 {
+		c.addError(sig.Results().At(1).Pos(), fmt.Sprintf("%s: too many return values, return a pointer to a struct instead", pragma))
+	} else if sig.Results().Len() == 1 {
+		result := sig.Results().At(0)
+		if suggestion := wasmABISuggestion(result.Type()); suggestion != "" {
+			c.addError(result.Pos(), fmt.Sprintf("%s: result type %s is not a WebAssembly type, %s", pragma, result.Type().String(), suggestion))
+		}
+	}
+	for i := 0; i < sig.Params().Len(); i++ {
+		param := sig.Params().At(i)
+		if suggestion := wasmABISuggestion(param.Type()); suggestion != "" {
+			c.addError(param.Pos(), fmt.Sprintf("%s: parameter type %s is not a WebAssembly type, %s", pragma, param.Type().String(), suggestion))
+		}
+	}
+}
+
+// wasmABISuggestion returns the empty string if the type lowers to a single
+// WebAssembly numeric type, and otherwise a suggestion for a type that does.
+func wasmABISuggestion(typ types.Type) string {
+	switch typ := typ.Underlying().(type) {
+	case *types.Basic:
+		switch {
+		case typ.Info()&types.IsString != 0:
+			return "pass a pointer and length instead (unsafe.StringData(s), uint32(len(s)))"
+		case typ.Info()&types.IsComplex != 0:
+			return "pass the real and imaginary parts separately instead"
+		}
+		return ""
+	case *types.Pointer:
+		return ""
+	case *types.Slice:
+		return "pass a pointer and length instead (unsafe.SliceData(s), uint32(len(s)))"
+	case *types.Struct, *types.Array:
+		return "pass a pointer to the value instead"
+	default:
+		// Interfaces, maps, channels, functions: these are Go-specific
+		// values that can't be used outside of Go.
+		return "it can only be used from Go"
+	}
+}
+
 // getParams returns the function parameters, including the receiver at the
 // start. This is an alternative to the Params member of *ssa.Function, which is
 // not yet populated when the package has not yet been built.
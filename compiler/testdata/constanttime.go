@@ -0,0 +1,67 @@
+package main
+
+// Comparing two slices in constant time is allowed: the loop only depends on
+// the length.
+//
+//go:constanttime
+func equal(a, b []byte) bool {
+	v := byte(0)
+	for i := range a {
+		v |= a[i] ^ b[i]
+	}
+	return v == 0
+}
+
+// ERROR: constant time: branch on secret value
+//
+//go:constanttime
+func branch(key []byte) int {
+	if key[0] == 3 {
+		return 1
+	}
+	return 0
+}
+
+var table [256]byte
+
+// ERROR: constant time: index depends on secret value
+//
+//go:constanttime
+func lookupTable(key []byte) byte {
+	return table[key[0]]
+}
+
+// ERROR: constant time: division of secret value
+// ERROR: constant time: division by secret value
+//
+//go:constanttime
+func divide(key []byte, n int) int {
+	return int(key[0])/n + 1000/n + int(key[1])/7
+}
+
+// ERROR: constant time: lookup depends on secret value
+//
+//go:constanttime
+func lookupMap(key []byte, m map[byte]int) int {
+	return m[key[0]]
+}
+
+// ERROR: constant time: branch on secret value
+//
+//go:constanttime
+func local(k uint32) uint32 {
+	var s [4]uint32
+	s[1] = k
+	if s[2] > 3 {
+		return 1
+	}
+	return 0
+}
+
+// Functions without //go:constanttime are not checked.
+func unchecked(key []byte) int {
+	if key[0] == 3 {
+		return 1
+	}
+	return 0
+}
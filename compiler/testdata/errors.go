@@ -41,3 +41,26 @@ func invalidreturn() int
 //
 //go:wasmimport modulename invalidUnsafePointerReturn
 func invalidUnsafePointerReturn() unsafe.Pointer
+
+//go:wasmexport validexport
+func validexport(a int32, b float64) int64 {
+	return 0
+}
+
+// ERROR: can only use //go:wasmexport on definitions
+//
+//go:wasmexport exportdeclaration
+func exportdeclaration()
+
+// ERROR: //go:wasmexport invalidexport: unsupported parameter type string
+//
+//go:wasmexport invalidexport
+func invalidexport(a string) {
+}
+
+// ERROR: //go:wasmexport invalidexportresult: unsupported result type unsafe.Pointer
+//
+//go:wasmexport invalidexportresult
+func invalidexportresult() unsafe.Pointer {
+	return nil
+}
@@ -0,0 +1,30 @@
+package main
+
+//export validlegacyexport
+func validlegacyexport(a int, b *byte, c uintptr, d bool) int {
+	return 0
+}
+
+type Point struct{ X, Y int32 }
+
+// ERROR: //export legacyexport: result type error is not a WebAssembly type, it can only be used from Go
+// ERROR: //export legacyexport: parameter type string is not a WebAssembly type, pass a pointer and length instead (unsafe.StringData(s), uint32(len(s)))
+// ERROR: //export legacyexport: parameter type []byte is not a WebAssembly type, pass a pointer and length instead (unsafe.SliceData(s), uint32(len(s)))
+// ERROR: //export legacyexport: parameter type main.Point is not a WebAssembly type, pass a pointer to the value instead
+//
+//export legacyexport
+func legacyexport(a string, b []byte, c Point) error {
+	return nil
+}
+
+// ERROR: //export legacymulti: too many return values, return a pointer to a struct instead
+//
+//export legacymulti
+func legacymulti() (int32, int32) {
+	return 0, 0
+}
+
+// Declarations without a body are imports, which the host defines.
+//
+//export legacyimport
+func legacyimport(s string) (int32, int32)
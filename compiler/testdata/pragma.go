@@ -48,6 +48,12 @@ func inlineFunc() {
 func noinlineFunc() {
 }
 
+// Function should never be inlined and is optimized for size, for cold code.
+//
+//go:outline
+func outlineFunc() {
+}
+
 // This function should have the specified section.
 //
 //go:section .special_function_section
@@ -159,6 +159,12 @@ func Get(name string) string {
 		}
 
 		return findWasmOpt()
+	case "POLKATOOL":
+		// The linker of PolkaVM, which converts ELF files to PolkaVM blobs.
+		if path := os.Getenv("POLKATOOL"); path != "" {
+			return path
+		}
+		return "polkatool"
 	default:
 		return ""
 	}
@@ -11,6 +11,10 @@ import (
 // Update this value before release of new version of software.
 const version = "0.31.2"
 
+// Version of the LimeChain fork, released on top of the TinyGo version above.
+// Update this value before a release of the fork.
+const forkVersion = "1"
+
 var (
 	// This variable is set at build time using -ldflags parameters.
 	// See: https://stackoverflow.com/a/11355611
@@ -27,6 +31,13 @@ func Version() string {
 	return v
 }
 
+// ForkVersion returns the version of the LimeChain fork of TinyGo, which is the
+// TinyGo version it is based on followed by the fork version, like
+// 0.31.2-limechain.1.
+func ForkVersion() string {
+	return Version() + "-limechain." + forkVersion
+}
+
 // GetGorootVersion returns the major and minor version for a given GOROOT path.
 // If the goroot cannot be determined, (0, 0) is returned.
 func GetGorootVersion() (major, minor int, err error) {
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/tetratelabs/wazero/api"
+	"github.com/tinygo-org/tinygo/builder"
+	"github.com/tinygo-org/tinygo/wasmhost"
+)
+
+func TestHeapSnapshot(t *testing.T) {
+	t.Parallel()
+
+	options := optionsFromTarget("polkawasm", sema)
+	options.HeapSnapshot = true
+	options.Selfcheck = true
+	config, err := builder.NewConfig(&options)
+	if err != nil {
+		t.Fatal(err)
+	}
+	result, err := builder.Build("testdata/heapsnapshot.go", ".wasm", t.TempDir(), config)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Take a snapshot, change the globals and the heap, and roll back to the
+	// snapshot. The program must still work after that, and it must be
+	// possible to roll back to the same snapshot again. The runtime state must
+	// stay valid all the time.
+	var stdout bytes.Buffer
+	runHost := func(ctx context.Context, mod api.Module, stack []uint64) {
+		call := func(name string, params ...uint64) uint64 {
+			results, err := mod.ExportedFunction(name).Call(ctx, params...)
+			if err != nil {
+				panic(err)
+			}
+			if failed, err := mod.ExportedFunction("__runtime_selfcheck").Call(ctx); err != nil || failed[0] != 0 {
+				panic(fmt.Sprintf("runtime self-check failed after %s: %v %v", name, failed, err))
+			}
+			if len(results) == 0 {
+				return 0
+			}
+			return results[0]
+		}
+		call("update", 1)
+		call("update", 2)
+		if size := call("tinygo_heap_snapshot"); size == 0 {
+			panic("could not take a snapshot")
+		}
+		call("report")
+		for n := uint64(3); n < 100; n++ {
+			call("update", n)
+		}
+		call("report")
+		if call("tinygo_heap_restore") == 0 {
+			panic("could not restore the snapshot")
+		}
+		call("report")
+		call("update", 200)
+		call("report")
+		call("tinygo_heap_restore")
+		call("report")
+	}
+	_, err = wasmhost.Run(context.Background(), result.Binary, wasmhost.Config{
+		Stdout:    &stdout,
+		Functions: []wasmhost.HostFunction{{Name: "run_host", Func: runHost}},
+	})
+	if err != nil {
+		t.Fatal("could not run program:", err)
+	}
+	expected := "" +
+		"counter: 2 names: 2 values: 2 sum: 6\n" +
+		"counter: 99 names: 99 values: 99 sum: 9900\n" +
+		"counter: 2 names: 2 values: 2 sum: 6\n" +
+		"counter: 3 names: 3 values: 3 sum: 406\n" +
+		"counter: 2 names: 2 values: 2 sum: 6\n"
+	if stdout.String() != expected {
+		t.Errorf("unexpected output:\n%s", stdout.String())
+	}
+}
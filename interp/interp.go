@@ -11,6 +11,10 @@ import (
 	"tinygo.org/x/go-llvm"
 )
 
+// Function attribute of package initializers that must be evaluated at compile
+// time, set with //go:compiletimeinit.
+const compiletimeInitAttr = "tinygo-compiletime-init"
+
 // Enable extra checks, which should be disabled by default.
 // This may help track down bugs by adding a few more sanity checks.
 const checks = true
@@ -60,7 +64,11 @@ func (r *runner) dispose() {
 
 // Run evaluates runtime.initAll function as much as possible at compile time.
 // Set debug to true if it should print output while running.
-func Run(mod llvm.Module, timeout time.Duration, debug bool) error {
+//
+// Package initializers that could not be evaluated at compile time are left to
+// run at runtime. They are returned, with the error that made the interpreter
+// give up on them.
+func Run(mod llvm.Module, timeout time.Duration, debug bool) (runtimeInits []*Error, err error) {
 	r := newRunner(mod, timeout, debug)
 	defer r.dispose()
 
@@ -97,7 +105,7 @@ func Run(mod llvm.Module, timeout time.Duration, debug bool) error {
 			break // ret void
 		}
 		if inst.IsACallInst().IsNil() || inst.CalledValue().IsAFunction().IsNil() {
-			return errorAt(inst, "interp: expected all instructions in "+initAll.Name()+" to be direct calls")
+			return nil, errorAt(inst, "interp: expected all instructions in "+initAll.Name()+" to be direct calls")
 		}
 		initCalls = append(initCalls, inst)
 	}
@@ -107,7 +115,7 @@ func Run(mod llvm.Module, timeout time.Duration, debug bool) error {
 	for _, call := range initCalls {
 		initName := call.CalledValue().Name()
 		if !strings.HasSuffix(initName, ".init") {
-			return errorAt(call, "interp: expected all instructions in "+initAll.Name()+" to be *.init() calls")
+			return nil, errorAt(call, "interp: expected all instructions in "+initAll.Name()+" to be *.init() calls")
 		}
 		r.pkgName = initName[:len(initName)-len(".init")]
 		fn := call.CalledValue()
@@ -117,6 +125,12 @@ func Run(mod llvm.Module, timeout time.Duration, debug bool) error {
 		_, mem, callErr := r.run(r.getFunction(fn), nil, nil, "    ")
 		call.EraseFromParentAsInstruction()
 		if callErr != nil {
+			if isRecoverableError(callErr.Err) && !fn.GetStringAttributeAtIndex(-1, compiletimeInitAttr).IsNil() {
+				// The package is marked with //go:compiletimeinit, so it
+				// must not be initialized at runtime.
+				callErr.Err = fmt.Errorf("package initializer cannot be evaluated at compile time (//go:compiletimeinit): %w", callErr.Err)
+				return nil, callErr
+			}
 			if isRecoverableError(callErr.Err) {
 				if r.debug {
 					fmt.Fprintln(os.Stderr, "not interpreting", r.pkgName, "because of error:", callErr.Error())
@@ -132,11 +146,12 @@ func Run(mod llvm.Module, timeout time.Duration, debug bool) error {
 				// initializer, won't be accessed by later package initializers.
 				err := r.markExternalLoad(fn)
 				if err != nil {
-					return fmt.Errorf("failed to interpret package %s: %w", r.pkgName, err)
+					return nil, fmt.Errorf("failed to interpret package %s: %w", r.pkgName, err)
 				}
+				runtimeInits = append(runtimeInits, callErr)
 				continue
 			}
-			return callErr
+			return nil, callErr
 		}
 		for index, obj := range mem.objects {
 			r.objects[index] = obj
@@ -166,7 +181,7 @@ func Run(mod llvm.Module, timeout time.Duration, debug bool) error {
 			// memory layout.
 			initializer, err := obj.buffer.asRawValue(r).rawLLVMValue(&mem)
 			if err != nil {
-				return err
+				return nil, err
 			}
 			initializerType := initializer.Type()
 			newGlobal := llvm.AddGlobal(mod, initializerType, obj.llvmGlobal.Name()+".tmp")
@@ -187,7 +202,7 @@ func Run(mod llvm.Module, timeout time.Duration, debug bool) error {
 			continue
 		}
 		if err != nil {
-			return err
+			return nil, err
 		}
 		if checks && initializer.Type() != obj.llvmGlobal.GlobalValueType() {
 			panic("initializer type mismatch")
@@ -195,7 +210,7 @@ func Run(mod llvm.Module, timeout time.Duration, debug bool) error {
 		obj.llvmGlobal.SetInitializer(initializer)
 	}
 
-	return nil
+	return runtimeInits, nil
 }
 
 // RunFunc evaluates a single package initializer at compile time.
@@ -215,6 +230,9 @@ func RunFunc(fn llvm.Value, timeout time.Duration, debug bool) error {
 	newFn := llvm.AddFunction(mod, fn.Name()+".tmp", fn.GlobalValueType())
 	newFn.SetLinkage(fn.Linkage())
 	newFn.SetVisibility(fn.Visibility())
+	if attr := fn.GetStringAttributeAtIndex(-1, compiletimeInitAttr); !attr.IsNil() {
+		newFn.AddFunctionAttr(attr)
+	}
 	entry := mod.Context().AddBasicBlock(newFn, "entry")
 
 	// Create a builder, to insert instructions that could not be evaluated at
@@ -17,27 +17,30 @@ func TestInterp(t *testing.T) {
 		// for a particular build because llvm.Version is a constant.
 		panic(err)
 	}
-	for _, name := range []string{
-		"basic",
-		"phi",
-		"slice-copy",
-		"consteval",
-		"interface",
-		"revert",
-		"alloc",
+	for _, tc := range []struct {
+		name         string
+		runtimeInits []string // packages that are initialized at runtime
+	}{
+		{"basic", nil},
+		{"phi", nil},
+		{"slice-copy", nil},
+		{"consteval", nil},
+		{"interface", nil},
+		{"revert", []string{"baz", "foo", "y"}},
+		{"alloc", nil},
 	} {
-		name := name // make local to this closure
-		if name == "slice-copy" && llvmVersion < 14 {
+		tc := tc // make local to this closure
+		if tc.name == "slice-copy" && llvmVersion < 14 {
 			continue
 		}
-		t.Run(name, func(t *testing.T) {
+		t.Run(tc.name, func(t *testing.T) {
 			t.Parallel()
-			runTest(t, "testdata/"+name)
+			runTest(t, "testdata/"+tc.name, tc.runtimeInits)
 		})
 	}
 }
 
-func runTest(t *testing.T, pathPrefix string) {
+func runTest(t *testing.T, pathPrefix string, expectedRuntimeInits []string) {
 	// Read the input IR.
 	ctx := llvm.NewContext()
 	defer ctx.Dispose()
@@ -53,7 +56,7 @@ func runTest(t *testing.T, pathPrefix string) {
 	defer mod.Dispose()
 
 	// Perform the transform.
-	err = Run(mod, 10*time.Minute, false)
+	runtimeInits, err := Run(mod, 10*time.Minute, false)
 	if err != nil {
 		if err, match := err.(*Error); match {
 			println(err.Error())
@@ -71,6 +74,15 @@ func runTest(t *testing.T, pathPrefix string) {
 		t.Fatal(err)
 	}
 
+	// Check which package initializers were left to run at runtime.
+	var importPaths []string
+	for _, init := range runtimeInits {
+		importPaths = append(importPaths, init.ImportPath)
+	}
+	if strings.Join(importPaths, " ") != strings.Join(expectedRuntimeInits, " ") {
+		t.Errorf("expected runtime initializers %v, got %v", expectedRuntimeInits, importPaths)
+	}
+
 	// To be sure, verify that the module is still valid.
 	if llvm.VerifyModule(mod, llvm.PrintMessageAction) != nil {
 		t.FailNow()
@@ -132,3 +144,25 @@ func filterIrrelevantIRLines(lines []string) []string {
 	}
 	return out
 }
+
+func TestCompiletimeInit(t *testing.T) {
+	t.Parallel()
+	ctx := llvm.NewContext()
+	defer ctx.Dispose()
+	buf, err := llvm.NewMemoryBufferFromFile("testdata/compiletimeinit.ll")
+	if err != nil {
+		t.Fatal("could not read file:", err)
+	}
+	mod, err := ctx.ParseIR(buf)
+	if err != nil {
+		t.Fatal("could not load module:", err)
+	}
+	defer mod.Dispose()
+
+	// The package initializer can't be evaluated at compile time, which is an
+	// error because of //go:compiletimeinit.
+	_, err = Run(mod, 10*time.Minute, false)
+	if interpErr, ok := err.(*Error); !ok || interpErr.ImportPath != "main" || !strings.Contains(interpErr.Error(), "//go:compiletimeinit") {
+		t.Errorf("expected //go:compiletimeinit error for package main, got %v", err)
+	}
+}
@@ -0,0 +1,18 @@
+target datalayout = "e-m:e-i64:64-f80:128-n8:16:32:64-S128"
+target triple = "x86_64--linux"
+
+@main.knownAtRuntime = global i64 0
+
+define void @runtime.initAll() unnamed_addr {
+entry:
+  call void @main.init(ptr undef)
+  ret void
+}
+
+; Marked with //go:compiletimeinit, so it must not be reverted.
+define internal void @main.init(ptr %context) unnamed_addr #0 {
+  store i64 5, ptr @main.knownAtRuntime
+  unreachable ; this triggers a revert of @main.init.
+}
+
+attributes #0 = { "tinygo-compiletime-init" }
@@ -233,6 +233,7 @@ func pathsToOverride(goMinor int, needsSyscallPackage bool) map[string]bool {
 		"crypto/":               true,
 		"crypto/rand/":          false,
 		"crypto/tls/":           false,
+		"cosmwasm/":             false,
 		"device/":               false,
 		"examples/":             false,
 		"internal/":             true,
@@ -241,12 +242,15 @@ func pathsToOverride(goMinor int, needsSyscallPackage bool) map[string]bool {
 		"internal/reflectlite/": false,
 		"internal/task/":        false,
 		"machine/":              false,
+		"math/":                 true,
+		"math/u256/":            false,
 		"net/":                  true,
 		"net/http/":             false,
 		"os/":                   true,
 		"os/user/":              false,
 		"reflect/":              false,
 		"runtime/":              false,
+		"stylus/":               false,
 		"sync/":                 true,
 		"testing/":              true,
 	}
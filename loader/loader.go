@@ -25,6 +25,7 @@ import (
 	"github.com/tinygo-org/tinygo/cgo"
 	"github.com/tinygo-org/tinygo/compileopts"
 	"github.com/tinygo-org/tinygo/goenv"
+	"github.com/tinygo-org/tinygo/scalegen"
 )
 
 var initFileVersions = func(info *types.Info) {}
@@ -51,8 +52,10 @@ type PackageJSON struct {
 	Name       string
 	ForTest    string
 	Root       string
+	Goroot     bool // part of the (TinyGo) standard library
 	Module     struct {
 		Path      string
+		Version   string
 		Main      bool
 		Dir       string
 		GoMod     string
@@ -477,6 +480,19 @@ func (p *Package) parseFiles() ([]*ast.File, error) {
 		return nil, Errors{p, fileErrs}
 	}
 
+	// Derive the SCALE codec methods of //tinygo:scale types.
+	scaleCode, err := scalegen.Generate(p.program.fset, files)
+	if err != nil {
+		return nil, Errors{p, []error{err}}
+	}
+	if scaleCode != nil {
+		f, err := parser.ParseFile(p.program.fset, p.Dir+"/!scale.go", scaleCode, parser.ParseComments)
+		if err != nil {
+			return nil, Errors{p, []error{err}}
+		}
+		files = append(files, f)
+	}
+
 	return files, nil
 }
 
@@ -33,6 +33,7 @@ import (
 	"github.com/tinygo-org/tinygo/goenv"
 	"github.com/tinygo-org/tinygo/interp"
 	"github.com/tinygo-org/tinygo/loader"
+	"github.com/tinygo-org/tinygo/wasmhost"
 	"golang.org/x/tools/go/buildutil"
 	"tinygo.org/x/go-llvm"
 
@@ -214,6 +215,81 @@ func Build(pkgName, outpath string, options *compileopts.Options) error {
 	return nil
 }
 
+// BuildGCMatrix builds the given package once for every garbage collector in
+// the comma separated list of -gc-matrix, to compare their size and
+// performance. The output file of each build is outpath with the name of the
+// collector appended, like runtime_extalloc.wasm for -o runtime.wasm.
+//
+// The builds run one after the other, each as a full build of its own: the
+// front end is not shared between them.
+func BuildGCMatrix(pkgName, outpath, gcMatrix string, options *compileopts.Options) error {
+	if outpath == "" {
+		return errors.New("-gc-matrix needs an output file (-o)")
+	}
+	if options.GC != "" {
+		return errors.New("-gc-matrix can't be used with -gc")
+	}
+	gcs := strings.Split(gcMatrix, ",")
+	var gcOptions []*compileopts.Options
+	for i, gc := range gcs {
+		for _, other := range gcs[:i] {
+			if gc == other {
+				return fmt.Errorf("-gc-matrix: %s is listed more than once", gc)
+			}
+		}
+		opts, err := gcMatrixOptions(options, gc)
+		if err != nil {
+			return fmt.Errorf("-gc-matrix: %w", err)
+		}
+		gcOptions = append(gcOptions, opts)
+	}
+
+	var sizes []int64
+	for i, gc := range gcs {
+		gcOutpath := gcMatrixOutpath(outpath, gc)
+		if err := Build(pkgName, gcOutpath, gcOptions[i]); err != nil {
+			return err
+		}
+		if options.PrintJSON {
+			continue // only the configuration was printed
+		}
+		st, err := os.Stat(gcOutpath)
+		if err != nil {
+			return err
+		}
+		sizes = append(sizes, st.Size())
+	}
+	for i, size := range sizes {
+		fmt.Printf("%-16s %9d  %s\n", gcs[i], size, gcMatrixOutpath(outpath, gcs[i]))
+	}
+	return nil
+}
+
+// gcMatrixOptions returns a copy of options that builds with the given
+// -gc-matrix entry. Every entry is a -gc option, except for extalloc_leaking
+// which is the extalloc GC with the extalloc_leaking build tag (see
+// src/runtime/gc_extalloc_leaking.go).
+func gcMatrixOptions(options *compileopts.Options, gc string) (*compileopts.Options, error) {
+	gcOptions := *options
+	gcOptions.Tags = append([]string(nil), options.Tags...)
+	gcOptions.GC = gc
+	if gc == "extalloc_leaking" {
+		gcOptions.GC = "extalloc"
+		gcOptions.Tags = append(gcOptions.Tags, "extalloc_leaking")
+	}
+	if err := gcOptions.Verify(); err != nil {
+		return nil, err
+	}
+	return &gcOptions, nil
+}
+
+// gcMatrixOutpath returns the output path of the build with the given garbage
+// collector for -gc-matrix.
+func gcMatrixOutpath(outpath, gc string) string {
+	ext := filepath.Ext(outpath)
+	return strings.TrimSuffix(outpath, ext) + "_" + gc + ext
+}
+
 // Test runs the tests in the given package. Returns whether the test passed and
 // possibly an error if the test failed to run.
 func Test(pkgName string, stdout, stderr io.Writer, options *compileopts.Options, outpath string) (bool, error) {
@@ -254,8 +330,33 @@ func Test(pkgName string, stdout, stderr io.Writer, options *compileopts.Options
 	if testConfig.Shuffle != "" {
 		flags = append(flags, "-test.shuffle="+testConfig.Shuffle)
 	}
+	if testConfig.FuzzRegexp != "" {
+		// Fuzzing is driven by the built-in WebAssembly host, which provides
+		// the inputs and keeps track of the coverage.
+		if config.EmulatorName() != "wazero" {
+			return false, errors.New("-fuzz is only supported on WebAssembly targets that use the wazero emulator")
+		}
+		flags = append(flags, "-test.fuzz="+testConfig.FuzzRegexp)
+	}
+	if testConfig.FuzzTime != "" {
+		flags = append(flags, "-test.fuzztime="+testConfig.FuzzTime)
+	}
 
-	logToStdout := testConfig.Verbose || testConfig.BenchRegexp != ""
+	// Coverage counters are written by the built-in WebAssembly host to a
+	// temporary directory, and converted to a profile afterwards.
+	var coverDir string
+	if options.Cover && !testConfig.CompileOnly {
+		if config.EmulatorName() != "wazero" {
+			return false, errors.New("-cover is only supported on WebAssembly targets that use the wazero emulator")
+		}
+		coverDir, err = os.MkdirTemp("", "tinygo-cover")
+		if err != nil {
+			return false, err
+		}
+		defer os.RemoveAll(coverDir)
+	}
+
+	logToStdout := testConfig.Verbose || testConfig.BenchRegexp != "" || testConfig.FuzzRegexp != ""
 
 	var buf bytes.Buffer
 	var output io.Writer = &buf
@@ -318,6 +419,13 @@ func Test(pkgName string, stdout, stderr io.Writer, options *compileopts.Options
 			cmd.Args = append(cmd.Args[:1:1], args...)
 		}
 
+		if coverDir != "" {
+			if cmd.Env == nil {
+				cmd.Env = os.Environ()
+			}
+			cmd.Env = append(cmd.Env, "GOCOVERDIR="+coverDir)
+		}
+
 		// Run the test.
 		start := time.Now()
 		err = cmd.Run()
@@ -340,6 +448,24 @@ func Test(pkgName string, stdout, stderr io.Writer, options *compileopts.Options
 	})
 	importPath := strings.TrimSuffix(result.ImportPath, ".test")
 
+	var coverage string
+	if coverDir != "" && err == nil {
+		profile := io.Discard
+		if testConfig.CoverProfile != "" {
+			f, err := os.Create(testConfig.CoverProfile)
+			if err != nil {
+				return false, err
+			}
+			defer f.Close()
+			profile = f
+		}
+		covered, err := wasmhost.WriteCoverProfile(profile, []string{coverDir})
+		if err != nil {
+			return false, fmt.Errorf("could not read coverage data: %w", err)
+		}
+		coverage = fmt.Sprintf("\tcoverage: %.1f%% of statements", covered*100)
+	}
+
 	var w io.Writer = stdout
 	if logToStdout {
 		w = os.Stdout
@@ -349,7 +475,7 @@ func Test(pkgName string, stdout, stderr io.Writer, options *compileopts.Options
 		// Pretend the test passed - it at least didn't fail.
 		return true, nil
 	} else if passed && !testConfig.CompileOnly {
-		fmt.Fprintf(w, "ok  \t%s\t%.3fs\n", importPath, duration.Seconds())
+		fmt.Fprintf(w, "ok  \t%s\t%.3fs%s\n", importPath, duration.Seconds(), coverage)
 	} else {
 		fmt.Fprintf(w, "FAIL\t%s\t%.3fs\n", importPath, duration.Seconds())
 	}
@@ -780,6 +906,148 @@ func Run(pkgName string, options *compileopts.Options, cmdArgs []string) error {
 	return err
 }
 
+// gcDiffRun is the result of running a program in RunGCDiff.
+type gcDiffRun struct {
+	gc       string
+	binary   string
+	stdout   bytes.Buffer
+	stderr   bytes.Buffer
+	exitCode int
+	err      error // error returned by the host, for example a trap
+	stats    wasmhost.MemStats
+}
+
+// RunGCDiff compiles the given program twice, with -gc=extalloc and with
+// -gc=conservative, runs both under the built-in WebAssembly host and compares
+// their output and memory statistics. A difference usually means that one of
+// the collectors freed memory that was still in use, or that the compiler
+// generated code that relies on a particular collector. The output of the
+// extalloc run is written to stdout.
+func RunGCDiff(pkgName string, options *compileopts.Options, cmdArgs []string) error {
+	tmpdir, err := os.MkdirTemp("", "tinygo")
+	if err != nil {
+		return err
+	}
+	if !options.Work {
+		defer os.RemoveAll(tmpdir)
+	}
+
+	var runs []*gcDiffRun
+	for _, gc := range []string{"extalloc", "conservative"} {
+		gcOptions := *options
+		gcOptions.GC = gc
+		gcOptions.GCDiff = true
+		config, err := builder.NewConfig(&gcOptions)
+		if err != nil {
+			return err
+		}
+		if config.EmulatorName() != "wazero" {
+			return errors.New("-gc-diff is only supported on WebAssembly targets that use the wazero emulator")
+		}
+		outdir := filepath.Join(tmpdir, gc)
+		if err := os.Mkdir(outdir, 0o777); err != nil {
+			return err
+		}
+		result, err := builder.Build(pkgName, ".wasm", outdir, config)
+		if err != nil {
+			return err
+		}
+		run := &gcDiffRun{gc: gc, binary: result.Binary}
+		run.exitCode, run.err = wasmhost.Run(context.Background(), result.Binary, wasmhost.Config{
+			Args:     cmdArgs,
+			Dirs:     []string{"."},
+			Stdout:   &run.stdout,
+			Stderr:   &run.stderr,
+			MemStats: &run.stats,
+		})
+		runs = append(runs, run)
+	}
+	os.Stdout.Write(runs[0].stdout.Bytes())
+
+	// Compare everything that can be observed from outside the program.
+	var problems []string
+	a, b := runs[0], runs[1]
+	if (a.err != nil) != (b.err != nil) {
+		problems = append(problems, fmt.Sprintf("result differs:\n  %s: %s\n  %s: %s", a.gc, gcDiffResult(a), b.gc, gcDiffResult(b)))
+	} else if a.exitCode != b.exitCode {
+		problems = append(problems, fmt.Sprintf("exit code differs: %s exited with %d, %s with %d", a.gc, a.exitCode, b.gc, b.exitCode))
+	}
+	for _, output := range []struct {
+		name string
+		a, b []byte
+	}{
+		{"stdout", a.stdout.Bytes(), b.stdout.Bytes()},
+		{"stderr", a.stderr.Bytes(), b.stderr.Bytes()},
+	} {
+		if line, ok := firstDifferentLine(output.a, output.b); ok {
+			problems = append(problems, fmt.Sprintf("%s differs at line %d:\n  %s: %q\n  %s: %q", output.name, line+1, a.gc, lineAt(output.a, line), b.gc, lineAt(output.b, line)))
+		}
+	}
+	for _, run := range runs {
+		if !run.stats.Valid {
+			continue
+		}
+		if err := run.stats.Check(); err != nil {
+			problems = append(problems, fmt.Sprintf("%s: invalid memory statistics: %v", run.gc, err))
+		}
+	}
+	if a.stats.Valid && b.stats.Valid && a.stats.Mallocs != b.stats.Mallocs {
+		problems = append(problems, fmt.Sprintf("number of allocations differs: %s did %d, %s did %d", a.gc, a.stats.Mallocs, b.gc, b.stats.Mallocs))
+	}
+	if len(problems) != 0 {
+		return errors.New("-gc-diff: " + strings.Join(problems, "\n"))
+	}
+	if !a.stats.Valid || !b.stats.Valid {
+		fmt.Fprintln(os.Stderr, "gc-diff: output matches (memory statistics not available, the program did not return from main)")
+	} else {
+		fmt.Fprintf(os.Stderr, "gc-diff: output matches (%d allocations)\n", a.stats.Mallocs)
+	}
+	if a.err != nil {
+		return a.err
+	}
+	if a.exitCode != 0 {
+		return &commandError{"failed to run compiled binary", a.binary, fmt.Errorf("exit status %d", a.exitCode)}
+	}
+	return nil
+}
+
+// gcDiffResult describes how a program run by RunGCDiff ended.
+func gcDiffResult(run *gcDiffRun) string {
+	if run.err != nil {
+		return run.err.Error()
+	}
+	return fmt.Sprintf("exit code %d", run.exitCode)
+}
+
+// firstDifferentLine returns the index of the first line that differs between
+// a and b.
+func firstDifferentLine(a, b []byte) (int, bool) {
+	if bytes.Equal(a, b) {
+		return 0, false
+	}
+	linesA := strings.Split(string(a), "\n")
+	linesB := strings.Split(string(b), "\n")
+	for i := 0; i < len(linesA) && i < len(linesB); i++ {
+		if linesA[i] != linesB[i] {
+			return i, true
+		}
+	}
+	if len(linesA) < len(linesB) {
+		return len(linesA), true
+	}
+	return len(linesB), true
+}
+
+// lineAt returns the line with the given index, or "<EOF>" if there is no
+// such line.
+func lineAt(data []byte, index int) string {
+	lines := strings.Split(string(data), "\n")
+	if index >= len(lines) {
+		return "<EOF>"
+	}
+	return lines[index]
+}
+
 // buildAndRun builds and runs the given program, writing output to stdout and
 // errors to os.Stderr. It takes care of emulators (qemu, wasmtime, etc) and
 // passes command line arguments and evironment variables in a way appropriate
@@ -793,7 +1061,7 @@ func buildAndRun(pkgName string, config *compileopts.Config, stdout io.Writer, c
 	// conventional way.
 	needsEnvInVars := config.GOOS() == "js"
 	for _, tag := range config.BuildTags() {
-		if tag == "baremetal" {
+		if tag == "baremetal" || tag == "wasm_unknown" {
 			needsEnvInVars = true
 		}
 	}
@@ -835,6 +1103,14 @@ func buildAndRun(pkgName string, config *compileopts.Config, stdout io.Writer, c
 		if _, ok := os.LookupEnv("WASMTIME_BACKTRACE_DETAILS"); !ok {
 			extraCmdEnv = append(extraCmdEnv, "WASMTIME_BACKTRACE_DETAILS=1")
 		}
+	} else if config.EmulatorName() == "wazero" {
+		// The built-in WebAssembly host takes the same kind of flags as
+		// wasmtime.
+		emuArgs = append(emuArgs, "-dir=.")
+		for _, v := range environmentVars {
+			emuArgs = append(emuArgs, "-env="+v)
+		}
+		args = append(args, cmdArgs...)
 	} else {
 		// Pass environment variables and command line parameters as usual.
 		// This also works on qemu-aarch64 etc.
@@ -879,6 +1155,14 @@ func buildAndRun(pkgName string, config *compileopts.Config, stdout io.Writer, c
 		name = emulator[0]
 		emuArgs = append(emuArgs, emulator[1:]...)
 		args = append(emuArgs, args...)
+		if name == "wazero" {
+			// Run the binary using the WebAssembly host built into TinyGo.
+			name, err = os.Executable()
+			if err != nil {
+				return result, err
+			}
+			args = append([]string{"wasmhost"}, args...)
+		}
 	}
 	var cmd *exec.Cmd
 	if ctx != nil {
@@ -1229,8 +1513,10 @@ func usage(command string) {
 		fmt.Fprintln(os.Stderr, "  env:     list environment variables used during build")
 		fmt.Fprintln(os.Stderr, "  list:    run go list using the TinyGo root")
 		fmt.Fprintln(os.Stderr, "  clean:   empty cache directory ("+goenv.Get("GOCACHE")+")")
-		fmt.Fprintln(os.Stderr, "  targets: list targets")
+		fmt.Fprintln(os.Stderr, "  targets: list targets (or describe them with -json)")
 		fmt.Fprintln(os.Stderr, "  info:    show info for specified target")
+		fmt.Fprintln(os.Stderr, "  covdata: convert coverage data of WebAssembly programs built with -cover")
+		fmt.Fprintln(os.Stderr, "  inspect: show how a WebAssembly file was built")
 		fmt.Fprintln(os.Stderr, "  version: show version")
 		fmt.Fprintln(os.Stderr, "  help:    print this help text")
 
@@ -1353,6 +1639,112 @@ func (m globalValuesFlag) Set(value string) error {
 	return nil
 }
 
+// runWasmHost runs a WebAssembly file using the host built into TinyGo, which
+// is used for targets with the "wazero" emulator. It returns the exit code of
+// the WebAssembly program.
+func runWasmHost(args []string) int {
+	var config wasmhost.Config
+	flags := flag.NewFlagSet("wasmhost", flag.ExitOnError)
+	flags.Func("env", "set an environment variable (key=value)", func(s string) error {
+		config.Env = append(config.Env, s)
+		return nil
+	})
+	flags.Func("dir", "make a directory available (dir or hostdir::guestdir)", func(s string) error {
+		config.Dirs = append(config.Dirs, s)
+		return nil
+	})
+	bench := flags.String("bench", "", "run the exported benchmarks matching the regular expression")
+	flags.StringVar(&config.CoverDir, "coverdir", os.Getenv("GOCOVERDIR"), "write coverage data of modules built with -cover to this directory")
+	flags.Parse(args)
+	if flags.NArg() < 1 {
+		fmt.Fprintln(os.Stderr, "usage: tinygo wasmhost [-env=key=value] [-dir=dir] [-bench=regexp] [-coverdir=dir] file.wasm [args...]")
+		return 1
+	}
+	config.Args = flags.Args()[1:]
+	config.Stdin = os.Stdin
+	config.Stdout = os.Stdout
+	config.Stderr = os.Stderr
+	if *bench != "" {
+		match, err := regexp.Compile(*bench)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "error:", err)
+			return 1
+		}
+		results, err := wasmhost.RunBenchmarks(context.Background(), flags.Arg(0), config, match)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "error:", err)
+			return 1
+		}
+		for _, result := range results {
+			fmt.Println(result)
+		}
+		return 0
+	}
+	var initReport []wasmhost.InitCost
+	config.InitReport = &initReport
+	var hostCallStats []wasmhost.HostCallStat
+	config.HostCallStats = &hostCallStats
+	exitCode, err := wasmhost.Run(context.Background(), flags.Arg(0), config)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "error:", err)
+		var trapErr *wasmhost.TrapError
+		if errors.As(err, &trapErr) && trapErr.CrashDump != nil {
+			// The module was built with -crash-dump.
+			wasmhost.WriteCrashDump(os.Stderr, trapErr.CrashDump)
+		}
+		return 1
+	}
+	if initReport != nil {
+		// The module was built with -report-init.
+		wasmhost.WriteInitReport(os.Stderr, initReport)
+	}
+	if hostCallStats != nil {
+		// The module was built with -host-call-stats.
+		wasmhost.WriteHostCallStats(os.Stderr, hostCallStats)
+	}
+	return exitCode
+}
+
+// runCovData converts the coverage data written by `tinygo wasmhost` for
+// programs built with -cover, like `go tool covdata` does for Go programs. It
+// supports the textfmt and percent modes.
+func runCovData(args []string) int {
+	if len(args) < 1 || (args[0] != "textfmt" && args[0] != "percent") {
+		fmt.Fprintln(os.Stderr, "usage: tinygo covdata textfmt -i=dir[,dir...] -o=file")
+		fmt.Fprintln(os.Stderr, "       tinygo covdata percent -i=dir[,dir...]")
+		return 1
+	}
+	mode := args[0]
+	flags := flag.NewFlagSet("covdata "+mode, flag.ExitOnError)
+	input := flags.String("i", "", "comma separated list of coverage data directories")
+	output := flags.String("o", "", "output file for the coverage profile (textfmt)")
+	flags.Parse(args[1:])
+	if *input == "" || mode == "textfmt" && *output == "" {
+		flags.Usage()
+		return 1
+	}
+
+	var w io.Writer = io.Discard
+	if mode == "textfmt" {
+		f, err := os.Create(*output)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "error:", err)
+			return 1
+		}
+		defer f.Close()
+		w = f
+	}
+	covered, err := wasmhost.WriteCoverProfile(w, strings.Split(*input, ","))
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "error:", err)
+		return 1
+	}
+	if mode == "percent" {
+		fmt.Printf("coverage: %.1f%% of statements\n", covered*100)
+	}
+	return 0
+}
+
 // parseGoLinkFlag parses the -ldflags parameter. Its primary purpose right now
 // is the -X flag, for setting the value of global string variables.
 func parseGoLinkFlag(flagsString string) (map[string]map[string]string, error) {
@@ -1411,6 +1803,7 @@ func main() {
 	gc := flag.String("gc", "", "garbage collector to use (none, leaking, conservative)")
 	panicStrategy := flag.String("panic", "print", "panic strategy (print, trap)")
 	scheduler := flag.String("scheduler", "", "which scheduler to use (none, tasks, asyncify)")
+	maps := flag.String("maps", "", "which map implementation to use (buckets, compact)")
 	serial := flag.String("serial", "", "which serial output to use (none, uart, usb)")
 	work := flag.Bool("work", false, "print the name of the temporary build directory and do not delete this directory on exit")
 	interpTimeout := flag.Duration("interp-timeout", 180*time.Second, "interp optimization pass timeout")
@@ -1423,12 +1816,58 @@ func main() {
 		stackSize = uint64(size)
 		return err
 	})
-	printSize := flag.String("size", "", "print sizes (none, short, full)")
+	printSize := flag.String("size", "", "print sizes (none, short, full, json)")
+	var sizeBudget uint64
+	flag.Func("size-budget", "fail the build if the (wasm) binary without custom sections is larger than this size (e.g. 1.5MB)", func(s string) error {
+		size, err := bytesize.Parse(s)
+		sizeBudget = uint64(size)
+		return err
+	})
+	sizeCompare := flag.String("size-compare", "", "print per-function size differences compared to the given (wasm) binary")
 	printStacks := flag.Bool("print-stacks", false, "print stack sizes of goroutines")
-	printAllocsString := flag.String("print-allocs", "", "regular expression of functions for which heap allocations should be printed")
+	printAllocsString := flag.String("print-allocs", "", "regular expression of functions for which heap allocations should be printed (prefix with json: or use json for JSON output)")
+	inlineBudget := flag.Int("inline-budget", 0, "inlining threshold of the optimizer, lower for smaller and higher for faster code (WebAssembly only, 0 for the default of -opt, negative to only inline //go:inline functions)")
+	functionOrder := flag.String("function-order", "source", "order of the functions in the code section: source, callgraph (hot functions first, functions only called before a trap last), profile (most called in -function-profile first) (WebAssembly only)")
+	functionProfile := flag.String("function-profile", "", "file with a call count and a function name per line, for -function-order=profile")
+	yieldPoints := flag.Uint("yield-points", 0, "call the host (env.ext_yield) every N loop iterations (WebAssembly only, 0 to disable)")
+	whyLive := flag.String("why-live", "", "print which root (such as an exported function) keeps the given symbol in the binary")
+	printRetained := flag.Bool("print-retained", false, "print which root keeps each function in the binary")
+	printInits := flag.Bool("print-runtime-init", false, "print which package initializers could not be evaluated at compile time")
+	strictInit := flag.Bool("strict-init", false, "fail the build if a package initializer could not be evaluated at compile time")
+	constantTime := flag.Bool("constanttime", false, "report branches, table lookups and divisions that depend on secret values (the parameters) in functions annotated with //go:constanttime")
+	fpDeterministic := flag.Bool("fp-deterministic", false, "make floating point results independent of the host: canonicalize NaNs that leave the program, don't fuse multiply-add in C code and use the Go implementations of math.Exp, math.Exp2 and math.Log (WebAssembly only)")
+	lowerFmt := flag.Bool("lower-fmt", false, "lower simple fmt.Sprintf and fmt.Errorf calls (%s, %d, %v, %t, %x) to string concatenation")
+	mergeFunctions := flag.Bool("merge-functions", false, "fold functions with identical code, like generic instantiations for types with the same layout")
+	splitCold := flag.Bool("split-cold", false, "move code that only runs on error paths, like panics, to the end of the code section (WebAssembly only)")
+	icf := flag.Bool("icf", false, "fold functions with identical machine code in the linked module, keeping functions of which the address is taken (WebAssembly only)")
+	compressData := flag.Bool("compress-data", false, "compress large data segments and decompress them when the module is instantiated, for a smaller binary (WebAssembly only)")
+	sectionHook := flag.String("section-hook", "", "command (or Go file) that is run with the linked wasm file and prints a JSON object of custom sections to add (WebAssembly only)")
+	debugOutput := flag.String("debug-output", "", "also write the output with names and DWARF debug information to this file, and strip them from the -o output (WebAssembly only)")
+	crashDump := flag.Bool("crash-dump", false, "save the stack pointer, heap statistics, current export and recent output for the host when the program traps (WebAssembly only)")
+	hostCallStats := flag.Bool("host-call-stats", false, "count the calls and the bytes passed per imported function, which the host can read through the tinygo_host_call_stats export (WebAssembly only)")
+	redzone := flag.Uint("redzone", 0, "check that the given number of bytes at the bottom of the system stack stay unused, to detect stack overflows (WebAssembly only)")
+	selfcheck := flag.Bool("selfcheck", false, "export __runtime_selfcheck, which returns a bitmask of the runtime invariants that don't hold (WebAssembly only)")
+	heapSnapshot := flag.Bool("heap-snapshot", false, "export tinygo_heap_snapshot and tinygo_heap_restore, to roll back the globals and the heap without instantiating the module again (-gc=extalloc only)")
+	exports := flag.String("exports", "", "comma separated list of the only //export functions to export, so that the others can be removed (WebAssembly only)")
+	interfaceGC := flag.String("interface-gc", "", "types to include in interface method calls: safe (all types), unsafe (only types stored in an interface outside of reflect)")
+	reproducible := flag.Bool("reproducible", false, "don't store machine-specific paths in the output, so that builds on different machines are identical")
+	traceCalls := flag.Bool("trace-calls", false, "record function entry/exit in a ring buffer that can be drained by the host (WebAssembly only)")
+	reportInit := flag.Bool("report-init", false, "measure the package initializers that run at runtime, reported by the built-in WebAssembly host (WebAssembly only)")
+	hostArgs := flag.Bool("host-args", false, "read os.Args and environment variables from the host at startup, like the built-in WebAssembly host provides (wasm-unknown only)")
+	hostTicks := flag.Bool("host-ticks", false, "read the time from an instruction or fuel counter of the host, for benchmarks in deterministic environments (wasm-unknown only)")
+	hostInput := flag.Bool("host-input", false, "read os.Stdin from the host, like the built-in WebAssembly host provides (wasm-unknown only)")
+	cover := flag.Bool("cover", false, "add coverage counters, written by tinygo wasmhost to $GOCOVERDIR (WebAssembly only)")
+	coverPkg := flag.String("coverpkg", "", "comma separated list of packages to cover with -cover (default: the main package)")
+	hostHashing := flag.String("host-hashing", "", "replace hash functions with Polkadot host functions: all, blake2b, sha256 (comma separated, prefix with - to exclude)")
+	hostCrypto := flag.String("host-crypto", "", "replace signature verification with Polkadot host functions: all, ed25519 (comma separated, prefix with - to exclude)")
+	logLevel := flag.String("log-level", "", "remove runtime/hostlog calls below this level from the program: debug, info, warn, error, off")
+	softFloat := flag.String("soft-float", "", "lower floating point operations to integer soft-float routines in these packages, for bit-exact results on every host (e.g. github.com/foo/consensus/...,math) (WebAssembly only)")
+	panicChecks := flag.String("panic-checks", "", "enable or disable bounds/nil checks per package (e.g. github.com/foo/codec/...:off)")
 	printCommands := flag.Bool("x", false, "Print commands")
 	parallelism := flag.Int("p", runtime.GOMAXPROCS(0), "the number of build jobs that can run in parallel")
+	flag.IntVar(parallelism, "j", runtime.GOMAXPROCS(0), "same as -p")
 	nodebug := flag.Bool("no-debug", false, "strip debug information")
+	wasmNames := flag.String("names", "keep", "WebAssembly name section: keep, demangle, strip, exported-only")
 	ocdCommandsString := flag.String("ocd-commands", "", "OpenOCD commands, overriding target spec (can specify multiple separated by commas)")
 	ocdOutput := flag.Bool("ocd-output", false, "print OCD daemon output during debug")
 	port := flag.String("port", "", "flash port (can specify multiple candidates separated by commas)")
@@ -1452,7 +1891,7 @@ func main() {
 	skipDwarf := flag.Bool("internal-nodwarf", false, "internal flag, use -no-debug instead")
 
 	var flagJSON, flagDeps, flagTest bool
-	if command == "help" || command == "list" || command == "info" || command == "build" {
+	if command == "help" || command == "list" || command == "info" || command == "build" || command == "inspect" || command == "targets" {
 		flag.BoolVar(&flagJSON, "json", false, "print data in JSON format")
 	}
 	if command == "help" || command == "list" {
@@ -1464,6 +1903,28 @@ func main() {
 		flag.StringVar(&outpath, "o", "", "output filename")
 	}
 
+	var gcDiff bool
+	if command == "help" || command == "run" {
+		flag.BoolVar(&gcDiff, "gc-diff", false, "run the program with -gc=extalloc and -gc=conservative and compare the results (WebAssembly only)")
+	}
+	var gcMatrix string
+	if command == "help" || command == "build" {
+		flag.StringVar(&gcMatrix, "gc-matrix", "", "build once for every garbage collector in the comma separated list, appending its name to the output file (like -gc-matrix=extalloc,extalloc_leaking,conservative, where extalloc_leaking is -gc=extalloc -tags=extalloc_leaking)")
+	}
+
+	// Flags of the hidden gc-stress command.
+	var stressSeed int64
+	var stressDuration time.Duration
+	if command == "gc-stress" {
+		flag.Int64Var(&stressSeed, "seed", time.Now().UnixNano(), "random seed for the workload")
+		flag.DurationVar(&stressDuration, "duration", time.Hour, "how long to run the workload")
+	}
+
+	var checkTarget string
+	if command == "help" || command == "inspect" {
+		flag.StringVar(&checkTarget, "check-target", "", "check that the WebAssembly file satisfies the constraints of this target, like polkawasm-wasi")
+	}
+
 	var testConfig compileopts.TestConfig
 	if command == "help" || command == "test" {
 		flag.BoolVar(&testConfig.CompileOnly, "c", false, "compile the test binary but do not run it")
@@ -1476,6 +1937,9 @@ func main() {
 		flag.StringVar(&testConfig.BenchTime, "benchtime", "", "run each benchmark for duration `d`")
 		flag.BoolVar(&testConfig.BenchMem, "benchmem", false, "show memory stats for benchmarks")
 		flag.StringVar(&testConfig.Shuffle, "shuffle", "", "shuffle the order the tests and benchmarks run")
+		flag.StringVar(&testConfig.FuzzRegexp, "fuzz", "", "fuzz: run the fuzz test matching the regexp (WebAssembly only)")
+		flag.StringVar(&testConfig.FuzzTime, "fuzztime", "", "fuzz for duration `d` (default forever)")
+		flag.StringVar(&testConfig.CoverProfile, "coverprofile", "", "write a coverage profile to `file` (WebAssembly only)")
 	}
 
 	// Early command processing, before commands are interpreted by the Go flag
@@ -1488,6 +1952,10 @@ func main() {
 			os.Exit(1)
 		}
 		os.Exit(0)
+	case "wasmhost":
+		os.Exit(runWasmHost(os.Args[2:]))
+	case "covdata":
+		os.Exit(runCovData(os.Args[2:]))
 	}
 
 	flag.CommandLine.Parse(os.Args[2:])
@@ -1498,6 +1966,15 @@ func main() {
 	}
 
 	var printAllocs *regexp.Regexp
+	var printAllocsJSON bool
+	if *printAllocsString == "json" {
+		// Print all heap allocations as JSON.
+		*printAllocsString = "."
+		printAllocsJSON = true
+	} else if strings.HasPrefix(*printAllocsString, "json:") {
+		*printAllocsString = strings.TrimPrefix(*printAllocsString, "json:")
+		printAllocsJSON = true
+	}
 	if *printAllocsString != "" {
 		printAllocs, err = regexp.Compile(*printAllocsString)
 		if err != nil {
@@ -1520,7 +1997,10 @@ func main() {
 		Opt:             *opt,
 		GC:              *gc,
 		PanicStrategy:   *panicStrategy,
+		SoftFloat:       *softFloat,
+		PanicChecks:     *panicChecks,
 		Scheduler:       *scheduler,
+		Maps:            *maps,
 		Serial:          *serial,
 		Work:            *work,
 		InterpTimeout:   *interpTimeout,
@@ -1531,8 +2011,42 @@ func main() {
 		Semaphore:       make(chan struct{}, *parallelism),
 		Debug:           !*nodebug,
 		PrintSizes:      *printSize,
+		SizeBudget:      sizeBudget,
+		SizeCompare:     *sizeCompare,
 		PrintStacks:     *printStacks,
+		WhyLive:         *whyLive,
+		PrintRetained:   *printRetained,
+		PrintInits:      *printInits,
+		StrictInit:      *strictInit,
+		TraceCalls:      *traceCalls,
+		ReportInit:      *reportInit,
+		HostArgs:        *hostArgs,
+		HostTicks:       *hostTicks,
+		HostInput:       *hostInput,
+		Reproducible:    *reproducible,
+		Cover:           *cover || *coverPkg != "" || testConfig.CoverProfile != "",
+		CoverPackages:   *coverPkg,
+		MergeFunctions:  *mergeFunctions,
+		SplitCold:       *splitCold,
+		ICF:             *icf,
+		CompressData:    *compressData,
+		SectionHook:     *sectionHook,
+		DebugOutput:     *debugOutput,
+		CrashDump:       *crashDump,
+		HostCallStats:   *hostCallStats,
+		Redzone:         uint32(*redzone),
+		Selfcheck:       *selfcheck,
+		HeapSnapshot:    *heapSnapshot,
+		Exports:         *exports,
+		InterfaceGC:     *interfaceGC,
+		LowerFmt:        *lowerFmt,
+		ConstantTime:    *constantTime,
+		FPDeterministic: *fpDeterministic,
+		HostHashing:     *hostHashing,
+		HostCrypto:      *hostCrypto,
+		LogLevel:        *logLevel,
 		PrintAllocs:     printAllocs,
+		PrintAllocsJSON: printAllocsJSON,
 		Tags:            []string(tags),
 		TestConfig:      testConfig,
 		GlobalValues:    globalVarValues,
@@ -1543,6 +2057,11 @@ func main() {
 		Monitor:         *monitor,
 		BaudRate:        *baudrate,
 		Timeout:         *timeout,
+		WasmNames:       *wasmNames,
+		YieldPoints:     uint32(*yieldPoints),
+		InlineBudget:    *inlineBudget,
+		FunctionOrder:   *functionOrder,
+		FunctionProfile: *functionProfile,
 	}
 	if *printCommands {
 		options.PrintCommands = printCommand
@@ -1583,6 +2102,11 @@ func main() {
 			options.Target = "wasm"
 		}
 
+		if gcMatrix != "" {
+			err := BuildGCMatrix(pkgName, outpath, gcMatrix, options)
+			handleCompilerError(err)
+			break
+		}
 		err := Build(pkgName, outpath, options)
 		handleCompilerError(err)
 	case "build-library":
@@ -1650,6 +2174,11 @@ func main() {
 			os.Exit(1)
 		}
 		pkgName := filepath.ToSlash(flag.Arg(0))
+		if gcDiff {
+			err := RunGCDiff(pkgName, options, flag.Args()[1:])
+			handleCompilerError(err)
+			break
+		}
 		err := Run(pkgName, options, flag.Args()[1:])
 		handleCompilerError(err)
 	case "test":
@@ -1672,6 +2201,11 @@ func main() {
 			os.Exit(1)
 		}
 
+		if testConfig.FuzzRegexp != "" && len(explicitPkgNames) > 1 {
+			fmt.Println("cannot use -fuzz flag with multiple packages")
+			os.Exit(1)
+		}
+
 		fail := make(chan struct{}, 1)
 		var wg sync.WaitGroup
 		bufs := make([]testOutputBuf, len(explicitPkgNames))
@@ -1748,19 +2282,98 @@ func main() {
 			fmt.Printf("%-20s %4s:%4s %s\n", s.Name, s.VID, s.PID, s.Target)
 		}
 	case "targets":
-		specs, err := compileopts.GetTargetSpecs()
-		if err != nil {
-			fmt.Fprintln(os.Stderr, "could not list targets:", err)
-			os.Exit(1)
-			return
+		var specs map[string]*compileopts.TargetSpec
+		if flag.NArg() != 0 {
+			// Only describe the given targets, which may also be custom
+			// target files.
+			specs = make(map[string]*compileopts.TargetSpec)
+			for _, name := range flag.Args() {
+				spec, err := compileopts.LoadTarget(&compileopts.Options{Target: name})
+				if err != nil {
+					fmt.Fprintln(os.Stderr, "could not load target:", err)
+					os.Exit(1)
+				}
+				specs[name] = spec
+			}
+		} else {
+			var err error
+			specs, err = compileopts.GetTargetSpecs()
+			if err != nil {
+				fmt.Fprintln(os.Stderr, "could not list targets:", err)
+				os.Exit(1)
+				return
+			}
 		}
 		names := []string{}
 		for key := range specs {
 			names = append(names, key)
 		}
 		sort.Strings(names)
-		for _, name := range names {
-			fmt.Println(name)
+		if flagJSON {
+			// Describe the defaults of every target, so that build systems
+			// don't need to parse the target files and resolve inheritance
+			// themselves.
+			type targetInfo struct {
+				Name            string   `json:"name"`
+				LLVMTriple      string   `json:"llvm_triple"`
+				GOOS            string   `json:"goos"`
+				GOARCH          string   `json:"goarch"`
+				CPU             string   `json:"cpu,omitempty"`
+				Features        []string `json:"features"`
+				BuildTags       []string `json:"build_tags"`
+				GC              string   `json:"garbage_collector"`
+				Scheduler       string   `json:"scheduler"`
+				Libc            string   `json:"libc,omitempty"`
+				Linker          string   `json:"linker"`
+				Emulator        string   `json:"emulator,omitempty"`
+				FlashMethod     string   `json:"flash_method,omitempty"`
+				RequiredExports []string `json:"required_exports"`
+				ExtallocMalloc  string   `json:"extalloc_malloc,omitempty"`
+				ExtallocFree    string   `json:"extalloc_free,omitempty"`
+				WasmPasses      []string `json:"wasm_passes"`
+			}
+			infos := []targetInfo{}
+			for _, name := range names {
+				spec := specs[name]
+				config := &compileopts.Config{Options: &compileopts.Options{}, Target: spec}
+				features := []string{}
+				if spec.Features != "" {
+					features = strings.Split(spec.Features, ",")
+				}
+				info := targetInfo{
+					Name:            name,
+					LLVMTriple:      spec.Triple,
+					GOOS:            spec.GOOS,
+					GOARCH:          spec.GOARCH,
+					CPU:             spec.CPU,
+					Features:        features,
+					BuildTags:       spec.BuildTags,
+					GC:              config.GC(),
+					Scheduler:       config.Scheduler(),
+					Libc:            spec.Libc,
+					Linker:          spec.Linker,
+					Emulator:        spec.Emulator,
+					FlashMethod:     spec.FlashMethod,
+					RequiredExports: spec.RequiredExports,
+					ExtallocMalloc:  spec.ExtallocMalloc,
+					ExtallocFree:    spec.ExtallocFree,
+					WasmPasses:      spec.WasmPasses,
+				}
+				// Use empty lists instead of null, to make the output easier
+				// to consume.
+				for _, list := range []*[]string{&info.BuildTags, &info.RequiredExports, &info.WasmPasses} {
+					if *list == nil {
+						*list = []string{}
+					}
+				}
+				infos = append(infos, info)
+			}
+			json, _ := json.MarshalIndent(infos, "", "  ")
+			fmt.Println(string(json))
+		} else {
+			for _, name := range names {
+				fmt.Println(name)
+			}
 		}
 	case "info":
 		if flag.NArg() == 1 {
@@ -1850,6 +2463,49 @@ func main() {
 			fmt.Fprintln(os.Stderr, "failed to run `go list`:", err)
 			os.Exit(1)
 		}
+	case "gc-stress":
+		// Hidden command to validate changes to the GC: it runs a randomized
+		// allocation workload for a long time, with all GC assertions enabled.
+		// It uses the polkawasm-wasi target by default, so that the extalloc
+		// GC is tested.
+		if options.Target == "" {
+			options.Target = "polkawasm-wasi"
+		}
+		options.Tags = append(options.Tags, "runtime_asserts")
+		config, err := builder.NewConfig(options)
+		handleCompilerError(err)
+		args := []string{strconv.FormatInt(stressSeed, 10), stressDuration.String()}
+		_, err = buildAndRun("examples/gcstress", config, os.Stdout, args, nil, 0, func(cmd *exec.Cmd, result builder.BuildResult) error {
+			return cmd.Run()
+		})
+		handleCompilerError(err)
+	case "inspect":
+		if flag.NArg() != 1 {
+			fmt.Fprintln(os.Stderr, "inspect expects a single WebAssembly file")
+			usage(command)
+			os.Exit(1)
+		}
+		info, err := builder.InspectWasm(flag.Arg(0))
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "error:", err)
+			os.Exit(1)
+		}
+		if checkTarget != "" {
+			spec, err := compileopts.LoadTarget(&compileopts.Options{Target: checkTarget})
+			handleCompilerError(err)
+			if err := builder.CheckWasmTarget(info, spec); err != nil {
+				fmt.Fprintf(os.Stderr, "%s does not satisfy the constraints of target %s:\n%s\n", flag.Arg(0), checkTarget, err)
+				os.Exit(1)
+			}
+			fmt.Printf("%s satisfies the constraints of target %s\n", flag.Arg(0), checkTarget)
+			return
+		}
+		if flagJSON {
+			data, _ := json.MarshalIndent(info, "", "  ")
+			fmt.Println(string(data))
+		} else {
+			fmt.Print(info)
+		}
 	case "clean":
 		// remove cache directory
 		err := os.RemoveAll(goenv.Get("GOCACHE"))
@@ -59,10 +59,12 @@ func TestBuild(t *testing.T) {
 		"cgo/",
 		"channel.go",
 		"embed/",
+		"eventqueue.go",
 		"float.go",
 		"gc.go",
 		"generics.go",
 		"goroutines.go",
+		"hostbuf.go",
 		"init.go",
 		"init_multi.go",
 		"interface.go",
@@ -72,6 +74,7 @@ func TestBuild(t *testing.T) {
 		"oldgo/",
 		"print.go",
 		"reflect.go",
+		"reflectcodec/",
 		"slice.go",
 		"sort.go",
 		"stdlib.go",
@@ -179,7 +182,114 @@ func TestBuild(t *testing.T) {
 			t.Parallel()
 			runPlatTests(optionsFromTarget("wasi", sema), tests, t)
 		})
+		t.Run("WASI-extalloc", func(t *testing.T) {
+			t.Parallel()
+			options := optionsFromTarget("wasi", sema)
+			options.GC = "extalloc"
+			runPlatTests(options, tests, t)
+		})
+		t.Run("WASI-fp-deterministic", func(t *testing.T) {
+			t.Parallel()
+			// The results must be the same in every WebAssembly runtime, so
+			// check the output of wasmtime (the emulator of the wasi target)
+			// and of wasmer against the same expected output.
+			wasmer := t.TempDir() + "/wasi-wasmer.json"
+			err := os.WriteFile(wasmer, []byte(`{"inherits": ["wasi"], "replace": ["emulator"], "emulator": "wasmer run {}"}`), 0o666)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, target := range []string{"wasi", wasmer} {
+				options := optionsFromTarget(target, sema)
+				options.FPDeterministic = true
+				emulator := "wasmtime"
+				if target == wasmer {
+					emulator = "wasmer"
+				}
+				t.Run(emulator, func(t *testing.T) {
+					t.Parallel()
+					emuCheck(t, options)
+					runTest("fpdeterministic.go", options, t, nil, nil)
+				})
+			}
+		})
 	}
+
+	// Run the GC conformance tests against all the GCs that can be used with
+	// memory from a Polkadot-style host allocator.
+	t.Run("GC", func(t *testing.T) {
+		t.Parallel()
+		for _, gc := range []string{"extalloc", "custom", "leaking"} {
+			gc := gc
+			t.Run(gc, func(t *testing.T) {
+				t.Parallel()
+				options := optionsFromTarget("polkawasm-wasi", sema)
+				options.GC = gc
+				runTest("gc/", options, t, nil, nil)
+			})
+		}
+
+		// Check the heap limit of the extalloc GC. The program ends by
+		// running out of memory.
+		t.Run("extalloc-limit", func(t *testing.T) {
+			t.Parallel()
+			target := t.TempDir() + "/polkawasm-wasi-limit.json"
+			err := os.WriteFile(target, []byte(`{"inherits": ["polkawasm-wasi"], "extalloc-limit": 1048576}`), 0o666)
+			if err != nil {
+				t.Fatal(err)
+			}
+			runTestFailing("gc/limit/", optionsFromTarget(target, sema), t)
+		})
+	})
+
+	// Run the tests that are specific to the Polkadot targets, like a
+	// reflection-driven codec as SCALE codec libraries use.
+	t.Run("Polkadot", func(t *testing.T) {
+		t.Parallel()
+		t.Run("polkawasm-wasi", func(t *testing.T) {
+			t.Parallel()
+			runTest("reflectcodec/", optionsFromTarget("polkawasm-wasi", sema), t, nil, nil)
+		})
+		t.Run("polkawasm", func(t *testing.T) {
+			t.Parallel()
+			// Only test binaries run main on wasm-unknown targets, so run it
+			// from the TestMain of the test binary.
+			options := optionsFromTarget("polkawasm", sema)
+			options.TestConfig.CompileTestBinary = true
+			runTest("reflectcodec/", options, t, nil, nil)
+		})
+
+		// Bounds check panics include the index or bounds and the source
+		// position in debug builds.
+		for _, name := range []string{"boundsindex.go", "boundsslice.go"} {
+			name := name
+			t.Run(name, func(t *testing.T) {
+				t.Parallel()
+				runTestFailing(name, optionsFromTarget("polkawasm-wasi", sema), t)
+			})
+		}
+
+		// Files are kept in memory with the memfs build tag on polkawasm. The
+		// tag has no effect on polkawasm-wasi, where the same program must
+		// behave the same with the files in the host /tmp directory.
+		t.Run("memfs", func(t *testing.T) {
+			t.Parallel()
+			options := optionsFromTarget("polkawasm", sema)
+			options.Tags = []string{"memfs"}
+			options.TestConfig.CompileTestBinary = true
+			runTest("memfs/", options, t, nil, nil)
+		})
+		t.Run("memfs-wasi", func(t *testing.T) {
+			t.Parallel()
+			target := t.TempDir() + "/polkawasm-wasi-tmp.json"
+			err := os.WriteFile(target, []byte(`{"inherits": ["polkawasm-wasi"], "emulator": "wazero -dir={tmpDir}::/tmp {}"}`), 0o666)
+			if err != nil {
+				t.Fatal(err)
+			}
+			options := optionsFromTarget(target, sema)
+			options.Tags = []string{"memfs"}
+			runTest("memfs/", options, t, nil, nil)
+		})
+	})
 }
 
 func runPlatTests(options compileopts.Options, tests []string, t *testing.T) {
@@ -250,6 +360,20 @@ func runPlatTests(options compileopts.Options, tests []string, t *testing.T) {
 			runTest("alias.go", options, t, nil, nil)
 		})
 	}
+	t.Run("lowerfmt.go", func(t *testing.T) {
+		t.Parallel()
+		options := compileopts.Options(options)
+		options.LowerFmt = true
+		runTest("lowerfmt.go", options, t, nil, nil)
+	})
+	if isWebAssembly {
+		t.Run("map.go-maps-compact", func(t *testing.T) {
+			t.Parallel()
+			options := compileopts.Options(options)
+			options.Maps = "compact"
+			runTest("map.go", options, t, nil, nil)
+		})
+	}
 	if options.Target == "" || options.Target == "wasi" {
 		t.Run("filesystem.go", func(t *testing.T) {
 			t.Parallel()
@@ -279,6 +403,10 @@ func emuCheck(t *testing.T, options compileopts.Options) {
 	}
 	if spec.Emulator != "" {
 		emulatorCommand := strings.SplitN(spec.Emulator, " ", 2)[0]
+		if emulatorCommand == "wazero" {
+			// Built into TinyGo, see TestMain.
+			return
+		}
 		_, err := exec.LookPath(emulatorCommand)
 		if err != nil {
 			if errors.Is(err, exec.ErrNotFound) {
@@ -330,7 +458,18 @@ func runTest(name string, options compileopts.Options, t *testing.T, cmdArgs, en
 	runTestWithConfig(name, t, options, cmdArgs, environmentVars)
 }
 
+// runTestFailing is like runTest, for a program that is expected to exit with
+// an error (for example because it panics). Its output must still match the
+// expected output.
+func runTestFailing(name string, options compileopts.Options, t *testing.T) {
+	checkTestOutput(name, t, options, nil, nil, true)
+}
+
 func runTestWithConfig(name string, t *testing.T, options compileopts.Options, cmdArgs, environmentVars []string) {
+	checkTestOutput(name, t, options, cmdArgs, environmentVars, false)
+}
+
+func checkTestOutput(name string, t *testing.T, options compileopts.Options, cmdArgs, environmentVars []string, expectExitError bool) {
 	// Get the expected output for this test.
 	// Note: not using filepath.Join as it strips the path separator at the end
 	// of the path.
@@ -355,8 +494,15 @@ func runTestWithConfig(name string, t *testing.T, options compileopts.Options, c
 
 	// Build the test binary.
 	stdout := &bytes.Buffer{}
+	exitError := false
 	_, err = buildAndRun(pkgName, config, stdout, cmdArgs, environmentVars, time.Minute, func(cmd *exec.Cmd, result builder.BuildResult) error {
-		return cmd.Run()
+		err := cmd.Run()
+		var exitErr *exec.ExitError
+		if expectExitError && errors.As(err, &exitErr) {
+			exitError = true
+			return nil
+		}
+		return err
 	})
 	if err != nil {
 		printCompilerError(t.Log, err)
@@ -380,12 +526,26 @@ func runTestWithConfig(name string, t *testing.T, options compileopts.Options, c
 		re := regexp.MustCompile(`\([0-9]\.[0-9][0-9]s\)`)
 		actual = re.ReplaceAllLiteral(actual, []byte{'(', '0', '.', '0', '0', 's', ')'})
 	}
+	if name == "gc/limit/" {
+		// Strip the heap statistics printed when running out of memory.
+		re := regexp.MustCompile(`extalloc: [0-9]+ bytes allocated, [0-9]+ bytes in use by [0-9]+ objects`)
+		actual = re.ReplaceAllLiteral(actual, []byte("extalloc: N bytes allocated, N bytes in use by N objects"))
+	}
+
+	if strings.HasPrefix(name, "bounds") {
+		// Strip the directory of the source position in bounds check panics.
+		re := regexp.MustCompile(`panic: runtime error at [^ ]*[/\\]testdata[/\\]`)
+		actual = re.ReplaceAllLiteral(actual, []byte("panic: runtime error at testdata/"))
+	}
 
 	// Check whether the command ran successfully.
 	fail := false
 	if err != nil {
 		t.Log("failed to run:", err)
 		fail = true
+	} else if expectExitError && !exitError {
+		t.Log("expected the program to exit with an error")
+		fail = true
 	} else if !bytes.Equal(expected, actual) {
 		t.Logf("output did not match (expected %d bytes, got %d bytes):", len(expected), len(actual))
 		fail = true
@@ -594,6 +754,42 @@ func TestGetListOfPackages(t *testing.T) {
 	}
 }
 
+func TestGCMatrixOptions(t *testing.T) {
+	options := &compileopts.Options{
+		Opt:  "z",
+		Tags: []string{"foo"},
+	}
+	tests := []struct {
+		gc   string
+		want string
+		tags []string
+	}{
+		{"extalloc", "extalloc", []string{"foo"}},
+		{"extalloc_leaking", "extalloc", []string{"foo", "extalloc_leaking"}},
+		{"conservative", "conservative", []string{"foo"}},
+	}
+	for _, tc := range tests {
+		gcOptions, err := gcMatrixOptions(options, tc.gc)
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", tc.gc, err)
+			continue
+		}
+		if gcOptions.GC != tc.want {
+			t.Errorf("%s: expected -gc=%s, got -gc=%s", tc.gc, tc.want, gcOptions.GC)
+		}
+		if !reflect.DeepEqual(gcOptions.Tags, tc.tags) {
+			t.Errorf("%s: expected tags %v, got %v", tc.gc, tc.tags, gcOptions.Tags)
+		}
+	}
+	if !reflect.DeepEqual(options.Tags, []string{"foo"}) {
+		t.Errorf("options were modified: tags %v", options.Tags)
+	}
+
+	if _, err := gcMatrixOptions(options, "bogus"); err == nil {
+		t.Error("expected an error for -gc-matrix=bogus")
+	}
+}
+
 // This TestMain is necessary because TinyGo may also be invoked to run certain
 // LLVM tools in a separate process. Not capturing these invocations would lead
 // to recursive tests.
@@ -608,6 +804,9 @@ func TestMain(m *testing.M) {
 				os.Exit(1)
 			}
 			os.Exit(0)
+		case "wasmhost":
+			// Run a WebAssembly binary for the "wazero" emulator.
+			os.Exit(runWasmHost(os.Args[2:]))
 		}
 	}
 
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/tetratelabs/wazero/api"
+	"github.com/tinygo-org/tinygo/builder"
+	"github.com/tinygo-org/tinygo/wasmhost"
+)
+
+func TestRedzone(t *testing.T) {
+	t.Parallel()
+
+	// The program uses about two thirds of the 64kB system stack in an
+	// exported function, see testdata/redzone.go. This fits in the stack, but
+	// not when the bottom half is a redzone.
+	for _, redzone := range []uint32{0, 32768} {
+		options := optionsFromTarget("polkawasm", sema)
+		options.Redzone = redzone
+		config, err := builder.NewConfig(&options)
+		if err != nil {
+			t.Fatal(err)
+		}
+		result, err := builder.Build("testdata/redzone.go", ".wasm", t.TempDir(), config)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		callExport := func(ctx context.Context, mod api.Module, stack []uint64) {
+			if _, err := mod.ExportedFunction("overflow").Call(ctx); err != nil {
+				panic(err)
+			}
+		}
+		var stdout bytes.Buffer
+		_, err = wasmhost.Run(context.Background(), result.Binary, wasmhost.Config{
+			Stdout:    &stdout,
+			Functions: []wasmhost.HostFunction{{Name: "call_export", Func: callExport}},
+		})
+		if redzone == 0 {
+			if err != nil {
+				t.Error("unexpected error without a redzone:", err)
+			}
+		} else {
+			var trapErr *wasmhost.TrapError
+			if !errors.As(err, &trapErr) {
+				t.Fatal("expected a trap error, got:", err)
+			}
+			if trapErr.Reason != wasmhost.TrapStackOverflow || trapErr.Aux >= redzone {
+				t.Errorf("unexpected trap: %s (address %d)", trapErr, trapErr.Aux)
+			}
+		}
+		if !bytes.HasPrefix(stdout.Bytes(), []byte("recursed: true\n")) {
+			t.Errorf("unexpected output with -redzone=%d:\n%s", redzone, stdout.String())
+		}
+	}
+
+	// The redzone must leave some of the stack to the program.
+	options := optionsFromTarget("polkawasm", sema)
+	options.Redzone = 65536
+	_, err := builder.NewConfig(&options)
+	if err == nil || err.Error() != "-redzone=65536 must be smaller than the system stack of 65536 bytes" {
+		t.Error("unexpected error for a redzone as big as the stack:", err)
+	}
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/tetratelabs/wazero/api"
+	"github.com/tinygo-org/tinygo/builder"
+	"github.com/tinygo-org/tinygo/wasmhost"
+)
+
+func TestReentry(t *testing.T) {
+	t.Parallel()
+
+	// Build a program of which an imported function calls an exported
+	// function (the export numbered by its parameter), see
+	// testdata/reentry.go.
+	options := optionsFromTarget("polkawasm", sema)
+	config, err := builder.NewConfig(&options)
+	if err != nil {
+		t.Fatal(err)
+	}
+	result, err := builder.Build("testdata/reentry.go", ".wasm", t.TempDir(), config)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// When the nested call traps, the outer exported function is still
+	// running. The host must still be able to find out why the program
+	// trapped.
+	exports := []string{"", "first", "second"}
+	var exitCode, trapInfo []uint64
+	var exitCodeErr, trapInfoErr error
+	callExport := func(ctx context.Context, mod api.Module, stack []uint64) {
+		_, err := mod.ExportedFunction(exports[uint32(stack[0])]).Call(ctx)
+		if err != nil && exitCode == nil && exitCodeErr == nil {
+			exitCode, exitCodeErr = mod.ExportedFunction("tinygo_exit_code").Call(ctx)
+			trapInfo, trapInfoErr = mod.ExportedFunction("tinygo_trap_info").Call(ctx)
+		}
+		if err != nil {
+			panic(err)
+		}
+	}
+	var stdout bytes.Buffer
+	_, err = wasmhost.Run(context.Background(), result.Binary, wasmhost.Config{
+		Stdout: &stdout,
+		Functions: []wasmhost.HostFunction{
+			{Name: "call_export", Params: []api.ValueType{api.ValueTypeI32}, Func: callExport},
+		},
+	})
+
+	var trapErr *wasmhost.TrapError
+	if !errors.As(err, &trapErr) {
+		t.Fatal("expected a trap error, got:", err)
+	}
+	if trapErr.Reason != wasmhost.TrapReentry {
+		t.Errorf("unexpected trap reason: %s", trapErr)
+	}
+	if exitCodeErr != nil || len(exitCode) != 1 || int32(exitCode[0]) != -1 {
+		t.Errorf("could not read the exit code after the trap: %v %v", exitCode, exitCodeErr)
+	}
+	if trapInfoErr != nil || len(trapInfo) != 1 {
+		t.Errorf("could not read the trap information after the trap: %v %v", trapInfo, trapInfoErr)
+	}
+	if !strings.HasPrefix(stdout.String(), "first\n") || strings.Contains(stdout.String(), "unreachable") {
+		t.Errorf("unexpected output:\n%s", stdout.String())
+	}
+}
@@ -0,0 +1,131 @@
+package scalegen
+
+import (
+	"bytes"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// The types below are encoded and decoded by TestRoundTrip, with the methods in
+// roundtrip_scale_test.go. Pass -update to go test to regenerate that file.
+
+type Hash [4]byte
+
+//tinygo:scale
+type Header struct {
+	ParentHash Hash
+	Number     uint32 `scale:"compact"`
+	Digest     []DigestItem
+}
+
+//tinygo:scale
+type DigestItem struct {
+	Engine  [2]byte
+	Payload []byte
+}
+
+//tinygo:scale
+type Transfer struct {
+	Header
+	To       *Hash
+	Amount   uint64
+	Fee      int16
+	Memo     string
+	Approved bool
+	Tags     [][]uint16
+	Nonce    uint16 `scale:"compact"`
+	Big      uint64 `scale:"compact"`
+	cache    []byte
+}
+
+func TestRoundTripGenerated(t *testing.T) {
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, "roundtrip_test.go", nil, parser.ParseComments)
+	if err != nil {
+		t.Fatal("could not parse Go source file:", err)
+	}
+	result, err := Generate(fset, []*ast.File{f})
+	if err != nil {
+		t.Fatal("could not generate code:", err)
+	}
+	const outPath = "roundtrip_scale_test.go"
+	if *flagUpdate {
+		err := os.WriteFile(outPath, result, 0666)
+		if err != nil {
+			t.Error("could not write output file:", err)
+		}
+		return
+	}
+	expected, err := os.ReadFile(outPath)
+	if err != nil {
+		t.Fatal("could not read expected output:", err)
+	}
+	if strings.ReplaceAll(string(expected), "\r\n", "\n") != string(result) {
+		t.Errorf("output did not match %s, run go test -update", outPath)
+	}
+}
+
+func TestRoundTrip(t *testing.T) {
+	// Check the encoding against a known encoding.
+	header := Header{
+		ParentHash: Hash{1, 2, 3, 4},
+		Number:     69,
+		Digest:     []DigestItem{{Engine: [2]byte{'a', 'b'}, Payload: []byte{9}}},
+	}
+	encoded := header.EncodeScale(nil)
+	expected := []byte{1, 2, 3, 4, 0x15, 0x01, 0x04, 'a', 'b', 0x04, 9}
+	if !bytes.Equal(encoded, expected) {
+		t.Errorf("unexpected encoding of the header:\nexpected: %x\nactual:   %x", expected, encoded)
+	}
+
+	// Encode and decode a value with all kinds of fields, with the compact
+	// integers at the boundaries of their encoding modes.
+	for _, big := range []uint64{0, 1<<6 - 1, 1 << 6, 1<<14 - 1, 1 << 14, 1<<30 - 1, 1 << 30, 1<<32 - 1, 1 << 32, 1<<64 - 1} {
+		value := Transfer{
+			Header:   header,
+			To:       &Hash{5, 6, 7, 8},
+			Amount:   1<<64 - 2,
+			Fee:      -2,
+			Memo:     "hello",
+			Approved: true,
+			Tags:     [][]uint16{{1, 0xffff}, {}},
+			Nonce:    1<<16 - 1,
+			Big:      big,
+			cache:    []byte{1},
+		}
+		encoded := value.EncodeScale([]byte{0xaa})
+		var decoded Transfer
+		rest, err := decoded.DecodeScale(append(encoded[1:], 0xbb))
+		if err != nil {
+			t.Errorf("could not decode %d: %v", big, err)
+			continue
+		}
+		if !bytes.Equal(rest, []byte{0xbb}) {
+			t.Errorf("unexpected rest after decoding %d: %x", big, rest)
+		}
+		value.cache = nil // not encoded
+		if !reflect.DeepEqual(decoded, value) {
+			t.Errorf("decoded value doesn't match:\nexpected: %+v\nactual:   %+v", value, decoded)
+		}
+
+		// Truncated input must be rejected.
+		for i := 1; i < len(encoded); i++ {
+			var decoded Transfer
+			if _, err := decoded.DecodeScale(encoded[1:i]); err != errScaleShort {
+				t.Errorf("decoding %d of %d bytes: expected %v, got %v", i-1, len(encoded)-1, errScaleShort, err)
+			}
+		}
+	}
+
+	// Compact integers that don't fit in the field must be rejected instead
+	// of truncated.
+	var decoded Header
+	if _, err := decoded.DecodeScale(scaleAppendCompact([]byte{1, 2, 3, 4}, 1<<32)); err != errScaleInvalid {
+		t.Errorf("decoding an out of range compact integer: expected %v, got %v", errScaleInvalid, err)
+	}
+}
@@ -0,0 +1,503 @@
+// Package scalegen generates SCALE codec methods for Go struct types.
+//
+// Struct types are opted in with a //tinygo:scale comment in their
+// documentation. For these types, an EncodeScale and DecodeScale method are
+// generated that encode the exported fields (including embedded fields of an
+// exported type) in declaration order without using reflection:
+//
+//	func (v *T) EncodeScale(buf []byte) []byte
+//	func (v *T) DecodeScale(buf []byte) ([]byte, error)
+//
+// Struct field tags can be used to customize the encoding of a field:
+//
+//	scale:"-"        skip this field
+//	scale:"compact"  use the compact encoding for an unsigned integer field
+//
+// Fields of other named types (that are not integers, strings etc.) must
+// implement the same two methods.
+//
+// The loader calls Generate for every package it parses and adds the generated
+// file to the package, so the methods are derived at build time without a
+// go:generate step. The generated file doesn't import any packages, as it can
+// only use the packages that the package itself imports.
+package scalegen
+
+import (
+	"bytes"
+	"fmt"
+	"go/ast"
+	"go/format"
+	"go/token"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// Generate returns the source of a Go file with SCALE codec methods for all
+// struct types in the given files that are marked with //tinygo:scale. It
+// returns nil if there are no such types.
+func Generate(fset *token.FileSet, files []*ast.File) ([]byte, error) {
+	g := &generator{
+		fset:  fset,
+		types: make(map[string]*ast.TypeSpec),
+	}
+	var marked []*ast.TypeSpec
+	for _, file := range files {
+		for _, decl := range file.Decls {
+			decl, ok := decl.(*ast.GenDecl)
+			if !ok || decl.Tok != token.TYPE {
+				continue
+			}
+			for _, spec := range decl.Specs {
+				spec := spec.(*ast.TypeSpec)
+				g.types[spec.Name.Name] = spec
+				if !hasScaleDirective(spec.Doc) && !(len(decl.Specs) == 1 && hasScaleDirective(decl.Doc)) {
+					continue
+				}
+				if _, ok := spec.Type.(*ast.StructType); !ok || spec.TypeParams != nil {
+					return nil, fmt.Errorf("%s: //tinygo:scale can only be used on non-generic struct types", fset.Position(spec.Pos()))
+				}
+				marked = append(marked, spec)
+			}
+		}
+	}
+	if len(marked) == 0 {
+		return nil, nil
+	}
+	for _, spec := range marked {
+		g.marked = append(g.marked, spec.Name.Name)
+	}
+
+	g.printf("// Code generated by tinygo scalegen. DO NOT EDIT.\n\n")
+	g.printf("package %s\n\n", files[0].Name.Name)
+	for _, spec := range marked {
+		if err := g.generateType(spec); err != nil {
+			return nil, err
+		}
+	}
+	g.printf("%s", helpers)
+
+	return format.Source(g.buf.Bytes())
+}
+
+// hasScaleDirective returns whether the comment group contains a
+// //tinygo:scale line.
+func hasScaleDirective(doc *ast.CommentGroup) bool {
+	if doc == nil {
+		return false
+	}
+	for _, comment := range doc.List {
+		if strings.TrimSpace(comment.Text) == "//tinygo:scale" {
+			return true
+		}
+	}
+	return false
+}
+
+type generator struct {
+	fset   *token.FileSet
+	types  map[string]*ast.TypeSpec // all types declared in the package
+	marked []string                 // types that get generated methods
+	buf    bytes.Buffer
+	depth  int // loop nesting depth, for unique loop variable names
+}
+
+func (g *generator) printf(format string, args ...interface{}) {
+	fmt.Fprintf(&g.buf, format, args...)
+}
+
+// field is a single struct field that is part of the encoding.
+type field struct {
+	name    string
+	typ     ast.Expr
+	compact bool
+}
+
+// generateType generates the EncodeScale and DecodeScale methods for a single
+// struct type.
+func (g *generator) generateType(spec *ast.TypeSpec) error {
+	var fields []field
+	for _, f := range spec.Type.(*ast.StructType).Fields.List {
+		compact := false
+		if f.Tag != nil {
+			tag, err := strconv.Unquote(f.Tag.Value)
+			if err != nil {
+				return fmt.Errorf("%s: %w", g.fset.Position(f.Tag.Pos()), err)
+			}
+			switch reflect.StructTag(tag).Get("scale") {
+			case "":
+			case "-":
+				continue
+			case "compact":
+				compact = true
+			default:
+				return fmt.Errorf("%s: unknown scale tag %q", g.fset.Position(f.Tag.Pos()), tag)
+			}
+		}
+		if len(f.Names) == 0 {
+			// Embedded field, which is encoded like a field with the name of
+			// its type.
+			name, err := g.embeddedName(f.Type)
+			if err != nil {
+				return err
+			}
+			if token.IsExported(name) {
+				fields = append(fields, field{name: name, typ: f.Type, compact: compact})
+			}
+			continue
+		}
+		for _, name := range f.Names {
+			if !name.IsExported() {
+				continue
+			}
+			fields = append(fields, field{name: name.Name, typ: f.Type, compact: compact})
+		}
+	}
+
+	name := spec.Name.Name
+	g.printf("// EncodeScale appends the SCALE encoding of v to buf.\n")
+	g.printf("func (v *%s) EncodeScale(buf []byte) []byte {\n", name)
+	for _, f := range fields {
+		if err := g.encode("v."+f.name, f.typ, f.compact); err != nil {
+			return err
+		}
+	}
+	g.printf("return buf\n}\n\n")
+
+	g.printf("// DecodeScale decodes v from the start of buf and returns the rest of buf.\n")
+	g.printf("func (v *%s) DecodeScale(buf []byte) ([]byte, error) {\n", name)
+	if len(fields) != 0 {
+		g.printf("var err error\n_ = err\n")
+	}
+	for _, f := range fields {
+		if err := g.decode("v."+f.name, f.typ, f.compact); err != nil {
+			return err
+		}
+	}
+	g.printf("return buf, nil\n}\n\n")
+	return nil
+}
+
+// embeddedName returns the field name of an embedded field of the given type.
+func (g *generator) embeddedName(typ ast.Expr) (string, error) {
+	if star, ok := typ.(*ast.StarExpr); ok {
+		typ = star.X
+	}
+	switch typ := typ.(type) {
+	case *ast.Ident:
+		return typ.Name, nil
+	case *ast.SelectorExpr:
+		return typ.Sel.Name, nil
+	default:
+		return "", fmt.Errorf("%s: unsupported embedded field in SCALE codec", g.fset.Position(typ.Pos()))
+	}
+}
+
+// Sizes of the fixed size integer types.
+var intSizes = map[string]int{
+	"uint8": 1, "byte": 1, "int8": 1,
+	"uint16": 2, "int16": 2,
+	"uint32": 4, "int32": 4,
+	"uint64": 8, "int64": 8,
+}
+
+// kind determines how a value of the given type is encoded. It returns the
+// name of a basic type ("bool", "string", "uint32", etc.), "method" for types
+// that implement the codec methods themselves, or "" for composite types that
+// are described by the returned expression.
+func (g *generator) kind(typ ast.Expr) (string, ast.Expr, error) {
+	switch typ := typ.(type) {
+	case *ast.Ident:
+		if typ.Name == "bool" || typ.Name == "string" || intSizes[typ.Name] != 0 {
+			return typ.Name, nil, nil
+		}
+		if typ.Name == "int" || typ.Name == "uint" || typ.Name == "uintptr" {
+			return "", nil, fmt.Errorf("%s: %s has no fixed size, use a sized integer type", g.fset.Position(typ.Pos()), typ.Name)
+		}
+		spec := g.types[typ.Name]
+		if spec == nil || g.isMarked(typ.Name) {
+			return "method", nil, nil
+		}
+		if _, ok := spec.Type.(*ast.StructType); ok {
+			return "method", nil, nil
+		}
+		// Use the underlying type of this named type.
+		kind, underlying, err := g.kind(spec.Type)
+		if underlying == nil {
+			underlying = spec.Type
+		}
+		return kind, underlying, err
+	case *ast.SelectorExpr:
+		return "method", nil, nil
+	case *ast.ArrayType, *ast.StarExpr:
+		return "", typ, nil
+	default:
+		return "", nil, fmt.Errorf("%s: unsupported type in SCALE codec", g.fset.Position(typ.Pos()))
+	}
+}
+
+func (g *generator) isMarked(name string) bool {
+	for _, marked := range g.marked {
+		if marked == name {
+			return true
+		}
+	}
+	return false
+}
+
+// typeString returns the Go source for the given type expression.
+func (g *generator) typeString(typ ast.Expr) string {
+	var buf bytes.Buffer
+	format.Node(&buf, g.fset, typ)
+	return buf.String()
+}
+
+// loopVar returns a new loop variable name, to be released with endLoop.
+func (g *generator) loopVar() string {
+	g.depth++
+	if g.depth == 1 {
+		return "i"
+	}
+	return "i" + strconv.Itoa(g.depth)
+}
+
+func (g *generator) endLoop() {
+	g.depth--
+}
+
+// encode generates code to append the encoding of expr (of type typ) to buf.
+func (g *generator) encode(expr string, typ ast.Expr, compact bool) error {
+	kind, composite, err := g.kind(typ)
+	if err != nil {
+		return err
+	}
+	if compact {
+		if intSizes[kind] == 0 || strings.HasPrefix(kind, "int") {
+			// The compact encoding has no sign, so negative numbers would be
+			// encoded as huge numbers.
+			return fmt.Errorf("%s: compact encoding is only supported for unsigned integers", g.fset.Position(typ.Pos()))
+		}
+		g.printf("buf = scaleAppendCompact(buf, uint64(%s))\n", expr)
+		return nil
+	}
+	switch {
+	case kind == "bool":
+		g.printf("if %s {\nbuf = append(buf, 1)\n} else {\nbuf = append(buf, 0)\n}\n", expr)
+	case kind == "string":
+		g.printf("buf = scaleAppendCompact(buf, uint64(len(%s)))\n", expr)
+		g.printf("buf = append(buf, %s...)\n", expr)
+	case intSizes[kind] != 0:
+		size := intSizes[kind]
+		g.printf("{\nx := uint%d(%s)\nbuf = append(buf", size*8, expr)
+		for i := 0; i < size; i++ {
+			if i == 0 {
+				g.printf(", byte(x)")
+			} else {
+				g.printf(", byte(x>>%d)", i*8)
+			}
+		}
+		g.printf(")\n}\n")
+	case kind == "method":
+		g.printf("buf = %s.EncodeScale(buf)\n", expr)
+	default:
+		switch composite := composite.(type) {
+		case *ast.StarExpr:
+			// Option<T>
+			g.printf("if %s == nil {\nbuf = append(buf, 0)\n} else {\nbuf = append(buf, 1)\n", expr)
+			if err := g.encode("(*"+expr+")", composite.X, false); err != nil {
+				return err
+			}
+			g.printf("}\n")
+		case *ast.ArrayType:
+			if composite.Len == nil {
+				// Vec<T>
+				g.printf("buf = scaleAppendCompact(buf, uint64(len(%s)))\n", expr)
+			}
+			if elemKind, _, _ := g.kind(composite.Elt); elemKind == "uint8" || elemKind == "byte" {
+				if composite.Len == nil {
+					g.printf("buf = append(buf, %s...)\n", expr)
+				} else {
+					g.printf("buf = append(buf, %s[:]...)\n", expr)
+				}
+				return nil
+			}
+			i := g.loopVar()
+			defer g.endLoop()
+			g.printf("for %s := range %s {\n", i, expr)
+			if err := g.encode(expr+"["+i+"]", composite.Elt, false); err != nil {
+				return err
+			}
+			g.printf("}\n")
+		}
+	}
+	return nil
+}
+
+// decode generates code to decode expr (of type typ) from buf.
+func (g *generator) decode(expr string, typ ast.Expr, compact bool) error {
+	kind, composite, err := g.kind(typ)
+	if err != nil {
+		return err
+	}
+	typeString := g.typeString(typ)
+	if compact {
+		g.printf("{\nvar n uint64\nn, buf, err = scaleReadCompact(buf)\nif err != nil {\nreturn nil, err\n}\n")
+		if bits := intSizes[kind] * 8; bits < 64 {
+			// Reject values that don't fit instead of truncating them.
+			g.printf("if n > 1<<%d-1 {\nreturn nil, errScaleInvalid\n}\n", bits)
+		}
+		g.printf("%s = %s(n)\n}\n", expr, typeString)
+		return nil
+	}
+	switch {
+	case kind == "bool":
+		g.printf("if len(buf) < 1 {\nreturn nil, errScaleShort\n}\n")
+		g.printf("if buf[0] > 1 {\nreturn nil, errScaleInvalid\n}\n")
+		g.printf("%s = %s\nbuf = buf[1:]\n", expr, convert(typeString, "bool", "buf[0] == 1"))
+	case kind == "string":
+		g.printf("{\nvar n uint64\nn, buf, err = scaleReadCompact(buf)\nif err != nil {\nreturn nil, err\n}\n")
+		g.printf("if uint64(len(buf)) < n {\nreturn nil, errScaleShort\n}\n")
+		g.printf("%s = %s(buf[:n])\nbuf = buf[n:]\n}\n", expr, typeString)
+	case intSizes[kind] != 0:
+		size := intSizes[kind]
+		bits := size * 8
+		g.printf("if len(buf) < %d {\nreturn nil, errScaleShort\n}\n", size)
+		var parts []string
+		for i := 0; i < size; i++ {
+			if i == 0 {
+				parts = append(parts, fmt.Sprintf("uint%d(buf[0])", bits))
+			} else {
+				parts = append(parts, fmt.Sprintf("uint%d(buf[%d])<<%d", bits, i, i*8))
+			}
+		}
+		value := strings.Join(parts, " | ")
+		if size == 1 {
+			value = "buf[0]"
+		}
+		g.printf("%s = %s\nbuf = buf[%d:]\n", expr, convert(typeString, "uint"+strconv.Itoa(bits), value), size)
+	case kind == "method":
+		g.printf("buf, err = %s.DecodeScale(buf)\nif err != nil {\nreturn nil, err\n}\n", expr)
+	default:
+		switch composite := composite.(type) {
+		case *ast.StarExpr:
+			// Option<T>
+			g.printf("if len(buf) < 1 {\nreturn nil, errScaleShort\n}\n")
+			g.printf("switch buf[0] {\ncase 0:\n%s = nil\nbuf = buf[1:]\ncase 1:\nbuf = buf[1:]\n", expr)
+			g.printf("%s = new(%s)\n", expr, g.typeString(composite.X))
+			if err := g.decode("(*"+expr+")", composite.X, false); err != nil {
+				return err
+			}
+			g.printf("default:\nreturn nil, errScaleInvalid\n}\n")
+		case *ast.ArrayType:
+			elemKind, _, _ := g.kind(composite.Elt)
+			isBytes := elemKind == "uint8" || elemKind == "byte"
+			if composite.Len != nil {
+				if isBytes {
+					g.printf("if len(buf) < len(%s) {\nreturn nil, errScaleShort\n}\n", expr)
+					g.printf("buf = buf[copy(%s[:], buf):]\n", expr)
+					return nil
+				}
+			} else {
+				// Every element takes at least one byte (in practice), so
+				// limit the allocation size to the remaining input.
+				g.printf("{\nvar n uint64\nn, buf, err = scaleReadCompact(buf)\nif err != nil {\nreturn nil, err\n}\n")
+				g.printf("if uint64(len(buf)) < n {\nreturn nil, errScaleShort\n}\n")
+				g.printf("%s = make(%s, n)\n}\n", expr, typeString)
+				if isBytes {
+					g.printf("buf = buf[copy(%s, buf):]\n", expr)
+					return nil
+				}
+			}
+			i := g.loopVar()
+			defer g.endLoop()
+			g.printf("for %s := range %s {\n", i, expr)
+			if err := g.decode(expr+"["+i+"]", composite.Elt, false); err != nil {
+				return err
+			}
+			g.printf("}\n")
+		}
+	}
+	return nil
+}
+
+// convert returns the Go expression to convert value (of type from) to the
+// given type, omitting the conversion if it isn't needed.
+func convert(to, from, value string) string {
+	if to == from || (to == "byte" && from == "uint8") {
+		return value
+	}
+	return to + "(" + value + ")"
+}
+
+// Helper functions that are included once in every generated file. They don't
+// use any packages, see the package documentation.
+const helpers = `
+// scaleError is an error returned by the DecodeScale methods.
+type scaleError string
+
+func (err scaleError) Error() string {
+	return string(err)
+}
+
+const (
+	errScaleShort   = scaleError("scale: input too short")
+	errScaleInvalid = scaleError("scale: invalid input")
+)
+
+// scaleAppendCompact appends n in the SCALE compact integer encoding.
+func scaleAppendCompact(buf []byte, n uint64) []byte {
+	switch {
+	case n < 1<<6:
+		return append(buf, byte(n<<2))
+	case n < 1<<14:
+		return append(buf, byte(n<<2)|1, byte(n>>6))
+	case n < 1<<30:
+		return append(buf, byte(n<<2)|2, byte(n>>6), byte(n>>14), byte(n>>22))
+	}
+	size := 4
+	for size < 8 && n>>(size*8) != 0 {
+		size++
+	}
+	buf = append(buf, byte(size-4)<<2|3)
+	for i := 0; i < size; i++ {
+		buf = append(buf, byte(n>>(i*8)))
+	}
+	return buf
+}
+
+// scaleReadCompact reads a SCALE compact integer from the start of buf.
+func scaleReadCompact(buf []byte) (uint64, []byte, error) {
+	if len(buf) < 1 {
+		return 0, nil, errScaleShort
+	}
+	var size int
+	switch buf[0] & 3 {
+	case 0:
+		return uint64(buf[0] >> 2), buf[1:], nil
+	case 1:
+		size = 2
+	case 2:
+		size = 4
+	default:
+		size = int(buf[0]>>2) + 5
+		if size > 9 {
+			return 0, nil, errScaleInvalid // doesn't fit in a uint64
+		}
+	}
+	if len(buf) < size {
+		return 0, nil, errScaleShort
+	}
+	var n uint64
+	if buf[0]&3 == 3 {
+		for i := size - 1; i >= 1; i-- {
+			n = n<<8 | uint64(buf[i])
+		}
+	} else {
+		for i := size - 1; i >= 0; i-- {
+			n = n<<8 | uint64(buf[i])
+		}
+		n >>= 2
+	}
+	return n, buf[size:], nil
+}
+`
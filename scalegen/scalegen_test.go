@@ -0,0 +1,76 @@
+package scalegen
+
+import (
+	"flag"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// Pass -update to go test to update the output of the test files.
+var flagUpdate = flag.Bool("update", false, "update tests based on test output")
+
+func TestGenerate(t *testing.T) {
+	for _, name := range []string{
+		"basic",
+	} {
+		name := name
+		t.Run(name, func(t *testing.T) {
+			path := filepath.Join("testdata", name+".go")
+			fset := token.NewFileSet()
+			f, err := parser.ParseFile(fset, path, nil, parser.ParseComments)
+			if err != nil {
+				t.Fatal("could not parse Go source file:", err)
+			}
+			result, err := Generate(fset, []*ast.File{f})
+			if err != nil {
+				t.Fatal("could not generate code:", err)
+			}
+
+			outPath := filepath.Join("testdata", name+".out.go")
+			if *flagUpdate {
+				err := os.WriteFile(outPath, result, 0666)
+				if err != nil {
+					t.Error("could not write output file:", err)
+				}
+				return
+			}
+			expected, err := os.ReadFile(outPath)
+			if err != nil {
+				t.Fatal("could not read expected output:", err)
+			}
+			if strings.ReplaceAll(string(expected), "\r\n", "\n") != string(result) {
+				t.Errorf("output did not match %s", outPath)
+			}
+		})
+	}
+}
+
+func TestGenerateErrors(t *testing.T) {
+	for _, tc := range []struct {
+		src string
+		err string
+	}{
+		{"//tinygo:scale\ntype T struct { X int }", "errors.go:3:19: int has no fixed size, use a sized integer type"},
+		{"//tinygo:scale\ntype T struct { X string `scale:\"compact\"` }", "errors.go:3:19: compact encoding is only supported for unsigned integers"},
+		{"//tinygo:scale\ntype T struct { X int32 `scale:\"compact\"` }", "errors.go:3:19: compact encoding is only supported for unsigned integers"},
+		{"//tinygo:scale\ntype T struct { G[uint8] }", "errors.go:3:17: unsupported embedded field in SCALE codec"},
+		{"//tinygo:scale\ntype T struct { X uint8 `scale:\"big\"` }", "errors.go:3:25: unknown scale tag \"scale:\\\"big\\\"\""},
+		{"//tinygo:scale\ntype T int", "errors.go:3:6: //tinygo:scale can only be used on non-generic struct types"},
+		{"//tinygo:scale\ntype T struct { F func() }", "errors.go:3:19: unsupported type in SCALE codec"},
+	} {
+		fset := token.NewFileSet()
+		f, err := parser.ParseFile(fset, "errors.go", "package main\n"+tc.src, parser.ParseComments)
+		if err != nil {
+			t.Fatal("could not parse Go source:", err)
+		}
+		_, err = Generate(fset, []*ast.File{f})
+		if err == nil || err.Error() != tc.err {
+			t.Errorf("expected error %q, got %v", tc.err, err)
+		}
+	}
+}
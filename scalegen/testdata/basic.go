@@ -0,0 +1,52 @@
+package main
+
+type Balance uint64
+
+type Hash [4]byte
+
+type AccountID Hash
+
+// Header is a block header.
+//
+//tinygo:scale
+type Header struct {
+	ParentHash Hash
+	Number     uint32 `scale:"compact"`
+	StateRoot  [4]byte
+	Digest     []DigestItem
+}
+
+//tinygo:scale
+type DigestItem struct {
+	Engine  [2]byte
+	Payload []byte
+}
+
+//tinygo:scale
+type Transfer struct {
+	From     AccountID
+	To       *AccountID
+	Amount   Balance
+	Fee      int16
+	Memo     string
+	Approved bool
+	Tags     [][]uint16
+	Header   Header
+	cache    []byte // unexported, not encoded
+	Ignored  uint32 `scale:"-"`
+}
+
+// Embedded fields are encoded like fields with the name of their type.
+//
+//tinygo:scale
+type SignedTransfer struct {
+	Transfer
+	*DigestItem
+	Nonce     uint16 `scale:"compact"`
+	signature []byte // unexported, not encoded
+}
+
+// Not marked, so no methods are generated.
+type Other struct {
+	X uint8
+}
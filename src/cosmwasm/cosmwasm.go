@@ -0,0 +1,23 @@
+//go:build cosmwasm
+
+// Package cosmwasm provides the glue between CosmWasm contract entry points and
+// the host. It is only available on the cosmwasm target.
+//
+// Entry points receive and return the addresses of regions, which describe a
+// block of memory in the contract. For example:
+//
+//	//export instantiate
+//	func instantiate(env, info, msg uint32) uint32 {
+//		input := cosmwasm.Read(msg)
+//		...
+//		return cosmwasm.Write(result)
+//	}
+package cosmwasm
+
+// Read returns the contents of the region at the given address, as passed to
+// an entry point by the host. Every region must be read at most once.
+func Read(region uint32) []byte
+
+// Write copies data into a new region and returns its address, so that it can
+// be returned from an entry point to the host.
+func Write(data []byte) uint32
@@ -0,0 +1,167 @@
+// Program gcstress is a long running, randomized stress test for the garbage
+// collector. It is run by the hidden `tinygo gc-stress` command, which builds
+// it with runtime assertions enabled so that the GC checks its own invariants
+// after every collection cycle.
+//
+// The program keeps a table of objects that reference each other at random.
+// It keeps replacing, linking and dropping objects, and after every cycle it
+// walks all reachable objects to check that none of them were freed while
+// still in use.
+//
+// Usage: gcstress [seed [duration]]
+package main
+
+import (
+	"math/rand"
+	"os"
+	"runtime"
+	"strconv"
+	"time"
+)
+
+const (
+	slots         = 1024 // number of objects directly referenced by the table
+	refsPerObject = 4    // number of references from one object to others
+	opsPerCycle   = 5000 // number of random operations between two checks
+)
+
+type object struct {
+	id      uint32
+	payload []byte
+	refs    [refsPerObject]*object
+}
+
+var (
+	table  [slots]*object
+	nextID uint32
+	rng    *rand.Rand
+)
+
+func main() {
+	seed := int64(1)
+	duration := time.Minute
+	if len(os.Args) > 1 {
+		n, err := strconv.ParseInt(os.Args[1], 10, 64)
+		if err != nil {
+			println("invalid seed:", os.Args[1])
+			os.Exit(2)
+		}
+		seed = n
+	}
+	if len(os.Args) > 2 {
+		d, err := time.ParseDuration(os.Args[2])
+		if err != nil {
+			println("invalid duration:", os.Args[2])
+			os.Exit(2)
+		}
+		duration = d
+	}
+	rng = rand.New(rand.NewSource(seed))
+	println("gc-stress: seed", seed, "duration", duration.String())
+
+	start := time.Now()
+	lastReport := start
+	var stats runtime.MemStats
+	for cycle := 1; time.Since(start) < duration; cycle++ {
+		for i := 0; i < opsPerCycle; i++ {
+			step()
+		}
+		runtime.GC()
+		reachable := check()
+		if time.Since(lastReport) >= 10*time.Second {
+			lastReport = time.Now()
+			runtime.ReadMemStats(&stats)
+			println("cycle", cycle, "reachable", reachable, "heap", stats.HeapInuse, "mallocs", stats.Mallocs, "frees", stats.Frees)
+		}
+	}
+	println("gc-stress: ok")
+}
+
+// newObject allocates an object with a payload that can be verified later.
+// Most objects are small, but some are large enough to need many pages.
+func newObject() *object {
+	var size int
+	switch n := rng.Intn(100); {
+	case n < 70:
+		size = rng.Intn(64)
+	case n < 99:
+		size = 64 + rng.Intn(4096)
+	default:
+		size = 16*1024 + rng.Intn(128*1024)
+	}
+	nextID++
+	obj := &object{id: nextID, payload: make([]byte, size)}
+	for i := range obj.payload {
+		obj.payload[i] = payloadByte(obj.id, i)
+	}
+	return obj
+}
+
+func payloadByte(id uint32, index int) byte {
+	return byte(id*31 + uint32(index)*7)
+}
+
+// step does a single random operation on the object graph.
+func step() {
+	slot := rng.Intn(slots)
+	switch n := rng.Intn(100); {
+	case n < 40:
+		// Replace an object, which may make it unreachable.
+		table[slot] = newObject()
+	case n < 70:
+		// Reference another object, possibly creating a cycle.
+		if obj := table[slot]; obj != nil {
+			obj.refs[rng.Intn(refsPerObject)] = table[rng.Intn(slots)]
+		}
+	case n < 80:
+		// Reference an object only through another object.
+		if obj := table[slot]; obj != nil {
+			obj.refs[rng.Intn(refsPerObject)] = newObject()
+		}
+	case n < 90:
+		// Drop an object from the table, it may still be referenced.
+		table[slot] = nil
+	default:
+		// Allocate some garbage that is never referenced.
+		for i := rng.Intn(16); i > 0; i-- {
+			garbage := newObject()
+			garbage.refs[0] = table[rng.Intn(slots)]
+		}
+	}
+}
+
+// check walks all objects that are reachable from the table and checks their
+// contents. It returns the number of reachable objects.
+func check() int {
+	seen := make(map[*object]struct{})
+	var stack []*object
+	for _, obj := range table {
+		if obj != nil {
+			stack = append(stack, obj)
+		}
+	}
+	for len(stack) > 0 {
+		obj := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		if _, ok := seen[obj]; ok {
+			continue
+		}
+		seen[obj] = struct{}{}
+		if obj.id == 0 || obj.id > nextID {
+			println("gc-stress: corrupted object id", obj.id)
+			panic("gc-stress: heap corruption")
+		}
+		for i, b := range obj.payload {
+			if b != payloadByte(obj.id, i) {
+				println("gc-stress: corrupted payload of object", obj.id, "at offset", i)
+				panic("gc-stress: heap corruption")
+			}
+		}
+		for _, ref := range obj.refs {
+			if ref != nil {
+				stack = append(stack, ref)
+			}
+		}
+	}
+	return len(seen)
+}
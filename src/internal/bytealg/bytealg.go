@@ -9,6 +9,8 @@ package bytealg
 //
 // This is indicated specifically in the file.
 
+import _ "unsafe"
+
 const (
 	// Index can search any valid length of string.
 
@@ -269,16 +271,23 @@ func IndexRabinKarp[T string | []byte](s, sep T) int {
 	return -1
 }
 
-// MakeNoZero makes a slice of length and capacity n without zeroing the bytes.
+// MakeNoZero makes a slice of length n and capacity of at least n bytes
+// without zeroing the bytes. Like in the Go runtime, the capacity is rounded
+// up to the memory that the allocator reserves anyway: the callers in package
+// bytes slice the result to a capacity of n, while strings.Builder uses the
+// extra capacity when it grows.
 // It is the caller's responsibility to ensure uninitialized bytes
 // do not leak to the end user.
 func MakeNoZero(n int) []byte {
 	// Note: this does zero the buffer even though that's not necessary.
 	// For performance reasons we might want to change this (similar to the
 	// malloc function implemented in the runtime).
-	return make([]byte, n)
+	return make([]byte, n, roundupAllocSize(uintptr(n)))
 }
 
+//go:linkname roundupAllocSize runtime.roundupAllocSize
+func roundupAllocSize(size uintptr) uintptr
+
 // Copied from the Go 1.22rc1 source tree.
 func LastIndexByte(s []byte, c byte) int {
 	for i := len(s) - 1; i >= 0; i-- {
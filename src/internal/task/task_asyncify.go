@@ -14,6 +14,9 @@ const stackCanary = uintptr(uint64(0x670c1333b83bf575) & uint64(^uintptr(0)))
 //go:linkname runtimePanic runtime.runtimePanic
 func runtimePanic(str string)
 
+//go:linkname stackOverflowPanic runtime.stackOverflowPanic
+func stackOverflowPanic()
+
 // state is a structure which holds a reference to the state of the task.
 // When the task is suspended, the stack pointers are saved here.
 type state struct {
@@ -87,7 +90,7 @@ func Current() *Task {
 func Pause() {
 	// This is mildly unsafe but this is also the only place we can do this.
 	if *(*uintptr)(unsafe.Pointer(currentTask.state.asyncifysp)) != stackCanary {
-		runtimePanic("stack overflow")
+		stackOverflowPanic()
 	}
 
 	currentTask.state.unwind()
@@ -114,7 +117,7 @@ func (t *Task) Resume() {
 	currentTask = prevTask
 	t.gcData.swap()
 	if t.state.asyncifysp > t.state.csp {
-		runtimePanic("stack overflow")
+		stackOverflowPanic()
 	}
 }
 
@@ -0,0 +1,322 @@
+// Package u256 implements fixed-width 256-bit unsigned integers.
+//
+// Unlike math/big, an Int is a plain value that lives on the stack or inline
+// in other values, so arithmetic never allocates. Operations wrap around on
+// overflow like the built-in unsigned integer types; the *Overflow variants
+// report whether this happened. The arithmetic is built on bits.Add64,
+// bits.Sub64 and bits.Mul64, which the compiler lowers to plain LLVM integer
+// operations.
+package u256
+
+import (
+	"errors"
+	"math/bits"
+)
+
+// Int is an unsigned 256-bit integer, stored as four 64-bit limbs with the
+// least significant limb first. The zero value is 0.
+type Int [4]uint64
+
+// Max is the largest value that can be stored in an Int.
+var Max = Int{^uint64(0), ^uint64(0), ^uint64(0), ^uint64(0)}
+
+var (
+	errSyntax = errors.New("u256: invalid syntax")
+	errRange  = errors.New("u256: value out of range")
+)
+
+// FromUint64 returns x as an Int.
+func FromUint64(x uint64) Int {
+	return Int{x}
+}
+
+// FromBytes interprets b as a big-endian unsigned integer. It panics if b is
+// longer than 32 bytes.
+func FromBytes(b []byte) Int {
+	if len(b) > 32 {
+		panic("u256: FromBytes: input longer than 32 bytes")
+	}
+	var z Int
+	for i, c := range b {
+		shift := uint(len(b)-1-i) * 8
+		z[shift/64] |= uint64(c) << (shift % 64)
+	}
+	return z
+}
+
+// Bytes32 returns x as a 32 byte big-endian value.
+func (x Int) Bytes32() [32]byte {
+	var b [32]byte
+	for i := 0; i < 32; i++ {
+		shift := uint(31-i) * 8
+		b[i] = byte(x[shift/64] >> (shift % 64))
+	}
+	return b
+}
+
+// IsZero returns whether x == 0.
+func (x Int) IsZero() bool {
+	return x[0]|x[1]|x[2]|x[3] == 0
+}
+
+// IsUint64 returns whether x can be represented as a uint64.
+func (x Int) IsUint64() bool {
+	return x[1]|x[2]|x[3] == 0
+}
+
+// Uint64 returns the low 64 bits of x.
+func (x Int) Uint64() uint64 {
+	return x[0]
+}
+
+// Cmp compares x and y and returns -1, 0 or +1 if x is less than, equal to or
+// greater than y.
+func (x Int) Cmp(y Int) int {
+	for i := 3; i >= 0; i-- {
+		if x[i] != y[i] {
+			if x[i] < y[i] {
+				return -1
+			}
+			return 1
+		}
+	}
+	return 0
+}
+
+// Lt returns whether x < y.
+func (x Int) Lt(y Int) bool {
+	return x.Cmp(y) < 0
+}
+
+// BitLen returns the number of bits needed to represent x.
+func (x Int) BitLen() int {
+	for i := 3; i >= 0; i-- {
+		if x[i] != 0 {
+			return i*64 + bits.Len64(x[i])
+		}
+	}
+	return 0
+}
+
+// Add returns x + y, wrapping around on overflow.
+func (x Int) Add(y Int) Int {
+	z, _ := x.AddOverflow(y)
+	return z
+}
+
+// AddOverflow returns x + y and whether the addition overflowed.
+func (x Int) AddOverflow(y Int) (Int, bool) {
+	var z Int
+	var carry uint64
+	z[0], carry = bits.Add64(x[0], y[0], 0)
+	z[1], carry = bits.Add64(x[1], y[1], carry)
+	z[2], carry = bits.Add64(x[2], y[2], carry)
+	z[3], carry = bits.Add64(x[3], y[3], carry)
+	return z, carry != 0
+}
+
+// Sub returns x - y, wrapping around on underflow.
+func (x Int) Sub(y Int) Int {
+	z, _ := x.SubOverflow(y)
+	return z
+}
+
+// SubOverflow returns x - y and whether the subtraction underflowed.
+func (x Int) SubOverflow(y Int) (Int, bool) {
+	var z Int
+	var borrow uint64
+	z[0], borrow = bits.Sub64(x[0], y[0], 0)
+	z[1], borrow = bits.Sub64(x[1], y[1], borrow)
+	z[2], borrow = bits.Sub64(x[2], y[2], borrow)
+	z[3], borrow = bits.Sub64(x[3], y[3], borrow)
+	return z, borrow != 0
+}
+
+// Mul returns x * y, wrapping around on overflow.
+func (x Int) Mul(y Int) Int {
+	_, lo := x.MulFull(y)
+	return lo
+}
+
+// MulOverflow returns x * y and whether the multiplication overflowed.
+func (x Int) MulOverflow(y Int) (Int, bool) {
+	hi, lo := x.MulFull(y)
+	return lo, !hi.IsZero()
+}
+
+// MulFull returns the full 512-bit product of x and y, as the high and low 256
+// bits.
+func (x Int) MulFull(y Int) (hi, lo Int) {
+	var p [8]uint64
+	for i := 0; i < 4; i++ {
+		var carry uint64
+		for j := 0; j < 4; j++ {
+			h, l := bits.Mul64(x[i], y[j])
+			var c uint64
+			l, c = bits.Add64(l, p[i+j], 0)
+			h += c
+			l, c = bits.Add64(l, carry, 0)
+			h += c
+			p[i+j] = l
+			carry = h
+		}
+		p[i+4] = carry
+	}
+	return Int{p[4], p[5], p[6], p[7]}, Int{p[0], p[1], p[2], p[3]}
+}
+
+// Div returns x / y. It panics if y is zero.
+func (x Int) Div(y Int) Int {
+	q, _ := x.DivMod(y)
+	return q
+}
+
+// Mod returns x % y. It panics if y is zero.
+func (x Int) Mod(y Int) Int {
+	_, r := x.DivMod(y)
+	return r
+}
+
+// DivMod returns x / y and x % y. It panics if y is zero.
+func (x Int) DivMod(y Int) (q, r Int) {
+	if y.IsZero() {
+		panic("u256: division by zero")
+	}
+	if x.IsUint64() && y.IsUint64() {
+		return Int{x[0] / y[0]}, Int{x[0] % y[0]}
+	}
+	if y.IsUint64() {
+		q, rem := x.divUint64(y[0])
+		return q, Int{rem}
+	}
+	if x.Lt(y) {
+		return Int{}, x
+	}
+
+	// Binary long division, only over the bits that can be set in the
+	// quotient.
+	shift := uint(x.BitLen() - y.BitLen())
+	d := y.Lsh(shift)
+	r = x
+	for i := int(shift); i >= 0; i-- {
+		if !r.Lt(d) {
+			r = r.Sub(d)
+			q[i/64] |= 1 << uint(i%64)
+		}
+		d = d.Rsh(1)
+	}
+	return q, r
+}
+
+// divUint64 returns x / y and x % y for a single limb divisor.
+func (x Int) divUint64(y uint64) (q Int, r uint64) {
+	for i := 3; i >= 0; i-- {
+		q[i], r = bits.Div64(r, x[i], y)
+	}
+	return q, r
+}
+
+// Lsh returns x << n.
+func (x Int) Lsh(n uint) Int {
+	if n >= 256 {
+		return Int{}
+	}
+	var z Int
+	limbs, n := int(n/64), n%64
+	for i := 3; i >= limbs; i-- {
+		z[i] = x[i-limbs] << n
+		if n != 0 && i-limbs > 0 {
+			z[i] |= x[i-limbs-1] >> (64 - n)
+		}
+	}
+	return z
+}
+
+// Rsh returns x >> n.
+func (x Int) Rsh(n uint) Int {
+	if n >= 256 {
+		return Int{}
+	}
+	var z Int
+	limbs, n := int(n/64), n%64
+	for i := 0; i+limbs < 4; i++ {
+		z[i] = x[i+limbs] >> n
+		if n != 0 && i+limbs < 3 {
+			z[i] |= x[i+limbs+1] << (64 - n)
+		}
+	}
+	return z
+}
+
+// And returns x & y.
+func (x Int) And(y Int) Int {
+	return Int{x[0] & y[0], x[1] & y[1], x[2] & y[2], x[3] & y[3]}
+}
+
+// Or returns x | y.
+func (x Int) Or(y Int) Int {
+	return Int{x[0] | y[0], x[1] | y[1], x[2] | y[2], x[3] | y[3]}
+}
+
+// Xor returns x ^ y.
+func (x Int) Xor(y Int) Int {
+	return Int{x[0] ^ y[0], x[1] ^ y[1], x[2] ^ y[2], x[3] ^ y[3]}
+}
+
+// Not returns ^x.
+func (x Int) Not() Int {
+	return Int{^x[0], ^x[1], ^x[2], ^x[3]}
+}
+
+// String returns x in decimal.
+func (x Int) String() string {
+	if x.IsZero() {
+		return "0"
+	}
+	var buf [78]byte // 2**256 has 78 decimal digits
+	i := len(buf)
+	for !x.IsZero() {
+		// Convert 19 digits at a time, the most that fits in a uint64.
+		var chunk uint64
+		x, chunk = x.divUint64(1e19)
+		for j := 0; j < 19 && (chunk != 0 || !x.IsZero()); j++ {
+			i--
+			buf[i] = byte('0' + chunk%10)
+			chunk /= 10
+		}
+	}
+	return string(buf[i:])
+}
+
+// Parse parses a decimal string, or a hexadecimal string with a 0x prefix.
+func Parse(s string) (Int, error) {
+	base := uint64(10)
+	if len(s) > 2 && s[0] == '0' && (s[1] == 'x' || s[1] == 'X') {
+		base = 16
+		s = s[2:]
+	}
+	if s == "" {
+		return Int{}, errSyntax
+	}
+	var z Int
+	for i := 0; i < len(s); i++ {
+		var digit uint64
+		switch c := s[i]; {
+		case '0' <= c && c <= '9':
+			digit = uint64(c - '0')
+		case base == 16 && 'a' <= c && c <= 'f':
+			digit = uint64(c-'a') + 10
+		case base == 16 && 'A' <= c && c <= 'F':
+			digit = uint64(c-'A') + 10
+		default:
+			return Int{}, errSyntax
+		}
+		var overflow, carry bool
+		z, overflow = z.MulOverflow(Int{base})
+		z, carry = z.AddOverflow(Int{digit})
+		if overflow || carry {
+			return Int{}, errRange
+		}
+	}
+	return z, nil
+}
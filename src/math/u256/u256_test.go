@@ -0,0 +1,120 @@
+package u256_test
+
+import (
+	"math/big"
+	"math/rand"
+	"math/u256"
+	"testing"
+)
+
+var mod256 = new(big.Int).Lsh(big.NewInt(1), 256)
+
+func toBig(x u256.Int) *big.Int {
+	b := x.Bytes32()
+	return new(big.Int).SetBytes(b[:])
+}
+
+func fromBig(b *big.Int) u256.Int {
+	return u256.FromBytes(new(big.Int).Mod(b, mod256).Bytes())
+}
+
+// randInt returns a random value, with a bias towards values where some limbs
+// are zero or all ones.
+func randInt(r *rand.Rand) u256.Int {
+	var x u256.Int
+	for i := range x {
+		switch r.Intn(4) {
+		case 0:
+		case 1:
+			x[i] = ^uint64(0)
+		default:
+			x[i] = r.Uint64()
+		}
+	}
+	return x
+}
+
+func TestArithmetic(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	for i := 0; i < 1000; i++ {
+		x, y := randInt(r), randInt(r)
+		bx, by := toBig(x), toBig(y)
+
+		if got, want := x.Add(y), fromBig(new(big.Int).Add(bx, by)); got != want {
+			t.Errorf("%s + %s: got %s, want %s", x, y, got, want)
+		}
+		if got, want := x.Sub(y), fromBig(new(big.Int).Sub(bx, by)); got != want {
+			t.Errorf("%s - %s: got %s, want %s", x, y, got, want)
+		}
+		if got, want := x.Mul(y), fromBig(new(big.Int).Mul(bx, by)); got != want {
+			t.Errorf("%s * %s: got %s, want %s", x, y, got, want)
+		}
+		hi, _ := x.MulFull(y)
+		if got, want := hi, fromBig(new(big.Int).Rsh(new(big.Int).Mul(bx, by), 256)); got != want {
+			t.Errorf("%s * %s: got high bits %s, want %s", x, y, got, want)
+		}
+		if !y.IsZero() {
+			q, m := x.DivMod(y)
+			if want := fromBig(new(big.Int).Div(bx, by)); q != want {
+				t.Errorf("%s / %s: got %s, want %s", x, y, q, want)
+			}
+			if want := fromBig(new(big.Int).Mod(bx, by)); m != want {
+				t.Errorf("%s %% %s: got %s, want %s", x, y, m, want)
+			}
+		}
+		if got, want := x.Cmp(y), bx.Cmp(by); got != want {
+			t.Errorf("cmp(%s, %s): got %d, want %d", x, y, got, want)
+		}
+		n := uint(r.Intn(260))
+		if got, want := x.Lsh(n), fromBig(new(big.Int).Lsh(bx, n)); got != want {
+			t.Errorf("%s << %d: got %s, want %s", x, n, got, want)
+		}
+		if got, want := x.Rsh(n), fromBig(new(big.Int).Rsh(bx, n)); got != want {
+			t.Errorf("%s >> %d: got %s, want %s", x, n, got, want)
+		}
+		if got, want := x.String(), bx.String(); got != want {
+			t.Errorf("String: got %s, want %s", got, want)
+		}
+		if parsed, err := u256.Parse(bx.String()); err != nil || parsed != x {
+			t.Errorf("Parse(%s): got %s, %v", bx, parsed, err)
+		}
+		if parsed, err := u256.Parse("0x" + bx.Text(16)); err != nil || parsed != x {
+			t.Errorf("Parse(0x%s): got %s, %v", bx.Text(16), parsed, err)
+		}
+	}
+}
+
+func TestOverflow(t *testing.T) {
+	one := u256.FromUint64(1)
+	if _, overflow := u256.Max.AddOverflow(one); !overflow {
+		t.Error("Max + 1 did not overflow")
+	}
+	if _, overflow := (u256.Int{}).SubOverflow(one); !overflow {
+		t.Error("0 - 1 did not underflow")
+	}
+	if _, overflow := u256.Max.MulOverflow(u256.FromUint64(2)); !overflow {
+		t.Error("Max * 2 did not overflow")
+	}
+	if _, overflow := u256.Max.MulOverflow(one); overflow {
+		t.Error("Max * 1 overflowed")
+	}
+	if _, err := u256.Parse("115792089237316195423570985008687907853269984665640564039457584007913129639936"); err == nil {
+		t.Error("Parse(2**256) did not return an error")
+	}
+	for _, s := range []string{"", "0x", "12a", "-1"} {
+		if _, err := u256.Parse(s); err == nil {
+			t.Errorf("Parse(%q) did not return an error", s)
+		}
+	}
+}
+
+func TestAllocs(t *testing.T) {
+	x := u256.FromUint64(12345)
+	y := u256.Max.Rsh(3)
+	allocs := testing.AllocsPerRun(10, func() {
+		x = x.Mul(y).Add(y).Div(x.Add(u256.FromUint64(7)))
+	})
+	if allocs != 0 {
+		t.Errorf("expected no allocations, got %.1f", allocs)
+	}
+}
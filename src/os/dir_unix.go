@@ -2,7 +2,7 @@
 // Use of this source code is governed by a BSD-style
 // license that can be found in the LICENSE file.
 
-//go:build linux && !baremetal && !wasi && !wasip1
+//go:build linux && !baremetal && !wasi && !wasip1 && !wasm_unknown
 
 package os
 
@@ -0,0 +1,164 @@
+//go:build (baremetal || (wasm && !wasi && !wasip1) || wasm_unknown) && (memfs || storagefs)
+
+package os
+
+// With the memfs build tag, the in-memory filesystem of the syscall package is
+// mounted at the root. This way tests and libraries that incidentally use
+// files (for example in os.TempDir) work on systems without a filesystem. The
+// storagefs build tag does the same, with the host storage in /storage.
+
+import (
+	"io"
+	"syscall"
+	"time"
+)
+
+func init() {
+	Mount("/", memFilesystem{})
+	newFileHandle = func(fd uintptr) FileHandle {
+		return memFileHandle(fd)
+	}
+}
+
+// memFilesystem implements the Filesystem interface on top of the syscall
+// package.
+type memFilesystem struct{}
+
+func (fs memFilesystem) OpenFile(name string, flag int, perm FileMode) (uintptr, error) {
+	fd, err := syscall.Open(name, flag, uint32(perm.Perm()))
+	if err != nil {
+		return 0, memError(err)
+	}
+	return uintptr(fd), nil
+}
+
+func (fs memFilesystem) Mkdir(name string, perm FileMode) error {
+	return memError(syscall.Mkdir(name, uint32(perm.Perm())))
+}
+
+func (fs memFilesystem) Remove(name string) error {
+	err := syscall.Unlink(name)
+	if err == syscall.EISDIR {
+		err = syscall.Rmdir(name)
+	}
+	if err != nil {
+		return &PathError{Op: "remove", Path: name, Err: memError(err)}
+	}
+	return nil
+}
+
+// memError converts the syscall errors that have an equivalent in the os
+// package, as syscall.Errno doesn't implement Is on these systems.
+func memError(err error) error {
+	switch err {
+	case syscall.ENOENT:
+		return ErrNotExist
+	case syscall.EEXIST:
+		return ErrExist
+	case syscall.EBADF:
+		return ErrClosed
+	}
+	return err
+}
+
+// memFileHandle is a file descriptor of the in-memory filesystem. It
+// implements the FileHandle interface.
+type memFileHandle uintptr
+
+func (f memFileHandle) Read(b []byte) (n int, err error) {
+	n, err = syscall.Read(int(f), b)
+	if n == 0 && len(b) > 0 && err == nil {
+		err = io.EOF
+	}
+	return n, memError(err)
+}
+
+func (f memFileHandle) ReadAt(b []byte, offset int64) (n int, err error) {
+	n, err = syscall.Pread(int(f), b, offset)
+	if n < len(b) && err == nil {
+		err = io.EOF
+	}
+	return n, memError(err)
+}
+
+func (f memFileHandle) Write(b []byte) (n int, err error) {
+	n, err = syscall.Write(int(f), b)
+	return n, memError(err)
+}
+
+func (f memFileHandle) WriteAt(b []byte, offset int64) (n int, err error) {
+	n, err = syscall.Pwrite(int(f), b, offset)
+	return n, memError(err)
+}
+
+func (f memFileHandle) Seek(offset int64, whence int) (int64, error) {
+	newoffset, err := syscall.Seek(int(f), offset, whence)
+	return newoffset, memError(err)
+}
+
+func (f memFileHandle) Sync() error {
+	return memError(syscall.Fsync(int(f)))
+}
+
+func (f memFileHandle) Close() error {
+	return memError(syscall.Close(int(f)))
+}
+
+func (f memFileHandle) Fd() uintptr {
+	return uintptr(f)
+}
+
+// Stat returns the FileInfo structure describing file.
+func (f *File) Stat() (FileInfo, error) {
+	handle, ok := f.handle.(memFileHandle)
+	if !ok {
+		return nil, &PathError{Op: "stat", Path: f.name, Err: ErrNotImplemented}
+	}
+	var st syscall.Stat_t
+	if err := syscall.Fstat(int(handle), &st); err != nil {
+		return nil, &PathError{Op: "stat", Path: f.name, Err: memError(err)}
+	}
+	return newMemFileInfo(f.name, &st), nil
+}
+
+// statNolog stats a file with no test logging.
+func statNolog(name string) (FileInfo, error) {
+	var st syscall.Stat_t
+	if err := syscall.Stat(name, &st); err != nil {
+		return nil, &PathError{Op: "stat", Path: name, Err: memError(err)}
+	}
+	return newMemFileInfo(name, &st), nil
+}
+
+// lstatNolog lstats a file with no test logging.
+func lstatNolog(name string) (FileInfo, error) {
+	var st syscall.Stat_t
+	if err := syscall.Lstat(name, &st); err != nil {
+		return nil, &PathError{Op: "lstat", Path: name, Err: memError(err)}
+	}
+	return newMemFileInfo(name, &st), nil
+}
+
+// memFileInfo implements FileInfo for the in-memory filesystem.
+type memFileInfo struct {
+	name string
+	sys  syscall.Stat_t
+}
+
+func newMemFileInfo(name string, st *syscall.Stat_t) *memFileInfo {
+	return &memFileInfo{name: basename(name), sys: *st}
+}
+
+func (fi *memFileInfo) Name() string       { return fi.name }
+func (fi *memFileInfo) Size() int64        { return fi.sys.Size }
+func (fi *memFileInfo) ModTime() time.Time { return time.Time{} }
+func (fi *memFileInfo) IsDir() bool        { return fi.Mode().IsDir() }
+func (fi *memFileInfo) Sys() interface{}   { return &fi.sys }
+
+func (fi *memFileInfo) Mode() FileMode {
+	mode := FileMode(fi.sys.Mode & 0o777)
+	if fi.sys.Mode&syscall.S_IFMT == syscall.S_IFDIR {
+		mode |= ModeDir
+	}
+	return mode
+}
@@ -1,8 +1,9 @@
-//go:build baremetal || (wasm && !wasi && !wasip1)
+//go:build baremetal || (wasm && !wasi && !wasip1) || wasm_unknown
 
 package os
 
 import (
+	"io"
 	_ "unsafe"
 )
 
@@ -38,7 +39,15 @@ func (f *file) close() error {
 	return f.handle.Close()
 }
 
+// newFileHandle, if set, returns the handle for a file descriptor other than
+// stdin, stdout and stderr. It is set when there is a filesystem, see
+// file_memfs.go.
+var newFileHandle func(fd uintptr) FileHandle
+
 func NewFile(fd uintptr, name string) *File {
+	if fd > 2 && newFileHandle != nil {
+		return &File{&file{handle: newFileHandle(fd), name: name}}
+	}
 	return &File{&file{handle: stdioFileHandle(fd), name: name}}
 }
 
@@ -50,7 +59,8 @@ func Rename(oldpath, newpath string) error {
 	return ErrNotImplemented
 }
 
-// Read reads up to len(b) bytes from machine.Serial.
+// Read reads up to len(b) bytes from machine.Serial, or from the host on
+// wasm-unknown.
 // It returns the number of bytes read and any error encountered.
 func (f stdioFileHandle) Read(b []byte) (n int, err error) {
 	if len(b) == 0 {
@@ -62,6 +72,10 @@ func (f stdioFileHandle) Read(b []byte) (n int, err error) {
 		gosched()
 		size = buffered()
 	}
+	if size < 0 {
+		// Systems without any input report the end of the input.
+		return 0, io.EOF
+	}
 
 	if size > len(b) {
 		size = len(b)
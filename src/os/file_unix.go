@@ -1,8 +1,12 @@
-//go:build darwin || (linux && !baremetal) || wasip1
+//go:build darwin || (linux && !baremetal && !wasm_unknown) || wasip1
 
 // target wasi sets GOOS=linux and thus the +linux build tag,
 // even though it doesn't show up in "tinygo info target -wasi"
 
+// wasm-unknown targets also set GOOS=linux, but they have no libc to provide
+// the syscall functions used here. They use file_other.go instead, even
+// without the memfs build tag, so that the os package compiles for them.
+
 // Portions copyright 2009 The Go Authors. All rights reserved.
 // Use of this source code is governed by a BSD-style
 // license that can be found in the LICENSE file.
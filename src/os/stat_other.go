@@ -1,4 +1,4 @@
-//go:build baremetal || (wasm && !wasi && !wasip1)
+//go:build (baremetal || (wasm && !wasi && !wasip1) || wasm_unknown) && !memfs && !storagefs
 
 // Copyright 2016 The Go Authors. All rights reserved.
 // Use of this source code is governed by a BSD-style
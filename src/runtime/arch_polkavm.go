@@ -0,0 +1,33 @@
+//go:build polkavm
+
+package runtime
+
+const GOARCH = "arm" // riscv pretends to be arm
+
+// The bitness of the CPU (e.g. 8, 32, 64).
+const TargetBits = 32
+
+const deferExtraRegs = 0
+
+const callInstSize = 4 // 8 without relaxation, maybe 4 with relaxation
+
+// Heap objects are aligned to 8 bytes, the alignment of int64 and float64. The
+// ilp32e ABI only aligns the stack to 4 bytes.
+func align(ptr uintptr) uintptr {
+	return (ptr + 7) &^ 7
+}
+
+func getCurrentStackPointer() uintptr {
+	return uintptr(stacksave())
+}
+
+// PolkaVM runs the program on a single thread without interrupts, so there is
+// nothing to do here.
+
+//go:linkname procPin sync/atomic.runtime_procPin
+func procPin() {
+}
+
+//go:linkname procUnpin sync/atomic.runtime_procUnpin
+func procUnpin() {
+}
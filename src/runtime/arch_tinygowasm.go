@@ -21,6 +21,9 @@ var heapStartSymbol [0]byte
 //go:extern __global_base
 var globalsStartSymbol [0]byte
 
+//go:extern __data_end
+var dataEndSymbol [0]byte
+
 const (
 	// wasmMemoryIndex is always zero until the multi-memory feature is used.
 	//
@@ -59,10 +62,103 @@ var (
 
 	globalsStart = uintptr(unsafe.Pointer(&globalsStartSymbol))
 	globalsEnd   = uintptr(unsafe.Pointer(&heapStartSymbol))
+)
+
+// StackBounds returns the bounds of the system stack: the stack in linear
+// memory (also called the shadow stack) that is used when the host calls an
+// exported function. The stack grows down from top towards bottom.
+//
+// The bounds follow from the memory layout chosen by the linker, so they're
+// also valid when the module is entered through an exported function without
+// calling _start or _initialize first. With --stack-first (which all
+// WebAssembly targets use) the stack is placed below the globals, otherwise
+// it's placed between the globals and the heap.
+func StackBounds() (bottom, top uintptr) {
+	dataEnd := align(uintptr(unsafe.Pointer(&dataEndSymbol)))
+	heapBase := uintptr(unsafe.Pointer(&heapStartSymbol))
+	if heapBase > dataEnd {
+		// There is space reserved between the globals and the heap: this must
+		// be the stack.
+		return dataEnd, heapBase
+	}
+	return 0, uintptr(unsafe.Pointer(&globalsStartSymbol))
+}
 
-	stackTop = uintptr(unsafe.Pointer(&globalsStartSymbol))
+var (
+	// wasmInitialized is set once the heap and all packages are initialized,
+	// either by _start or _initialize or by the first exported function that
+	// is called.
+	wasmInitialized bool
+
+	// wasmEntryStackTop is the highest stack pointer that the host passed to
+	// an exported function. All stack frames of the program are below it.
+	wasmEntryStackTop uintptr
+
+	// wasmExportDepth is the number of exported functions that are currently
+	// running: more than one if the host called an exported function from an
+	// imported function.
+	wasmExportDepth uint32
 )
 
+// wasmExportEnter is called at the start of every exported function (except
+// _start and _initialize), with the stack pointer the host called it with.
+// Calls to it are inserted by the compiler, see transform.AddExportPrologues.
+//
+// Hosts like Substrate call exported functions without calling _initialize
+// first, so the program is initialized here in that case. Every call from the
+// host starts with a fresh budget, see SetCallBudget.
+//
+// Without a scheduler, the runtime doesn't expect to be entered again while
+// it's running: the host could call an exported function in the middle of a
+// GC cycle or while the runtime is writing debug output, for example from a
+// host allocator that calls back into the program. Such calls are rejected
+// with a trap. With a scheduler (like on the js target) nested calls are
+// expected, and only the outermost call resets the budget.
+//
+// With threads, several workers run exported functions at the same time, each
+// on its own stack, so this state can't be kept in globals. See threadEnter.
+func wasmExportEnter(sp uintptr) {
+	if hasThreads {
+		threadEnter()
+		return
+	}
+	if wasmExportDepth != 0 && !hasScheduler {
+		runtimePanicReason(returnAddress(0), trapReentry, 0, "exported function called while another exported function is running")
+	}
+	checkRedzone()
+	wasmExportDepth++
+	if sp > wasmEntryStackTop {
+		wasmEntryStackTop = sp
+	}
+	if wasmExportDepth == 1 {
+		resetCallBudget()
+	}
+	if !wasmInitialized {
+		wasmInitialize()
+	}
+}
+
+// wasmExportExit is called when an exported function returns to the host,
+// see wasmExportEnter.
+func wasmExportExit() {
+	if hasThreads {
+		threadExit()
+		return
+	}
+	checkRedzone()
+	wasmExportDepth--
+}
+
+// wasmExportAbort is called right before the program traps, which returns to
+// the host without calling wasmExportExit. The host may still call exported
+// functions after a trap (for example to read the exit code), which must not
+// be rejected as nested calls.
+func wasmExportAbort() {
+	if !hasThreads {
+		wasmExportDepth = 0
+	}
+}
+
 func align(ptr uintptr) uintptr {
 	// Align to 16, which is the alignment of max_align_t:
 	// https://godbolt.org/z/dYqTsWrGq
@@ -78,6 +174,7 @@ func getCurrentStackPointer() uintptr
 func growHeap() bool {
 	// Grow memory by the available size, which means the heap size is doubled.
 	memorySize := wasm_memory_size(wasmMemoryIndex)
+	payForMemoryGrow(memorySize)
 	result := wasm_memory_grow(wasmMemoryIndex, memorySize)
 	if result == -1 {
 		// Grow failed.
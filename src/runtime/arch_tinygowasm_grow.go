@@ -0,0 +1,8 @@
+//go:build tinygo.wasm && !stylus
+
+package runtime
+
+// payForMemoryGrow is called before growing the memory by the given number of
+// pages. Only Stylus needs to do something here.
+func payForMemoryGrow(pages int32) {
+}
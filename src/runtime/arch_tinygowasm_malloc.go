@@ -1,4 +1,4 @@
-//go:build tinygo.wasm && !(custommalloc || wasm_unknown)
+//go:build tinygo.wasm && !(custommalloc || wasm_unknown || gc.extalloc)
 
 package runtime
 
@@ -7,6 +7,8 @@ import "unsafe"
 // The below functions override the default allocator of wasi-libc. This ensures
 // code linked from other languages can allocate memory without colliding with
 // our GC allocations.
+// With the extalloc GC it's the other way around: the GC allocates all its
+// memory using the wasi-libc allocator, so it must not be overridden.
 
 var allocs = make(map[uintptr][]byte)
 
@@ -0,0 +1,90 @@
+// Package benchmark runs benchmarks inside a WebAssembly module, with the time
+// measured by the host. This makes it possible to compare numbers with other
+// implementations that are run in the same host, without relying on a clock
+// inside the module.
+//
+// Benchmarks are exported functions with a name that starts with "bench_",
+// which return the result of Report:
+//
+//	//export bench_decode
+//	func benchDecode() uint64 {
+//		return benchmark.Report(benchmark.Run("Decode", 1000, func() {
+//			...
+//		}))
+//	}
+//
+// The benchmarks can then be run with `tinygo wasmhost -bench=. file.wasm`.
+// The host must provide the tinygo_benchmark_start and tinygo_benchmark_stop
+// functions in the "env" module.
+package benchmark
+
+import (
+	"runtime"
+	"strconv"
+	"unsafe"
+)
+
+//go:wasmimport env tinygo_benchmark_start
+func hostStart()
+
+//go:wasmimport env tinygo_benchmark_stop
+func hostStop() uint64
+
+// Result is the result of a single benchmark.
+type Result struct {
+	Name        string
+	N           int    // number of iterations
+	Nanoseconds uint64 // total time of all iterations, measured by the host
+	Mallocs     uint64 // total number of heap allocations
+	AllocBytes  uint64 // total number of bytes allocated
+}
+
+// Run calls fn n times and returns the time it took and the number of heap
+// allocations done.
+func Run(name string, n int, fn func()) Result {
+	var before, after runtime.MemStats
+	runtime.ReadMemStats(&before)
+	hostStart()
+	for i := 0; i < n; i++ {
+		fn()
+	}
+	ns := hostStop()
+	runtime.ReadMemStats(&after)
+	return Result{
+		Name:        name,
+		N:           n,
+		Nanoseconds: ns,
+		Mallocs:     after.Mallocs - before.Mallocs,
+		AllocBytes:  after.TotalAlloc - before.TotalAlloc,
+	}
+}
+
+// The last report, which must stay alive until the host has read it.
+var report []byte
+
+// Report encodes the results as a JSON array for the host, and returns its
+// location as a pointer-size: the pointer in the low 32 bits and the length in
+// the high 32 bits. This is the value that must be returned from a benchmark
+// function.
+func Report(results ...Result) uint64 {
+	buf := append(report[:0], '[')
+	for i, r := range results {
+		if i != 0 {
+			buf = append(buf, ',')
+		}
+		buf = append(buf, `{"name":`...)
+		buf = strconv.AppendQuote(buf, r.Name)
+		buf = append(buf, `,"n":`...)
+		buf = strconv.AppendInt(buf, int64(r.N), 10)
+		buf = append(buf, `,"ns":`...)
+		buf = strconv.AppendUint(buf, r.Nanoseconds, 10)
+		buf = append(buf, `,"mallocs":`...)
+		buf = strconv.AppendUint(buf, r.Mallocs, 10)
+		buf = append(buf, `,"bytes":`...)
+		buf = strconv.AppendUint(buf, r.AllocBytes, 10)
+		buf = append(buf, '}')
+	}
+	buf = append(buf, ']')
+	report = buf
+	return uint64(uintptr(unsafe.Pointer(&buf[0]))) | uint64(len(buf))<<32
+}
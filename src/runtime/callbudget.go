@@ -0,0 +1,52 @@
+package runtime
+
+// Per-call allocation budgets. Hosts like Substrate limit the memory and time
+// a call may use and trap when the limit is hit, which fails the whole block
+// instead of just the extrinsic that used too much. A budget lets the program
+// fail earlier with a clear reason that the host can report.
+
+var (
+	callBudgetAllocs uintptr // maximum number of allocations, 0 for no limit
+	callBudgetBytes  uintptr // maximum number of bytes allocated, 0 for no limit
+	callAllocs       uintptr // allocations since the start of the call
+	callBytes        uintptr // bytes allocated since the start of the call
+)
+
+// SetCallBudget limits the number of allocations and the number of bytes
+// allocated by the current call. On WebAssembly, the budget is counted from
+// the start of every exported function the host calls, so it only needs to be
+// set once, for example in an init function. Elsewhere it's counted from the
+// call to SetCallBudget. A limit of 0 means no limit.
+//
+// An allocation that goes over the budget panics with a runtime error, which
+// is recorded as a call budget trap for the host (see recordTrap). Memory that
+// is freed again is still counted, as the budget is meant to bound the work
+// done by a call and not the size of the heap.
+func SetCallBudget(maxAllocs, maxBytes uintptr) {
+	callBudgetAllocs = maxAllocs
+	callBudgetBytes = maxBytes
+	resetCallBudget()
+}
+
+// resetCallBudget starts counting the budget from zero. It's called at the start
+// of every exported function, see wasmExportEnter.
+func resetCallBudget() {
+	callAllocs = 0
+	callBytes = 0
+}
+
+// checkCallBudget counts an allocation of the given size against the budget, and
+// panics if it goes over the budget. It's called by the allocator before the
+// memory is allocated.
+//
+//go:inline
+func checkCallBudget(size uintptr) {
+	callAllocs++
+	callBytes += size
+	if callBudgetAllocs != 0 && callAllocs > callBudgetAllocs {
+		runtimePanicReason(returnAddress(0), trapCallBudget, size, "call budget exceeded: too many allocations")
+	}
+	if callBudgetBytes != 0 && callBytes > callBudgetBytes {
+		runtimePanicReason(returnAddress(0), trapCallBudget, size, "call budget exceeded: too many bytes allocated")
+	}
+}
@@ -0,0 +1,26 @@
+//go:build tinygo.cover
+
+package runtime
+
+// Coverage counters for -cover. The compiler adds a counter to every basic
+// block in the covered packages, and points coverageCounters to them. The
+// counters are described by the tinygo.coverage custom section that is added
+// to the WebAssembly file, in the same order.
+
+import "unsafe"
+
+// Coverage counters, set by the compiler.
+var coverageCounters []uint32
+
+// Return the location of the coverage counters as a pointer-size: the pointer
+// in the low 32 bits and the number of bytes in the high 32 bits. The location
+// doesn't change, so the host can call this before starting the program and
+// read the counters when it has exited.
+//
+//export tinygo_coverage
+func coverageLocation() uint64 {
+	if len(coverageCounters) == 0 {
+		return 0
+	}
+	return uint64(uintptr(unsafe.Pointer(&coverageCounters[0]))) | uint64(len(coverageCounters)*4)<<32
+}
@@ -0,0 +1,66 @@
+//go:build tinygo.wasm && tinygo.crashdump
+
+package runtime
+
+// Crash dumps for -crash-dump. When the program traps through the runtime (see
+// recordTrap), it saves some of its state in a fixed location in linear memory
+// that the host can read after the trap through the tinygo_crash_dump export.
+// On-chain hosts usually don't show the debug output of a program, so the most
+// recent output is kept in the crash dump as well.
+
+import "unsafe"
+
+const crashDumpLogSize = 256
+
+// crashDumpRecord is the crash dump as stored in linear memory. Keep this in
+// sync with wasmhost/crashdump.go.
+type crashDumpRecord struct {
+	captured      uint32 // 1 once the state below was captured
+	sp            uint32 // stack pointer when the trap was recorded
+	stackTop      uint32 // top of the stack, see wasmEntryStackTop
+	heapStart     uint32
+	heapEnd       uint32
+	heapInuse     uint32 // bytes of the heap in use, from ReadMemStats
+	mallocs       uint32
+	frees         uint32
+	exportName    uint32 // pointer to the name of the current exported function
+	exportNameLen uint32
+	logWritten    uint32                 // total number of bytes written to log
+	log           [crashDumpLogSize]byte // ring buffer with the last debug output
+}
+
+var crashDump crashDumpRecord
+
+// crashDumpPutchar records a byte of debug output.
+func crashDumpPutchar(c byte) {
+	crashDump.log[crashDump.logWritten%crashDumpLogSize] = c
+	crashDump.logWritten++
+}
+
+// crashDumpExport records the name of the exported function the host called.
+// Calls to it are inserted by the compiler, see transform.AddExportPrologues.
+func crashDumpExport(name *byte, length uintptr) {
+	crashDump.exportName = uint32(uintptr(unsafe.Pointer(name)))
+	crashDump.exportNameLen = uint32(length)
+}
+
+// captureCrashDump saves the state of the program, just before it traps.
+func captureCrashDump() {
+	crashDump.sp = uint32(getCurrentStackPointer())
+	crashDump.stackTop = uint32(wasmEntryStackTop)
+	crashDump.heapStart = uint32(heapStart)
+	crashDump.heapEnd = uint32(heapEnd)
+	var m MemStats
+	ReadMemStats(&m)
+	crashDump.heapInuse = uint32(m.HeapInuse)
+	crashDump.mallocs = uint32(m.Mallocs)
+	crashDump.frees = uint32(m.Frees)
+	crashDump.captured = 1
+}
+
+// Return the address of the crash dump, see crashDumpRecord.
+//
+//export tinygo_crash_dump
+func exportCrashDump() uint32 {
+	return uint32(uintptr(unsafe.Pointer(&crashDump)))
+}
@@ -0,0 +1,11 @@
+//go:build !(tinygo.wasm && tinygo.crashdump)
+
+package runtime
+
+// Crash dumps are only recorded with -crash-dump.
+
+func crashDumpPutchar(c byte) {
+}
+
+func captureCrashDump() {
+}
@@ -21,22 +21,57 @@ func Stack() []byte {
 	return nil
 }
 
+// Build information of the program, set by the compiler.
+var (
+	buildGoVersion string // Go version of the standard library
+	buildPath      string // import path of the main package
+	buildModule    string // module path of the main package
+	buildSettings  string // key=value pairs, one per line
+)
+
 // ReadBuildInfo returns the build information embedded
 // in the running binary. The information is available only
 // in binaries built with module support.
 //
-// Not implemented.
+// The dependencies of the main module are not included. The settings describe
+// the compiler (including the TinyGo version and the version of the LimeChain
+// fork), the target and the GC.
 func ReadBuildInfo() (info *BuildInfo, ok bool) {
-	return nil, false
+	if buildPath == "" {
+		return nil, false
+	}
+	info = &BuildInfo{
+		GoVersion: buildGoVersion,
+		Path:      buildPath,
+	}
+	if buildModule != "" {
+		info.Main = Module{Path: buildModule, Version: "(devel)"}
+	}
+	start := 0
+	for i := 0; i <= len(buildSettings); i++ {
+		if i < len(buildSettings) && buildSettings[i] != '\n' {
+			continue
+		}
+		line := buildSettings[start:i]
+		start = i + 1
+		for j := 0; j < len(line); j++ {
+			if line[j] == '=' {
+				info.Settings = append(info.Settings, BuildSetting{Key: line[:j], Value: line[j+1:]})
+				break
+			}
+		}
+	}
+	return info, true
 }
 
 // BuildInfo represents the build information read from
 // the running binary.
 type BuildInfo struct {
-	Path     string    // The main package path
-	Main     Module    // The module containing the main package
-	Deps     []*Module // Module dependencies
-	Settings []BuildSetting
+	GoVersion string    // Version of Go that produced this binary.
+	Path      string    // The main package path
+	Main      Module    // The module containing the main package
+	Deps      []*Module // Module dependencies
+	Settings  []BuildSetting
 }
 
 type BuildSetting struct {
@@ -0,0 +1,127 @@
+// Package eventqueue provides a deterministic publish/subscribe event queue.
+//
+// Programs built with -scheduler=none have no goroutines, which means that a
+// channel operation that cannot complete immediately will never complete. A
+// common pattern in Go code is to send events over a channel to a goroutine
+// that handles them in a loop. With an event queue, this becomes: subscribe a
+// handler instead of starting a goroutine, publish an event instead of sending
+// on a channel, and call Process where the goroutine would otherwise have been
+// scheduled.
+//
+// Events are delivered in the order in which they were published and handlers
+// are called in the order in which they were subscribed, so processing is
+// fully deterministic. This makes the package also suitable for environments
+// (like blockchain runtimes) where every execution must produce the same
+// result.
+package eventqueue
+
+// Handler is called for every event published to a topic it is subscribed to.
+type Handler[T any] func(topic string, event T)
+
+// Subscription identifies a subscribed handler. It can be passed to
+// Unsubscribe.
+type Subscription uint32
+
+type subscriber[T any] struct {
+	id      Subscription
+	topic   string
+	handler Handler[T]
+}
+
+type event[T any] struct {
+	topic string
+	value T
+}
+
+// Queue is a FIFO event queue. The zero value is an empty queue without
+// subscribers, ready to use.
+type Queue[T any] struct {
+	events      []event[T]
+	head        int // index of the oldest pending event in events
+	subscribers []subscriber[T]
+	lastID      Subscription
+}
+
+// Subscribe registers a handler for all events published to the given topic.
+// An empty topic subscribes the handler to all events.
+func (q *Queue[T]) Subscribe(topic string, handler Handler[T]) Subscription {
+	q.lastID++
+	q.subscribers = append(q.subscribers, subscriber[T]{
+		id:      q.lastID,
+		topic:   topic,
+		handler: handler,
+	})
+	return q.lastID
+}
+
+// Unsubscribe removes a handler that was previously registered with Subscribe.
+// When called from within a handler, the change takes effect starting with the
+// next event.
+func (q *Queue[T]) Unsubscribe(s Subscription) {
+	for i, sub := range q.subscribers {
+		if sub.id == s {
+			// Build a new slice instead of modifying the existing one, as it
+			// may currently be in use by Step.
+			q.subscribers = append(q.subscribers[:i:i], q.subscribers[i+1:]...)
+			return
+		}
+	}
+}
+
+// Publish adds an event to the end of the queue. It does not call any
+// handlers: events are only delivered by Step and Process. It is safe to
+// publish new events from within a handler.
+func (q *Queue[T]) Publish(topic string, value T) {
+	q.events = append(q.events, event[T]{topic: topic, value: value})
+}
+
+// Len returns the number of events that have been published but not yet
+// delivered.
+func (q *Queue[T]) Len() int {
+	return len(q.events) - q.head
+}
+
+// Step delivers the oldest pending event to all subscribed handlers. It
+// returns false if there was no event to deliver.
+func (q *Queue[T]) Step() bool {
+	if q.head == len(q.events) {
+		return false
+	}
+
+	// Remove the event from the queue before calling any handlers, so that
+	// handlers can publish new events.
+	ev := q.events[q.head]
+	q.events[q.head] = event[T]{} // don't keep the value alive
+	q.head++
+	if q.head == len(q.events) {
+		q.events = q.events[:0]
+		q.head = 0
+	} else if q.head >= 16 && q.head*2 >= len(q.events) {
+		// Move pending events to the start of the slice, to avoid growing
+		// it indefinitely when the queue is never fully drained.
+		n := copy(q.events, q.events[q.head:])
+		for i := n; i < len(q.events); i++ {
+			q.events[i] = event[T]{}
+		}
+		q.events = q.events[:n]
+		q.head = 0
+	}
+
+	for _, sub := range q.subscribers {
+		if sub.topic == "" || sub.topic == ev.topic {
+			sub.handler(ev.topic, ev.value)
+		}
+	}
+	return true
+}
+
+// Process delivers pending events until the queue is empty, including events
+// published by handlers while processing. It returns the number of events
+// delivered.
+func (q *Queue[T]) Process() int {
+	n := 0
+	for q.Step() {
+		n++
+	}
+	return n
+}
@@ -4,13 +4,15 @@ func Callers(skip int, pc []uintptr) int {
 	return 0
 }
 
-// buildVersion is the Tinygo tree's version string at build time.
+// buildVersion is the version of the compiler that built the program, together
+// with the GC and the target, like "0.31.2-limechain.1 gc=leaking
+// target=wasm-unknown".
 //
 // This is set by the linker.
 var buildVersion string
 
-// Version returns the Tinygo tree's version string.
-// It is the same as goenv.Version().
+// Version returns the version of the TinyGo fork that built the program, the
+// TinyGo version it is based on, and the GC and target that were used.
 func Version() string {
 	return buildVersion
 }
@@ -52,9 +52,6 @@ var (
 	metadataStart unsafe.Pointer // pointer to the start of the heap metadata
 	nextAlloc     gcBlock        // the next block that should be tried by the allocator
 	endBlock      gcBlock        // the block just past the end of the available space
-	gcTotalAlloc  uint64         // total number of bytes allocated
-	gcMallocs     uint64         // total number of allocations
-	gcFrees       uint64         // total number of objects freed
 )
 
 // zeroSizedAlloc is just a sentinel that gets returned when allocating 0 bytes.
@@ -281,8 +278,8 @@ func alloc(size uintptr, layout unsafe.Pointer) unsafe.Pointer {
 		runtimePanicAt(returnAddress(0), "heap alloc in interrupt")
 	}
 
-	gcTotalAlloc += uint64(size)
-	gcMallocs++
+	checkCallBudget(size)
+	gcCountAlloc(size)
 
 	neededBlocks := (size + (bytesPerBlock - 1)) / bytesPerBlock
 
@@ -318,7 +315,7 @@ func alloc(size uintptr, layout unsafe.Pointer) unsafe.Pointer {
 					// Unfortunately the heap could not be increased. This
 					// happens on baremetal systems for example (where all
 					// available RAM has already been dedicated to the heap).
-					runtimePanicAt(returnAddress(0), "out of memory")
+					runtimePanicReason(returnAddress(0), trapOutOfMemory, size, "out of memory")
 				}
 			}
 		}
@@ -379,6 +376,12 @@ func alloc(size uintptr, layout unsafe.Pointer) unsafe.Pointer {
 	}
 }
 
+// roundupAllocSize returns the number of bytes that alloc reserves for an
+// object of the given size: a whole number of blocks.
+func roundupAllocSize(size uintptr) uintptr {
+	return (size + (bytesPerBlock - 1)) &^ (bytesPerBlock - 1)
+}
+
 func realloc(ptr unsafe.Pointer, size uintptr) unsafe.Pointer {
 	if ptr == nil {
 		return alloc(size, nil)
@@ -414,6 +417,11 @@ func GC() {
 // of the runtime.GC() function. The difference is that it returns the number of
 // free bytes in the heap after the GC is finished.
 func runGC() (freeBytes uintptr) {
+	if noGCDepth != 0 {
+		// Not allowed to run right now, see EnterNoGC.
+		gcDeferred = true
+		return 0
+	}
 	if gcDebug {
 		println("running collection cycle...")
 	}
@@ -625,7 +633,7 @@ func sweep() (freeBytes uintptr) {
 			// Unmarked head. Free it, including all tail blocks following it.
 			block.markFree()
 			freeCurrentObject = true
-			gcFrees++
+			gcCountFrees(1)
 			freeBytes += bytesPerBlock
 		case blockStateTail:
 			if freeCurrentObject {
@@ -668,6 +676,37 @@ func dumpHeap() {
 	}
 }
 
+// gcSelfcheck checks the invariants of the heap outside of a collection cycle:
+// the metadata is within the heap, every object starts with a head block, no
+// blocks are marked, and the number of objects matches the statistics. It
+// returns the invariants that don't hold, as selfcheck bits.
+func gcSelfcheck() (failed uint32) {
+	if heapStart > uintptr(metadataStart) || uintptr(metadataStart) > heapEnd || uintptr(endBlock)*bytesPerBlock > uintptr(metadataStart)-heapStart {
+		return selfcheckHeapBounds
+	}
+	objects := uint64(0)
+	prev := blockStateFree
+	for block := gcBlock(0); block < endBlock; block++ {
+		state := block.state()
+		switch state {
+		case blockStateHead:
+			objects++
+		case blockStateTail:
+			if prev == blockStateFree {
+				// Tail block that doesn't belong to any object.
+				failed |= selfcheckHeapOrder
+			}
+		case blockStateMark:
+			failed |= selfcheckHeapState
+		}
+		prev = state
+	}
+	if gcFrees > gcMallocs || objects != gcMallocs-gcFrees {
+		failed |= selfcheckHeapStats
+	}
+	return
+}
+
 // ReadMemStats populates m with memory statistics.
 //
 // The returned memory statistics are up to date as of the
@@ -686,9 +725,7 @@ func ReadMemStats(m *MemStats) {
 	m.HeapReleased = 0 // always 0, we don't currently release memory back to the OS.
 	m.HeapSys = m.HeapInuse + m.HeapIdle
 	m.GCSys = uint64(heapEnd - uintptr(metadataStart))
-	m.TotalAlloc = gcTotalAlloc
-	m.Mallocs = gcMallocs
-	m.Frees = gcFrees
+	readGCStats(m)
 	m.Sys = uint64(heapEnd - heapStart)
 }
 
@@ -0,0 +1,57 @@
+package runtime
+
+// Sections in which no garbage collection cycle may run, for example while
+// the host holds raw pointers into the heap.
+
+// Both variables are only accessed with the GC lock held. The nesting depth
+// of every worker is kept separately (see threadNoGCDepth), so that a worker
+// can't end the section of another worker.
+var (
+	noGCDepth  uint32 // number of no-GC sections running in all workers
+	gcDeferred bool   // a collection cycle was requested during a no-GC section
+)
+
+// EnterNoGC starts a section in which no garbage collection cycle runs. Use it
+// around code that passes pointers to heap objects to the host (for example,
+// as integers to an imported function), when the host may still use them after
+// the last use of the pointer in Go code: the compiler may drop the last
+// reference before the host is done with it, and the object must not be freed
+// in the meantime.
+//
+// Allocations inside the section grow the heap instead of running a
+// collection cycle, and calls to GC are deferred until the section ends. Calls
+// to EnterNoGC can be nested, and every call must be paired with a call to
+// ExitNoGC.
+//
+// This is only supported by the conservative, precise and extalloc GCs. The
+// other GCs never free memory, except for gc.custom which must implement it
+// itself if needed. With threads, a collection cycle doesn't run while any
+// worker is in a no-GC section.
+func EnterNoGC() {
+	gcLock()
+	depth := threadNoGCDepth()
+	*depth++
+	noGCDepth++
+	gcUnlock()
+}
+
+// ExitNoGC ends a section started with EnterNoGC. When it ends the outermost
+// section, it runs a collection cycle if one was requested inside the section.
+func ExitNoGC() {
+	gcLock()
+	depth := threadNoGCDepth()
+	if *depth == 0 {
+		gcUnlock()
+		runtimePanic("ExitNoGC without EnterNoGC")
+	}
+	*depth--
+	noGCDepth--
+	runDeferred := noGCDepth == 0 && gcDeferred
+	if runDeferred {
+		gcDeferred = false
+	}
+	gcUnlock()
+	if runDeferred {
+		GC()
+	}
+}
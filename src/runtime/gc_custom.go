@@ -45,6 +45,13 @@ func alloc(size uintptr, layout unsafe.Pointer) unsafe.Pointer
 // free is called to explicitly free a previously allocated pointer.
 func free(ptr unsafe.Pointer)
 
+// roundupAllocSize returns the number of bytes that alloc reserves for an
+// object of the given size. It isn't known for a custom GC, so it is assumed
+// that alloc doesn't reserve more than requested.
+func roundupAllocSize(size uintptr) uintptr {
+	return size
+}
+
 // markRoots is called with the start and end addresses to scan for references.
 // It is currently only called with the top and bottom of the stack.
 func markRoots(start, end uintptr)
@@ -58,6 +65,12 @@ func SetFinalizer(obj interface{}, finalizer interface{})
 // ReadMemStats populates m with memory statistics.
 func ReadMemStats(ms *MemStats)
 
+// gcSelfcheck returns the heap invariants that don't hold, as selfcheck bits.
+// The heap of a custom GC can't be checked by the runtime.
+func gcSelfcheck() uint32 {
+	return 0
+}
+
 func setHeapEnd(newHeapEnd uintptr) {
 	// Heap is in custom GC so ignore for when called from wasm initialization.
 }
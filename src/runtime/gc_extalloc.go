@@ -0,0 +1,1011 @@
+//go:build gc.extalloc
+
+package runtime
+
+// This GC implementation gets its memory from an allocator outside of the
+// WebAssembly module: the embedder provides a malloc and free function, which
+// are imported as extalloc and extfree. The names under which they're imported
+// can be changed with the extalloc-malloc and extalloc-free target properties.
+// On targets that link wasi-libc (like -target=wasi), the malloc and free of
+// wasi-libc are used instead, unless these properties are set.
+//
+// Because the allocator isn't under our control, the GC keeps its own index of
+// all objects it allocated: an array of start and end addresses that is kept
+// sorted by start address during a collection cycle. The collector itself is a
+// conservative mark/sweep collector like gc.conservative: everything that
+// looks like a pointer into an object keeps that object alive, and all
+// unreachable objects are handed back to the external allocator.
+//
+// Most objects are tiny and short-lived, and adding each of them to the index
+// (and sorting them during the next collection cycle) would be the main cost
+// of the GC. Therefore small objects are allocated in a nursery instead: a
+// chunk of memory from the external allocator in which objects are allocated
+// by bumping a pointer, and which has a single entry in the index. A chunk
+// keeps track of its objects using bitmaps. Objects are never moved, so objects
+// that survive a collection cycle stay in their chunk, which stays in the index
+// until all objects in it are unreachable. The memory of unreachable objects
+// in a chunk is not reused.
+//
+// Hosts may limit the amount of memory that can be allocated, and fail with an
+// unhelpful error (or trap) when that limit is exceeded. The heap limit can be
+// set with the extalloc-limit target property, or queried from the host with
+// the import in the extalloc-limit-fn property. The GC never allocates more
+// than this limit from the external allocator: it collects more often as the
+// limit comes closer, and panics with a description of the heap when an
+// allocation doesn't fit even after a collection cycle.
+//
+// When an allocation fails even after a collection cycle (whether because of
+// the heap limit or because the external allocator returned nil), it is
+// retried according to the out of memory strategy: every retry signals memory
+// pressure to the handlers registered with RegisterMemoryPressureHandler and
+// runs another collection cycle. See SetOutOfMemoryStrategy. The handlers are
+// also called when the heap is still big after a regular collection cycle,
+// see SetMemoryPressureThresholds.
+
+import (
+	"unsafe"
+)
+
+const extallocDebug = false
+
+// Allocate size bytes of memory in the external allocator. It returns nil when
+// no memory could be allocated.
+//
+//export extalloc
+func extalloc(size uintptr) unsafe.Pointer
+
+// Free memory previously allocated with extalloc.
+//
+//export extfree
+func extfree(ptr unsafe.Pointer)
+
+// Return the maximum number of bytes that may be allocated with extalloc, or
+// zero if there is no limit. This function is defined by the compiler, unless
+// it is imported from the host (see the extalloc-limit-fn target property).
+//
+//export extlimit
+func extlimit() uintptr
+
+// extallocObject is a single entry in the object index. The lowest bits of end
+// are used as flags, which is possible because all object sizes are rounded
+// up to the heap alignment.
+type extallocObject struct {
+	start uintptr
+	end   uintptr
+}
+
+const (
+	extallocMarkBit  = 1 // object is marked (for a chunk: some object in it is marked)
+	extallocChunkBit = 2 // entry is a nursery chunk, see extallocChunk
+	extallocFlags    = extallocMarkBit | extallocChunkBit
+)
+
+var (
+	extallocObjects   unsafe.Pointer // index of all objects, an array of extallocObject
+	extallocCap       uintptr        // capacity of the object index
+	extallocLen       uintptr        // number of objects in the object index
+	extallocSorted    uintptr        // number of objects at the start of the index that are sorted
+	extallocMin       uintptr        // lowest object start address
+	extallocMax       uintptr        // highest object end address
+	extallocLive      uintptr        // number of bytes in use by all objects
+	extallocNextGC    uintptr        // run a GC cycle when extallocLive reaches this value
+	extallocOverflown bool           // mark stack overflowed, rescan marked objects
+	extallocNursery   *extallocChunk // chunk in which small objects are allocated
+	extallocLimit     uintptr        // maximum number of bytes allocated from the external allocator, or 0
+	extallocHeld      uintptr        // number of bytes currently allocated from the external allocator
+	extallocReserved  uintptr        // same, including bucket slack and headers of the external allocator
+)
+
+// How the external allocator rounds up allocations, set by the compiler from
+// the extalloc-bucket and extalloc-header target properties. Allocators like
+// the FreeingBumpHeapAllocator of Substrate round every size up to a power of
+// two of at least extallocBucket bytes, and put a header of extallocHeader
+// bytes in front of it. extallocBucket is zero if the allocator doesn't use
+// buckets.
+var (
+	extallocBucket uintptr
+	extallocHeader uintptr
+)
+
+// Minimum amount of memory that must be allocated before the first GC cycle
+// runs, and the minimum capacity of the object index.
+const (
+	extallocMinHeap  = 64 * 1024
+	extallocMinIndex = 64
+)
+
+// Gaps between objects smaller than this are not counted in the fragmentation
+// statistics. They're usually just the chunk headers of the external
+// allocator.
+const extallocMinGap = 8 * unsafe.Sizeof(uintptr(0))
+
+// Objects of at most extallocSmallObject bytes are allocated in chunks of
+// extallocChunkSize bytes, in units of extallocGranule bytes (which is at least
+// the heap alignment on all architectures).
+const (
+	extallocSmallObject   = 256
+	extallocChunkSize     = 4096
+	extallocGranule       = 16
+	extallocChunkGranules = extallocChunkSize / extallocGranule
+)
+
+// extallocChunk is the header of a nursery chunk, which is followed by the
+// objects in the chunk. The bitmaps have a bit for every granule, which is
+// set for the first granule of an object.
+type extallocChunk struct {
+	top    uintptr        // end of the last object in the chunk
+	live   uintptr        // number of bytes in live objects
+	starts extallocBitmap // start of every object, reachable or not
+	alive  extallocBitmap // start of every object that wasn't freed by a collection cycle
+	marks  extallocBitmap // start of every marked object, during a collection cycle
+}
+
+type extallocBitmap [extallocChunkGranules / 8]uint8
+
+func (b *extallocBitmap) get(i uintptr) bool {
+	return b[i/8]&(1<<(i%8)) != 0
+}
+
+func (b *extallocBitmap) set(i uintptr) {
+	b[i/8] |= 1 << (i % 8)
+}
+
+func (b *extallocBitmap) clear(i uintptr) {
+	b[i/8] &^= 1 << (i % 8)
+}
+
+// data returns the address of the first object in the chunk.
+func (c *extallocChunk) data() uintptr {
+	return (uintptr(unsafe.Pointer(c)) + unsafe.Sizeof(extallocChunk{}) + extallocGranule - 1) &^ (extallocGranule - 1)
+}
+
+// objectEnd returns the index of the first granule after the object that
+// starts at the given granule.
+func (c *extallocChunk) objectEnd(granule uintptr) uintptr {
+	n := (c.top - c.data()) / extallocGranule
+	end := granule + 1
+	for end < n && !c.starts.get(end) {
+		end++
+	}
+	return end
+}
+
+// find returns the bounds of the live object in the chunk that contains addr.
+// It returns false if addr doesn't point into a live object.
+func (c *extallocChunk) find(addr uintptr) (start, end uintptr, ok bool) {
+	data := c.data()
+	if addr < data || addr >= c.top {
+		return 0, 0, false
+	}
+	granule := (addr - data) / extallocGranule
+	for !c.starts.get(granule) {
+		// Objects are small, so this loop runs at most a few times. The first
+		// granule always starts an object.
+		granule--
+	}
+	if !c.alive.get(granule) {
+		return 0, 0, false
+	}
+	return data + granule*extallocGranule, data + c.objectEnd(granule)*extallocGranule, true
+}
+
+// sweep frees all objects in the chunk that weren't marked, and clears the mark
+// bits. It returns the number of objects that were freed.
+func (c *extallocChunk) sweep() (freed uint64) {
+	n := (c.top - c.data()) / extallocGranule
+	c.live = 0
+	for granule := uintptr(0); granule < n; {
+		end := c.objectEnd(granule)
+		if c.alive.get(granule) {
+			if c.marks.get(granule) {
+				c.live += (end - granule) * extallocGranule
+			} else {
+				c.alive.clear(granule)
+				freed++
+			}
+		}
+		granule = end
+	}
+	c.marks = extallocBitmap{}
+	return freed
+}
+
+// zeroSizedAlloc is just a sentinel that gets returned when allocating 0 bytes.
+var zeroSizedAlloc uint8
+
+// extallocObjectAt returns the object at the given index.
+func extallocObjectAt(index uintptr) *extallocObject {
+	return (*extallocObject)(unsafe.Add(extallocObjects, index*unsafe.Sizeof(extallocObject{})))
+}
+
+func initHeap() {
+	extallocLimit = extlimit()
+	extallocSetNextGC(0)
+	if extallocLimit != 0 {
+		SetMemoryPressureThresholds(extallocLimit/2, extallocLimit/4*3)
+	}
+}
+
+func setHeapEnd(newHeapEnd uintptr) {
+	// Nothing to do here, the heap is managed by the external allocator.
+}
+
+// alloc allocates memory from the external allocator, possibly doing a garbage
+// collection cycle if needed. If no memory could be allocated, it panics.
+//
+//go:noinline
+func alloc(size uintptr, layout unsafe.Pointer) unsafe.Pointer {
+	if size == 0 {
+		return unsafe.Pointer(&zeroSizedAlloc)
+	}
+	size = roundupAllocSize(size)
+	small := size <= extallocSmallObject
+	checkCallBudget(size)
+
+	gcLock()
+	if extallocLive+size >= extallocNextGC {
+		runGC()
+
+		// Let the program free caches if the heap is still big.
+		if level := memoryPressureLevel(extallocHeld); level != 0 {
+			signalMemoryPressure(level)
+		}
+	}
+
+	var ptr unsafe.Pointer
+	if small {
+		ptr = extallocNurseryAlloc(size)
+	}
+	if ptr == nil {
+		// Not a small object, or no new chunk could be allocated.
+		ptr = extallocAllocObject(size)
+		if ptr == nil {
+			if extallocLimit != 0 && extallocHeld+size > extallocLimit {
+				// Describe the heap, otherwise it's hard to know whether
+				// the limit is too low or there is a memory leak.
+				println("extalloc: could not allocate", size, "bytes: heap limit of", extallocLimit, "bytes reached")
+				println("extalloc:", extallocHeld, "bytes allocated,", extallocLive, "bytes in use by", extallocLen, "objects")
+			}
+			if extallocDebug {
+				println("extalloc: could not allocate", size, "bytes")
+				extallocDump()
+			}
+			runtimePanicReason(returnAddress(0), trapOutOfMemory, size, "out of memory")
+		}
+	}
+	if extallocDebug {
+		println("extalloc:", ptr, size)
+	}
+	memzero(ptr, size)
+
+	extallocLive += size
+	gcCountAlloc(size)
+	gcUnlock()
+	return ptr
+}
+
+// roundupAllocSize returns the number of bytes that alloc reserves for an
+// object of the given size. Large objects get the whole bucket of the external
+// allocator, so that for example a growing slice uses the slack as capacity.
+func roundupAllocSize(size uintptr) uintptr {
+	if size <= extallocSmallObject {
+		return (size + extallocGranule - 1) &^ (extallocGranule - 1)
+	}
+	return extallocBucketSize(align(size))
+}
+
+// extallocBucketSize returns the size of the bucket the external allocator
+// uses for an allocation of the given size, not including its header.
+func extallocBucketSize(size uintptr) uintptr {
+	if extallocBucket == 0 {
+		return size
+	}
+	bucket := extallocBucket
+	for bucket < size {
+		if bucket > ^uintptr(0)/2 {
+			return size // can't be allocated anyway
+		}
+		bucket *= 2
+	}
+	return bucket
+}
+
+// extallocAllocObject allocates memory from the external allocator and adds it
+// to the object index, doing a garbage collection cycle if there is no memory
+// left. It returns nil if no memory could be allocated.
+func extallocAllocObject(size uintptr) unsafe.Pointer {
+	// Make sure there is space in the index for the new object, before
+	// allocating the object itself. Otherwise the new object wouldn't be
+	// reachable during a GC cycle triggered by growing the index.
+	if extallocLen == extallocCap && !extallocGrowIndex() {
+		runGC()
+		for attempt := 1; extallocLen == extallocCap && !extallocGrowIndex(); attempt++ {
+			if !extallocRetry(attempt, 2*extallocCap*unsafe.Sizeof(extallocObject{})) {
+				return nil
+			}
+		}
+	}
+
+	ptr := extallocExternal(size)
+	if ptr == nil {
+		// Try once more after freeing all unreachable objects, and then as
+		// often as the out of memory strategy allows.
+		runGC()
+		ptr = extallocExternal(size)
+		for attempt := 1; ptr == nil; attempt++ {
+			if !extallocRetry(attempt, size) {
+				return nil
+			}
+			ptr = extallocExternal(size)
+		}
+		if extallocLen == extallocCap && !extallocGrowIndex() {
+			// The memory pressure handlers allocated enough objects to
+			// fill up the index.
+			extallocRelease(ptr, size)
+			return nil
+		}
+	}
+
+	start := uintptr(ptr)
+	*extallocObjectAt(extallocLen) = extallocObject{start, start + size}
+	if extallocSorted == extallocLen && (extallocLen == 0 || extallocObjectAt(extallocLen-1).start < start) {
+		// The index is still sorted, which is common when the allocator hands
+		// out memory at increasing addresses.
+		extallocSorted++
+	}
+	extallocLen++
+	if extallocMin == 0 || start < extallocMin {
+		extallocMin = start
+	}
+	if start+size > extallocMax {
+		extallocMax = start + size
+	}
+	return ptr
+}
+
+// extallocNurseryAlloc allocates a small object in the nursery, starting a new
+// chunk if the current chunk is full. It returns nil if no new chunk could be
+// allocated.
+func extallocNurseryAlloc(size uintptr) unsafe.Pointer {
+	chunk := extallocNursery
+	if chunk == nil || chunk.top+size > uintptr(unsafe.Pointer(chunk))+extallocChunkSize {
+		ptr := extallocAllocObject(extallocChunkSize)
+		if ptr == nil {
+			return nil
+		}
+		extallocObjectAt(extallocLen - 1).end |= extallocChunkBit
+		chunk = (*extallocChunk)(ptr)
+		*chunk = extallocChunk{}
+		chunk.top = chunk.data()
+		extallocNursery = chunk
+	}
+	ptr := chunk.top
+	granule := (ptr - chunk.data()) / extallocGranule
+	chunk.starts.set(granule)
+	chunk.alive.set(granule)
+	chunk.top += size
+	chunk.live += size
+	return unsafe.Pointer(ptr)
+}
+
+// extallocGrowIndex doubles the capacity of the object index. It returns false
+// if the external allocator has no memory left.
+func extallocGrowIndex() bool {
+	newCap := extallocCap * 2
+	if newCap < extallocMinIndex {
+		newCap = extallocMinIndex
+	}
+	newObjects := extallocExternal(newCap * unsafe.Sizeof(extallocObject{}))
+	if newObjects == nil {
+		return false
+	}
+	if extallocObjects != nil {
+		memcpy(newObjects, extallocObjects, extallocLen*unsafe.Sizeof(extallocObject{}))
+		extallocRelease(extallocObjects, extallocCap*unsafe.Sizeof(extallocObject{}))
+	}
+	extallocObjects = newObjects
+	extallocCap = newCap
+	return true
+}
+
+// extallocRetry prepares for another attempt to allocate size bytes, after the
+// given number of attempts failed even after a collection cycle: it signals
+// memory pressure according to the out of memory strategy (so that caches can
+// be freed) and runs another collection cycle. At the critical level, it also
+// shrinks the object index to its minimum size. It returns false if the
+// strategy gives up.
+func extallocRetry(attempt int, size uintptr) bool {
+	level := outOfMemoryStrategy(attempt, size)
+	if level <= 0 {
+		return false
+	}
+	if extallocDebug {
+		println("extalloc: retrying allocation of", size, "bytes at memory pressure level", level)
+	}
+	signalMemoryPressure(level)
+	runGC()
+	if level >= MemoryPressureCritical {
+		extallocShrinkIndex()
+	}
+	return true
+}
+
+// extallocShrinkIndex reduces the capacity of the object index to what is
+// needed for the objects in it, if that frees memory in the external
+// allocator.
+func extallocShrinkIndex() {
+	newCap := uintptr(extallocMinIndex)
+	for newCap < extallocLen {
+		newCap *= 2
+	}
+	if newCap >= extallocCap {
+		return
+	}
+	newObjects := extallocExternal(newCap * unsafe.Sizeof(extallocObject{}))
+	if newObjects == nil {
+		return
+	}
+	memcpy(newObjects, extallocObjects, extallocLen*unsafe.Sizeof(extallocObject{}))
+	extallocRelease(extallocObjects, extallocCap*unsafe.Sizeof(extallocObject{}))
+	extallocObjects = newObjects
+	extallocCap = newCap
+}
+
+// extallocExternal allocates memory from the external allocator, unless that
+// would exceed the heap limit. It returns nil if no memory could be allocated.
+func extallocExternal(size uintptr) unsafe.Pointer {
+	if extallocLimit != 0 && extallocHeld+size > extallocLimit {
+		return nil
+	}
+	ptr := extalloc(size)
+	if ptr != nil {
+		extallocHeld += size
+		extallocReserved += extallocBucketSize(size) + extallocHeader
+	}
+	return ptr
+}
+
+// extallocRelease returns memory of the given size, allocated with
+// extallocExternal, to the external allocator.
+func extallocRelease(ptr unsafe.Pointer, size uintptr) {
+	extfree(ptr)
+	extallocHeld -= size
+	extallocReserved -= extallocBucketSize(size) + extallocHeader
+}
+
+func realloc(ptr unsafe.Pointer, size uintptr) unsafe.Pointer {
+	if ptr == nil {
+		return alloc(size, nil)
+	}
+
+	gcLock()
+	start, end, ok := extallocFindObject(uintptr(ptr))
+	if !ok || start != uintptr(ptr) {
+		runtimePanic("realloc: invalid pointer")
+	}
+	oldSize := end - start
+	if size <= oldSize {
+		gcUnlock()
+		return ptr
+	}
+
+	// Grow the object in place if it's the last object in the nursery chunk
+	// and the chunk has space left, which is common for a buffer that is
+	// grown repeatedly.
+	if chunk := extallocNursery; chunk != nil && start >= chunk.data() && end == chunk.top {
+		newEnd := start + roundupAllocSize(size)
+		if newEnd <= uintptr(unsafe.Pointer(chunk))+extallocChunkSize {
+			memzero(unsafe.Pointer(end), newEnd-end)
+			chunk.top = newEnd
+			chunk.live += newEnd - end
+			extallocLive += newEnd - end
+			gcTotalAlloc += uint64(newEnd - end)
+			gcUnlock()
+			return ptr
+		}
+	}
+
+	newAlloc := alloc(size, nil)
+	memcpy(newAlloc, ptr, oldSize)
+	free(ptr)
+	gcUnlock()
+
+	return newAlloc
+}
+
+func free(ptr unsafe.Pointer) {
+	// TODO: free objects on request, when the compiler knows they're unused.
+}
+
+// extallocFind returns the index of the object or chunk that contains addr.
+func extallocFind(addr uintptr) (uintptr, bool) {
+	if addr < extallocMin || addr >= extallocMax {
+		return 0, false
+	}
+
+	// Binary search in the sorted part of the index.
+	low, high := uintptr(0), extallocSorted
+	for low < high {
+		mid := low + (high-low)/2
+		if extallocObjectAt(mid).start <= addr {
+			low = mid + 1
+		} else {
+			high = mid
+		}
+	}
+	if low > 0 {
+		obj := extallocObjectAt(low - 1)
+		if addr < obj.end&^extallocFlags {
+			return low - 1, true
+		}
+	}
+
+	// Linear search in the objects allocated since the last sort.
+	for i := extallocSorted; i < extallocLen; i++ {
+		obj := extallocObjectAt(i)
+		if addr >= obj.start && addr < obj.end&^extallocFlags {
+			return i, true
+		}
+	}
+	return 0, false
+}
+
+// extallocFindObject returns the bounds of the object that contains addr,
+// which may be an object in a nursery chunk.
+func extallocFindObject(addr uintptr) (start, end uintptr, ok bool) {
+	index, ok := extallocFind(addr)
+	if !ok {
+		return 0, 0, false
+	}
+	obj := extallocObjectAt(index)
+	if obj.end&extallocChunkBit != 0 {
+		return (*extallocChunk)(unsafe.Pointer(obj.start)).find(addr)
+	}
+	return obj.start, obj.end &^ extallocFlags, true
+}
+
+// HeapIterate calls f for every object on the heap, in order of address, with
+// its address and size (as reserved by the GC, which may be a bit more than was
+// allocated). It stops when f returns false. This can be used to write tools
+// like leak detectors in Go, running in the program itself.
+//
+// Objects that aren't reachable anymore but weren't freed yet by a collection
+// cycle are included, call GC first to only get reachable objects. No
+// collection cycle runs while iterating, and with threads, other workers wait
+// for the iteration to finish before they allocate. f may allocate memory, but
+// those objects may or may not be visited.
+//
+// This is only supported by the extalloc GC.
+func HeapIterate(f func(ptr unsafe.Pointer, size uintptr) bool) {
+	gcLock()
+	EnterNoGC()
+	extallocSort()
+	for addr := uintptr(0); ; {
+		start, end, ok := extallocNextObject(addr)
+		if !ok || !f(unsafe.Pointer(start), end-start) {
+			break
+		}
+		// Continue by address, as f may have changed the index.
+		addr = end
+	}
+	gcUnlock()
+	ExitNoGC()
+}
+
+// extallocNextObject returns the bounds of the first live object that starts
+// at or after addr, which may be an object in a nursery chunk. Only the sorted
+// part of the index is searched.
+func extallocNextObject(addr uintptr) (start, end uintptr, ok bool) {
+	// Binary search for the first object or chunk that ends after addr.
+	low, high := uintptr(0), extallocSorted
+	for low < high {
+		mid := low + (high-low)/2
+		if extallocObjectAt(mid).end&^extallocFlags <= addr {
+			low = mid + 1
+		} else {
+			high = mid
+		}
+	}
+	for i := low; i < extallocSorted; i++ {
+		obj := extallocObjectAt(i)
+		if obj.end&extallocChunkBit == 0 {
+			return obj.start, obj.end &^ extallocFlags, true
+		}
+		chunk := (*extallocChunk)(unsafe.Pointer(obj.start))
+		data := chunk.data()
+		granule := uintptr(0)
+		if addr > data {
+			granule = (addr - data + extallocGranule - 1) / extallocGranule
+		}
+		n := (chunk.top - data) / extallocGranule
+		for ; granule < n; granule++ {
+			if chunk.alive.get(granule) {
+				return data + granule*extallocGranule, data + chunk.objectEnd(granule)*extallocGranule, true
+			}
+		}
+	}
+	return 0, 0, false
+}
+
+// extallocSort sorts the object index by start address, using heapsort so that
+// it doesn't need any extra memory.
+func extallocSort() {
+	if extallocSorted == extallocLen {
+		return
+	}
+	n := extallocLen
+	for i := n / 2; i > 0; i-- {
+		extallocSiftDown(i-1, n)
+	}
+	for i := n - 1; i > 0; i-- {
+		*extallocObjectAt(0), *extallocObjectAt(i) = *extallocObjectAt(i), *extallocObjectAt(0)
+		extallocSiftDown(0, i)
+	}
+	extallocSorted = extallocLen
+}
+
+func extallocSiftDown(root, n uintptr) {
+	for {
+		child := 2*root + 1
+		if child >= n {
+			return
+		}
+		if child+1 < n && extallocObjectAt(child).start < extallocObjectAt(child+1).start {
+			child++
+		}
+		if extallocObjectAt(root).start >= extallocObjectAt(child).start {
+			return
+		}
+		*extallocObjectAt(root), *extallocObjectAt(child) = *extallocObjectAt(child), *extallocObjectAt(root)
+		root = child
+	}
+}
+
+// GC performs a garbage collection cycle.
+func GC() {
+	gcLock()
+	runGC()
+	gcUnlock()
+}
+
+// runGC performs a garbage collection cycle: it marks all reachable objects
+// and returns all other objects to the external allocator. With threads, the
+// GC lock must be held.
+func runGC() {
+	if noGCDepth != 0 {
+		// Not allowed to run right now, see EnterNoGC.
+		gcDeferred = true
+		return
+	}
+	if extallocDebug {
+		println("running collection cycle...")
+	}
+
+	// Mark phase: mark all reachable objects, recursively.
+	extallocSort()
+	if hasThreads {
+		// Other workers must not move pointers around while marking.
+		threadsStop()
+		markThreads()
+	} else {
+		markStack()
+	}
+	findGlobals(markRoots)
+	for extallocOverflown {
+		// Re-scan all marked objects, as some of the objects they reference
+		// may not have been scanned.
+		extallocOverflown = false
+		for i := uintptr(0); i < extallocLen; i++ {
+			obj := extallocObjectAt(i)
+			if obj.end&extallocMarkBit == 0 {
+				continue
+			}
+			if obj.end&extallocChunkBit == 0 {
+				extallocScan(obj.start, obj.end&^extallocFlags)
+				continue
+			}
+			chunk := (*extallocChunk)(unsafe.Pointer(obj.start))
+			data := chunk.data()
+			n := (chunk.top - data) / extallocGranule
+			for granule := uintptr(0); granule < n; granule++ {
+				if chunk.marks.get(granule) {
+					extallocScan(data+granule*extallocGranule, data+chunk.objectEnd(granule)*extallocGranule)
+				}
+			}
+		}
+	}
+
+	// Sweep phase: free all unmarked objects and compact the index. Objects
+	// pinned by a heap snapshot are kept, see extallocPinned.
+	live := uintptr(0)
+	n := uintptr(0)
+	extallocMin, extallocMax = 0, 0
+	for i := uintptr(0); i < extallocLen; i++ {
+		obj := *extallocObjectAt(i)
+		if obj.end&extallocChunkBit != 0 {
+			// Free all unmarked objects in the chunk, and the chunk itself
+			// when none of its objects are left.
+			chunk := (*extallocChunk)(unsafe.Pointer(obj.start))
+			gcCountFrees(chunk.sweep())
+			if chunk.live == 0 && !extallocPinned(obj.start) {
+				if extallocDebug {
+					println("extfree: chunk", obj.start)
+				}
+				if chunk == extallocNursery {
+					extallocNursery = nil
+				}
+				extallocRelease(unsafe.Pointer(obj.start), extallocChunkSize)
+				continue
+			}
+			live += chunk.live
+		} else if obj.end&extallocMarkBit == 0 && !extallocPinned(obj.start) {
+			if extallocDebug {
+				println("extfree:", obj.start, obj.end-obj.start)
+			}
+			extallocRelease(unsafe.Pointer(obj.start), obj.end-obj.start)
+			gcCountFrees(1)
+			continue
+		} else {
+			live += obj.end&^extallocFlags - obj.start
+		}
+		obj.end &^= extallocMarkBit
+		*extallocObjectAt(n) = obj
+		n++
+		if extallocMin == 0 {
+			extallocMin = obj.start
+		}
+		extallocMax = obj.end &^ extallocFlags
+	}
+	extallocLen = n
+	extallocSorted = n
+	extallocLive = live
+	if gcAsserts {
+		extallocCheck()
+	}
+	if extallocDebug {
+		extallocDump()
+	}
+
+	extallocSetNextGC(live)
+}
+
+// extallocSetNextGC determines when the next collection cycle runs, given the
+// number of bytes in live objects after a cycle.
+func extallocSetNextGC(live uintptr) {
+	if extallocLeaking {
+		extallocNextGC = ^uintptr(0)
+		return
+	}
+
+	// Run the next cycle when the heap has doubled in size.
+	extallocNextGC = live * 2
+	if extallocNextGC < extallocMinHeap {
+		extallocNextGC = extallocMinHeap
+	}
+
+	// Near the heap limit, collect when half of the remaining memory is used
+	// up. This way, cycles run more often as the heap gets closer to the
+	// limit, instead of failing when the heap would have been small enough
+	// after a collection.
+	if extallocLimit != 0 {
+		room := uintptr(0)
+		if extallocHeld < extallocLimit {
+			room = (extallocLimit - extallocHeld) / 2
+		}
+		if extallocNextGC > live+room {
+			extallocNextGC = live + room
+		}
+	}
+}
+
+// extallocCheck checks the invariants of the object index after a sweep, and
+// panics if any of them doesn't hold. See extallocVerify.
+func extallocCheck() {
+	if failed := extallocVerify(); failed != 0 {
+		runtimePanic(selfcheckMessage(failed))
+	}
+}
+
+// extallocVerify checks the invariants of the object index, which must be
+// sorted: the objects don't overlap, no mark bits are left, and the bounds and
+// the byte counts match the objects in the index. It returns the invariants
+// that don't hold, as selfcheck bits.
+func extallocVerify() (failed uint32) {
+	if extallocLen > extallocCap {
+		return selfcheckHeapBounds
+	}
+	live := uintptr(0)
+	held := extallocCap * unsafe.Sizeof(extallocObject{})
+	for i := uintptr(0); i < extallocLen; i++ {
+		obj := extallocObjectAt(i)
+		if obj.end&extallocMarkBit != 0 {
+			failed |= selfcheckHeapState
+		}
+		end := obj.end &^ extallocFlags
+		if obj.start >= end || obj.start%unsafe.Alignof(obj.start) != 0 || end-obj.start != align(end-obj.start) {
+			failed |= selfcheckHeapState
+		}
+		if i > 0 && extallocObjectAt(i-1).end&^extallocFlags > obj.start {
+			failed |= selfcheckHeapOrder
+		}
+		if obj.start < extallocMin || end > extallocMax {
+			failed |= selfcheckHeapBounds
+		}
+		if obj.end&extallocChunkBit != 0 {
+			chunk := (*extallocChunk)(unsafe.Pointer(obj.start))
+			if chunk.top > end || chunk.marks != (extallocBitmap{}) {
+				failed |= selfcheckHeapState
+			}
+			live += chunk.live
+		} else {
+			live += end - obj.start
+		}
+		held += end - obj.start
+	}
+	if live != extallocLive {
+		failed |= selfcheckHeapStats
+	}
+	if held != extallocHeld || extallocLimit != 0 && held > extallocLimit {
+		failed |= selfcheckHeapStats
+	}
+	if gcFrees > gcMallocs {
+		failed |= selfcheckHeapStats
+	}
+	return
+}
+
+// gcSelfcheck checks the invariants of the heap outside of a collection cycle,
+// see extallocVerify.
+func gcSelfcheck() uint32 {
+	extallocSort()
+	return extallocVerify()
+}
+
+// extallocGaps returns statistics about the gaps between the objects in the
+// index: the number of gaps, the total number of bytes in them, and the size
+// of the largest gap. The index must be sorted and no mark bits may be set.
+func extallocGaps() (count, total, largest uintptr) {
+	for i := uintptr(1); i < extallocLen; i++ {
+		gap := extallocObjectAt(i).start - extallocObjectAt(i-1).end&^extallocFlags
+		if gap < extallocMinGap {
+			continue
+		}
+		count++
+		total += gap
+		if gap > largest {
+			largest = gap
+		}
+	}
+	return
+}
+
+// extallocDump prints the state of the heap, for debugging. It includes the
+// fragmentation statistics, to see whether running out of memory is caused by
+// fragmentation in the external allocator or by too many live objects.
+func extallocDump() {
+	extallocSort()
+	count, total, largest := extallocGaps()
+	println("heap:", extallocLen, "objects,", extallocLive, "bytes live in", extallocMax-extallocMin, "bytes from", extallocMin, "to", extallocMax)
+	println("gaps:", count, "gaps,", total, "bytes, largest", largest, "bytes")
+}
+
+// markRoots reads all pointers from start to end (exclusive) and if they look
+// like a heap pointer and are unmarked, marks them and scans that object as
+// well (recursively). The start and end parameters must be valid pointers and
+// must be aligned.
+func markRoots(start, end uintptr) {
+	if gcAsserts {
+		if start >= end {
+			runtimePanic("gc: unexpected range to mark")
+		}
+		if start%unsafe.Alignof(start) != 0 {
+			runtimePanic("gc: unaligned start pointer")
+		}
+	}
+
+	end -= unsafe.Sizeof(end) - unsafe.Alignof(end)
+	for addr := start; addr < end; addr += unsafe.Alignof(addr) {
+		root := *(*uintptr)(unsafe.Pointer(addr))
+		if objStart, objEnd, ok := extallocMark(root); ok {
+			extallocScan(objStart, objEnd)
+		}
+	}
+}
+
+// extallocMark marks the object that contains addr, if there is one. It
+// returns the bounds of the object if it wasn't marked yet, in which case the
+// object must be scanned.
+func extallocMark(addr uintptr) (start, end uintptr, ok bool) {
+	index, ok := extallocFind(addr)
+	if !ok {
+		return 0, 0, false
+	}
+	obj := extallocObjectAt(index)
+	if obj.end&extallocChunkBit == 0 {
+		if obj.end&extallocMarkBit != 0 {
+			return 0, 0, false
+		}
+		obj.end |= extallocMarkBit
+		return obj.start, obj.end &^ extallocFlags, true
+	}
+
+	chunk := (*extallocChunk)(unsafe.Pointer(obj.start))
+	start, end, ok = chunk.find(addr)
+	if !ok {
+		return 0, 0, false
+	}
+	granule := (start - chunk.data()) / extallocGranule
+	if chunk.marks.get(granule) {
+		return 0, 0, false
+	}
+	chunk.marks.set(granule)
+	obj.end |= extallocMarkBit
+	return start, end, true
+}
+
+// extallocScan scans the object from start to end (exclusive), and marks all
+// objects it references. It uses a small fixed-size stack and falls back to
+// rescanning all marked objects when it overflows, like gc.conservative does.
+func extallocScan(start, end uintptr) {
+	var stack [16]struct{ start, end uintptr }
+	stack[0].start, stack[0].end = start, end
+	stackLen := 1
+	for stackLen > 0 {
+		stackLen--
+		start, end := stack[stackLen].start, stack[stackLen].end
+		for addr := start; addr < end; addr += unsafe.Alignof(addr) {
+			word := *(*uintptr)(unsafe.Pointer(addr))
+			refStart, refEnd, ok := extallocMark(word)
+			if !ok {
+				continue
+			}
+			if stackLen == len(stack) {
+				extallocOverflown = true
+				continue
+			}
+			stack[stackLen].start, stack[stackLen].end = refStart, refEnd
+			stackLen++
+		}
+	}
+}
+
+// ReadMemStats populates m with memory statistics.
+//
+// The returned memory statistics are up to date as of the
+// call to ReadMemStats. This would not do GC implicitly for you.
+func ReadMemStats(m *MemStats) {
+	gcLock()
+
+	// Memory in nursery chunks that isn't used by live objects is idle: the
+	// end of the current chunk, and the space of objects that were freed.
+	idle := uintptr(0)
+	for i := uintptr(0); i < extallocLen; i++ {
+		obj := extallocObjectAt(i)
+		if obj.end&extallocChunkBit != 0 {
+			idle += extallocChunkSize - (*extallocChunk)(unsafe.Pointer(obj.start)).live
+		}
+	}
+	m.HeapInuse = uint64(extallocLive)
+	m.HeapIdle = uint64(idle)
+	m.HeapReleased = 0
+	m.HeapSys = m.HeapInuse + m.HeapIdle
+	m.GCSys = uint64(extallocCap * unsafe.Sizeof(extallocObject{}))
+	readGCStats(m)
+	m.Sys = m.HeapSys + m.GCSys + uint64(extallocReserved-extallocHeld) // plus bucket slack and headers
+
+	extallocSort()
+	count, total, largest := extallocGaps()
+	m.HeapSpan = uint64(extallocMax - extallocMin)
+	m.HeapGaps = uint64(count)
+	m.HeapGapBytes = uint64(total)
+	m.HeapLargestGap = uint64(largest)
+	m.HeapFragmentation = 0
+	if total != 0 {
+		m.HeapFragmentation = 1 - float64(largest)/float64(total)
+	}
+	gcUnlock()
+}
+
+func SetFinalizer(obj interface{}, finalizer interface{}) {
+	// Unimplemented.
+}
@@ -0,0 +1,6 @@
+//go:build gc.extalloc && !extalloc_leaking
+
+package runtime
+
+// See gc_extalloc_leaking.go.
+const extallocLeaking = false
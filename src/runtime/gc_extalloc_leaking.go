@@ -0,0 +1,9 @@
+//go:build gc.extalloc && extalloc_leaking
+
+package runtime
+
+// With the extalloc_leaking build tag, the extalloc GC never runs a collection
+// cycle by itself: objects are allocated from the external allocator in the
+// same way, but they're only freed when runtime.GC is called. This shows how
+// much time the program spends in collection cycles, see benchmarks/compare.py.
+const extallocLeaking = true
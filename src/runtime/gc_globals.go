@@ -1,4 +1,4 @@
-//go:build (gc.conservative || gc.precise) && (baremetal || tinygo.wasm)
+//go:build (gc.conservative || gc.extalloc || gc.precise) && (baremetal || tinygo.wasm)
 
 package runtime
 
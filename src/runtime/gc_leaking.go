@@ -13,27 +13,23 @@ import (
 // Ever-incrementing pointer: no memory is freed.
 var heapptr = heapStart
 
-// Total amount allocated for runtime.MemStats
-var gcTotalAlloc uint64
-
-// Total number of calls to alloc()
-var gcMallocs uint64
-
-// Total number of objected freed; for leaking collector this stays 0
-const gcFrees = 0
-
 // Inlining alloc() speeds things up slightly but bloats the executable by 50%,
 // see https://github.com/tinygo-org/tinygo/issues/2674.  So don't.
 //
 //go:noinline
 func alloc(size uintptr, layout unsafe.Pointer) unsafe.Pointer {
+	if size == 0 {
+		// Like the other GCs, don't count zero-sized allocations. The
+		// pointer only needs to be non-nil.
+		return unsafe.Pointer(heapptr)
+	}
 	// TODO: this can be optimized by not casting between pointers and ints so
 	// much. And by using platform-native data types (e.g. *uint8 for 8-bit
 	// systems).
 	size = align(size)
+	checkCallBudget(size)
 	addr := heapptr
-	gcTotalAlloc += uint64(size)
-	gcMallocs++
+	gcCountAlloc(size)
 	heapptr += size
 	for heapptr >= heapEnd {
 		// Try to increase the heap and check again.
@@ -41,13 +37,19 @@ func alloc(size uintptr, layout unsafe.Pointer) unsafe.Pointer {
 			continue
 		}
 		// Failed to make the heap bigger, so we must really be out of memory.
-		runtimePanic("out of memory")
+		runtimePanicReason(returnAddress(0), trapOutOfMemory, size, "out of memory")
 	}
 	pointer := unsafe.Pointer(addr)
 	memzero(pointer, size)
 	return pointer
 }
 
+// roundupAllocSize returns the number of bytes that alloc reserves for an
+// object of the given size.
+func roundupAllocSize(size uintptr) uintptr {
+	return align(size)
+}
+
 func realloc(ptr unsafe.Pointer, size uintptr) unsafe.Pointer {
 	newAlloc := alloc(size, nil)
 	if ptr == nil {
@@ -75,12 +77,23 @@ func ReadMemStats(m *MemStats) {
 
 	m.HeapSys = m.HeapInuse + m.HeapIdle
 	m.GCSys = 0
-	m.TotalAlloc = gcTotalAlloc
-	m.Mallocs = gcMallocs
-	m.Frees = gcFrees
+	readGCStats(m)
 	m.Sys = uint64(heapEnd - heapStart)
 }
 
+// gcSelfcheck checks that the heap pointer is within the heap and matches the
+// number of bytes allocated. It returns the invariants that don't hold, as
+// selfcheck bits.
+func gcSelfcheck() (failed uint32) {
+	if heapptr < heapStart || heapptr > heapEnd {
+		failed |= selfcheckHeapBounds
+	}
+	if uint64(heapptr-heapStart) != gcTotalAlloc {
+		failed |= selfcheckHeapStats
+	}
+	return
+}
+
 func GC() {
 	// No-op.
 }
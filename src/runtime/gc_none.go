@@ -10,14 +10,14 @@ import (
 	"unsafe"
 )
 
-var gcTotalAlloc uint64 // for runtime.MemStats
-var gcMallocs uint64
-var gcFrees uint64
-
 func alloc(size uintptr, layout unsafe.Pointer) unsafe.Pointer
 
 func realloc(ptr unsafe.Pointer, size uintptr) unsafe.Pointer
 
+func roundupAllocSize(size uintptr) uintptr {
+	return size
+}
+
 func free(ptr unsafe.Pointer) {
 	// Nothing to free when nothing gets allocated.
 }
@@ -30,6 +30,11 @@ func SetFinalizer(obj interface{}, finalizer interface{}) {
 	// Unimplemented.
 }
 
+func gcSelfcheck() uint32 {
+	// There is no heap to check.
+	return 0
+}
+
 func initHeap() {
 	// Nothing to initialize.
 }
@@ -0,0 +1,110 @@
+package runtime
+
+// Handling of memory pressure: the GC lets the program free caches when the
+// heap is still big after a collection cycle, and when an allocation fails
+// even after a collection cycle, before it gives up and panics.
+
+// Memory pressure levels, passed to the handlers registered with
+// RegisterMemoryPressureHandler.
+const (
+	// MemoryPressureModerate means that the heap is above the moderate
+	// threshold after a collection cycle, or that an allocation failed even
+	// after a collection cycle. Handlers should drop entries that are cheap
+	// to recreate.
+	MemoryPressureModerate = 1
+
+	// MemoryPressureCritical means that the heap is above the critical
+	// threshold after a collection cycle, or that an allocation still failed
+	// after the handlers were called with MemoryPressureModerate. Handlers
+	// should drop everything they can, as the program may soon panic with an
+	// out of memory error.
+	MemoryPressureCritical = 2
+)
+
+var (
+	memoryPressureHandlers []func(level int)
+	memoryPressureActive   bool // handlers are running, don't call them again
+	outOfMemoryStrategy    = defaultOutOfMemoryStrategy
+
+	// Thresholds for the heap size after a collection cycle, in bytes, or
+	// zero if unset. See SetMemoryPressureThresholds.
+	memoryPressureModerate uintptr
+	memoryPressureCritical uintptr
+)
+
+// RegisterMemoryPressureHandler registers a function that is called when the
+// heap is running out of memory, with one of the MemoryPressure* levels: when
+// the heap is above one of the thresholds after a collection cycle (see
+// SetMemoryPressureThresholds), and when an allocation fails (see
+// SetOutOfMemoryStrategy). It can be used to free caches: memory that is no
+// longer referenced after the handler returns is freed by the next collection
+// cycle.
+//
+// Handlers run in the goroutine that is allocating, in the middle of an
+// allocation. They should be quick, and allocate little or no memory.
+//
+// Only the extalloc GC calls these handlers at the moment.
+func RegisterMemoryPressureHandler(handler func(level int)) {
+	memoryPressureHandlers = append(memoryPressureHandlers, handler)
+}
+
+// SetMemoryPressureThresholds sets the size of the heap after a collection
+// cycle, in bytes, above which the memory pressure handlers are called with
+// MemoryPressureModerate and MemoryPressureCritical. A threshold of zero is
+// never reached.
+//
+// When the extalloc GC has a heap limit, the thresholds are set to half and
+// three quarters of the limit by default. Otherwise they're unset.
+func SetMemoryPressureThresholds(moderate, critical uintptr) {
+	memoryPressureModerate = moderate
+	memoryPressureCritical = critical
+}
+
+// memoryPressureLevel returns the memory pressure level for a heap of the given
+// size, according to the thresholds. It returns zero if the heap is below both
+// thresholds.
+func memoryPressureLevel(size uintptr) int {
+	if memoryPressureCritical != 0 && size >= memoryPressureCritical {
+		return MemoryPressureCritical
+	}
+	if memoryPressureModerate != 0 && size >= memoryPressureModerate {
+		return MemoryPressureModerate
+	}
+	return 0
+}
+
+// SetOutOfMemoryStrategy changes how the GC retries an allocation of size
+// bytes that failed even after a collection cycle. The strategy is called with
+// the number of the retry (starting at 1), and returns the memory pressure
+// level to signal to the handlers before the next collection cycle, or zero to
+// give up and panic with an out of memory error.
+//
+// The default strategy retries twice, first with MemoryPressureModerate and
+// then with MemoryPressureCritical. Passing nil restores the default.
+func SetOutOfMemoryStrategy(strategy func(attempt int, size uintptr) (level int)) {
+	if strategy == nil {
+		strategy = defaultOutOfMemoryStrategy
+	}
+	outOfMemoryStrategy = strategy
+}
+
+func defaultOutOfMemoryStrategy(attempt int, size uintptr) int {
+	if attempt > MemoryPressureCritical {
+		return 0
+	}
+	return attempt
+}
+
+// signalMemoryPressure calls all memory pressure handlers with the given
+// level. Handlers that allocate may cause another out of memory condition,
+// the handlers aren't called recursively in that case.
+func signalMemoryPressure(level int) {
+	if memoryPressureActive {
+		return
+	}
+	memoryPressureActive = true
+	for _, handler := range memoryPressureHandlers {
+		handler(level)
+	}
+	memoryPressureActive = false
+}
@@ -1,4 +1,4 @@
-//go:build (gc.conservative || gc.custom || gc.precise) && tinygo.wasm
+//go:build (gc.conservative || gc.custom || gc.extalloc || gc.precise) && tinygo.wasm
 
 package runtime
 
@@ -26,7 +26,8 @@ type stackChainObject struct {
 //
 // Therefore, we only need to scan the system stack.
 // It is relatively easy to scan the system stack while we're on it: we can
-// simply read __stack_pointer and __global_base and scan the area inbetween.
+// simply read __stack_pointer and scan the area up to the top of the stack (see
+// StackBounds).
 // Unfortunately, it's hard to get the system stack pointer while we're on a
 // goroutine stack. But when we're on a goroutine stack, the system stack is in
 // the scheduler which means there shouldn't be anything on the system stack
@@ -46,7 +47,25 @@ func markStack() {
 	volatile.LoadUint32((*uint32)(unsafe.Pointer(&stackChainStart)))
 
 	if task.OnSystemStack() {
-		markRoots(getCurrentStackPointer(), stackTop)
+		// Only scan the part of the stack that is in use. The stack pointer
+		// may be outside the stack if the stack overflowed or if the host
+		// changed it, don't scan memory that isn't part of the stack in that
+		// case.
+		bottom, top := StackBounds()
+		sp := getCurrentStackPointer()
+		if (sp < bottom || sp >= top) && sp < wasmEntryStackTop {
+			// The host called an exported function with a stack outside of
+			// the one reserved by the linker. Scan up to the stack pointer
+			// it passed in, see wasmExportEnter.
+			markRoots(sp, wasmEntryStackTop)
+			return
+		}
+		if sp < bottom {
+			sp = bottom
+		}
+		if sp < top {
+			markRoots(sp, top)
+		}
 	}
 }
 
@@ -1,4 +1,4 @@
-//go:build (gc.conservative || gc.precise) && !tinygo.wasm
+//go:build (gc.conservative || gc.precise || (gc.extalloc && polkavm)) && !tinygo.wasm
 
 package runtime
 
@@ -0,0 +1,36 @@
+//go:build gc.conservative || gc.precise || gc.leaking || gc.extalloc || gc.none
+
+package runtime
+
+// Allocation statistics for runtime.MemStats, shared by the GCs in the runtime
+// so that they all count in the same way: every call to alloc is one
+// allocation (also when it is called from realloc), except for zero-sized
+// allocations which don't use the heap, and every object that is given back to
+// the heap is one free. That way the statistics of a program can be compared
+// between GCs, as done by -gc-diff. Only TotalAlloc depends on the GC, as it
+// includes the rounding and headers of the GC.
+
+var (
+	gcTotalAlloc uint64 // total number of bytes allocated
+	gcMallocs    uint64 // total number of allocations
+	gcFrees      uint64 // total number of objects freed
+)
+
+// gcCountAlloc records a single allocation, of size bytes as reserved by the
+// GC.
+func gcCountAlloc(size uintptr) {
+	gcTotalAlloc += uint64(size)
+	gcMallocs++
+}
+
+// gcCountFrees records that n objects were freed.
+func gcCountFrees(n uint64) {
+	gcFrees += n
+}
+
+// readGCStats fills in the allocation statistics of m.
+func readGCStats(m *MemStats) {
+	m.TotalAlloc = gcTotalAlloc
+	m.Mallocs = gcMallocs
+	m.Frees = gcFrees
+}
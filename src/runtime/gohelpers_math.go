@@ -0,0 +1,224 @@
+//go:build tinygo.wasm && gohelpers.math
+
+// Copyright 2009 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package runtime
+
+// The libm functions for the math group of the go-helpers target property.
+// The compiler implements math.Exp, math.Exp2 and math.Log with the LLVM
+// intrinsics of the same name, which WebAssembly has no instructions for: the
+// backend lowers them to calls to exp, exp2 and log (or expf, exp2f and logf
+// after narrowing to float32). Without a C library, the runtime provides them.
+//
+// The algorithms are the same as the pure Go ones of the math package, which
+// come from FreeBSD's /usr/src/lib/msun/src/e_exp.c and e_log.c. The float32
+// versions use the float64 ones, which is accurate enough and keeps the code
+// small. The builder keeps these functions in the module until the backend
+// emits calls to them, see setRuntimeHelpers.
+
+const (
+	libmLn2Hi = 6.93147180369123816490e-01 // 3fe62e42 fee00000
+	libmLn2Lo = 1.90821492927058770002e-10 // 3dea39ef 35793c76
+
+	float64Mask  = 0x7ff
+	float64Shift = 64 - 11 - 1
+	float64Bias  = 1023
+)
+
+//go:linkname libmExp exp
+func libmExp(x float64) float64 {
+	const (
+		log2e = 1.44269504088896338700e+00
+
+		overflow  = 7.09782712893383973096e+02
+		underflow = -7.45133219101941108420e+02
+		nearZero  = 1.0 / (1 << 28) // 2**-28
+	)
+
+	// special cases
+	switch {
+	case isNaN(x) || x == inf:
+		return x
+	case x == -inf:
+		return 0
+	case x > overflow:
+		return inf
+	case x < underflow:
+		return 0
+	case -nearZero < x && x < nearZero:
+		return 1 + x
+	}
+
+	// reduce; computed as r = hi - lo for extra precision.
+	var k int
+	switch {
+	case x < 0:
+		k = int(log2e*x - 0.5)
+	case x > 0:
+		k = int(log2e*x + 0.5)
+	}
+	hi := x - float64(k)*libmLn2Hi
+	lo := float64(k) * libmLn2Lo
+	return libmExpMulti(hi, lo, k)
+}
+
+//go:linkname libmExp2 exp2
+func libmExp2(x float64) float64 {
+	const (
+		overflow  = 1.0239999999999999e+03
+		underflow = -1.0740e+03
+	)
+
+	// special cases
+	switch {
+	case isNaN(x) || x == inf:
+		return x
+	case x == -inf:
+		return 0
+	case x > overflow:
+		return inf
+	case x < underflow:
+		return 0
+	}
+
+	// argument reduction; x = r×lg(e) + n, where |r| ≤ ln(2)/2.
+	var n int
+	switch {
+	case x > 0:
+		n = int(x + 0.5)
+	case x < 0:
+		n = int(x - 0.5)
+	}
+	t := x - float64(n)
+	hi := t * libmLn2Hi
+	lo := -t * libmLn2Lo
+	return libmExpMulti(hi, lo, n)
+}
+
+// libmExpMulti returns e**r × 2**k where r = hi - lo and |r| ≤ ln(2)/2.
+func libmExpMulti(hi, lo float64, k int) float64 {
+	const (
+		p1 = 1.66666666666666657415e-01  // 0x3FC55555; 0x55555555
+		p2 = -2.77777777770155933842e-03 // 0xBF66C16C; 0x16BEBD93
+		p3 = 6.61375632143793436117e-05  // 0x3F11566A; 0xAF25DE2C
+		p4 = -1.65339022054652515390e-06 // 0xBEBBBD41; 0xC5D26BF1
+		p5 = 4.13813679705723846039e-08  // 0x3E663769; 0x72BEA4D0
+	)
+
+	r := hi - lo
+	t := r * r
+	c := r - t*(p1+t*(p2+t*(p3+t*(p4+t*p5))))
+	y := 1 - ((lo - (r*c)/(2-c)) - hi)
+	return libmLdexp(y, k)
+}
+
+//go:linkname libmLog log
+func libmLog(x float64) float64 {
+	const (
+		sqrt2 = 1.41421356237309504880168872420969807856967187537694807317667974
+		l1    = 6.666666666666735130e-01 // 3FE55555 55555593
+		l2    = 3.999999999940941908e-01 // 3FD99999 9997FA04
+		l3    = 2.857142874366239149e-01 // 3FD24924 94229359
+		l4    = 2.222219843214978396e-01 // 3FCC71C5 1D8E78AF
+		l5    = 1.818357216161805012e-01 // 3FC74664 96CB03DE
+		l6    = 1.531383769920937332e-01 // 3FC39A09 D078C69F
+		l7    = 1.479819860511658591e-01 // 3FC2F112 DF3E5244
+	)
+
+	// special cases
+	switch {
+	case isNaN(x) || x == inf:
+		return x
+	case x < 0:
+		return float64frombits(0x7FF8000000000001) // NaN
+	case x == 0:
+		return -inf
+	}
+
+	// reduce
+	f1, ki := libmFrexp(x)
+	if f1 < sqrt2/2 {
+		f1 *= 2
+		ki--
+	}
+	f := f1 - 1
+	k := float64(ki)
+
+	// compute
+	s := f / (2 + f)
+	s2 := s * s
+	s4 := s2 * s2
+	t1 := s2 * (l1 + s4*(l3+s4*(l5+s4*l7)))
+	t2 := s4 * (l2 + s4*(l4+s4*l6))
+	R := t1 + t2
+	hfsq := 0.5 * f * f
+	return k*libmLn2Hi - ((hfsq - (s*(hfsq+R) + k*libmLn2Lo)) - f)
+}
+
+//go:linkname libmExpf expf
+func libmExpf(x float32) float32 {
+	return float32(libmExp(float64(x)))
+}
+
+//go:linkname libmExp2f exp2f
+func libmExp2f(x float32) float32 {
+	return float32(libmExp2(float64(x)))
+}
+
+//go:linkname libmLogf logf
+func libmLogf(x float32) float32 {
+	return float32(libmLog(float64(x)))
+}
+
+// libmNormalize returns a normal number y and exponent exp satisfying
+// x == y × 2**exp. It assumes x is finite and non-zero.
+func libmNormalize(x float64) (y float64, exp int) {
+	const smallestNormal = 2.2250738585072014e-308 // 2**-1022
+	if abs(x) < smallestNormal {
+		return x * (1 << 52), -52
+	}
+	return x, 0
+}
+
+// libmFrexp breaks f into a normalized fraction and an integral power of two,
+// like math.Frexp. It assumes f is finite and non-zero.
+func libmFrexp(f float64) (frac float64, exp int) {
+	f, exp = libmNormalize(f)
+	x := float64bits(f)
+	exp += int((x>>float64Shift)&float64Mask) - float64Bias + 1
+	x &^= float64Mask << float64Shift
+	x |= (-1 + float64Bias) << float64Shift
+	frac = float64frombits(x)
+	return
+}
+
+// libmLdexp returns frac × 2**exp, like math.Ldexp.
+func libmLdexp(frac float64, exp int) float64 {
+	// special cases
+	switch {
+	case frac == 0:
+		return frac // correctly return -0
+	case isInf(frac) || isNaN(frac):
+		return frac
+	}
+	frac, e := libmNormalize(frac)
+	exp += e
+	x := float64bits(frac)
+	exp += int(x>>float64Shift)&float64Mask - float64Bias
+	if exp < -1075 {
+		return copysign(0, frac) // underflow
+	}
+	if exp > 1023 { // overflow
+		return copysign(inf, frac)
+	}
+	var m float64 = 1
+	if exp < -1022 { // denormal
+		exp += 53
+		m = 1.0 / (1 << 53) // 2**-53
+	}
+	x &^= float64Mask << float64Shift
+	x |= uint64(exp+float64Bias) << float64Shift
+	return m * float64frombits(x)
+}
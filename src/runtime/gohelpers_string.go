@@ -0,0 +1,37 @@
+//go:build tinygo.wasm && gohelpers.string
+
+package runtime
+
+// The strlen, memcmp and bcmp functions for the string group of the go-helpers
+// target property, which replace the ones of the C library. The backend emits
+// calls to memcmp and bcmp for comparisons of larger values, and the C library
+// calls them too. The builder keeps them in the module and stops LLVM from
+// replacing their loops with calls to themselves, see setRuntimeHelpers.
+
+import "unsafe"
+
+//go:linkname strlenGo strlen
+func strlenGo(ptr unsafe.Pointer) uintptr {
+	n := uintptr(0)
+	for *(*byte)(unsafe.Add(ptr, n)) != 0 {
+		n++
+	}
+	return n
+}
+
+//go:linkname memcmpGo memcmp
+func memcmpGo(a, b unsafe.Pointer, n uintptr) int32 {
+	for i := uintptr(0); i < n; i++ {
+		x := *(*byte)(unsafe.Add(a, i))
+		y := *(*byte)(unsafe.Add(b, i))
+		if x != y {
+			return int32(x) - int32(y)
+		}
+	}
+	return 0
+}
+
+//go:linkname bcmpGo bcmp
+func bcmpGo(a, b unsafe.Pointer, n uintptr) int32 {
+	return memcmpGo(a, b, n)
+}
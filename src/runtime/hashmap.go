@@ -1,12 +1,16 @@
+//go:build !maps.compact
+
 package runtime
 
 // This is a hashmap implementation for the map[T]T type.
 // It is very roughly based on the implementation of the Go hashmap:
 //
 //     https://golang.org/src/runtime/map.go
+//
+// An alternative implementation that is smaller but slower can be found in
+// hashmap_compact.go (-maps=compact).
 
 import (
-	"reflect"
 	"unsafe"
 )
 
@@ -22,14 +26,6 @@ type hashmap struct {
 	keyHash    func(key unsafe.Pointer, size, seed uintptr) uint32
 }
 
-type hashmapAlgorithm uint8
-
-const (
-	hashmapAlgorithmBinary hashmapAlgorithm = iota
-	hashmapAlgorithmString
-	hashmapAlgorithmInterface
-)
-
 // A hashmap bucket. A bucket is a container of 8 key/value pairs: first the
 // following two entries, then the 8 keys, then the 8 values. This somewhat odd
 // ordering is to make sure the keys and values are well aligned when one of
@@ -120,34 +116,6 @@ func hashmapClear(m *hashmap) {
 	}
 }
 
-func hashmapKeyEqualAlg(alg hashmapAlgorithm) func(x, y unsafe.Pointer, n uintptr) bool {
-	switch alg {
-	case hashmapAlgorithmBinary:
-		return memequal
-	case hashmapAlgorithmString:
-		return hashmapStringEqual
-	case hashmapAlgorithmInterface:
-		return hashmapInterfaceEqual
-	default:
-		// compiler bug :(
-		return nil
-	}
-}
-
-func hashmapKeyHashAlg(alg hashmapAlgorithm) func(key unsafe.Pointer, n, seed uintptr) uint32 {
-	switch alg {
-	case hashmapAlgorithmBinary:
-		return hash32
-	case hashmapAlgorithmString:
-		return hashmapStringPtrHash
-	case hashmapAlgorithmInterface:
-		return hashmapInterfacePtrHash
-	default:
-		// compiler bug :(
-		return nil
-	}
-}
-
 func hashmapHasSpaceToGrow(bucketBits uint8) bool {
 	// Over this limit, we're likely to overflow uintptrs during calculations
 	// or numbers of hash elements.   Don't allow any more growth.
@@ -167,21 +135,6 @@ func hashmapOverLoadFactor(n uintptr, bucketBits uint8) bool {
 	return n > max
 }
 
-// Return the number of entries in this hashmap, called from the len builtin.
-// A nil hashmap is defined as having length 0.
-//
-//go:inline
-func hashmapLen(m *hashmap) int {
-	if m == nil {
-		return 0
-	}
-	return int(m.count)
-}
-
-func hashmapLenUnsafePointer(m unsafe.Pointer) int {
-	return hashmapLen((*hashmap)(m))
-}
-
 //go:inline
 func hashmapBucketSize(m *hashmap) uintptr {
 	return unsafe.Sizeof(hashmapBucket{}) + uintptr(m.keySize)*8 + uintptr(m.valueSize)*8
@@ -459,226 +412,3 @@ func hashmapNext(m *hashmap, it *hashmapIterator, key, value unsafe.Pointer) boo
 func hashmapNextUnsafePointer(m unsafe.Pointer, it unsafe.Pointer, key, value unsafe.Pointer) bool {
 	return hashmapNext((*hashmap)(m), (*hashmapIterator)(it), key, value)
 }
-
-// Hashmap with plain binary data keys (not containing strings etc.).
-func hashmapBinarySet(m *hashmap, key, value unsafe.Pointer) {
-	if m == nil {
-		nilMapPanic()
-	}
-	hash := hash32(key, m.keySize, m.seed)
-	hashmapSet(m, key, value, hash)
-}
-
-func hashmapBinarySetUnsafePointer(m unsafe.Pointer, key, value unsafe.Pointer) {
-	hashmapBinarySet((*hashmap)(m), key, value)
-}
-
-func hashmapBinaryGet(m *hashmap, key, value unsafe.Pointer, valueSize uintptr) bool {
-	if m == nil {
-		memzero(value, uintptr(valueSize))
-		return false
-	}
-	hash := hash32(key, m.keySize, m.seed)
-	return hashmapGet(m, key, value, valueSize, hash)
-}
-
-func hashmapBinaryGetUnsafePointer(m unsafe.Pointer, key, value unsafe.Pointer, valueSize uintptr) bool {
-	return hashmapBinaryGet((*hashmap)(m), key, value, valueSize)
-}
-
-func hashmapBinaryDelete(m *hashmap, key unsafe.Pointer) {
-	if m == nil {
-		return
-	}
-	hash := hash32(key, m.keySize, m.seed)
-	hashmapDelete(m, key, hash)
-}
-
-func hashmapBinaryDeleteUnsafePointer(m unsafe.Pointer, key unsafe.Pointer) {
-	hashmapBinaryDelete((*hashmap)(m), key)
-}
-
-// Hashmap with string keys (a common case).
-
-func hashmapStringEqual(x, y unsafe.Pointer, n uintptr) bool {
-	return *(*string)(x) == *(*string)(y)
-}
-
-func hashmapStringHash(s string, seed uintptr) uint32 {
-	_s := (*_string)(unsafe.Pointer(&s))
-	return hash32(unsafe.Pointer(_s.ptr), uintptr(_s.length), seed)
-}
-
-func hashmapStringPtrHash(sptr unsafe.Pointer, size uintptr, seed uintptr) uint32 {
-	_s := *(*_string)(sptr)
-	return hash32(unsafe.Pointer(_s.ptr), uintptr(_s.length), seed)
-}
-
-func hashmapStringSet(m *hashmap, key string, value unsafe.Pointer) {
-	if m == nil {
-		nilMapPanic()
-	}
-	hash := hashmapStringHash(key, m.seed)
-	hashmapSet(m, unsafe.Pointer(&key), value, hash)
-}
-
-func hashmapStringSetUnsafePointer(m unsafe.Pointer, key string, value unsafe.Pointer) {
-	hashmapStringSet((*hashmap)(m), key, value)
-}
-
-func hashmapStringGet(m *hashmap, key string, value unsafe.Pointer, valueSize uintptr) bool {
-	if m == nil {
-		memzero(value, uintptr(valueSize))
-		return false
-	}
-	hash := hashmapStringHash(key, m.seed)
-	return hashmapGet(m, unsafe.Pointer(&key), value, valueSize, hash)
-}
-
-func hashmapStringGetUnsafePointer(m unsafe.Pointer, key string, value unsafe.Pointer, valueSize uintptr) bool {
-	return hashmapStringGet((*hashmap)(m), key, value, valueSize)
-}
-
-func hashmapStringDelete(m *hashmap, key string) {
-	if m == nil {
-		return
-	}
-	hash := hashmapStringHash(key, m.seed)
-	hashmapDelete(m, unsafe.Pointer(&key), hash)
-}
-
-func hashmapStringDeleteUnsafePointer(m unsafe.Pointer, key string) {
-	hashmapStringDelete((*hashmap)(m), key)
-}
-
-// Hashmap with interface keys (for everything else).
-
-// This is a method that is intentionally unexported in the reflect package. It
-// is identical to the Interface() method call, except it doesn't check whether
-// a field is exported and thus allows circumventing the type system.
-// The hash function needs it as it also needs to hash unexported struct fields.
-//
-//go:linkname valueInterfaceUnsafe reflect.valueInterfaceUnsafe
-func valueInterfaceUnsafe(v reflect.Value) interface{}
-
-func hashmapFloat32Hash(ptr unsafe.Pointer, seed uintptr) uint32 {
-	f := *(*uint32)(ptr)
-	if f == 0x80000000 {
-		// convert -0 to 0 for hashing
-		f = 0
-	}
-	return hash32(unsafe.Pointer(&f), 4, seed)
-}
-
-func hashmapFloat64Hash(ptr unsafe.Pointer, seed uintptr) uint32 {
-	f := *(*uint64)(ptr)
-	if f == 0x8000000000000000 {
-		// convert -0 to 0 for hashing
-		f = 0
-	}
-	return hash32(unsafe.Pointer(&f), 8, seed)
-}
-
-func hashmapInterfaceHash(itf interface{}, seed uintptr) uint32 {
-	x := reflect.ValueOf(itf)
-	if x.RawType() == nil {
-		return 0 // nil interface
-	}
-
-	value := (*_interface)(unsafe.Pointer(&itf)).value
-	ptr := value
-	if x.RawType().Size() <= unsafe.Sizeof(uintptr(0)) {
-		// Value fits in pointer, so it's directly stored in the pointer.
-		ptr = unsafe.Pointer(&value)
-	}
-
-	switch x.RawType().Kind() {
-	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
-		return hash32(ptr, x.RawType().Size(), seed)
-	case reflect.Bool, reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
-		return hash32(ptr, x.RawType().Size(), seed)
-	case reflect.Float32:
-		// It should be possible to just has the contents. However, NaN != NaN
-		// so if you're using lots of NaNs as map keys (you shouldn't) then hash
-		// time may become exponential. To fix that, it would be better to
-		// return a random number instead:
-		// https://research.swtch.com/randhash
-		return hashmapFloat32Hash(ptr, seed)
-	case reflect.Float64:
-		return hashmapFloat64Hash(ptr, seed)
-	case reflect.Complex64:
-		rptr, iptr := ptr, unsafe.Add(ptr, 4)
-		return hashmapFloat32Hash(rptr, seed) ^ hashmapFloat32Hash(iptr, seed)
-	case reflect.Complex128:
-		rptr, iptr := ptr, unsafe.Add(ptr, 8)
-		return hashmapFloat64Hash(rptr, seed) ^ hashmapFloat64Hash(iptr, seed)
-	case reflect.String:
-		return hashmapStringHash(x.String(), seed)
-	case reflect.Chan, reflect.Ptr, reflect.UnsafePointer:
-		// It might seem better to just return the pointer, but that won't
-		// result in an evenly distributed hashmap. Instead, hash the pointer
-		// like most other types.
-		return hash32(ptr, x.RawType().Size(), seed)
-	case reflect.Array:
-		var hash uint32
-		for i := 0; i < x.Len(); i++ {
-			hash ^= hashmapInterfaceHash(valueInterfaceUnsafe(x.Index(i)), seed)
-		}
-		return hash
-	case reflect.Struct:
-		var hash uint32
-		for i := 0; i < x.NumField(); i++ {
-			hash ^= hashmapInterfaceHash(valueInterfaceUnsafe(x.Field(i)), seed)
-		}
-		return hash
-	default:
-		runtimePanic("comparing un-comparable type")
-		return 0 // unreachable
-	}
-}
-
-func hashmapInterfacePtrHash(iptr unsafe.Pointer, size uintptr, seed uintptr) uint32 {
-	_i := *(*interface{})(iptr)
-	return hashmapInterfaceHash(_i, seed)
-}
-
-func hashmapInterfaceEqual(x, y unsafe.Pointer, n uintptr) bool {
-	return *(*interface{})(x) == *(*interface{})(y)
-}
-
-func hashmapInterfaceSet(m *hashmap, key interface{}, value unsafe.Pointer) {
-	if m == nil {
-		nilMapPanic()
-	}
-	hash := hashmapInterfaceHash(key, m.seed)
-	hashmapSet(m, unsafe.Pointer(&key), value, hash)
-}
-
-func hashmapInterfaceSetUnsafePointer(m unsafe.Pointer, key interface{}, value unsafe.Pointer) {
-	hashmapInterfaceSet((*hashmap)(m), key, value)
-}
-
-func hashmapInterfaceGet(m *hashmap, key interface{}, value unsafe.Pointer, valueSize uintptr) bool {
-	if m == nil {
-		memzero(value, uintptr(valueSize))
-		return false
-	}
-	hash := hashmapInterfaceHash(key, m.seed)
-	return hashmapGet(m, unsafe.Pointer(&key), value, valueSize, hash)
-}
-
-func hashmapInterfaceGetUnsafePointer(m unsafe.Pointer, key interface{}, value unsafe.Pointer, valueSize uintptr) bool {
-	return hashmapInterfaceGet((*hashmap)(m), key, value, valueSize)
-}
-
-func hashmapInterfaceDelete(m *hashmap, key interface{}) {
-	if m == nil {
-		return
-	}
-	hash := hashmapInterfaceHash(key, m.seed)
-	hashmapDelete(m, unsafe.Pointer(&key), hash)
-}
-
-func hashmapInterfaceDeleteUnsafePointer(m unsafe.Pointer, key interface{}) {
-	hashmapInterfaceDelete((*hashmap)(m), key)
-}
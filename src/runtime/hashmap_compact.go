@@ -0,0 +1,306 @@
+//go:build maps.compact
+
+package runtime
+
+// This is a compact hashmap implementation for the map[T]T type, selected with
+// -maps=compact. It uses open addressing with linear probing in a single
+// allocation, and rehashes the whole table at once when it gets too full.
+// This makes it a lot smaller than the default implementation (and avoids
+// allocating anything for empty maps), at the cost of some speed for large
+// maps.
+//
+// The table consists of an array of tags (one byte per slot), followed by an
+// array of keys and an array of values. A tag is 0 for an empty slot, 1 for a
+// deleted slot, and otherwise contains the top bits of the hash of the key.
+
+import (
+	"unsafe"
+)
+
+const (
+	hashmapTagEmpty   = 0
+	hashmapTagDeleted = 1
+)
+
+// The underlying hashmap structure for Go.
+type hashmap struct {
+	slots     unsafe.Pointer // tags, keys and values (nil for an empty map)
+	seed      uintptr
+	count     uintptr // number of entries
+	used      uintptr // number of entries plus number of deleted slots
+	keySize   uintptr
+	valueSize uintptr
+	slotBits  uint8 // number of slots is 1<<slotBits
+	keyEqual  func(x, y unsafe.Pointer, n uintptr) bool
+	keyHash   func(key unsafe.Pointer, size, seed uintptr) uint32
+}
+
+type hashmapIterator struct {
+	slots    unsafe.Pointer // table that is being iterated over
+	numSlots uintptr        // number of slots in this table
+	index    uintptr        // index of the next slot to look at
+}
+
+func hashmapNewIterator() unsafe.Pointer {
+	return unsafe.Pointer(new(hashmapIterator))
+}
+
+// Return the tag for the given hash, which is never one of the special values
+// for empty or deleted slots.
+func hashmapTag(hash uint32) uint8 {
+	tag := uint8(hash >> 24)
+	if tag <= hashmapTagDeleted {
+		tag += 2
+	}
+	return tag
+}
+
+// Return the number of slot bits needed to store n entries without exceeding
+// the maximum load factor of 3/4.
+func hashmapSlotBits(n uintptr) uint8 {
+	slotBits := uint8(3)
+	for n*4 > (uintptr(3) << slotBits) {
+		slotBits++
+	}
+	return slotBits
+}
+
+// Create a new hashmap with the given keySize and valueSize.
+func hashmapMake(keySize, valueSize uintptr, sizeHint uintptr, alg uint8) *hashmap {
+	m := &hashmap{
+		seed:      uintptr(fastrand()),
+		keySize:   keySize,
+		valueSize: valueSize,
+		keyEqual:  hashmapKeyEqualAlg(hashmapAlgorithm(alg)),
+		keyHash:   hashmapKeyHashAlg(hashmapAlgorithm(alg)),
+	}
+	if sizeHint != 0 {
+		m.slotBits = hashmapSlotBits(sizeHint)
+		m.slots = alloc(hashmapTableSize(m, uintptr(1)<<m.slotBits), nil)
+	}
+	return m
+}
+
+func hashmapMakeUnsafePointer(keySize, valueSize uintptr, sizeHint uintptr, alg uint8) unsafe.Pointer {
+	return (unsafe.Pointer)(hashmapMake(keySize, valueSize, sizeHint, alg))
+}
+
+// Remove all entries from the map, without actually deallocating the space for
+// it. This is used for the clear builtin, and can be used to reuse a map (to
+// avoid extra heap allocations).
+func hashmapClear(m *hashmap) {
+	if m == nil || m.slots == nil {
+		// Nothing to do. According to the spec:
+		// > If the map or slice is nil, clear is a no-op.
+		return
+	}
+	m.count = 0
+	m.used = 0
+	// Also clear the keys and values, so that the GC won't pin these
+	// allocations.
+	memzero(m.slots, hashmapTableSize(m, uintptr(1)<<m.slotBits))
+}
+
+//go:inline
+func hashmapTableSize(m *hashmap, numSlots uintptr) uintptr {
+	return numSlots * (1 + m.keySize + m.valueSize)
+}
+
+//go:inline
+func hashmapSlotTag(slots unsafe.Pointer, index uintptr) *uint8 {
+	return (*uint8)(unsafe.Add(slots, index))
+}
+
+//go:inline
+func hashmapSlotKey(m *hashmap, slots unsafe.Pointer, numSlots, index uintptr) unsafe.Pointer {
+	return unsafe.Add(slots, numSlots+m.keySize*index)
+}
+
+//go:inline
+func hashmapSlotValue(m *hashmap, slots unsafe.Pointer, numSlots, index uintptr) unsafe.Pointer {
+	return unsafe.Add(slots, numSlots+m.keySize*numSlots+m.valueSize*index)
+}
+
+// Find the slot of the given key. It returns the slot index and true if the
+// key was found, or the index of the empty slot where the key would have to be
+// inserted and false if it wasn't found. The map must have a table.
+//
+//go:nobounds
+func hashmapFind(m *hashmap, key unsafe.Pointer, hash uint32) (uintptr, bool) {
+	tag := hashmapTag(hash)
+	numSlots := uintptr(1) << m.slotBits
+	mask := numSlots - 1
+	index := uintptr(hash) & mask
+	for {
+		slotTag := *hashmapSlotTag(m.slots, index)
+		if slotTag == hashmapTagEmpty {
+			// Reached the end of the probe sequence.
+			return index, false
+		}
+		if slotTag == tag && m.keyEqual(key, hashmapSlotKey(m, m.slots, numSlots, index), m.keySize) {
+			return index, true
+		}
+		index = (index + 1) & mask
+	}
+}
+
+// Set a specified key to a given value. Grow the map if necessary.
+//
+//go:nobounds
+func hashmapSet(m *hashmap, key unsafe.Pointer, value unsafe.Pointer, hash uint32) {
+	if m.slots == nil || (m.used+1)*4 > (uintptr(3)<<m.slotBits) {
+		// There must be at least one empty slot after inserting the key, so
+		// make the table bigger (or remove deleted slots) before inserting.
+		hashmapRehash(m)
+	}
+
+	numSlots := uintptr(1) << m.slotBits
+	index, found := hashmapFind(m, key, hash)
+	if !found {
+		m.count++
+		m.used++
+		memcpy(hashmapSlotKey(m, m.slots, numSlots, index), key, m.keySize)
+		*hashmapSlotTag(m.slots, index) = hashmapTag(hash)
+	}
+	memcpy(hashmapSlotValue(m, m.slots, numSlots, index), value, m.valueSize)
+}
+
+func hashmapSetUnsafePointer(m unsafe.Pointer, key unsafe.Pointer, value unsafe.Pointer, hash uint32) {
+	hashmapSet((*hashmap)(m), key, value, hash)
+}
+
+// Move all entries to a new table, sized for twice the current number of
+// entries. Deleted slots are dropped in the process.
+//
+// The old table is not modified, so that iterators that were started before
+// can continue to use it.
+func hashmapRehash(m *hashmap) {
+	oldSlots := m.slots
+	oldNumSlots := uintptr(1) << m.slotBits
+
+	m.slotBits = hashmapSlotBits((m.count + 1) * 2)
+	numSlots := uintptr(1) << m.slotBits
+	m.slots = alloc(hashmapTableSize(m, numSlots), nil)
+	m.used = m.count
+	if oldSlots == nil {
+		return
+	}
+
+	for i := uintptr(0); i < oldNumSlots; i++ {
+		if *hashmapSlotTag(oldSlots, i) <= hashmapTagDeleted {
+			continue
+		}
+		key := hashmapSlotKey(m, oldSlots, oldNumSlots, i)
+		hash := m.keyHash(key, m.keySize, m.seed)
+		index, _ := hashmapFind(m, key, hash)
+		*hashmapSlotTag(m.slots, index) = hashmapTag(hash)
+		memcpy(hashmapSlotKey(m, m.slots, numSlots, index), key, m.keySize)
+		memcpy(hashmapSlotValue(m, m.slots, numSlots, index), hashmapSlotValue(m, oldSlots, oldNumSlots, i), m.valueSize)
+	}
+}
+
+// Get the value of a specified key, or zero the value if not found.
+//
+//go:nobounds
+func hashmapGet(m *hashmap, key, value unsafe.Pointer, valueSize uintptr, hash uint32) bool {
+	if m == nil || m.count == 0 {
+		// Getting a value out of a nil map is valid. From the spec:
+		// > if the map is nil or does not contain such an entry, a[x] is the
+		// > zero value for the element type of M
+		memzero(value, uintptr(valueSize))
+		return false
+	}
+
+	index, found := hashmapFind(m, key, hash)
+	if !found {
+		memzero(value, m.valueSize)
+		return false
+	}
+	memcpy(value, hashmapSlotValue(m, m.slots, uintptr(1)<<m.slotBits, index), m.valueSize)
+	return true
+}
+
+func hashmapGetUnsafePointer(m unsafe.Pointer, key, value unsafe.Pointer, valueSize uintptr, hash uint32) bool {
+	return hashmapGet((*hashmap)(m), key, value, valueSize, hash)
+}
+
+// Delete a given key from the map. No-op when the key does not exist in the
+// map.
+//
+//go:nobounds
+func hashmapDelete(m *hashmap, key unsafe.Pointer, hash uint32) {
+	if m == nil || m.count == 0 {
+		// The delete builtin is defined even when the map is nil. From the spec:
+		// > If the map m is nil or the element m[k] does not exist, delete is a
+		// > no-op.
+		return
+	}
+
+	index, found := hashmapFind(m, key, hash)
+	if !found {
+		return
+	}
+	numSlots := uintptr(1) << m.slotBits
+	m.count--
+	if *hashmapSlotTag(m.slots, (index+1)&(numSlots-1)) == hashmapTagEmpty {
+		// The next slot is empty, so no probe sequence continues past this
+		// slot and it can be marked empty instead of deleted.
+		*hashmapSlotTag(m.slots, index) = hashmapTagEmpty
+		m.used--
+	} else {
+		*hashmapSlotTag(m.slots, index) = hashmapTagDeleted
+	}
+	// Zero out the key and value so garbage collector doesn't pin the allocations.
+	memzero(hashmapSlotKey(m, m.slots, numSlots, index), m.keySize)
+	memzero(hashmapSlotValue(m, m.slots, numSlots, index), m.valueSize)
+}
+
+// Iterate over a hashmap.
+//
+//go:nobounds
+func hashmapNext(m *hashmap, it *hashmapIterator, key, value unsafe.Pointer) bool {
+	if m == nil {
+		// From the spec: If the map is nil, the number of iterations is 0.
+		return false
+	}
+
+	if it.slots == nil {
+		// initialize iterator
+		if m.slots == nil {
+			return false
+		}
+		it.slots = m.slots
+		it.numSlots = uintptr(1) << m.slotBits
+	}
+
+	for it.index < it.numSlots {
+		index := it.index
+		it.index++
+		if *hashmapSlotTag(it.slots, index) <= hashmapTagDeleted {
+			// slot is empty - move on
+			continue
+		}
+
+		memcpy(key, hashmapSlotKey(m, it.slots, it.numSlots, index), m.keySize)
+		if it.slots == m.slots {
+			// Our view of the table is the same as the parent map.
+			// Just copy the value we have
+			memcpy(value, hashmapSlotValue(m, it.slots, it.numSlots, index), m.valueSize)
+			return true
+		}
+
+		// The map was rehashed while iterating. Look up the key in the new
+		// table and return that value if it still exists.
+		hash := m.keyHash(key, m.keySize, m.seed)
+		if hashmapGet(m, key, value, m.valueSize, hash) {
+			return true
+		}
+	}
+
+	// went through all slots
+	return false
+}
+
+func hashmapNextUnsafePointer(m unsafe.Pointer, it unsafe.Pointer, key, value unsafe.Pointer) bool {
+	return hashmapNext((*hashmap)(m), (*hashmapIterator)(it), key, value)
+}
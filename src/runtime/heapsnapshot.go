@@ -0,0 +1,138 @@
+//go:build tinygo.wasm && gc.extalloc && tinygo.heapsnapshot
+
+package runtime
+
+// Heap snapshots for -heap-snapshot. Hosts that reuse an instance for
+// speculative executions (like a block author trying transactions) can take a
+// snapshot of the program state with tinygo_heap_snapshot and roll back to it
+// with tinygo_heap_restore, instead of instantiating the module again.
+//
+// A snapshot is a copy of the globals, the object index of the GC and the
+// contents of all objects, in a single buffer from the external allocator. The
+// objects in the snapshot are pinned: they're never returned to the external
+// allocator while the snapshot exists, even when they become unreachable, so
+// that restoring only needs to free the objects allocated after the snapshot
+// and copy the contents back. The state of the external allocator itself is
+// not part of the snapshot, so it must be kept outside of the module, like
+// the allocator of a Substrate host.
+
+import "unsafe"
+
+var (
+	heapSnapshot      unsafe.Pointer // buffer with the snapshot, or nil
+	heapSnapshotIndex unsafe.Pointer // object index in the snapshot, sorted by start address
+	heapSnapshotLen   uintptr        // number of objects in the snapshot
+)
+
+// Take a snapshot of the globals and the heap, replacing the previous snapshot.
+// It returns the size of the snapshot in bytes, or 0 if there was no memory
+// for it.
+//
+//export tinygo_heap_snapshot
+func heapSnapshotTake() uint32 {
+	heapSnapshotRelease()
+
+	// Don't include unreachable objects, and sort the index so that pinned
+	// objects can be found with a binary search.
+	runGC()
+	extallocSort()
+
+	globalsSize := globalsEnd - globalsStart
+	indexSize := extallocLen * unsafe.Sizeof(extallocObject{})
+	size := globalsSize + indexSize
+	for i := uintptr(0); i < extallocLen; i++ {
+		obj := extallocObjectAt(i)
+		size += obj.end&^extallocFlags - obj.start
+	}
+	buf := extalloc(size)
+	if buf == nil {
+		return 0
+	}
+	heapSnapshot = buf
+	heapSnapshotIndex = unsafe.Add(buf, globalsSize)
+	heapSnapshotLen = extallocLen
+	memcpy(heapSnapshotIndex, extallocObjects, indexSize)
+	ptr := unsafe.Add(heapSnapshotIndex, indexSize)
+	for i := uintptr(0); i < extallocLen; i++ {
+		obj := extallocObjectAt(i)
+		objSize := obj.end&^extallocFlags - obj.start
+		memcpy(ptr, unsafe.Pointer(obj.start), objSize)
+		ptr = unsafe.Add(ptr, objSize)
+	}
+
+	// Copy the globals last, so that the snapshot includes the variables
+	// above and they stay the same when the snapshot is restored.
+	memcpy(buf, unsafe.Pointer(globalsStart), globalsSize)
+	return uint32(size)
+}
+
+// Roll the globals and the heap back to the last snapshot. The snapshot is
+// kept, so it can be restored again. It returns 0 if there is no snapshot.
+//
+//export tinygo_heap_restore
+func heapSnapshotRestore() uint32 {
+	if heapSnapshot == nil {
+		return 0
+	}
+
+	// Free the objects allocated after the snapshot. All other objects are
+	// pinned, so what is left in the index are the objects of the snapshot.
+	for i := uintptr(0); i < extallocLen; i++ {
+		obj := extallocObjectAt(i)
+		if !extallocPinned(obj.start) {
+			extallocRelease(unsafe.Pointer(obj.start), obj.end&^extallocFlags-obj.start)
+		}
+	}
+
+	// The object index may have moved since the snapshot, and the memory held
+	// from the external allocator is still the same as it is now.
+	objects, capacity := extallocObjects, extallocCap
+	held, reserved := extallocHeld, extallocReserved
+	globalsSize := globalsEnd - globalsStart
+	memcpy(unsafe.Pointer(globalsStart), heapSnapshot, globalsSize)
+	extallocObjects, extallocCap = objects, capacity
+	extallocHeld, extallocReserved = held, reserved
+
+	indexSize := heapSnapshotLen * unsafe.Sizeof(extallocObject{})
+	memcpy(extallocObjects, heapSnapshotIndex, indexSize)
+	ptr := unsafe.Add(heapSnapshotIndex, indexSize)
+	for i := uintptr(0); i < extallocLen; i++ {
+		obj := extallocObjectAt(i)
+		objSize := obj.end&^extallocFlags - obj.start
+		memcpy(unsafe.Pointer(obj.start), ptr, objSize)
+		ptr = unsafe.Add(ptr, objSize)
+	}
+	return 1
+}
+
+// Release the snapshot, so that its objects can be freed again.
+//
+//export tinygo_heap_snapshot_release
+func heapSnapshotRelease() {
+	if heapSnapshot == nil {
+		return
+	}
+	extfree(heapSnapshot)
+	heapSnapshot = nil
+	heapSnapshotIndex = nil
+	heapSnapshotLen = 0
+}
+
+// extallocPinned returns whether the object starting at start is part of the
+// snapshot, and must not be returned to the external allocator.
+func extallocPinned(start uintptr) bool {
+	low, high := uintptr(0), heapSnapshotLen
+	for low < high {
+		mid := (low + high) / 2
+		obj := (*extallocObject)(unsafe.Add(heapSnapshotIndex, mid*unsafe.Sizeof(extallocObject{})))
+		if obj.start == start {
+			return true
+		}
+		if obj.start < start {
+			low = mid + 1
+		} else {
+			high = mid
+		}
+	}
+	return false
+}
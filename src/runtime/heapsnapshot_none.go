@@ -0,0 +1,10 @@
+//go:build gc.extalloc && !(tinygo.wasm && tinygo.heapsnapshot)
+
+package runtime
+
+// Objects are only pinned by a heap snapshot with -heap-snapshot.
+
+//go:inline
+func extallocPinned(start uintptr) bool {
+	return false
+}
@@ -0,0 +1,164 @@
+// Package hostbuf provides reusable buffers for passing data to and from host
+// functions, like the storage functions of a Substrate host.
+//
+// Reading a value from the host usually means allocating a new buffer for
+// every read, which quickly adds up when a call reads many storage values.
+// Buffers returned by Get can instead be returned with Put once the data has
+// been used, so that the next read reuses them:
+//
+//	buf, ok := hostbuf.Read(64, func(buf []byte) int {
+//		return storageRead(key, buf)
+//	})
+//	if ok {
+//		decode(buf)
+//		hostbuf.Put(buf)
+//	}
+//
+// Buffers are referenced by this package from Get until Put, so the GC doesn't
+// free them while the host may still write to them, even when the program only
+// passes them to the host as an integer. Buffers of more than 256 bytes are
+// kept for reuse after Put, in size classes that are a power of two. The sizes
+// are rounded up to what the allocator reserves anyway, so that a buffer uses
+// all the memory it occupies. Kept buffers are dropped when the GC signals
+// memory pressure (see runtime.RegisterMemoryPressureHandler).
+package hostbuf
+
+import "unsafe"
+
+const (
+	// Buffers of at most this size are not kept after Put. They're cheap to
+	// allocate, for example in the nursery chunks of the extalloc GC.
+	minPooledSize = 256
+
+	// Number of size classes of the buffers that are kept: 512 bytes up to
+	// 256MiB.
+	numClasses = 20
+
+	// Maximum number of buffers kept per size class.
+	maxPooledPerClass = 4
+)
+
+var (
+	pooled [numClasses][][]byte // buffers for reuse, by size class
+	inUse  [][]byte             // buffers returned by Get and not yet by Put
+	added  bool                 // the memory pressure handler is registered
+)
+
+//go:linkname registerMemoryPressureHandler runtime.RegisterMemoryPressureHandler
+func registerMemoryPressureHandler(handler func(level int))
+
+//go:linkname roundupAllocSize runtime.roundupAllocSize
+func roundupAllocSize(size uintptr) uintptr
+
+// Get returns a buffer of length n. It may contain data from an earlier use,
+// so it should only be used for data that the host overwrites. The buffer must
+// be passed to Put when it's no longer used.
+func Get(n int) []byte {
+	if n == 0 {
+		return nil
+	}
+	var buf []byte
+	class := sizeClass(n)
+	if class >= 0 && len(pooled[class]) != 0 {
+		list := pooled[class]
+		buf = list[len(list)-1]
+		list[len(list)-1] = nil
+		pooled[class] = list[:len(list)-1]
+	} else {
+		size := n
+		if class >= 0 {
+			size = minPooledSize << (class + 1)
+		}
+		buf = make([]byte, roundupAllocSize(uintptr(size)))
+	}
+	buf = buf[:n]
+	inUse = append(inUse, buf)
+	return buf
+}
+
+// Put returns a buffer obtained with Get, so that it can be reused. The buffer
+// must not be used anymore after this call.
+func Put(buf []byte) {
+	buf = buf[:cap(buf)]
+	if len(buf) == 0 {
+		return
+	}
+	found := false
+	for i, b := range inUse {
+		if &b[:cap(b)][0] == &buf[0] {
+			inUse[i] = inUse[len(inUse)-1]
+			inUse[len(inUse)-1] = nil
+			inUse = inUse[:len(inUse)-1]
+			found = true
+			break
+		}
+	}
+	if !found {
+		panic("hostbuf: Put of a buffer that wasn't returned by Get")
+	}
+	class := sizeClass(len(buf))
+	if class < 0 || minPooledSize<<(class+1) > len(buf) {
+		// Not large enough to be reused for its size class.
+		class--
+	}
+	if class < 0 || len(pooled[class]) == maxPooledPerClass {
+		return
+	}
+	if !added {
+		added = true
+		registerMemoryPressureHandler(drop)
+	}
+	pooled[class] = append(pooled[class], buf)
+}
+
+// Read reads a value of unknown size from the host. The read function fills
+// the buffer it gets with the start of the value and returns the full size of
+// the value, or -1 if there is no value. If the value didn't fit, Read calls it
+// again with a buffer that is large enough. The first buffer has size bytes.
+//
+// If there is a value, the returned buffer contains it and must be passed to
+// Put when it's no longer used.
+func Read(size int, read func(buf []byte) int) ([]byte, bool) {
+	buf := Get(size)
+	n := read(buf)
+	if n > len(buf) {
+		Put(buf)
+		buf = Get(n)
+		n = read(buf)
+	}
+	if n < 0 || n > len(buf) {
+		Put(buf)
+		return nil, false
+	}
+	return buf[:n], true
+}
+
+// PointerSize returns the Polkadot pointer-size of the buffer: the pointer in
+// the low 32 bits and the length in the high 32 bits.
+func PointerSize(buf []byte) uint64 {
+	if len(buf) == 0 {
+		return 0
+	}
+	return uint64(uintptr(unsafe.Pointer(&buf[0]))) | uint64(len(buf))<<32
+}
+
+// sizeClass returns the size class of buffers that can hold n bytes, or -1 if
+// such buffers aren't kept after Put.
+func sizeClass(n int) int {
+	if n <= minPooledSize {
+		return -1
+	}
+	for class := 0; class < numClasses; class++ {
+		if n <= minPooledSize<<(class+1) {
+			return class
+		}
+	}
+	return -1
+}
+
+// drop drops all kept buffers when memory is running low.
+func drop(level int) {
+	for i := range pooled {
+		pooled[i] = nil
+	}
+}
@@ -0,0 +1,66 @@
+//go:build tinygo.hostcallstats
+
+package runtime
+
+// Host call statistics for -host-call-stats. The compiler inserts a call to
+// hostCallRecord after every call to an imported function, which counts the
+// calls and the bytes passed to and from the host per import. The host reads
+// the statistics through the tinygo_host_call_stats export.
+
+import "unsafe"
+
+// The statistics of a single imported function, as stored in linear memory.
+type hostCallStat struct {
+	name    uint32 // pointer to the import name, as module.name
+	nameLen uint32
+	calls   uint64
+	bytes   uint64
+}
+
+// Maximum number of imported functions that can be recorded.
+const hostCallStatsMax = 256
+
+var (
+	hostCallStats      [hostCallStatsMax]hostCallStat
+	hostCallStatsCount uint32
+)
+
+// hostCallRecord records a call to the imported function with the given ID,
+// which is assigned by the compiler. The bytes are the size of the parameters
+// and results, where an i64 counts as a Polkadot pointer-size: the length in
+// its high 32 bits.
+func hostCallRecord(id uint32, name string, bytes uint32) {
+	if id >= hostCallStatsMax {
+		return
+	}
+	stat := &hostCallStats[id]
+	if stat.calls == 0 {
+		stat.name = uint32(uintptr(unsafe.Pointer((*_string)(unsafe.Pointer(&name)).ptr)))
+		stat.nameLen = uint32(len(name))
+		if id >= hostCallStatsCount {
+			hostCallStatsCount = id + 1
+		}
+	}
+	stat.calls++
+	stat.bytes += uint64(bytes)
+}
+
+// Return the statistics as a pointer-size: the pointer in the low 32 bits and
+// the number of bytes in the high 32 bits. Each entry is 24 bytes: a little
+// endian uint32 pointer to the import name, a uint32 length of the import
+// name, and the uint64 number of calls and number of bytes passed. Imports
+// that weren't called yet have zero calls and no name.
+//
+//export tinygo_host_call_stats
+func exportHostCallStats() uint64 {
+	size := uintptr(hostCallStatsCount) * unsafe.Sizeof(hostCallStat{})
+	return uint64(uintptr(unsafe.Pointer(&hostCallStats))) | uint64(size)<<32
+}
+
+// Reset the statistics, for example at the start of a block.
+//
+//export tinygo_host_call_stats_reset
+func resetHostCallStats() {
+	hostCallStats = [hostCallStatsMax]hostCallStat{}
+	hostCallStatsCount = 0
+}
@@ -0,0 +1,150 @@
+// Package hostlog implements small structured logging for programs that run
+// inside a host, like a Substrate runtime.
+//
+// The message is followed by key/value pairs, like in log/slog:
+//
+//	hostlog.Info("transfer", "from", from, "amount", amount)
+//
+// The arguments are only formatted when the message is actually logged. A
+// func() string argument is only called at that point, which makes it possible
+// to defer expensive formatting.
+//
+// Depending on the target, messages are written with the builtin print function
+// (to the debug output), or passed to the ext_logging_log_version_1 function
+// of the host. The -log-level= compiler flag removes all calls below the given
+// level from the program, together with their arguments.
+package hostlog
+
+import "strconv"
+
+// Level is the severity of a log message. The values match the log levels of
+// Polkadot hosts.
+type Level uint32
+
+const (
+	LevelError Level = 1
+	LevelWarn  Level = 2
+	LevelInfo  Level = 3
+	LevelDebug Level = 4
+)
+
+// String returns the upper case name of the level, like "INFO".
+func (l Level) String() string {
+	switch l {
+	case LevelError:
+		return "ERROR"
+	case LevelWarn:
+		return "WARN"
+	case LevelInfo:
+		return "INFO"
+	case LevelDebug:
+		return "DEBUG"
+	default:
+		return "TRACE"
+	}
+}
+
+// Target is passed to the host together with every message, to tell apart the
+// log messages of different parts of the program.
+var Target = "runtime"
+
+// The functions below are recognized by the compiler, which removes calls to
+// them for the -log-level= flag. Keep their names in sync with
+// transform/hostlog.go.
+
+// Debug logs a message at LevelDebug.
+func Debug(msg string, args ...interface{}) {
+	log(LevelDebug, msg, args)
+}
+
+// Info logs a message at LevelInfo.
+func Info(msg string, args ...interface{}) {
+	log(LevelInfo, msg, args)
+}
+
+// Warn logs a message at LevelWarn.
+func Warn(msg string, args ...interface{}) {
+	log(LevelWarn, msg, args)
+}
+
+// Error logs a message at LevelError.
+func Error(msg string, args ...interface{}) {
+	log(LevelError, msg, args)
+}
+
+func log(level Level, msg string, args []interface{}) {
+	if !enabled(level) {
+		return
+	}
+	if len(args) == 0 {
+		output(level, msg)
+		return
+	}
+	output(level, string(format(nil, msg, args)))
+}
+
+// format appends the message and the key/value pairs to buf.
+func format(buf []byte, msg string, args []interface{}) []byte {
+	buf = append(buf, msg...)
+	for len(args) != 0 {
+		buf = append(buf, ' ')
+		key, ok := args[0].(string)
+		if !ok || len(args) == 1 {
+			// A value without a key.
+			buf = append(buf, "!BADKEY="...)
+			buf = appendValue(buf, args[0])
+			args = args[1:]
+			continue
+		}
+		buf = append(buf, key...)
+		buf = append(buf, '=')
+		buf = appendValue(buf, args[1])
+		args = args[2:]
+	}
+	return buf
+}
+
+// appendValue appends a single value. Only a few common types are supported
+// to keep the code size down, other values are printed as "?".
+func appendValue(buf []byte, value interface{}) []byte {
+	switch value := value.(type) {
+	case nil:
+		return append(buf, "<nil>"...)
+	case string:
+		return append(buf, value...)
+	case []byte:
+		return append(buf, value...)
+	case func() string:
+		return append(buf, value()...)
+	case error:
+		return append(buf, value.Error()...)
+	case interface{ String() string }:
+		return append(buf, value.String()...)
+	case bool:
+		return strconv.AppendBool(buf, value)
+	case int:
+		return strconv.AppendInt(buf, int64(value), 10)
+	case int8:
+		return strconv.AppendInt(buf, int64(value), 10)
+	case int16:
+		return strconv.AppendInt(buf, int64(value), 10)
+	case int32:
+		return strconv.AppendInt(buf, int64(value), 10)
+	case int64:
+		return strconv.AppendInt(buf, value, 10)
+	case uint:
+		return strconv.AppendUint(buf, uint64(value), 10)
+	case uint8:
+		return strconv.AppendUint(buf, uint64(value), 10)
+	case uint16:
+		return strconv.AppendUint(buf, uint64(value), 10)
+	case uint32:
+		return strconv.AppendUint(buf, uint64(value), 10)
+	case uint64:
+		return strconv.AppendUint(buf, value, 10)
+	case uintptr:
+		return strconv.AppendUint(buf, uint64(value), 10)
+	default:
+		return append(buf, '?')
+	}
+}
@@ -0,0 +1,33 @@
+//go:build hostlog.ext
+
+package hostlog
+
+// Messages are passed to the logging functions of Polkadot hosts. The host
+// tells which levels it is interested in, so that messages that would be
+// dropped anyway aren't formatted.
+
+import "unsafe"
+
+//go:wasmimport env ext_logging_log_version_1
+func extLog(level uint32, target, message uint64)
+
+//go:wasmimport env ext_logging_max_level_version_1
+func extMaxLevel() uint32
+
+func enabled(level Level) bool {
+	return uint32(level) <= extMaxLevel()
+}
+
+func output(level Level, msg string) {
+	extLog(uint32(level), pointerSize(Target), pointerSize(msg))
+}
+
+// pointerSize returns the Polkadot pointer-size of the string: the pointer in
+// the low 32 bits and the length in the high 32 bits.
+func pointerSize(s string) uint64 {
+	if len(s) == 0 {
+		return 0
+	}
+	ptr := *(*unsafe.Pointer)(unsafe.Pointer(&s))
+	return uint64(uintptr(ptr)) | uint64(len(s))<<32
+}
@@ -0,0 +1,14 @@
+//go:build !hostlog.ext
+
+package hostlog
+
+// Without a host logging function, messages are written to the debug output
+// with the builtin print function.
+
+func enabled(level Level) bool {
+	return true
+}
+
+func output(level Level, msg string) {
+	println(level.String(), Target+":", msg)
+}
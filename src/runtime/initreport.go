@@ -0,0 +1,71 @@
+//go:build tinygo.reportinit
+
+package runtime
+
+// Package initialization costs for -report-init. The compiler inserts calls to
+// initReportEnter and initReportExit around every package initializer that
+// could not be evaluated at compile time, so these run every time the module is
+// instantiated. The host reads the costs through the tinygo_init_report export
+// once the module is initialized.
+
+import "unsafe"
+
+// The cost of a single package initializer, as stored in linear memory.
+type initCost struct {
+	name        uint32 // pointer to the package path
+	nameLen     uint32
+	nanoseconds uint64
+	mallocs     uint64
+	allocBytes  uint64
+}
+
+// Maximum number of package initializers that can be recorded.
+const initReportMax = 1024
+
+var (
+	initReport      [initReportMax]initCost
+	initReportCount uint32
+	initReportStart initCost // values at the start of the current initializer
+)
+
+func initReportEnter(pkg string) {
+	var m MemStats
+	ReadMemStats(&m)
+	initReportStart = initCost{
+		name:        uint32(uintptr(unsafe.Pointer((*_string)(unsafe.Pointer(&pkg)).ptr))),
+		nameLen:     uint32(len(pkg)),
+		nanoseconds: uint64(ticksToNanoseconds(ticks())),
+		mallocs:     m.Mallocs,
+		allocBytes:  m.TotalAlloc,
+	}
+}
+
+func initReportExit() {
+	now := uint64(ticksToNanoseconds(ticks()))
+	var m MemStats
+	ReadMemStats(&m)
+	if initReportCount == initReportMax {
+		return
+	}
+	start := initReportStart
+	initReport[initReportCount] = initCost{
+		name:        start.name,
+		nameLen:     start.nameLen,
+		nanoseconds: now - start.nanoseconds,
+		mallocs:     m.Mallocs - start.mallocs,
+		allocBytes:  m.TotalAlloc - start.allocBytes,
+	}
+	initReportCount++
+}
+
+// Return the recorded package initializers as a pointer-size: the pointer in
+// the low 32 bits and the number of bytes in the high 32 bits. Each entry is 32
+// bytes: a little endian uint32 pointer to the package path, a uint32 length of
+// the package path, and the uint64 time in nanoseconds, number of allocations
+// and number of allocated bytes.
+//
+//export tinygo_init_report
+func exportInitReport() uint64 {
+	size := uintptr(initReportCount) * unsafe.Sizeof(initCost{})
+	return uint64(uintptr(unsafe.Pointer(&initReport))) | uint64(size)<<32
+}
@@ -0,0 +1,73 @@
+//go:build tinygo.wasm && memroutines.deterministic
+
+package runtime
+
+// The memcpy, memmove and memset functions for the mem-routines target property
+// set to deterministic, or the mem group of the go-helpers target property.
+// They replace the ones of the C library, which take different paths depending
+// on the alignment of the pointers (and for memmove, on whether the
+// destination comes before the source). With those, the number of
+// instructions executed for the same input can differ between builds that
+// place the data at different addresses, which matters to hosts that meter
+// instructions, like the weights of Polkadot runtimes.
+//
+// These functions copy 8 bytes at a time, with unaligned loads and stores
+// (which WebAssembly allows), followed by the remaining bytes one at a time.
+// The number of instructions they execute only depends on the length. The
+// builder keeps them in the module and stops LLVM from replacing their loops
+// with calls to themselves, see setRuntimeHelpers.
+
+import "unsafe"
+
+//go:linkname memcpyDeterministic memcpy
+func memcpyDeterministic(dst, src unsafe.Pointer, n uintptr) unsafe.Pointer {
+	words := n / 8
+	for i := uintptr(0); i < words; i++ {
+		*(*[8]byte)(unsafe.Add(dst, i*8)) = *(*[8]byte)(unsafe.Add(src, i*8))
+	}
+	for i := words * 8; i < n; i++ {
+		*(*byte)(unsafe.Add(dst, i)) = *(*byte)(unsafe.Add(src, i))
+	}
+	return dst
+}
+
+//go:linkname memmoveDeterministic memmove
+func memmoveDeterministic(dst, src unsafe.Pointer, n uintptr) unsafe.Pointer {
+	// Copy backward if dst comes after src, so that overlapping bytes are read
+	// before they're overwritten. Both directions run the same loops, only
+	// the start offsets and the steps differ: going backward, the words are
+	// copied from the end down and the remaining bytes are at the start.
+	words := n / 8
+	tail := n % 8
+	backward := uintptr(0) // all ones when copying backward
+	if uintptr(dst) > uintptr(src) {
+		backward = ^uintptr(0)
+	}
+	offset := (n - 8) & backward
+	step := 8 - 16&backward // 8 or -8
+	for i := uintptr(0); i < words; i++ {
+		*(*[8]byte)(unsafe.Add(dst, offset)) = *(*[8]byte)(unsafe.Add(src, offset))
+		offset += step
+	}
+	offset = words*8&^backward | (tail-1)&backward
+	step = 1 - 2&backward // 1 or -1
+	for i := uintptr(0); i < tail; i++ {
+		*(*byte)(unsafe.Add(dst, offset)) = *(*byte)(unsafe.Add(src, offset))
+		offset += step
+	}
+	return dst
+}
+
+//go:linkname memsetDeterministic memset
+func memsetDeterministic(ptr unsafe.Pointer, c int32, n uintptr) unsafe.Pointer {
+	b := byte(c)
+	word := [8]byte{b, b, b, b, b, b, b, b}
+	words := n / 8
+	for i := uintptr(0); i < words; i++ {
+		*(*[8]byte)(unsafe.Add(ptr, i*8)) = word
+	}
+	for i := words * 8; i < n; i++ {
+		*(*byte)(unsafe.Add(ptr, i)) = b
+	}
+	return ptr
+}
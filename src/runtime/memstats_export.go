@@ -0,0 +1,33 @@
+//go:build tinygo.gcdiff
+
+package runtime
+
+// Memory statistics for tinygo run -gc-diff, which runs the same program with
+// different GCs and compares the results. The host calls tinygo_memstats after
+// the program has returned from main.
+
+import "unsafe"
+
+// The MemStats fields that are exported, in the order the host expects them.
+var exportedMemStats [9]uint64
+
+// Return the current memory statistics as a pointer-size: the pointer in the
+// low 32 bits and the number of bytes in the high 32 bits.
+//
+//export tinygo_memstats
+func exportMemStats() uint64 {
+	var m MemStats
+	ReadMemStats(&m)
+	exportedMemStats = [9]uint64{
+		m.Sys,
+		m.HeapSys,
+		m.HeapIdle,
+		m.HeapInuse,
+		m.HeapReleased,
+		m.TotalAlloc,
+		m.Mallocs,
+		m.Frees,
+		m.GCSys,
+	}
+	return uint64(uintptr(unsafe.Pointer(&exportedMemStats))) | uint64(unsafe.Sizeof(exportedMemStats))<<32
+}
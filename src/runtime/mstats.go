@@ -57,4 +57,34 @@ type MemStats struct {
 
 	// GCSys is bytes of memory in garbage collection metadata.
 	GCSys uint64
+
+	// Heap fragmentation statistics (TinyGo-specific).
+	//
+	// These are only set by GCs that get their memory from an external
+	// allocator (-gc=extalloc). They're calculated from the addresses of all
+	// heap objects, so they only include the gaps between heap objects and
+	// not the free memory of the external allocator before the first or after
+	// the last object. Gaps smaller than a few words are not counted, as
+	// these are usually bookkeeping of the external allocator.
+
+	// HeapSpan is bytes in the address range from the start of the lowest
+	// heap object to the end of the highest heap object.
+	HeapSpan uint64
+
+	// HeapGaps is the number of gaps between heap objects.
+	HeapGaps uint64
+
+	// HeapGapBytes is bytes in gaps between heap objects.
+	HeapGapBytes uint64
+
+	// HeapLargestGap is bytes in the largest gap between heap objects. This is
+	// an estimate of the largest object that can be allocated without the
+	// external allocator getting more memory.
+	HeapLargestGap uint64
+
+	// HeapFragmentation is the fraction of the memory in gaps that can't be
+	// used for an object of HeapLargestGap bytes: 0 if there is at most a
+	// single gap, approaching 1 if the gap memory is spread over many small
+	// gaps.
+	HeapFragmentation float64
 }
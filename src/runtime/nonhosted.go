@@ -1,4 +1,4 @@
-//go:build baremetal || js
+//go:build baremetal || js || wasm_unknown
 
 package runtime
 
@@ -31,26 +31,22 @@ var osEnv string
 
 func init() {
 	if osArgs != "" {
-		s := osArgs
-		start := 0
-		for i := 0; i < len(s); i++ {
-			if s[i] == 0 {
-				args = append(args, s[start:i])
-				start = i + 1
-			}
-		}
-		args = append(args, s[start:])
+		args = append(args, splitNulSeparated(osArgs)...)
 	}
-
 	if osEnv != "" {
-		s := osEnv
-		start := 0
-		for i := 0; i < len(s); i++ {
-			if s[i] == 0 {
-				env = append(env, s[start:i])
-				start = i + 1
-			}
+		env = append(env, splitNulSeparated(osEnv)...)
+	}
+}
+
+// splitNulSeparated splits a list of strings separated by NUL bytes.
+func splitNulSeparated(s string) []string {
+	var list []string
+	start := 0
+	for i := 0; i < len(s); i++ {
+		if s[i] == 0 {
+			list = append(list, s[start:i])
+			start = i + 1
 		}
-		env = append(env, s[start:])
 	}
+	return append(list, s[start:])
 }
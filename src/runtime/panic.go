@@ -46,6 +46,11 @@ func _panic(message interface{}) {
 			// unreachable
 		}
 	}
+	if msg, ok := message.(string); ok {
+		recordTrap(trapPanic, 0, msg)
+	} else {
+		recordTrap(trapPanic, 0, "")
+	}
 	printstring("panic: ")
 	printitf(message)
 	printnl()
@@ -60,6 +65,13 @@ func runtimePanic(msg string) {
 }
 
 func runtimePanicAt(addr unsafe.Pointer, msg string) {
+	runtimePanicReason(addr, trapRuntimeError, 0, msg)
+}
+
+// runtimePanicReason is like runtimePanicAt, but records a more specific reason
+// for the host (see recordTrap) with some extra data depending on the reason.
+func runtimePanicReason(addr unsafe.Pointer, reason uint32, aux uintptr, msg string) {
+	recordTrap(reason, aux, msg)
 	if hasReturnAddr {
 		printstring("panic: runtime error at ")
 		printptr(uintptr(addr) - callInstSize)
@@ -131,7 +143,7 @@ func _recover(useParentFrame bool) interface{} {
 
 // Panic when trying to dereference a nil pointer.
 func nilPanic() {
-	runtimePanicAt(returnAddress(0), "nil pointer dereference")
+	runtimePanicReason(returnAddress(0), trapNilPointer, 0, "nil pointer dereference")
 }
 
 // Panic when trying to add an entry to a nil map
@@ -141,25 +153,90 @@ func nilMapPanic() {
 
 // Panic when trying to acces an array or slice out of bounds.
 func lookupPanic() {
-	runtimePanicAt(returnAddress(0), "index out of range")
+	runtimePanicReason(returnAddress(0), trapOutOfRange, 0, "index out of range")
 }
 
 // Panic when trying to slice a slice out of bounds.
 func slicePanic() {
-	runtimePanicAt(returnAddress(0), "slice out of range")
+	runtimePanicReason(returnAddress(0), trapOutOfRange, 0, "slice out of range")
+}
+
+// Panic when trying to access an array or slice out of bounds, with the index
+// and length in the message like the gc toolchain. The compiler only uses this
+// function instead of lookupPanic with Config.BoundsMessages, pos is the source
+// position of the index expression.
+func lookupPanicDetails(index, length int64, pos string) {
+	recordTrap(trapOutOfRange, 0, "index out of range")
+	printBoundsPanicPrefix(pos)
+	printstring("index out of range [")
+	printint64(index)
+	printstring("]")
+	if index >= 0 {
+		printstring(" with length ")
+		printint64(length)
+	}
+	printnl()
+	abort()
+}
+
+// Panic when trying to slice a slice out of bounds, with the bounds in the
+// message like the gc toolchain. The compiler only uses this function instead
+// of slicePanic with Config.BoundsMessages. For a slice expression without a
+// max, max is the capacity.
+func slicePanicDetails(low, high, max, capacity int64, pos string) {
+	recordTrap(trapOutOfRange, 0, "slice out of range")
+	printBoundsPanicPrefix(pos)
+	printstring("slice bounds out of range [")
+	switch {
+	case max > capacity || max < 0:
+		printstring("::")
+		printint64(max)
+		printstring("] with capacity ")
+		printint64(capacity)
+	case max == capacity && (high > max || high < 0):
+		printstring(":")
+		printint64(high)
+		printstring("] with capacity ")
+		printint64(capacity)
+	case high > max || high < 0:
+		printstring(":")
+		printint64(high)
+		printstring(":")
+		printint64(max)
+		printstring("]")
+	default:
+		printint64(low)
+		printstring(":")
+		printint64(high)
+		printstring("]")
+	}
+	printnl()
+	abort()
+}
+
+// printBoundsPanicPrefix prints the start of a bounds check panic message,
+// with the source position if there is one.
+func printBoundsPanicPrefix(pos string) {
+	if pos != "" {
+		printstring("panic: runtime error at ")
+		printstring(pos)
+		printstring(": ")
+	} else {
+		printstring("panic: runtime error: ")
+	}
 }
 
 // Panic when trying to convert a slice to an array pointer (Go 1.17+) and the
 // slice is shorter than the array.
 func sliceToArrayPointerPanic() {
-	runtimePanicAt(returnAddress(0), "slice smaller than array")
+	runtimePanicReason(returnAddress(0), trapOutOfRange, 0, "slice smaller than array")
 }
 
 // Panic when calling unsafe.Slice() (Go 1.17+) or unsafe.String() (Go 1.20+)
 // with a len that's too large (which includes if the ptr is nil and len is
 // nonzero).
 func unsafeSlicePanic() {
-	runtimePanicAt(returnAddress(0), "unsafe.Slice/String: len out of range")
+	runtimePanicReason(returnAddress(0), trapOutOfRange, 0, "unsafe.Slice/String: len out of range")
 }
 
 // Panic when trying to create a new channel that is too big.
@@ -177,6 +254,12 @@ func divideByZeroPanic() {
 	runtimePanicAt(returnAddress(0), "divide by zero")
 }
 
+// Panic when a goroutine stack overflowed. This is only detected by some
+// schedulers.
+func stackOverflowPanic() {
+	runtimePanicReason(returnAddress(0), trapStackOverflow, 0, "stack overflow")
+}
+
 func blockingPanic() {
 	runtimePanicAt(returnAddress(0), "trying to do blocking operation in exported function")
 }
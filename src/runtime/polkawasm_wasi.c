@@ -0,0 +1,17 @@
+//go:build polkawasm_wasi
+
+// This file provides the Polkadot allocator host functions on top of the
+// wasi-libc malloc and free, for the polkawasm-wasi target. This makes it
+// possible to run programs that use the same memory model as on a Polkadot
+// host directly under a WASI runtime like wasmtime or wazero.
+
+#include <stdint.h>
+#include <stdlib.h>
+
+uint32_t ext_allocator_malloc_version_1(uint32_t size) {
+    return (uint32_t)(uintptr_t)malloc(size);
+}
+
+void ext_allocator_free_version_1(uint32_t ptr) {
+    free((void *)(uintptr_t)ptr);
+}
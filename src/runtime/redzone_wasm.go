@@ -0,0 +1,50 @@
+//go:build tinygo.wasm
+
+package runtime
+
+import "unsafe"
+
+// wasmRedzoneSize is the size in bytes of the redzone at the bottom of the
+// system stack, set by the compiler for the -redzone flag. It's zero if there
+// is no redzone.
+//
+// The redzone is the part of the stack that is closest to the memory below it:
+// the globals when the stack is placed after them. A stack overflow writes
+// into the redzone before it corrupts any globals. The stack starts out zeroed
+// and a program shouldn't use this much of it, so the redzone must stay zero.
+// This is checked when an exported function is entered and when it returns to
+// the host, which catches most overflows that would otherwise silently
+// corrupt memory. Writes of only zero bytes are not detected.
+var wasmRedzoneSize uintptr
+
+// checkRedzone panics with a stack overflow if something was written to the
+// redzone.
+func checkRedzone() {
+	if addr, dirty := redzoneDirty(); dirty {
+		runtimePanicReason(returnAddress(0), trapStackOverflow, addr, "stack overflow into redzone")
+	}
+}
+
+// redzoneDirty returns whether something was written to the redzone, and the
+// address of the first word that isn't zero.
+func redzoneDirty() (uintptr, bool) {
+	if wasmRedzoneSize == 0 {
+		return 0, false
+	}
+	bottom, top := StackBounds()
+	end := bottom + wasmRedzoneSize
+	if end > top {
+		end = top
+	}
+	if bottom == 0 {
+		// Don't read from the null pointer. The first bytes of memory are
+		// never used by the program anyway.
+		bottom = 16
+	}
+	for addr := bottom; addr+4 <= end; addr += 4 {
+		if *(*uint32)(unsafe.Pointer(addr)) != 0 {
+			return addr, true
+		}
+	}
+	return 0, false
+}
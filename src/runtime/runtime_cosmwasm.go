@@ -0,0 +1,98 @@
+//go:build cosmwasm
+
+package runtime
+
+// This file implements the memory interface of CosmWasm contracts. The host
+// passes data to the contract (and the contract returns data to the host)
+// through regions: a small struct that describes a block of memory in the
+// contract. The host asks the contract to allocate regions with the exported
+// allocate function, and frees regions returned by the contract with the
+// exported deallocate function.
+// See: https://github.com/CosmWasm/cosmwasm/blob/main/packages/std/src/memory.rs
+
+import "unsafe"
+
+// cosmwasmRegion is the region struct as used by the CosmWasm host.
+type cosmwasmRegion struct {
+	offset   uint32
+	capacity uint32
+	length   uint32
+}
+
+// Regions that are currently owned by the host. They must be kept alive until
+// they are freed by deallocate, or handed over to the contract by
+// cosmwasmRead.
+var cosmwasmRegions map[*cosmwasmRegion][]byte
+
+var cosmwasmInitialized bool
+
+// cosmwasmInit initializes the runtime. The CosmWasm host doesn't call
+// _initialize, but it always calls allocate to pass the input of an entry
+// point so it is done there.
+func cosmwasmInit() {
+	if !cosmwasmInitialized {
+		cosmwasmInitialized = true
+		_initialize()
+		cosmwasmRegions = make(map[*cosmwasmRegion][]byte)
+	}
+}
+
+// cosmwasmNewRegion allocates a region and a buffer of the given capacity.
+func cosmwasmNewRegion(capacity, length uint32) *cosmwasmRegion {
+	buf := make([]byte, capacity)
+	region := &cosmwasmRegion{
+		capacity: capacity,
+		length:   length,
+	}
+	if capacity != 0 {
+		region.offset = uint32(uintptr(unsafe.Pointer(&buf[0])))
+	}
+	cosmwasmRegions[region] = buf
+	return region
+}
+
+//export allocate
+func cosmwasmAllocate(size uint32) *cosmwasmRegion {
+	cosmwasmInit()
+	return cosmwasmNewRegion(size, 0)
+}
+
+//export deallocate
+func cosmwasmDeallocate(region *cosmwasmRegion) {
+	if _, ok := cosmwasmRegions[region]; !ok {
+		runtimePanic("deallocate: invalid region")
+	}
+	delete(cosmwasmRegions, region)
+}
+
+// Version of the CosmWasm interface implemented by this runtime. The host
+// checks for this export.
+//
+//export interface_version_8
+func cosmwasmInterfaceVersion8() {}
+
+// cosmwasmRead returns the contents of a region passed in by the host. The
+// region is now owned by the contract: the returned slice stays valid for as
+// long as it is referenced.
+//
+//go:linkname cosmwasmRead cosmwasm.Read
+func cosmwasmRead(ptr uint32) []byte {
+	region := (*cosmwasmRegion)(unsafe.Pointer(uintptr(ptr)))
+	buf, ok := cosmwasmRegions[region]
+	if !ok {
+		runtimePanic("cosmwasm: invalid region")
+	}
+	delete(cosmwasmRegions, region)
+	return buf[:region.length]
+}
+
+// cosmwasmWrite copies data to a new region that can be returned to the host,
+// and returns the address of the region. The host frees it with deallocate.
+//
+//go:linkname cosmwasmWrite cosmwasm.Write
+func cosmwasmWrite(data []byte) uint32 {
+	cosmwasmInit()
+	region := cosmwasmNewRegion(uint32(len(data)), uint32(len(data)))
+	copy(cosmwasmRegions[region], data)
+	return uint32(uintptr(unsafe.Pointer(region)))
+}
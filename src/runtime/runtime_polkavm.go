@@ -0,0 +1,174 @@
+//go:build polkavm
+
+package runtime
+
+// This file implements the runtime for PolkaVM, a RISC-V based virtual machine
+// for smart contracts and JAM services. A PolkaVM program has no main entry
+// point: the host calls the exported functions of the program, which are
+// listed in the metadata added by transform.AddPolkaVMMetadata. The program is
+// initialized when the host calls an exported function for the first time.
+//
+// The only memory that can be allocated is the heap after the globals, which is
+// grown with the sbrk instruction and never shrinks. The extalloc GC gets its
+// memory from polkavm_malloc and polkavm_free below, which keep a free list of
+// the blocks handed back by the GC so that they can be reused.
+// See: https://github.com/paritytech/polkavm
+
+import (
+	"device"
+	"unsafe"
+)
+
+type timeUnit int64
+
+// PolkaVM has no clock, time only advances by sleeping.
+var timestamp timeUnit
+
+var polkavmInitialized bool
+
+// polkavmExportEnter is called at the start of every exported function, with
+// the stack pointer of the wrapper that the host called. Calls to it are
+// inserted by the compiler, see transform.AddPolkaVMMetadata.
+//
+// The stack is empty between calls from the host, so the stack pointer of the
+// current call is the top of the stack that the GC scans.
+func polkavmExportEnter(sp unsafe.Pointer) {
+	stackTop = uintptr(sp)
+	if !polkavmInitialized {
+		polkavmInitialized = true
+		initHeap()
+		initAll()
+	}
+}
+
+// polkavmBlock is the header of a block allocated by polkavm_malloc. The next
+// field is only used while the block is in the free list.
+type polkavmBlock struct {
+	size uintptr // size of the block, without the header
+	next *polkavmBlock
+}
+
+const polkavmBlockHeader = unsafe.Sizeof(polkavmBlock{})
+
+// polkavmFreeList is the list of free blocks, sorted by address.
+var polkavmFreeList *polkavmBlock
+
+// polkavmSbrk grows the heap by size bytes with the sbrk instruction. It returns
+// 0 if the heap couldn't be grown, and the current end of the heap if size is 0.
+func polkavmSbrk(size uintptr) uintptr {
+	return device.AsmFull(".insn r 0xb, 1, 0, {}, {size}, zero", map[string]interface{}{
+		"size": size,
+	})
+}
+
+// polkavm_malloc allocates size bytes for the extalloc GC, from the free list
+// if there is a big enough block, or else by growing the heap. It returns nil
+// when the heap can't be grown.
+//
+//export polkavm_malloc
+func polkavmMalloc(size uintptr) unsafe.Pointer {
+	size = align(size)
+	for prev := &polkavmFreeList; *prev != nil; prev = &(*prev).next {
+		block := *prev
+		if block.size < size {
+			continue
+		}
+		if block.size >= size+polkavmBlockHeader+align(1) {
+			// Split the block, the rest stays in the free list.
+			rest := (*polkavmBlock)(unsafe.Add(unsafe.Pointer(block), polkavmBlockHeader+size))
+			rest.size = block.size - size - polkavmBlockHeader
+			rest.next = block.next
+			block.size = size
+			*prev = rest
+		} else {
+			*prev = block.next
+		}
+		return unsafe.Add(unsafe.Pointer(block), polkavmBlockHeader)
+	}
+
+	// The initial end of the heap is only aligned to 4 bytes.
+	end := polkavmSbrk(0)
+	padding := align(end) - end
+	if polkavmSbrk(padding+polkavmBlockHeader+size) == 0 {
+		return nil
+	}
+	block := (*polkavmBlock)(unsafe.Pointer(end + padding))
+	block.size = size
+	return unsafe.Add(unsafe.Pointer(block), polkavmBlockHeader)
+}
+
+// polkavm_free adds a block allocated with polkavm_malloc to the free list,
+// merging it with the blocks right before and after it.
+//
+//export polkavm_free
+func polkavmFree(ptr unsafe.Pointer) {
+	if ptr == nil {
+		return
+	}
+	block := (*polkavmBlock)(unsafe.Add(ptr, -int(polkavmBlockHeader)))
+	var prev *polkavmBlock
+	next := polkavmFreeList
+	for next != nil && uintptr(unsafe.Pointer(next)) < uintptr(unsafe.Pointer(block)) {
+		prev = next
+		next = next.next
+	}
+	if next != nil && polkavmBlockEnd(block) == uintptr(unsafe.Pointer(next)) {
+		block.size += polkavmBlockHeader + next.size
+		next = next.next
+	}
+	block.next = next
+	if prev == nil {
+		polkavmFreeList = block
+	} else if polkavmBlockEnd(prev) == uintptr(unsafe.Pointer(block)) {
+		prev.size += polkavmBlockHeader + block.size
+		prev.next = block.next
+	} else {
+		prev.next = block
+	}
+}
+
+func polkavmBlockEnd(block *polkavmBlock) uintptr {
+	return uintptr(unsafe.Pointer(block)) + polkavmBlockHeader + block.size
+}
+
+func ticksToNanoseconds(ticks timeUnit) int64 {
+	return int64(ticks)
+}
+
+func nanosecondsToTicks(ns int64) timeUnit {
+	return timeUnit(ns)
+}
+
+func sleepTicks(d timeUnit) {
+	timestamp += d
+}
+
+func ticks() timeUnit {
+	return timestamp
+}
+
+// PolkaVM has no standard output, so everything written to it is dropped.
+func putchar(c byte) {
+}
+
+func getchar() byte {
+	// dummy, TODO
+	return 0
+}
+
+func buffered() int {
+	// dummy, TODO
+	return 0
+}
+
+// abort executes the unimp instruction, which makes PolkaVM trap.
+func abort() {
+	for {
+		device.Asm("unimp")
+	}
+}
+
+// There is no way to stop the program other than trapping.
+func exit(code int) {
+	abort()
+}
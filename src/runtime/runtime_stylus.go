@@ -0,0 +1,61 @@
+//go:build stylus
+
+package runtime
+
+// This file implements the entry point of Arbitrum Stylus contracts. The host
+// calls user_entrypoint with the length of the call data, and the contract
+// returns 0 on success or 1 when reverting. The result data is passed back
+// through write_result. Every call runs in a fresh instance, so main.main is
+// simply called on every entry and the heap is never collected.
+// See: https://github.com/OffchainLabs/stylus-sdk-rs
+
+import "unsafe"
+
+//go:wasmimport vm_hooks read_args
+func stylusReadArgs(dest unsafe.Pointer)
+
+//go:wasmimport vm_hooks write_result
+func stylusWriteResult(data unsafe.Pointer, length uint32)
+
+//go:wasmimport vm_hooks pay_for_memory_grow
+func stylusPayForMemoryGrow(pages uint32)
+
+var (
+	stylusArgs   []byte
+	stylusStatus int32
+)
+
+//export user_entrypoint
+func stylusEntrypoint(length uint32) int32 {
+	_initialize()
+	stylusArgs = make([]byte, length)
+	if length != 0 {
+		stylusReadArgs(unsafe.Pointer(&stylusArgs[0]))
+	}
+	callMain()
+	return stylusStatus
+}
+
+// The host charges gas for memory growth, which must be paid for before the
+// memory.grow instruction is executed.
+func payForMemoryGrow(pages int32) {
+	stylusPayForMemoryGrow(uint32(pages))
+}
+
+//go:linkname stylusGetArgs stylus.Args
+func stylusGetArgs() []byte {
+	return stylusArgs
+}
+
+//go:linkname stylusSetResult stylus.setResult
+func stylusSetResult(data []byte, revert bool) {
+	stylusStatus = 0
+	if revert {
+		stylusStatus = 1
+	}
+	var ptr unsafe.Pointer
+	if len(data) != 0 {
+		ptr = unsafe.Pointer(&data[0])
+	}
+	stylusWriteResult(ptr, uint32(len(data)))
+}
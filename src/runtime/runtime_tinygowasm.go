@@ -37,6 +37,7 @@ var (
 )
 
 func putchar(c byte) {
+	crashDumpPutchar(c)
 	putcharBuffer[putcharPosition] = c
 	putcharPosition++
 
@@ -47,6 +48,15 @@ func putchar(c byte) {
 	}
 }
 
+// putcharSelfcheck checks that the buffer of putchar is valid. It returns the
+// invariants that don't hold, as selfcheck bits.
+func putcharSelfcheck() uint32 {
+	if putcharPosition >= putcharBufferSize || putcharIOVec.buf != unsafe.Pointer(&putcharBuffer[0]) {
+		return selfcheckDebugBuffer
+	}
+	return 0
+}
+
 func getchar() byte {
 	// dummy, TODO
 	return 0
@@ -96,3 +106,14 @@ func hardwareRand() (n uint64, ok bool) {
 //
 //export arc4random
 func libc_arc4random() uint32
+
+// wasmInitialize initializes the heap and all packages, without running main.
+// It is called from the first exported function if the host calls it before
+// _start, which normally initializes the program.
+func wasmInitialize() {
+	wasmInitialized = true
+	heapStart = uintptr(unsafe.Pointer(&heapStartSymbol))
+	heapEnd = uintptr(wasm_memory_size(0) * wasmPageSize)
+	initHeap()
+	initAll()
+}
@@ -7,21 +7,25 @@ const (
 )
 
 func putchar(c byte) {
+	crashDumpPutchar(c)
+	testPutchar(c)
 }
 
-func getchar() byte {
-	// dummy, TODO
-	return 0
-}
-
-func buffered() int {
-	// dummy, TODO
+// putcharSelfcheck checks that the buffer of putchar is valid. It returns the
+// invariants that don't hold, as selfcheck bits.
+func putcharSelfcheck() uint32 {
+	if !testPutcharValid() {
+		return selfcheckDebugBuffer
+	}
 	return 0
 }
 
 //go:linkname now time.now
 func now() (sec int64, nsec int32, mono int64) {
-	return 0, 0, 0
+	mono = nanotime()
+	sec = mono / (1000 * 1000 * 1000)
+	nsec = int32(mono - sec*(1000*1000*1000))
+	return
 }
 
 // Abort executes the wasm 'unreachable' instruction.
@@ -29,8 +33,24 @@ func abort() {
 	trap()
 }
 
+// exitCode is the code passed to os.Exit, or -1 if it wasn't called.
+var exitCode int32 = -1
+
+// There is no way to stop the program other than trapping. The host can read
+// the exit code afterwards through tinygo_exit_code, to tell an exit apart from
+// a panic (which leaves the exit code at -1).
+//
 //go:linkname syscall_Exit syscall.Exit
 func syscall_Exit(code int) {
+	exitCode = int32(code)
+	testExit()
+	wasmExportAbort()
+	trap()
+}
+
+//export tinygo_exit_code
+func exportExitCode() int32 {
+	return exitCode
 }
 
 // There is not yet any support for any form of parallelism on WebAssembly, so these
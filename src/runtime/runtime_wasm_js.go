@@ -12,6 +12,7 @@ var wasmNested bool
 
 //export _start
 func _start() {
+	wasmInitialized = true
 	// These need to be initialized early so that the heap can be initialized.
 	heapStart = uintptr(unsafe.Pointer(&heapStartSymbol))
 	heapEnd = uintptr(wasm_memory_size(0) * wasmPageSize)
@@ -13,9 +13,18 @@ func __wasm_call_ctors()
 
 //export _initialize
 func _initialize() {
+	wasmInitialize()
+}
+
+// wasmInitialize initializes the heap and all packages. It is called from
+// _initialize, or from the first exported function if the host doesn't call
+// _initialize.
+func wasmInitialize() {
+	wasmInitialized = true
 	// These need to be initialized early so that the heap can be initialized.
 	heapStart = uintptr(unsafe.Pointer(&heapStartSymbol))
 	heapEnd = uintptr(wasm_memory_size(0) * wasmPageSize)
+	initHeap()
 	initAll()
 }
 
@@ -23,8 +32,6 @@ func init() {
 	__wasm_call_ctors()
 }
 
-var args []string
-
 func ticksToNanoseconds(ticks timeUnit) int64 {
 	return int64(ticks)
 }
@@ -38,7 +45,3 @@ const timePrecisionNanoseconds = 1000
 
 func sleepTicks(d timeUnit) {
 }
-
-func ticks() timeUnit {
-	return timeUnit(0)
-}
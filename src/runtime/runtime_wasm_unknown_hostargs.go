@@ -0,0 +1,34 @@
+//go:build wasm_unknown && tinygo.hostargs
+
+package runtime
+
+// With -host-args, the command line arguments and the environment variables are
+// read from the host at startup. This is meant for test harnesses and command
+// line tools built for wasm-unknown, on-chain builds don't import these
+// functions. Both host functions write a NUL separated list to the given buffer
+// (a pointer-size) if it is large enough, and return the size of the list. So
+// they are called once to get the size and once more to read the list.
+//
+// The arguments from the host include the program name and replace the default
+// arguments, unless the host doesn't provide any.
+
+import "unsafe"
+
+//go:wasmimport env tinygo_args_get
+func hostArgsGet(buf uint64) uint32
+
+//go:wasmimport env tinygo_environ_get
+func hostEnvironGet(buf uint64) uint32
+
+func init() {
+	if size := hostArgsGet(0); size != 0 {
+		buf := make([]byte, size)
+		hostArgsGet(uint64(uintptr(unsafe.Pointer(&buf[0]))) | uint64(size)<<32)
+		args = splitNulSeparated(string(buf))
+	}
+	if size := hostEnvironGet(0); size != 0 {
+		buf := make([]byte, size)
+		hostEnvironGet(uint64(uintptr(unsafe.Pointer(&buf[0]))) | uint64(size)<<32)
+		env = append(env, splitNulSeparated(string(buf))...)
+	}
+}
@@ -0,0 +1,69 @@
+//go:build wasm_unknown && tinygo.hostinput
+
+package runtime
+
+// With -host-input, the standard input is read from the host through the
+// tinygo_stdin_read function. This is meant for REPL-style debugging tools and
+// tests that read from os.Stdin, on-chain builds don't import this function.
+// The host function fills the given buffer (a pointer-size) and returns the
+// number of bytes written, or -1 at the end of the input. It may block until
+// input is available.
+
+import "unsafe"
+
+//go:wasmimport env tinygo_stdin_read
+func hostStdinRead(buf uint64) int32
+
+// Ring buffer with the input that was read from the host but not yet by the
+// program.
+var (
+	inputBuffer [128]byte
+	inputHead   int // index of the next byte to return from getchar
+	inputLen    int // number of bytes in the buffer
+	inputEOF    bool
+)
+
+// getchar returns the next byte of the input, waiting for the host if needed.
+// It returns 0 at the end of the input.
+func getchar() byte {
+	if buffered() <= 0 {
+		return 0
+	}
+	c := inputBuffer[inputHead]
+	inputHead = (inputHead + 1) % len(inputBuffer)
+	inputLen--
+	return c
+}
+
+// buffered returns the number of bytes that can be read with getchar without
+// waiting, or -1 at the end of the input. The host is only asked for more
+// input once the buffer is empty, because it may block.
+func buffered() int {
+	if inputLen == 0 && !inputEOF {
+		inputFill()
+	}
+	if inputLen == 0 && inputEOF {
+		return -1
+	}
+	return inputLen
+}
+
+// inputFill reads as much input from the host as fits in the free space after
+// the last byte in the ring buffer, up to the end of the array.
+func inputFill() {
+	tail := (inputHead + inputLen) % len(inputBuffer)
+	end := len(inputBuffer)
+	if tail < inputHead {
+		end = inputHead
+	}
+	size := end - tail
+	n := hostStdinRead(uint64(uintptr(unsafe.Pointer(&inputBuffer[tail]))) | uint64(size)<<32)
+	if n < 0 {
+		inputEOF = true
+		return
+	}
+	if int(n) > size {
+		n = int32(size) // misbehaving host
+	}
+	inputLen += int(n)
+}
@@ -0,0 +1,17 @@
+//go:build wasm_unknown && tinygo.hostticks
+
+package runtime
+
+// With -host-ticks, the time is read from an instruction or fuel counter of the
+// host instead of a clock. Every unit counts as a nanosecond. This gives a
+// monotonic clock in deterministic environments that don't have a wall clock
+// (or where reading it isn't allowed), so that time.Since and benchmarks
+// measure something meaningful. It is also used by test binaries, to make
+// benchmarks independent of the speed of the machine.
+
+//go:wasmimport env tinygo_instruction_count
+func hostInstructionCount() uint64
+
+func ticks() timeUnit {
+	return timeUnit(hostInstructionCount())
+}
@@ -0,0 +1,14 @@
+//go:build wasm_unknown && !tinygo.hostinput
+
+package runtime
+
+// Without -host-input there is no input at all, so reading from os.Stdin
+// returns io.EOF right away.
+
+func getchar() byte {
+	return 0
+}
+
+func buffered() int {
+	return -1 // end of file
+}
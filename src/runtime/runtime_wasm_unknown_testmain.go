@@ -0,0 +1,88 @@
+//go:build wasm_unknown && tinygo.test
+
+package runtime
+
+// Test binaries on wasm-unknown need some way to run the tests and to report
+// the results. They export a _start function that runs the tests, and print
+// their output a line at a time through the ext_misc_print_utf8 host function
+// of Polkadot hosts, which is also provided by `tinygo wasmhost`. Like other
+// programs, test binaries trap when they exit and leave the exit code in
+// tinygo_exit_code. The time, which is needed for benchmarks, is read from
+// ext_offchain_timestamp (in milliseconds).
+//
+// ext_misc_print_utf8 always ends the line, so lines that don't fit in the line
+// buffer are printed in parts through tinygo_print_utf8, which doesn't. Only
+// the last part of the line is printed with ext_misc_print_utf8.
+
+import "unsafe"
+
+//go:wasmimport env ext_misc_print_utf8_version_1
+func printUTF8(data uint64)
+
+//go:wasmimport env tinygo_print_utf8
+func printUTF8Continued(data uint64)
+
+//go:wasmimport env ext_offchain_timestamp_version_1
+func offchainTimestamp() uint64
+
+var (
+	testLine    [128]byte
+	testLineLen int
+
+	// Whether part of the current line has already been printed.
+	testLineContinued bool
+)
+
+//export _start
+func _start() {
+	_initialize()
+	callMain()
+	testFlush()
+}
+
+func testPutchar(c byte) {
+	if c == '\n' {
+		testPrintLine()
+		return
+	}
+	if testLineLen == len(testLine) {
+		printUTF8Continued(testLineData())
+		testLineLen = 0
+		testLineContinued = true
+	}
+	testLine[testLineLen] = c
+	testLineLen++
+}
+
+// testPutcharValid returns whether the line buffer of testPutchar is valid.
+func testPutcharValid() bool {
+	return testLineLen >= 0 && testLineLen <= len(testLine)
+}
+
+// testPrintLine prints the current line, which may be empty.
+func testPrintLine() {
+	printUTF8(testLineData())
+	testLineLen = 0
+	testLineContinued = false
+}
+
+// testLineData returns the line buffer as a pointer-size: the pointer in the
+// low 32 bits and the length in the high 32 bits.
+func testLineData() uint64 {
+	return uint64(uintptr(unsafe.Pointer(&testLine[0]))) | uint64(testLineLen)<<32
+}
+
+// testFlush prints the current line, if it wasn't ended with a newline.
+func testFlush() {
+	if testLineLen != 0 || testLineContinued {
+		testPrintLine()
+	}
+}
+
+func testExit() {
+	testFlush()
+}
+
+func testTicks() timeUnit {
+	return timeUnit(offchainTimestamp()) * 1000_000
+}
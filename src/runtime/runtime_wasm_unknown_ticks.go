@@ -0,0 +1,7 @@
+//go:build wasm_unknown && !tinygo.hostticks
+
+package runtime
+
+func ticks() timeUnit {
+	return testTicks()
+}
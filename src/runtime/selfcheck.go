@@ -0,0 +1,33 @@
+package runtime
+
+// Self-checks of the runtime state, for -selfcheck. The checks return the
+// invariants that don't hold as a bitmask, so that a host can tell which part
+// of the runtime state is corrupted. Keep the bits in sync with
+// wasmhost/selfcheck.go.
+const (
+	selfcheckHeapOrder   = 1 << iota // heap objects are not sorted or overlap
+	selfcheckHeapBounds              // heap object or heap metadata outside of the heap
+	selfcheckHeapState               // mark bits left outside of a GC cycle, or an invalid object
+	selfcheckHeapStats               // allocation statistics don't match the heap
+	selfcheckDebugBuffer             // buffer of the debug output is invalid
+	selfcheckStack                   // stack pointer outside of the stack, or a dirty redzone
+)
+
+// selfcheckMessage returns a description of the lowest failed invariant in
+// failed, for runtime panics.
+func selfcheckMessage(failed uint32) string {
+	switch {
+	case failed&selfcheckHeapOrder != 0:
+		return "gc: objects not sorted or overlapping"
+	case failed&selfcheckHeapBounds != 0:
+		return "gc: object outside of heap bounds"
+	case failed&selfcheckHeapState != 0:
+		return "gc: invalid object state"
+	case failed&selfcheckHeapStats != 0:
+		return "gc: allocated byte count mismatch"
+	case failed&selfcheckDebugBuffer != 0:
+		return "invalid debug output buffer"
+	default:
+		return "invalid stack"
+	}
+}
@@ -0,0 +1,28 @@
+//go:build tinygo.wasm && tinygo.selfcheck
+
+package runtime
+
+// Check the invariants of the runtime state, for -selfcheck: the heap, the
+// buffer of the debug output and the system stack. The result is a bitmask of
+// the invariants that don't hold (see selfcheckHeapOrder and the following
+// constants), or 0 if the runtime state looks valid.
+//
+// This is a diagnostic for hosts, which can call it between calls to other
+// exported functions to find out whether a misbehaving program corrupted the
+// runtime state. The heap is only checked once the program was initialized.
+//
+//export __runtime_selfcheck
+func selfcheck() uint32 {
+	failed := putcharSelfcheck()
+	if wasmInitialized {
+		failed |= gcSelfcheck()
+	}
+	bottom, top := StackBounds()
+	if sp := getCurrentStackPointer(); sp < bottom || sp > top {
+		failed |= selfcheckStack
+	}
+	if _, dirty := redzoneDirty(); dirty {
+		failed |= selfcheckStack
+	}
+	return failed
+}
@@ -16,6 +16,7 @@ func sliceAppend(srcBuf, elemsBuf unsafe.Pointer, srcLen, srcCap, elemsLen uintp
 
 	if srcLen+elemsLen > srcCap {
 		// Slice does not fit, allocate a new buffer that's large enough.
+		empty := srcCap == 0
 		srcCap = srcCap * 2
 		if srcCap == 0 { // e.g. zero slice
 			srcCap = 1
@@ -27,6 +28,9 @@ func sliceAppend(srcBuf, elemsBuf unsafe.Pointer, srcLen, srcCap, elemsLen uintp
 			// programs).
 			srcCap *= 2
 		}
+		if empty {
+			srcCap = sliceRoundupCap(srcCap, elemSize)
+		}
 		buf := alloc(srcCap*elemSize, nil)
 
 		// Copy the old slice to the new slice.
@@ -80,3 +84,20 @@ func sliceGrow(oldBuf unsafe.Pointer, oldLen, oldCap, newCap, elemSize uintptr)
 
 	return buf, oldLen, oldCap
 }
+
+// sliceRoundupCap increases the capacity of a byte slice that is appended to a
+// slice without capacity, to use all the memory that alloc reserves for it
+// anyway. This is how bytes.Buffer allocates a larger buffer when it needs to
+// grow (append([]byte(nil), make([]byte, c)...)), like the Go runtime rounds
+// up to the closest size class. This avoids some allocations when the buffer
+// is grown again.
+//
+// strings.Builder gets the same rounding from bytealg.MakeNoZero when it grows.
+// Other slices keep a capacity that is a power of two, which doesn't depend on
+// the GC in use.
+func sliceRoundupCap(newCap, elemSize uintptr) uintptr {
+	if elemSize != 1 {
+		return newCap
+	}
+	return roundupAllocSize(newCap)
+}
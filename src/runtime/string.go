@@ -66,6 +66,33 @@ func stringConcat(x, y _string) _string {
 	}
 }
 
+// Add n strings together, with a single allocation. The compiler uses this for
+// chains of concatenations like a + b + c.
+func stringConcatN(parts *_string, n uintptr) _string {
+	strs := unsafe.Slice(parts, n)
+	length := uintptr(0)
+	nonEmpty := 0
+	var last _string
+	for _, s := range strs {
+		if s.length != 0 {
+			length += s.length
+			nonEmpty++
+			last = s
+		}
+	}
+	if nonEmpty <= 1 {
+		// No need to allocate a new string.
+		return last
+	}
+	buf := alloc(length, nil)
+	offset := uintptr(0)
+	for _, s := range strs {
+		memcpy(unsafe.Add(buf, offset), unsafe.Pointer(s.ptr), s.length)
+		offset += s.length
+	}
+	return _string{ptr: (*byte)(buf), length: length}
+}
+
 // Create a string from a []byte slice.
 func stringFromBytes(x struct {
 	ptr *byte
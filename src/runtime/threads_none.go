@@ -0,0 +1,34 @@
+//go:build !(tinygo.wasm && gc.extalloc && tinygo.threads)
+
+package runtime
+
+// Without threads (see threads_wasm.go), only one worker runs the program and
+// there is nothing to lock or stop.
+
+const hasThreads = false
+
+var noGCThreadDepth uint32
+
+// threadNoGCDepth returns the nesting depth of EnterNoGC calls of the current
+// worker.
+func threadNoGCDepth() *uint32 {
+	return &noGCThreadDepth
+}
+
+//go:inline
+func threadEnter() {}
+
+//go:inline
+func threadExit() {}
+
+//go:inline
+func gcLock() {}
+
+//go:inline
+func gcUnlock() {}
+
+//go:inline
+func threadsStop() {}
+
+//go:inline
+func markThreads() {}
@@ -0,0 +1,242 @@
+//go:build tinygo.wasm && gc.extalloc && tinygo.threads
+
+package runtime
+
+// Threads for the wasm-threads target, to experiment with the WebAssembly
+// threads proposal in tooling (not in consensus code). The host runs the
+// program in several workers: instances of the module that share the same
+// memory, each with its own system stack. The program doesn't start threads
+// itself, it only makes sure that workers running at the same time don't
+// corrupt the runtime state:
+//
+//   - The data structures of the extalloc GC are protected by a lock. A worker
+//     that finds the lock taken waits for it with memory.atomic.wait32.
+//   - A collection cycle stops the world: the worker running it waits until
+//     all other workers that are running Go code are waiting for the lock,
+//     and then scans their stacks too. Workers only stop while waiting for
+//     the lock, so a worker in a loop that doesn't allocate delays the
+//     collection cycle until it allocates again.
+//
+// The first worker uses the stack reserved by the linker, and must initialize
+// the program (with _initialize or its first exported call) before other
+// workers call into it. Other workers must call tinygo_thread_attach with the
+// bounds of their stack before they call any other exported function, and
+// tinygo_thread_detach when they're done.
+
+import (
+	"runtime/volatile"
+	"sync/atomic"
+	"unsafe"
+)
+
+const hasThreads = true
+
+// Maximum number of workers, including the first.
+const threadsMax = 64
+
+// threadState is the state of a single worker. The depths are only changed
+// with the GC lock held.
+type threadState struct {
+	bottom  uintptr // bounds of the system stack of the worker
+	top     uintptr
+	waiting uintptr // stack pointer while waiting for the GC lock, or 0
+	depth   uint32  // number of exported functions running in the worker
+	noGC    uint32  // nesting depth of EnterNoGC calls in the worker
+}
+
+var (
+	threads    [threadsMax]threadState     // the first worker is at index 0
+	threadsLen uint32                  = 1 // number of used entries in threads
+
+	// Incremented every time a worker starts waiting for the GC lock, so that
+	// a collection cycle can wait for workers to stop.
+	threadsWaiting uint32
+
+	gcLockState uint32 // 0: unlocked, 1: locked, 2: locked and workers may be waiting
+	gcLockOwner uint32 // index+1 of the worker that holds the lock, or 0
+	gcLockDepth uint32 // number of times the owner took the lock
+)
+
+//export llvm.wasm.memory.atomic.wait32
+func wasmMemoryAtomicWait32(ptr *uint32, expected uint32, timeout int64) int32
+
+//export llvm.wasm.memory.atomic.notify
+func wasmMemoryAtomicNotify(ptr *uint32, count uint32) uint32
+
+// Register a worker with its system stack, which ranges from bottom to top. It
+// returns 0 if there are too many workers.
+//
+//export tinygo_thread_attach
+func threadAttach(bottom, top uint32) uint32 {
+	gcLock()
+	for i := uint32(1); i < threadsMax; i++ {
+		if threads[i].top != 0 {
+			continue
+		}
+		threads[i] = threadState{bottom: uintptr(bottom), top: uintptr(top)}
+		if i >= threadsLen {
+			atomic.StoreUint32(&threadsLen, i+1)
+		}
+		gcUnlock()
+		return 1
+	}
+	gcUnlock()
+	return 0
+}
+
+// Remove the current worker, registered with tinygo_thread_attach.
+//
+//export tinygo_thread_detach
+func threadDetach() {
+	gcLock()
+	if i := threadIndex(); i > 0 {
+		// No-GC sections that the worker didn't end, end with it.
+		noGCDepth -= threads[i].noGC
+		threads[i] = threadState{}
+	}
+	gcUnlock()
+}
+
+// threadIndex returns the index of the current worker, found by the stack
+// pointer. It returns -1 if the worker didn't call tinygo_thread_attach.
+func threadIndex() int {
+	sp := getCurrentStackPointer()
+	if bottom, top := StackBounds(); sp > bottom && sp <= top {
+		return 0
+	}
+	n := atomic.LoadUint32(&threadsLen)
+	for i := uint32(1); i < n; i++ {
+		if sp > threads[i].bottom && sp <= threads[i].top {
+			return int(i)
+		}
+	}
+	return -1
+}
+
+// threadNoGCDepth returns the nesting depth of EnterNoGC calls of the current
+// worker. The GC lock must be held.
+func threadNoGCDepth() *uint32 {
+	i := threadIndex()
+	if i < 0 {
+		runtimePanic("no-GC section in a worker without tinygo_thread_attach")
+	}
+	return &threads[i].noGC
+}
+
+// threadEnter is called instead of the rest of wasmExportEnter: most of the
+// state it keeps is per worker.
+func threadEnter() {
+	i := threadIndex()
+	if i < 0 {
+		runtimePanic("exported function called by a worker without tinygo_thread_attach")
+	}
+	if !wasmInitialized {
+		if i != 0 {
+			runtimePanic("exported function called by a worker before the program was initialized")
+		}
+		wasmInitialize()
+	}
+	gcLock()
+	threads[i].depth++
+	gcUnlock()
+}
+
+// threadExit is called instead of the rest of wasmExportExit.
+func threadExit() {
+	gcLock()
+	threads[threadIndex()].depth--
+	gcUnlock()
+}
+
+// gcLock takes the lock that protects the GC data structures. The worker that
+// holds it may take it again, for example when a memory pressure handler
+// allocates.
+func gcLock() {
+	self := uint32(threadIndex() + 1)
+	if self != 0 && atomic.LoadUint32(&gcLockOwner) == self {
+		gcLockDepth++
+		return
+	}
+	if !atomic.CompareAndSwapUint32(&gcLockState, 0, 1) {
+		// Another worker holds the lock, and may be running a collection
+		// cycle that waits for this worker to stop. Tell it where the stack
+		// of this worker is in use.
+		if self != 0 {
+			atomic.StoreUintptr(&threads[self-1].waiting, getCurrentStackPointer())
+		}
+		atomic.AddUint32(&threadsWaiting, 1)
+		wasmMemoryAtomicNotify(&threadsWaiting, ^uint32(0))
+		for atomic.SwapUint32(&gcLockState, 2) != 0 {
+			wasmMemoryAtomicWait32(&gcLockState, 2, -1)
+		}
+		if self != 0 {
+			atomic.StoreUintptr(&threads[self-1].waiting, 0)
+		}
+	}
+	atomic.StoreUint32(&gcLockOwner, self)
+	gcLockDepth = 1
+}
+
+// gcUnlock releases the lock taken with gcLock.
+func gcUnlock() {
+	gcLockDepth--
+	if gcLockDepth != 0 {
+		return
+	}
+	atomic.StoreUint32(&gcLockOwner, 0)
+	if atomic.SwapUint32(&gcLockState, 0) == 2 {
+		wasmMemoryAtomicNotify(&gcLockState, 1)
+	}
+}
+
+// threadsStop waits until all other workers that are running Go code wait for
+// the GC lock, which must be held by the current worker.
+func threadsStop() {
+	self := threadIndex()
+	for {
+		waiting := atomic.LoadUint32(&threadsWaiting)
+		if threadsStopped(self) {
+			return
+		}
+		wasmMemoryAtomicWait32(&threadsWaiting, waiting, -1)
+	}
+}
+
+// threadsStopped returns whether all workers other than self are stopped.
+func threadsStopped(self int) bool {
+	for i := 0; i < int(threadsLen); i++ {
+		if i != self && threads[i].depth != 0 && atomic.LoadUintptr(&threads[i].waiting) == 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// markThreads marks all root pointers on the stacks of the workers, instead of
+// markStack. All other workers must be stopped, see threadsStop.
+//
+// The stack chain that the compiler maintains in stackChainStart is shared by
+// all workers, so it's meaningless here. That's fine: the stack objects in it
+// are on the system stacks, which are scanned as a whole.
+func markThreads() {
+	// Force LLVM to consider stackChainStart live, see markStack.
+	volatile.LoadUint32((*uint32)(unsafe.Pointer(&stackChainStart)))
+
+	self := threadIndex()
+	for i := 0; i < int(threadsLen); i++ {
+		t := &threads[i]
+		sp := t.waiting
+		if i == self {
+			sp = getCurrentStackPointer()
+		} else if t.depth == 0 {
+			continue // not running Go code
+		}
+		top := t.top
+		if i == 0 {
+			_, top = StackBounds()
+		}
+		if sp < top {
+			markRoots(sp, top)
+		}
+	}
+}
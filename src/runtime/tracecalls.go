@@ -0,0 +1,84 @@
+//go:build tinygo.tracecalls
+
+package runtime
+
+// Function entry/exit tracing for -trace-calls. The compiler inserts calls to
+// traceEnter and traceExit in every function (except those in the runtime)
+// which record an event in a ring buffer. The host can drain this buffer
+// through the tinygo_trace_drain export, for example to build a flamegraph.
+//
+// Function IDs are indices into the tinygo.trace_names custom section that is
+// added to the WebAssembly file.
+
+import "unsafe"
+
+// A single trace event, as stored in linear memory.
+type traceEvent struct {
+	id    uint32 // function ID, with the highest bit set for function exit
+	_     uint32
+	ticks int64
+}
+
+const (
+	traceBufferSize = 4096 // must be a power of two
+	traceExitFlag   = 1 << 31
+)
+
+var (
+	traceBuffer  [traceBufferSize]traceEvent // ring buffer
+	traceDrained [traceBufferSize]traceEvent // events in order, see tinygo_trace_drain
+	traceHead    uint32                      // next event to be drained
+	traceTail    uint32                      // next event to be written
+	traceDropped uint32                      // events overwritten before being drained
+)
+
+func traceEnter(id uint32) {
+	traceRecord(id)
+}
+
+func traceExit(id uint32) {
+	traceRecord(id | traceExitFlag)
+}
+
+func traceRecord(id uint32) {
+	if traceTail-traceHead == traceBufferSize {
+		// Buffer is full: overwrite the oldest event.
+		traceHead++
+		traceDropped++
+	}
+	traceBuffer[traceTail%traceBufferSize] = traceEvent{id: id, ticks: int64(ticks())}
+	traceTail++
+}
+
+// Copy all pending events to the drain buffer, oldest first, and return the
+// number of events copied. The address of the drain buffer can be obtained
+// with tinygo_trace_buffer. Each event is 16 bytes: a little endian uint32
+// function ID (with the highest bit set for function exit), 4 bytes of
+// padding and an int64 timestamp in ticks.
+//
+//export tinygo_trace_drain
+func traceDrain() uint32 {
+	n := traceTail - traceHead
+	for i := uint32(0); i < n; i++ {
+		traceDrained[i] = traceBuffer[(traceHead+i)%traceBufferSize]
+	}
+	traceHead = traceTail
+	return n
+}
+
+// Return the address of the buffer filled by tinygo_trace_drain.
+//
+//export tinygo_trace_buffer
+func traceDrainBuffer() unsafe.Pointer {
+	return unsafe.Pointer(&traceDrained[0])
+}
+
+// Return the number of events that were lost because the ring buffer was full,
+// and reset the counter.
+//
+//export tinygo_trace_dropped
+func traceDroppedEvents() uint32 {
+	n := traceDropped
+	traceDropped = 0
+	return n
+}
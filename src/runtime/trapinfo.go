@@ -0,0 +1,16 @@
+package runtime
+
+// Reasons for a trap, recorded with recordTrap before the program traps so that
+// WebAssembly hosts can tell different failures apart. Hosts only see an
+// "unreachable" trap otherwise. Keep these in sync with wasmhost/trapinfo.go.
+const (
+	trapUnreachable   = 0 // no reason recorded: unreachable code or a trap outside the runtime
+	trapPanic         = 1 // explicit call to panic
+	trapRuntimeError  = 2 // runtime error without a more specific reason below
+	trapNilPointer    = 3 // nil pointer dereference
+	trapOutOfRange    = 4 // index or slice expression out of range
+	trapOutOfMemory   = 5 // allocation failed, aux is the requested size
+	trapStackOverflow = 6 // goroutine stack overflow, or system stack overflow into the -redzone (aux is the address)
+	trapCallBudget    = 7 // over the budget set with SetCallBudget, aux is the requested size
+	trapReentry       = 8 // exported function called while another one is running
+)
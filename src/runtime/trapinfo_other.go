@@ -0,0 +1,7 @@
+//go:build !tinygo.wasm
+
+package runtime
+
+// Only WebAssembly hosts can read the reason of a trap.
+func recordTrap(reason uint32, aux uintptr, msg string) {
+}
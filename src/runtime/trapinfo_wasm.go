@@ -0,0 +1,44 @@
+//go:build tinygo.wasm
+
+package runtime
+
+import "unsafe"
+
+// trapRecord describes why the program trapped, as stored in linear memory.
+// The host finds it through the tinygo_trap_info export.
+type trapRecord struct {
+	reason     uint32 // one of the trap* constants
+	aux        uint32 // extra data, depending on the reason
+	message    uint32 // pointer to the panic or runtime error message
+	messageLen uint32
+}
+
+var trapInfo trapRecord
+
+// recordTrap records the reason of a trap that is about to happen. Only the
+// first reason is kept, in case the runtime panics again while printing the
+// panic message. With -crash-dump, it also captures a crash dump.
+//
+// The trap ends all running exported functions, see wasmExportAbort.
+func recordTrap(reason uint32, aux uintptr, msg string) {
+	wasmExportAbort()
+	if trapInfo.reason != trapUnreachable {
+		return
+	}
+	trapInfo = trapRecord{
+		reason:     reason,
+		aux:        uint32(aux),
+		message:    uint32(uintptr(unsafe.Pointer((*_string)(unsafe.Pointer(&msg)).ptr))),
+		messageLen: uint32(len(msg)),
+	}
+	captureCrashDump()
+}
+
+// Return the address of the trap information: four little endian uint32 values
+// with the reason, the extra data, and the pointer and length of the message.
+// All values are zero if the program didn't trap through the runtime.
+//
+//export tinygo_trap_info
+func exportTrapInfo() uint32 {
+	return uint32(uintptr(unsafe.Pointer(&trapInfo)))
+}
@@ -0,0 +1,30 @@
+//go:build tinygo.wasm
+
+package runtime
+
+// Cooperative yield points, inserted by the compiler at loop back-edges when
+// building with -yield-points=N. They give the host a chance to interrupt
+// long running code, for example to enforce a deadline, without needing fuel
+// metering.
+
+// Number of yield points to pass before calling the host. Set by the compiler.
+var yieldInterval uint32 = 1
+
+var yieldCounter uint32
+
+// Called by the host (if it supports yield points) every yieldInterval loop
+// iterations. The host may trap to abort execution.
+//
+//go:wasmimport env ext_yield
+func hostYield()
+
+// yieldPoint is called by compiler-inserted code at every loop back-edge.
+//
+//go:inline
+func yieldPoint() {
+	yieldCounter++
+	if yieldCounter >= yieldInterval {
+		yieldCounter = 0
+		hostYield()
+	}
+}
@@ -0,0 +1,114 @@
+//go:build stylus
+
+// Package stylus provides access to the host of Arbitrum Stylus contracts. It
+// is only available on the stylus target.
+//
+// The contract is the main function of the program, which is called for every
+// call to the contract:
+//
+//	func main() {
+//		args := stylus.Args()
+//		...
+//		stylus.Return(result)
+//	}
+package stylus
+
+import "unsafe"
+
+// Args returns the call data of the current call.
+func Args() []byte
+
+func setResult(data []byte, revert bool)
+
+// Return sets the data that is returned to the caller. The contract should
+// return from main afterwards.
+func Return(data []byte) {
+	setResult(data, false)
+}
+
+// Revert reverts the current call with the given data. The contract should
+// return from main afterwards.
+func Revert(data []byte) {
+	setResult(data, true)
+}
+
+//go:wasmimport vm_hooks storage_load_bytes32
+func storageLoadBytes32(key, dest unsafe.Pointer)
+
+//go:wasmimport vm_hooks storage_cache_bytes32
+func storageCacheBytes32(key, value unsafe.Pointer)
+
+//go:wasmimport vm_hooks storage_flush_cache
+func storageFlushCache(clearCache uint32)
+
+//go:wasmimport vm_hooks msg_sender
+func msgSender(dest unsafe.Pointer)
+
+//go:wasmimport vm_hooks msg_value
+func msgValue(dest unsafe.Pointer)
+
+//go:wasmimport vm_hooks block_number
+func blockNumber() uint64
+
+//go:wasmimport vm_hooks native_keccak256
+func nativeKeccak256(data unsafe.Pointer, length uint32, output unsafe.Pointer)
+
+//go:wasmimport vm_hooks emit_log
+func emitLog(data unsafe.Pointer, length uint32, topics uint32)
+
+// LoadStorage returns the value of the given storage slot.
+func LoadStorage(key [32]byte) (value [32]byte) {
+	storageLoadBytes32(unsafe.Pointer(&key), unsafe.Pointer(&value))
+	return
+}
+
+// StoreStorage sets the value of the given storage slot. The value is written
+// to storage by Flush.
+func StoreStorage(key, value [32]byte) {
+	storageCacheBytes32(unsafe.Pointer(&key), unsafe.Pointer(&value))
+}
+
+// Flush writes all values stored with StoreStorage to storage.
+func Flush() {
+	storageFlushCache(0)
+}
+
+// Sender returns the address of the caller.
+func Sender() (addr [20]byte) {
+	msgSender(unsafe.Pointer(&addr))
+	return
+}
+
+// Value returns the amount of ETH sent with the call, as a big-endian number.
+func Value() (value [32]byte) {
+	msgValue(unsafe.Pointer(&value))
+	return
+}
+
+// BlockNumber returns the number of the current block.
+func BlockNumber() uint64 {
+	return blockNumber()
+}
+
+// Keccak256 returns the Keccak-256 hash of data, computed by the host.
+func Keccak256(data []byte) (hash [32]byte) {
+	nativeKeccak256(dataPointer(data), uint32(len(data)), unsafe.Pointer(&hash))
+	return
+}
+
+// EmitLog emits an EVM log with the given topics and data.
+func EmitLog(topics [][32]byte, data []byte) {
+	buf := make([]byte, 0, len(topics)*32+len(data))
+	for _, topic := range topics {
+		buf = append(buf, topic[:]...)
+	}
+	buf = append(buf, data...)
+	emitLog(dataPointer(buf), uint32(len(buf)), uint32(len(topics)))
+}
+
+func dataPointer(data []byte) unsafe.Pointer {
+	if len(data) == 0 {
+		return nil
+	}
+	return unsafe.Pointer(&data[0])
+}
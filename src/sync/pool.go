@@ -1,15 +1,21 @@
 package sync
 
-// Pool is a very simple implementation of sync.Pool.
+// Pool is a very simple implementation of sync.Pool. With the extalloc GC,
+// items are kept until the GC signals memory pressure (see
+// runtime.RegisterMemoryPressureHandler): half of them are dropped at a
+// moderate level, and all of them at a critical level. The other GCs never
+// signal memory pressure, so there the items are kept as long as the pool.
 type Pool struct {
 	New   func() interface{}
 	items []interface{}
+	added bool // the pool is in allPools (only with the extalloc GC)
 }
 
 // Get returns an item in the pool, or the value of calling Pool.New() if there are no items.
 func (p *Pool) Get() interface{} {
 	if len(p.items) > 0 {
 		x := p.items[len(p.items)-1]
+		p.items[len(p.items)-1] = nil // let the GC free it if it's dropped later
 		p.items = p.items[:len(p.items)-1]
 		return x
 	}
@@ -21,5 +27,9 @@ func (p *Pool) Get() interface{} {
 
 // Put adds a value back into the pool.
 func (p *Pool) Put(x interface{}) {
+	if x == nil {
+		return
+	}
+	p.register()
 	p.items = append(p.items, x)
 }
@@ -0,0 +1,7 @@
+//go:build !gc.extalloc
+
+package sync
+
+// register does nothing: the GC never signals memory pressure, so the pool
+// doesn't need to be tracked (which would keep it alive forever).
+func (p *Pool) register() {}
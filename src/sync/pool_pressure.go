@@ -0,0 +1,51 @@
+//go:build gc.extalloc
+
+package sync
+
+import _ "unsafe"
+
+// allPools contains all pools that had items put into them. The pools are kept
+// alive to be able to drop their items under memory pressure, so this is only
+// done with a GC that signals memory pressure.
+var allPools []*Pool
+
+// Memory pressure level at which all items are dropped. This is the same as
+// runtime.MemoryPressureCritical.
+const memoryPressureCritical = 2
+
+//go:linkname registerMemoryPressureHandler runtime.RegisterMemoryPressureHandler
+func registerMemoryPressureHandler(handler func(level int))
+
+func init() {
+	registerMemoryPressureHandler(poolCleanup)
+}
+
+// register adds the pool to allPools, if it isn't already.
+func (p *Pool) register() {
+	if !p.added {
+		p.added = true
+		allPools = append(allPools, p)
+	}
+}
+
+// poolCleanup drops items from all pools when memory is running low. The
+// oldest items are dropped first, as recently used items are the most likely
+// to be used again.
+func poolCleanup(level int) {
+	for _, p := range allPools {
+		keep := 0
+		if level < memoryPressureCritical {
+			keep = len(p.items) / 2
+		}
+		drop := len(p.items) - keep
+		copy(p.items, p.items[drop:])
+		for i := keep; i < len(p.items); i++ {
+			p.items[i] = nil
+		}
+		p.items = p.items[:keep]
+		if keep == 0 {
+			// Also free the backing array.
+			p.items = nil
+		}
+	}
+}
@@ -1,10 +1,27 @@
-//go:build baremetal || (wasm && !wasip1) || wasm_unknown
+//go:build (baremetal || (wasm && !wasip1) || wasm_unknown) && !memfs && !storagefs
 
 // This file emulates some file-related functions that are only available
-// under a real operating system.
+// under a real operating system. Build with the memfs tag to use an in-memory
+// filesystem instead, see file_memfs.go.
 
 package syscall
 
 func Getwd() (string, error) {
 	return "", nil
 }
+
+func Open(path string, mode int, perm uint32) (fd int, err error) {
+	return 0, ENOSYS
+}
+
+func Read(fd int, p []byte) (n int, err error) {
+	return 0, ENOSYS
+}
+
+func Seek(fd int, offset int64, whence int) (off int64, err error) {
+	return 0, ENOSYS
+}
+
+func Close(fd int) (err error) {
+	return ENOSYS
+}
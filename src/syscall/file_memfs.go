@@ -0,0 +1,356 @@
+//go:build (baremetal || (wasm && !wasip1) || wasm_unknown) && (memfs || storagefs)
+
+// This file implements a small in-memory filesystem for systems without a real
+// one, enabled with the memfs build tag. It is meant for tests and libraries
+// that incidentally use files, everything is lost when the program exits.
+// File descriptors 0, 1 and 2 are left to stdin, stdout and stderr.
+//
+// Directories can be backed by something else than memory, like the host
+// storage with the storagefs build tag (see file_storagefs.go).
+
+package syscall
+
+const (
+	S_IFMT  = 0170000
+	S_IFDIR = 0040000
+	S_IFREG = 0100000
+)
+
+// Stat_t only contains the fields that the in-memory filesystem keeps track
+// of.
+type Stat_t struct {
+	Mode uint32
+	Size int64
+}
+
+// memInode is a single file or directory.
+type memInode struct {
+	mode  uint32 // file type and permission bits
+	data  []byte
+	mount memMount // if set, this is a copy of a file in the mount
+	name  string   // name of the file in the mount
+	dirty bool     // whether the file must be stored in the mount
+}
+
+// memMount is a directory of which the files are kept outside of the in-memory
+// filesystem. Files are loaded when they're opened, and stored again when
+// they're closed or synced after being modified.
+type memMount interface {
+	load(name string) (data []byte, ok bool)
+	store(name string, data []byte)
+	remove(name string) bool
+}
+
+// memFile is an open file.
+type memFile struct {
+	inode  *memInode
+	offset int64
+	mode   int // flags passed to Open
+}
+
+var (
+	memInodes = map[string]*memInode{
+		"/":    {mode: S_IFDIR | 0o755},
+		"/tmp": {mode: S_IFDIR | 0o777},
+	}
+	memMounts = map[string]memMount{} // by path of the mount point
+	memFiles  = map[int]*memFile{}
+	memNextFd = 3
+	memWd     = "/"
+)
+
+// memPath returns the cleaned absolute path for the given path, relative to
+// the working directory.
+func memPath(path string) string {
+	if len(path) == 0 || path[0] != '/' {
+		path = memWd + "/" + path
+	}
+	var parts []string
+	start := 0
+	for i := 0; i <= len(path); i++ {
+		if i < len(path) && path[i] != '/' {
+			continue
+		}
+		switch part := path[start:i]; part {
+		case "", ".":
+		case "..":
+			if len(parts) != 0 {
+				parts = parts[:len(parts)-1]
+			}
+		default:
+			parts = append(parts, part)
+		}
+		start = i + 1
+	}
+	if len(parts) == 0 {
+		return "/"
+	}
+	cleaned := ""
+	for _, part := range parts {
+		cleaned += "/" + part
+	}
+	return cleaned
+}
+
+// memFindMount returns the mount that contains the given cleaned path and the
+// name of the file in the mount, or nil if the path isn't in a mount.
+func memFindMount(path string) (memMount, string) {
+	for prefix, mount := range memMounts {
+		if len(path) > len(prefix)+1 && path[:len(prefix)] == prefix && path[len(prefix)] == '/' {
+			return mount, path[len(prefix)+1:]
+		}
+	}
+	return nil, ""
+}
+
+// memLookup returns the inode at the given cleaned path, or nil if it doesn't
+// exist.
+func memLookup(path string) *memInode {
+	if inode := memInodes[path]; inode != nil {
+		return inode
+	}
+	if mount, name := memFindMount(path); mount != nil {
+		if data, ok := mount.load(name); ok {
+			return &memInode{mode: S_IFREG | 0o666, data: data, mount: mount, name: name}
+		}
+	}
+	return nil
+}
+
+// memSync stores a modified file in its mount.
+func memSync(inode *memInode) {
+	if inode.mount != nil && inode.dirty {
+		inode.mount.store(inode.name, inode.data)
+		inode.dirty = false
+	}
+}
+
+// memCreate creates a new inode at the given cleaned path, which must not
+// exist yet.
+func memCreate(path string, mode uint32) (*memInode, error) {
+	if mount, name := memFindMount(path); mount != nil && mode&S_IFMT == S_IFREG {
+		return &memInode{mode: mode, mount: mount, name: name, dirty: true}, nil
+	}
+	parentPath := "/"
+	for i := len(path) - 1; i > 0; i-- {
+		if path[i] == '/' {
+			parentPath = path[:i]
+			break
+		}
+	}
+	parent := memInodes[parentPath]
+	if parent == nil {
+		return nil, ENOENT
+	}
+	if parent.mode&S_IFMT != S_IFDIR {
+		return nil, ENOTDIR
+	}
+	inode := &memInode{mode: mode}
+	memInodes[path] = inode
+	return inode, nil
+}
+
+func Getwd() (string, error) {
+	return memWd, nil
+}
+
+func Chdir(path string) (err error) {
+	path = memPath(path)
+	inode := memLookup(path)
+	if inode == nil {
+		return ENOENT
+	}
+	if inode.mode&S_IFMT != S_IFDIR {
+		return ENOTDIR
+	}
+	memWd = path
+	return nil
+}
+
+func Open(path string, mode int, perm uint32) (fd int, err error) {
+	path = memPath(path)
+	inode := memLookup(path)
+	writable := mode&(O_WRONLY|O_RDWR) != 0
+	switch {
+	case inode == nil && mode&O_CREAT == 0:
+		return -1, ENOENT
+	case inode == nil:
+		inode, err = memCreate(path, S_IFREG|perm&0o777)
+		if err != nil {
+			return -1, err
+		}
+	case mode&(O_CREAT|O_EXCL) == O_CREAT|O_EXCL:
+		return -1, EEXIST
+	case inode.mode&S_IFMT == S_IFDIR && writable:
+		return -1, EISDIR
+	}
+	if mode&O_TRUNC != 0 && writable {
+		inode.data = nil
+		inode.dirty = true
+	}
+	fd = memNextFd
+	memNextFd++
+	memFiles[fd] = &memFile{inode: inode, mode: mode}
+	return fd, nil
+}
+
+func Close(fd int) (err error) {
+	f := memFiles[fd]
+	if f == nil {
+		return EBADF
+	}
+	memSync(f.inode)
+	delete(memFiles, fd)
+	return nil
+}
+
+func Fsync(fd int) (err error) {
+	f := memFiles[fd]
+	if f == nil {
+		return EBADF
+	}
+	memSync(f.inode)
+	return nil
+}
+
+func Read(fd int, p []byte) (n int, err error) {
+	f := memFiles[fd]
+	if f == nil {
+		return 0, EBADF
+	}
+	n, err = Pread(fd, p, f.offset)
+	f.offset += int64(n)
+	return n, err
+}
+
+func Pread(fd int, p []byte, offset int64) (n int, err error) {
+	f := memFiles[fd]
+	if f == nil || f.mode&O_WRONLY != 0 {
+		return 0, EBADF
+	}
+	if f.inode.mode&S_IFMT == S_IFDIR {
+		return 0, EISDIR
+	}
+	if offset >= int64(len(f.inode.data)) {
+		return 0, nil
+	}
+	return copy(p, f.inode.data[offset:]), nil
+}
+
+func Write(fd int, p []byte) (n int, err error) {
+	f := memFiles[fd]
+	if f == nil {
+		return 0, EBADF
+	}
+	if f.mode&O_APPEND != 0 {
+		f.offset = int64(len(f.inode.data))
+	}
+	n, err = Pwrite(fd, p, f.offset)
+	f.offset += int64(n)
+	return n, err
+}
+
+func Pwrite(fd int, p []byte, offset int64) (n int, err error) {
+	f := memFiles[fd]
+	if f == nil || f.mode&(O_WRONLY|O_RDWR) == 0 {
+		return 0, EBADF
+	}
+	end := offset + int64(len(p))
+	if end > int64(len(f.inode.data)) {
+		data := make([]byte, end)
+		copy(data, f.inode.data)
+		f.inode.data = data
+	}
+	f.inode.dirty = true
+	return copy(f.inode.data[offset:], p), nil
+}
+
+func Seek(fd int, offset int64, whence int) (off int64, err error) {
+	f := memFiles[fd]
+	if f == nil {
+		return 0, EBADF
+	}
+	switch whence {
+	case 0: // io.SeekStart
+	case 1: // io.SeekCurrent
+		offset += f.offset
+	case 2: // io.SeekEnd
+		offset += int64(len(f.inode.data))
+	default:
+		return 0, EINVAL
+	}
+	if offset < 0 {
+		return 0, EINVAL
+	}
+	f.offset = offset
+	return offset, nil
+}
+
+func Stat(path string, p *Stat_t) (err error) {
+	inode := memLookup(memPath(path))
+	if inode == nil {
+		return ENOENT
+	}
+	*p = Stat_t{Mode: inode.mode, Size: int64(len(inode.data))}
+	return nil
+}
+
+func Lstat(path string, p *Stat_t) (err error) {
+	// There are no symbolic links.
+	return Stat(path, p)
+}
+
+func Fstat(fd int, p *Stat_t) (err error) {
+	f := memFiles[fd]
+	if f == nil {
+		return EBADF
+	}
+	*p = Stat_t{Mode: f.inode.mode, Size: int64(len(f.inode.data))}
+	return nil
+}
+
+func Mkdir(path string, mode uint32) (err error) {
+	path = memPath(path)
+	if memLookup(path) != nil {
+		return EEXIST
+	}
+	_, err = memCreate(path, S_IFDIR|mode&0o777)
+	return err
+}
+
+func Unlink(path string) (err error) {
+	path = memPath(path)
+	inode := memInodes[path]
+	if inode == nil {
+		if mount, name := memFindMount(path); mount != nil && mount.remove(name) {
+			return nil
+		}
+		return ENOENT
+	}
+	if inode.mode&S_IFMT == S_IFDIR {
+		return EISDIR
+	}
+	delete(memInodes, path)
+	return nil
+}
+
+func Rmdir(path string) (err error) {
+	path = memPath(path)
+	inode := memInodes[path]
+	if inode == nil {
+		return ENOENT
+	}
+	if inode.mode&S_IFMT != S_IFDIR {
+		return ENOTDIR
+	}
+	if path == "/" {
+		return EBUSY
+	}
+	for other := range memInodes {
+		if len(other) > len(path) && other[:len(path)] == path && other[len(path)] == '/' {
+			return ENOTEMPTY
+		}
+	}
+	delete(memInodes, path)
+	return nil
+}
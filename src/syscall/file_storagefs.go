@@ -0,0 +1,132 @@
+//go:build wasm_unknown && storagefs
+
+// With the storagefs build tag, the files in /storage are kept in the child
+// storage of a Substrate host, through the ext_default_child_storage_* host
+// functions. The first path element below /storage is the child storage key
+// and the rest of the path is the key, so /storage/foo/bar/baz is the key
+// "bar/baz" in the child storage "foo". All other paths are in memory, see
+// file_memfs.go.
+//
+// Files are read from storage when they're opened and written back when
+// they're closed or synced. This is meant to make it easier to experiment with
+// existing Go code in a runtime, not for production use.
+
+package syscall
+
+import "unsafe"
+
+//go:wasmimport env ext_default_child_storage_get_version_1
+func childStorageGet(storageKey, key uint64) uint64
+
+//go:wasmimport env ext_default_child_storage_set_version_1
+func childStorageSet(storageKey, key, value uint64)
+
+//go:wasmimport env ext_default_child_storage_clear_version_1
+func childStorageClear(storageKey, key uint64)
+
+//go:wasmimport env ext_allocator_free_version_1
+func storageFree(ptr uint32)
+
+func init() {
+	memInodes["/storage"] = &memInode{mode: S_IFDIR | 0o777}
+	memMounts["/storage"] = hostStorage{}
+}
+
+// hostStorage implements memMount for the child storage of the host.
+type hostStorage struct{}
+
+func (hostStorage) load(name string) ([]byte, bool) {
+	storageKey, key, ok := splitStorageName(name)
+	if !ok {
+		return nil, false
+	}
+	return storageGet(storageKey, key)
+}
+
+func (hostStorage) store(name string, data []byte) {
+	storageKey, key, ok := splitStorageName(name)
+	if !ok {
+		return
+	}
+	childStorageSet(pointerSize(storageKey), pointerSize(key), pointerSize(data))
+}
+
+func (hostStorage) remove(name string) bool {
+	storageKey, key, ok := splitStorageName(name)
+	if !ok {
+		return false
+	}
+	if _, ok := storageGet(storageKey, key); !ok {
+		return false
+	}
+	childStorageClear(pointerSize(storageKey), pointerSize(key))
+	return true
+}
+
+// splitStorageName splits a file name in the child storage key and the key.
+func splitStorageName(name string) (storageKey, key []byte, ok bool) {
+	for i := 0; i < len(name); i++ {
+		if name[i] == '/' {
+			if i == 0 || i == len(name)-1 {
+				return nil, nil, false
+			}
+			return []byte(name[:i]), []byte(name[i+1:]), true
+		}
+	}
+	return nil, nil, false
+}
+
+// storageGet reads a value from the child storage. The host returns it as a
+// SCALE encoded Option<Vec<u8>>, in memory that must be freed afterwards.
+func storageGet(storageKey, key []byte) ([]byte, bool) {
+	result := childStorageGet(pointerSize(storageKey), pointerSize(key))
+	if uint32(result) == 0 {
+		return nil, false
+	}
+	defer storageFree(uint32(result))
+	encoded := unsafe.Slice((*byte)(unsafe.Pointer(uintptr(uint32(result)))), uintptr(result>>32))
+	if len(encoded) == 0 || encoded[0] == 0 {
+		return nil, false // None
+	}
+	length, size := decodeCompact(encoded[1:])
+	if size == 0 || len(encoded)-1-size < length {
+		return nil, false // invalid encoding
+	}
+	data := make([]byte, length)
+	copy(data, encoded[1+size:])
+	return data, true
+}
+
+// decodeCompact decodes a SCALE compact integer. It returns the value and the
+// number of bytes it takes, or a size of 0 if it can't be decoded.
+func decodeCompact(b []byte) (value, size int) {
+	if len(b) == 0 {
+		return 0, 0
+	}
+	switch b[0] & 3 {
+	case 0:
+		return int(b[0] >> 2), 1
+	case 1:
+		if len(b) < 2 {
+			return 0, 0
+		}
+		return int(uint16(b[0])|uint16(b[1])<<8) >> 2, 2
+	case 2:
+		if len(b) < 4 {
+			return 0, 0
+		}
+		return int((uint32(b[0]) | uint32(b[1])<<8 | uint32(b[2])<<16 | uint32(b[3])<<24) >> 2), 4
+	default:
+		// Values of 2^30 and larger don't fit in memory anyway.
+		return 0, 0
+	}
+}
+
+// pointerSize returns the Polkadot pointer-size of the slice: the pointer in
+// the low 32 bits and the length in the high 32 bits.
+func pointerSize(b []byte) uint64 {
+	if len(b) == 0 {
+		return 0
+	}
+	return uint64(uintptr(unsafe.Pointer(&b[0]))) | uint64(len(b))<<32
+}
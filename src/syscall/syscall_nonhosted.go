@@ -129,22 +129,6 @@ func Environ() []string {
 	return envCopy
 }
 
-func Open(path string, mode int, perm uint32) (fd int, err error) {
-	return 0, ENOSYS
-}
-
-func Read(fd int, p []byte) (n int, err error) {
-	return 0, ENOSYS
-}
-
-func Seek(fd int, offset int64, whence int) (off int64, err error) {
-	return 0, ENOSYS
-}
-
-func Close(fd int) (err error) {
-	return ENOSYS
-}
-
 // Processes
 
 type WaitStatus uint32
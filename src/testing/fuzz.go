@@ -1,8 +1,8 @@
 package testing
 
 import (
-	"errors"
 	"fmt"
+	"os"
 	"reflect"
 	"time"
 )
@@ -100,11 +100,11 @@ var supportedTypes = map[reflect.Type]bool{
 // whose remaining arguments are the types to be fuzzed.
 // For example:
 //
-//	f.Fuzz(func(t *testing.T, b []byte, i int) { ... })
+//	f.Fuzz(func(t *testing.T, b []byte) { ... })
 //
-// The following types are allowed: []byte, string, bool, byte, rune, float32,
-// float64, int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64.
-// More types may be supported in the future.
+// TinyGo only supports fuzz functions with a single []byte or string argument.
+// Fuzzing is driven by the WebAssembly host (see the tinygo.fuzz build tag),
+// so it is only supported on WebAssembly targets.
 //
 // ff must not call any *F methods, e.g. (*F).Log, (*F).Error, (*F).Skip. Use
 // the corresponding *T method instead. The only *F methods that are allowed in
@@ -120,11 +120,144 @@ var supportedTypes = map[reflect.Type]bool{
 // (set with -fuzztime), or the test process is interrupted by a signal. F.Fuzz
 // should be called exactly once, unless F.Skip or F.Fail is called beforehand.
 func (f *F) Fuzz(ff interface{}) {
-	f.failed = true
-	f.result.N = 0
-	f.result.T = 0
-	f.result.Error = errors.New("operation not implemented")
-	return
+	if f.fuzzCalled {
+		panic("testing: F.Fuzz called more than once")
+	}
+	f.fuzzCalled = true
+
+	var fn func(t *T, data []byte)
+	isString := false
+	switch ff := ff.(type) {
+	case func(*T, []byte):
+		fn = ff
+	case func(*T, string):
+		fn = func(t *T, data []byte) {
+			ff(t, string(data))
+		}
+		isString = true
+	default:
+		f.Errorf("testing: unsupported fuzz function type %T, only func(*testing.T, []byte) and func(*testing.T, string) are supported", ff)
+		return
+	}
+
+	// Run the seed corpus first.
+	var seeds [][]byte
+	for _, entry := range f.corpus {
+		if len(entry.Values) != 1 {
+			f.Errorf("testing: %s has %d values, expected 1", entry.Path, len(entry.Values))
+			return
+		}
+		var data []byte
+		switch value := entry.Values[0].(type) {
+		case []byte:
+			data = value
+		case string:
+			data = []byte(value)
+		default:
+			f.Errorf("testing: %s has unsupported type %T", entry.Path, value)
+			return
+		}
+		seeds = append(seeds, data)
+		f.runInput(f.name+"/"+entry.Path, fn, data, true)
+	}
+	if f.failed {
+		return
+	}
+
+	// Fuzz with inputs generated by the host, until an input fails or the
+	// host stops.
+	f.inFuzzFn = true
+	start := time.Now()
+	n, err := fuzzWithHost(f.name, isString, seeds, flagFuzzTime, func(data []byte) bool {
+		return f.runInput(f.name, fn, data, false)
+	})
+	f.inFuzzFn = false
+	f.result = fuzzResult{N: n, T: time.Since(start), Error: err}
+	if err != nil {
+		f.Error(err)
+	}
+}
+
+// runInput runs the fuzz function with a single input, as a subtest of f. The
+// result is reported if the input is from the seed corpus or if it failed. It
+// returns whether the input passed.
+func (f *F) runInput(name string, fn func(t *T, data []byte), data []byte, seed bool) bool {
+	t := &T{
+		common: common{
+			output: &logger{logToStdout: flagVerbose},
+			name:   name,
+			parent: &f.common,
+			level:  f.level + 1,
+			indent: f.indent + "    ",
+		},
+		context: f.testContext,
+	}
+	if seed && flagVerbose {
+		fmt.Fprintf(f.output, "=== RUN   %s\n", t.name)
+	}
+	t.start = time.Now()
+	fn(t, data)
+	t.duration = time.Since(t.start)
+	t.runCleanup()
+	if seed || t.failed {
+		t.report()
+	}
+	return !t.failed
+}
+
+// runFuzzTargets runs the fuzz target selected with -test.fuzz: first with its
+// seed corpus and then with inputs generated by the fuzzing host. Fuzz targets
+// are not run without -test.fuzz.
+func runFuzzTargets(matchString func(pat, str string) (bool, error), fuzzTargets []InternalFuzzTarget) (ran, ok bool) {
+	if flagFuzzRegexp == "" {
+		return false, true
+	}
+	var matched []InternalFuzzTarget
+	for _, target := range fuzzTargets {
+		if match, _ := matchString(flagFuzzRegexp, target.Name); match {
+			matched = append(matched, target)
+		}
+	}
+	if len(matched) == 0 {
+		fmt.Fprintln(os.Stderr, "testing: warning: no fuzz tests to fuzz")
+		return false, true
+	}
+	if len(matched) > 1 {
+		var names []string
+		for _, target := range matched {
+			names = append(names, target.Name)
+		}
+		fmt.Fprintf(os.Stderr, "testing: will not fuzz, -fuzz matches more than one fuzz test: %v\n", names)
+		return false, false
+	}
+
+	ctx := newTestContext(newMatcher(matchString, "", "-test.fuzz", ""))
+	t := &T{
+		common: common{
+			output: &logger{logToStdout: flagVerbose},
+		},
+		context: ctx,
+	}
+	tRunner(t, func(t *T) {
+		target := matched[0]
+		t.Run(target.Name, func(t *T) {
+			f := &F{
+				common: common{
+					output: t.output,
+					name:   t.name,
+					level:  t.level,
+					indent: t.indent,
+				},
+				testContext: t.context,
+			}
+			target.Fn(f)
+			if f.failed {
+				t.Fail()
+			}
+		})
+		ok = !t.Failed()
+	})
+	return t.ran, ok
 }
 
 // fuzzContext holds fields common to all fuzz tests.
@@ -0,0 +1,83 @@
+//go:build tinygo.wasm && tinygo.fuzz
+
+package testing
+
+// Fuzzing on WebAssembly is driven by the host (`tinygo wasmhost`), which
+// generates the inputs and keeps the corpus. The fuzz loop asks the host for
+// the next input, runs it, and reports whether it failed. In between, the host
+// reads the coverage counters to find out whether the input reached new code.
+// Panics trap the WebAssembly module, after which the host saves the input
+// that caused it.
+
+import (
+	"time"
+	"unsafe"
+)
+
+// Start fuzzing. The name and counters are passed as a pointer-size: the
+// pointer in the low 32 bits and the length in the high 32 bits.
+//
+//go:wasmimport env tinygo_fuzz_start
+func hostFuzzStart(name uint64, isString uint32, counters uint64, fuzzTime int64)
+
+// Add an input to the corpus of the host.
+//
+//go:wasmimport env tinygo_fuzz_seed
+func hostFuzzSeed(data uint64)
+
+// Report whether the previous input failed, and return the length of the next
+// input or -1 to stop fuzzing.
+//
+//go:wasmimport env tinygo_fuzz_next
+func hostFuzzNext(failed uint32) int32
+
+// Copy the next input to buf.
+//
+//go:wasmimport env tinygo_fuzz_input
+func hostFuzzInput(buf unsafe.Pointer)
+
+// Coverage counters, one for each basic block in the instrumented code. The
+// compiler points this slice to the counters when building with -fuzz.
+var fuzzCounters []byte
+
+// fuzzWithHost runs the fuzz loop. It returns the number of inputs that were
+// run.
+func fuzzWithHost(name string, isString bool, seeds [][]byte, fuzzTime time.Duration, run func(data []byte) bool) (int, error) {
+	hostFuzzStart(pointerSize([]byte(name)), boolToUint32(isString), pointerSize(fuzzCounters), int64(fuzzTime))
+	for _, seed := range seeds {
+		hostFuzzSeed(pointerSize(seed))
+	}
+	var buf []byte
+	failed := false
+	n := 0
+	for {
+		size := hostFuzzNext(boolToUint32(failed))
+		if size < 0 {
+			break
+		}
+		if int(size) > cap(buf) {
+			buf = make([]byte, size)
+		}
+		buf = buf[:size]
+		if size != 0 {
+			hostFuzzInput(unsafe.Pointer(&buf[0]))
+		}
+		failed = !run(buf)
+		n++
+	}
+	return n, nil
+}
+
+func pointerSize(data []byte) uint64 {
+	if len(data) == 0 {
+		return 0
+	}
+	return uint64(uintptr(unsafe.Pointer(&data[0]))) | uint64(len(data))<<32
+}
+
+func boolToUint32(b bool) uint32 {
+	if b {
+		return 1
+	}
+	return 0
+}
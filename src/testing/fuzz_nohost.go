@@ -0,0 +1,14 @@
+//go:build !tinygo.wasm || !tinygo.fuzz
+
+package testing
+
+import (
+	"errors"
+	"time"
+)
+
+// fuzzWithHost is only supported on WebAssembly, where the host generates the
+// inputs.
+func fuzzWithHost(name string, isString bool, seeds [][]byte, fuzzTime time.Duration, run func(data []byte) bool) (int, error) {
+	return 0, errors.New("fuzzing is only supported on WebAssembly targets, with tinygo test -fuzz")
+}
@@ -32,6 +32,8 @@ var (
 	flagSkipRegexp string
 	flagShuffle    string
 	flagCount      int
+	flagFuzzRegexp string
+	flagFuzzTime   time.Duration
 )
 
 var initRan bool
@@ -50,6 +52,8 @@ func Init() {
 	flag.StringVar(&flagShuffle, "test.shuffle", "off", "shuffle: off, on, <numeric-seed>")
 
 	flag.IntVar(&flagCount, "test.count", 1, "run each test or benchmark `count` times")
+	flag.StringVar(&flagFuzzRegexp, "test.fuzz", "", "run the fuzz test matching `regexp`")
+	flag.DurationVar(&flagFuzzTime, "test.fuzztime", 0, "time to spend fuzzing; default is to run indefinitely")
 
 	initBenchmarkFlags()
 }
@@ -478,8 +482,9 @@ func newTestContext(m *matcher) *testContext {
 // M is a test suite.
 type M struct {
 	// tests is a list of the test names to execute
-	Tests      []InternalTest
-	Benchmarks []InternalBenchmark
+	Tests       []InternalTest
+	Benchmarks  []InternalBenchmark
+	FuzzTargets []InternalFuzzTarget
 
 	deps testDeps
 
@@ -531,10 +536,11 @@ func (m *M) Run() (code int) {
 	}
 
 	testRan, testOk := runTests(m.deps.MatchString, m.Tests)
-	if !testRan && *matchBenchmarks == "" {
+	if !testRan && *matchBenchmarks == "" && flagFuzzRegexp == "" {
 		fmt.Fprintln(os.Stderr, "testing: warning: no tests to run")
 	}
-	if !testOk || !runBenchmarks(m.deps.MatchString, m.Benchmarks) {
+	_, fuzzOk := runFuzzTargets(m.deps.MatchString, m.FuzzTargets)
+	if !testOk || !fuzzOk || !runBenchmarks(m.deps.MatchString, m.Benchmarks) {
 		fmt.Println("FAIL")
 		m.exitCode = 1
 	} else {
@@ -610,9 +616,10 @@ type InternalExample struct {
 func MainStart(deps interface{}, tests []InternalTest, benchmarks []InternalBenchmark, fuzzTargets []InternalFuzzTarget, examples []InternalExample) *M {
 	Init()
 	return &M{
-		Tests:      tests,
-		Benchmarks: benchmarks,
-		deps:       deps.(testDeps),
+		Tests:       tests,
+		Benchmarks:  benchmarks,
+		FuzzTargets: fuzzTargets,
+		deps:        deps.(testDeps),
 	}
 }
 
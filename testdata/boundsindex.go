@@ -0,0 +1,15 @@
+package main
+
+// Check the message of an index out of range panic in a debug build for a
+// Polkadot target, which includes the index, length and source position.
+
+var (
+	values = []int{1, 2, 3}
+	index  = 5
+)
+
+func main() {
+	println("reading index", index)
+	v := values[index]
+	println("unreachable:", v)
+}
@@ -0,0 +1,15 @@
+package main
+
+// Check the message of a slice bounds out of range panic in a debug build for
+// a Polkadot target, which includes the bounds and source position.
+
+var (
+	values = []int{1, 2, 3}
+	high   = 5
+)
+
+func main() {
+	println("slicing up to", high)
+	s := values[1:high]
+	println("unreachable:", len(s))
+}
@@ -0,0 +1,29 @@
+package main
+
+import "runtime/eventqueue"
+
+func main() {
+	var q eventqueue.Queue[int]
+
+	q.Subscribe("tick", func(topic string, n int) {
+		println("tick handler:", n)
+		if n < 3 {
+			// Publishing from a handler queues the event after all others.
+			q.Publish("tick", n+1)
+		}
+	})
+	all := q.Subscribe("", func(topic string, n int) {
+		println("all handler:", topic, n)
+	})
+	q.Subscribe("tock", func(topic string, n int) {
+		println("tock handler:", n)
+		q.Unsubscribe(all)
+	})
+
+	q.Publish("tick", 1)
+	q.Publish("tock", 10)
+	println("pending:", q.Len())
+	println("processed:", q.Process())
+	println("pending:", q.Len())
+	println("step on empty queue:", q.Step())
+}
@@ -0,0 +1,33 @@
+package main
+
+// Floating point results that may differ between hosts, unless the program is
+// built with -fp-deterministic.
+
+import (
+	"math"
+	"runtime/volatile"
+)
+
+// Read with a volatile load, so that LLVM can't compute the results at compile
+// time.
+var zeroBits uint64
+
+func main() {
+	zero := math.Float64frombits(volatile.LoadUint64(&zeroBits))
+
+	// The sign and payload of these NaNs are up to the host.
+	nan := zero / zero
+	println("0/0:        ", math.Float64bits(nan))
+	println("inf-inf:    ", math.Float64bits(math.Inf(1)-math.Inf(1)*(1+zero)))
+	println("sqrt(-1):   ", math.Float64bits(math.Sqrt(zero-1)))
+	println("float32 nan:", math.Float32bits(float32(nan)))
+
+	// These are not rounded the same way by all C libraries.
+	println("exp(1):     ", math.Float64bits(math.Exp(1+zero)))
+	println("exp2(0.3):  ", math.Float64bits(math.Exp2(0.3+zero)))
+	println("log(10):    ", math.Float64bits(math.Log(10+zero)))
+
+	// Fusing the multiplication and addition would give 5.551115123125783e-17.
+	x, y := 0.1+zero, 10+zero
+	println("x*y-1:      ", math.Float64bits(float64(x*y)-1))
+}
@@ -0,0 +1,6 @@
+//go:build !gc.leaking && !gc.custom
+
+package main
+
+// The GC frees unreachable memory, so the heap must not keep growing.
+const collecting = true
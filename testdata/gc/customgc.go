@@ -0,0 +1,63 @@
+//go:build gc.custom
+
+package main
+
+// This is a minimal implementation of the gc.custom interface, to check that a
+// GC plugged in from outside the runtime gets all the allocations. It gets its
+// memory from the wasi-libc allocator and never frees anything.
+
+import (
+	"runtime"
+	"unsafe"
+)
+
+var (
+	customTotalAlloc uint64
+	customMallocs    uint64
+)
+
+//export calloc
+func libc_calloc(nmemb, size uintptr) unsafe.Pointer
+
+//go:linkname initHeap runtime.initHeap
+func initHeap() {
+}
+
+//go:linkname alloc runtime.alloc
+func alloc(size uintptr, layout unsafe.Pointer) unsafe.Pointer {
+	if size == 0 {
+		size = 1
+	}
+	ptr := libc_calloc(1, size)
+	if ptr == nil {
+		panic("custom gc: out of memory")
+	}
+	customTotalAlloc += uint64(size)
+	customMallocs++
+	return ptr
+}
+
+//go:linkname free runtime.free
+func free(ptr unsafe.Pointer) {
+}
+
+//go:linkname markRoots runtime.markRoots
+func markRoots(start, end uintptr) {
+}
+
+//go:linkname GC runtime.GC
+func GC() {
+}
+
+//go:linkname SetFinalizer runtime.SetFinalizer
+func SetFinalizer(obj interface{}, finalizer interface{}) {
+}
+
+//go:linkname ReadMemStats runtime.ReadMemStats
+func ReadMemStats(m *runtime.MemStats) {
+	m.HeapInuse = customTotalAlloc
+	m.HeapSys = customTotalAlloc
+	m.TotalAlloc = customTotalAlloc
+	m.Mallocs = customMallocs
+	m.Sys = customTotalAlloc
+}
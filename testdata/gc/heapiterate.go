@@ -0,0 +1,41 @@
+//go:build gc.extalloc
+
+package main
+
+import (
+	"runtime"
+	"unsafe"
+)
+
+// testHeapIterate checks that runtime.HeapIterate visits live objects in order
+// of address, with at least their allocated size, and that it stops when asked.
+func testHeapIterate() bool {
+	objects := make([][]byte, 20)
+	for i := range objects {
+		objects[i] = make([]byte, 1+i*37) // small and large objects
+	}
+	found := 0
+	last := uintptr(0)
+	ok := true
+	runtime.HeapIterate(func(ptr unsafe.Pointer, size uintptr) bool {
+		if uintptr(ptr) < last {
+			ok = false
+		}
+		last = uintptr(ptr) + size
+		for _, obj := range objects {
+			if unsafe.Pointer(&obj[0]) == ptr {
+				found++
+				if size < uintptr(len(obj)) {
+					ok = false
+				}
+			}
+		}
+		return true
+	})
+	visited := 0
+	runtime.HeapIterate(func(ptr unsafe.Pointer, size uintptr) bool {
+		visited++
+		return false
+	})
+	return ok && found == len(objects) && visited == 1
+}
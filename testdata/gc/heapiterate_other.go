@@ -0,0 +1,8 @@
+//go:build !gc.extalloc
+
+package main
+
+// runtime.HeapIterate is only supported by the extalloc GC.
+func testHeapIterate() bool {
+	return true
+}
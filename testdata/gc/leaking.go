@@ -0,0 +1,7 @@
+//go:build gc.leaking || gc.custom
+
+package main
+
+// The GC never frees memory (the custom GC below is a leaking GC too), so the
+// heap is expected to grow.
+const collecting = false
@@ -0,0 +1,177 @@
+package main
+
+// This tests the heap limit of the extalloc GC, which is set to 1MB with the
+// extalloc-limit target property in main_test.go. Close to the limit, the GC
+// must collect more often instead of running out of memory, call the memory
+// pressure handlers so that the program can free memory, and when the heap
+// really is full it must describe the heap before it panics.
+
+import "runtime"
+
+const limit = 1 << 20
+
+var (
+	live [][]byte
+	sink []byte
+
+	// Memory that the memory pressure handler frees, when it is called with
+	// at least freeLevel.
+	cache     [][]byte
+	freeLevel = 3
+
+	// Highest level the memory pressure handler was called with.
+	pressureLevel int
+)
+
+func main() {
+	runtime.RegisterMemoryPressureHandler(func(level int) {
+		if level > pressureLevel {
+			pressureLevel = level
+		}
+		if level >= freeLevel {
+			cache = nil
+		}
+	})
+
+	testCollections()
+	testPressureThresholds()
+	testPressureHandler()
+	testOutOfMemoryStrategy()
+	testOutOfMemory()
+}
+
+// Far from the limit, a collection cycle runs when the heap has doubled in
+// size. Close to the limit it runs when half of the remaining memory has been
+// used up. With 768kB in use, that's after every 8 allocations of 16kB, while
+// waiting for the external allocator to fail would only collect after every 16
+// allocations.
+func testCollections() {
+	for i := 0; i < 48; i++ {
+		live = append(live, make([]byte, 16*1024))
+	}
+	if n := countCollections(128, 16*1024); n >= 12 {
+		println("collections near the limit: ok")
+	} else {
+		println("collections near the limit: FAIL, ran", n, "collections")
+	}
+	live = nil
+	runtime.GC()
+}
+
+// countCollections allocates n objects of the given size that are garbage
+// right away, and returns the number of collection cycles that ran in the
+// meantime: the number of times the heap in use shrunk.
+func countCollections(n, size int) int {
+	var stats runtime.MemStats
+	runtime.ReadMemStats(&stats)
+	collections := 0
+	for i := 0; i < n; i++ {
+		previous := stats.HeapInuse
+		sink = make([]byte, size)
+		runtime.ReadMemStats(&stats)
+		if stats.HeapInuse < previous {
+			collections++
+		}
+	}
+	sink = nil
+	return collections
+}
+
+// The memory pressure handlers are called when the heap is above half of the
+// limit after a collection cycle, and with the critical level when it is above
+// three quarters.
+func testPressureThresholds() {
+	pressureLevel = 0
+	for i := 0; i < 40; i++ {
+		live = append(live, make([]byte, 16*1024))
+	}
+	countCollections(32, 16*1024)
+	moderate := pressureLevel == runtime.MemoryPressureModerate
+	for i := 0; i < 10; i++ {
+		live = append(live, make([]byte, 16*1024))
+	}
+	countCollections(32, 16*1024)
+	critical := pressureLevel == runtime.MemoryPressureCritical
+	if moderate && critical {
+		println("memory pressure thresholds: ok")
+	} else {
+		println("memory pressure thresholds: FAIL, moderate", moderate, "critical", critical)
+	}
+	live = nil
+	runtime.GC()
+}
+
+// fillHeap fills the heap up to 960kB, with 320kB of it in the cache that the
+// memory pressure handler can free. The thresholds are disabled, so that only
+// a failing allocation calls the handler.
+func fillHeap() {
+	runtime.SetMemoryPressureThresholds(0, 0)
+	pressureLevel = 0
+	for i := 0; i < 40; i++ {
+		live = append(live, make([]byte, 16*1024))
+	}
+	for i := 0; i < 20; i++ {
+		cache = append(cache, make([]byte, 16*1024))
+	}
+}
+
+// resetHeap frees everything allocated by fillHeap, and restores the default
+// thresholds.
+func resetHeap() {
+	live = nil
+	cache = nil
+	sink = nil
+	runtime.GC()
+	runtime.SetMemoryPressureThresholds(limit/2, limit/4*3)
+}
+
+// An allocation that doesn't fit is retried after the memory pressure handlers
+// were called, so it succeeds if they free enough memory.
+func testPressureHandler() {
+	fillHeap()
+	freeLevel = runtime.MemoryPressureModerate
+	sink = make([]byte, 128*1024)
+	if cache == nil && pressureLevel == runtime.MemoryPressureModerate {
+		println("memory pressure handler: ok")
+	} else {
+		println("memory pressure handler: FAIL, level", pressureLevel)
+	}
+	resetHeap()
+}
+
+// The out of memory strategy determines the memory pressure level of every
+// retry. Here the handler only frees memory at the critical level, which the
+// strategy only uses in the second retry.
+func testOutOfMemoryStrategy() {
+	var attempts int
+	var retrySize uintptr
+	runtime.SetOutOfMemoryStrategy(func(attempt int, size uintptr) int {
+		attempts = attempt
+		retrySize = size
+		if attempt > 2 {
+			return 0
+		}
+		return attempt
+	})
+	fillHeap()
+	freeLevel = runtime.MemoryPressureCritical
+	sink = make([]byte, 128*1024)
+	if cache == nil && attempts == 2 && retrySize == 128*1024 && pressureLevel == runtime.MemoryPressureCritical {
+		println("out of memory strategy: ok")
+	} else {
+		println("out of memory strategy: FAIL, attempts", attempts, "size", retrySize, "level", pressureLevel)
+	}
+	runtime.SetOutOfMemoryStrategy(nil)
+	freeLevel = 3
+	resetHeap()
+}
+
+// testOutOfMemory keeps all allocations alive until the heap limit is reached,
+// which panics.
+func testOutOfMemory() {
+	println("allocating until the heap limit is reached")
+	for len(live) < 2*limit/(64*1024) {
+		live = append(live, make([]byte, 64*1024))
+	}
+	println("heap limit: FAIL, allocated", len(live)*64, "kB")
+}
@@ -0,0 +1,459 @@
+package main
+
+// This is a conformance test for the garbage collectors that are used on
+// WebAssembly targets that get their memory from the host. It builds data
+// structures that are hard to get right for a conservative collector (cycles,
+// interior pointers, huge objects, a fragmented heap), churns the heap to
+// overwrite anything that was freed too early, and then checks that the data
+// structures are still intact.
+//
+// When the GC actually collects memory, it also checks that repeating the same
+// work doesn't make the heap grow without bounds.
+
+import (
+	"runtime"
+	"unsafe"
+)
+
+// Number of times the whole workload is repeated, to check for bounded growth.
+const rounds = 5
+
+var failed bool
+
+func main() {
+	var heapSizes [rounds]uint64
+	for round := 0; round < rounds; round++ {
+		verbose := round == 0
+		report(verbose, "linked list", testLinkedList())
+		report(verbose, "cycles", testCycles())
+		report(verbose, "interior pointers", testInteriorPointers())
+		report(verbose, "finalizers", testFinalizers())
+		report(verbose, "huge slices", testHugeSlices())
+		report(verbose, "fragmentation", testFragmentation())
+		report(verbose, "no-GC section", testNoGC())
+		report(verbose, "stack bounds", testStackBounds())
+		report(verbose, "memory statistics", testMemStats(verbose))
+		report(verbose, "heap iteration", testHeapIterate())
+
+		runtime.GC()
+		var stats runtime.MemStats
+		runtime.ReadMemStats(&stats)
+		heapSizes[round] = stats.HeapInuse
+	}
+
+	// The first round may leave some objects behind that are kept alive by
+	// stale pointers, but after that the heap must stay roughly the same size.
+	if collecting {
+		limit := heapSizes[0]*2 + 64*1024
+		for round, size := range heapSizes {
+			if size > limit {
+				println("bounded growth: heap grew to", size, "bytes in round", round, "limit", limit)
+				failed = true
+			}
+		}
+	}
+	if !failed {
+		println("bounded growth: ok")
+	}
+	println("done")
+}
+
+// report prints the result of a single test. Only the first round prints
+// passing tests, so that the output doesn't depend on the number of rounds.
+func report(verbose bool, name string, ok bool) {
+	if !ok {
+		println(name+":", "FAIL")
+		failed = true
+	} else if verbose {
+		println(name+":", "ok")
+	}
+}
+
+// churn allocates and throws away a lot of memory of different sizes, which
+// causes a few collection cycles and overwrites memory that was freed while it
+// was still in use.
+//
+//go:noinline
+func churn() {
+	var keep [8][]byte
+	size := 16
+	for i := 0; i < 500; i++ {
+		buf := make([]byte, size)
+		for j := range buf {
+			buf[j] = 0xa5
+		}
+		keep[i%len(keep)] = buf
+		size = size*3%1021 + 16
+	}
+	for i := 0; i < 500; i++ {
+		node := &listNode{value: -1}
+		node.next = node
+	}
+}
+
+type listNode struct {
+	next  *listNode
+	value int
+}
+
+//go:noinline
+func buildList(n int) *listNode {
+	var head *listNode
+	for i := n - 1; i >= 0; i-- {
+		head = &listNode{next: head, value: i}
+	}
+	return head
+}
+
+func testLinkedList() bool {
+	const n = 10000
+	head := buildList(n)
+	churn()
+	runtime.GC()
+	i := 0
+	for node := head; node != nil; node = node.next {
+		if node.value != i {
+			return false
+		}
+		i++
+	}
+	return i == n
+}
+
+type graphNode struct {
+	id    int
+	edges []*graphNode
+}
+
+// buildGraph creates a graph in which every node is reachable from every other
+// node, and returns just one of them.
+//
+//go:noinline
+func buildGraph(n int) *graphNode {
+	nodes := make([]*graphNode, n)
+	for i := range nodes {
+		nodes[i] = &graphNode{id: i}
+	}
+	for i, node := range nodes {
+		node.edges = append(node.edges, nodes[(i+1)%n], nodes[(i*7+3)%n])
+		if i%10 == 0 {
+			node.edges = append(node.edges, node) // self-reference
+		}
+	}
+	return nodes[n/2]
+}
+
+func testCycles() bool {
+	const n = 1000
+	start := buildGraph(n)
+
+	// Also create a lot of unreachable cycles, which must be collected.
+	for i := 0; i < 20; i++ {
+		buildGraph(100)
+	}
+	churn()
+	runtime.GC()
+
+	seen := make([]bool, n)
+	count := 0
+	stack := []*graphNode{start}
+	for len(stack) > 0 {
+		node := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		if node.id < 0 || node.id >= n {
+			return false
+		}
+		if seen[node.id] {
+			continue
+		}
+		seen[node.id] = true
+		count++
+		if len(node.edges) < 2 || node.edges[0].id != (node.id+1)%n || node.edges[1].id != (node.id*7+3)%n {
+			return false
+		}
+		stack = append(stack, node.edges...)
+	}
+	return count == n
+}
+
+type record struct {
+	header  [8]uint32
+	payload [32]uint32
+}
+
+// makeInteriorPointers returns pointers into the middle of objects and slices
+// that start in the middle of an array, so that nothing points to the start of
+// the objects anymore.
+//
+//go:noinline
+func makeInteriorPointers(n int) ([]*uint32, [][]uint32) {
+	fields := make([]*uint32, n)
+	slices := make([][]uint32, n)
+	for i := 0; i < n; i++ {
+		r := &record{}
+		for j := range r.payload {
+			r.payload[j] = uint32(i*100 + j)
+		}
+		fields[i] = &r.payload[17]
+
+		arr := make([]uint32, 256)
+		for j := range arr {
+			arr[j] = uint32(i*1000 + j)
+		}
+		slices[i] = arr[200:210]
+	}
+	return fields, slices
+}
+
+func testInteriorPointers() bool {
+	const n = 64
+	fields, slices := makeInteriorPointers(n)
+	churn()
+	runtime.GC()
+	for i := 0; i < n; i++ {
+		if *fields[i] != uint32(i*100+17) {
+			return false
+		}
+		for j, v := range slices[i] {
+			if v != uint32(i*1000+200+j) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+type finalized struct {
+	value int
+	round int
+	data  [16]int
+}
+
+var (
+	finalizerRound int // incremented every time testFinalizers runs
+	finalizerCalls int // finalizers that ran for objects of the current round
+)
+
+func testFinalizers() bool {
+	finalizerRound++
+	finalizerCalls = 0
+	objects := make([]*finalized, 100)
+	for i := range objects {
+		obj := &finalized{value: i, round: finalizerRound}
+		runtime.SetFinalizer(obj, func(obj *finalized) {
+			if obj.round == finalizerRound {
+				finalizerCalls++
+			}
+		})
+		objects[i] = obj
+	}
+	churn()
+	runtime.GC()
+
+	// Finalizers may not be supported at all, but they must never run for an
+	// object that is still reachable.
+	if finalizerCalls != 0 {
+		return false
+	}
+	for i, obj := range objects {
+		if obj.value != i {
+			return false
+		}
+	}
+	return true
+}
+
+// fillHuge allocates a huge byte slice and a huge pointer slice, which must
+// both be scanned (or not) correctly.
+//
+//go:noinline
+func fillHuge(size int) ([]byte, []*listNode) {
+	bytes := make([]byte, size)
+	for i := range bytes {
+		bytes[i] = byte(i * 7)
+	}
+	pointers := make([]*listNode, size/64)
+	for i := range pointers {
+		if i%256 == 0 {
+			pointers[i] = &listNode{value: i}
+		}
+	}
+	return bytes, pointers
+}
+
+func testHugeSlices() bool {
+	const size = 2 * 1024 * 1024
+	for i := 0; i < 3; i++ {
+		bytes, pointers := fillHuge(size)
+		churn()
+		runtime.GC()
+		for j, b := range bytes {
+			if b != byte(j*7) {
+				return false
+			}
+		}
+		for j, p := range pointers {
+			if (j%256 == 0) != (p != nil) || p != nil && p.value != j {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// fragment allocates objects of alternating sizes and only keeps every third
+// one, which leaves lots of small holes in the heap.
+//
+//go:noinline
+func fragment(n int) [][]byte {
+	var kept [][]byte
+	for i := 0; i < n; i++ {
+		size := 8 << uint(i%8)
+		buf := make([]byte, size)
+		for j := range buf {
+			buf[j] = byte(i)
+		}
+		if i%3 == 0 {
+			kept = append(kept, buf)
+		}
+	}
+	return kept
+}
+
+func testFragmentation() bool {
+	const n = 3000
+	kept := fragment(n)
+
+	// Allocate objects that don't fit in most of the holes.
+	var large [][]byte
+	for i := 0; i < 100; i++ {
+		large = append(large, make([]byte, 3000+i))
+	}
+	churn()
+	runtime.GC()
+
+	for k, buf := range kept {
+		i := k * 3
+		if len(buf) != 8<<uint(i%8) {
+			return false
+		}
+		for _, b := range buf {
+			if b != byte(i) {
+				return false
+			}
+		}
+	}
+	for i, buf := range large {
+		if len(buf) != 3000+i {
+			return false
+		}
+	}
+
+	// The fragmentation statistics are only set by some GCs, but they must
+	// always be consistent.
+	var stats runtime.MemStats
+	runtime.ReadMemStats(&stats)
+	if stats.HeapLargestGap > stats.HeapGapBytes || stats.HeapFragmentation < 0 || stats.HeapFragmentation >= 1 {
+		return false
+	}
+	if stats.HeapSpan != 0 && stats.HeapGapBytes+stats.HeapInuse > stats.HeapSpan {
+		return false
+	}
+	return true
+}
+
+// hiddenPointer hides a pointer from the GC, like a pointer that was passed to
+// the host as an integer.
+type hiddenPointer uintptr
+
+const hideMask = 0x5a5a5a5a
+
+//go:noinline
+func hide(p *[64]uint32) hiddenPointer {
+	return hiddenPointer(uintptr(unsafe.Pointer(p)) ^ hideMask)
+}
+
+func (p hiddenPointer) reveal() *[64]uint32 {
+	return (*[64]uint32)(unsafe.Pointer(uintptr(p) ^ hideMask))
+}
+
+// testNoGC checks that objects that are only referenced by a hidden pointer
+// are not freed inside a section started with runtime.EnterNoGC, even when
+// runtime.GC is called or a lot of memory is allocated.
+func testNoGC() bool {
+	runtime.EnterNoGC()
+	hidden := make([]hiddenPointer, 16)
+	for i := range hidden {
+		buf := new([64]uint32)
+		for j := range buf {
+			buf[j] = uint32(i*64 + j)
+		}
+		hidden[i] = hide(buf)
+	}
+	runtime.EnterNoGC() // nested sections are allowed
+	churn()
+	runtime.GC()
+	runtime.ExitNoGC()
+	churn()
+	ok := true
+	for i, p := range hidden {
+		for j, v := range p.reveal() {
+			if v != uint32(i*64+j) {
+				ok = false
+			}
+		}
+	}
+	runtime.ExitNoGC()
+	return ok
+}
+
+// testStackBounds checks that runtime.StackBounds returns a valid range, and
+// that objects only referenced from the stack are not freed.
+func testStackBounds() bool {
+	bottom, top := runtime.StackBounds()
+	if bottom >= top {
+		return false
+	}
+	var lists [16]*listNode
+	for i := range lists {
+		lists[i] = buildList(10)
+	}
+	churn()
+	runtime.GC()
+	for _, node := range lists {
+		for i := 0; i < 10; i++ {
+			if node == nil || node.value != i {
+				return false
+			}
+			node = node.next
+		}
+	}
+	return true
+}
+
+// testMemStats checks that every allocation is counted once in the memory
+// statistics, and that nothing is freed while the objects are in use. All GCs
+// count in the same way, so the first round prints the counter deltas to
+// compare them between the GCs. Only TotalAlloc includes the rounding of the
+// GC, so it is only checked to cover the requested sizes.
+func testMemStats(verbose bool) bool {
+	const n = 100
+	objects := make([][]byte, n)
+	var before, after runtime.MemStats
+	runtime.EnterNoGC()
+	runtime.ReadMemStats(&before)
+	size := uint64(0)
+	for i := range objects {
+		objects[i] = make([]byte, 1+i*7)
+		size += uint64(len(objects[i]))
+	}
+	runtime.ReadMemStats(&after)
+	runtime.ExitNoGC()
+	mallocs := after.Mallocs - before.Mallocs
+	frees := after.Frees - before.Frees
+	covered := after.TotalAlloc-before.TotalAlloc >= size
+	if verbose {
+		println("memory statistics: mallocs", mallocs, "frees", frees, "bytes", size, "covered", covered)
+	}
+	return mallocs == n && frees == 0 && covered
+}
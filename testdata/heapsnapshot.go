@@ -0,0 +1,42 @@
+package main
+
+// State that a -heap-snapshot build rolls back: a global counter, and a map and
+// a slice on the heap. See TestHeapSnapshot.
+
+//go:wasmimport env run_host
+func runHost()
+
+var (
+	counter int
+	names   = map[string]int{}
+	values  []int
+)
+
+func init() {
+	// Regular programs don't run main on wasm-unknown targets, but they do run
+	// the package initializers from _initialize. The host takes the snapshots
+	// and calls the exports from here.
+	runHost()
+}
+
+//export update
+func update(n uint32) {
+	counter++
+	names[string(rune('a'+n))] = int(n)
+	values = append(values, int(n))
+}
+
+//export report
+func report() {
+	sum := 0
+	for _, value := range values {
+		sum += value
+	}
+	for _, value := range names {
+		sum += value
+	}
+	println("counter:", counter, "names:", len(names), "values:", len(values), "sum:", sum)
+}
+
+func main() {
+}
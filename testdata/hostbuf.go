@@ -0,0 +1,54 @@
+package main
+
+import "runtime/hostbuf"
+
+var storage = map[string]string{
+	"short": "hello",
+	"long":  string(make([]byte, 1000)),
+}
+
+// storageRead is like the ext_storage_read_version_1 function of a Polkadot
+// host: it copies the start of the value into buf and returns its full size.
+func storageRead(key string, buf []byte) int {
+	value, ok := storage[key]
+	if !ok {
+		return -1
+	}
+	copy(buf, value)
+	return len(value)
+}
+
+func main() {
+	// Large buffers are reused after Put.
+	a := hostbuf.Get(1000)
+	println("len:", len(a), "cap at least 1024:", cap(a) >= 1024)
+	hostbuf.Put(a)
+	b := hostbuf.Get(600)
+	println("reused:", &a[0] == &b[0])
+	c := hostbuf.Get(600)
+	println("reused while in use:", &b[0] == &c[0])
+	hostbuf.Put(b)
+	hostbuf.Put(c)
+
+	// Small buffers are not kept.
+	d := hostbuf.Get(16)
+	println("small cap:", cap(d) < 256)
+	hostbuf.Put(d)
+
+	// Values of unknown size.
+	for _, key := range []string{"short", "long", "missing"} {
+		buf, ok := hostbuf.Read(64, func(buf []byte) int {
+			return storageRead(key, buf)
+		})
+		println("read", key+":", len(buf), ok)
+		if ok {
+			hostbuf.Put(buf)
+		}
+	}
+	short, _ := hostbuf.Read(64, func(buf []byte) int {
+		return storageRead("short", buf)
+	})
+	println("value:", string(short))
+	println("pointer-size length:", hostbuf.PointerSize(short)>>32)
+	hostbuf.Put(short)
+}
@@ -0,0 +1,34 @@
+package main
+
+// Test formatting that is lowered to string concatenation with -lower-fmt.
+// The output must be exactly the same as with the regular fmt package.
+
+import (
+	"errors"
+	"fmt"
+)
+
+type myInt int
+
+type stringer int
+
+func (s stringer) String() string {
+	return "stringer"
+}
+
+func main() {
+	println(fmt.Sprintf("hello"))
+	println(fmt.Sprintf("%d %d %d", 5, -5, int8(-128)))
+	println(fmt.Sprintf("%x %x %v", 255, -255, uint64(18446744073709551615)))
+	println(fmt.Sprintf("%s=%v, %t 100%%", "key", "value", true))
+	println(fmt.Sprintf("%v %d", myInt(3), byte('a')))
+
+	// These can't be lowered, but must still work.
+	println(fmt.Sprintf("%v", stringer(1)))
+	println(fmt.Sprintf("%5d|%q", 42, "quoted"))
+
+	err := fmt.Errorf("error %d", 42)
+	println(err.Error())
+	wrapped := fmt.Errorf("wrapped: %w", err)
+	println(wrapped.Error(), errors.Unwrap(wrapped) == err)
+}
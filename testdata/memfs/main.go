@@ -0,0 +1,65 @@
+package main
+
+// Test the basic file operations of the os package. Targets without a
+// filesystem, like polkawasm, provide them with the memfs build tag.
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+)
+
+func main() {
+	wd, err := os.Getwd()
+	check("getwd", err)
+	println("getwd:", filepath.IsAbs(wd))
+
+	dir, err := os.MkdirTemp("", "memfs")
+	check("mkdir", err)
+	name := filepath.Join(dir, "file.txt")
+
+	// Write a file in two parts.
+	f, err := os.Create(name)
+	check("create", err)
+	n, err := f.Write([]byte("hello, "))
+	check("write", err)
+	m, err := f.WriteString("world")
+	check("write", err)
+	println("write:", n+m)
+	check("close", f.Close())
+
+	info, err := os.Stat(name)
+	check("stat", err)
+	println("stat:", info.Name(), info.Size(), info.Mode().IsRegular())
+	info, err = os.Stat(dir)
+	check("stat", err)
+	println("stat dir:", info.IsDir())
+
+	// Read it back, until the end of the file.
+	f, err = os.Open(name)
+	check("open", err)
+	data, err := io.ReadAll(f)
+	check("read", err)
+	println("read:", string(data))
+	_, err = f.Read(make([]byte, 1))
+	println("read at end:", err == io.EOF)
+	info, err = f.Stat()
+	check("stat", err)
+	println("stat open file:", info.Size())
+	check("close", f.Close())
+
+	_, err = os.Open(filepath.Join(dir, "missing.txt"))
+	println("open missing:", os.IsNotExist(err))
+
+	check("remove", os.Remove(name))
+	check("remove", os.Remove(dir))
+	_, err = os.Stat(dir)
+	println("stat removed:", os.IsNotExist(err))
+}
+
+func check(op string, err error) {
+	if err != nil {
+		println(op+":", err.Error())
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,40 @@
+package main
+
+// Overflow the system stack into the redzone of -redzone from an exported
+// function, see TestRedzone. The runtime checks the redzone when the exported
+// function returns to the host.
+
+import "runtime"
+
+//go:wasmimport env call_export
+func callExport()
+
+func init() {
+	// Regular programs don't run main on wasm-unknown targets, but they do run
+	// the package initializers from _initialize. The host calls the "overflow"
+	// export from here.
+	callExport()
+}
+
+//export overflow
+func overflow() {
+	// Use about two thirds of the system stack, with stack frames of a bit
+	// more than 256 bytes.
+	bottom, top := runtime.StackBounds()
+	println("recursed:", recurse(int((top-bottom)/256*2/3)) != 0)
+}
+
+//go:noinline
+func recurse(depth int) byte {
+	var buf [256]byte
+	for i := range buf {
+		buf[i] = byte(depth+i) | 1
+	}
+	if depth > 0 {
+		buf[depth%len(buf)] += recurse(depth - 1)
+	}
+	return buf[depth*7%len(buf)]
+}
+
+func main() {
+}
@@ -0,0 +1,30 @@
+package main
+
+// Call an exported function from an imported function, while another exported
+// function is still running. Without a scheduler the runtime rejects the
+// nested call with a trap, see TestReentry.
+
+//go:wasmimport env call_export
+func callExport(n uint32)
+
+func init() {
+	// Regular programs don't run main on wasm-unknown targets, but they do run
+	// the package initializers from _initialize. The host calls the "first"
+	// export from here.
+	callExport(1)
+}
+
+//export first
+func first() {
+	println("first")
+	callExport(2) // the host calls the "second" export from here
+	println("unreachable: returned from the nested call")
+}
+
+//export second
+func second() {
+	println("unreachable: nested call")
+}
+
+func main() {
+}
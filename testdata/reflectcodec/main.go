@@ -0,0 +1,221 @@
+package main
+
+// Test a reflection-driven encoder and decoder for nested structs, similar to
+// what SCALE codec libraries do: iterate over struct fields, and set values
+// through settable reflect.Values.
+
+import (
+	"encoding/hex"
+	"errors"
+	"reflect"
+)
+
+type Header struct {
+	ParentHash [4]byte
+	Number     uint32
+	Digest     []DigestItem
+}
+
+type DigestItem struct {
+	Engine  [2]byte
+	Payload []byte
+}
+
+type Extrinsic struct {
+	Signed  bool
+	Nonce   uint64
+	Tip     *uint16
+	Call    Call
+	Comment string
+}
+
+type Call struct {
+	Module   uint8
+	Function uint8
+	Args     []int32
+}
+
+type Block struct {
+	Header     Header
+	Extrinsics []Extrinsic
+	unexported int // must be skipped
+}
+
+func encode(buf []byte, v reflect.Value) []byte {
+	switch v.Kind() {
+	case reflect.Bool:
+		if v.Bool() {
+			return append(buf, 1)
+		}
+		return append(buf, 0)
+	case reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n := v.Uint()
+		for i := 0; i < int(v.Type().Size()); i++ {
+			buf = append(buf, byte(n>>(8*i)))
+		}
+		return buf
+	case reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n := uint64(v.Int())
+		for i := 0; i < int(v.Type().Size()); i++ {
+			buf = append(buf, byte(n>>(8*i)))
+		}
+		return buf
+	case reflect.String:
+		buf = encodeLength(buf, v.Len())
+		return append(buf, v.String()...)
+	case reflect.Slice:
+		buf = encodeLength(buf, v.Len())
+		fallthrough
+	case reflect.Array:
+		for i := 0; i < v.Len(); i++ {
+			buf = encode(buf, v.Index(i))
+		}
+		return buf
+	case reflect.Ptr:
+		if v.IsNil() {
+			return append(buf, 0)
+		}
+		return encode(append(buf, 1), v.Elem())
+	case reflect.Struct:
+		for i := 0; i < v.NumField(); i++ {
+			if v.Type().Field(i).PkgPath != "" {
+				continue // unexported
+			}
+			buf = encode(buf, v.Field(i))
+		}
+		return buf
+	default:
+		panic("unsupported kind: " + v.Kind().String())
+	}
+}
+
+func encodeLength(buf []byte, n int) []byte {
+	// Compact encoding, single and two byte modes only.
+	if n < 64 {
+		return append(buf, byte(n<<2))
+	}
+	return append(buf, byte(n<<2)|1, byte(n>>6))
+}
+
+var errShort = errors.New("input too short")
+
+type decoder struct {
+	buf []byte
+	err error
+}
+
+func (d *decoder) byte() byte {
+	if len(d.buf) == 0 {
+		d.err = errShort
+		return 0
+	}
+	b := d.buf[0]
+	d.buf = d.buf[1:]
+	return b
+}
+
+func (d *decoder) length() int {
+	b := d.byte()
+	if b&3 == 0 {
+		return int(b >> 2)
+	}
+	return int(b>>2) | int(d.byte())<<6
+}
+
+func (d *decoder) decode(v reflect.Value) {
+	switch v.Kind() {
+	case reflect.Bool:
+		v.SetBool(d.byte() != 0)
+	case reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		var n uint64
+		for i := 0; i < int(v.Type().Size()); i++ {
+			n |= uint64(d.byte()) << (8 * i)
+		}
+		v.SetUint(n)
+	case reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		var n uint64
+		for i := 0; i < int(v.Type().Size()); i++ {
+			n |= uint64(d.byte()) << (8 * i)
+		}
+		// Sign extend.
+		shift := 64 - 8*v.Type().Size()
+		v.SetInt(int64(n<<shift) >> shift)
+	case reflect.String:
+		n := d.length()
+		s := make([]byte, n)
+		for i := range s {
+			s[i] = d.byte()
+		}
+		v.SetString(string(s))
+	case reflect.Slice:
+		n := d.length()
+		if n == 0 {
+			v.Set(reflect.Zero(v.Type()))
+			return
+		}
+		v.Set(reflect.MakeSlice(v.Type(), n, n))
+		for i := 0; i < n; i++ {
+			d.decode(v.Index(i))
+		}
+	case reflect.Array:
+		for i := 0; i < v.Len(); i++ {
+			d.decode(v.Index(i))
+		}
+	case reflect.Ptr:
+		if d.byte() == 0 {
+			v.Set(reflect.Zero(v.Type()))
+			return
+		}
+		v.Set(reflect.New(v.Type().Elem()))
+		d.decode(v.Elem())
+	case reflect.Struct:
+		for i := 0; i < v.NumField(); i++ {
+			if !v.Field(i).CanSet() {
+				continue // unexported
+			}
+			d.decode(v.Field(i))
+		}
+	default:
+		panic("unsupported kind: " + v.Kind().String())
+	}
+}
+
+func main() {
+	tip := uint16(500)
+	block := Block{
+		Header: Header{
+			ParentHash: [4]byte{0xde, 0xad, 0xbe, 0xef},
+			Number:     1000,
+			Digest: []DigestItem{
+				{Engine: [2]byte{'a', 'b'}, Payload: []byte{1, 2, 3}},
+				{Engine: [2]byte{'c', 'd'}},
+			},
+		},
+		Extrinsics: []Extrinsic{
+			{Signed: true, Nonce: 7, Tip: &tip, Call: Call{Module: 4, Function: 1, Args: []int32{-1, 100}}, Comment: "transfer"},
+			{Call: Call{Module: 0, Function: 2}},
+		},
+		unexported: 5,
+	}
+
+	encoded := encode(nil, reflect.ValueOf(block))
+	println("encoded:", hex.EncodeToString(encoded))
+
+	var decoded Block
+	d := &decoder{buf: encoded}
+	d.decode(reflect.ValueOf(&decoded).Elem())
+	println("remaining:", len(d.buf))
+	println("number:", decoded.Header.Number)
+	println("digest:", len(decoded.Header.Digest), string(decoded.Header.Digest[0].Engine[:]), len(decoded.Header.Digest[0].Payload))
+	println("tip:", *decoded.Extrinsics[0].Tip, decoded.Extrinsics[1].Tip == nil)
+	println("args:", decoded.Extrinsics[0].Call.Args[0], decoded.Extrinsics[0].Call.Args[1])
+	println("comment:", decoded.Extrinsics[0].Comment)
+
+	block.unexported = 0
+	println("equal:", reflect.DeepEqual(block, decoded))
+
+	// Decoding truncated input must fail cleanly.
+	d = &decoder{buf: encoded[:10]}
+	d.decode(reflect.ValueOf(&decoded).Elem())
+	println("truncated:", d.err == errShort)
+}
@@ -0,0 +1,10 @@
+package main
+
+// Programs on wasm-unknown targets only run main as part of a test binary, so
+// polkawasm runs this test as one.
+
+import "testing"
+
+func TestMain(m *testing.M) {
+	main()
+}
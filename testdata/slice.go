@@ -1,6 +1,9 @@
 package main
 
-import "unsafe"
+import (
+	"strings"
+	"unsafe"
+)
 
 type MySlice [32]byte
 
@@ -134,6 +137,18 @@ func main() {
 	}
 	println()
 
+	// strings.Builder may get more capacity than it asks for when it grows,
+	// which must all be usable.
+	var builder strings.Builder
+	builder.WriteString("abc")
+	builder.Grow(100)
+	grown := builder.Cap() >= 103
+	for builder.Len() < builder.Cap() {
+		builder.WriteByte('x')
+	}
+	built := builder.String()
+	println("builder:", grown, built[:4], len(built) == builder.Cap(), built[len(built)-1:])
+
 	// Test conversion from array to slice.
 	slice1 := []int{1, 2, 3, 4}
 	arr1 := (*[4]int)(slice1)
@@ -17,11 +17,38 @@ func testRunesToString(r []rune) {
 	println("string from runes:", string(r))
 }
 
+func testConcat(a, b, c string) {
+	println("concat:", a+b+c)
+	println("concat with empty:", a+""+c)
+	println("concat nested:", a+(b+c)+a)
+	s := a + b
+	println("concat reused:", s+c, s)
+	var m myString = myString(a) + myString(b) + "!"
+	println("concat named:", string(m))
+}
+
+func testBytesToString(b []byte) {
+	m := map[string]int{"foo": 1, "bar": 2}
+	println("bytes to string:", string(b) == "foo", m[string(b)])
+	switch string(b) {
+	case "foo":
+		b[0] = 'b'
+		println("bytes to string switch: foo", string(b))
+	default:
+		println("bytes to string switch: default")
+	}
+	s := string(b)
+	b[0] = 'x'
+	println("bytes to string copy:", s, string(b))
+}
+
 type myString string
 
 func main() {
 	testRangeString()
 	testStringToRunes()
 	testRunesToString([]rune{97, 98, 99, 252, 162, 8364, 66376, 176, 120})
+	testConcat("foo", "bar", "baz")
+	testBytesToString([]byte("foo"))
 	var _ = len([]byte(myString("foobar"))) // issue 1246
 }
@@ -0,0 +1,158 @@
+//go:build tinygo.wasm && gohelpers.math
+
+package runtime_polkawasm
+
+// Accuracy tests for the libm functions of the runtime, for the math group of
+// the go-helpers target property. Run them with:
+//
+//	tinygo test -target=polkawasm ./tests/runtime_polkawasm
+
+import (
+	"math"
+	"testing"
+)
+
+//export exp
+func libm_exp(x float64) float64
+
+//export exp2
+func libm_exp2(x float64) float64
+
+//export log
+func libm_log(x float64) float64
+
+//export expf
+func libm_expf(x float32) float32
+
+//export logf
+func libm_logf(x float32) float32
+
+// The expected values are correctly rounded, the results may be off by one
+// unit in the last place.
+var libmTests = []struct {
+	name   string
+	fn     func(float64) float64
+	mathFn func(float64) float64
+	tests  []struct{ x, want float64 }
+}{
+	{"exp", libm_exp, math.Exp, []struct{ x, want float64 }{
+		{-700.5, 5.980196118639791e-305},
+		{-20.25, 1.6052280551856116e-09},
+		{-1, 0.36787944117144233},
+		{-0.5, 0.6065306597126334},
+		{-1e-10, 0.9999999999},
+		{1e-10, 1.0000000001},
+		{0.1, 1.1051709180756477},
+		{0.5, 1.6487212707001282},
+		{1, 2.718281828459045},
+		{2.5, 12.182493960703473},
+		{10, 22026.465794806718},
+		{88.7, 3.325986980250579e+38},
+		{100, 2.6881171418161356e+43},
+		{709.7, 1.6549840276802644e+308},
+	}},
+	{"exp2", libm_exp2, math.Exp2, []struct{ x, want float64 }{
+		{-1074, 5e-324},
+		{-1022.5, 1.5733648139913585e-308},
+		{-3.7, 0.07694652583405726},
+		{-0.25, 0.8408964152537145},
+		{0.3, 1.2311444133449163},
+		{1, 2},
+		{7.5, 181.01933598375618},
+		{52.1, 4826838566504041.0},
+		{1023.9, 1.6773070034857416e+308},
+	}},
+	{"log", libm_log, math.Log, []struct{ x, want float64 }{
+		{5e-324, -744.4400719213812},
+		{1e-310, -713.8013788281542},
+		{1e-300, -690.7755278982137},
+		{0.001, -6.907755278982137},
+		{0.5, -0.6931471805599453},
+		{0.99999, -1.0000050000287824e-05},
+		{1, 0},
+		{1.00001, 9.999950000398841e-06},
+		{2, 0.6931471805599453},
+		{math.E, 1},
+		{10, 2.302585092994046},
+		{1e10, 23.025850929940457},
+		{1e300, 690.7755278982137},
+		{math.MaxFloat64, 709.782712893384},
+	}},
+}
+
+func TestLibmAccuracy(t *testing.T) {
+	for _, tc := range libmTests {
+		for _, test := range tc.tests {
+			got := tc.fn(test.x)
+			if ulps(got, test.want) > 1 {
+				t.Errorf("%s(%v) = %v, want %v", tc.name, test.x, got, test.want)
+			}
+			// The math package calls the same function, unless LLVM could
+			// compute the result at compile time.
+			if got := tc.mathFn(test.x); ulps(got, test.want) > 1 {
+				t.Errorf("math.%s(%v) = %v, want %v", tc.name, test.x, got, test.want)
+			}
+		}
+	}
+}
+
+func TestLibmSpecialCases(t *testing.T) {
+	inf := math.Inf(1)
+	for _, test := range []struct {
+		name string
+		got  float64
+		want float64
+	}{
+		{"exp(+Inf)", libm_exp(inf), inf},
+		{"exp(-Inf)", libm_exp(-inf), 0},
+		{"exp(710)", libm_exp(710), inf},
+		{"exp(-746)", libm_exp(-746), 0},
+		{"exp(0)", libm_exp(0), 1},
+		{"exp2(1024)", libm_exp2(1024), inf},
+		{"exp2(-1075)", libm_exp2(-1075), 0},
+		{"log(+Inf)", libm_log(inf), inf},
+		{"log(0)", libm_log(0), -inf},
+	} {
+		if test.got != test.want {
+			t.Errorf("%s = %v, want %v", test.name, test.got, test.want)
+		}
+	}
+	for name, got := range map[string]float64{
+		"exp(NaN)":  libm_exp(math.NaN()),
+		"exp2(NaN)": libm_exp2(math.NaN()),
+		"log(NaN)":  libm_log(math.NaN()),
+		"log(-1)":   libm_log(-1),
+	} {
+		if !math.IsNaN(got) {
+			t.Errorf("%s = %v, want NaN", name, got)
+		}
+	}
+}
+
+func TestLibmFloat32(t *testing.T) {
+	for _, x := range []float32{-80.5, -1, 0.1, 1, 2.5, 88.5} {
+		if got, want := libm_expf(x), float32(math.Exp(float64(x))); got != want {
+			t.Errorf("expf(%v) = %v, want %v", x, got, want)
+		}
+	}
+	for _, x := range []float32{1e-40, 0.001, 0.5, 1, 3, 1e38} {
+		if got, want := libm_logf(x), float32(math.Log(float64(x))); got != want {
+			t.Errorf("logf(%v) = %v, want %v", x, got, want)
+		}
+	}
+}
+
+// ulps returns the distance between a and b in units in the last place.
+func ulps(a, b float64) uint64 {
+	if a == b {
+		return 0
+	}
+	x, y := int64(math.Float64bits(a)), int64(math.Float64bits(b))
+	if (x < 0) != (y < 0) {
+		return math.MaxUint64
+	}
+	if x > y {
+		return uint64(x - y)
+	}
+	return uint64(y - x)
+}
@@ -9,10 +9,36 @@ import (
 	"fmt"
 	"go/token"
 	"regexp"
+	"strconv"
 
 	"tinygo.org/x/go-llvm"
 )
 
+// HeapAlloc describes a heap allocation that could not be replaced with a
+// stack allocation, and why.
+type HeapAlloc struct {
+	Pos       token.Position // position of the allocation
+	Function  string         // function containing the allocation
+	Size      uint64         // allocation size in bytes, or 0 if not constant
+	Reason    string         // "dynamic-size", "too-large" or "escapes"
+	EscapesAt token.Position // position where the value escapes, if known
+	Message   string         // human readable explanation
+}
+
+// SizeClass returns the allocation size rounded up to a power of two (with a
+// minimum of 16 bytes), as a rough indication of how costly the allocation is.
+// It returns "dynamic" for allocations without a constant size.
+func (a HeapAlloc) SizeClass() string {
+	if a.Size == 0 {
+		return "dynamic"
+	}
+	class := uint64(16)
+	for class < a.Size {
+		class *= 2
+	}
+	return strconv.FormatUint(class, 10)
+}
+
 // OptimizeAllocs tries to replace heap allocations with stack allocations
 // whenever possible. It relies on the LLVM 'nocapture' flag for interprocedural
 // escape analysis, and within a function looks whether an allocation can escape
@@ -21,6 +47,14 @@ import (
 // heap allocation explanation should be printed (why the object can't be stack
 // allocated).
 func OptimizeAllocs(mod llvm.Module, printAllocs *regexp.Regexp, maxStackAlloc uint64, logger func(token.Position, string)) {
+	OptimizeAllocsReport(mod, printAllocs, maxStackAlloc, func(alloc HeapAlloc) {
+		logger(alloc.Pos, "object allocated on the heap: "+alloc.Message)
+	})
+}
+
+// OptimizeAllocsReport is like OptimizeAllocs, but reports the remaining heap
+// allocations in functions matching printAllocs in a structured form.
+func OptimizeAllocsReport(mod llvm.Module, printAllocs *regexp.Regexp, maxStackAlloc uint64, report func(HeapAlloc)) {
 	allocator := mod.NamedFunction("runtime.alloc")
 	if allocator.IsNil() {
 		// nothing to optimize
@@ -38,7 +72,12 @@ func OptimizeAllocs(mod llvm.Module, printAllocs *regexp.Regexp, maxStackAlloc u
 		if heapalloc.Operand(0).IsAConstantInt().IsNil() {
 			// Do not allocate variable length arrays on the stack.
 			if logAllocs {
-				logAlloc(logger, heapalloc, "size is not constant")
+				report(HeapAlloc{
+					Pos:      getPosition(heapalloc),
+					Function: heapalloc.InstructionParent().Parent().Name(),
+					Reason:   "dynamic-size",
+					Message:  "size is not constant",
+				})
 			}
 			continue
 		}
@@ -47,7 +86,13 @@ func OptimizeAllocs(mod llvm.Module, printAllocs *regexp.Regexp, maxStackAlloc u
 		if size > maxStackAlloc {
 			// The maximum size for a stack allocation.
 			if logAllocs {
-				logAlloc(logger, heapalloc, fmt.Sprintf("object size %d exceeds maximum stack allocation size %d", size, maxStackAlloc))
+				report(HeapAlloc{
+					Pos:      getPosition(heapalloc),
+					Function: heapalloc.InstructionParent().Parent().Name(),
+					Size:     size,
+					Reason:   "too-large",
+					Message:  fmt.Sprintf("object size %d exceeds maximum stack allocation size %d", size, maxStackAlloc),
+				})
 			}
 			continue
 		}
@@ -84,7 +129,14 @@ func OptimizeAllocs(mod llvm.Module, printAllocs *regexp.Regexp, maxStackAlloc u
 				if atPos.Line != 0 {
 					msg = fmt.Sprintf("escapes at line %d", atPos.Line)
 				}
-				logAlloc(logger, heapalloc, msg)
+				report(HeapAlloc{
+					Pos:       getPosition(heapalloc),
+					Function:  heapalloc.InstructionParent().Parent().Name(),
+					Size:      size,
+					Reason:    "escapes",
+					EscapesAt: atPos,
+					Message:   msg,
+				})
 			}
 			continue
 		}
@@ -148,6 +200,30 @@ func valueEscapesAt(value llvm.Value) llvm.Value {
 			if at := valueEscapesAt(use); !at.IsNil() {
 				return at
 			}
+		case llvm.Select:
+			// A select escapes if the selected value escapes.
+			// Note that phi nodes are not handled in the same way: they may
+			// carry the pointer to the next iteration of a loop, where the
+			// stack allocation would be reused.
+			if at := valueEscapesAt(use); !at.IsNil() {
+				return at
+			}
+		case llvm.InsertValue:
+			// Slices and interfaces are aggregates that contain a pointer,
+			// for example from make([]T, n) or when boxing a value in an
+			// interface. The pointer only escapes if the aggregate escapes.
+			if at := valueEscapesAt(use); !at.IsNil() {
+				return at
+			}
+		case llvm.ExtractValue:
+			// Extracting the length or capacity from a slice doesn't let the
+			// pointer escape.
+			if use.Type().TypeKind() == llvm.IntegerTypeKind {
+				continue
+			}
+			if at := valueEscapesAt(use); !at.IsNil() {
+				return at
+			}
 		case llvm.Load:
 			// Load does not escape.
 		case llvm.Store:
@@ -172,9 +248,3 @@ func valueEscapesAt(value llvm.Value) llvm.Value {
 	// Checked all uses, and none let the pointer value escape.
 	return llvm.Value{}
 }
-
-// logAlloc prints a message to stderr explaining why the given object had to be
-// allocated on the heap.
-func logAlloc(logger func(token.Position, string), allocCall llvm.Value, reason string) {
-	logger(getPosition(allocCall), "object allocated on the heap: "+reason)
-}
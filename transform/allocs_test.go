@@ -21,6 +21,25 @@ func TestAllocs(t *testing.T) {
 	})
 }
 
+func TestHeapAllocSizeClass(t *testing.T) {
+	t.Parallel()
+	for _, tc := range []struct {
+		size      uint64
+		sizeClass string
+	}{
+		{0, "dynamic"},
+		{1, "16"},
+		{16, "16"},
+		{17, "32"},
+		{300, "512"},
+	} {
+		alloc := transform.HeapAlloc{Size: tc.size}
+		if sizeClass := alloc.SizeClass(); sizeClass != tc.sizeClass {
+			t.Errorf("size class of %d bytes: expected %s, got %s", tc.size, tc.sizeClass, sizeClass)
+		}
+	}
+}
+
 type allocsTestOutput struct {
 	filename string
 	line     int
@@ -0,0 +1,38 @@
+package transform
+
+import (
+	"tinygo.org/x/go-llvm"
+)
+
+// Opcodes of atomic instructions, which are missing from the LLVM bindings.
+// The values are from llvm-c/Core.h.
+const (
+	opcodeFence         llvm.Opcode = 55
+	opcodeAtomicCmpXchg llvm.Opcode = 56
+	opcodeAtomicRMW     llvm.Opcode = 57
+)
+
+// CheckAtomics returns an error for every atomic instruction in the module. The
+// compiler lowers sync/atomic to plain loads and stores in single-threaded
+// programs, so this verifies that no atomic instruction slipped through some
+// other way, as the host may not support them (like the WebAssembly MVP).
+func CheckAtomics(mod llvm.Module) []error {
+	var errs []error
+	for fn := mod.FirstFunction(); !fn.IsNil(); fn = llvm.NextFunction(fn) {
+		for bb := fn.FirstBasicBlock(); !bb.IsNil(); bb = llvm.NextBasicBlock(bb) {
+			for inst := bb.FirstInstruction(); !inst.IsNil(); inst = llvm.NextInstruction(inst) {
+				atomic := false
+				switch inst.InstructionOpcode() {
+				case opcodeFence, opcodeAtomicCmpXchg, opcodeAtomicRMW:
+					atomic = true
+				case llvm.Load, llvm.Store:
+					atomic = inst.Ordering() != llvm.AtomicOrderingNotAtomic
+				}
+				if atomic {
+					errs = append(errs, errorAt(inst, "atomic operation in single-threaded program, in function "+fn.Name()))
+				}
+			}
+		}
+	}
+	return errs
+}
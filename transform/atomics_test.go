@@ -0,0 +1,43 @@
+package transform_test
+
+import (
+	"testing"
+
+	"github.com/tinygo-org/tinygo/transform"
+	"tinygo.org/x/go-llvm"
+)
+
+func TestCheckAtomics(t *testing.T) {
+	t.Parallel()
+	ctx := llvm.NewContext()
+	defer ctx.Dispose()
+	buf, err := llvm.NewMemoryBufferFromFile("testdata/atomics.ll")
+	if err != nil {
+		t.Fatal("could not read file:", err)
+	}
+	mod, err := ctx.ParseIR(buf)
+	if err != nil {
+		t.Fatal("could not load module:", err)
+	}
+	defer mod.Dispose()
+
+	var functions []string
+	for _, err := range transform.CheckAtomics(mod) {
+		functions = append(functions, err.Error())
+	}
+	expected := []string{
+		"atomic operation in single-threaded program, in function main.atomicAdd",
+		"atomic operation in single-threaded program, in function main.compareAndSwap",
+		"atomic operation in single-threaded program, in function main.load",
+		"atomic operation in single-threaded program, in function main.load",
+		"atomic operation in single-threaded program, in function main.store",
+	}
+	if len(functions) != len(expected) {
+		t.Fatalf("expected %d errors, got %d: %q", len(expected), len(functions), functions)
+	}
+	for i := range expected {
+		if functions[i] != expected[i] {
+			t.Errorf("error %d: expected %q, got %q", i, expected[i], functions[i])
+		}
+	}
+}
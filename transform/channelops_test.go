@@ -0,0 +1,40 @@
+package transform_test
+
+import (
+	"go/token"
+	"reflect"
+	"testing"
+
+	"github.com/tinygo-org/tinygo/transform"
+	"tinygo.org/x/go-llvm"
+)
+
+func TestReportBlockingChannelOps(t *testing.T) {
+	t.Parallel()
+	ctx := llvm.NewContext()
+	defer ctx.Dispose()
+	buf, err := llvm.NewMemoryBufferFromFile("testdata/channelops.ll")
+	if err != nil {
+		t.Fatal("could not read file:", err)
+	}
+	mod, err := ctx.ParseIR(buf)
+	if err != nil {
+		t.Fatal("could not load module:", err)
+	}
+	defer mod.Dispose()
+
+	// Only the operations outside of the standard library are reported, even
+	// if the module path doesn't contain a dot.
+	var reported []string
+	goroot := map[string]bool{"os/signal": true, "runtime": true}
+	transform.ReportBlockingChannelOps(mod, goroot, func(pos token.Position, msg string) {
+		reported = append(reported, pos.String()+": "+msg)
+	})
+	expected := []string{
+		"/app/worker.go:4:5: channel send without a scheduler will deadlock if it cannot complete immediately, consider using runtime/eventqueue instead",
+		"/app/worker.go:8:9: channel receive without a scheduler will deadlock if it cannot complete immediately, consider using runtime/eventqueue instead",
+	}
+	if !reflect.DeepEqual(reported, expected) {
+		t.Errorf("unexpected reports:\nexpected: %q\nactual:   %q", expected, reported)
+	}
+}
@@ -0,0 +1,147 @@
+package transform
+
+import (
+	"strconv"
+
+	"tinygo.org/x/go-llvm"
+)
+
+// SplitColdFunctions moves the functions that are only used on error paths,
+// like panics and the formatting of their messages, to a new module which is
+// returned. This is the -split-cold command line option.
+//
+// The new module is linked after everything else, so that this code ends up
+// at the end of the code section. Hosts that compile the module lazily or in
+// tiers can then skip over it quickly, and it doesn't get in the way of the
+// code that is actually executed.
+//
+// A function is considered cold when it is only called from a basic block that
+// ends in an unreachable instruction (which is how a panic call ends), or from
+// other cold functions. Cold functions are also marked as cold and noinline,
+// so that they won't be inlined back into their callers during LTO.
+//
+// Because the two modules refer to each other, all internal globals and
+// functions are changed to hidden external symbols. They are internalized
+// again during LTO.
+func SplitColdFunctions(mod llvm.Module) (llvm.Module, error) {
+	ctx := mod.Context()
+	cold := findColdFunctions(mod)
+
+	// Make internal symbols visible to the other module.
+	unnamed := 0
+	exposeSymbol := func(global llvm.Value) {
+		switch global.Linkage() {
+		case llvm.InternalLinkage, llvm.PrivateLinkage:
+		default:
+			return
+		}
+		if global.Name() == "" {
+			global.SetName("tinygo.cold.unnamed." + strconv.Itoa(unnamed))
+			unnamed++
+		}
+		global.SetLinkage(llvm.ExternalLinkage)
+		global.SetVisibility(llvm.HiddenVisibility)
+	}
+	for global := mod.FirstGlobal(); !global.IsNil(); global = llvm.NextGlobal(global) {
+		if !global.IsDeclaration() {
+			exposeSymbol(global)
+		}
+	}
+	for fn := mod.FirstFunction(); !fn.IsNil(); fn = llvm.NextFunction(fn) {
+		if !fn.IsDeclaration() {
+			exposeSymbol(fn)
+		}
+	}
+	coldAttr := ctx.CreateEnumAttribute(llvm.AttributeKindID("cold"), 0)
+	noinlineAttr := ctx.CreateEnumAttribute(llvm.AttributeKindID("noinline"), 0)
+	for _, fn := range cold {
+		fn.AddFunctionAttr(coldAttr)
+		fn.AddFunctionAttr(noinlineAttr)
+	}
+
+	// Make a copy of the module, with the same symbol names.
+	coldMod, err := ctx.ParseIR(llvm.WriteBitcodeToMemoryBuffer(mod))
+	if err != nil {
+		return llvm.Module{}, err
+	}
+
+	// Only keep the declarations of the cold functions in the original module.
+	isCold := make(map[string]bool)
+	for _, fn := range cold {
+		isCold[fn.Name()] = true
+		newFn := replaceFunctionBody(fn)
+		newFn.SetVisibility(llvm.HiddenVisibility)
+		newFn.AddFunctionAttr(coldAttr)
+	}
+
+	// And only keep the cold functions in the new module. Everything else is
+	// declared, so that it refers to the symbols of the original module.
+	var functions, globals []llvm.Value
+	for fn := coldMod.FirstFunction(); !fn.IsNil(); fn = llvm.NextFunction(fn) {
+		if !fn.IsDeclaration() && !isCold[fn.Name()] {
+			functions = append(functions, fn)
+		}
+	}
+	for global := coldMod.FirstGlobal(); !global.IsNil(); global = llvm.NextGlobal(global) {
+		if !global.IsDeclaration() {
+			globals = append(globals, global)
+		}
+	}
+	for _, fn := range functions {
+		visibility := fn.Visibility()
+		newFn := replaceFunctionBody(fn)
+		newFn.SetVisibility(visibility)
+	}
+	for _, global := range globals {
+		if global.Linkage() == llvm.AppendingLinkage {
+			// Lists like llvm.used are already part of the original module.
+			global.EraseFromParentAsGlobal()
+			continue
+		}
+		global.SetInitializer(llvm.Value{})
+		global.SetLinkage(llvm.ExternalLinkage)
+	}
+
+	return coldMod, nil
+}
+
+// findColdFunctions returns the internal functions that are only called on
+// error paths, see SplitColdFunctions.
+func findColdFunctions(mod llvm.Module) []llvm.Value {
+	isCold := make(map[llvm.Value]bool)
+	var cold []llvm.Value
+	for changed := true; changed; {
+		changed = false
+		for fn := mod.FirstFunction(); !fn.IsNil(); fn = llvm.NextFunction(fn) {
+			if isCold[fn] || fn.IsDeclaration() || fn.Linkage() != llvm.InternalLinkage {
+				continue
+			}
+			uses := getUses(fn)
+			if len(uses) == 0 {
+				continue
+			}
+			onlyCold := true
+			for _, use := range uses {
+				if use.IsACallInst().IsNil() || use.CalledValue() != fn {
+					// Not a call, for example a function pointer.
+					onlyCold = false
+					break
+				}
+				block := use.InstructionParent()
+				if isCold[block.Parent()] {
+					continue
+				}
+				if block.LastInstruction().IsAUnreachableInst().IsNil() {
+					onlyCold = false
+					break
+				}
+			}
+			if onlyCold {
+				isCold[fn] = true
+				cold = append(cold, fn)
+				changed = true
+			}
+		}
+	}
+	return cold
+}
@@ -0,0 +1,35 @@
+package transform_test
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/tinygo-org/tinygo/transform"
+	"tinygo.org/x/go-llvm"
+)
+
+func TestSplitColdFunctions(t *testing.T) {
+	t.Parallel()
+	testTransform(t, "testdata/coldsplit", func(mod llvm.Module) {
+		coldMod, err := transform.SplitColdFunctions(mod)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer coldMod.Dispose()
+		if err := llvm.VerifyModule(coldMod, llvm.PrintMessageAction); err != nil {
+			t.Error("IR verification of the cold module failed")
+		}
+
+		// The cold functions must be defined in the new module.
+		var defined []string
+		for fn := coldMod.FirstFunction(); !fn.IsNil(); fn = llvm.NextFunction(fn) {
+			if !fn.IsDeclaration() {
+				defined = append(defined, fn.Name())
+			}
+		}
+		expected := []string{"runtime.printPanicValue", "runtime.printPanic", "runtime._panic"}
+		if !reflect.DeepEqual(defined, expected) {
+			t.Errorf("expected cold functions %v, got %v", expected, defined)
+		}
+	})
+}
@@ -0,0 +1,141 @@
+package transform
+
+import (
+	"path/filepath"
+
+	"tinygo.org/x/go-llvm"
+)
+
+// CoverageBlock is a range of source code with a single coverage counter, in
+// the same form as a block in a Go coverage profile.
+type CoverageBlock struct {
+	File      string // file name as used in the coverage profile
+	StartLine uint32
+	StartCol  uint32
+	EndLine   uint32
+	EndCol    uint32
+	NumStmts  uint32
+}
+
+// InstrumentCoverage adds a 32-bit counter to every basic block with source
+// locations in a file for which fileName returns true, for -cover. Basic
+// blocks that cover exactly the same source range share a counter. The
+// counters are stored in a single array in the tinygo_coverage section, which
+// is made available to the runtime through the runtime.coverageCounters slice.
+// The returned blocks describe each counter, in order.
+//
+// The fileName function gets the full path of a source file, and returns the
+// name to use for it in the coverage profile.
+func InstrumentCoverage(mod llvm.Module, fileName func(path string) (string, bool)) []CoverageBlock {
+	coverageCounters := mod.NamedGlobal("runtime.coverageCounters")
+	if coverageCounters.IsNil() {
+		// No coverage support in the runtime.
+		return nil
+	}
+
+	// Find the source range of all basic blocks.
+	var blocks []CoverageBlock
+	blockIndices := make(map[CoverageBlock]int)
+	var positions []llvm.Value
+	var counterIndices []int
+	names := make(map[llvm.Metadata]string)
+	for fn := mod.FirstFunction(); !fn.IsNil(); fn = llvm.NextFunction(fn) {
+		if fn.IsDeclaration() {
+			continue
+		}
+		for bb := fn.FirstBasicBlock(); !bb.IsNil(); bb = llvm.NextBasicBlock(bb) {
+			var block CoverageBlock
+			lines := make(map[uint32]struct{})
+			for inst := bb.FirstInstruction(); !inst.IsNil(); inst = llvm.NextInstruction(inst) {
+				loc := inst.InstructionDebugLoc()
+				if loc.IsNil() || loc.LocationLine() == 0 || !loc.LocationInlinedAt().IsNil() {
+					continue
+				}
+				file := loc.LocationScope().ScopeFile()
+				name, ok := names[file]
+				if !ok {
+					path := file.FileFilename()
+					if !filepath.IsAbs(path) {
+						path = filepath.Join(file.FileDirectory(), path)
+					}
+					name, _ = fileName(path)
+					names[file] = name
+				}
+				if name == "" || block.File != "" && block.File != name {
+					continue
+				}
+				line, col := uint32(loc.LocationLine()), uint32(loc.LocationColumn())
+				if col == 0 {
+					col = 1
+				}
+				if block.File == "" {
+					block = CoverageBlock{File: name, StartLine: line, StartCol: col, EndLine: line, EndCol: col}
+				}
+				if line < block.StartLine || line == block.StartLine && col < block.StartCol {
+					block.StartLine, block.StartCol = line, col
+				}
+				if line > block.EndLine || line == block.EndLine && col > block.EndCol {
+					block.EndLine, block.EndCol = line, col
+				}
+				lines[line] = struct{}{}
+			}
+			if block.File == "" {
+				continue
+			}
+			block.EndCol++ // the end column is exclusive
+			block.NumStmts = uint32(len(lines))
+
+			index, ok := blockIndices[block]
+			if !ok {
+				index = len(blocks)
+				blockIndices[block] = index
+				blocks = append(blocks, block)
+			}
+
+			// Insert the counter after the phi nodes, and after the allocas
+			// at the start of the entry block so that they stay together.
+			inst := bb.FirstInstruction()
+			for !inst.IsAPHINode().IsNil() || !inst.IsAAllocaInst().IsNil() {
+				inst = llvm.NextInstruction(inst)
+			}
+			positions = append(positions, inst)
+			counterIndices = append(counterIndices, index)
+		}
+	}
+	if len(blocks) == 0 {
+		return nil
+	}
+
+	ctx := mod.Context()
+	builder := ctx.NewBuilder()
+	defer builder.Dispose()
+	i32 := ctx.Int32Type()
+	countersType := llvm.ArrayType(i32, len(blocks))
+	counters := llvm.AddGlobal(mod, countersType, "tinygo_coverage_counters")
+	counters.SetInitializer(llvm.ConstNull(countersType))
+	counters.SetLinkage(llvm.InternalLinkage)
+	counters.SetSection("tinygo_coverage")
+	counters.SetAlignment(4)
+
+	for i, inst := range positions {
+		builder.SetInsertPointBefore(inst)
+		counter := builder.CreateInBoundsGEP(countersType, counters, []llvm.Value{
+			llvm.ConstInt(i32, 0, false),
+			llvm.ConstInt(i32, uint64(counterIndices[i]), false),
+		}, "")
+		value := builder.CreateLoad(i32, counter, "")
+		value = builder.CreateAdd(value, llvm.ConstInt(i32, 1, false), "")
+		builder.CreateStore(value, counter)
+	}
+
+	// Point runtime.coverageCounters to the counters.
+	sliceType := coverageCounters.GlobalValueType()
+	uintptrType := sliceType.StructElementTypes()[1]
+	length := llvm.ConstInt(uintptrType, uint64(len(blocks)), false)
+	coverageCounters.SetInitializer(llvm.ConstNamedStruct(sliceType, []llvm.Value{
+		llvm.ConstBitCast(counters, sliceType.StructElementTypes()[0]),
+		length,
+		length,
+	}))
+	return blocks
+}
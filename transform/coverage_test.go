@@ -0,0 +1,29 @@
+package transform_test
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/tinygo-org/tinygo/transform"
+	"tinygo.org/x/go-llvm"
+)
+
+func TestInstrumentCoverage(t *testing.T) {
+	t.Parallel()
+	testTransform(t, "testdata/coverage", func(mod llvm.Module) {
+		blocks := transform.InstrumentCoverage(mod, func(path string) (string, bool) {
+			if path == "/src/example/main.go" {
+				return "example.com/main.go", true
+			}
+			return "", false
+		})
+		expected := []transform.CoverageBlock{
+			{File: "example.com/main.go", StartLine: 4, StartCol: 7, EndLine: 4, EndCol: 8, NumStmts: 1},
+			{File: "example.com/main.go", StartLine: 5, StartCol: 3, EndLine: 5, EndCol: 11, NumStmts: 1},
+			{File: "example.com/main.go", StartLine: 7, StartCol: 2, EndLine: 7, EndCol: 3, NumStmts: 1},
+		}
+		if !reflect.DeepEqual(blocks, expected) {
+			t.Errorf("unexpected blocks: %+v", blocks)
+		}
+	})
+}
@@ -0,0 +1,145 @@
+package transform
+
+import (
+	"errors"
+
+	"github.com/tinygo-org/tinygo/compiler/llvmutil"
+	"tinygo.org/x/go-llvm"
+)
+
+// AddExportPrologues wraps every function exported from a WebAssembly module
+// in a small function that calls runtime.wasmExportEnter before calling the
+// original function and runtime.wasmExportExit after it returns. The runtime
+// uses this to record the top of the system stack for the GC, to initialize
+// the program if the host didn't call _initialize first, and to detect calls
+// from the host while another exported function is still running.
+//
+// The stack pointer is read in the wrapper instead of in the exported
+// function itself, because the wrapper doesn't need a stack frame: the value
+// it reads is exactly the stack pointer that the host passed in, above all
+// the stack frames of the program. The original function is renamed with a
+// $body suffix and marked noinline so that it stays this way. Calls from
+// within the program still go to the original function directly.
+//
+// The _start and _initialize entry points are not wrapped, as they already
+// initialize the program. Neither is __runtime_selfcheck (see -selfcheck), which
+// must report a corrupted runtime state instead of trapping on it, nor are the
+// functions with which workers of the wasm-threads target register themselves
+// before they may call other exported functions. The exports with which the
+// host inspects the program after it trapped or exited aren't wrapped either,
+// see exportPrologueSkip.
+//
+// With crashDump set (the -crash-dump flag), the wrapper also passes the export
+// name to runtime.crashDumpExport, so that a crash dump can tell which exported
+// function the host called.
+func AddExportPrologues(mod llvm.Module, crashDump bool) error {
+	var exports []llvm.Value
+	for fn := mod.FirstFunction(); !fn.IsNil(); fn = llvm.NextFunction(fn) {
+		if fn.IsDeclaration() {
+			continue
+		}
+		attr := fn.GetStringAttributeAtIndex(-1, "wasm-export-name")
+		if attr.IsNil() {
+			continue
+		}
+		if exportPrologueSkip[attr.GetStringValue()] {
+			continue
+		}
+		exports = append(exports, fn)
+	}
+	if len(exports) == 0 {
+		return nil
+	}
+
+	exportEnter := mod.NamedFunction("runtime.wasmExportEnter")
+	if exportEnter.IsNil() {
+		return errors.New("runtime.wasmExportEnter is missing")
+	}
+	exportExit := mod.NamedFunction("runtime.wasmExportExit")
+	if exportExit.IsNil() {
+		return errors.New("runtime.wasmExportExit is missing")
+	}
+	uintptrType := exportEnter.GlobalValueType().ParamTypes()[0]
+	var crashDumpExport llvm.Value
+	if crashDump {
+		crashDumpExport = mod.NamedFunction("runtime.crashDumpExport")
+		if crashDumpExport.IsNil() {
+			return errors.New("runtime.crashDumpExport is missing")
+		}
+	}
+	getStackPointer := mod.NamedFunction("tinygo_getCurrentStackPointer")
+	if getStackPointer.IsNil() {
+		getStackPointer = llvm.AddFunction(mod, "tinygo_getCurrentStackPointer", llvm.FunctionType(uintptrType, nil, false))
+	}
+
+	ctx := mod.Context()
+	builder := ctx.NewBuilder()
+	defer builder.Dispose()
+	context := llvm.Undef(llvm.PointerType(ctx.Int8Type(), 0))
+	noinline := ctx.CreateEnumAttribute(llvm.AttributeKindID("noinline"), 0)
+
+	var wrappers []llvm.Value
+	for _, fn := range exports {
+		name := fn.Name()
+		exportName := fn.GetStringAttributeAtIndex(-1, "wasm-export-name")
+		fn.SetName(name + "$body")
+		fn.RemoveStringAttributeAtIndex(-1, "wasm-export-name")
+		fn.AddFunctionAttr(noinline)
+
+		wrapper := llvm.AddFunction(mod, name, fn.GlobalValueType())
+		wrapper.SetLinkage(fn.Linkage())
+		wrapper.SetVisibility(fn.Visibility())
+		wrapper.AddFunctionAttr(exportName)
+		params := wrapper.Params()
+		for i, param := range fn.Params() {
+			params[i].SetName(param.Name())
+		}
+
+		builder.SetInsertPointAtEnd(ctx.AddBasicBlock(wrapper, "entry"))
+		sp := builder.CreateCall(getStackPointer.GlobalValueType(), getStackPointer, nil, "")
+		builder.CreateCall(exportEnter.GlobalValueType(), exportEnter, []llvm.Value{sp, context}, "")
+		if crashDump {
+			nameValue := ctx.ConstString(exportName.GetStringValue(), false)
+			nameGlobal := llvm.AddGlobal(mod, nameValue.Type(), name+"$exportname")
+			nameGlobal.SetInitializer(nameValue)
+			nameGlobal.SetLinkage(llvm.PrivateLinkage)
+			nameGlobal.SetGlobalConstant(true)
+			nameGlobal.SetUnnamedAddr(true)
+			nameGlobal.SetAlignment(1)
+			nameLen := llvm.ConstInt(uintptrType, uint64(len(exportName.GetStringValue())), false)
+			builder.CreateCall(crashDumpExport.GlobalValueType(), crashDumpExport, []llvm.Value{nameGlobal, nameLen, context}, "")
+		}
+		result := builder.CreateCall(fn.GlobalValueType(), fn, params, "")
+		builder.CreateCall(exportExit.GlobalValueType(), exportExit, []llvm.Value{context}, "")
+		if fn.GlobalValueType().ReturnType().TypeKind() == llvm.VoidTypeKind {
+			builder.CreateRetVoid()
+		} else {
+			builder.CreateRet(result)
+		}
+		wrappers = append(wrappers, wrapper)
+	}
+
+	// Exported functions must be marked as used, see the compiler.
+	llvmutil.AppendToGlobal(mod, "llvm.used", wrappers...)
+	return nil
+}
+
+// exportPrologueSkip lists the exported functions that AddExportPrologues
+// doesn't wrap.
+var exportPrologueSkip = map[string]bool{
+	"_start":               true,
+	"_initialize":          true,
+	"__runtime_selfcheck":  true,
+	"tinygo_thread_attach": true,
+	"tinygo_thread_detach": true,
+
+	// Read by the host after a trap or os.Exit, when the runtime may be in
+	// any state. They must not trap themselves, or initialize the program.
+	"tinygo_exit_code":             true,
+	"tinygo_trap_info":             true,
+	"tinygo_crash_dump":            true,
+	"tinygo_memstats":              true,
+	"tinygo_host_call_stats":       true,
+	"tinygo_host_call_stats_reset": true,
+	"tinygo_coverage":              true,
+}
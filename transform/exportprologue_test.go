@@ -0,0 +1,28 @@
+package transform_test
+
+import (
+	"testing"
+
+	"github.com/tinygo-org/tinygo/transform"
+	"tinygo.org/x/go-llvm"
+)
+
+func TestAddExportPrologues(t *testing.T) {
+	t.Parallel()
+	testTransform(t, "testdata/exportprologue", func(mod llvm.Module) {
+		err := transform.AddExportPrologues(mod, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+	})
+}
+
+func TestAddExportProloguesCrashDump(t *testing.T) {
+	t.Parallel()
+	testTransform(t, "testdata/exportprologue-crashdump", func(mod llvm.Module) {
+		err := transform.AddExportPrologues(mod, true)
+		if err != nil {
+			t.Fatal(err)
+		}
+	})
+}
@@ -0,0 +1,86 @@
+package transform
+
+import (
+	"fmt"
+
+	"github.com/tinygo-org/tinygo/compiler/llvmutil"
+	"tinygo.org/x/go-llvm"
+)
+
+// RestrictExports removes the export of all //export functions of a
+// WebAssembly module except for the given ones and the _start and _initialize
+// entry points. This is the -exports= command line option.
+//
+// Normally every exported function is a root for dead code elimination, even
+// if the host never calls it, like the tinygo_* functions that the runtime
+// exports for the built-in host. With an exact list of exports, the linker
+// can remove these functions and everything only they use.
+//
+// It is an error if one of the given names is not exported, as that is most
+// likely a typo that would result in a module the host can't use.
+func RestrictExports(mod llvm.Module, names []string) error {
+	keep := map[string]bool{
+		"_start":      true,
+		"_initialize": true,
+	}
+	for _, name := range names {
+		keep[name] = true
+	}
+
+	var exports []llvm.Value
+	for fn := mod.FirstFunction(); !fn.IsNil(); fn = llvm.NextFunction(fn) {
+		if !fn.IsDeclaration() && !fn.GetStringAttributeAtIndex(-1, "wasm-export-name").IsNil() {
+			exports = append(exports, fn)
+		}
+	}
+	found := make(map[string]bool)
+	for _, fn := range exports {
+		found[fn.GetStringAttributeAtIndex(-1, "wasm-export-name").GetStringValue()] = true
+	}
+	for _, name := range names {
+		if !found[name] {
+			return fmt.Errorf("-exports: function %s is not exported", name)
+		}
+	}
+
+	unexported := make(map[llvm.Value]bool)
+	for _, fn := range exports {
+		if keep[fn.GetStringAttributeAtIndex(-1, "wasm-export-name").GetStringValue()] {
+			continue
+		}
+		fn.RemoveStringAttributeAtIndex(-1, "wasm-export-name")
+		if fn.Linkage() == llvm.ExternalLinkage {
+			// The function may still be called from C code, so keep the
+			// symbol but make sure it is internalized during LTO.
+			fn.SetVisibility(llvm.HiddenVisibility)
+		}
+		unexported[fn] = true
+	}
+
+	// Exported functions were added to llvm.used by the compiler to keep them
+	// alive, see there.
+	removeFromUsed(mod, unexported)
+	return nil
+}
+
+// removeFromUsed removes the given functions and globals from llvm.used.
+func removeFromUsed(mod llvm.Module, values map[llvm.Value]bool) {
+	used := mod.NamedGlobal("llvm.used")
+	if used.IsNil() || len(values) == 0 {
+		return
+	}
+	builder := mod.Context().NewBuilder()
+	defer builder.Dispose()
+	initializer := used.Initializer()
+	var kept []llvm.Value
+	for i := 0; i < initializer.Type().ArrayLength(); i++ {
+		value := builder.CreateExtractValue(initializer, i, "")
+		if !values[stripPointerCasts(value)] {
+			kept = append(kept, value)
+		}
+	}
+	used.EraseFromParentAsGlobal()
+	if len(kept) != 0 {
+		llvmutil.AppendToGlobal(mod, "llvm.used", kept...)
+	}
+}
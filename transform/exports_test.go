@@ -0,0 +1,24 @@
+package transform_test
+
+import (
+	"testing"
+
+	"github.com/tinygo-org/tinygo/transform"
+	"tinygo.org/x/go-llvm"
+)
+
+func TestRestrictExports(t *testing.T) {
+	t.Parallel()
+	testTransform(t, "testdata/exports", func(mod llvm.Module) {
+		// A typo must be reported, without changing the module.
+		err := transform.RestrictExports(mod, []string{"run", "rnu"})
+		if err == nil || err.Error() != "-exports: function rnu is not exported" {
+			t.Errorf("unexpected error: %v", err)
+		}
+
+		err = transform.RestrictExports(mod, []string{"run"})
+		if err != nil {
+			t.Fatal(err)
+		}
+	})
+}
@@ -0,0 +1,79 @@
+package transform
+
+import (
+	"strings"
+
+	"tinygo.org/x/go-llvm"
+)
+
+// InstrumentFuzzCounters adds an 8-bit counter to every basic block of the
+// program, for coverage-guided fuzzing with -fuzz. The counters are stored in
+// a single array, which is made available to the testing package through the
+// testing.fuzzCounters slice so that it can pass them to the fuzzing host.
+// The counters wrap around on overflow, which is fine for detecting new
+// coverage.
+//
+// The runtime and the testing package are not instrumented, as they're
+// running the fuzzer and don't say anything about the code being tested.
+func InstrumentFuzzCounters(mod llvm.Module) {
+	fuzzCounters := mod.NamedGlobal("testing.fuzzCounters")
+	if fuzzCounters.IsNil() {
+		// No fuzz support in the testing package.
+		return
+	}
+
+	// Find all basic blocks that need a counter, and where to put it.
+	var positions []llvm.Value
+	for fn := mod.FirstFunction(); !fn.IsNil(); fn = llvm.NextFunction(fn) {
+		if fn.IsDeclaration() {
+			continue
+		}
+		name := fn.Name()
+		if strings.HasPrefix(name, "runtime.") || strings.HasPrefix(name, "internal/task.") || strings.HasPrefix(name, "testing.") {
+			continue
+		}
+		for bb := fn.FirstBasicBlock(); !bb.IsNil(); bb = llvm.NextBasicBlock(bb) {
+			// Insert the counter after the phi nodes, and after the allocas
+			// at the start of the entry block so that they stay together.
+			inst := bb.FirstInstruction()
+			for !inst.IsAPHINode().IsNil() || !inst.IsAAllocaInst().IsNil() {
+				inst = llvm.NextInstruction(inst)
+			}
+			positions = append(positions, inst)
+		}
+	}
+	if len(positions) == 0 {
+		return
+	}
+
+	ctx := mod.Context()
+	builder := ctx.NewBuilder()
+	defer builder.Dispose()
+	i8 := ctx.Int8Type()
+	i32 := ctx.Int32Type()
+	countersType := llvm.ArrayType(i8, len(positions))
+	counters := llvm.AddGlobal(mod, countersType, "tinygo_fuzz_counters")
+	counters.SetInitializer(llvm.ConstNull(countersType))
+	counters.SetLinkage(llvm.InternalLinkage)
+
+	for i, inst := range positions {
+		builder.SetInsertPointBefore(inst)
+		counter := builder.CreateInBoundsGEP(countersType, counters, []llvm.Value{
+			llvm.ConstInt(i32, 0, false),
+			llvm.ConstInt(i32, uint64(i), false),
+		}, "")
+		value := builder.CreateLoad(i8, counter, "")
+		value = builder.CreateAdd(value, llvm.ConstInt(i8, 1, false), "")
+		builder.CreateStore(value, counter)
+	}
+
+	// Point testing.fuzzCounters to the counters.
+	sliceType := fuzzCounters.GlobalValueType()
+	uintptrType := sliceType.StructElementTypes()[1]
+	length := llvm.ConstInt(uintptrType, uint64(len(positions)), false)
+	fuzzCounters.SetInitializer(llvm.ConstNamedStruct(sliceType, []llvm.Value{
+		llvm.ConstBitCast(counters, sliceType.StructElementTypes()[0]),
+		length,
+		length,
+	}))
+}
@@ -0,0 +1,109 @@
+package transform
+
+import (
+	"errors"
+
+	"tinygo.org/x/go-llvm"
+)
+
+// InstrumentHostCalls inserts a call to runtime.hostCallRecord after every call
+// to an imported WebAssembly function, for the -host-call-stats option. Every
+// imported function gets its own ID, which is passed together with the import
+// name (as module.name) and the number of bytes passed in the call.
+//
+// The bytes are the size of the parameters and the result. An i64 is counted
+// as a Polkadot pointer-size, which has the length of the data in its high 32
+// bits, as that's how Polkadot hosts exchange data with the program.
+//
+// Like InstrumentInits, this must be run after interp, so that calls made by
+// package initializers at compile time aren't counted.
+func InstrumentHostCalls(mod llvm.Module) error {
+	record := mod.NamedFunction("runtime.hostCallRecord")
+	if record.IsNil() {
+		return errors.New("-host-call-stats: runtime.hostCallRecord is missing")
+	}
+
+	ctx := mod.Context()
+	builder := ctx.NewBuilder()
+	defer builder.Dispose()
+	targetData := llvm.NewTargetData(mod.DataLayout())
+	defer targetData.Dispose()
+	context := llvm.Undef(llvm.PointerType(ctx.Int8Type(), 0))
+	i32Type := ctx.Int32Type()
+	uintptrType := record.GlobalValueType().ParamTypes()[2]
+
+	// size returns the number of bytes passed to or from the host in a value.
+	size := func(value llvm.Value) llvm.Value {
+		typ := value.Type()
+		if typ.TypeKind() == llvm.IntegerTypeKind && typ.IntTypeWidth() == 64 {
+			length := builder.CreateLShr(value, llvm.ConstInt(typ, 32, false), "")
+			return builder.CreateTrunc(length, i32Type, "")
+		}
+		return llvm.ConstInt(i32Type, targetData.TypeAllocSize(typ), false)
+	}
+
+	id := uint64(0)
+	for fn := mod.FirstFunction(); !fn.IsNil(); fn = llvm.NextFunction(fn) {
+		if !fn.IsDeclaration() {
+			continue
+		}
+		importName := fn.GetStringAttributeAtIndex(-1, "wasm-import-name")
+		if importName.IsNil() {
+			continue
+		}
+		var calls []llvm.Value
+		for use := fn.FirstUse(); !use.IsNil(); use = use.NextUse() {
+			call := use.User()
+			if !call.IsACallInst().IsNil() && call.CalledValue() == fn {
+				calls = append(calls, call)
+			}
+		}
+		if len(calls) == 0 {
+			continue
+		}
+
+		name := "env"
+		if attr := fn.GetStringAttributeAtIndex(-1, "wasm-import-module"); !attr.IsNil() {
+			name = attr.GetStringValue()
+		}
+		name += "." + importName.GetStringValue()
+		value := ctx.ConstString(name, false)
+		global := llvm.AddGlobal(mod, value.Type(), fn.Name()+"$importname")
+		global.SetInitializer(value)
+		global.SetLinkage(llvm.InternalLinkage)
+		global.SetGlobalConstant(true)
+		global.SetUnnamedAddr(true)
+		global.SetAlignment(1)
+
+		for _, call := range calls {
+			builder.SetInsertPointBefore(llvm.NextInstruction(call))
+			var values []llvm.Value
+			for i := 0; i < call.OperandsCount()-1; i++ {
+				values = append(values, call.Operand(i))
+			}
+			if call.Type().TypeKind() != llvm.VoidTypeKind {
+				values = append(values, call)
+			}
+			bytes := llvm.ConstInt(i32Type, 0, false)
+			for i, value := range values {
+				if i == 0 {
+					bytes = size(value)
+				} else {
+					bytes = builder.CreateAdd(bytes, size(value), "")
+				}
+			}
+			recordCall := builder.CreateCall(record.GlobalValueType(), record, []llvm.Value{
+				llvm.ConstInt(i32Type, id, false),
+				global,
+				llvm.ConstInt(uintptrType, uint64(len(name)), false),
+				bytes,
+				context,
+			}, "")
+			if loc := call.InstructionDebugLoc(); !loc.IsNil() {
+				recordCall.InstructionSetDebugLoc(loc)
+			}
+		}
+		id++
+	}
+	return nil
+}
@@ -0,0 +1,18 @@
+package transform_test
+
+import (
+	"testing"
+
+	"github.com/tinygo-org/tinygo/transform"
+	"tinygo.org/x/go-llvm"
+)
+
+func TestInstrumentHostCalls(t *testing.T) {
+	t.Parallel()
+	testTransform(t, "testdata/hostcallstats", func(mod llvm.Module) {
+		err := transform.InstrumentHostCalls(mod)
+		if err != nil {
+			t.Fatal(err)
+		}
+	})
+}
@@ -0,0 +1,98 @@
+package transform
+
+import (
+	"fmt"
+
+	"tinygo.org/x/go-llvm"
+)
+
+// hostCryptoFunctions lists the signature verification functions that can be
+// replaced with a call to a host function, together with the scheme name used
+// on the command line. All of these functions have the signature
+// func(publicKey, message, sig []byte) bool.
+var hostCryptoFunctions = []struct {
+	scheme   string
+	function string
+	host     string
+	keySize  uint64
+	sigSize  uint64
+}{
+	{"ed25519", "crypto/ed25519.Verify", "ext_crypto_ed25519_verify_version_1", 32, 64},
+}
+
+// ReplaceCryptoFunctions replaces the body of the signature verification
+// functions for the given schemes with a call to the equivalent Polkadot host
+// function (ext_crypto_*_verify), imported from the "env" module. This is the
+// -host-crypto= command line option. The Go implementation is still used on
+// targets where this option isn't used.
+//
+// Signatures of the wrong length are rejected without calling the host, like
+// the Go implementation does. Public keys of the wrong length trap, where the
+// Go implementation would panic.
+//
+// This must be run before the functions are inlined into their callers.
+func ReplaceCryptoFunctions(mod llvm.Module, schemes []string) error {
+	ctx := mod.Context()
+	builder := ctx.NewBuilder()
+	defer builder.Dispose()
+	i64Type := ctx.Int64Type()
+
+	for _, cf := range hostCryptoFunctions {
+		if !isInList(schemes, cf.scheme) {
+			continue
+		}
+		fn := mod.NamedFunction(cf.function)
+		if fn.IsNil() || fn.IsDeclaration() {
+			continue // verification function not used
+		}
+
+		// Check the signature: (ptr, len, cap) * 3 + context -> bool.
+		fnType := fn.GlobalValueType()
+		params := fnType.ParamTypes()
+		if len(params) != 10 || fnType.ReturnType() != ctx.Int1Type() || params[1].TypeKind() != llvm.IntegerTypeKind || params[1].IntTypeWidth() != 32 {
+			return fmt.Errorf("cannot replace %s with a host function: unexpected signature", cf.function)
+		}
+		uintptrType := params[1]
+		hostFn := getHostFunction(mod, cf.host, llvm.FunctionType(ctx.Int32Type(), []llvm.Type{uintptrType, i64Type, uintptrType}, false))
+
+		// The host function takes the signature and public key as plain
+		// pointers, so the lengths have to be checked first.
+		newFn := replaceFunctionBody(fn)
+		key, keyLen := newFn.Param(0), newFn.Param(1)
+		msg, msgLen := newFn.Param(3), newFn.Param(4)
+		sig, sigLen := newFn.Param(6), newFn.Param(7)
+		entry := ctx.AddBasicBlock(newFn, "entry")
+		badKey := ctx.AddBasicBlock(newFn, "key.invalid")
+		checkSig := ctx.AddBasicBlock(newFn, "key.valid")
+		verify := ctx.AddBasicBlock(newFn, "sig.valid")
+		invalid := ctx.AddBasicBlock(newFn, "sig.invalid")
+
+		builder.SetInsertPointAtEnd(entry)
+		keyOK := builder.CreateICmp(llvm.IntEQ, keyLen, llvm.ConstInt(uintptrType, cf.keySize, false), "")
+		builder.CreateCondBr(keyOK, checkSig, badKey)
+
+		builder.SetInsertPointAtEnd(badKey)
+		trap := mod.NamedFunction("llvm.trap")
+		if trap.IsNil() {
+			trap = llvm.AddFunction(mod, "llvm.trap", llvm.FunctionType(ctx.VoidType(), nil, false))
+		}
+		builder.CreateCall(trap.GlobalValueType(), trap, nil, "")
+		builder.CreateUnreachable()
+
+		builder.SetInsertPointAtEnd(checkSig)
+		sigOK := builder.CreateICmp(llvm.IntEQ, sigLen, llvm.ConstInt(uintptrType, cf.sigSize, false), "")
+		builder.CreateCondBr(sigOK, verify, invalid)
+
+		builder.SetInsertPointAtEnd(verify)
+		result := builder.CreateCall(hostFn.GlobalValueType(), hostFn, []llvm.Value{
+			builder.CreatePtrToInt(sig, uintptrType, ""),
+			createPointerSize(builder, msg, msgLen),
+			builder.CreatePtrToInt(key, uintptrType, ""),
+		}, "")
+		builder.CreateRet(builder.CreateICmp(llvm.IntNE, result, llvm.ConstInt(ctx.Int32Type(), 0, false), ""))
+
+		builder.SetInsertPointAtEnd(invalid)
+		builder.CreateRet(llvm.ConstInt(ctx.Int1Type(), 0, false))
+	}
+	return nil
+}
@@ -0,0 +1,18 @@
+package transform_test
+
+import (
+	"testing"
+
+	"github.com/tinygo-org/tinygo/transform"
+	"tinygo.org/x/go-llvm"
+)
+
+func TestReplaceCryptoFunctions(t *testing.T) {
+	t.Parallel()
+	testTransform(t, "testdata/hostcrypto", func(mod llvm.Module) {
+		err := transform.ReplaceCryptoFunctions(mod, []string{"ed25519"})
+		if err != nil {
+			t.Error(err)
+		}
+	})
+}
@@ -0,0 +1,115 @@
+package transform
+
+import (
+	"fmt"
+
+	"tinygo.org/x/go-llvm"
+)
+
+// hostHashFunctions lists the hash functions that can be replaced with a call
+// to a host function, together with the algorithm name used on the command
+// line. All of these functions have the signature func(data []byte) [32]byte.
+var hostHashFunctions = []struct {
+	algorithm string
+	function  string
+	host      string
+}{
+	{"blake2b", "golang.org/x/crypto/blake2b.Sum256", "ext_hashing_blake2_256_version_1"},
+	{"sha256", "crypto/sha256.Sum256", "ext_hashing_sha2_256_version_1"},
+}
+
+// ReplaceHashFunctions replaces the body of the hash functions for the given
+// algorithms with a call to the equivalent Polkadot host function
+// (ext_hashing_*), imported from the "env" module. The host function takes a
+// pointer-size (the pointer in the low 32 bits and the length in the high 32
+// bits) and returns a pointer to the 32 byte hash. This avoids linking in the
+// Go implementation of the hash, and is a lot faster too. This is the
+// -host-hashing= command line option.
+//
+// This must be run before the functions are inlined into their callers.
+func ReplaceHashFunctions(mod llvm.Module, algorithms []string) error {
+	ctx := mod.Context()
+	builder := ctx.NewBuilder()
+	defer builder.Dispose()
+	i64Type := ctx.Int64Type()
+	hashType := llvm.ArrayType(ctx.Int8Type(), 32)
+
+	for _, hf := range hostHashFunctions {
+		if !isInList(algorithms, hf.algorithm) {
+			continue
+		}
+		fn := mod.NamedFunction(hf.function)
+		if fn.IsNil() || fn.IsDeclaration() {
+			continue // hash function not used
+		}
+
+		// Check the signature: (ptr, len, cap, context) -> [32]byte.
+		fnType := fn.GlobalValueType()
+		params := fnType.ParamTypes()
+		if len(params) != 4 || fnType.ReturnType() != hashType || params[1].TypeKind() != llvm.IntegerTypeKind || params[1].IntTypeWidth() != 32 {
+			return fmt.Errorf("cannot replace %s with a host function: unexpected signature", hf.function)
+		}
+		uintptrType := params[1]
+
+		hostFn := getHostFunction(mod, hf.host, llvm.FunctionType(uintptrType, []llvm.Type{i64Type}, false))
+
+		// Replace the Go implementation with a new function that only calls
+		// the host function.
+		newFn := replaceFunctionBody(fn)
+		builder.SetInsertPointAtEnd(ctx.AddBasicBlock(newFn, "entry"))
+		data := createPointerSize(builder, newFn.Param(0), newFn.Param(1))
+		result := builder.CreateCall(hostFn.GlobalValueType(), hostFn, []llvm.Value{data}, "")
+		resultPtr := builder.CreateIntToPtr(result, newFn.Param(0).Type(), "")
+		hash := builder.CreateLoad(hashType, resultPtr, "")
+		builder.CreateRet(hash)
+	}
+	return nil
+}
+
+// getHostFunction returns the function imported from the "env" module with the
+// given name, declaring it if needed.
+func getHostFunction(mod llvm.Module, name string, fnType llvm.Type) llvm.Value {
+	fn := mod.NamedFunction(name)
+	if fn.IsNil() {
+		ctx := mod.Context()
+		fn = llvm.AddFunction(mod, name, fnType)
+		fn.AddFunctionAttr(ctx.CreateStringAttribute("wasm-import-module", "env"))
+		fn.AddFunctionAttr(ctx.CreateStringAttribute("wasm-import-name", name))
+	}
+	return fn
+}
+
+// replaceFunctionBody replaces fn with a new function without a body, with the
+// same name, type and linkage. All uses of fn are replaced with the new
+// function.
+func replaceFunctionBody(fn llvm.Value) llvm.Value {
+	name := fn.Name()
+	newFn := llvm.AddFunction(fn.GlobalParent(), "", fn.GlobalValueType())
+	newFn.SetLinkage(fn.Linkage())
+	fn.ReplaceAllUsesWith(newFn)
+	fn.EraseFromParentAsFunction()
+	newFn.SetName(name)
+	return newFn
+}
+
+// createPointerSize creates a 64-bit pointer-size value as used by the Polkadot
+// host functions: the pointer in the low 32 bits and the length in the high 32
+// bits.
+func createPointerSize(builder llvm.Builder, ptr, length llvm.Value) llvm.Value {
+	i64Type := ptr.Type().Context().Int64Type()
+	ptr = builder.CreatePtrToInt(ptr, length.Type(), "")
+	ptr = builder.CreateZExt(ptr, i64Type, "")
+	length = builder.CreateZExt(length, i64Type, "")
+	length = builder.CreateShl(length, llvm.ConstInt(i64Type, 32, false), "")
+	return builder.CreateOr(ptr, length, "")
+}
+
+// isInList returns whether the given string is present in the list.
+func isInList(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
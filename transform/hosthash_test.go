@@ -0,0 +1,19 @@
+package transform_test
+
+import (
+	"testing"
+
+	"github.com/tinygo-org/tinygo/transform"
+	"tinygo.org/x/go-llvm"
+)
+
+func TestReplaceHashFunctions(t *testing.T) {
+	t.Parallel()
+	testTransform(t, "testdata/hosthash", func(mod llvm.Module) {
+		// Only replace sha256, to check that blake2b is left alone.
+		err := transform.ReplaceHashFunctions(mod, []string{"sha256"})
+		if err != nil {
+			t.Error(err)
+		}
+	})
+}
@@ -0,0 +1,49 @@
+package transform
+
+import "tinygo.org/x/go-llvm"
+
+// hostLogFunctions lists the logging functions of the runtime/hostlog package,
+// from the lowest to the highest level.
+var hostLogFunctions = []struct {
+	level    string
+	function string
+}{
+	{"debug", "runtime/hostlog.Debug"},
+	{"info", "runtime/hostlog.Info"},
+	{"warn", "runtime/hostlog.Warn"},
+	{"error", "runtime/hostlog.Error"},
+}
+
+// StripLogCalls removes all calls to the runtime/hostlog functions below the
+// given level (debug, info, warn, error, or off to remove all of them). The
+// arguments of these calls, like the message strings and the boxed values, are
+// then removed by the optimizer as dead code. This is the -log-level= command
+// line option.
+//
+// This must be run before the functions are inlined into their callers.
+func StripLogCalls(mod llvm.Module, level string) {
+	ctx := mod.Context()
+	builder := ctx.NewBuilder()
+	defer builder.Dispose()
+
+	for _, lf := range hostLogFunctions {
+		if lf.level == level {
+			break // this level and the ones above are kept
+		}
+		fn := mod.NamedFunction(lf.function)
+		if fn.IsNil() || fn.IsDeclaration() {
+			continue // log function not used
+		}
+		for _, use := range getUses(fn) {
+			if !use.IsACallInst().IsNil() && use.CalledValue() == fn {
+				use.EraseFromParentAsInstruction()
+			}
+		}
+
+		// The function may still be used as a function value, make it do
+		// nothing.
+		newFn := replaceFunctionBody(fn)
+		builder.SetInsertPointAtEnd(ctx.AddBasicBlock(newFn, "entry"))
+		builder.CreateRetVoid()
+	}
+}
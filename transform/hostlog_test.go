@@ -0,0 +1,16 @@
+package transform_test
+
+import (
+	"testing"
+
+	"github.com/tinygo-org/tinygo/transform"
+	"tinygo.org/x/go-llvm"
+)
+
+func TestStripLogCalls(t *testing.T) {
+	t.Parallel()
+	testTransform(t, "testdata/hostlog", func(mod llvm.Module) {
+		// Info is missing from the module, which must be ignored.
+		transform.StripLogCalls(mod, "info")
+	})
+}
@@ -0,0 +1,67 @@
+package transform
+
+import (
+	"errors"
+	"strings"
+
+	"tinygo.org/x/go-llvm"
+)
+
+// InstrumentInits inserts a call to runtime.initReportEnter before and a call
+// to runtime.initReportExit after every package initializer that is still
+// called from runtime.initAll, for the -report-init option. This must be run
+// after interp, so that only the initializers that run at runtime are
+// measured. The package path is passed to runtime.initReportEnter as a string.
+func InstrumentInits(mod llvm.Module) error {
+	initAll := mod.NamedFunction("runtime.initAll")
+	enter := mod.NamedFunction("runtime.initReportEnter")
+	exit := mod.NamedFunction("runtime.initReportExit")
+	if initAll.IsNil() || enter.IsNil() || exit.IsNil() {
+		return errors.New("-report-init: runtime.initAll, runtime.initReportEnter or runtime.initReportExit is missing")
+	}
+
+	ctx := mod.Context()
+	builder := ctx.NewBuilder()
+	defer builder.Dispose()
+	context := llvm.Undef(llvm.PointerType(ctx.Int8Type(), 0))
+	uintptrType := enter.GlobalValueType().ParamTypes()[1]
+
+	var calls []llvm.Value
+	for bb := initAll.FirstBasicBlock(); !bb.IsNil(); bb = llvm.NextBasicBlock(bb) {
+		for inst := bb.FirstInstruction(); !inst.IsNil(); inst = llvm.NextInstruction(inst) {
+			if inst.IsACallInst().IsNil() {
+				continue
+			}
+			name := inst.CalledValue().Name()
+			if !strings.HasSuffix(name, ".init") {
+				continue
+			}
+			calls = append(calls, inst)
+		}
+	}
+
+	for _, call := range calls {
+		pkgPath := strings.TrimSuffix(call.CalledValue().Name(), ".init")
+		value := ctx.ConstString(pkgPath, false)
+		global := llvm.AddGlobal(mod, value.Type(), pkgPath+"$string")
+		global.SetInitializer(value)
+		global.SetLinkage(llvm.InternalLinkage)
+		global.SetGlobalConstant(true)
+		global.SetUnnamedAddr(true)
+		global.SetAlignment(1)
+
+		builder.SetInsertPointBefore(call)
+		enterCall := builder.CreateCall(enter.GlobalValueType(), enter, []llvm.Value{
+			global,
+			llvm.ConstInt(uintptrType, uint64(len(pkgPath)), false),
+			context,
+		}, "")
+		builder.SetInsertPointBefore(llvm.NextInstruction(call))
+		exitCall := builder.CreateCall(exit.GlobalValueType(), exit, []llvm.Value{context}, "")
+		if loc := call.InstructionDebugLoc(); !loc.IsNil() {
+			enterCall.InstructionSetDebugLoc(loc)
+			exitCall.InstructionSetDebugLoc(loc)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,18 @@
+package transform_test
+
+import (
+	"testing"
+
+	"github.com/tinygo-org/tinygo/transform"
+	"tinygo.org/x/go-llvm"
+)
+
+func TestInstrumentInits(t *testing.T) {
+	t.Parallel()
+	testTransform(t, "testdata/initreport", func(mod llvm.Module) {
+		err := transform.InstrumentInits(mod)
+		if err != nil {
+			t.Fatal(err)
+		}
+	})
+}
@@ -204,12 +204,23 @@ func (p *lowerInterfacesPass) run() error {
 	}
 
 	// Find all the interfaces that are implemented per type.
+	unsafeGC := p.config.Options.InterfaceGC == "unsafe"
 	for _, t := range p.types {
 		// This type has no methods, so don't spend time calculating them.
 		if len(t.methods) == 0 {
 			continue
 		}
 
+		// With -interface-gc=unsafe, ignore types that are never stored in an
+		// interface by the program itself. This removes their methods from
+		// the interface method thunks, so that they can be removed as dead
+		// code. It is unsafe because the reflect package can still create
+		// such an interface value from the type information of another type,
+		// for example with reflect.Value.Elem().Interface().
+		if unsafeGC && !isTypecodeUsedInCode(t.typecode) {
+			continue
+		}
+
 		// Pre-calculate a set of signatures that this type has, for easy
 		// lookup/check.
 		typeSignatureSet := make(map[*signatureInfo]struct{})
@@ -369,6 +380,28 @@ func (p *lowerInterfacesPass) run() error {
 	return nil
 }
 
+// isTypecodeUsedInCode returns whether the given type code is used anywhere
+// else than in the type information of other types: in a function or in the
+// initializer of a regular global (like an interface value in a global).
+func isTypecodeUsedInCode(value llvm.Value) bool {
+	for _, use := range getUses(value) {
+		if !use.IsAInstruction().IsNil() {
+			return true
+		}
+		if !use.IsAGlobalVariable().IsNil() {
+			if !strings.HasPrefix(use.Name(), "reflect/types.type:") {
+				return true
+			}
+			continue
+		}
+		// A constant (expression) that may be used in a function or global.
+		if isTypecodeUsedInCode(use) {
+			return true
+		}
+	}
+	return false
+}
+
 // addTypeMethods reads the method set of the given type info struct. It
 // retrieves the signatures and the references to the method functions
 // themselves for later type<->interface matching.
@@ -503,6 +536,13 @@ func (p *lowerInterfacesPass) defineInterfaceMethodFunc(fn llvm.Value, itf *inte
 	fn.SetLinkage(llvm.InternalLinkage)
 	fn.SetUnnamedAddr(true)
 	AddStandardAttributes(fn, p.config)
+	if len(itf.types) == 1 {
+		// Only one type implements this interface, so the thunk is just a
+		// type check followed by a direct call. Inline it everywhere so that
+		// the call is devirtualized and the concrete method can be inlined
+		// into the caller.
+		fn.AddFunctionAttr(p.ctx.CreateEnumAttribute(llvm.AttributeKindID("alwaysinline"), 0))
+	}
 
 	// Collect the params that will be passed to the functions to call.
 	// These params exclude the receiver (which may actually consist of multiple
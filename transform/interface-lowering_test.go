@@ -3,6 +3,7 @@ package transform_test
 import (
 	"testing"
 
+	"github.com/tinygo-org/tinygo/compileopts"
 	"github.com/tinygo-org/tinygo/transform"
 	"tinygo.org/x/go-llvm"
 )
@@ -23,3 +24,24 @@ func TestInterfaceLowering(t *testing.T) {
 		}
 	})
 }
+
+func TestInterfaceLoweringUnsafeGC(t *testing.T) {
+	t.Parallel()
+	config := &compileopts.Config{
+		Target:  &compileopts.TargetSpec{},
+		Options: &compileopts.Options{Opt: "2", InterfaceGC: "unsafe"},
+	}
+	testTransform(t, "testdata/interface-gc", func(mod llvm.Module) {
+		err := transform.LowerInterfaces(mod, config)
+		if err != nil {
+			t.Error(err)
+		}
+
+		po := llvm.NewPassBuilderOptions()
+		defer po.Dispose()
+		err = mod.RunPasses("globaldce", llvm.TargetMachine{}, po)
+		if err != nil {
+			t.Error("failed to run passes:", err)
+		}
+	})
+}
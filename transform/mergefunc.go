@@ -0,0 +1,119 @@
+package transform
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"tinygo.org/x/go-llvm"
+)
+
+// MergedFunction is a function that was found to be identical to another
+// function and was folded into it by MergeFunctions.
+type MergedFunction struct {
+	Name         string
+	Instructions int // number of instructions removed
+}
+
+// MergeFunctions folds functions with an identical body into a single
+// function, using the LLVM mergefunc pass. This mostly helps generic code:
+// instantiations for types with the same memory layout usually compile to the
+// exact same code. It returns the functions that were folded away.
+//
+// Functions of which the address may be observed are not deleted, but are
+// replaced with a small thunk that calls the remaining function.
+func MergeFunctions(mod llvm.Module) ([]MergedFunction, error) {
+	// Count the number of instructions in each function, to be able to tell
+	// which functions were merged and how much that saved.
+	before := make(map[string]int)
+	var names []string
+	for fn := mod.FirstFunction(); !fn.IsNil(); fn = llvm.NextFunction(fn) {
+		if fn.IsDeclaration() {
+			continue
+		}
+		before[fn.Name()] = countInstructions(fn)
+		names = append(names, fn.Name())
+	}
+
+	po := llvm.NewPassBuilderOptions()
+	defer po.Dispose()
+	err := mod.RunPasses("mergefunc", llvm.TargetMachine{}, po)
+	if err != nil {
+		return nil, fmt.Errorf("could not build pass pipeline: %w", err)
+	}
+
+	var merged []MergedFunction
+	for _, name := range names {
+		after := 0
+		if fn := mod.NamedFunction(name); !fn.IsNil() && !fn.IsDeclaration() {
+			after = countInstructions(fn)
+		}
+		if after < before[name] {
+			merged = append(merged, MergedFunction{
+				Name:         name,
+				Instructions: before[name] - after,
+			})
+		}
+	}
+	return merged, nil
+}
+
+// metadataRef matches references to metadata, like !dbg !12.
+var metadataRef = regexp.MustCompile(`!\d+`)
+
+// MergeOutlinedFunctions folds functions with the //go:outline pragma into
+// another outlined function with the same signature and code, even without
+// -merge-functions. Outlined functions are usually cold helpers that differ
+// only in where they were written, like error paths in generic code. Unlike
+// MergeFunctions, only the outlined functions are considered, so the rest of
+// the program isn't affected.
+//
+// Functions are compared by their IR, ignoring the debug information. As Go
+// functions can't be compared, the address of a merged function doesn't need
+// to be kept. Functions that are visible outside the module are never merged.
+func MergeOutlinedFunctions(mod llvm.Module) []MergedFunction {
+	var merged []MergedFunction
+	for changed := true; changed; {
+		changed = false
+		canonical := make(map[string]llvm.Value)
+		for fn := mod.FirstFunction(); !fn.IsNil(); {
+			next := llvm.NextFunction(fn)
+			if fn.IsDeclaration() || fn.Linkage() != llvm.InternalLinkage || fn.GetStringAttributeAtIndex(-1, "tinygo-outline").IsNil() {
+				fn = next
+				continue
+			}
+			// The first line contains the function name, so use the type,
+			// parameter names and section instead.
+			key := fn.GlobalValueType().String() + " " + fn.Section()
+			for _, param := range fn.Params() {
+				key += " %" + param.Name()
+			}
+			ir := fn.String()
+			key += metadataRef.ReplaceAllString(ir[strings.Index(ir, "{\n"):], "!")
+			if other, ok := canonical[key]; ok {
+				merged = append(merged, MergedFunction{
+					Name:         fn.Name(),
+					Instructions: countInstructions(fn),
+				})
+				fn.ReplaceAllUsesWith(other)
+				fn.EraseFromParentAsFunction()
+				changed = true
+			} else {
+				canonical[key] = fn
+			}
+			fn = next
+		}
+	}
+	return merged
+}
+
+// countInstructions returns the number of instructions in the given function.
+func countInstructions(fn llvm.Value) int {
+	count := 0
+	for bb := fn.FirstBasicBlock(); !bb.IsNil(); bb = llvm.NextBasicBlock(bb) {
+		for inst := bb.FirstInstruction(); !inst.IsNil(); inst = llvm.NextInstruction(inst) {
+			count++
+		}
+	}
+	return count
+}
@@ -0,0 +1,31 @@
+package transform_test
+
+import (
+	"testing"
+
+	"github.com/tinygo-org/tinygo/transform"
+	"tinygo.org/x/go-llvm"
+)
+
+func TestMergeFunctions(t *testing.T) {
+	t.Parallel()
+	testTransform(t, "testdata/mergefunc", func(mod llvm.Module) {
+		merged, err := transform.MergeFunctions(mod)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(merged) != 1 || merged[0].Instructions != 10 {
+			t.Errorf("expected one function of 10 instructions to be merged, got %v", merged)
+		}
+	})
+}
+
+func TestMergeOutlinedFunctions(t *testing.T) {
+	t.Parallel()
+	testTransform(t, "testdata/mergefunc-outline", func(mod llvm.Module) {
+		merged := transform.MergeOutlinedFunctions(mod)
+		if len(merged) != 1 || merged[0].Name != "main.notFound[uint]" {
+			t.Errorf("expected main.notFound[uint] to be merged, got %v", merged)
+		}
+	})
+}
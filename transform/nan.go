@@ -0,0 +1,64 @@
+package transform
+
+import (
+	"tinygo.org/x/go-llvm"
+)
+
+// CanonicalizeNaNs replaces NaN values with the canonical quiet NaN (positive,
+// with only the most significant bit of the significand set) where they leave
+// the program, for -fp-deterministic. WebAssembly leaves the sign and payload
+// of a NaN produced by an arithmetic operation up to the host, so without this
+// the same program may produce different bits on different hosts.
+//
+// A NaN leaves the program when it is returned from an exported function, when
+// it is passed to a function defined outside the module (like an imported host
+// function, but not the soft-float routines of -soft-float), or when its bits
+// are read as an integer, as math.Float64bits does. The latter is a bitcast
+// once the optimization pipeline has removed the store and load in between, so
+// this pass must run after it.
+func CanonicalizeNaNs(mod llvm.Module) {
+	ctx := mod.Context()
+	builder := ctx.NewBuilder()
+	defer builder.Dispose()
+
+	// canonicalize returns value with NaNs replaced, inserted before inst. It
+	// returns the value itself if it isn't a floating point value.
+	canonicalize := func(inst, value llvm.Value) llvm.Value {
+		var nan llvm.Value
+		switch value.Type().TypeKind() {
+		case llvm.FloatTypeKind:
+			nan = llvm.ConstBitCast(llvm.ConstInt(ctx.Int32Type(), 0x7fc00000, false), value.Type())
+		case llvm.DoubleTypeKind:
+			nan = llvm.ConstBitCast(llvm.ConstInt(ctx.Int64Type(), 0x7ff8000000000000, false), value.Type())
+		default:
+			return value
+		}
+		builder.SetInsertPointBefore(inst)
+		isNaN := builder.CreateFCmp(llvm.FloatUNO, value, value, "isnan")
+		return builder.CreateSelect(isNaN, nan, value, "canonical")
+	}
+
+	for fn := mod.FirstFunction(); !fn.IsNil(); fn = llvm.NextFunction(fn) {
+		exported := !fn.GetStringAttributeAtIndex(-1, "wasm-export-name").IsNil()
+		for bb := fn.FirstBasicBlock(); !bb.IsNil(); bb = llvm.NextBasicBlock(bb) {
+			for inst := bb.FirstInstruction(); !inst.IsNil(); inst = llvm.NextInstruction(inst) {
+				switch {
+				case exported && !inst.IsAReturnInst().IsNil() && inst.OperandsCount() != 0:
+					inst.SetOperand(0, canonicalize(inst, inst.Operand(0)))
+				case !inst.IsACallInst().IsNil():
+					callee := inst.CalledValue()
+					if callee.IsAFunction().IsNil() || !callee.IsDeclaration() || callee.IntrinsicID() != 0 || softFloatRoutines[callee.Name()] {
+						continue
+					}
+					for i := 0; i < inst.OperandsCount()-1; i++ {
+						inst.SetOperand(i, canonicalize(inst, inst.Operand(i)))
+					}
+				case !inst.IsABitCastInst().IsNil():
+					if inst.Type().TypeKind() == llvm.IntegerTypeKind {
+						inst.SetOperand(0, canonicalize(inst, inst.Operand(0)))
+					}
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,15 @@
+package transform_test
+
+import (
+	"testing"
+
+	"github.com/tinygo-org/tinygo/transform"
+	"tinygo.org/x/go-llvm"
+)
+
+func TestCanonicalizeNaNs(t *testing.T) {
+	t.Parallel()
+	testTransform(t, "testdata/nan", func(mod llvm.Module) {
+		transform.CanonicalizeNaNs(mod)
+	})
+}
@@ -1,10 +1,13 @@
 package transform
 
 import (
+	"encoding/json"
 	"errors"
 	"fmt"
 	"go/token"
 	"os"
+	"strconv"
+	"strings"
 
 	"github.com/tinygo-org/tinygo/compileopts"
 	"github.com/tinygo-org/tinygo/compiler/ircheck"
@@ -44,6 +47,13 @@ func Optimize(mod llvm.Module, config *compileopts.Config) []error {
 		ReplacePanicsWithTrap(mod) // -panic=trap
 	}
 
+	if config.Options.YieldPoints != 0 {
+		err := InsertYieldPoints(mod, config.Options.YieldPoints) // -yield-points=N
+		if err != nil {
+			return []error{err}
+		}
+	}
+
 	// run a check of all of our code
 	if config.VerifyIR() {
 		errs := ircheck.Module(mod)
@@ -61,8 +71,17 @@ func Optimize(mod llvm.Module, config *compileopts.Config) []error {
 			return []error{fmt.Errorf("could not build pass pipeline: %w", err)}
 		}
 
+		if config.Options.LowerFmt {
+			// Report this after dead code has been removed, but before
+			// fmt.Sprintf and fmt.Errorf may have been inlined.
+			reportFmtCalls(mod, func(pos token.Position, msg string) {
+				fmt.Fprintln(os.Stderr, pos.String()+": "+msg)
+			})
+		}
+
 		// Run TinyGo-specific optimization passes.
 		OptimizeStringToBytes(mod)
+		OptimizeStringFromBytes(mod)
 		OptimizeReflectImplements(mod)
 		maxStackSize := config.MaxStackAlloc()
 		OptimizeAllocs(mod, nil, maxStackSize, nil)
@@ -85,10 +104,15 @@ func Optimize(mod llvm.Module, config *compileopts.Config) []error {
 		}
 
 		// Run TinyGo-specific interprocedural optimizations.
-		OptimizeAllocs(mod, config.Options.PrintAllocs, maxStackSize, func(pos token.Position, msg string) {
-			fmt.Fprintln(os.Stderr, pos.String()+": "+msg)
-		})
+		if config.Options.PrintAllocsJSON {
+			OptimizeAllocsReport(mod, config.Options.PrintAllocs, maxStackSize, printAllocJSON)
+		} else {
+			OptimizeAllocs(mod, config.Options.PrintAllocs, maxStackSize, func(pos token.Position, msg string) {
+				fmt.Fprintln(os.Stderr, pos.String()+": "+msg)
+			})
+		}
 		OptimizeStringToBytes(mod)
+		OptimizeStringFromBytes(mod)
 		OptimizeStringEqual(mod)
 
 	} else {
@@ -122,6 +146,14 @@ func Optimize(mod llvm.Module, config *compileopts.Config) []error {
 		}
 	}
 
+	if config.SingleThreaded() {
+		// The compiler lowers atomic operations to plain loads and stores in
+		// this case, make sure none are left.
+		if errs := CheckAtomics(mod); len(errs) > 0 {
+			return errs
+		}
+	}
+
 	if config.VerifyIR() {
 		if errs := ircheck.Module(mod); errs != nil {
 			return errs
@@ -140,6 +172,10 @@ func Optimize(mod llvm.Module, config *compileopts.Config) []error {
 		fn.SetLinkage(llvm.InternalLinkage)
 	}
 
+	if config.Options.InlineBudget != 0 {
+		setInlineBudget(mod, config.Options.InlineBudget) // -inline-budget=N
+	}
+
 	// Run the default pass pipeline.
 	// TODO: set the PrepareForThinLTO flag somehow.
 	po := llvm.NewPassBuilderOptions()
@@ -150,6 +186,16 @@ func Optimize(mod llvm.Module, config *compileopts.Config) []error {
 		return []error{fmt.Errorf("could not build pass pipeline: %w", err)}
 	}
 
+	if config.Options.FPDeterministic {
+		// This must run after the optimization pipeline, see the comment on
+		// CanonicalizeNaNs.
+		CanonicalizeNaNs(mod)
+	}
+
+	// Deduplicate string constants of all packages, now that unused strings
+	// have been removed.
+	MergeStringConstants(mod)
+
 	hasGCPass := MakeGCStackSlots(mod)
 	if hasGCPass {
 		if err := llvm.VerifyModule(mod, llvm.PrintMessageAction); err != nil {
@@ -168,3 +214,114 @@ var functionsUsedInTransforms = []string{
 	"runtime.free",
 	"runtime.nilPanic",
 }
+
+// setInlineBudget sets the inlining threshold of every function in the module,
+// overriding the threshold of the optimization level (225 for -opt=2, 5 for
+// -opt=z). The LLVM inliner reads it from the function-inline-threshold
+// attribute of the called function. Functions with //go:inline or
+// //go:noinline are always or never inlined, regardless of the threshold: the
+// compiler only makes //go:inline mandatory when a budget is set.
+func setInlineBudget(mod llvm.Module, budget int) {
+	attr := mod.Context().CreateStringAttribute("function-inline-threshold", strconv.Itoa(budget))
+	for fn := mod.FirstFunction(); !fn.IsNil(); fn = llvm.NextFunction(fn) {
+		if !fn.IsDeclaration() {
+			fn.AddFunctionAttr(attr)
+		}
+	}
+}
+
+// allocJSON is a single heap allocation as printed by -print-allocs=json.
+type allocJSON struct {
+	Pos       string `json:"pos"`
+	Function  string `json:"function"`
+	Size      uint64 `json:"size,omitempty"`
+	SizeClass string `json:"sizeClass"`
+	Reason    string `json:"reason"`
+	EscapesAt string `json:"escapesAt,omitempty"`
+	Message   string `json:"message"`
+}
+
+// printAllocJSON prints a heap allocation as a single line of JSON to stderr,
+// so that the output can easily be processed by other tools.
+func printAllocJSON(alloc HeapAlloc) {
+	out := allocJSON{
+		Pos:       alloc.Pos.String(),
+		Function:  alloc.Function,
+		Size:      alloc.Size,
+		SizeClass: alloc.SizeClass(),
+		Reason:    alloc.Reason,
+		Message:   alloc.Message,
+	}
+	if alloc.EscapesAt.IsValid() {
+		out.EscapesAt = alloc.EscapesAt.String()
+	}
+	data, err := json.Marshal(out)
+	if err != nil {
+		panic(err) // can't happen
+	}
+	fmt.Fprintln(os.Stderr, string(data))
+}
+
+// reportFmtCalls calls logger for every call to fmt.Sprintf and fmt.Errorf that
+// remains in the program, because the compiler couldn't lower it (-lower-fmt).
+func reportFmtCalls(mod llvm.Module, logger func(token.Position, string)) {
+	for _, name := range []string{"fmt.Sprintf", "fmt.Errorf"} {
+		fn := mod.NamedFunction(name)
+		if fn.IsNil() {
+			continue
+		}
+		for _, call := range getUses(fn) {
+			if call.IsACallInst().IsNil() || call.CalledValue() != fn {
+				continue
+			}
+			logger(getPosition(call), name+" call could not be lowered: only constant format strings with %s, %d, %v, %t and %x on basic types without methods are supported")
+		}
+	}
+}
+
+// ReportBlockingChannelOps calls logger for every channel send, receive or
+// select statement that may block. Without a scheduler there is no other
+// goroutine that could unblock them. Operations in the packages in goroot (the
+// standard library) are not reported, as they can't be changed and usually
+// don't block in practice.
+func ReportBlockingChannelOps(mod llvm.Module, goroot map[string]bool, logger func(token.Position, string)) {
+	seen := make(map[token.Position]struct{})
+	for _, op := range []struct{ name, kind string }{
+		{"runtime.chanSend", "channel send"},
+		{"runtime.chanRecv", "channel receive"},
+		{"runtime.chanSelect", "blocking select"},
+	} {
+		fn := mod.NamedFunction(op.name)
+		if fn.IsNil() {
+			continue
+		}
+		for _, call := range getUses(fn) {
+			if call.IsACallInst().IsNil() || goroot[functionPackagePath(call.InstructionParent().Parent().Name())] {
+				continue
+			}
+			pos := getPosition(call)
+			if _, ok := seen[pos]; ok {
+				continue
+			}
+			seen[pos] = struct{}{}
+			logger(pos, op.kind+" without a scheduler will deadlock if it cannot complete immediately, consider using runtime/eventqueue instead")
+		}
+	}
+}
+
+// functionPackagePath returns the path of the package that defines the
+// function with the given name, or "" if it isn't a Go function. Function names
+// start with the package path, possibly after the receiver type of a method:
+// main.foo, (*example.com/pkg.T).Method.
+func functionPackagePath(name string) string {
+	name = strings.TrimLeft(name, "(*")
+	if i := strings.IndexByte(name, '['); i >= 0 {
+		name = name[:i] // type arguments of a generic function
+	}
+	slash := strings.LastIndexByte(name, '/') + 1
+	dot := strings.IndexByte(name[slash:], '.')
+	if dot < 0 {
+		return ""
+	}
+	return name[:slash+dot]
+}
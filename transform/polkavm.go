@@ -0,0 +1,219 @@
+package transform
+
+// This file adds the metadata with which polkatool, the linker of PolkaVM,
+// finds the imported and exported functions of a program. polkatool reads the
+// ELF file produced by the normal linker and converts it to a PolkaVM blob.
+//
+// Every import and export is described by a metadata struct in the
+// .polkavm_metadata section, which is laid out like this (packed, little
+// endian, pointers are 32 bits):
+//
+//	u8  version (1)
+//	u32 flags (0)
+//	u32 length of the symbol name
+//	ptr symbol name
+//	u8  number of registers used for the parameters
+//	u8  number of registers used for the result
+//
+// Exported functions are listed in the .polkavm_exports section, each as a
+// version byte (1) followed by a pointer to the metadata and a pointer to the
+// function. Imported functions are called with a custom instruction followed
+// by a pointer to the metadata, which polkatool replaces with an ecalli
+// instruction.
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+
+	"github.com/tinygo-org/tinygo/compiler/llvmutil"
+	"tinygo.org/x/go-llvm"
+)
+
+// Registers that PolkaVM uses to pass parameters (a0-a5) and results (a0-a1)
+// between the host and the program, as defined by the ilp32e ABI.
+const (
+	polkaVMMaxParamRegs  = 6
+	polkaVMMaxResultRegs = 2
+)
+
+// AddPolkaVMMetadata adds the PolkaVM metadata of all imported and exported
+// functions, as marked by the compiler with the polkavm-import-name and
+// polkavm-export-name attributes.
+//
+// Exported functions are wrapped in the same way as by AddExportPrologues: the
+// wrapper passes the stack pointer to runtime.polkavmExportEnter, which records
+// it as the top of the stack for the GC and initializes the program on the
+// first call. The original function is renamed with a $body suffix.
+//
+// Imported functions are declarations, which get a body that calls the host.
+// The body is a naked function, so that the parameters and results stay in the
+// registers in which the caller put them and in which the host expects them.
+func AddPolkaVMMetadata(mod llvm.Module) error {
+	var imports, exports []llvm.Value
+	for fn := mod.FirstFunction(); !fn.IsNil(); fn = llvm.NextFunction(fn) {
+		if !fn.GetStringAttributeAtIndex(-1, "polkavm-import-name").IsNil() && fn.IsDeclaration() {
+			imports = append(imports, fn)
+		}
+		if !fn.GetStringAttributeAtIndex(-1, "polkavm-export-name").IsNil() && !fn.IsDeclaration() {
+			exports = append(exports, fn)
+		}
+	}
+	if len(imports) == 0 && len(exports) == 0 {
+		return nil
+	}
+
+	ctx := mod.Context()
+	builder := ctx.NewBuilder()
+	defer builder.Dispose()
+	i8ptrType := llvm.PointerType(ctx.Int8Type(), 0)
+	context := llvm.Undef(i8ptrType)
+	noinline := ctx.CreateEnumAttribute(llvm.AttributeKindID("noinline"), 0)
+	naked := ctx.CreateEnumAttribute(llvm.AttributeKindID("naked"), 0)
+	var used []llvm.Value
+
+	for i, fn := range imports {
+		name := fn.GetStringAttributeAtIndex(-1, "polkavm-import-name").GetStringValue()
+		metadata, err := addPolkaVMMetadata(mod, fn, name, "polkavm_import_"+strconv.Itoa(i))
+		if err != nil {
+			return err
+		}
+		fn.RemoveStringAttributeAtIndex(-1, "polkavm-import-name")
+		fn.SetLinkage(llvm.InternalLinkage)
+		fn.AddFunctionAttr(naked)
+		fn.AddFunctionAttr(noinline)
+		builder.SetInsertPointAtEnd(ctx.AddBasicBlock(fn, "entry"))
+		asmType := llvm.FunctionType(ctx.VoidType(), nil, false)
+		asm := llvm.InlineAsm(asmType, ".insn r 0xb, 0, 0, zero, zero, zero\n.4byte "+metadata.Name()+"\nret", "", true, false, 0, false)
+		builder.CreateCall(asmType, asm, nil, "")
+		builder.CreateUnreachable()
+		used = append(used, metadata)
+	}
+
+	if len(exports) != 0 {
+		exportEnter := mod.NamedFunction("runtime.polkavmExportEnter")
+		if exportEnter.IsNil() {
+			return errors.New("runtime.polkavmExportEnter is missing")
+		}
+		stacksave := mod.NamedFunction("llvm.stacksave")
+		if stacksave.IsNil() {
+			stacksave = llvm.AddFunction(mod, "llvm.stacksave", llvm.FunctionType(i8ptrType, nil, false))
+		}
+		for i, fn := range exports {
+			name := fn.Name()
+			exportName := fn.GetStringAttributeAtIndex(-1, "polkavm-export-name").GetStringValue()
+			metadata, err := addPolkaVMMetadata(mod, fn, exportName, "polkavm_export_"+strconv.Itoa(i))
+			if err != nil {
+				return err
+			}
+			fn.SetName(name + "$body")
+			fn.RemoveStringAttributeAtIndex(-1, "polkavm-export-name")
+			fn.AddFunctionAttr(noinline)
+
+			wrapper := llvm.AddFunction(mod, name, fn.GlobalValueType())
+			wrapper.SetLinkage(fn.Linkage())
+			wrapper.SetVisibility(fn.Visibility())
+			params := wrapper.Params()
+			for i, param := range fn.Params() {
+				params[i].SetName(param.Name())
+			}
+			builder.SetInsertPointAtEnd(ctx.AddBasicBlock(wrapper, "entry"))
+			sp := builder.CreateCall(stacksave.GlobalValueType(), stacksave, nil, "")
+			builder.CreateCall(exportEnter.GlobalValueType(), exportEnter, []llvm.Value{sp, context}, "")
+			result := builder.CreateCall(fn.GlobalValueType(), fn, params, "")
+			if fn.GlobalValueType().ReturnType().TypeKind() == llvm.VoidTypeKind {
+				builder.CreateRetVoid()
+			} else {
+				builder.CreateRet(result)
+			}
+
+			// The entry in .polkavm_exports.
+			entryValue := ctx.ConstStruct([]llvm.Value{
+				llvm.ConstInt(ctx.Int8Type(), 1, false),
+				metadata,
+				wrapper,
+			}, true)
+			entry := llvm.AddGlobal(mod, entryValue.Type(), metadata.Name()+"$entry")
+			entry.SetInitializer(entryValue)
+			entry.SetLinkage(llvm.InternalLinkage)
+			entry.SetGlobalConstant(true)
+			entry.SetSection(".polkavm_exports")
+			entry.SetAlignment(1)
+			used = append(used, entry)
+		}
+	}
+
+	llvmutil.AppendToGlobal(mod, "llvm.used", used...)
+	return nil
+}
+
+// addPolkaVMMetadata adds the metadata struct for the given imported or
+// exported function, as a global with the given name. It returns an error if
+// the parameters or result of the function don't fit in the registers that
+// PolkaVM uses for them.
+func addPolkaVMMetadata(mod llvm.Module, fn llvm.Value, symbol, globalName string) (llvm.Value, error) {
+	fnType := fn.GlobalValueType()
+	paramRegs := 0
+	for _, param := range fnType.ParamTypes() {
+		n, ok := polkaVMRegisters(param)
+		if !ok {
+			return llvm.Value{}, fmt.Errorf("%s: cannot pass parameter of type %s to or from the PolkaVM host", symbol, param.String())
+		}
+		paramRegs += n
+	}
+	if paramRegs > polkaVMMaxParamRegs {
+		return llvm.Value{}, fmt.Errorf("%s: parameters need %d registers, PolkaVM supports at most %d", symbol, paramRegs, polkaVMMaxParamRegs)
+	}
+	resultRegs := 0
+	if returnType := fnType.ReturnType(); returnType.TypeKind() != llvm.VoidTypeKind {
+		n, ok := polkaVMRegisters(returnType)
+		if !ok || n > polkaVMMaxResultRegs {
+			return llvm.Value{}, fmt.Errorf("%s: cannot return a value of type %s to or from the PolkaVM host", symbol, returnType.String())
+		}
+		resultRegs = n
+	}
+
+	ctx := mod.Context()
+	symbolValue := ctx.ConstString(symbol, false)
+	symbolGlobal := llvm.AddGlobal(mod, symbolValue.Type(), globalName+"$symbol")
+	symbolGlobal.SetInitializer(symbolValue)
+	symbolGlobal.SetLinkage(llvm.PrivateLinkage)
+	symbolGlobal.SetGlobalConstant(true)
+	symbolGlobal.SetSection(".polkavm_metadata")
+	symbolGlobal.SetAlignment(1)
+
+	metadataValue := ctx.ConstStruct([]llvm.Value{
+		llvm.ConstInt(ctx.Int8Type(), 1, false),                    // version
+		llvm.ConstInt(ctx.Int32Type(), 0, false),                   // flags
+		llvm.ConstInt(ctx.Int32Type(), uint64(len(symbol)), false), // symbol length
+		symbolGlobal,
+		llvm.ConstInt(ctx.Int8Type(), uint64(paramRegs), false),
+		llvm.ConstInt(ctx.Int8Type(), uint64(resultRegs), false),
+	}, true)
+	metadata := llvm.AddGlobal(mod, metadataValue.Type(), globalName)
+	metadata.SetInitializer(metadataValue)
+	metadata.SetLinkage(llvm.InternalLinkage)
+	metadata.SetGlobalConstant(true)
+	metadata.SetSection(".polkavm_metadata")
+	metadata.SetAlignment(1)
+	return metadata, nil
+}
+
+// polkaVMRegisters returns the number of 32-bit registers in which a value of
+// the given type is passed, or false if it can't be passed in registers.
+func polkaVMRegisters(t llvm.Type) (int, bool) {
+	switch t.TypeKind() {
+	case llvm.IntegerTypeKind:
+		if t.IntTypeWidth() <= 32 {
+			return 1, true
+		}
+		if t.IntTypeWidth() == 64 {
+			return 2, true
+		}
+	case llvm.PointerTypeKind, llvm.FloatTypeKind:
+		return 1, true
+	case llvm.DoubleTypeKind:
+		return 2, true
+	}
+	return 0, false
+}
@@ -0,0 +1,18 @@
+package transform_test
+
+import (
+	"testing"
+
+	"github.com/tinygo-org/tinygo/transform"
+	"tinygo.org/x/go-llvm"
+)
+
+func TestAddPolkaVMMetadata(t *testing.T) {
+	t.Parallel()
+	testTransform(t, "testdata/polkavm", func(mod llvm.Module) {
+		err := transform.AddPolkaVMMetadata(mod)
+		if err != nil {
+			t.Fatal(err)
+		}
+	})
+}
@@ -0,0 +1,128 @@
+package transform
+
+// This file implements the -why-live= and -print-retained diagnostics, which
+// explain why a function ends up in the final binary.
+
+import (
+	"tinygo.org/x/go-llvm"
+)
+
+// Retainers describes which root keeps each function and global in the module
+// alive, by recording for every value the function or global that first
+// referenced it in a breadth-first walk over the reference graph. Roots are
+// all externally visible functions and globals, like exported functions.
+type Retainers struct {
+	parents map[llvm.Value]llvm.Value
+	order   []llvm.Value // all live values in the order they were found
+}
+
+// FindRetainers builds the reference graph of the module and determines for
+// every function and global which other function or global references it. The
+// result is an approximation of what the linker will keep, as it doesn't know
+// which externally visible symbols the linker will discard.
+func FindRetainers(mod llvm.Module) *Retainers {
+	r := &Retainers{
+		parents: make(map[llvm.Value]llvm.Value),
+	}
+
+	// Collect the roots.
+	var worklist []llvm.Value
+	addRoot := func(value llvm.Value) {
+		if value.IsDeclaration() {
+			return
+		}
+		switch value.Linkage() {
+		case llvm.InternalLinkage, llvm.PrivateLinkage:
+			return
+		}
+		r.parents[value] = llvm.Value{}
+		r.order = append(r.order, value)
+		worklist = append(worklist, value)
+	}
+	for fn := mod.FirstFunction(); !fn.IsNil(); fn = llvm.NextFunction(fn) {
+		addRoot(fn)
+	}
+	for global := mod.FirstGlobal(); !global.IsNil(); global = llvm.NextGlobal(global) {
+		addRoot(global)
+	}
+
+	// Walk the reference graph, breadth first so that the shortest path to a
+	// root is found.
+	visitedConstants := make(map[llvm.Value]struct{})
+	for len(worklist) != 0 {
+		value := worklist[0]
+		worklist = worklist[1:]
+		forEachReference(value, visitedConstants, func(ref llvm.Value) {
+			if _, ok := r.parents[ref]; ok {
+				return
+			}
+			r.parents[ref] = value
+			r.order = append(r.order, ref)
+			worklist = append(worklist, ref)
+		})
+	}
+	return r
+}
+
+// Live returns all functions and globals that are (directly or indirectly)
+// referenced by a root, in breadth-first order.
+func (r *Retainers) Live() []llvm.Value {
+	return r.order
+}
+
+// Chain returns the chain of references that keeps the given value alive,
+// starting at the root and ending at the value itself. It returns nil if the
+// value is not live.
+func (r *Retainers) Chain(value llvm.Value) []llvm.Value {
+	if _, ok := r.parents[value]; !ok {
+		return nil
+	}
+	var chain []llvm.Value
+	for !value.IsNil() {
+		chain = append(chain, value)
+		value = r.parents[value]
+	}
+	// Reverse the chain, so that it starts at the root.
+	for i, j := 0, len(chain)-1; i < j; i, j = i+1, j-1 {
+		chain[i], chain[j] = chain[j], chain[i]
+	}
+	return chain
+}
+
+// forEachReference calls fn for every function and global referenced from the
+// given function body or global initializer.
+func forEachReference(value llvm.Value, visitedConstants map[llvm.Value]struct{}, fn func(llvm.Value)) {
+	var visitOperand func(operand llvm.Value)
+	visitOperand = func(operand llvm.Value) {
+		if operand.IsNil() {
+			return
+		}
+		if !operand.IsAGlobalValue().IsNil() {
+			fn(operand)
+			return
+		}
+		if operand.IsAConstant().IsNil() {
+			return
+		}
+		// Constant expressions and aggregates may refer to globals.
+		if _, ok := visitedConstants[operand]; ok {
+			return
+		}
+		visitedConstants[operand] = struct{}{}
+		for i := 0; i < operand.OperandsCount(); i++ {
+			visitOperand(operand.Operand(i))
+		}
+	}
+
+	if !value.IsAFunction().IsNil() {
+		for bb := value.FirstBasicBlock(); !bb.IsNil(); bb = llvm.NextBasicBlock(bb) {
+			for inst := bb.FirstInstruction(); !inst.IsNil(); inst = llvm.NextInstruction(inst) {
+				for i := 0; i < inst.OperandsCount(); i++ {
+					visitOperand(inst.Operand(i))
+				}
+			}
+		}
+	} else if initializer := value.Initializer(); !initializer.IsNil() {
+		visitOperand(initializer)
+	}
+}
@@ -0,0 +1,45 @@
+package transform_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/tinygo-org/tinygo/transform"
+	"tinygo.org/x/go-llvm"
+)
+
+func TestFindRetainers(t *testing.T) {
+	t.Parallel()
+	ctx := llvm.NewContext()
+	defer ctx.Dispose()
+	buf, err := llvm.NewMemoryBufferFromFile("testdata/retained.ll")
+	if err != nil {
+		t.Fatal("could not read file:", err)
+	}
+	mod, err := ctx.ParseIR(buf)
+	if err != nil {
+		t.Fatal("could not load module:", err)
+	}
+	defer mod.Dispose()
+
+	retainers := transform.FindRetainers(mod)
+	for _, tc := range []struct {
+		name  string
+		chain string
+	}{
+		{"_start", "_start"},
+		{"fmt.Println", "_start main.main main.handlers main.handler fmt.Println"},
+		{"main.dead", ""},
+	} {
+		var names []string
+		for _, value := range retainers.Chain(mod.NamedFunction(tc.name)) {
+			names = append(names, value.Name())
+		}
+		if chain := strings.Join(names, " "); chain != tc.chain {
+			t.Errorf("unexpected chain for %s:\nexpected: %s\nactual:   %s", tc.name, tc.chain, chain)
+		}
+	}
+	if chain := retainers.Chain(mod.NamedGlobal("main.unused")); chain != nil {
+		t.Error("expected main.unused to be dead, got chain of length", len(chain))
+	}
+}
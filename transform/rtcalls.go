@@ -73,6 +73,208 @@ func OptimizeStringToBytes(mod llvm.Module) {
 	}
 }
 
+// OptimizeStringFromBytes removes the copy in runtime.stringFromBytes(...)
+// calls whenever the resulting string only lives for a short time and the byte
+// slice cannot be modified during that time. This optimizes patterns like the
+// following, that are very common in decoders:
+//
+//	if string(buf) == "foo" { ... }
+//	switch string(buf) { ... }
+//	value := m[string(buf)]
+//
+// The string is only passed to runtime functions that read it without keeping
+// a reference to it, and there are no instructions that might write memory
+// between the conversion and the last use of the string. Therefore, the string
+// can simply point to the backing array of the byte slice.
+func OptimizeStringFromBytes(mod llvm.Module) {
+	stringFromBytes := mod.NamedFunction("runtime.stringFromBytes")
+	if stringFromBytes.IsNil() {
+		// nothing to optimize
+		return
+	}
+
+	for _, call := range getUses(stringFromBytes) {
+		slicePtr := call.Operand(0)
+		sliceLen := call.Operand(1)
+
+		// Check that the string is only used by runtime calls that read it.
+		var ptrUses, lenUses, readers []llvm.Value
+		canShare := true
+		for _, use := range getUses(call) {
+			if use.IsAExtractValueInst().IsNil() {
+				// The string is used in some other way, for example it is
+				// stored or returned.
+				canShare = false
+				break
+			}
+			switch use.Type().TypeKind() {
+			case llvm.IntegerTypeKind:
+				lenUses = append(lenUses, use)
+			case llvm.PointerTypeKind:
+				ptrUses = append(ptrUses, use)
+				for _, reader := range getUses(use) {
+					if reader.IsACallInst().IsNil() || !stringReaders[reader.CalledValue().Name()] {
+						canShare = false
+					}
+					readers = append(readers, reader)
+				}
+			default:
+				// should not happen
+				panic("unknown return type of runtime.stringFromBytes: " + use.Type().String())
+			}
+		}
+		if !canShare || !noWritesBeforeUses(call, readers) {
+			continue
+		}
+
+		// The string can point to the byte slice.
+		for _, use := range ptrUses {
+			use.ReplaceAllUsesWith(slicePtr)
+			use.EraseFromParentAsInstruction()
+		}
+		for _, use := range lenUses {
+			use.ReplaceAllUsesWith(sliceLen)
+			use.EraseFromParentAsInstruction()
+		}
+		call.EraseFromParentAsInstruction()
+	}
+}
+
+// stringReaders are the runtime functions that only read the strings that are
+// passed to them, without keeping a reference. Also see mayWriteMemory.
+var stringReaders = map[string]bool{
+	"runtime.stringEqual":      true,
+	"runtime.stringLess":       true,
+	"runtime.hashmapStringGet": true,
+	"runtime.trackPointer":     true,
+}
+
+// noWritesBeforeUses returns true if no instruction that runs after the given
+// instruction and before one of the uses may write to memory, and false if this
+// cannot be proven.
+func noWritesBeforeUses(inst llvm.Value, uses []llvm.Value) bool {
+	// Find all basic blocks that can reach one of the uses, by walking the
+	// control flow graph backwards.
+	fn := inst.InstructionParent().Parent()
+	predecessors := make(map[llvm.BasicBlock][]llvm.BasicBlock)
+	for bb := fn.FirstBasicBlock(); !bb.IsNil(); bb = llvm.NextBasicBlock(bb) {
+		for _, succ := range successors(bb) {
+			predecessors[succ] = append(predecessors[succ], bb)
+		}
+	}
+	reachesUse := make(map[llvm.BasicBlock]bool)
+	var worklist []llvm.BasicBlock
+	for _, use := range uses {
+		worklist = append(worklist, use.InstructionParent())
+	}
+	for len(worklist) != 0 {
+		bb := worklist[len(worklist)-1]
+		worklist = worklist[:len(worklist)-1]
+		if reachesUse[bb] {
+			continue
+		}
+		reachesUse[bb] = true
+		worklist = append(worklist, predecessors[bb]...)
+	}
+
+	// Check all instructions after inst in the same basic block. If there is
+	// no path to a use from the end of this basic block, only check up to the
+	// last use.
+	instBB := inst.InstructionParent()
+	end := llvm.Value{}
+	if !successorReaches(instBB, reachesUse) {
+		for i := llvm.NextInstruction(inst); !i.IsNil(); i = llvm.NextInstruction(i) {
+			for _, use := range uses {
+				if use == i {
+					end = llvm.NextInstruction(i)
+				}
+			}
+		}
+	}
+	for i := llvm.NextInstruction(inst); i != end; i = llvm.NextInstruction(i) {
+		if mayWriteMemory(i) {
+			return false
+		}
+	}
+
+	// Check all basic blocks that may run after inst and before a use. This
+	// is an overestimation, as it includes all instructions in these basic
+	// blocks.
+	visited := make(map[llvm.BasicBlock]bool)
+	worklist = append(worklist[:0], successors(instBB)...)
+	for len(worklist) != 0 {
+		bb := worklist[len(worklist)-1]
+		worklist = worklist[:len(worklist)-1]
+		if visited[bb] || !reachesUse[bb] {
+			continue
+		}
+		visited[bb] = true
+		for i := bb.FirstInstruction(); !i.IsNil(); i = llvm.NextInstruction(i) {
+			if i != inst && mayWriteMemory(i) {
+				return false
+			}
+		}
+		worklist = append(worklist, successors(bb)...)
+	}
+	return true
+}
+
+// successors returns the basic blocks that the terminator of bb may jump to.
+func successors(bb llvm.BasicBlock) []llvm.BasicBlock {
+	var result []llvm.BasicBlock
+	terminator := bb.LastInstruction()
+	for i := 0; i < terminator.OperandsCount(); i++ {
+		operand := terminator.Operand(i)
+		if operand.IsBasicBlock() {
+			result = append(result, operand.AsBasicBlock())
+		}
+	}
+	return result
+}
+
+// successorReaches returns whether one of the successors of bb is in the set.
+func successorReaches(bb llvm.BasicBlock, set map[llvm.BasicBlock]bool) bool {
+	for _, succ := range successors(bb) {
+		if set[succ] {
+			return true
+		}
+	}
+	return false
+}
+
+// mayWriteMemory returns true if the instruction may write to memory (other
+// than stack allocations that are only used by runtime calls), and false if it
+// certainly doesn't.
+func mayWriteMemory(inst llvm.Value) bool {
+	switch inst.InstructionOpcode() {
+	case llvm.Call:
+		name := inst.CalledValue().Name()
+		if strings.HasPrefix(name, "llvm.lifetime.") || strings.HasPrefix(name, "llvm.dbg.") {
+			return false
+		}
+		if name == "runtime.hashmapStringGet" {
+			// Writes the value to the buffer, which is a stack allocation.
+			return inst.Operand(3).IsAAllocaInst().IsNil()
+		}
+		return !stringReaders[name]
+	case llvm.Ret, llvm.Br, llvm.Switch, llvm.Unreachable,
+		llvm.Add, llvm.FAdd, llvm.Sub, llvm.FSub, llvm.Mul, llvm.FMul,
+		llvm.UDiv, llvm.SDiv, llvm.FDiv, llvm.URem, llvm.SRem, llvm.FRem,
+		llvm.Shl, llvm.LShr, llvm.AShr, llvm.And, llvm.Or, llvm.Xor,
+		llvm.Alloca, llvm.Load, llvm.GetElementPtr,
+		llvm.Trunc, llvm.ZExt, llvm.SExt, llvm.FPToUI, llvm.FPToSI,
+		llvm.UIToFP, llvm.SIToFP, llvm.FPTrunc, llvm.FPExt,
+		llvm.PtrToInt, llvm.IntToPtr, llvm.BitCast,
+		llvm.ICmp, llvm.FCmp, llvm.PHI, llvm.Select,
+		llvm.ExtractElement, llvm.InsertElement, llvm.ShuffleVector,
+		llvm.ExtractValue, llvm.InsertValue:
+		return false
+	default:
+		// Stores, atomic operations, and anything else that is unknown.
+		return true
+	}
+}
+
 // OptimizeStringEqual transforms runtime.stringEqual(...) calls into simple
 // integer comparisons if at least one of the sides of the comparison is zero.
 // Ths converts str == "" into len(str) == 0 and "" == "" into false.
@@ -0,0 +1,289 @@
+package transform
+
+import (
+	"tinygo.org/x/go-llvm"
+)
+
+// The fneg opcode is missing from the LLVM bindings. The value is from
+// llvm-c/Core.h.
+const opcodeFNeg llvm.Opcode = 66
+
+// softFloatRoutines are the compiler-rt functions that LowerSoftFloat may emit
+// calls to.
+var softFloatRoutines = func() map[string]bool {
+	routines := map[string]bool{
+		"__extendsfdf2": true,
+		"__truncdfsf2":  true,
+	}
+	for _, suffix := range []string{"sf", "df"} {
+		for _, op := range []string{"add", "sub", "mul", "div"} {
+			routines["__"+op+suffix+"3"] = true
+		}
+		for _, op := range []string{"eq", "ne", "lt", "le", "gt", "ge", "unord"} {
+			routines["__"+op+suffix+"2"] = true
+		}
+		for _, size := range []string{"si", "di"} {
+			routines["__fix"+suffix+size] = true
+			routines["__fixuns"+suffix+size] = true
+			routines["__float"+size+suffix] = true
+			routines["__floatun"+size+suffix] = true
+		}
+	}
+	return routines
+}()
+
+// LowerSoftFloat replaces every floating point operation in the module with a
+// call to the soft-float routines of compiler-rt, for -soft-float. Those only
+// use integer operations, so the results are the same on every host, including
+// the sign and payload of NaNs that WebAssembly leaves up to the host.
+//
+// Loads, stores, phis and selects of floating point values are left alone, as
+// they don't change the bits. The pass must run before the optimization
+// pipeline, which would otherwise merge operations into intrinsics (like
+// llvm.fmuladd) that can't be lowered. Operations without a compiler-rt
+// routine, like frem and most intrinsics, result in an error.
+func LowerSoftFloat(mod llvm.Module) []error {
+	ctx := mod.Context()
+	builder := ctx.NewBuilder()
+	defer builder.Dispose()
+
+	// Collect the instructions first, as they're erased while lowering.
+	var insts []llvm.Value
+	var errs []error
+	for fn := mod.FirstFunction(); !fn.IsNil(); fn = llvm.NextFunction(fn) {
+		for bb := fn.FirstBasicBlock(); !bb.IsNil(); bb = llvm.NextBasicBlock(bb) {
+			for inst := bb.FirstInstruction(); !inst.IsNil(); inst = llvm.NextInstruction(inst) {
+				switch inst.InstructionOpcode() {
+				case llvm.FAdd, llvm.FSub, llvm.FMul, llvm.FDiv, opcodeFNeg, llvm.FCmp,
+					llvm.FPToSI, llvm.FPToUI, llvm.SIToFP, llvm.UIToFP, llvm.FPExt, llvm.FPTrunc:
+					insts = append(insts, inst)
+				case llvm.FRem:
+					errs = append(errs, errorAt(inst, "frem can't be lowered to soft-float, in function "+fn.Name()))
+				case llvm.Call:
+					callee := inst.CalledValue()
+					if callee.IsAFunction().IsNil() || callee.IntrinsicID() == 0 {
+						continue
+					}
+					usesFloat := isSoftFloatType(inst.Type())
+					for i := 0; i < inst.OperandsCount()-1; i++ {
+						usesFloat = usesFloat || isSoftFloatType(inst.Operand(i).Type())
+					}
+					if usesFloat {
+						errs = append(errs, errorAt(inst, callee.Name()+" can't be lowered to soft-float, in function "+fn.Name()))
+					}
+				}
+			}
+		}
+	}
+
+	// call emits a call to the given compiler-rt routine, declaring it if
+	// needed.
+	call := func(name string, resultType llvm.Type, args ...llvm.Value) llvm.Value {
+		var paramTypes []llvm.Type
+		for _, arg := range args {
+			paramTypes = append(paramTypes, arg.Type())
+		}
+		fnType := llvm.FunctionType(resultType, paramTypes, false)
+		fn := mod.NamedFunction(name)
+		if fn.IsNil() {
+			fn = llvm.AddFunction(mod, name, fnType)
+		}
+		return builder.CreateCall(fnType, fn, args, "")
+	}
+	i32 := ctx.Int32Type()
+	zero := llvm.ConstInt(i32, 0, false)
+
+	for _, inst := range insts {
+		builder.SetInsertPointBefore(inst)
+		var suffix string // the type of the floating point operand
+		var replacement llvm.Value
+		switch inst.InstructionOpcode() {
+		case llvm.FAdd, llvm.FSub, llvm.FMul, llvm.FDiv:
+			suffix = softFloatSuffix(inst.Type())
+			if suffix == "" {
+				break
+			}
+			op := map[llvm.Opcode]string{llvm.FAdd: "add", llvm.FSub: "sub", llvm.FMul: "mul", llvm.FDiv: "div"}[inst.InstructionOpcode()]
+			replacement = call("__"+op+suffix+"3", inst.Type(), inst.Operand(0), inst.Operand(1))
+		case opcodeFNeg:
+			// Flip the sign bit, like the fneg instruction itself.
+			suffix = softFloatSuffix(inst.Type())
+			intType, signBit := ctx.Int32Type(), uint64(1)<<31
+			if suffix == "df" {
+				intType, signBit = ctx.Int64Type(), 1<<63
+			} else if suffix == "" {
+				break
+			}
+			bits := builder.CreateBitCast(inst.Operand(0), intType, "")
+			bits = builder.CreateXor(bits, llvm.ConstInt(intType, signBit, false), "")
+			replacement = builder.CreateBitCast(bits, inst.Type(), "")
+		case llvm.FCmp:
+			suffix = softFloatSuffix(inst.Operand(0).Type())
+			if suffix == "" {
+				break
+			}
+			// compare calls one of the comparison routines and compares its
+			// result against zero. The routines differ only in the value they
+			// return for unordered operands (NaNs).
+			compare := func(op string, pred llvm.IntPredicate) llvm.Value {
+				result := call("__"+op+suffix+"2", i32, inst.Operand(0), inst.Operand(1))
+				return builder.CreateICmp(pred, result, zero, "")
+			}
+			switch inst.FloatPredicate() {
+			case llvm.FloatPredicateFalse:
+				replacement = llvm.ConstInt(ctx.Int1Type(), 0, false)
+			case llvm.FloatOEQ:
+				replacement = compare("eq", llvm.IntEQ)
+			case llvm.FloatOGT:
+				replacement = compare("gt", llvm.IntSGT)
+			case llvm.FloatOGE:
+				replacement = compare("ge", llvm.IntSGE)
+			case llvm.FloatOLT:
+				replacement = compare("lt", llvm.IntSLT)
+			case llvm.FloatOLE:
+				replacement = compare("le", llvm.IntSLE)
+			case llvm.FloatONE:
+				replacement = builder.CreateAnd(compare("eq", llvm.IntNE), compare("unord", llvm.IntEQ), "")
+			case llvm.FloatORD:
+				replacement = compare("unord", llvm.IntEQ)
+			case llvm.FloatUNO:
+				replacement = compare("unord", llvm.IntNE)
+			case llvm.FloatUEQ:
+				replacement = builder.CreateOr(compare("eq", llvm.IntEQ), compare("unord", llvm.IntNE), "")
+			case llvm.FloatUGT:
+				replacement = compare("le", llvm.IntSGT)
+			case llvm.FloatUGE:
+				replacement = compare("lt", llvm.IntSGE)
+			case llvm.FloatULT:
+				replacement = compare("ge", llvm.IntSLT)
+			case llvm.FloatULE:
+				replacement = compare("gt", llvm.IntSLE)
+			case llvm.FloatUNE:
+				replacement = compare("ne", llvm.IntNE)
+			case llvm.FloatPredicateTrue:
+				replacement = llvm.ConstInt(ctx.Int1Type(), 1, false)
+			}
+		case llvm.FPToSI, llvm.FPToUI:
+			suffix = softFloatSuffix(inst.Operand(0).Type())
+			if suffix == "" {
+				break
+			}
+			name := "__fix" + suffix
+			if inst.InstructionOpcode() == llvm.FPToUI {
+				name = "__fixuns" + suffix
+			}
+			switch width := inst.Type().IntTypeWidth(); {
+			case width == 64:
+				replacement = call(name+"di", inst.Type(), inst.Operand(0))
+			case width <= 32:
+				replacement = call(name+"si", i32, inst.Operand(0))
+				replacement = builder.CreateTruncOrBitCast(replacement, inst.Type(), "")
+			default:
+				suffix = ""
+			}
+		case llvm.SIToFP, llvm.UIToFP:
+			suffix = softFloatSuffix(inst.Type())
+			if suffix == "" {
+				break
+			}
+			name, value := "__float", inst.Operand(0)
+			if inst.InstructionOpcode() == llvm.UIToFP {
+				name = "__floatun"
+			}
+			switch width := value.Type().IntTypeWidth(); {
+			case width == 64:
+				replacement = call(name+"di"+suffix, inst.Type(), value)
+			case width <= 32:
+				if inst.InstructionOpcode() == llvm.UIToFP {
+					value = builder.CreateZExtOrBitCast(value, i32, "")
+				} else {
+					value = builder.CreateSExtOrBitCast(value, i32, "")
+				}
+				replacement = call(name+"si"+suffix, inst.Type(), value)
+			default:
+				suffix = ""
+			}
+		case llvm.FPExt, llvm.FPTrunc:
+			from, to := softFloatSuffix(inst.Operand(0).Type()), softFloatSuffix(inst.Type())
+			switch {
+			case from == "sf" && to == "df":
+				suffix = from
+				replacement = call("__extendsfdf2", inst.Type(), inst.Operand(0))
+			case from == "df" && to == "sf":
+				suffix = from
+				replacement = call("__truncdfsf2", inst.Type(), inst.Operand(0))
+			}
+		}
+		if suffix == "" {
+			// Not a float or double, or an integer type that compiler-rt has
+			// no conversion routine for.
+			errs = append(errs, errorAt(inst, "operation can't be lowered to soft-float, in function "+inst.InstructionParent().Parent().Name()))
+			continue
+		}
+		if name := inst.Name(); name != "" && !replacement.IsAInstruction().IsNil() {
+			inst.SetName("")
+			replacement.SetName(name)
+		}
+		inst.ReplaceAllUsesWith(replacement)
+		inst.EraseFromParentAsInstruction()
+	}
+	return errs
+}
+
+// softFloatSuffix returns the type suffix of the compiler-rt routines for the
+// given floating point type, or the empty string if there are none.
+func softFloatSuffix(t llvm.Type) string {
+	switch t.TypeKind() {
+	case llvm.FloatTypeKind:
+		return "sf"
+	case llvm.DoubleTypeKind:
+		return "df"
+	default:
+		return ""
+	}
+}
+
+// isSoftFloatType returns whether the type is (or contains) a floating point
+// type.
+func isSoftFloatType(t llvm.Type) bool {
+	switch t.TypeKind() {
+	case llvm.FloatTypeKind, llvm.DoubleTypeKind, llvm.X86_FP80TypeKind, llvm.FP128TypeKind, llvm.PPC_FP128TypeKind:
+		return true
+	case llvm.VectorTypeKind:
+		return isSoftFloatType(t.ElementType())
+	default:
+		return false
+	}
+}
+
+// IsSoftFloatRoutine returns whether the function name is one of the
+// compiler-rt routines that LowerSoftFloat emits calls to.
+func IsSoftFloatRoutine(name string) bool {
+	return softFloatRoutines[name]
+}
+
+// SoftFloatCallers returns the names of the functions defined in the module
+// that call a soft-float routine, for the -size report.
+func SoftFloatCallers(mod llvm.Module) []string {
+	var names []string
+	for fn := mod.FirstFunction(); !fn.IsNil(); fn = llvm.NextFunction(fn) {
+		if !softFloatRoutines[fn.Name()] {
+			continue
+		}
+		for use := fn.FirstUse(); !use.IsNil(); use = use.NextUse() {
+			caller := use.User()
+			if caller.IsACallInst().IsNil() {
+				continue
+			}
+			name := caller.InstructionParent().Parent().Name()
+			found := false
+			for _, n := range names {
+				found = found || n == name
+			}
+			if !found {
+				names = append(names, name)
+			}
+		}
+	}
+	return names
+}
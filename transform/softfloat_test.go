@@ -0,0 +1,50 @@
+package transform_test
+
+import (
+	"testing"
+
+	"github.com/tinygo-org/tinygo/transform"
+	"tinygo.org/x/go-llvm"
+)
+
+func TestLowerSoftFloat(t *testing.T) {
+	t.Parallel()
+	testTransform(t, "testdata/softfloat", func(mod llvm.Module) {
+		for _, err := range transform.LowerSoftFloat(mod) {
+			t.Error("failed to lower:", err)
+		}
+	})
+}
+
+func TestLowerSoftFloatErrors(t *testing.T) {
+	t.Parallel()
+	ctx := llvm.NewContext()
+	defer ctx.Dispose()
+	buf, err := llvm.NewMemoryBufferFromFile("testdata/softfloat-errors.ll")
+	if err != nil {
+		t.Fatal("could not read file:", err)
+	}
+	mod, err := ctx.ParseIR(buf)
+	if err != nil {
+		t.Fatal("could not load module:", err)
+	}
+	defer mod.Dispose()
+
+	var errs []string
+	for _, err := range transform.LowerSoftFloat(mod) {
+		errs = append(errs, err.Error())
+	}
+	expected := []string{
+		"frem can't be lowered to soft-float, in function main.remainder",
+		"llvm.fma.f64 can't be lowered to soft-float, in function main.fma",
+		"operation can't be lowered to soft-float, in function main.wide",
+	}
+	if len(errs) != len(expected) {
+		t.Fatalf("expected %d errors, got %d: %q", len(expected), len(errs), errs)
+	}
+	for i := range expected {
+		if errs[i] != expected[i] {
+			t.Errorf("error %d: expected %q, got %q", i, expected[i], errs[i])
+		}
+	}
+}
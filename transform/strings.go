@@ -0,0 +1,83 @@
+package transform
+
+import (
+	"sort"
+	"strings"
+
+	"tinygo.org/x/go-llvm"
+)
+
+// MergeStringConstants deduplicates the backing arrays of string constants
+// across all packages. Every package emits its own copy of a string literal,
+// and a string literal may also be part of a longer literal (for example,
+// "error" and "unexpected error"). This pass keeps a single data entry for all
+// of them: identical strings and strings that are contained in a longer string
+// are replaced with a pointer into the longer string.
+//
+// Only the globals created by the compiler for string constants (named
+// pkg$string) are merged. Go code can't compare the address of those, so
+// merging them is safe.
+func MergeStringConstants(mod llvm.Module) {
+	// Collect all string constants.
+	type stringGlobal struct {
+		global llvm.Value
+		value  string
+	}
+	var globals []stringGlobal
+	for global := mod.FirstGlobal(); !global.IsNil(); global = llvm.NextGlobal(global) {
+		if !strings.Contains(global.Name(), "$string") || global.IsDeclaration() {
+			continue
+		}
+		if !global.IsGlobalConstant() || global.Linkage() != llvm.InternalLinkage || global.Section() != "" || global.Alignment() > 1 {
+			continue
+		}
+		initializer := global.Initializer()
+		if !initializer.IsConstantString() {
+			continue
+		}
+		globals = append(globals, stringGlobal{global, initializer.ConstGetAsString()})
+	}
+
+	// Longest strings first, so that shorter strings can be found inside
+	// them. Sort by name for equal lengths to make the output deterministic.
+	sort.SliceStable(globals, func(i, j int) bool {
+		if len(globals[i].value) != len(globals[j].value) {
+			return len(globals[i].value) > len(globals[j].value)
+		}
+		return globals[i].global.Name() < globals[j].global.Name()
+	})
+
+	// Replace each string with a pointer into an earlier (longer or equal)
+	// string if possible.
+	var kept []stringGlobal
+	exact := make(map[string]llvm.Value)
+	i8 := mod.Context().Int8Type()
+	i32 := mod.Context().Int32Type()
+	for _, s := range globals {
+		var replacement llvm.Value
+		if global, ok := exact[s.value]; ok {
+			replacement = global
+		} else {
+			for _, k := range kept {
+				offset := strings.Index(k.value, s.value)
+				if offset < 0 {
+					continue
+				}
+				replacement = k.global
+				if offset != 0 {
+					replacement = llvm.ConstInBoundsGEP(i8, k.global, []llvm.Value{
+						llvm.ConstInt(i32, uint64(offset), false),
+					})
+				}
+				break
+			}
+		}
+		if replacement.IsNil() {
+			kept = append(kept, s)
+			exact[s.value] = s.global
+			continue
+		}
+		s.global.ReplaceAllUsesWith(llvm.ConstBitCast(replacement, s.global.Type()))
+		s.global.EraseFromParentAsGlobal()
+	}
+}
@@ -0,0 +1,15 @@
+package transform_test
+
+import (
+	"testing"
+
+	"github.com/tinygo-org/tinygo/transform"
+	"tinygo.org/x/go-llvm"
+)
+
+func TestMergeStringConstants(t *testing.T) {
+	t.Parallel()
+	testTransform(t, "testdata/strings", func(mod llvm.Module) {
+		transform.MergeStringConstants(mod)
+	})
+}
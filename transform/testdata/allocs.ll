@@ -70,6 +70,66 @@ define void @testZeroSizedAlloc() {
   ret void
 }
 
+; Create a small slice that is only passed to a function that doesn't let it
+; escape, like make([]byte, 8).
+define void @testNonEscapingSlice() {
+  %alloc = call ptr @runtime.alloc(i32 8, ptr null)
+  %slice.ptr = insertvalue { ptr, i32, i32 } undef, ptr %alloc, 0
+  %slice.len = insertvalue { ptr, i32, i32 } %slice.ptr, i32 8, 1
+  %slice.cap = insertvalue { ptr, i32, i32 } %slice.len, i32 8, 2
+  %len = extractvalue { ptr, i32, i32 } %slice.cap, 1
+  %buf = extractvalue { ptr, i32, i32 } %slice.cap, 0
+  %ptr = call ptr @noescapeIntPtr(ptr %buf)
+  ret void
+}
+
+; Return a small slice, which lets the backing array escape.
+define { ptr, i32, i32 } @testEscapingSlice() {
+  %alloc = call ptr @runtime.alloc(i32 8, ptr null)
+  %slice.ptr = insertvalue { ptr, i32, i32 } undef, ptr %alloc, 0
+  %slice.len = insertvalue { ptr, i32, i32 } %slice.ptr, i32 8, 1
+  %slice.cap = insertvalue { ptr, i32, i32 } %slice.len, i32 8, 2
+  ret { ptr, i32, i32 } %slice.cap
+}
+
+; Box a value in an interface that is only used locally.
+define i32 @testNonEscapingInterface(i1 %cond) {
+  %alloc = call ptr @runtime.alloc(i32 4, ptr null)
+  store i32 5, ptr %alloc
+  %itf.typecode = insertvalue { ptr, ptr } undef, ptr @runtime.zeroSizedAlloc, 0
+  %itf = insertvalue { ptr, ptr } %itf.typecode, ptr %alloc, 1
+  %typecode = extractvalue { ptr, ptr } %itf, 0
+  %value = extractvalue { ptr, ptr } %itf, 1
+  %select = select i1 %cond, ptr %value, ptr null
+  %isnil = icmp eq ptr %select, null
+  %result = load i32, ptr %value
+  ret i32 %result
+}
+
+; Store an interface containing a pointer, which lets the pointer escape.
+define void @testEscapingInterface(ptr %out) {
+  %alloc = call ptr @runtime.alloc(i32 4, ptr null)
+  %itf.typecode = insertvalue { ptr, ptr } undef, ptr @runtime.zeroSizedAlloc, 0
+  %itf = insertvalue { ptr, ptr } %itf.typecode, ptr %alloc, 1
+  store { ptr, ptr } %itf, ptr %out
+  ret void
+}
+
+; A phi node may carry the pointer to the next loop iteration, so it must be
+; heap allocated.
+define void @testPhiLoop() {
+entry:
+  br label %loop
+loop:
+  %prev = phi ptr [ null, %entry ], [ %alloc, %loop ]
+  %alloc = call ptr @runtime.alloc(i32 4, ptr null)
+  %ptr = call ptr @noescapeIntPtr(ptr %alloc)
+  %result = icmp eq ptr null, %ptr
+  br i1 %result, label %loop, label %end
+end:
+  ret void
+}
+
 declare ptr @escapeIntPtr(ptr)
 
 declare ptr @noescapeIntPtr(ptr nocapture)
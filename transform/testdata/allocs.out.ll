@@ -66,6 +66,63 @@ define void @testZeroSizedAlloc() {
   ret void
 }
 
+define void @testNonEscapingSlice() {
+  %stackalloc = alloca [8 x i8], align 4
+  store [8 x i8] zeroinitializer, ptr %stackalloc, align 4
+  %slice.ptr = insertvalue { ptr, i32, i32 } undef, ptr %stackalloc, 0
+  %slice.len = insertvalue { ptr, i32, i32 } %slice.ptr, i32 8, 1
+  %slice.cap = insertvalue { ptr, i32, i32 } %slice.len, i32 8, 2
+  %len = extractvalue { ptr, i32, i32 } %slice.cap, 1
+  %buf = extractvalue { ptr, i32, i32 } %slice.cap, 0
+  %ptr = call ptr @noescapeIntPtr(ptr %buf)
+  ret void
+}
+
+define { ptr, i32, i32 } @testEscapingSlice() {
+  %alloc = call ptr @runtime.alloc(i32 8, ptr null)
+  %slice.ptr = insertvalue { ptr, i32, i32 } undef, ptr %alloc, 0
+  %slice.len = insertvalue { ptr, i32, i32 } %slice.ptr, i32 8, 1
+  %slice.cap = insertvalue { ptr, i32, i32 } %slice.len, i32 8, 2
+  ret { ptr, i32, i32 } %slice.cap
+}
+
+define i32 @testNonEscapingInterface(i1 %cond) {
+  %stackalloc = alloca [4 x i8], align 4
+  store [4 x i8] zeroinitializer, ptr %stackalloc, align 4
+  store i32 5, ptr %stackalloc, align 4
+  %itf.typecode = insertvalue { ptr, ptr } undef, ptr @runtime.zeroSizedAlloc, 0
+  %itf = insertvalue { ptr, ptr } %itf.typecode, ptr %stackalloc, 1
+  %typecode = extractvalue { ptr, ptr } %itf, 0
+  %value = extractvalue { ptr, ptr } %itf, 1
+  %select = select i1 %cond, ptr %value, ptr null
+  %isnil = icmp eq ptr %select, null
+  %result = load i32, ptr %value, align 4
+  ret i32 %result
+}
+
+define void @testEscapingInterface(ptr %out) {
+  %alloc = call ptr @runtime.alloc(i32 4, ptr null)
+  %itf.typecode = insertvalue { ptr, ptr } undef, ptr @runtime.zeroSizedAlloc, 0
+  %itf = insertvalue { ptr, ptr } %itf.typecode, ptr %alloc, 1
+  store { ptr, ptr } %itf, ptr %out, align 4
+  ret void
+}
+
+define void @testPhiLoop() {
+entry:
+  br label %loop
+
+loop:                                             ; preds = %loop, %entry
+  %prev = phi ptr [ null, %entry ], [ %alloc, %loop ]
+  %alloc = call ptr @runtime.alloc(i32 4, ptr null)
+  %ptr = call ptr @noescapeIntPtr(ptr %alloc)
+  %result = icmp eq ptr null, %ptr
+  br i1 %result, label %loop, label %end
+
+end:                                              ; preds = %loop
+  ret void
+}
+
 declare ptr @escapeIntPtr(ptr)
 
 declare ptr @noescapeIntPtr(ptr nocapture)
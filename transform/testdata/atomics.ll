@@ -0,0 +1,38 @@
+target datalayout = "e-m:e-p:32:32-i64:64-n32:64-S128-ni:1:10:20"
+target triple = "wasm32-unknown-wasi"
+
+; Lowered by the compiler: plain loads and stores.
+define i32 @main.add(ptr %ptr, i32 %delta) {
+entry:
+  %old = load i32, ptr %ptr, align 4
+  %new = add i32 %old, %delta
+  store i32 %new, ptr %ptr, align 4
+  ret i32 %new
+}
+
+define i32 @main.atomicAdd(ptr %ptr, i32 %delta) {
+entry:
+  %old = atomicrmw add ptr %ptr, i32 %delta seq_cst, align 4
+  %new = add i32 %old, %delta
+  ret i32 %new
+}
+
+define i1 @main.compareAndSwap(ptr %ptr, i32 %old, i32 %new) {
+entry:
+  %tuple = cmpxchg ptr %ptr, i32 %old, i32 %new seq_cst seq_cst, align 4
+  %swapped = extractvalue { i32, i1 } %tuple, 1
+  ret i1 %swapped
+}
+
+define i32 @main.load(ptr %ptr) {
+entry:
+  fence seq_cst
+  %val = load atomic i32, ptr %ptr seq_cst, align 4
+  ret i32 %val
+}
+
+define void @main.store(ptr %ptr, i32 %val) {
+entry:
+  store atomic i32 %val, ptr %ptr seq_cst, align 4
+  ret void
+}
@@ -0,0 +1,40 @@
+target datalayout = "e-m:e-p:32:32-i64:64-n32:64-S128-ni:1:10:20"
+target triple = "wasm32-unknown-unknown"
+
+declare void @runtime.chanSend(ptr, ptr, ptr, ptr)
+
+declare i1 @runtime.chanRecv(ptr, ptr, ptr, ptr)
+
+; A module without a dot in its path.
+define void @"myapp/worker.Send"(ptr %ch, ptr %value) !dbg !2 {
+entry:
+  call void @runtime.chanSend(ptr %ch, ptr %value, ptr undef, ptr undef), !dbg !4
+  ret void
+}
+
+define i1 @"(*myapp/worker.Queue).Recv"(ptr %ch, ptr %value) !dbg !5 {
+entry:
+  %ok = call i1 @runtime.chanRecv(ptr %ch, ptr %value, ptr undef, ptr undef), !dbg !6
+  ret i1 %ok
+}
+
+; A package of the standard library.
+define void @"(*os/signal.handler).notify"(ptr %ch, ptr %value) !dbg !7 {
+entry:
+  call void @runtime.chanSend(ptr %ch, ptr %value, ptr undef, ptr undef), !dbg !8
+  ret void
+}
+
+!llvm.dbg.cu = !{!0}
+!llvm.module.flags = !{!9}
+
+!0 = distinct !DICompileUnit(language: DW_LANG_C99, file: !1, producer: "TinyGo", isOptimized: true, runtimeVersion: 0, emissionKind: FullDebug)
+!1 = !DIFile(filename: "worker.go", directory: "/app")
+!2 = distinct !DISubprogram(name: "myapp/worker.Send", scope: !1, file: !1, line: 3, type: !3, scopeLine: 3, spFlags: DISPFlagDefinition, unit: !0)
+!3 = !DISubroutineType(types: !{})
+!4 = !DILocation(line: 4, column: 5, scope: !2)
+!5 = distinct !DISubprogram(name: "(*myapp/worker.Queue).Recv", scope: !1, file: !1, line: 7, type: !3, scopeLine: 7, spFlags: DISPFlagDefinition, unit: !0)
+!6 = !DILocation(line: 8, column: 9, scope: !5)
+!7 = distinct !DISubprogram(name: "(*os/signal.handler).notify", scope: !1, file: !1, line: 12, type: !3, scopeLine: 12, spFlags: DISPFlagDefinition, unit: !0)
+!8 = !DILocation(line: 13, column: 5, scope: !7)
+!9 = !{i32 2, !"Debug Info Version", i32 3}
@@ -0,0 +1,53 @@
+target datalayout = "e-m:e-p:32:32-i64:64-n32:64-S128-ni:1:10:20"
+target triple = "wasm32-unknown-unknown"
+
+@"main$string" = internal unnamed_addr constant [5 x i8] c"oops!", align 1
+@main.counter = internal global i32 0, align 4
+
+declare void @runtime.abort()
+
+; Used by both normal and cold code, so it must stay.
+define internal void @runtime.printstring(ptr %s.data, i32 %s.len) {
+entry:
+  %0 = load i32, ptr @main.counter, align 4
+  %1 = add i32 %0, %s.len
+  store i32 %1, ptr @main.counter, align 4
+  ret void
+}
+
+; Only called from a cold function.
+define internal void @runtime.printPanicValue(ptr %s.data, i32 %s.len) {
+entry:
+  call void @runtime.printstring(ptr %s.data, i32 %s.len)
+  ret void
+}
+
+; Only called before an unreachable instruction.
+define internal void @runtime.printPanic(ptr %s.data, i32 %s.len) {
+entry:
+  call void @runtime.printPanicValue(ptr %s.data, i32 %s.len)
+  ret void
+}
+
+; Only called before an unreachable instruction.
+define internal void @runtime._panic(ptr %s.data, i32 %s.len) {
+entry:
+  call void @runtime.printPanic(ptr %s.data, i32 %s.len)
+  call void @runtime.abort()
+  unreachable
+}
+
+define void @main.check(i1 %ok) #0 {
+entry:
+  br i1 %ok, label %done, label %fail
+
+fail:
+  call void @runtime._panic(ptr @"main$string", i32 5)
+  unreachable
+
+done:
+  call void @runtime.printstring(ptr @"main$string", i32 5)
+  ret void
+}
+
+attributes #0 = { "wasm-export-name"="check" }
@@ -0,0 +1,40 @@
+target datalayout = "e-m:e-p:32:32-i64:64-n32:64-S128-ni:1:10:20"
+target triple = "wasm32-unknown-unknown"
+
+@"main$string" = hidden unnamed_addr constant [5 x i8] c"oops!", align 1
+@main.counter = hidden global i32 0, align 4
+
+declare void @runtime.abort()
+
+define hidden void @runtime.printstring(ptr %s.data, i32 %s.len) {
+entry:
+  %0 = load i32, ptr @main.counter, align 4
+  %1 = add i32 %0, %s.len
+  store i32 %1, ptr @main.counter, align 4
+  ret void
+}
+
+define void @main.check(i1 %ok) #0 {
+entry:
+  br i1 %ok, label %done, label %fail
+
+fail:                                             ; preds = %entry
+  call void @runtime._panic(ptr @"main$string", i32 5)
+  unreachable
+
+done:                                             ; preds = %entry
+  call void @runtime.printstring(ptr @"main$string", i32 5)
+  ret void
+}
+
+; Function Attrs: cold
+declare hidden void @runtime.printPanic(ptr, i32) #1
+
+; Function Attrs: cold
+declare hidden void @runtime._panic(ptr, i32) #1
+
+; Function Attrs: cold
+declare hidden void @runtime.printPanicValue(ptr, i32) #1
+
+attributes #0 = { "wasm-export-name"="check" }
+attributes #1 = { cold }
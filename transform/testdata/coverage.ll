@@ -0,0 +1,46 @@
+target datalayout = "e-m:e-p:32:32-i64:64-n32:64-S128-ni:1:10:20"
+target triple = "wasm32-unknown-wasi"
+
+@runtime.coverageCounters = global { ptr, i32, i32 } zeroinitializer
+
+define i32 @main.abs(i32 %x, ptr %context) !dbg !5 {
+entry:
+  %neg = icmp slt i32 %x, 0, !dbg !7
+  br i1 %neg, label %negative, label %positive, !dbg !7
+
+negative:
+  %negated = sub i32 0, %x, !dbg !8
+  ret i32 %negated, !dbg !9
+
+positive:
+  %result = phi i32 [ %x, %entry ]
+  ret i32 %result, !dbg !10
+
+; This block has the same source range as the positive block, so it shares
+; its counter.
+unused:
+  ret i32 %x, !dbg !10
+}
+
+; Functions in files that are not covered are not instrumented.
+define void @runtime.printint32(i32 %n, ptr %context) !dbg !11 {
+entry:
+  ret void, !dbg !13
+}
+
+!llvm.dbg.cu = !{!0}
+!llvm.module.flags = !{!3, !4}
+
+!0 = distinct !DICompileUnit(language: DW_LANG_Go, file: !1, producer: "TinyGo", isOptimized: true, runtimeVersion: 0, emissionKind: FullDebug)
+!1 = !DIFile(filename: "main.go", directory: "/src/example")
+!2 = !DIFile(filename: "print.go", directory: "/src/runtime")
+!3 = !{i32 7, !"Dwarf Version", i32 4}
+!4 = !{i32 2, !"Debug Info Version", i32 3}
+!5 = distinct !DISubprogram(name: "abs", scope: !1, file: !1, line: 3, type: !6, scopeLine: 3, spFlags: DISPFlagDefinition, unit: !0)
+!6 = !DISubroutineType(types: !{})
+!7 = !DILocation(line: 4, column: 7, scope: !5)
+!8 = !DILocation(line: 5, column: 10, scope: !5)
+!9 = !DILocation(line: 5, column: 3, scope: !5)
+!10 = !DILocation(line: 7, column: 2, scope: !5)
+!11 = distinct !DISubprogram(name: "printint32", scope: !2, file: !2, line: 1, type: !6, scopeLine: 1, spFlags: DISPFlagDefinition, unit: !0)
+!13 = !DILocation(line: 2, column: 1, scope: !11)
@@ -0,0 +1,57 @@
+target datalayout = "e-m:e-p:32:32-i64:64-n32:64-S128-ni:1:10:20"
+target triple = "wasm32-unknown-wasi"
+
+@runtime.coverageCounters = global { ptr, i32, i32 } { ptr @tinygo_coverage_counters, i32 3, i32 3 }
+@tinygo_coverage_counters = internal global [3 x i32] zeroinitializer, section "tinygo_coverage", align 4
+
+define i32 @main.abs(i32 %x, ptr %context) !dbg !4 {
+entry:
+  %0 = load i32, ptr getelementptr inbounds ([3 x i32], ptr @tinygo_coverage_counters, i32 0, i32 0), align 4, !dbg !7
+  %1 = add i32 %0, 1, !dbg !7
+  store i32 %1, ptr getelementptr inbounds ([3 x i32], ptr @tinygo_coverage_counters, i32 0, i32 0), align 4, !dbg !7
+  %neg = icmp slt i32 %x, 0, !dbg !7
+  br i1 %neg, label %negative, label %positive, !dbg !7
+
+negative:                                         ; preds = %entry
+  %2 = load i32, ptr getelementptr inbounds ([3 x i32], ptr @tinygo_coverage_counters, i32 0, i32 1), align 4, !dbg !8
+  %3 = add i32 %2, 1, !dbg !8
+  store i32 %3, ptr getelementptr inbounds ([3 x i32], ptr @tinygo_coverage_counters, i32 0, i32 1), align 4, !dbg !8
+  %negated = sub i32 0, %x, !dbg !8
+  ret i32 %negated, !dbg !9
+
+positive:                                         ; preds = %entry
+  %result = phi i32 [ %x, %entry ]
+  %4 = load i32, ptr getelementptr inbounds ([3 x i32], ptr @tinygo_coverage_counters, i32 0, i32 2), align 4, !dbg !10
+  %5 = add i32 %4, 1, !dbg !10
+  store i32 %5, ptr getelementptr inbounds ([3 x i32], ptr @tinygo_coverage_counters, i32 0, i32 2), align 4, !dbg !10
+  ret i32 %result, !dbg !10
+
+unused:                                           ; No predecessors!
+  %6 = load i32, ptr getelementptr inbounds ([3 x i32], ptr @tinygo_coverage_counters, i32 0, i32 2), align 4, !dbg !10
+  %7 = add i32 %6, 1, !dbg !10
+  store i32 %7, ptr getelementptr inbounds ([3 x i32], ptr @tinygo_coverage_counters, i32 0, i32 2), align 4, !dbg !10
+  ret i32 %x, !dbg !10
+}
+
+define void @runtime.printint32(i32 %n, ptr %context) !dbg !11 {
+entry:
+  ret void, !dbg !13
+}
+
+!llvm.dbg.cu = !{!0}
+!llvm.module.flags = !{!2, !3}
+
+!0 = distinct !DICompileUnit(language: DW_LANG_Go, file: !1, producer: "TinyGo", isOptimized: true, runtimeVersion: 0, emissionKind: FullDebug)
+!1 = !DIFile(filename: "main.go", directory: "/src/example")
+!2 = !{i32 7, !"Dwarf Version", i32 4}
+!3 = !{i32 2, !"Debug Info Version", i32 3}
+!4 = distinct !DISubprogram(name: "abs", scope: !1, file: !1, line: 3, type: !5, scopeLine: 3, spFlags: DISPFlagDefinition, unit: !0)
+!5 = !DISubroutineType(types: !6)
+!6 = !{}
+!7 = !DILocation(line: 4, column: 7, scope: !4)
+!8 = !DILocation(line: 5, column: 10, scope: !4)
+!9 = !DILocation(line: 5, column: 3, scope: !4)
+!10 = !DILocation(line: 7, column: 2, scope: !4)
+!11 = distinct !DISubprogram(name: "printint32", scope: !12, file: !12, line: 1, type: !5, scopeLine: 1, spFlags: DISPFlagDefinition, unit: !0)
+!12 = !DIFile(filename: "print.go", directory: "/src/runtime")
+!13 = !DILocation(line: 2, column: 1, scope: !11)
@@ -0,0 +1,52 @@
+target datalayout = "e-m:e-p:32:32-i64:64-n32:64-S128-ni:1:10:20"
+target triple = "wasm32-unknown-unknown"
+
+@llvm.used = appending global [3 x ptr] [ptr @_initialize, ptr @Core_version, ptr @Core_initialize_block]
+
+declare void @runtime.wasmExportEnter(i32, ptr)
+
+declare void @runtime.wasmExportExit(ptr)
+
+declare void @runtime.initAll(ptr)
+
+declare i64 @main.version(ptr, ptr)
+
+@runtime.exitCode = global i32 -1
+
+; Already initializes the program, so it isn't wrapped.
+define void @_initialize() #0 {
+entry:
+  call void @runtime.initAll(ptr undef)
+  ret void
+}
+
+define i64 @Core_version(i32 %data, i32 %len) #1 {
+entry:
+  %buf = alloca [8 x i8], align 1
+  %result = call i64 @main.version(ptr %buf, ptr undef)
+  ret i64 %result
+}
+
+define void @Core_initialize_block(i32 %data, i32 %len) #2 {
+entry:
+  ret void
+}
+
+; Read by the host after the program exited, so it isn't wrapped.
+define i32 @tinygo_exit_code() #3 {
+entry:
+  %code = load i32, ptr @runtime.exitCode, align 4
+  ret i32 %code
+}
+
+; Calls from within the program skip the prologue.
+define i64 @main.callVersion(ptr %context) {
+entry:
+  %result = call i64 @Core_version(i32 0, i32 0)
+  ret i64 %result
+}
+
+attributes #0 = { "wasm-export-name"="_initialize" }
+attributes #1 = { "wasm-export-name"="Core_version" }
+attributes #2 = { "wasm-export-name"="Core_initialize_block" }
+attributes #3 = { "wasm-export-name"="tinygo_exit_code" }
@@ -0,0 +1,70 @@
+target datalayout = "e-m:e-p:32:32-i64:64-n32:64-S128-ni:1:10:20"
+target triple = "wasm32-unknown-unknown"
+
+@runtime.exitCode = global i32 -1
+
+@llvm.used = appending global [5 x ptr] [ptr @_initialize, ptr @"Core_version$body", ptr @"Core_initialize_block$body", ptr @Core_version, ptr @Core_initialize_block]
+
+declare void @runtime.wasmExportEnter(i32, ptr)
+
+declare void @runtime.wasmExportExit(ptr)
+
+declare void @runtime.initAll(ptr)
+
+declare i64 @main.version(ptr, ptr)
+
+define void @_initialize() #0 {
+entry:
+  call void @runtime.initAll(ptr undef)
+  ret void
+}
+
+define i64 @"Core_version$body"(i32 %data, i32 %len) #1 {
+entry:
+  %buf = alloca [8 x i8], align 1
+  %result = call i64 @main.version(ptr %buf, ptr undef)
+  ret i64 %result
+}
+
+define void @"Core_initialize_block$body"(i32 %data, i32 %len) #1 {
+entry:
+  ret void
+}
+
+define i32 @tinygo_exit_code() #2 {
+entry:
+  %code = load i32, ptr @runtime.exitCode, align 4
+  ret i32 %code
+}
+
+define i64 @main.callVersion(ptr %context) {
+entry:
+  %result = call i64 @"Core_version$body"(i32 0, i32 0)
+  ret i64 %result
+}
+
+declare i32 @tinygo_getCurrentStackPointer()
+
+define i64 @Core_version(i32 %data, i32 %len) #3 {
+entry:
+  %0 = call i32 @tinygo_getCurrentStackPointer()
+  call void @runtime.wasmExportEnter(i32 %0, ptr undef)
+  %1 = call i64 @"Core_version$body"(i32 %data, i32 %len)
+  call void @runtime.wasmExportExit(ptr undef)
+  ret i64 %1
+}
+
+define void @Core_initialize_block(i32 %data, i32 %len) #4 {
+entry:
+  %0 = call i32 @tinygo_getCurrentStackPointer()
+  call void @runtime.wasmExportEnter(i32 %0, ptr undef)
+  call void @"Core_initialize_block$body"(i32 %data, i32 %len)
+  call void @runtime.wasmExportExit(ptr undef)
+  ret void
+}
+
+attributes #0 = { "wasm-export-name"="_initialize" }
+attributes #1 = { noinline }
+attributes #2 = { "wasm-export-name"="tinygo_exit_code" }
+attributes #3 = { "wasm-export-name"="Core_version" }
+attributes #4 = { "wasm-export-name"="Core_initialize_block" }
@@ -0,0 +1,29 @@
+target datalayout = "e-m:e-p:32:32-i64:64-n32:64-S128-ni:1:10:20"
+target triple = "wasm32-unknown-unknown"
+
+@llvm.used = appending global [4 x ptr] [ptr @main.run, ptr @main.debugState, ptr @runtime.trapInfo, ptr @_initialize]
+
+define void @main.run() #0 {
+entry:
+  ret void
+}
+
+define i32 @main.debugState() #1 {
+entry:
+  ret i32 0
+}
+
+define internal ptr @runtime.trapInfo() #2 {
+entry:
+  ret ptr null
+}
+
+define void @_initialize() #3 {
+entry:
+  ret void
+}
+
+attributes #0 = { "wasm-export-name"="run" }
+attributes #1 = { "wasm-export-name"="debug_state" }
+attributes #2 = { "wasm-export-name"="tinygo_trap_info" }
+attributes #3 = { "wasm-export-name"="_initialize" }
@@ -0,0 +1,27 @@
+target datalayout = "e-m:e-p:32:32-i64:64-n32:64-S128-ni:1:10:20"
+target triple = "wasm32-unknown-unknown"
+
+@llvm.used = appending global [2 x ptr] [ptr @main.run, ptr @_initialize]
+
+define void @main.run() #0 {
+entry:
+  ret void
+}
+
+define hidden i32 @main.debugState() {
+entry:
+  ret i32 0
+}
+
+define internal ptr @runtime.trapInfo() {
+entry:
+  ret ptr null
+}
+
+define void @_initialize() #1 {
+entry:
+  ret void
+}
+
+attributes #0 = { "wasm-export-name"="run" }
+attributes #1 = { "wasm-export-name"="_initialize" }
@@ -0,0 +1,36 @@
+target datalayout = "e-m:e-p:32:32-i64:64-n32:64-S128-ni:1:10:20"
+target triple = "wasm32-unknown-wasi"
+
+@testing.fuzzCounters = global { ptr, i32, i32 } zeroinitializer
+
+define void @main.check(ptr %data.ptr, i32 %data.len, ptr %context) {
+entry:
+  %x = alloca i32, align 4
+  %empty = icmp eq i32 %data.len, 0
+  br i1 %empty, label %done, label %nonempty
+
+nonempty:
+  %first = load i8, ptr %data.ptr, align 1
+  %isA = icmp eq i8 %first, 65
+  br i1 %isA, label %crash, label %done
+
+crash:
+  call void @runtime.nilPanic(ptr undef)
+  unreachable
+
+done:
+  %result = phi i32 [ 0, %entry ], [ 1, %nonempty ]
+  store i32 %result, ptr %x, align 4
+  ret void
+}
+
+; Runtime and testing functions are not instrumented.
+define void @runtime.nilPanic(ptr %context) {
+entry:
+  ret void
+}
+
+define void @testing.tRunner(ptr %context) {
+entry:
+  ret void
+}
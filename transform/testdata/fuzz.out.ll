@@ -0,0 +1,48 @@
+target datalayout = "e-m:e-p:32:32-i64:64-n32:64-S128-ni:1:10:20"
+target triple = "wasm32-unknown-wasi"
+
+@testing.fuzzCounters = global { ptr, i32, i32 } { ptr @tinygo_fuzz_counters, i32 4, i32 4 }
+@tinygo_fuzz_counters = internal global [4 x i8] zeroinitializer
+
+define void @main.check(ptr %data.ptr, i32 %data.len, ptr %context) {
+entry:
+  %x = alloca i32, align 4
+  %0 = load i8, ptr getelementptr inbounds ([4 x i8], ptr @tinygo_fuzz_counters, i32 0, i32 0), align 1
+  %1 = add i8 %0, 1
+  store i8 %1, ptr getelementptr inbounds ([4 x i8], ptr @tinygo_fuzz_counters, i32 0, i32 0), align 1
+  %empty = icmp eq i32 %data.len, 0
+  br i1 %empty, label %done, label %nonempty
+
+nonempty:                                         ; preds = %entry
+  %2 = load i8, ptr getelementptr inbounds ([4 x i8], ptr @tinygo_fuzz_counters, i32 0, i32 1), align 1
+  %3 = add i8 %2, 1
+  store i8 %3, ptr getelementptr inbounds ([4 x i8], ptr @tinygo_fuzz_counters, i32 0, i32 1), align 1
+  %first = load i8, ptr %data.ptr, align 1
+  %isA = icmp eq i8 %first, 65
+  br i1 %isA, label %crash, label %done
+
+crash:                                            ; preds = %nonempty
+  %4 = load i8, ptr getelementptr inbounds ([4 x i8], ptr @tinygo_fuzz_counters, i32 0, i32 2), align 1
+  %5 = add i8 %4, 1
+  store i8 %5, ptr getelementptr inbounds ([4 x i8], ptr @tinygo_fuzz_counters, i32 0, i32 2), align 1
+  call void @runtime.nilPanic(ptr undef)
+  unreachable
+
+done:                                             ; preds = %nonempty, %entry
+  %result = phi i32 [ 0, %entry ], [ 1, %nonempty ]
+  %6 = load i8, ptr getelementptr inbounds ([4 x i8], ptr @tinygo_fuzz_counters, i32 0, i32 3), align 1
+  %7 = add i8 %6, 1
+  store i8 %7, ptr getelementptr inbounds ([4 x i8], ptr @tinygo_fuzz_counters, i32 0, i32 3), align 1
+  store i32 %result, ptr %x, align 4
+  ret void
+}
+
+define void @runtime.nilPanic(ptr %context) {
+entry:
+  ret void
+}
+
+define void @testing.tRunner(ptr %context) {
+entry:
+  ret void
+}
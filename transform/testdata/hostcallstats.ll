@@ -0,0 +1,29 @@
+target datalayout = "e-m:e-p:32:32-i64:64-n32:64-S128-ni:1:10:20"
+target triple = "wasm32-unknown-unknown"
+
+declare void @runtime.hostCallRecord(i32, ptr, i32, i32, ptr)
+
+; Pointer-size parameter and result.
+declare i64 @main.storageGet(i64) #0
+
+; Other parameters are counted by their size.
+declare i32 @main.fdWrite(i32, ptr, i32, ptr) #1
+
+; Not called, so it doesn't get an ID.
+declare void @main.unused() #2
+
+; Not imported from the host.
+declare void @main.external(i32)
+
+define i64 @main.get(i64 %key, ptr %context) {
+entry:
+  %value = call i64 @main.storageGet(i64 %key)
+  %n = call i32 @main.fdWrite(i32 1, ptr null, i32 1, ptr null)
+  %value2 = call i64 @main.storageGet(i64 17179869184)
+  call void @main.external(i32 0)
+  ret i64 %value
+}
+
+attributes #0 = { "wasm-import-module"="env" "wasm-import-name"="ext_storage_get_version_1" }
+attributes #1 = { "wasm-import-module"="wasi_snapshot_preview1" "wasm-import-name"="fd_write" }
+attributes #2 = { "wasm-import-module"="env" "wasm-import-name"="ext_unused" }
@@ -0,0 +1,39 @@
+target datalayout = "e-m:e-p:32:32-i64:64-n32:64-S128-ni:1:10:20"
+target triple = "wasm32-unknown-unknown"
+
+@"main.storageGet$importname" = internal unnamed_addr constant [29 x i8] c"env.ext_storage_get_version_1", align 1
+@"main.fdWrite$importname" = internal unnamed_addr constant [31 x i8] c"wasi_snapshot_preview1.fd_write", align 1
+
+declare void @runtime.hostCallRecord(i32, ptr, i32, i32, ptr)
+
+declare i64 @main.storageGet(i64) #0
+
+declare i32 @main.fdWrite(i32, ptr, i32, ptr) #1
+
+declare void @main.unused() #2
+
+declare void @main.external(i32)
+
+define i64 @main.get(i64 %key, ptr %context) {
+entry:
+  %value = call i64 @main.storageGet(i64 %key)
+  %0 = lshr i64 %key, 32
+  %1 = trunc i64 %0 to i32
+  %2 = lshr i64 %value, 32
+  %3 = trunc i64 %2 to i32
+  %4 = add i32 %1, %3
+  call void @runtime.hostCallRecord(i32 0, ptr @"main.storageGet$importname", i32 29, i32 %4, ptr undef)
+  %n = call i32 @main.fdWrite(i32 1, ptr null, i32 1, ptr null)
+  call void @runtime.hostCallRecord(i32 1, ptr @"main.fdWrite$importname", i32 31, i32 20, ptr undef)
+  %value2 = call i64 @main.storageGet(i64 17179869184)
+  %5 = lshr i64 %value2, 32
+  %6 = trunc i64 %5 to i32
+  %7 = add i32 4, %6
+  call void @runtime.hostCallRecord(i32 0, ptr @"main.storageGet$importname", i32 29, i32 %7, ptr undef)
+  call void @main.external(i32 0)
+  ret i64 %value
+}
+
+attributes #0 = { "wasm-import-module"="env" "wasm-import-name"="ext_storage_get_version_1" }
+attributes #1 = { "wasm-import-module"="wasi_snapshot_preview1" "wasm-import-name"="fd_write" }
+attributes #2 = { "wasm-import-module"="env" "wasm-import-name"="ext_unused" }
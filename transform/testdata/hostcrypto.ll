@@ -0,0 +1,16 @@
+target datalayout = "e-m:e-p:32:32-i64:64-n32:64-S128-ni:1:10:20"
+target triple = "wasm32-unknown-wasi"
+
+declare i1 @"crypto/ed25519.verify"(ptr, i32, i32, ptr, i32, i32, ptr, i32, i32, ptr)
+
+define i1 @"crypto/ed25519.Verify"(ptr %publicKey.data, i32 %publicKey.len, i32 %publicKey.cap, ptr %message.data, i32 %message.len, i32 %message.cap, ptr %sig.data, i32 %sig.len, i32 %sig.cap, ptr %context) {
+entry:
+  %result = call i1 @"crypto/ed25519.verify"(ptr %publicKey.data, i32 %publicKey.len, i32 %publicKey.cap, ptr %message.data, i32 %message.len, i32 %message.cap, ptr %sig.data, i32 %sig.len, i32 %sig.cap, ptr undef)
+  ret i1 %result
+}
+
+define i1 @main.checkSignature(ptr %key, ptr %msg, i32 %msg.len, ptr %sig, ptr %context) {
+entry:
+  %result = call i1 @"crypto/ed25519.Verify"(ptr %key, i32 32, i32 32, ptr %msg, i32 %msg.len, i32 %msg.len, ptr %sig, i32 64, i32 64, ptr undef)
+  ret i1 %result
+}
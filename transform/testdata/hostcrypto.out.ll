@@ -0,0 +1,47 @@
+target datalayout = "e-m:e-p:32:32-i64:64-n32:64-S128-ni:1:10:20"
+target triple = "wasm32-unknown-wasi"
+
+declare i1 @"crypto/ed25519.verify"(ptr, i32, i32, ptr, i32, i32, ptr, i32, i32, ptr)
+
+define i1 @main.checkSignature(ptr %key, ptr %msg, i32 %msg.len, ptr %sig, ptr %context) {
+entry:
+  %result = call i1 @"crypto/ed25519.Verify"(ptr %key, i32 32, i32 32, ptr %msg, i32 %msg.len, i32 %msg.len, ptr %sig, i32 64, i32 64, ptr undef)
+  ret i1 %result
+}
+
+declare i32 @ext_crypto_ed25519_verify_version_1(i32, i64, i32) #0
+
+define i1 @"crypto/ed25519.Verify"(ptr %0, i32 %1, i32 %2, ptr %3, i32 %4, i32 %5, ptr %6, i32 %7, i32 %8, ptr %9) {
+entry:
+  %10 = icmp eq i32 %1, 32
+  br i1 %10, label %key.valid, label %key.invalid
+
+key.invalid:                                      ; preds = %entry
+  call void @llvm.trap()
+  unreachable
+
+key.valid:                                        ; preds = %entry
+  %11 = icmp eq i32 %7, 64
+  br i1 %11, label %sig.valid, label %sig.invalid
+
+sig.valid:                                        ; preds = %key.valid
+  %12 = ptrtoint ptr %6 to i32
+  %13 = ptrtoint ptr %3 to i32
+  %14 = zext i32 %13 to i64
+  %15 = zext i32 %4 to i64
+  %16 = shl i64 %15, 32
+  %17 = or i64 %14, %16
+  %18 = ptrtoint ptr %0 to i32
+  %19 = call i32 @ext_crypto_ed25519_verify_version_1(i32 %12, i64 %17, i32 %18)
+  %20 = icmp ne i32 %19, 0
+  ret i1 %20
+
+sig.invalid:                                      ; preds = %key.valid
+  ret i1 false
+}
+
+; Function Attrs: cold noreturn nounwind
+declare void @llvm.trap() #1
+
+attributes #0 = { "wasm-import-module"="env" "wasm-import-name"="ext_crypto_ed25519_verify_version_1" }
+attributes #1 = { cold noreturn nounwind }
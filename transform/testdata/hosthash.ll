@@ -0,0 +1,29 @@
+target datalayout = "e-m:e-p:32:32-i64:64-n32:64-S128-ni:1:10:20"
+target triple = "wasm32-unknown-wasi"
+
+declare void @"crypto/sha256.block"(ptr, ptr, i32, i32, ptr)
+
+declare void @"golang.org/x/crypto/blake2b.hashBlocks"(ptr, ptr, i32, i32, ptr)
+
+define [32 x i8] @"crypto/sha256.Sum256"(ptr %data.data, i32 %data.len, i32 %data.cap, ptr %context) {
+entry:
+  %d = alloca [32 x i8], align 1
+  call void @"crypto/sha256.block"(ptr %d, ptr %data.data, i32 %data.len, i32 %data.cap, ptr undef)
+  %hash = load [32 x i8], ptr %d, align 1
+  ret [32 x i8] %hash
+}
+
+define [32 x i8] @"golang.org/x/crypto/blake2b.Sum256"(ptr %data.data, i32 %data.len, i32 %data.cap, ptr %context) {
+entry:
+  %d = alloca [32 x i8], align 1
+  call void @"golang.org/x/crypto/blake2b.hashBlocks"(ptr %d, ptr %data.data, i32 %data.len, i32 %data.cap, ptr undef)
+  %hash = load [32 x i8], ptr %d, align 1
+  ret [32 x i8] %hash
+}
+
+define [32 x i8] @main.hashBoth(ptr %data.data, i32 %data.len, i32 %data.cap, ptr %context) {
+entry:
+  %sha = call [32 x i8] @"crypto/sha256.Sum256"(ptr %data.data, i32 %data.len, i32 %data.cap, ptr undef)
+  %blake = call [32 x i8] @"golang.org/x/crypto/blake2b.Sum256"(ptr %data.data, i32 %data.len, i32 %data.cap, ptr undef)
+  ret [32 x i8] %sha
+}
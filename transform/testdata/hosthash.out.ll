@@ -0,0 +1,38 @@
+target datalayout = "e-m:e-p:32:32-i64:64-n32:64-S128-ni:1:10:20"
+target triple = "wasm32-unknown-wasi"
+
+declare void @"crypto/sha256.block"(ptr, ptr, i32, i32, ptr)
+
+declare void @"golang.org/x/crypto/blake2b.hashBlocks"(ptr, ptr, i32, i32, ptr)
+
+define [32 x i8] @"golang.org/x/crypto/blake2b.Sum256"(ptr %data.data, i32 %data.len, i32 %data.cap, ptr %context) {
+entry:
+  %d = alloca [32 x i8], align 1
+  call void @"golang.org/x/crypto/blake2b.hashBlocks"(ptr %d, ptr %data.data, i32 %data.len, i32 %data.cap, ptr undef)
+  %hash = load [32 x i8], ptr %d, align 1
+  ret [32 x i8] %hash
+}
+
+define [32 x i8] @main.hashBoth(ptr %data.data, i32 %data.len, i32 %data.cap, ptr %context) {
+entry:
+  %sha = call [32 x i8] @"crypto/sha256.Sum256"(ptr %data.data, i32 %data.len, i32 %data.cap, ptr undef)
+  %blake = call [32 x i8] @"golang.org/x/crypto/blake2b.Sum256"(ptr %data.data, i32 %data.len, i32 %data.cap, ptr undef)
+  ret [32 x i8] %sha
+}
+
+declare i32 @ext_hashing_sha2_256_version_1(i64) #0
+
+define [32 x i8] @"crypto/sha256.Sum256"(ptr %0, i32 %1, i32 %2, ptr %3) {
+entry:
+  %4 = ptrtoint ptr %0 to i32
+  %5 = zext i32 %4 to i64
+  %6 = zext i32 %1 to i64
+  %7 = shl i64 %6, 32
+  %8 = or i64 %5, %7
+  %9 = call i32 @ext_hashing_sha2_256_version_1(i64 %8)
+  %10 = inttoptr i32 %9 to ptr
+  %11 = load [32 x i8], ptr %10, align 1
+  ret [32 x i8] %11
+}
+
+attributes #0 = { "wasm-import-module"="env" "wasm-import-name"="ext_hashing_sha2_256_version_1" }
@@ -0,0 +1,24 @@
+target datalayout = "e-m:e-p:32:32-i64:64-n32:64-S128-ni:1:10:20"
+target triple = "wasm32-unknown-unknown"
+
+@"main$string" = internal unnamed_addr constant [5 x i8] c"hello", align 1
+@main.logFunc = global ptr @"runtime/hostlog.Debug"
+
+declare void @"runtime/hostlog.log"(i32, ptr, i32, ptr, i32, i32, ptr)
+
+define void @"runtime/hostlog.Warn"(ptr %msg.data, i32 %msg.len, ptr %args.data, i32 %args.len, i32 %args.cap, ptr %context) {
+entry:
+  call void @"runtime/hostlog.log"(i32 2, ptr %msg.data, i32 %msg.len, ptr %args.data, i32 %args.len, i32 %args.cap, ptr undef)
+  ret void
+}
+
+define void @main.main(ptr %context) {
+entry:
+  call void @"runtime/hostlog.Warn"(ptr @"main$string", i32 5, ptr null, i32 0, i32 0, ptr undef)
+  ret void
+}
+
+define void @"runtime/hostlog.Debug"(ptr %0, i32 %1, ptr %2, i32 %3, i32 %4, ptr %5) {
+entry:
+  ret void
+}
@@ -0,0 +1,21 @@
+target datalayout = "e-m:e-p:32:32-i64:64-n32:64-S128-ni:1:10:20"
+target triple = "wasm32-unknown-wasi"
+
+@main.x = internal global i32 0
+
+declare void @runtime.initReportEnter(ptr, i32, ptr)
+
+declare void @runtime.initReportExit(ptr)
+
+declare void @foo.init(ptr)
+
+declare void @"example.com/bar.init"(ptr)
+
+; The store was left by interp, only the package initializers are measured.
+define void @runtime.initAll(ptr %context) {
+entry:
+  call void @foo.init(ptr undef)
+  store i32 3, ptr @main.x, align 4
+  call void @"example.com/bar.init"(ptr undef)
+  ret void
+}
@@ -0,0 +1,26 @@
+target datalayout = "e-m:e-p:32:32-i64:64-n32:64-S128-ni:1:10:20"
+target triple = "wasm32-unknown-wasi"
+
+@main.x = internal global i32 0
+@"foo$string" = internal unnamed_addr constant [3 x i8] c"foo", align 1
+@"example.com/bar$string" = internal unnamed_addr constant [15 x i8] c"example.com/bar", align 1
+
+declare void @runtime.initReportEnter(ptr, i32, ptr)
+
+declare void @runtime.initReportExit(ptr)
+
+declare void @foo.init(ptr)
+
+declare void @"example.com/bar.init"(ptr)
+
+define void @runtime.initAll(ptr %context) {
+entry:
+  call void @runtime.initReportEnter(ptr @"foo$string", i32 3, ptr undef)
+  call void @foo.init(ptr undef)
+  call void @runtime.initReportExit(ptr undef)
+  store i32 3, ptr @main.x, align 4
+  call void @runtime.initReportEnter(ptr @"example.com/bar$string", i32 15, ptr undef)
+  call void @"example.com/bar.init"(ptr undef)
+  call void @runtime.initReportExit(ptr undef)
+  ret void
+}
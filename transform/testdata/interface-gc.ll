@@ -0,0 +1,48 @@
+target datalayout = "e-m:e-p:32:32-i64:64-v128:64:128-a:0:32-n32-S64"
+target triple = "armv7m-none-eabi"
+
+@"reflect/types.type:basic:int" = linkonce_odr constant { i8, ptr } { i8 2, ptr @"reflect/types.type:pointer:basic:int" }, align 4
+@"reflect/types.type:pointer:basic:int" = linkonce_odr constant { i8, ptr } { i8 21, ptr @"reflect/types.type:basic:int" }, align 4
+@"reflect/methods.Double() int" = linkonce_odr constant i8 0
+@"Number$methodset" = linkonce_odr unnamed_addr constant { i32, [1 x ptr], { ptr } } { i32 1, [1 x ptr] [ptr @"reflect/methods.Double() int"], { ptr } { ptr @"(Number).Double$invoke" } }
+@"reflect/types.type:named:Number" = linkonce_odr constant { ptr, i8, ptr, ptr } { ptr @"Number$methodset", i8 34, ptr @"reflect/types.type:pointer:named:Number", ptr @"reflect/types.type:basic:int" }, align 4
+@"reflect/types.type:pointer:named:Number" = linkonce_odr constant { i8, ptr } { i8 21, ptr getelementptr inbounds ({ ptr, i8, ptr, ptr }, ptr @"reflect/types.type:named:Number", i32 0, i32 1) }, align 4
+
+; Other is only referenced from the type information of *Other, which is
+; never stored in an interface either.
+@"Other$methodset" = linkonce_odr unnamed_addr constant { i32, [1 x ptr], { ptr } } { i32 1, [1 x ptr] [ptr @"reflect/methods.Double() int"], { ptr } { ptr @"(Other).Double$invoke" } }
+@"reflect/types.type:named:Other" = linkonce_odr constant { ptr, i8, ptr, ptr } { ptr @"Other$methodset", i8 34, ptr @"reflect/types.type:pointer:named:Other", ptr @"reflect/types.type:basic:int" }, align 4
+@"reflect/types.type:pointer:named:Other" = linkonce_odr constant { i8, ptr } { i8 21, ptr getelementptr inbounds ({ ptr, i8, ptr, ptr }, ptr @"reflect/types.type:named:Other", i32 0, i32 1) }, align 4
+@main.otherType = global ptr @"reflect/types.type:pointer:named:Other"
+
+declare void @runtime.printint32(i32)
+declare void @runtime.nilPanic(ptr)
+
+define void @printNumber() {
+  call void @printDoubler(ptr getelementptr inbounds ({ ptr, i8, ptr, ptr }, ptr @"reflect/types.type:named:Number", i32 0, i32 1), ptr inttoptr (i32 3 to ptr))
+  ret void
+}
+
+define void @printDoubler(ptr %typecode, ptr %value) {
+  %result = call i32 @"Doubler.Double$invoke"(ptr %value, ptr %typecode, ptr undef)
+  call void @runtime.printint32(i32 %result)
+  ret void
+}
+
+define internal i32 @"(Number).Double$invoke"(ptr %receiverPtr, ptr %context) {
+  %receiver = ptrtoint ptr %receiverPtr to i32
+  %ret = mul i32 %receiver, 2
+  ret i32 %ret
+}
+
+define internal i32 @"(Other).Double$invoke"(ptr %receiverPtr, ptr %context) {
+  %receiver = ptrtoint ptr %receiverPtr to i32
+  %ret = mul i32 %receiver, 3
+  ret i32 %ret
+}
+
+; Once Other is removed, Number is the only type that implements Doubler, so
+; the method thunk is marked alwaysinline.
+declare i32 @"Doubler.Double$invoke"(ptr %receiver, ptr %typecode, ptr %context) #0
+
+attributes #0 = { "tinygo-invoke"="reflect/methods.Double() int" "tinygo-methods"="reflect/methods.Double() int" }
@@ -0,0 +1,48 @@
+target datalayout = "e-m:e-p:32:32-i64:64-v128:64:128-a:0:32-n32-S64"
+target triple = "armv7m-none-eabi"
+
+@"reflect/types.type:basic:int" = linkonce_odr constant { i8, ptr } { i8 2, ptr @"reflect/types.type:pointer:basic:int" }, align 4
+@"reflect/types.type:pointer:basic:int" = linkonce_odr constant { i8, ptr } { i8 21, ptr @"reflect/types.type:basic:int" }, align 4
+@"reflect/types.type:pointer:named:Number" = linkonce_odr constant { i8, ptr } { i8 21, ptr @"reflect/types.type:named:Number" }, align 4
+@"reflect/types.type:pointer:named:Other" = linkonce_odr constant { i8, ptr } { i8 21, ptr @"reflect/types.type:named:Other" }, align 4
+@main.otherType = global ptr @"reflect/types.type:pointer:named:Other"
+@"reflect/types.type:named:Number" = linkonce_odr constant { i8, ptr, ptr } { i8 34, ptr @"reflect/types.type:pointer:named:Number", ptr @"reflect/types.type:basic:int" }, align 4
+@"reflect/types.type:named:Other" = linkonce_odr constant { i8, ptr, ptr } { i8 34, ptr @"reflect/types.type:pointer:named:Other", ptr @"reflect/types.type:basic:int" }, align 4
+
+declare void @runtime.printint32(i32)
+
+declare void @runtime.nilPanic(ptr)
+
+define void @printNumber() {
+  call void @printDoubler(ptr @"reflect/types.type:named:Number", ptr inttoptr (i32 3 to ptr))
+  ret void
+}
+
+define void @printDoubler(ptr %typecode, ptr %value) {
+  %result = call i32 @"Doubler.Double$invoke"(ptr %value, ptr %typecode, ptr undef)
+  call void @runtime.printint32(i32 %result)
+  ret void
+}
+
+define internal i32 @"(Number).Double$invoke"(ptr %receiverPtr, ptr %context) {
+  %receiver = ptrtoint ptr %receiverPtr to i32
+  %ret = mul i32 %receiver, 2
+  ret i32 %ret
+}
+
+; Function Attrs: alwaysinline
+define internal i32 @"Doubler.Double$invoke"(ptr %receiver, ptr %actualType, ptr %context) unnamed_addr #0 {
+entry:
+  %"named:Number.icmp" = icmp eq ptr %actualType, @"reflect/types.type:named:Number"
+  br i1 %"named:Number.icmp", label %"named:Number", label %"named:Number.next"
+
+"named:Number":                                   ; preds = %entry
+  %0 = call i32 @"(Number).Double$invoke"(ptr %receiver, ptr undef)
+  ret i32 %0
+
+"named:Number.next":                              ; preds = %entry
+  call void @runtime.nilPanic(ptr undef)
+  unreachable
+}
+
+attributes #0 = { alwaysinline "tinygo-invoke"="reflect/methods.Double() int" "tinygo-methods"="reflect/methods.Double() int" }
@@ -80,6 +80,7 @@ define i32 @"(Number).Double$invoke"(ptr %receiverPtr, ptr %context) {
   ret i32 %ret
 }
 
+; Function Attrs: alwaysinline
 define internal i32 @"Doubler.Double$invoke"(ptr %receiver, ptr %actualType, ptr %context) unnamed_addr #0 {
 entry:
   %"named:Number.icmp" = icmp eq ptr %actualType, @"reflect/types.type:named:Number"
@@ -114,6 +115,6 @@ then:                                             ; No predecessors!
   ret i1 true
 }
 
-attributes #0 = { "tinygo-invoke"="reflect/methods.Double() int" "tinygo-methods"="reflect/methods.Double() int" }
+attributes #0 = { alwaysinline "tinygo-invoke"="reflect/methods.Double() int" "tinygo-methods"="reflect/methods.Double() int" }
 attributes #1 = { "tinygo-methods"="reflect/methods.Double() int" }
 attributes #2 = { "tinygo-methods"="reflect/methods.NeverImplementedMethod()" }
@@ -0,0 +1,71 @@
+target datalayout = "e-m:e-p:32:32-i64:64-n32:64-S128-ni:1:10:20"
+target triple = "wasm32-unknown-unknown"
+
+declare void @runtime.printstring(ptr, i32)
+
+@"main$string" = internal unnamed_addr constant [9 x i8] c"not found"
+
+; Outlined functions with the same code are merged, even if their debug
+; information differs.
+define internal void @"main.notFound[int]"(i32 %n) #0 !dbg !3 {
+entry:
+  call void @runtime.printstring(ptr @"main$string", i32 %n), !dbg !1
+  ret void
+}
+
+define internal void @"main.notFound[uint]"(i32 %n) #0 !dbg !4 {
+entry:
+  call void @runtime.printstring(ptr @"main$string", i32 %n), !dbg !2
+  ret void
+}
+
+; Other functions are left alone.
+define internal void @main.notOutlined(i32 %n) {
+entry:
+  call void @runtime.printstring(ptr @"main$string", i32 %n)
+  ret void
+}
+
+define internal void @main.notOutlined2(i32 %n) {
+entry:
+  call void @runtime.printstring(ptr @"main$string", i32 %n)
+  ret void
+}
+
+; The order of the parameters matters.
+define internal void @main.swapped(i32 %a, i32 %b) #0 {
+entry:
+  call void @runtime.printstring(ptr @"main$string", i32 %a)
+  ret void
+}
+
+define internal void @main.swapped2(i32 %b, i32 %a) #0 {
+entry:
+  call void @runtime.printstring(ptr @"main$string", i32 %a)
+  ret void
+}
+
+define void @main.main() {
+entry:
+  call void @"main.notFound[int]"(i32 1)
+  call void @"main.notFound[uint]"(i32 2)
+  call void @main.notOutlined(i32 3)
+  call void @main.notOutlined2(i32 4)
+  call void @main.swapped(i32 5, i32 6)
+  call void @main.swapped2(i32 7, i32 8)
+  ret void
+}
+
+attributes #0 = { minsize noinline optsize "tinygo-outline" }
+
+!llvm.module.flags = !{!0}
+!llvm.dbg.cu = !{!5}
+
+!0 = !{i32 2, !"Debug Info Version", i32 3}
+!1 = !DILocation(line: 10, scope: !3)
+!2 = !DILocation(line: 20, scope: !4)
+!3 = distinct !DISubprogram(name: "notFound[int]", scope: !6, file: !6, line: 9, type: !7, spFlags: DISPFlagDefinition, unit: !5)
+!4 = distinct !DISubprogram(name: "notFound[uint]", scope: !6, file: !6, line: 19, type: !7, spFlags: DISPFlagDefinition, unit: !5)
+!5 = distinct !DICompileUnit(language: DW_LANG_Go, file: !6, producer: "TinyGo", isOptimized: true, runtimeVersion: 0, emissionKind: FullDebug)
+!6 = !DIFile(filename: "main.go", directory: "/")
+!7 = !DISubroutineType(types: !{})
@@ -0,0 +1,63 @@
+target datalayout = "e-m:e-p:32:32-i64:64-n32:64-S128-ni:1:10:20"
+target triple = "wasm32-unknown-unknown"
+
+@"main$string" = internal unnamed_addr constant [9 x i8] c"not found"
+
+declare void @runtime.printstring(ptr, i32)
+
+; Function Attrs: minsize noinline optsize
+define internal void @"main.notFound[int]"(i32 %n) #0 !dbg !3 {
+entry:
+  call void @runtime.printstring(ptr @"main$string", i32 %n), !dbg !6
+  ret void
+}
+
+define internal void @main.notOutlined(i32 %n) {
+entry:
+  call void @runtime.printstring(ptr @"main$string", i32 %n)
+  ret void
+}
+
+define internal void @main.notOutlined2(i32 %n) {
+entry:
+  call void @runtime.printstring(ptr @"main$string", i32 %n)
+  ret void
+}
+
+; Function Attrs: minsize noinline optsize
+define internal void @main.swapped(i32 %a, i32 %b) #0 {
+entry:
+  call void @runtime.printstring(ptr @"main$string", i32 %a)
+  ret void
+}
+
+; Function Attrs: minsize noinline optsize
+define internal void @main.swapped2(i32 %b, i32 %a) #0 {
+entry:
+  call void @runtime.printstring(ptr @"main$string", i32 %a)
+  ret void
+}
+
+define void @main.main() {
+entry:
+  call void @"main.notFound[int]"(i32 1)
+  call void @"main.notFound[int]"(i32 2)
+  call void @main.notOutlined(i32 3)
+  call void @main.notOutlined2(i32 4)
+  call void @main.swapped(i32 5, i32 6)
+  call void @main.swapped2(i32 7, i32 8)
+  ret void
+}
+
+attributes #0 = { minsize noinline optsize "tinygo-outline" }
+
+!llvm.module.flags = !{!0}
+!llvm.dbg.cu = !{!1}
+
+!0 = !{i32 2, !"Debug Info Version", i32 3}
+!1 = distinct !DICompileUnit(language: DW_LANG_Go, file: !2, producer: "TinyGo", isOptimized: true, runtimeVersion: 0, emissionKind: FullDebug)
+!2 = !DIFile(filename: "main.go", directory: "/")
+!3 = distinct !DISubprogram(name: "notFound[int]", scope: !2, file: !2, line: 9, type: !4, spFlags: DISPFlagDefinition, unit: !1)
+!4 = !DISubroutineType(types: !5)
+!5 = !{}
+!6 = !DILocation(line: 10, scope: !3)
@@ -0,0 +1,48 @@
+target datalayout = "e-m:e-p:32:32-i64:64-n32:64-S128-ni:1:10:20"
+target triple = "wasm32-unknown-wasi"
+
+define internal i32 @"main.sum[int32]"(ptr %buf, i32 %len) unnamed_addr {
+entry:
+  br label %loop
+
+loop:                                             ; preds = %loop, %entry
+  %i = phi i32 [ 0, %entry ], [ %next, %loop ]
+  %total = phi i32 [ 0, %entry ], [ %add, %loop ]
+  %ptr = getelementptr i32, ptr %buf, i32 %i
+  %val = load i32, ptr %ptr, align 4
+  %add = add i32 %total, %val
+  %next = add i32 %i, 1
+  %done = icmp eq i32 %next, %len
+  br i1 %done, label %exit, label %loop
+
+exit:                                             ; preds = %loop
+  ret i32 %add
+}
+
+define internal i32 @"main.sum[int64]"(ptr %buf, i32 %len) unnamed_addr {
+entry:
+  br label %loop
+
+loop:                                             ; preds = %loop, %entry
+  %i = phi i32 [ 0, %entry ], [ %next, %loop ]
+  %total = phi i64 [ 0, %entry ], [ %add, %loop ]
+  %ptr = getelementptr i64, ptr %buf, i32 %i
+  %val = load i64, ptr %ptr, align 8
+  %add = add i64 %total, %val
+  %next = add i32 %i, 1
+  %done = icmp eq i32 %next, %len
+  br i1 %done, label %exit, label %loop
+
+exit:                                             ; preds = %loop
+  %result = trunc i64 %add to i32
+  ret i32 %result
+}
+
+define i32 @main.main(ptr %buf) {
+  %a = call i32 @"main.sum[int32]"(ptr %buf, i32 4)
+  %b = call i32 @"main.sum[int32]"(ptr %buf, i32 4)
+  %c = call i32 @"main.sum[int64]"(ptr %buf, i32 2)
+  %ab = add i32 %a, %b
+  %abc = add i32 %ab, %c
+  ret i32 %abc
+}
@@ -0,0 +1,29 @@
+target datalayout = "e-m:e-p:32:32-i64:64-n32:64-S128-ni:1:10:20"
+target triple = "wasm32-unknown-unknown"
+
+declare void @main.hostLog(double, i32) #0
+
+define internal double @main.internal(double %x) {
+entry:
+  ret double %x
+}
+
+; Exported functions return canonical NaNs. Imported functions get them as
+; arguments, other functions don't need to.
+define float @main.exported(float %x, double %y) #1 {
+entry:
+  call void @main.hostLog(double %y, i32 3)
+  %sum = fadd double %y, 1.0
+  %internal = call double @main.internal(double %sum)
+  ret float %x
+}
+
+; The bits of a float, as read by math.Float64bits.
+define i64 @main.bits(double %x) {
+entry:
+  %bits = bitcast double %x to i64
+  ret i64 %bits
+}
+
+attributes #0 = { "wasm-import-module"="env" "wasm-import-name"="host_log" }
+attributes #1 = { "wasm-export-name"="exported" }
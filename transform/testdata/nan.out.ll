@@ -0,0 +1,32 @@
+target datalayout = "e-m:e-p:32:32-i64:64-n32:64-S128-ni:1:10:20"
+target triple = "wasm32-unknown-unknown"
+
+declare void @main.hostLog(double, i32) #0
+
+define internal double @main.internal(double %x) {
+entry:
+  ret double %x
+}
+
+define float @main.exported(float %x, double %y) #1 {
+entry:
+  %isnan = fcmp uno double %y, %y
+  %canonical = select i1 %isnan, double 0x7FF8000000000000, double %y
+  call void @main.hostLog(double %canonical, i32 3)
+  %sum = fadd double %y, 1.000000e+00
+  %internal = call double @main.internal(double %sum)
+  %isnan1 = fcmp uno float %x, %x
+  %canonical2 = select i1 %isnan1, float 0x7FF8000000000000, float %x
+  ret float %canonical2
+}
+
+define i64 @main.bits(double %x) {
+entry:
+  %isnan = fcmp uno double %x, %x
+  %canonical = select i1 %isnan, double 0x7FF8000000000000, double %x
+  %bits = bitcast double %canonical to i64
+  ret i64 %bits
+}
+
+attributes #0 = { "wasm-import-module"="env" "wasm-import-name"="host_log" }
+attributes #1 = { "wasm-export-name"="exported" }
@@ -0,0 +1,32 @@
+target datalayout = "e-m:e-p:32:32-i64:64-n32-S32"
+target triple = "riscv32-unknown-none"
+
+declare void @runtime.polkavmExportEnter(ptr, ptr)
+
+declare i64 @main.gas() #0
+
+declare void @main.log(i32, ptr, i32) #1
+
+define i64 @refine(i32 %data, i32 %len) #2 {
+entry:
+  %gas = call i64 @main.gas()
+  ret i64 %gas
+}
+
+define void @accumulate(i32 %data, i32 %len) #3 {
+entry:
+  call void @main.log(i32 1, ptr null, i32 0)
+  ret void
+}
+
+; Calls from within the program skip the wrapper.
+define i64 @main.callRefine(ptr %context) {
+entry:
+  %result = call i64 @refine(i32 0, i32 0)
+  ret i64 %result
+}
+
+attributes #0 = { "polkavm-import-name"="gas" }
+attributes #1 = { "polkavm-import-name"="log" }
+attributes #2 = { "polkavm-export-name"="refine" }
+attributes #3 = { "polkavm-export-name"="accumulate" }
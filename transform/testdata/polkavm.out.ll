@@ -0,0 +1,73 @@
+target datalayout = "e-m:e-p:32:32-i64:64-n32-S32"
+target triple = "riscv32-unknown-none"
+
+@"polkavm_import_0$symbol" = private constant [3 x i8] c"gas", section ".polkavm_metadata", align 1
+@polkavm_import_0 = internal constant <{ i8, i32, i32, ptr, i8, i8 }> <{ i8 1, i32 0, i32 3, ptr @"polkavm_import_0$symbol", i8 0, i8 2 }>, section ".polkavm_metadata", align 1
+@"polkavm_import_1$symbol" = private constant [3 x i8] c"log", section ".polkavm_metadata", align 1
+@polkavm_import_1 = internal constant <{ i8, i32, i32, ptr, i8, i8 }> <{ i8 1, i32 0, i32 3, ptr @"polkavm_import_1$symbol", i8 3, i8 0 }>, section ".polkavm_metadata", align 1
+@"polkavm_export_0$symbol" = private constant [6 x i8] c"refine", section ".polkavm_metadata", align 1
+@polkavm_export_0 = internal constant <{ i8, i32, i32, ptr, i8, i8 }> <{ i8 1, i32 0, i32 6, ptr @"polkavm_export_0$symbol", i8 2, i8 2 }>, section ".polkavm_metadata", align 1
+@"polkavm_export_0$entry" = internal constant <{ i8, ptr, ptr }> <{ i8 1, ptr @polkavm_export_0, ptr @refine }>, section ".polkavm_exports", align 1
+@"polkavm_export_1$symbol" = private constant [10 x i8] c"accumulate", section ".polkavm_metadata", align 1
+@polkavm_export_1 = internal constant <{ i8, i32, i32, ptr, i8, i8 }> <{ i8 1, i32 0, i32 10, ptr @"polkavm_export_1$symbol", i8 2, i8 0 }>, section ".polkavm_metadata", align 1
+@"polkavm_export_1$entry" = internal constant <{ i8, ptr, ptr }> <{ i8 1, ptr @polkavm_export_1, ptr @accumulate }>, section ".polkavm_exports", align 1
+@llvm.used = appending global [4 x ptr] [ptr @polkavm_import_0, ptr @polkavm_import_1, ptr @"polkavm_export_0$entry", ptr @"polkavm_export_1$entry"]
+
+declare void @runtime.polkavmExportEnter(ptr, ptr)
+
+; Function Attrs: naked noinline
+define internal i64 @main.gas() #0 {
+entry:
+  call void asm sideeffect ".insn r 0xb, 0, 0, zero, zero, zero\0A.4byte polkavm_import_0\0Aret", ""()
+  unreachable
+}
+
+; Function Attrs: naked noinline
+define internal void @main.log(i32 %0, ptr %1, i32 %2) #0 {
+entry:
+  call void asm sideeffect ".insn r 0xb, 0, 0, zero, zero, zero\0A.4byte polkavm_import_1\0Aret", ""()
+  unreachable
+}
+
+; Function Attrs: noinline
+define i64 @"refine$body"(i32 %data, i32 %len) #1 {
+entry:
+  %gas = call i64 @main.gas()
+  ret i64 %gas
+}
+
+; Function Attrs: noinline
+define void @"accumulate$body"(i32 %data, i32 %len) #1 {
+entry:
+  call void @main.log(i32 1, ptr null, i32 0)
+  ret void
+}
+
+define i64 @main.callRefine(ptr %context) {
+entry:
+  %result = call i64 @"refine$body"(i32 0, i32 0)
+  ret i64 %result
+}
+
+; Function Attrs: nocallback nofree nosync nounwind willreturn
+declare ptr @llvm.stacksave() #2
+
+define i64 @refine(i32 %data, i32 %len) {
+entry:
+  %0 = call ptr @llvm.stacksave()
+  call void @runtime.polkavmExportEnter(ptr %0, ptr undef)
+  %1 = call i64 @"refine$body"(i32 %data, i32 %len)
+  ret i64 %1
+}
+
+define void @accumulate(i32 %data, i32 %len) {
+entry:
+  %0 = call ptr @llvm.stacksave()
+  call void @runtime.polkavmExportEnter(ptr %0, ptr undef)
+  call void @"accumulate$body"(i32 %data, i32 %len)
+  ret void
+}
+
+attributes #0 = { naked noinline }
+attributes #1 = { noinline }
+attributes #2 = { nocallback nofree nosync nounwind willreturn }
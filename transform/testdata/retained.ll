@@ -0,0 +1,30 @@
+target datalayout = "e-m:e-p:32:32-i64:64-n32:64-S128-ni:1:10:20"
+target triple = "wasm32-unknown-wasi"
+
+@main.handlers = internal global [1 x ptr] [ptr @main.handler]
+@main.unused = internal global i32 0
+
+define void @_start() {
+  call void @main.main(ptr undef)
+  ret void
+}
+
+define internal void @main.main(ptr %context) {
+  %handler = load ptr, ptr @main.handlers
+  call void %handler(ptr undef)
+  ret void
+}
+
+define internal void @main.handler(ptr %context) {
+  call void @fmt.Println(ptr undef)
+  ret void
+}
+
+define internal void @fmt.Println(ptr %context) {
+  ret void
+}
+
+define internal void @main.dead(ptr %context) {
+  %x = load i32, ptr @main.unused
+  ret void
+}
@@ -0,0 +1,22 @@
+target datalayout = "e-m:e-p:32:32-i64:64-n32:64-S128-ni:1:10:20"
+target triple = "wasm32-unknown-unknown"
+
+declare double @llvm.fma.f64(double, double, double)
+
+define double @main.remainder(double %x, double %y) {
+entry:
+  %rem = frem double %x, %y
+  ret double %rem
+}
+
+define double @main.fma(double %x, double %y, double %z) {
+entry:
+  %result = call double @llvm.fma.f64(double %x, double %y, double %z)
+  ret double %result
+}
+
+define i128 @main.wide(double %x) {
+entry:
+  %result = fptosi double %x to i128
+  ret i128 %result
+}
@@ -0,0 +1,49 @@
+target datalayout = "e-m:e-p:32:32-i64:64-n32:64-S128-ni:1:10:20"
+target triple = "wasm32-unknown-unknown"
+
+define double @main.arith(double %x, double %y) {
+entry:
+  %sum = fadd double %x, %y
+  %diff = fsub double %sum, 1.0
+  %product = fmul double %diff, %y
+  %quotient = fdiv double %product, %x
+  %neg = fneg double %quotient
+  ret double %neg
+}
+
+define float @main.arith32(float %x, float %y) {
+entry:
+  %sum = fadd float %x, %y
+  %neg = fneg float %sum
+  ret float %neg
+}
+
+; Loads, stores and selects don't change the bits, so they're left alone.
+define void @main.compare(double %x, double %y, ptr %out) {
+entry:
+  %oeq = fcmp oeq double %x, %y
+  %olt = fcmp olt double %x, %y
+  %one = fcmp one double %x, %y
+  %uno = fcmp uno double %x, %y
+  %ueq = fcmp ueq double %x, %y
+  %ugt = fcmp ugt double %x, %y
+  %une = fcmp une double %x, %y
+  %true = fcmp true double %x, %y
+  %min = select i1 %olt, double %x, double %y
+  store double %min, ptr %out, align 8
+  ret void
+}
+
+define i32 @main.convert(double %x, float %y, i8 %z, i64 %w) {
+entry:
+  %i8 = fptosi double %x to i8
+  %u64 = fptoui float %y to i64
+  %fromi8 = sitofp i8 %z to double
+  %fromu8 = uitofp i8 %z to float
+  %fromi64 = sitofp i64 %w to double
+  %ext = fpext float %fromu8 to double
+  %trunc = fptrunc double %fromi64 to float
+  %sum = fadd double %fromi8, %ext
+  %ret = fptoui double %sum to i32
+  ret i32 %ret
+}
@@ -0,0 +1,103 @@
+target datalayout = "e-m:e-p:32:32-i64:64-n32:64-S128-ni:1:10:20"
+target triple = "wasm32-unknown-unknown"
+
+define double @main.arith(double %x, double %y) {
+entry:
+  %sum = call double @__adddf3(double %x, double %y)
+  %diff = call double @__subdf3(double %sum, double 1.000000e+00)
+  %product = call double @__muldf3(double %diff, double %y)
+  %quotient = call double @__divdf3(double %product, double %x)
+  %0 = bitcast double %quotient to i64
+  %1 = xor i64 %0, -9223372036854775808
+  %neg = bitcast i64 %1 to double
+  ret double %neg
+}
+
+define float @main.arith32(float %x, float %y) {
+entry:
+  %sum = call float @__addsf3(float %x, float %y)
+  %0 = bitcast float %sum to i32
+  %1 = xor i32 %0, -2147483648
+  %neg = bitcast i32 %1 to float
+  ret float %neg
+}
+
+define void @main.compare(double %x, double %y, ptr %out) {
+entry:
+  %0 = call i32 @__eqdf2(double %x, double %y)
+  %oeq = icmp eq i32 %0, 0
+  %1 = call i32 @__ltdf2(double %x, double %y)
+  %olt = icmp slt i32 %1, 0
+  %2 = call i32 @__eqdf2(double %x, double %y)
+  %3 = icmp ne i32 %2, 0
+  %4 = call i32 @__unorddf2(double %x, double %y)
+  %5 = icmp eq i32 %4, 0
+  %one = and i1 %3, %5
+  %6 = call i32 @__unorddf2(double %x, double %y)
+  %uno = icmp ne i32 %6, 0
+  %7 = call i32 @__eqdf2(double %x, double %y)
+  %8 = icmp eq i32 %7, 0
+  %9 = call i32 @__unorddf2(double %x, double %y)
+  %10 = icmp ne i32 %9, 0
+  %ueq = or i1 %8, %10
+  %11 = call i32 @__ledf2(double %x, double %y)
+  %ugt = icmp sgt i32 %11, 0
+  %12 = call i32 @__nedf2(double %x, double %y)
+  %une = icmp ne i32 %12, 0
+  %min = select i1 %olt, double %x, double %y
+  store double %min, ptr %out, align 8
+  ret void
+}
+
+define i32 @main.convert(double %x, float %y, i8 %z, i64 %w) {
+entry:
+  %0 = call i32 @__fixdfsi(double %x)
+  %i8 = trunc i32 %0 to i8
+  %u64 = call i64 @__fixunssfdi(float %y)
+  %1 = sext i8 %z to i32
+  %fromi8 = call double @__floatsidf(i32 %1)
+  %2 = zext i8 %z to i32
+  %fromu8 = call float @__floatunsisf(i32 %2)
+  %fromi64 = call double @__floatdidf(i64 %w)
+  %ext = call double @__extendsfdf2(float %fromu8)
+  %trunc = call float @__truncdfsf2(double %fromi64)
+  %sum = call double @__adddf3(double %fromi8, double %ext)
+  %ret = call i32 @__fixunsdfsi(double %sum)
+  ret i32 %ret
+}
+
+declare double @__adddf3(double, double)
+
+declare double @__subdf3(double, double)
+
+declare double @__muldf3(double, double)
+
+declare double @__divdf3(double, double)
+
+declare float @__addsf3(float, float)
+
+declare i32 @__eqdf2(double, double)
+
+declare i32 @__ltdf2(double, double)
+
+declare i32 @__unorddf2(double, double)
+
+declare i32 @__ledf2(double, double)
+
+declare i32 @__nedf2(double, double)
+
+declare i32 @__fixdfsi(double)
+
+declare i64 @__fixunssfdi(float)
+
+declare double @__floatsidf(i32)
+
+declare float @__floatunsisf(i32)
+
+declare double @__floatdidf(i64)
+
+declare double @__extendsfdf2(float)
+
+declare float @__truncdfsf2(double)
+
+declare i32 @__fixunsdfsi(double)
@@ -0,0 +1,78 @@
+target datalayout = "e-m:e-i64:64-f80:128-n8:16:32:64-S128"
+target triple = "x86_64--linux"
+
+@str = constant [3 x i8] c"foo"
+@str.1 = constant [3 x i8] c"bar"
+
+declare { ptr, i64 } @runtime.stringFromBytes(ptr, i64, i64)
+
+declare i1 @runtime.stringEqual(ptr, i64, ptr, i64)
+
+declare i1 @runtime.hashmapStringGet(ptr, ptr, i64, ptr, i64)
+
+declare void @runtime.printstring(ptr, i64)
+
+; Test that comparing string(buf) to a constant string doesn't copy buf.
+define i1 @testEqual(ptr %buf.ptr, i64 %buf.len, i64 %buf.cap) {
+entry:
+  %eq = call i1 @runtime.stringEqual(ptr %buf.ptr, i64 %buf.len, ptr @str, i64 3)
+  ret i1 %eq
+}
+
+; Test that a switch over string(buf) doesn't copy buf.
+define i64 @testSwitch(ptr %buf.ptr, i64 %buf.len, i64 %buf.cap) {
+entry:
+  %eq1 = call i1 @runtime.stringEqual(ptr %buf.ptr, i64 %buf.len, ptr @str, i64 3)
+  br i1 %eq1, label %foo, label %next
+
+next:
+  %eq2 = call i1 @runtime.stringEqual(ptr %buf.ptr, i64 %buf.len, ptr @str.1, i64 3)
+  br i1 %eq2, label %bar, label %default
+
+foo:
+  store i8 0, ptr %buf.ptr, align 1
+  ret i64 1
+
+bar:
+  ret i64 2
+
+default:
+  ret i64 0
+}
+
+; Test that a map lookup with string(buf) as key doesn't copy buf.
+define i64 @testMapGet(ptr %m, ptr %buf.ptr, i64 %buf.len, i64 %buf.cap) {
+entry:
+  %hashmap.value = alloca i64, align 8
+  %ok = call i1 @runtime.hashmapStringGet(ptr %m, ptr %buf.ptr, i64 %buf.len, ptr %hashmap.value, i64 8)
+  %value = load i64, ptr %hashmap.value, align 8
+  ret i64 %value
+}
+
+; Test that buf is copied if it may be modified before the string is used.
+define i1 @testWriteBetween(ptr %buf.ptr, i64 %buf.len, i64 %buf.cap) {
+entry:
+  %s = call { ptr, i64 } @runtime.stringFromBytes(ptr %buf.ptr, i64 %buf.len, i64 %buf.cap)
+  store i8 0, ptr %buf.ptr, align 1
+  %s.ptr = extractvalue { ptr, i64 } %s, 0
+  %s.len = extractvalue { ptr, i64 } %s, 1
+  %eq = call i1 @runtime.stringEqual(ptr %s.ptr, i64 %s.len, ptr @str, i64 3)
+  ret i1 %eq
+}
+
+; Test that buf is copied if the string escapes.
+define { ptr, i64 } @testEscape(ptr %buf.ptr, i64 %buf.len, i64 %buf.cap) {
+entry:
+  %s = call { ptr, i64 } @runtime.stringFromBytes(ptr %buf.ptr, i64 %buf.len, i64 %buf.cap)
+  ret { ptr, i64 } %s
+}
+
+; Test that buf is copied if the string is passed to an unknown function.
+define void @testPrint(ptr %buf.ptr, i64 %buf.len, i64 %buf.cap) {
+entry:
+  %s = call { ptr, i64 } @runtime.stringFromBytes(ptr %buf.ptr, i64 %buf.len, i64 %buf.cap)
+  %s.ptr = extractvalue { ptr, i64 } %s, 0
+  %s.len = extractvalue { ptr, i64 } %s, 1
+  call void @runtime.printstring(ptr %s.ptr, i64 %s.len)
+  ret void
+}
@@ -0,0 +1,18 @@
+target datalayout = "e-m:e-p:32:32-i64:64-n32:64-S128-ni:1:10:20"
+target triple = "wasm32-unknown-wasi"
+
+@"main$string.1" = internal unnamed_addr constant [16 x i8] c"unexpected error", align 1
+@"main$string.3" = internal unnamed_addr constant [3 x i8] c"foo", align 1
+@main.data = internal constant [5 x i8] c"error"
+@main.strings = internal global [4 x { ptr, i32 }] [{ ptr, i32 } { ptr getelementptr inbounds (i8, ptr @"main$string.1", i32 11), i32 5 }, { ptr, i32 } { ptr @"main$string.1", i32 16 }, { ptr, i32 } { ptr getelementptr inbounds (i8, ptr @"main$string.1", i32 11), i32 5 }, { ptr, i32 } { ptr @"main$string.1", i32 10 }]
+
+declare void @runtime.printstring(ptr, i32)
+
+declare void @runtime.printbytes(ptr)
+
+define void @main.print() {
+  call void @runtime.printstring(ptr getelementptr inbounds (i8, ptr @"main$string.1", i32 11), i32 5)
+  call void @runtime.printstring(ptr @"main$string.3", i32 3)
+  call void @runtime.printbytes(ptr @main.data)
+  ret void
+}
@@ -0,0 +1,35 @@
+target datalayout = "e-m:e-p:32:32-i64:64-n32:64-S128-ni:1:10:20"
+target triple = "wasm32-unknown-wasi"
+
+declare void @runtime.traceEnter(i32, ptr)
+
+declare void @runtime.traceExit(i32, ptr)
+
+define void @main.main(ptr %context) {
+entry:
+  %x = alloca i32, align 4
+  store i32 3, ptr %x, align 4
+  %result = call i32 @main.abs(i32 -3, ptr undef)
+  call void @runtime.printint32(i32 %result, ptr undef)
+  ret void
+}
+
+; Function with two returns.
+define i32 @main.abs(i32 %x, ptr %context) {
+entry:
+  %neg = icmp slt i32 %x, 0
+  br i1 %neg, label %negative, label %positive
+
+negative:
+  %negated = sub i32 0, %x
+  ret i32 %negated
+
+positive:
+  ret i32 %x
+}
+
+; Runtime functions are not instrumented.
+define void @runtime.printint32(i32 %n, ptr %context) {
+entry:
+  ret void
+}
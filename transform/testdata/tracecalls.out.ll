@@ -0,0 +1,38 @@
+target datalayout = "e-m:e-p:32:32-i64:64-n32:64-S128-ni:1:10:20"
+target triple = "wasm32-unknown-wasi"
+
+declare void @runtime.traceEnter(i32, ptr)
+
+declare void @runtime.traceExit(i32, ptr)
+
+define void @main.main(ptr %context) {
+entry:
+  %x = alloca i32, align 4
+  call void @runtime.traceEnter(i32 0, ptr undef)
+  store i32 3, ptr %x, align 4
+  %result = call i32 @main.abs(i32 -3, ptr undef)
+  call void @runtime.printint32(i32 %result, ptr undef)
+  call void @runtime.traceExit(i32 0, ptr undef)
+  ret void
+}
+
+define i32 @main.abs(i32 %x, ptr %context) {
+entry:
+  call void @runtime.traceEnter(i32 1, ptr undef)
+  %neg = icmp slt i32 %x, 0
+  br i1 %neg, label %negative, label %positive
+
+negative:                                         ; preds = %entry
+  %negated = sub i32 0, %x
+  call void @runtime.traceExit(i32 1, ptr undef)
+  ret i32 %negated
+
+positive:                                         ; preds = %entry
+  call void @runtime.traceExit(i32 1, ptr undef)
+  ret i32 %x
+}
+
+define void @runtime.printint32(i32 %n, ptr %context) {
+entry:
+  ret void
+}
@@ -0,0 +1,61 @@
+target datalayout = "e-m:e-p:32:32-i64:64-n32:64-S128-ni:1:10:20"
+target triple = "wasm32-unknown-wasi"
+
+@runtime.yieldInterval = global i32 1
+
+declare void @runtime.yieldPoint(ptr)
+
+; Simple counting loop: the back-edge is in the loop body.
+define void @main.loop(i32 %n, ptr %context) {
+entry:
+  br label %loop.header
+
+loop.header:
+  %i = phi i32 [ 0, %entry ], [ %i.next, %loop.body ]
+  %cond = icmp slt i32 %i, %n
+  br i1 %cond, label %loop.body, label %loop.exit
+
+loop.body:
+  %i.next = add i32 %i, 1
+  br label %loop.header
+
+loop.exit:
+  ret void
+}
+
+; Infinite loop, where the back-edge is a branch to the same block.
+define void @main.forever(ptr %context) {
+entry:
+  br label %loop
+
+loop:
+  br label %loop
+}
+
+; No loop, so no yield points.
+define i32 @main.noLoop(i32 %x, ptr %context) {
+entry:
+  %cond = icmp eq i32 %x, 0
+  br i1 %cond, label %zero, label %nonzero
+
+zero:
+  ret i32 1
+
+nonzero:
+  ret i32 %x
+}
+
+; Loops in the runtime are not instrumented.
+define void @runtime.memzero(ptr %ptr, i32 %n, ptr %context) {
+entry:
+  br label %loop
+
+loop:
+  %i = phi i32 [ 0, %entry ], [ %i.next, %loop ]
+  %i.next = add i32 %i, 1
+  %cond = icmp slt i32 %i.next, %n
+  br i1 %cond, label %loop, label %exit
+
+exit:
+  ret void
+}
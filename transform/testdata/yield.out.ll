@@ -0,0 +1,59 @@
+target datalayout = "e-m:e-p:32:32-i64:64-n32:64-S128-ni:1:10:20"
+target triple = "wasm32-unknown-wasi"
+
+@runtime.yieldInterval = global i32 1000
+
+declare void @runtime.yieldPoint(ptr)
+
+define void @main.loop(i32 %n, ptr %context) {
+entry:
+  br label %loop.header
+
+loop.header:                                      ; preds = %loop.body, %entry
+  %i = phi i32 [ 0, %entry ], [ %i.next, %loop.body ]
+  %cond = icmp slt i32 %i, %n
+  br i1 %cond, label %loop.body, label %loop.exit
+
+loop.body:                                        ; preds = %loop.header
+  %i.next = add i32 %i, 1
+  call void @runtime.yieldPoint(ptr undef)
+  br label %loop.header
+
+loop.exit:                                        ; preds = %loop.header
+  ret void
+}
+
+define void @main.forever(ptr %context) {
+entry:
+  br label %loop
+
+loop:                                             ; preds = %loop, %entry
+  call void @runtime.yieldPoint(ptr undef)
+  br label %loop
+}
+
+define i32 @main.noLoop(i32 %x, ptr %context) {
+entry:
+  %cond = icmp eq i32 %x, 0
+  br i1 %cond, label %zero, label %nonzero
+
+zero:                                             ; preds = %entry
+  ret i32 1
+
+nonzero:                                          ; preds = %entry
+  ret i32 %x
+}
+
+define void @runtime.memzero(ptr %ptr, i32 %n, ptr %context) {
+entry:
+  br label %loop
+
+loop:                                             ; preds = %loop, %entry
+  %i = phi i32 [ 0, %entry ], [ %i.next, %loop ]
+  %i.next = add i32 %i, 1
+  %cond = icmp slt i32 %i.next, %n
+  br i1 %cond, label %loop, label %exit
+
+exit:                                             ; preds = %loop
+  ret void
+}
@@ -0,0 +1,68 @@
+package transform
+
+import (
+	"errors"
+	"strings"
+
+	"tinygo.org/x/go-llvm"
+)
+
+// InstrumentCalls inserts a call to runtime.traceEnter at the start of every
+// function and a call to runtime.traceExit before every return, for the
+// -trace-calls option. Both get a numeric function ID as parameter. The
+// returned slice maps these IDs back to function names.
+//
+// Functions in the runtime are not instrumented, as they would recurse into
+// the tracing code.
+func InstrumentCalls(mod llvm.Module) ([]string, error) {
+	traceEnter := mod.NamedFunction("runtime.traceEnter")
+	traceExit := mod.NamedFunction("runtime.traceExit")
+	if traceEnter.IsNil() || traceExit.IsNil() {
+		return nil, errors.New("-trace-calls: runtime.traceEnter or runtime.traceExit is missing")
+	}
+
+	ctx := mod.Context()
+	builder := ctx.NewBuilder()
+	defer builder.Dispose()
+	context := llvm.Undef(llvm.PointerType(ctx.Int8Type(), 0))
+
+	var names []string
+	for fn := mod.FirstFunction(); !fn.IsNil(); fn = llvm.NextFunction(fn) {
+		if fn.IsDeclaration() {
+			continue
+		}
+		name := fn.Name()
+		if strings.HasPrefix(name, "runtime.") || strings.HasPrefix(name, "internal/task.") {
+			continue
+		}
+		id := llvm.ConstInt(ctx.Int32Type(), uint64(len(names)), false)
+		names = append(names, name)
+
+		// Calls in functions with debug information need a debug location,
+		// so use the start of the function if nothing better is available.
+		builder.SetCurrentDebugLocation(0, 0, fn.Subprogram(), llvm.Metadata{})
+		insertCall := func(before, callee llvm.Value) {
+			builder.SetInsertPointBefore(before)
+			call := builder.CreateCall(callee.GlobalValueType(), callee, []llvm.Value{id, context}, "")
+			if loc := before.InstructionDebugLoc(); !loc.IsNil() {
+				call.InstructionSetDebugLoc(loc)
+			}
+		}
+
+		// Record the function entry after the allocas at the start of the
+		// entry block, so that they stay together.
+		entry := fn.EntryBasicBlock().FirstInstruction()
+		for !entry.IsAAllocaInst().IsNil() {
+			entry = llvm.NextInstruction(entry)
+		}
+		insertCall(entry, traceEnter)
+
+		// Record the function exit at every return.
+		for bb := fn.FirstBasicBlock(); !bb.IsNil(); bb = llvm.NextBasicBlock(bb) {
+			if terminator := bb.LastInstruction(); !terminator.IsAReturnInst().IsNil() {
+				insertCall(terminator, traceExit)
+			}
+		}
+	}
+	return names, nil
+}
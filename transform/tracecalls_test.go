@@ -0,0 +1,22 @@
+package transform_test
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/tinygo-org/tinygo/transform"
+	"tinygo.org/x/go-llvm"
+)
+
+func TestInstrumentCalls(t *testing.T) {
+	t.Parallel()
+	testTransform(t, "testdata/tracecalls", func(mod llvm.Module) {
+		names, err := transform.InstrumentCalls(mod)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if expected := []string{"main.main", "main.abs"}; !reflect.DeepEqual(names, expected) {
+			t.Errorf("unexpected function names: %v", names)
+		}
+	})
+}
@@ -0,0 +1,71 @@
+package transform
+
+import (
+	"errors"
+	"strings"
+
+	"tinygo.org/x/go-llvm"
+)
+
+// InsertYieldPoints inserts a call to runtime.yieldPoint at every loop
+// back-edge, so that the host gets a chance to interrupt long running loops
+// (for example to enforce a deadline) without needing fuel metering. The
+// runtime only calls out to the host once every interval calls to
+// runtime.yieldPoint. This is the -yield-points= command line option.
+//
+// Loops in the runtime itself are not instrumented: they are normally short
+// and often can't safely call out to the host (for example, in the GC).
+//
+// A back-edge is detected as a branch to a block that comes earlier in the
+// function (or to the same block). This is slightly conservative, but doesn't
+// need a dominator tree and may at most result in some extra yield points.
+func InsertYieldPoints(mod llvm.Module, interval uint32) error {
+	yieldPoint := mod.NamedFunction("runtime.yieldPoint")
+	if yieldPoint.IsNil() {
+		return errors.New("-yield-points is not supported on this target")
+	}
+	if global := mod.NamedGlobal("runtime.yieldInterval"); !global.IsNil() {
+		global.SetInitializer(llvm.ConstInt(global.GlobalValueType(), uint64(interval), false))
+	}
+
+	ctx := mod.Context()
+	builder := ctx.NewBuilder()
+	defer builder.Dispose()
+	context := llvm.Undef(llvm.PointerType(ctx.Int8Type(), 0))
+
+	for fn := mod.FirstFunction(); !fn.IsNil(); fn = llvm.NextFunction(fn) {
+		if fn.IsDeclaration() {
+			continue
+		}
+		name := fn.Name()
+		if strings.HasPrefix(name, "runtime.") || strings.HasPrefix(name, "internal/task.") {
+			continue
+		}
+
+		// Calls in functions with debug information need a debug location.
+		builder.SetCurrentDebugLocation(0, 0, fn.Subprogram(), llvm.Metadata{})
+
+		blockIndices := make(map[llvm.BasicBlock]int)
+		for i, bb := range fn.BasicBlocks() {
+			blockIndices[bb] = i
+		}
+		for i, bb := range fn.BasicBlocks() {
+			terminator := bb.LastInstruction()
+			for j := 0; j < terminator.OperandsCount(); j++ {
+				operand := terminator.Operand(j)
+				if !operand.IsBasicBlock() {
+					continue
+				}
+				if target, ok := blockIndices[operand.AsBasicBlock()]; ok && target <= i {
+					builder.SetInsertPointBefore(terminator)
+					call := builder.CreateCall(yieldPoint.GlobalValueType(), yieldPoint, []llvm.Value{context}, "")
+					if loc := terminator.InstructionDebugLoc(); !loc.IsNil() {
+						call.InstructionSetDebugLoc(loc)
+					}
+					break
+				}
+			}
+		}
+	}
+	return nil
+}
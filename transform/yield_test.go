@@ -0,0 +1,18 @@
+package transform_test
+
+import (
+	"testing"
+
+	"github.com/tinygo-org/tinygo/transform"
+	"tinygo.org/x/go-llvm"
+)
+
+func TestInsertYieldPoints(t *testing.T) {
+	t.Parallel()
+	testTransform(t, "testdata/yield", func(mod llvm.Module) {
+		err := transform.InsertYieldPoints(mod, 1000)
+		if err != nil {
+			t.Error(err)
+		}
+	})
+}
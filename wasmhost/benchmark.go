@@ -0,0 +1,77 @@
+package wasmhost
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/tetratelabs/wazero"
+)
+
+// Prefix of the exported functions that are benchmarks.
+const benchmarkPrefix = "bench_"
+
+// BenchmarkResult is the result of a single benchmark, as reported by the
+// runtime/benchmark package.
+type BenchmarkResult struct {
+	Name        string `json:"name"`
+	N           int    `json:"n"`
+	Nanoseconds uint64 `json:"ns"`
+	Mallocs     uint64 `json:"mallocs"`
+	AllocBytes  uint64 `json:"bytes"`
+}
+
+// String formats the result like `go test -bench -benchmem` does.
+func (r BenchmarkResult) String() string {
+	n := uint64(r.N)
+	if n == 0 {
+		n = 1
+	}
+	return fmt.Sprintf("Benchmark%s\t%8d\t%10d ns/op\t%8d B/op\t%8d allocs/op", r.Name, r.N, r.Nanoseconds/n, r.AllocBytes/n, r.Mallocs/n)
+}
+
+// RunBenchmarks initializes the WebAssembly module at the given path and calls
+// all exported benchmark functions (functions starting with "bench_") of which
+// the rest of the name matches the given regular expression, in alphabetical
+// order. It returns the results reported by these functions.
+func RunBenchmarks(ctx context.Context, path string, config Config, match *regexp.Regexp) ([]BenchmarkResult, error) {
+	r := wazero.NewRuntime(ctx)
+	defer r.Close(ctx)
+
+	mod, _, err := instantiate(ctx, r, newHost(config.Stderr, config.Stdout), path, config)
+	if err != nil {
+		return nil, err
+	}
+	if err := start(ctx, mod, "_initialize"); err != nil {
+		return nil, err
+	}
+
+	var names []string
+	for name := range mod.ExportedFunctionDefinitions() {
+		if strings.HasPrefix(name, benchmarkPrefix) && match.MatchString(name[len(benchmarkPrefix):]) {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	var results []BenchmarkResult
+	for _, name := range names {
+		fn := mod.ExportedFunction(name)
+		if len(fn.Definition().ParamTypes()) != 0 || len(fn.Definition().ResultTypes()) != 1 || fn.Definition().ResultTypes()[0] != i64 {
+			return nil, fmt.Errorf("%s: benchmark functions must have the signature func() uint64", name)
+		}
+		ret, err := fn.Call(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", name, err)
+		}
+		var reported []BenchmarkResult
+		if err := json.Unmarshal(readPointerSize(mod.Memory(), ret[0]), &reported); err != nil {
+			return nil, fmt.Errorf("%s: could not decode benchmark report: %w", name, err)
+		}
+		results = append(results, reported...)
+	}
+	return results, nil
+}
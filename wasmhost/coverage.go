@@ -0,0 +1,237 @@
+package wasmhost
+
+// Coverage data of modules built with -cover is written to a directory, in a
+// similar way to the GOCOVERDIR directory of Go programs: a covmeta.<hash>
+// file with the tinygo.coverage section of the module (see
+// builder/coverage.go for its format) and a covcounters.<hash>.<pid>.<time>
+// file with the counters of every run. WriteCoverProfile merges all of these
+// into a single coverage profile.
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/tetratelabs/wazero"
+	"github.com/tetratelabs/wazero/api"
+)
+
+// coverageDump writes the coverage counters of a module after it has run.
+type coverageDump struct {
+	mem      api.Memory
+	location uint64 // pointer-size of the counters
+	meta     []byte // contents of the tinygo.coverage section
+	metaHash string
+}
+
+// prepareCoverage finds the coverage counters of a module, before it is
+// started. It returns nil if the module wasn't built with -cover.
+func prepareCoverage(ctx context.Context, mod api.Module, compiled wazero.CompiledModule) (*coverageDump, error) {
+	fn := mod.ExportedFunction("tinygo_coverage")
+	if fn == nil {
+		return nil, nil
+	}
+	var meta []byte
+	for _, section := range compiled.CustomSections() {
+		if section.Name() == "tinygo.coverage" {
+			meta = section.Data()
+		}
+	}
+	if meta == nil {
+		return nil, errors.New("module exports tinygo_coverage but has no tinygo.coverage section")
+	}
+	results, err := fn.Call(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("could not find coverage counters: %w", err)
+	}
+	sum := sha256.Sum256(meta)
+	return &coverageDump{
+		mem:      mod.Memory(),
+		location: results[0],
+		meta:     meta,
+		metaHash: hex.EncodeToString(sum[:8]),
+	}, nil
+}
+
+// write writes the metadata (if it isn't there yet) and the current counters
+// to the given directory.
+func (c *coverageDump) write(dir string) error {
+	if err := os.MkdirAll(dir, 0o777); err != nil {
+		return err
+	}
+	metaPath := filepath.Join(dir, "covmeta."+c.metaHash)
+	if _, err := os.Stat(metaPath); err != nil {
+		if err := os.WriteFile(metaPath, c.meta, 0o666); err != nil {
+			return err
+		}
+	}
+	var counters []byte
+	if c.location != 0 {
+		counters = readPointerSize(c.mem, c.location)
+	}
+	countersPath := filepath.Join(dir, fmt.Sprintf("covcounters.%s.%d.%d", c.metaHash, os.Getpid(), time.Now().UnixNano()))
+	return os.WriteFile(countersPath, counters, 0o666)
+}
+
+// coverageBlock is a single block in a coverage profile.
+type coverageBlock struct {
+	file                string
+	startLine, startCol uint32
+	endLine, endCol     uint32
+	numStmts            uint32
+}
+
+// parseCoverageMeta parses the contents of the tinygo.coverage section.
+func parseCoverageMeta(data []byte) ([]coverageBlock, error) {
+	readUint := func() (uint32, error) {
+		value, n := binary.Uvarint(data)
+		if n <= 0 || value > 1<<32-1 {
+			return 0, errors.New("invalid coverage metadata")
+		}
+		data = data[n:]
+		return uint32(value), nil
+	}
+	numFiles, err := readUint()
+	if err != nil {
+		return nil, err
+	}
+	var files []string
+	for i := uint32(0); i < numFiles; i++ {
+		length, err := readUint()
+		if err != nil {
+			return nil, err
+		}
+		if uint32(len(data)) < length {
+			return nil, errors.New("invalid coverage metadata")
+		}
+		files = append(files, string(data[:length]))
+		data = data[length:]
+	}
+	numBlocks, err := readUint()
+	if err != nil {
+		return nil, err
+	}
+	var blocks []coverageBlock
+	for i := uint32(0); i < numBlocks; i++ {
+		var values [6]uint32
+		for j := range values {
+			values[j], err = readUint()
+			if err != nil {
+				return nil, err
+			}
+		}
+		if values[0] >= uint32(len(files)) {
+			return nil, errors.New("invalid coverage metadata")
+		}
+		blocks = append(blocks, coverageBlock{files[values[0]], values[1], values[2], values[3], values[4], values[5]})
+	}
+	return blocks, nil
+}
+
+// WriteCoverProfile reads the coverage data written by modules built with
+// -cover in the given directories, and writes a coverage profile in the
+// "count" mode of go test. The counts of all runs are added together. It
+// returns the fraction of statements that were covered.
+func WriteCoverProfile(w io.Writer, dirs []string) (float64, error) {
+	metas := make(map[string][]coverageBlock)
+	var counterFiles []string
+	for _, dir := range dirs {
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			return 0, err
+		}
+		for _, entry := range entries {
+			path := filepath.Join(dir, entry.Name())
+			if strings.HasPrefix(entry.Name(), "covmeta.") {
+				hash := strings.TrimPrefix(entry.Name(), "covmeta.")
+				data, err := os.ReadFile(path)
+				if err != nil {
+					return 0, err
+				}
+				blocks, err := parseCoverageMeta(data)
+				if err != nil {
+					return 0, fmt.Errorf("%s: %w", path, err)
+				}
+				metas[hash] = blocks
+			} else if strings.HasPrefix(entry.Name(), "covcounters.") {
+				counterFiles = append(counterFiles, path)
+			}
+		}
+	}
+
+	// Add up the counters of all runs. All blocks are included in the
+	// profile, including the ones that never ran.
+	counts := make(map[coverageBlock]uint64)
+	for _, blocks := range metas {
+		for _, block := range blocks {
+			counts[block] += 0
+		}
+	}
+	for _, path := range counterFiles {
+		hash := strings.Split(filepath.Base(path), ".")[1]
+		blocks, ok := metas[hash]
+		if !ok {
+			return 0, fmt.Errorf("%s: no coverage metadata found", path)
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return 0, err
+		}
+		if len(data) != len(blocks)*4 {
+			return 0, fmt.Errorf("%s: expected %d counters, found %d bytes", path, len(blocks), len(data))
+		}
+		for i, block := range blocks {
+			counts[block] += uint64(binary.LittleEndian.Uint32(data[i*4:]))
+		}
+	}
+
+	blocks := make([]coverageBlock, 0, len(counts))
+	for block := range counts {
+		blocks = append(blocks, block)
+	}
+	sort.Slice(blocks, func(i, j int) bool {
+		a, b := blocks[i], blocks[j]
+		if a.file != b.file {
+			return a.file < b.file
+		}
+		if a.startLine != b.startLine {
+			return a.startLine < b.startLine
+		}
+		if a.startCol != b.startCol {
+			return a.startCol < b.startCol
+		}
+		if a.endLine != b.endLine {
+			return a.endLine < b.endLine
+		}
+		return a.endCol < b.endCol
+	})
+
+	var total, covered uint64
+	if _, err := fmt.Fprintln(w, "mode: count"); err != nil {
+		return 0, err
+	}
+	for _, block := range blocks {
+		count := counts[block]
+		total += uint64(block.numStmts)
+		if count != 0 {
+			covered += uint64(block.numStmts)
+		}
+		_, err := fmt.Fprintf(w, "%s:%d.%d,%d.%d %d %d\n", block.file, block.startLine, block.startCol, block.endLine, block.endCol, block.numStmts, count)
+		if err != nil {
+			return 0, err
+		}
+	}
+	if total == 0 {
+		return 0, nil
+	}
+	return float64(covered) / float64(total), nil
+}
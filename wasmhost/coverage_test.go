@@ -0,0 +1,90 @@
+package wasmhost
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestCoverage(t *testing.T) {
+	// A WASI command built with -cover, with two coverage blocks of which
+	// only the first ran (7 times). It exits through proc_exit, so the
+	// counters must be read after the module has been closed.
+	types := []byte{3,
+		0x60, 0, 0, // () -> ()
+		0x60, 0, 1, 0x7e, // () -> i64
+		0x60, 1, 0x7f, 0, // (i32) -> ()
+	}
+	var imports []byte
+	imports = appendULEB128(imports, 1)
+	imports = appendName(imports, "wasi_snapshot_preview1")
+	imports = appendName(imports, "proc_exit")
+	imports = append(imports, 0x00, 2) // function 0
+	var exports []byte
+	exports = appendULEB128(exports, 3)
+	exports = appendName(exports, "memory")
+	exports = append(exports, 0x02, 0) // memory 0
+	exports = appendName(exports, "_start")
+	exports = append(exports, 0x00, 1) // function 1
+	exports = appendName(exports, "tinygo_coverage")
+	exports = append(exports, 0x00, 2) // function 2
+
+	var codes []byte
+	codes = appendULEB128(codes, 2)
+	for _, code := range [][]byte{
+		{0x41, 0, 0x41, 7, 0x36, 2, 16, 0x41, 0, 0x10, 0}, // store 7 at address 16, proc_exit(0)
+		appendSLEB128([]byte{0x42}, 16|8<<32),             // two counters at address 16
+	} {
+		body := append([]byte{0}, code...) // no locals
+		body = append(body, 0x0b)          // end
+		codes = appendULEB128(codes, uint32(len(body)))
+		codes = append(codes, body...)
+	}
+
+	var meta []byte
+	meta = appendULEB128(meta, 1)
+	meta = appendName(meta, "example.com/main.go")
+	meta = appendULEB128(meta, 2)
+	meta = append(meta, 0, 3, 2, 4, 10, 2) // main.go:3.2,4.10 with 2 statements
+	meta = append(meta, 0, 5, 2, 6, 3, 1)  // main.go:5.2,6.3 with 1 statement
+
+	module := []byte{0x00, 'a', 's', 'm', 0x01, 0x00, 0x00, 0x00}
+	module = appendSection(module, 1, types)
+	module = appendSection(module, 2, imports)
+	module = appendSection(module, 3, []byte{2, 0, 1})
+	module = appendSection(module, 5, []byte{1, 0x00, 1}) // memory with at least 1 page
+	module = appendSection(module, 7, exports)
+	module = appendSection(module, 10, codes)
+	module = appendSection(module, 0, append(appendName(nil, "tinygo.coverage"), meta...))
+	path := filepath.Join(t.TempDir(), "test.wasm")
+	if err := os.WriteFile(path, module, 0o666); err != nil {
+		t.Fatal(err)
+	}
+
+	// Run it twice, so that the counters of both runs are added together.
+	coverDir := t.TempDir()
+	for i := 0; i < 2; i++ {
+		exitCode, err := Run(context.Background(), path, Config{CoverDir: coverDir})
+		if err != nil {
+			t.Fatal("could not run module:", err)
+		}
+		if exitCode != 0 {
+			t.Fatalf("unexpected exit code %d", exitCode)
+		}
+	}
+
+	var profile bytes.Buffer
+	covered, err := WriteCoverProfile(&profile, []string{coverDir})
+	if err != nil {
+		t.Fatal("could not write coverage profile:", err)
+	}
+	expected := "mode: count\nexample.com/main.go:3.2,4.10 2 14\nexample.com/main.go:5.2,6.3 1 0\n"
+	if profile.String() != expected {
+		t.Errorf("unexpected coverage profile:\n%s", profile.String())
+	}
+	if covered < 0.66 || covered > 0.67 {
+		t.Errorf("expected 2/3 of the statements to be covered, got %f", covered)
+	}
+}
@@ -0,0 +1,87 @@
+package wasmhost
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"io"
+
+	"github.com/tetratelabs/wazero/api"
+)
+
+// CrashDump is the state of a module built with -crash-dump at the time it
+// trapped, as saved by the runtime.
+type CrashDump struct {
+	SP        uint32 // stack pointer
+	StackTop  uint32 // top of the stack, as passed by the host to an exported function
+	HeapStart uint32
+	HeapEnd   uint32
+	HeapInuse uint32
+	Mallocs   uint32
+	Frees     uint32
+	Export    string // name of the exported function that was called, if any
+	Log       []byte // most recent debug output, oldest byte first
+}
+
+// Layout of the crash dump in linear memory, see crashDumpRecord in the
+// runtime.
+const (
+	crashDumpHeaderSize = 44
+	crashDumpLogSize    = 256
+)
+
+// readCrashDump reads the crash dump of a module that exports
+// tinygo_crash_dump. It returns nil if the module doesn't export it or didn't
+// capture a crash dump.
+func readCrashDump(ctx context.Context, mod api.Module) *CrashDump {
+	fn := mod.ExportedFunction("tinygo_crash_dump")
+	if fn == nil {
+		return nil
+	}
+	results, err := fn.Call(ctx)
+	if err != nil || len(results) != 1 {
+		return nil
+	}
+	data, ok := mod.Memory().Read(uint32(results[0]), crashDumpHeaderSize+crashDumpLogSize)
+	if !ok || binary.LittleEndian.Uint32(data[0:]) == 0 {
+		return nil
+	}
+	dump := &CrashDump{
+		SP:        binary.LittleEndian.Uint32(data[4:]),
+		StackTop:  binary.LittleEndian.Uint32(data[8:]),
+		HeapStart: binary.LittleEndian.Uint32(data[12:]),
+		HeapEnd:   binary.LittleEndian.Uint32(data[16:]),
+		HeapInuse: binary.LittleEndian.Uint32(data[20:]),
+		Mallocs:   binary.LittleEndian.Uint32(data[24:]),
+		Frees:     binary.LittleEndian.Uint32(data[28:]),
+	}
+	if name, ok := mod.Memory().Read(binary.LittleEndian.Uint32(data[32:]), binary.LittleEndian.Uint32(data[36:])); ok {
+		dump.Export = string(name)
+	}
+
+	// The log is a ring buffer: the oldest byte is right after the newest one
+	// once it has wrapped around.
+	written := binary.LittleEndian.Uint32(data[40:])
+	log := data[crashDumpHeaderSize:]
+	if written <= crashDumpLogSize {
+		dump.Log = append(dump.Log, log[:written]...)
+	} else {
+		start := written % crashDumpLogSize
+		dump.Log = append(dump.Log, log[start:]...)
+		dump.Log = append(dump.Log, log[:start]...)
+	}
+	return dump
+}
+
+// WriteCrashDump writes a human readable version of the crash dump to w.
+func WriteCrashDump(w io.Writer, dump *CrashDump) {
+	fmt.Fprintln(w, "crash dump:")
+	if dump.Export != "" {
+		fmt.Fprintf(w, "  export:     %s\n", dump.Export)
+	}
+	fmt.Fprintf(w, "  stack:      sp=%#x top=%#x (%d bytes in use)\n", dump.SP, dump.StackTop, int64(dump.StackTop)-int64(dump.SP))
+	fmt.Fprintf(w, "  heap:       %#x-%#x, %d bytes in use, %d mallocs, %d frees\n", dump.HeapStart, dump.HeapEnd, dump.HeapInuse, dump.Mallocs, dump.Frees)
+	if len(dump.Log) != 0 {
+		fmt.Fprintf(w, "  last output:\n%s\n", dump.Log)
+	}
+}
@@ -0,0 +1,76 @@
+package wasmhost
+
+import (
+	"github.com/tetratelabs/wazero/api"
+)
+
+// envModule creates a WebAssembly module that imports the given host functions
+// from the host module and exports them again, together with a newly defined
+// memory.
+func envModule(functions []hostFunction, memory *memoryImport) []byte {
+	var types, imports, exports []byte
+	types = appendULEB128(types, uint32(len(functions)))
+	imports = appendULEB128(imports, uint32(len(functions)))
+	exports = appendULEB128(exports, uint32(len(functions)+1))
+	for i, f := range functions {
+		types = append(types, 0x60) // function type
+		types = appendValueTypes(types, f.params)
+		types = appendValueTypes(types, f.results)
+
+		imports = appendName(imports, hostModuleName)
+		imports = appendName(imports, f.name)
+		imports = append(imports, 0x00) // function import
+		imports = appendULEB128(imports, uint32(i))
+
+		exports = appendName(exports, f.name)
+		exports = append(exports, 0x00) // function export
+		exports = appendULEB128(exports, uint32(i))
+	}
+	exports = appendName(exports, memory.name)
+	exports = append(exports, 0x02, 0x00) // memory export, index 0
+
+	var memories []byte
+	memories = appendULEB128(memories, 1)
+	if memory.hasMax {
+		memories = append(memories, 0x01)
+		memories = appendULEB128(memories, memory.min)
+		memories = appendULEB128(memories, memory.max)
+	} else {
+		memories = append(memories, 0x00)
+		memories = appendULEB128(memories, memory.min)
+	}
+
+	module := []byte{0x00, 'a', 's', 'm', 0x01, 0x00, 0x00, 0x00}
+	module = appendSection(module, 1, types)
+	module = appendSection(module, 2, imports)
+	module = appendSection(module, 5, memories)
+	module = appendSection(module, 7, exports)
+	return module
+}
+
+func appendSection(buf []byte, id byte, payload []byte) []byte {
+	buf = append(buf, id)
+	buf = appendULEB128(buf, uint32(len(payload)))
+	return append(buf, payload...)
+}
+
+func appendValueTypes(buf []byte, types []api.ValueType) []byte {
+	buf = appendULEB128(buf, uint32(len(types)))
+	return append(buf, types...)
+}
+
+func appendName(buf []byte, name string) []byte {
+	buf = appendULEB128(buf, uint32(len(name)))
+	return append(buf, name...)
+}
+
+func appendULEB128(buf []byte, value uint32) []byte {
+	for {
+		b := byte(value & 0x7f)
+		value >>= 7
+		if value == 0 {
+			return append(buf, b)
+		}
+		buf = append(buf, b|0x80)
+	}
+}
@@ -0,0 +1,274 @@
+package wasmhost
+
+// This file implements a small coverage-guided fuzzing engine for
+// `tinygo test -fuzz`. The fuzz loop runs inside the WebAssembly module (see
+// src/testing/fuzz_host.go) and asks the host for every next input. The module
+// is built with an 8-bit counter for every basic block, which the host reads
+// after every input: inputs that reach new code, or the same code a different
+// number of times, are added to the corpus and mutated further.
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/tetratelabs/wazero/api"
+)
+
+// Maximum length of a generated input.
+const fuzzMaxLen = 4096
+
+// Header of the corpus files in testdata/fuzz, which are in the same format as
+// the ones written by `go test -fuzz`.
+const fuzzCorpusHeader = "go test fuzz v1"
+
+// Interesting byte values to try.
+var fuzzInterestingBytes = []byte{0, 1, 0x7f, 0x80, 0xff}
+
+type fuzzer struct {
+	name     string
+	dir      string    // directory with the corpus files
+	isString bool      // the fuzz function takes a string, not a []byte
+	counters uint64    // pointer-size of the coverage counters in the module
+	deadline time.Time // time to stop fuzzing, zero to fuzz forever
+	out      io.Writer
+	rand     *rand.Rand
+
+	corpus  [][]byte // inputs that found new coverage
+	pending [][]byte // inputs to run as-is before mutating the corpus
+	seen    []byte   // coverage buckets seen for each counter
+	zeros   []byte   // used for clearing the counters
+	current []byte   // input that is currently running
+
+	execs       int
+	interesting int
+	start       time.Time
+	lastStatus  time.Time
+}
+
+func newFuzzer(name string, isString bool, counters uint64, fuzzTime time.Duration, out io.Writer) *fuzzer {
+	now := time.Now()
+	f := &fuzzer{
+		name:       name,
+		dir:        filepath.Join("testdata", "fuzz", name),
+		isString:   isString,
+		counters:   counters,
+		out:        out,
+		rand:       rand.New(rand.NewSource(now.UnixNano())),
+		seen:       make([]byte, counters>>32),
+		zeros:      make([]byte, counters>>32),
+		start:      now,
+		lastStatus: now,
+	}
+	if fuzzTime > 0 {
+		f.deadline = now.Add(fuzzTime)
+	}
+
+	// Run the inputs that failed previously first.
+	entries, _ := os.ReadDir(f.dir)
+	for _, entry := range entries {
+		data, err := os.ReadFile(filepath.Join(f.dir, entry.Name()))
+		if err == nil {
+			data, err = parseCorpusFile(data)
+		}
+		if err != nil {
+			fmt.Fprintf(out, "fuzz: skipping %s: %v\n", filepath.Join(f.dir, entry.Name()), err)
+			continue
+		}
+		f.addSeed(data)
+	}
+	return f
+}
+
+// addSeed adds an input to the corpus.
+func (f *fuzzer) addSeed(data []byte) {
+	data = append([]byte(nil), data...)
+	f.corpus = append(f.corpus, data)
+	f.pending = append(f.pending, data)
+}
+
+// next processes the result of the current input, and returns the length of
+// the next input or -1 when fuzzing should stop.
+func (f *fuzzer) next(mem api.Memory, failed bool) int32 {
+	if f.current != nil {
+		if failed {
+			if err := f.saveFailure(); err != nil {
+				fmt.Fprintln(f.out, "fuzz:", err)
+			}
+			return -1
+		}
+		f.execs++
+		if f.updateCoverage(mem) {
+			f.corpus = append(f.corpus, f.current)
+			f.interesting++
+		}
+	}
+
+	now := time.Now()
+	if !f.deadline.IsZero() && now.After(f.deadline) {
+		f.printStatus(now)
+		f.current = nil
+		return -1
+	}
+	if now.Sub(f.lastStatus) >= 3*time.Second {
+		f.printStatus(now)
+	}
+
+	if len(f.zeros) != 0 && !mem.Write(uint32(f.counters), f.zeros) {
+		panic("fuzz: out of bounds coverage counters")
+	}
+	if len(f.pending) != 0 {
+		f.current = f.pending[0]
+		f.pending = f.pending[1:]
+	} else {
+		f.current = f.mutate()
+	}
+	return int32(len(f.current))
+}
+
+// updateCoverage reads the coverage counters, and returns whether the current
+// input reached a basic block a number of times that hasn't been seen before.
+// Like libFuzzer, the counters are put in buckets so that only significant
+// changes are taken into account.
+func (f *fuzzer) updateCoverage(mem api.Memory) bool {
+	if len(f.seen) == 0 {
+		return false
+	}
+	newCoverage := false
+	for i, count := range readPointerSize(mem, f.counters) {
+		if count == 0 {
+			continue
+		}
+		var bucket byte
+		switch {
+		case count <= 3:
+			bucket = 1 << (count - 1)
+		case count <= 7:
+			bucket = 1 << 3
+		case count <= 15:
+			bucket = 1 << 4
+		case count <= 31:
+			bucket = 1 << 5
+		case count <= 127:
+			bucket = 1 << 6
+		default:
+			bucket = 1 << 7
+		}
+		if f.seen[i]&bucket == 0 {
+			f.seen[i] |= bucket
+			newCoverage = true
+		}
+	}
+	return newCoverage
+}
+
+// mutate returns a new input, based on a random input from the corpus.
+func (f *fuzzer) mutate() []byte {
+	var data []byte
+	if len(f.corpus) != 0 {
+		data = append(data, f.corpus[f.rand.Intn(len(f.corpus))]...)
+	}
+	for n := 1 + f.rand.Intn(4); n > 0; n-- {
+		op := f.rand.Intn(8)
+		if len(data) == 0 {
+			op = 0 // only inserting makes sense
+		}
+		switch op {
+		case 0: // insert a random byte
+			i := f.rand.Intn(len(data) + 1)
+			data = append(data[:i], append([]byte{byte(f.rand.Intn(256))}, data[i:]...)...)
+		case 1: // remove a range of bytes
+			i := f.rand.Intn(len(data))
+			j := i + 1 + f.rand.Intn(len(data)-i)
+			data = append(data[:i], data[j:]...)
+		case 2: // flip a bit
+			data[f.rand.Intn(len(data))] ^= 1 << f.rand.Intn(8)
+		case 3: // set a random byte
+			data[f.rand.Intn(len(data))] = byte(f.rand.Intn(256))
+		case 4: // set an interesting byte
+			data[f.rand.Intn(len(data))] = fuzzInterestingBytes[f.rand.Intn(len(fuzzInterestingBytes))]
+		case 5: // add or subtract a small number
+			data[f.rand.Intn(len(data))] += byte(f.rand.Intn(33) - 16)
+		case 6: // duplicate a range of bytes
+			i := f.rand.Intn(len(data))
+			j := i + 1 + f.rand.Intn(len(data)-i)
+			k := f.rand.Intn(len(data) + 1)
+			chunk := append([]byte(nil), data[i:j]...)
+			data = append(data[:k], append(chunk, data[k:]...)...)
+		case 7: // insert a range of bytes from another input
+			other := f.corpus[f.rand.Intn(len(f.corpus))]
+			if len(other) == 0 {
+				continue
+			}
+			i := f.rand.Intn(len(other))
+			j := i + 1 + f.rand.Intn(len(other)-i)
+			k := f.rand.Intn(len(data) + 1)
+			data = append(data[:k], append(append([]byte(nil), other[i:j]...), data[k:]...)...)
+		}
+	}
+	if len(data) > fuzzMaxLen {
+		data = data[:fuzzMaxLen]
+	}
+	return data
+}
+
+// saveFailure writes the current input to the corpus directory, so that it is
+// tried first the next time.
+func (f *fuzzer) saveFailure() error {
+	data := f.current
+	f.current = nil
+	sum := sha256.Sum256(data)
+	path := filepath.Join(f.dir, hex.EncodeToString(sum[:8]))
+	if err := os.MkdirAll(f.dir, 0o777); err != nil {
+		return err
+	}
+	if err := os.WriteFile(path, marshalCorpusFile(data, f.isString), 0o666); err != nil {
+		return err
+	}
+	fmt.Fprintf(f.out, "\n    Failing input written to %s\n", path)
+	return nil
+}
+
+func (f *fuzzer) printStatus(now time.Time) {
+	f.lastStatus = now
+	elapsed := now.Sub(f.start)
+	fmt.Fprintf(f.out, "fuzz: elapsed: %s, execs: %d (%.0f/sec), new interesting: %d (total: %d)\n",
+		elapsed.Round(time.Second), f.execs, float64(f.execs)/elapsed.Seconds(), f.interesting, len(f.corpus))
+}
+
+// marshalCorpusFile returns the contents of a corpus file with the given input.
+func marshalCorpusFile(data []byte, isString bool) []byte {
+	typ := "[]byte"
+	if isString {
+		typ = "string"
+	}
+	return []byte(fmt.Sprintf("%s\n%s(%q)\n", fuzzCorpusHeader, typ, data))
+}
+
+// parseCorpusFile returns the input stored in a corpus file. Only files with a
+// single []byte or string value are supported.
+func parseCorpusFile(file []byte) ([]byte, error) {
+	lines := strings.Split(strings.TrimSpace(string(file)), "\n")
+	if len(lines) == 0 || lines[0] != fuzzCorpusHeader {
+		return nil, errors.New("not a fuzz corpus file")
+	}
+	if len(lines) != 2 {
+		return nil, fmt.Errorf("expected 1 value, got %d", len(lines)-1)
+	}
+	value := strings.TrimSpace(lines[1])
+	for _, prefix := range []string{"[]byte(", "string("} {
+		if strings.HasPrefix(value, prefix) && strings.HasSuffix(value, ")") {
+			s, err := strconv.Unquote(value[len(prefix) : len(value)-1])
+			return []byte(s), err
+		}
+	}
+	return nil, fmt.Errorf("unsupported value %s", value)
+}
@@ -0,0 +1,57 @@
+package wasmhost
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestFuzzCorpusFile(t *testing.T) {
+	for _, data := range [][]byte{nil, []byte("hello"), {0, 0xff, '"', '\n'}} {
+		for _, isString := range []bool{false, true} {
+			parsed, err := parseCorpusFile(marshalCorpusFile(data, isString))
+			if err != nil {
+				t.Errorf("could not parse corpus file for %q: %v", data, err)
+			} else if !bytes.Equal(parsed, data) {
+				t.Errorf("expected %q, got %q", data, parsed)
+			}
+		}
+	}
+	if _, err := parseCorpusFile([]byte("go test fuzz v1\nint(5)\n")); err == nil {
+		t.Error("expected an error for an unsupported value")
+	}
+}
+
+func TestFuzzSaveFailure(t *testing.T) {
+	f := newFuzzer("FuzzTest", true, 0, 0, io.Discard)
+	f.dir = t.TempDir()
+	f.current = []byte("crash")
+	if err := f.saveFailure(); err != nil {
+		t.Fatal(err)
+	}
+
+	// The input must be tried first when fuzzing again.
+	entries, err := os.ReadDir(f.dir)
+	if err != nil || len(entries) != 1 {
+		t.Fatalf("expected a single corpus file, got %v (%v)", entries, err)
+	}
+	data, err := os.ReadFile(filepath.Join(f.dir, entries[0].Name()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != "go test fuzz v1\nstring(\"crash\")\n" {
+		t.Errorf("unexpected corpus file: %q", data)
+	}
+}
+
+func TestFuzzMutate(t *testing.T) {
+	f := newFuzzer("FuzzTest", false, 0, 0, io.Discard)
+	f.addSeed([]byte("seed"))
+	for i := 0; i < 10000; i++ {
+		if data := f.mutate(); len(data) > fuzzMaxLen {
+			t.Fatalf("input of %d bytes is too long", len(data))
+		}
+	}
+}
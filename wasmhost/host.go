@@ -0,0 +1,286 @@
+package wasmhost
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/tetratelabs/wazero"
+	"github.com/tetratelabs/wazero/api"
+)
+
+// Name of the module with the host functions, when they're re-exported from
+// the "env" module together with the memory.
+const hostModuleName = "tinygo:host"
+
+const (
+	i32 = api.ValueTypeI32
+	i64 = api.ValueTypeI64
+)
+
+// hostFunction is a single function exported from the "env" module.
+type hostFunction struct {
+	name    string
+	params  []api.ValueType
+	results []api.ValueType
+	fn      api.GoModuleFunc
+}
+
+// host keeps the state of the host functions of a single module.
+type host struct {
+	log    io.Writer           // destination of ext_logging_log
+	args   []string            // returned by tinygo_args_get, including the program name
+	env    []string            // returned by tinygo_environ_get
+	stdin  io.Reader           // read by tinygo_stdin_read, may be nil
+	print  io.Writer           // destination of ext_misc_print_* and tinygo_print_utf8
+	start  time.Time           // start of the current benchmark
+	begin  time.Time           // creation of the host, for tinygo_instruction_count
+	fuzz   *fuzzer             // fuzzing engine, after tinygo_fuzz_start
+	next   uint32              // next free address for the allocator
+	sizes  map[uint32]uint32   // size of every allocated pointer
+	unused map[uint32][]uint32 // freed pointers, by size
+
+	storage map[childStorageKey][]byte // ext_default_child_storage_*
+	extra   []HostFunction             // functions from Config.Functions
+}
+
+func newHost(log, print io.Writer) *host {
+	if log == nil {
+		log = io.Discard
+	}
+	if print == nil {
+		print = io.Discard
+	}
+	return &host{
+		log:    log,
+		print:  print,
+		begin:  time.Now(),
+		sizes:  make(map[uint32]uint32),
+		unused: make(map[uint32][]uint32),
+
+		storage: make(map[childStorageKey][]byte),
+	}
+}
+
+// functions returns all functions provided by the host.
+func (h *host) functions() []hostFunction {
+	malloc := func(ctx context.Context, mod api.Module, stack []uint64) {
+		stack[0] = uint64(h.malloc(mod.Memory(), uint32(stack[0])))
+	}
+	free := func(ctx context.Context, mod api.Module, stack []uint64) {
+		h.free(uint32(stack[0]))
+	}
+	return append([]hostFunction{
+		{"ext_allocator_malloc_version_1", []api.ValueType{i32}, []api.ValueType{i32}, malloc},
+		{"ext_allocator_free_version_1", []api.ValueType{i32}, nil, free},
+		{"extalloc", []api.ValueType{i32}, []api.ValueType{i32}, malloc},
+		{"extfree", []api.ValueType{i32}, nil, free},
+		{"ext_logging_log_version_1", []api.ValueType{i32, i64, i64}, nil, func(ctx context.Context, mod api.Module, stack []uint64) {
+			target := readPointerSize(mod.Memory(), stack[1])
+			message := readPointerSize(mod.Memory(), stack[2])
+			fmt.Fprintf(h.log, "%s %s: %s\n", logLevelName(uint32(stack[0])), target, message)
+		}},
+		{"ext_logging_max_level_version_1", nil, []api.ValueType{i32}, func(ctx context.Context, mod api.Module, stack []uint64) {
+			stack[0] = 5 // trace: log everything
+		}},
+		{"tinygo_benchmark_start", nil, nil, func(ctx context.Context, mod api.Module, stack []uint64) {
+			h.start = time.Now()
+		}},
+		{"tinygo_benchmark_stop", nil, []api.ValueType{i64}, func(ctx context.Context, mod api.Module, stack []uint64) {
+			stack[0] = uint64(time.Since(h.start))
+		}},
+		{"tinygo_fuzz_start", []api.ValueType{i64, i32, i64, i64}, nil, func(ctx context.Context, mod api.Module, stack []uint64) {
+			name := string(readPointerSize(mod.Memory(), stack[0]))
+			h.fuzz = newFuzzer(name, stack[1] != 0, stack[2], time.Duration(stack[3]), h.print)
+		}},
+		{"tinygo_fuzz_seed", []api.ValueType{i64}, nil, func(ctx context.Context, mod api.Module, stack []uint64) {
+			h.fuzz.addSeed(readPointerSize(mod.Memory(), stack[0]))
+		}},
+		{"tinygo_fuzz_next", []api.ValueType{i32}, []api.ValueType{i32}, func(ctx context.Context, mod api.Module, stack []uint64) {
+			stack[0] = api.EncodeI32(h.fuzz.next(mod.Memory(), stack[0] != 0))
+		}},
+		{"tinygo_fuzz_input", []api.ValueType{i32}, nil, func(ctx context.Context, mod api.Module, stack []uint64) {
+			if !mod.Memory().Write(uint32(stack[0]), h.fuzz.current) {
+				panic("tinygo_fuzz_input: out of bounds buffer")
+			}
+		}},
+		{"tinygo_args_get", []api.ValueType{i64}, []api.ValueType{i32}, func(ctx context.Context, mod api.Module, stack []uint64) {
+			stack[0] = uint64(writeNulSeparated(mod.Memory(), stack[0], h.args))
+		}},
+		{"tinygo_environ_get", []api.ValueType{i64}, []api.ValueType{i32}, func(ctx context.Context, mod api.Module, stack []uint64) {
+			stack[0] = uint64(writeNulSeparated(mod.Memory(), stack[0], h.env))
+		}},
+		{"tinygo_stdin_read", []api.ValueType{i64}, []api.ValueType{i32}, func(ctx context.Context, mod api.Module, stack []uint64) {
+			stack[0] = uint64(h.readStdin(mod.Memory(), stack[0]))
+		}},
+		{"tinygo_instruction_count", nil, []api.ValueType{i64}, func(ctx context.Context, mod api.Module, stack []uint64) {
+			// wazero can't count instructions, so pretend that every
+			// nanosecond is an instruction. This is still monotonic.
+			stack[0] = uint64(time.Since(h.begin))
+		}},
+		{"ext_offchain_timestamp_version_1", nil, []api.ValueType{i64}, func(ctx context.Context, mod api.Module, stack []uint64) {
+			stack[0] = uint64(time.Now().UnixMilli())
+		}},
+		{"ext_misc_print_utf8_version_1", []api.ValueType{i64}, nil, func(ctx context.Context, mod api.Module, stack []uint64) {
+			fmt.Fprintf(h.print, "%s\n", readPointerSize(mod.Memory(), stack[0]))
+		}},
+		{"tinygo_print_utf8", []api.ValueType{i64}, nil, func(ctx context.Context, mod api.Module, stack []uint64) {
+			// Like ext_misc_print_utf8, but without ending the line.
+			h.print.Write(readPointerSize(mod.Memory(), stack[0]))
+		}},
+		{"ext_misc_print_num_version_1", []api.ValueType{i64}, nil, func(ctx context.Context, mod api.Module, stack []uint64) {
+			fmt.Fprintf(h.print, "%d\n", stack[0])
+		}},
+		{"ext_misc_print_hex_version_1", []api.ValueType{i64}, nil, func(ctx context.Context, mod api.Module, stack []uint64) {
+			fmt.Fprintf(h.print, "%x\n", readPointerSize(mod.Memory(), stack[0]))
+		}},
+	}, h.storageFunctions()...)
+}
+
+// allFunctions returns the functions provided by the host, followed by the
+// functions from Config.Functions.
+func (h *host) allFunctions() []hostFunction {
+	functions := h.functions()
+	for _, f := range h.extra {
+		functions = append(functions, hostFunction{f.Name, f.Params, f.Results, f.Func})
+	}
+	return functions
+}
+
+// memoryImport describes the memory imported by a module from "env".
+type memoryImport struct {
+	name   string
+	min    uint32
+	max    uint32
+	hasMax bool
+}
+
+// instantiate instantiates the "env" module. If the module imports its memory
+// from "env", a module is created that defines the memory and re-exports the
+// host functions, as host modules can't export memory.
+func (h *host) instantiate(ctx context.Context, r wazero.Runtime, memory *memoryImport) error {
+	name := "env"
+	if memory != nil {
+		name = hostModuleName
+	}
+	builder := r.NewHostModuleBuilder(name)
+	functions := h.allFunctions()
+	for _, f := range functions {
+		builder.NewFunctionBuilder().WithGoModuleFunction(f.fn, f.params, f.results).Export(f.name)
+	}
+	if _, err := builder.Instantiate(ctx); err != nil {
+		return err
+	}
+	if memory == nil {
+		return nil
+	}
+	_, err := r.InstantiateWithConfig(ctx, envModule(functions, memory), wazero.NewModuleConfig().WithName("env"))
+	return err
+}
+
+// malloc allocates size bytes in the given memory, growing it as needed. It
+// returns 0 if the memory can't grow any further. The memory that existed
+// before the first allocation is left alone, as it is used by the module
+// itself.
+func (h *host) malloc(mem api.Memory, size uint32) uint32 {
+	size = (size + 7) &^ 7
+	if size == 0 {
+		size = 8
+	}
+	if list := h.unused[size]; len(list) != 0 {
+		ptr := list[len(list)-1]
+		h.unused[size] = list[:len(list)-1]
+		h.sizes[ptr] = size
+		return ptr
+	}
+	if h.next == 0 {
+		h.next = mem.Size()
+	}
+	end := uint64(h.next) + uint64(size)
+	if end > uint64(mem.Size()) {
+		pages := (end - uint64(mem.Size()) + 0xffff) / 0x10000
+		if _, ok := mem.Grow(uint32(pages)); !ok {
+			return 0
+		}
+	}
+	ptr := h.next
+	h.next = uint32(end)
+	h.sizes[ptr] = size
+	return ptr
+}
+
+// free frees memory allocated by malloc, so that it can be reused for
+// allocations of the same size.
+func (h *host) free(ptr uint32) {
+	if ptr == 0 {
+		return
+	}
+	size, ok := h.sizes[ptr]
+	if !ok {
+		panic(fmt.Sprintf("free: invalid pointer 0x%x", ptr))
+	}
+	delete(h.sizes, ptr)
+	h.unused[size] = append(h.unused[size], ptr)
+}
+
+// readPointerSize reads the data described by a Polkadot pointer-size value:
+// the pointer in the low 32 bits and the length in the high 32 bits.
+func readPointerSize(mem api.Memory, ptrSize uint64) []byte {
+	data, ok := mem.Read(uint32(ptrSize), uint32(ptrSize>>32))
+	if !ok {
+		panic(fmt.Sprintf("out of bounds pointer-size 0x%x", ptrSize))
+	}
+	return data
+}
+
+// writeNulSeparated writes the list separated by NUL bytes to the buffer
+// described by a pointer-size value, if the buffer is large enough. It returns
+// the size of the list, so that the module can allocate a large enough buffer.
+func writeNulSeparated(mem api.Memory, ptrSize uint64, list []string) uint32 {
+	data := strings.Join(list, "\x00")
+	if uint64(len(data)) <= ptrSize>>32 {
+		if !mem.WriteString(uint32(ptrSize), data) {
+			panic(fmt.Sprintf("out of bounds pointer-size 0x%x", ptrSize))
+		}
+	}
+	return uint32(len(data))
+}
+
+// readStdin reads from the standard input into the buffer described by a
+// pointer-size value. It returns the number of bytes read, or -1 at the end of
+// the input.
+func (h *host) readStdin(mem api.Memory, ptrSize uint64) int32 {
+	if h.stdin == nil {
+		return -1
+	}
+	buf, ok := mem.Read(uint32(ptrSize), uint32(ptrSize>>32))
+	if !ok {
+		panic(fmt.Sprintf("out of bounds pointer-size 0x%x", ptrSize))
+	}
+	for {
+		n, err := h.stdin.Read(buf)
+		if n > 0 || len(buf) == 0 {
+			return int32(n)
+		}
+		if err != nil {
+			return -1
+		}
+	}
+}
+
+func logLevelName(level uint32) string {
+	switch level {
+	case 1:
+		return "ERROR"
+	case 2:
+		return "WARN"
+	case 3:
+		return "INFO"
+	case 4:
+		return "DEBUG"
+	default:
+		return "TRACE"
+	}
+}
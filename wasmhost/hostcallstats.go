@@ -0,0 +1,71 @@
+package wasmhost
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"io"
+
+	"github.com/tetratelabs/wazero/api"
+)
+
+// HostCallStat is the number of calls to an imported function and the number
+// of bytes passed in these calls, as counted by a module built with
+// -host-call-stats. An i64 parameter or result is counted as a Polkadot
+// pointer-size, by the length in its high 32 bits.
+type HostCallStat struct {
+	Import string // as module.name
+	Calls  uint64
+	Bytes  uint64
+}
+
+// Size of a single entry returned by tinygo_host_call_stats.
+const hostCallStatSize = 24
+
+// readHostCallStats calls the tinygo_host_call_stats function exported by the
+// runtime. It returns nil if the module doesn't export it. Imports that weren't
+// called are left out.
+func readHostCallStats(ctx context.Context, mod api.Module) ([]HostCallStat, error) {
+	fn := mod.ExportedFunction("tinygo_host_call_stats")
+	if fn == nil {
+		return nil, nil
+	}
+	results, err := fn.Call(ctx)
+	if err != nil {
+		return nil, err
+	}
+	data := readPointerSize(mod.Memory(), results[0])
+	if len(data)%hostCallStatSize != 0 {
+		return nil, fmt.Errorf("tinygo_host_call_stats: unexpected size %d", len(data))
+	}
+	stats := []HostCallStat{}
+	for ; len(data) != 0; data = data[hostCallStatSize:] {
+		calls := binary.LittleEndian.Uint64(data[8:])
+		if calls == 0 {
+			continue
+		}
+		name, ok := mod.Memory().Read(binary.LittleEndian.Uint32(data[0:]), binary.LittleEndian.Uint32(data[4:]))
+		if !ok {
+			return nil, fmt.Errorf("tinygo_host_call_stats: import name out of bounds")
+		}
+		stats = append(stats, HostCallStat{
+			Import: string(name),
+			Calls:  calls,
+			Bytes:  binary.LittleEndian.Uint64(data[16:]),
+		})
+	}
+	return stats, nil
+}
+
+// WriteHostCallStats writes the host call statistics as a table, followed by
+// the total.
+func WriteHostCallStats(w io.Writer, stats []HostCallStat) {
+	var total HostCallStat
+	fmt.Fprintf(w, "%10s %12s  %s\n", "calls", "bytes", "import")
+	for _, stat := range stats {
+		fmt.Fprintf(w, "%10d %12d  %s\n", stat.Calls, stat.Bytes, stat.Import)
+		total.Calls += stat.Calls
+		total.Bytes += stat.Bytes
+	}
+	fmt.Fprintf(w, "%10d %12d  (total of %d imports called)\n", total.Calls, total.Bytes, len(stats))
+}
@@ -0,0 +1,67 @@
+package wasmhost
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"io"
+
+	"github.com/tetratelabs/wazero/api"
+)
+
+// InitCost is the cost of a package initializer that runs every time the
+// module is instantiated, as measured by a module built with -report-init.
+type InitCost struct {
+	Package     string
+	Nanoseconds uint64
+	Mallocs     uint64
+	AllocBytes  uint64
+}
+
+// Size of a single entry returned by tinygo_init_report.
+const initCostSize = 32
+
+// readInitReport calls the tinygo_init_report function exported by the
+// runtime. It returns nil if the module doesn't export it.
+func readInitReport(ctx context.Context, mod api.Module) ([]InitCost, error) {
+	fn := mod.ExportedFunction("tinygo_init_report")
+	if fn == nil {
+		return nil, nil
+	}
+	results, err := fn.Call(ctx)
+	if err != nil {
+		return nil, err
+	}
+	data := readPointerSize(mod.Memory(), results[0])
+	if len(data)%initCostSize != 0 {
+		return nil, fmt.Errorf("tinygo_init_report: unexpected size %d", len(data))
+	}
+	costs := []InitCost{}
+	for ; len(data) != 0; data = data[initCostSize:] {
+		name, ok := mod.Memory().Read(binary.LittleEndian.Uint32(data[0:]), binary.LittleEndian.Uint32(data[4:]))
+		if !ok {
+			return nil, fmt.Errorf("tinygo_init_report: package name out of bounds")
+		}
+		costs = append(costs, InitCost{
+			Package:     string(name),
+			Nanoseconds: binary.LittleEndian.Uint64(data[8:]),
+			Mallocs:     binary.LittleEndian.Uint64(data[16:]),
+			AllocBytes:  binary.LittleEndian.Uint64(data[24:]),
+		})
+	}
+	return costs, nil
+}
+
+// WriteInitReport writes the costs of the package initializers as a table, in
+// the order in which the packages were initialized, followed by the total.
+func WriteInitReport(w io.Writer, costs []InitCost) {
+	var total InitCost
+	fmt.Fprintf(w, "%12s %8s %10s  %s\n", "ns", "allocs", "bytes", "package initializer")
+	for _, cost := range costs {
+		fmt.Fprintf(w, "%12d %8d %10d  %s\n", cost.Nanoseconds, cost.Mallocs, cost.AllocBytes, cost.Package)
+		total.Nanoseconds += cost.Nanoseconds
+		total.Mallocs += cost.Mallocs
+		total.AllocBytes += cost.AllocBytes
+	}
+	fmt.Fprintf(w, "%12d %8d %10d  (total of %d packages initialized at runtime)\n", total.Nanoseconds, total.Mallocs, total.AllocBytes, len(costs))
+}
@@ -0,0 +1,66 @@
+package wasmhost
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+
+	"github.com/tetratelabs/wazero/api"
+)
+
+// MemStats contains the memory statistics of a module built with -gc-diff,
+// as reported by runtime.ReadMemStats when the module returned from main.
+type MemStats struct {
+	Valid bool // whether the module exported its statistics
+
+	Sys          uint64
+	HeapSys      uint64
+	HeapIdle     uint64
+	HeapInuse    uint64
+	HeapReleased uint64
+	TotalAlloc   uint64
+	Mallocs      uint64
+	Frees        uint64
+	GCSys        uint64
+}
+
+// readMemStats calls the tinygo_memstats function exported by the runtime. It
+// returns MemStats with Valid unset if the module doesn't export it.
+func readMemStats(ctx context.Context, mod api.Module) (MemStats, error) {
+	fn := mod.ExportedFunction("tinygo_memstats")
+	if fn == nil {
+		return MemStats{}, nil
+	}
+	results, err := fn.Call(ctx)
+	if err != nil {
+		return MemStats{}, err
+	}
+	data := readPointerSize(mod.Memory(), results[0])
+	var m MemStats
+	values := []*uint64{&m.Sys, &m.HeapSys, &m.HeapIdle, &m.HeapInuse, &m.HeapReleased, &m.TotalAlloc, &m.Mallocs, &m.Frees, &m.GCSys}
+	if len(data) != len(values)*8 {
+		return MemStats{}, fmt.Errorf("tinygo_memstats: expected %d bytes, got %d", len(values)*8, len(data))
+	}
+	for i, value := range values {
+		*value = binary.LittleEndian.Uint64(data[i*8:])
+	}
+	m.Valid = true
+	return m, nil
+}
+
+// Check checks the invariants that must hold for the statistics of every GC.
+func (m *MemStats) Check() error {
+	switch {
+	case m.Frees > m.Mallocs:
+		return fmt.Errorf("more frees (%d) than mallocs (%d)", m.Frees, m.Mallocs)
+	case m.HeapInuse > m.HeapSys:
+		return fmt.Errorf("HeapInuse (%d) is larger than HeapSys (%d)", m.HeapInuse, m.HeapSys)
+	case m.HeapInuse+m.HeapIdle != m.HeapSys:
+		return fmt.Errorf("HeapInuse (%d) + HeapIdle (%d) is not equal to HeapSys (%d)", m.HeapInuse, m.HeapIdle, m.HeapSys)
+	case m.HeapReleased > m.HeapIdle:
+		return fmt.Errorf("HeapReleased (%d) is larger than HeapIdle (%d)", m.HeapReleased, m.HeapIdle)
+	case m.HeapSys > m.Sys:
+		return fmt.Errorf("HeapSys (%d) is larger than Sys (%d)", m.HeapSys, m.Sys)
+	}
+	return nil
+}
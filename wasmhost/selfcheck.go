@@ -0,0 +1,72 @@
+package wasmhost
+
+import (
+	"context"
+	"strings"
+
+	"github.com/tetratelabs/wazero/api"
+)
+
+// SelfcheckFailure is a bitmask of the runtime invariants that don't hold, as
+// returned by the __runtime_selfcheck export of a module built with
+// -selfcheck. The values match the selfcheck* constants in the runtime.
+type SelfcheckFailure uint32
+
+const (
+	SelfcheckHeapOrder   SelfcheckFailure = 1 << iota // heap objects are not sorted or overlap
+	SelfcheckHeapBounds                               // heap object or heap metadata outside of the heap
+	SelfcheckHeapState                                // mark bits left outside of a GC cycle, or an invalid object
+	SelfcheckHeapStats                                // allocation statistics don't match the heap
+	SelfcheckDebugBuffer                              // buffer of the debug output is invalid
+	SelfcheckStack                                    // stack pointer outside of the stack, or a dirty -redzone
+)
+
+var selfcheckNames = []string{
+	"heap order",
+	"heap bounds",
+	"heap state",
+	"heap stats",
+	"debug buffer",
+	"stack",
+}
+
+// String returns the failed invariants as a comma separated list.
+func (f SelfcheckFailure) String() string {
+	if f == 0 {
+		return "ok"
+	}
+	var names []string
+	for i, name := range selfcheckNames {
+		if f&(1<<i) != 0 {
+			names = append(names, name)
+		}
+	}
+	if f>>len(selfcheckNames) != 0 {
+		names = append(names, "unknown")
+	}
+	return strings.Join(names, ", ")
+}
+
+// SelfcheckError is returned by Run when a module built with -selfcheck ran
+// successfully, but its runtime state is corrupted afterwards.
+type SelfcheckError struct {
+	Failed SelfcheckFailure
+}
+
+func (e *SelfcheckError) Error() string {
+	return "runtime self-check failed: " + e.Failed.String()
+}
+
+// runSelfcheck calls the __runtime_selfcheck function exported by the runtime.
+// It returns 0 if the module doesn't export it.
+func runSelfcheck(ctx context.Context, mod api.Module) (SelfcheckFailure, error) {
+	fn := mod.ExportedFunction("__runtime_selfcheck")
+	if fn == nil {
+		return 0, nil
+	}
+	results, err := fn.Call(ctx)
+	if err != nil {
+		return 0, err
+	}
+	return SelfcheckFailure(results[0]), nil
+}
@@ -0,0 +1,69 @@
+package wasmhost
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/tetratelabs/wazero/api"
+)
+
+// The child storage of a Polkadot host (ext_default_child_storage_*), as used
+// by modules built with the storagefs build tag. It is kept in memory for as
+// long as the module runs.
+
+// childStorageKey is a key in a child storage.
+type childStorageKey struct {
+	storageKey string
+	key        string
+}
+
+// storageFunctions returns the child storage host functions.
+func (h *host) storageFunctions() []hostFunction {
+	return []hostFunction{
+		{"ext_default_child_storage_get_version_1", []api.ValueType{i64, i64}, []api.ValueType{i64}, func(ctx context.Context, mod api.Module, stack []uint64) {
+			value, ok := h.storage[h.storageKey(mod.Memory(), stack[0], stack[1])]
+			// The result is a SCALE encoded Option<Vec<u8>>.
+			encoded := []byte{0}
+			if ok {
+				encoded = appendCompact([]byte{1}, uint32(len(value)))
+				encoded = append(encoded, value...)
+			}
+			ptr := h.malloc(mod.Memory(), uint32(len(encoded)))
+			if ptr == 0 || !mod.Memory().Write(ptr, encoded) {
+				panic("ext_default_child_storage_get_version_1: out of memory")
+			}
+			stack[0] = uint64(ptr) | uint64(len(encoded))<<32
+		}},
+		{"ext_default_child_storage_set_version_1", []api.ValueType{i64, i64, i64}, nil, func(ctx context.Context, mod api.Module, stack []uint64) {
+			value := readPointerSize(mod.Memory(), stack[2])
+			h.storage[h.storageKey(mod.Memory(), stack[0], stack[1])] = append([]byte(nil), value...)
+		}},
+		{"ext_default_child_storage_clear_version_1", []api.ValueType{i64, i64}, nil, func(ctx context.Context, mod api.Module, stack []uint64) {
+			delete(h.storage, h.storageKey(mod.Memory(), stack[0], stack[1]))
+		}},
+	}
+}
+
+// storageKey reads the child storage key and the key from memory.
+func (h *host) storageKey(mem api.Memory, storageKey, key uint64) childStorageKey {
+	return childStorageKey{
+		storageKey: string(readPointerSize(mem, storageKey)),
+		key:        string(readPointerSize(mem, key)),
+	}
+}
+
+// appendCompact appends the SCALE compact encoding of value to buf.
+func appendCompact(buf []byte, value uint32) []byte {
+	switch {
+	case value < 1<<6:
+		return append(buf, byte(value<<2))
+	case value < 1<<14:
+		v := value<<2 | 1
+		return append(buf, byte(v), byte(v>>8))
+	case value < 1<<30:
+		v := value<<2 | 2
+		return append(buf, byte(v), byte(v>>8), byte(v>>16), byte(v>>24))
+	default:
+		panic(fmt.Sprintf("value %d too large for compact encoding", value))
+	}
+}
@@ -0,0 +1,108 @@
+package wasmhost
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"strings"
+
+	"github.com/tetratelabs/wazero/api"
+)
+
+// TrapReason is the reason why a module built by TinyGo trapped, as recorded
+// by the runtime. The values match the trap* constants in the runtime.
+type TrapReason uint32
+
+const (
+	TrapUnreachable   TrapReason = iota // no reason recorded
+	TrapPanic                           // explicit call to panic
+	TrapRuntimeError                    // other runtime error
+	TrapNilPointer                      // nil pointer dereference
+	TrapOutOfRange                      // index or slice expression out of range
+	TrapOutOfMemory                     // allocation failed
+	TrapStackOverflow                   // goroutine stack overflow, or system stack overflow into the -redzone
+	TrapCallBudget                      // over the budget set with runtime.SetCallBudget
+	TrapReentry                         // exported function called while another one is running
+)
+
+func (r TrapReason) String() string {
+	switch r {
+	case TrapUnreachable:
+		return "unreachable"
+	case TrapPanic:
+		return "panic"
+	case TrapRuntimeError:
+		return "runtime error"
+	case TrapNilPointer:
+		return "nil pointer dereference"
+	case TrapOutOfRange:
+		return "out of range"
+	case TrapOutOfMemory:
+		return "out of memory"
+	case TrapStackOverflow:
+		return "stack overflow"
+	case TrapCallBudget:
+		return "call budget exceeded"
+	case TrapReentry:
+		return "re-entrant call"
+	default:
+		return fmt.Sprintf("trap reason %d", uint32(r))
+	}
+}
+
+// TrapError is returned by Run when a module trapped after recording the reason
+// in the location returned by its tinygo_trap_info export.
+type TrapError struct {
+	Reason    TrapReason
+	Aux       uint32     // size of the failed allocation for TrapOutOfMemory and TrapCallBudget
+	Message   string     // panic or runtime error message, if any
+	CrashDump *CrashDump // state of the module when it trapped, with -crash-dump
+	Err       error      // the trap as reported by wazero
+}
+
+func (e *TrapError) Error() string {
+	msg := e.Reason.String()
+	if e.Reason == TrapOutOfMemory {
+		msg += fmt.Sprintf(" (allocating %d bytes)", e.Aux)
+	} else if strings.HasPrefix(e.Message, msg) {
+		msg = e.Message // already includes the reason
+	} else if e.Message != "" {
+		msg += ": " + e.Message
+	}
+	return msg + ": " + e.Err.Error()
+}
+
+func (e *TrapError) Unwrap() error {
+	return e.Err
+}
+
+// readTrapInfo reads the reason of a trap from a module that exports
+// tinygo_trap_info. It returns nil if the module doesn't export it or didn't
+// record a reason.
+func readTrapInfo(ctx context.Context, mod api.Module, err error) *TrapError {
+	fn := mod.ExportedFunction("tinygo_trap_info")
+	if fn == nil {
+		return nil
+	}
+	results, callErr := fn.Call(ctx)
+	if callErr != nil || len(results) != 1 {
+		return nil
+	}
+	data, ok := mod.Memory().Read(uint32(results[0]), 16)
+	if !ok {
+		return nil
+	}
+	trapErr := &TrapError{
+		Reason: TrapReason(binary.LittleEndian.Uint32(data[0:])),
+		Aux:    binary.LittleEndian.Uint32(data[4:]),
+		Err:    err,
+	}
+	if trapErr.Reason == TrapUnreachable {
+		return nil
+	}
+	if message, ok := mod.Memory().Read(binary.LittleEndian.Uint32(data[8:]), binary.LittleEndian.Uint32(data[12:])); ok {
+		trapErr.Message = string(message)
+	}
+	trapErr.CrashDump = readCrashDump(ctx, mod)
+	return trapErr
+}
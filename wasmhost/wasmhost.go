@@ -0,0 +1,253 @@
+// Package wasmhost implements a WebAssembly host on top of wazero, so that
+// WebAssembly programs can be run without installing a separate runtime. This
+// is used for the "wazero" emulator.
+//
+// Next to WASI, the host implements the allocator, logging and child storage
+// functions of a Polkadot host (ext_allocator_*, ext_logging_*,
+// ext_misc_print_*, ext_default_child_storage_*), the allocator used by
+// -gc=extalloc, the clock used by the runtime/benchmark package and the fuzzing
+// engine used by tinygo test -fuzz, all imported from the "env" module.
+// Modules built for wasm-unknown with -host-args read their command line
+// arguments and environment variables from "env" as well. Modules built with
+// -host-ticks read the time from its instruction counter, and modules built
+// with -host-input read their standard input from it. Test binaries print the
+// parts of lines that are too long for their line buffer with
+// tinygo_print_utf8, which doesn't end the line.
+package wasmhost
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/tetratelabs/wazero"
+	"github.com/tetratelabs/wazero/api"
+	"github.com/tetratelabs/wazero/imports/wasi_snapshot_preview1"
+	"github.com/tetratelabs/wazero/sys"
+)
+
+// Config configures how a WebAssembly module is run.
+type Config struct {
+	Args     []string  // command line arguments, not including the program name
+	Env      []string  // environment variables in the form key=value
+	Dirs     []string  // directories to make available, in the form dir or hostdir::guestdir
+	Stdin    io.Reader // defaults to no input
+	Stdout   io.Writer // defaults to discarding output
+	Stderr   io.Writer // defaults to discarding output
+	CoverDir string    // directory to write coverage data to, for modules built with -cover
+	MemStats *MemStats // if set, filled in for modules built with -gc-diff
+
+	// If set, filled in for modules built with -report-init. It is left nil
+	// for other modules.
+	InitReport *[]InitCost
+
+	// If set, filled in for modules built with -host-call-stats. It is left
+	// nil for other modules.
+	HostCallStats *[]HostCallStat
+
+	// Functions are added to the "env" module next to the functions of the
+	// host, for imports that the host doesn't implement itself.
+	Functions []HostFunction
+}
+
+// HostFunction is a function in the "env" module that is provided by the user
+// of the package. It is called with the module that imports it, so it can
+// call back into the exported functions of the module.
+type HostFunction struct {
+	Name    string
+	Params  []api.ValueType
+	Results []api.ValueType
+	Func    api.GoModuleFunc
+}
+
+// Run runs the WebAssembly module at the given path and returns its exit code.
+// The module is started by calling _start, or _initialize if there is no
+// _start function. If the module traps after the runtime recorded the reason,
+// the error is a *TrapError. If a module built with -selfcheck finds its
+// runtime state corrupted after running, the error is a *SelfcheckError.
+func Run(ctx context.Context, path string, config Config) (int, error) {
+	runtimeConfig := wazero.NewRuntimeConfig()
+	if config.CoverDir != "" {
+		// The coverage metadata is stored in a custom section.
+		runtimeConfig = runtimeConfig.WithCustomSections(true)
+	}
+	r := wazero.NewRuntimeWithConfig(ctx, runtimeConfig)
+	defer r.Close(ctx)
+
+	h := newHost(config.Stderr, config.Stdout)
+	mod, compiled, err := instantiate(ctx, r, h, path, config)
+	if err != nil {
+		return 0, err
+	}
+	var coverage *coverageDump
+	if config.CoverDir != "" {
+		coverage, err = prepareCoverage(ctx, mod, compiled)
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	// Commands (including test binaries) are started with _start, other
+	// modules are only initialized.
+	err = start(ctx, mod, "_start", "_initialize")
+	if coverage != nil {
+		// Write the coverage data even if the program failed, like go test
+		// does.
+		if coverErr := coverage.write(config.CoverDir); coverErr != nil {
+			return 0, coverErr
+		}
+	}
+	// Modules for wasm-unknown have no way to exit other than trapping, but
+	// they leave the code passed to os.Exit behind. Other traps may come with
+	// a reason recorded by the runtime.
+	exitCode := 0
+	var exitErr *sys.ExitError
+	if err != nil && !errors.As(err, &exitErr) {
+		if code, ok := readExitCode(ctx, mod); ok {
+			exitCode, err = code, nil
+		} else if trapErr := readTrapInfo(ctx, mod, err); trapErr != nil {
+			err = trapErr
+		}
+	}
+	if err == nil {
+		// Modules built with -selfcheck verify their runtime state, which
+		// must still be valid after running.
+		var failed SelfcheckFailure
+		failed, err = runSelfcheck(ctx, mod)
+		if err == nil && failed != 0 {
+			err = &SelfcheckError{Failed: failed}
+		}
+	}
+	if err == nil && config.MemStats != nil {
+		// The statistics can only be read while the module is still open,
+		// so not when it exited through proc_exit.
+		*config.MemStats, err = readMemStats(ctx, mod)
+		if err != nil {
+			return 0, err
+		}
+	}
+	if err == nil && config.InitReport != nil {
+		*config.InitReport, err = readInitReport(ctx, mod)
+		if err != nil {
+			return 0, err
+		}
+	}
+	if err == nil && config.HostCallStats != nil {
+		*config.HostCallStats, err = readHostCallStats(ctx, mod)
+		if err != nil {
+			return 0, err
+		}
+	}
+	if errors.As(err, &exitErr) {
+		return int(exitErr.ExitCode()), nil
+	}
+	if err != nil {
+		if h.fuzz != nil && h.fuzz.current != nil {
+			// The module trapped while running a fuzz input.
+			if saveErr := h.fuzz.saveFailure(); saveErr != nil {
+				return 0, saveErr
+			}
+		}
+		return 0, err
+	}
+	return exitCode, nil
+}
+
+// readExitCode calls the tinygo_exit_code function exported by wasm-unknown
+// modules, and returns the code the module passed to os.Exit. It returns false
+// if the module didn't call os.Exit, for example because it panicked.
+func readExitCode(ctx context.Context, mod api.Module) (int, bool) {
+	fn := mod.ExportedFunction("tinygo_exit_code")
+	if fn == nil {
+		return 0, false
+	}
+	results, err := fn.Call(ctx)
+	if err != nil || len(results) != 1 || int32(results[0]) < 0 {
+		return 0, false
+	}
+	return int(int32(results[0])), true
+}
+
+// instantiate instantiates the WebAssembly module at the given path in r,
+// together with the modules it imports and the host functions in h. No start
+// function is called yet, see start.
+func instantiate(ctx context.Context, r wazero.Runtime, h *host, path string, config Config) (api.Module, wazero.CompiledModule, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	wasi_snapshot_preview1.MustInstantiate(ctx, r)
+
+	compiled, err := r.CompileModule(ctx, data)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// Instantiate the "env" module with the host functions. If the module
+	// imports its memory, the env module must also provide it.
+	var memory *memoryImport
+	for _, def := range compiled.ImportedMemories() {
+		moduleName, name, _ := def.Import()
+		if moduleName != "env" {
+			return nil, nil, fmt.Errorf("unsupported memory import %s.%s", moduleName, name)
+		}
+		max, hasMax := def.Max()
+		memory = &memoryImport{name: name, min: def.Min(), max: max, hasMax: hasMax}
+	}
+	args := append([]string{filepath.Base(path)}, config.Args...)
+	h.args = args
+	h.env = config.Env
+	h.stdin = config.Stdin
+	h.extra = config.Functions
+	if err := h.instantiate(ctx, r, memory); err != nil {
+		return nil, nil, err
+	}
+
+	fsConfig := wazero.NewFSConfig()
+	for _, dir := range config.Dirs {
+		hostDir, guestDir, ok := strings.Cut(dir, "::")
+		if !ok {
+			guestDir = hostDir
+		}
+		fsConfig = fsConfig.WithDirMount(hostDir, guestDir)
+	}
+	moduleConfig := wazero.NewModuleConfig().
+		WithArgs(args...).
+		WithFSConfig(fsConfig).
+		WithSysWalltime().
+		WithSysNanotime().
+		WithSysNanosleep().
+		WithStartFunctions()
+	if config.Stdin != nil {
+		moduleConfig = moduleConfig.WithStdin(config.Stdin)
+	}
+	if config.Stdout != nil {
+		moduleConfig = moduleConfig.WithStdout(config.Stdout)
+	}
+	if config.Stderr != nil {
+		moduleConfig = moduleConfig.WithStderr(config.Stderr)
+	}
+	for _, env := range config.Env {
+		key, value, _ := strings.Cut(env, "=")
+		moduleConfig = moduleConfig.WithEnv(key, value)
+	}
+
+	mod, err := r.InstantiateModule(ctx, compiled, moduleConfig)
+	return mod, compiled, err
+}
+
+// start calls the first of the given functions that the module exports.
+func start(ctx context.Context, mod api.Module, startFunctions ...string) error {
+	for _, name := range startFunctions {
+		if fn := mod.ExportedFunction(name); fn != nil {
+			_, err := fn.Call(ctx)
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,792 @@
+package wasmhost
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/tetratelabs/wazero/api"
+)
+
+// writeModule writes a module with the given type, import and export sections
+// and a single function with the given code to a temporary file.
+func writeModule(t *testing.T, types, imports, exports []byte, functionType byte, code []byte) string {
+	t.Helper()
+	function := []byte{1, 1, 0x7f} // one local of type i32
+	function = append(function, code...)
+	function = append(function, 0x0b) // end
+	var codes []byte
+	codes = appendULEB128(codes, 1)
+	codes = appendULEB128(codes, uint32(len(function)))
+	codes = append(codes, function...)
+
+	module := []byte{0x00, 'a', 's', 'm', 0x01, 0x00, 0x00, 0x00}
+	module = appendSection(module, 1, types)
+	module = appendSection(module, 2, imports)
+	module = appendSection(module, 3, []byte{1, functionType})
+	module = appendSection(module, 7, exports)
+	module = appendSection(module, 10, codes)
+	path := filepath.Join(t.TempDir(), "test.wasm")
+	if err := os.WriteFile(path, module, 0o666); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestImportedMemory(t *testing.T) {
+	// A module that imports its memory, allocates 5 bytes with the Polkadot
+	// allocator, writes "hello" to it and prints it.
+	types := []byte{3,
+		0x60, 1, 0x7f, 1, 0x7f, // (i32) -> i32
+		0x60, 1, 0x7e, 0, // (i64) -> ()
+		0x60, 0, 0, // () -> ()
+	}
+	var imports []byte
+	imports = appendULEB128(imports, 3)
+	imports = appendName(imports, "env")
+	imports = appendName(imports, "memory")
+	imports = append(imports, 0x02, 0x00, 1) // memory with at least 1 page
+	imports = appendName(imports, "env")
+	imports = appendName(imports, "ext_allocator_malloc_version_1")
+	imports = append(imports, 0x00, 0) // function 0
+	imports = appendName(imports, "env")
+	imports = appendName(imports, "ext_misc_print_utf8_version_1")
+	imports = append(imports, 0x00, 1) // function 1
+	var exports []byte
+	exports = appendULEB128(exports, 1)
+	exports = appendName(exports, "_initialize")
+	exports = append(exports, 0x00, 2) // function 2
+
+	code := []byte{
+		0x41, 5, 0x10, 0, 0x21, 0, // local0 = malloc(5)
+	}
+	for i, c := range []byte("hello") {
+		code = append(code, 0x20, 0, 0x41) // local0, i32.const c
+		code = appendSLEB128(code, int64(c))
+		code = append(code, 0x3a, 0, byte(i)) // i32.store8 offset=i
+	}
+	code = append(code,
+		0x20, 0, 0xad, // i64.extend_i32_u(local0)
+		0x42, 5, 0x42, 32, 0x86, // 5 << 32
+		0x84,    // i64.or
+		0x10, 1, // print_utf8
+	)
+	path := writeModule(t, types, imports, exports, 2, code)
+
+	var stdout bytes.Buffer
+	exitCode, err := Run(context.Background(), path, Config{Stdout: &stdout})
+	if err != nil {
+		t.Fatal("could not run module:", err)
+	}
+	if exitCode != 0 {
+		t.Errorf("unexpected exit code %d", exitCode)
+	}
+	if stdout.String() != "hello\n" {
+		t.Errorf("unexpected output: %q", stdout.String())
+	}
+}
+
+func TestPrintContinued(t *testing.T) {
+	// A module that prints "hi" without ending the line, then "hi" and an empty
+	// line.
+	types := []byte{2,
+		0x60, 1, 0x7e, 0, // (i64) -> ()
+		0x60, 0, 0, // () -> ()
+	}
+	var imports []byte
+	imports = appendULEB128(imports, 3)
+	imports = appendName(imports, "env")
+	imports = appendName(imports, "memory")
+	imports = append(imports, 0x02, 0x00, 1) // memory with at least 1 page
+	imports = appendName(imports, "env")
+	imports = appendName(imports, "tinygo_print_utf8")
+	imports = append(imports, 0x00, 0) // function 0
+	imports = appendName(imports, "env")
+	imports = appendName(imports, "ext_misc_print_utf8_version_1")
+	imports = append(imports, 0x00, 0) // function 1
+	var exports []byte
+	exports = appendULEB128(exports, 1)
+	exports = appendName(exports, "_initialize")
+	exports = append(exports, 0x00, 2) // function 2
+
+	var code []byte
+	for i, c := range []byte("hi") {
+		code = append(code, 0x41, 0, 0x41) // i32.const 0, i32.const c
+		code = appendSLEB128(code, int64(c))
+		code = append(code, 0x3a, 0, byte(i)) // i32.store8 offset=i
+	}
+	code = append(code,
+		0x42, 2, 0x42, 32, 0x86, 0x10, 0, // tinygo_print_utf8(2 << 32)
+		0x42, 2, 0x42, 32, 0x86, 0x10, 1, // print_utf8(2 << 32)
+		0x42, 0, 0x10, 1, // print_utf8(0)
+	)
+	path := writeModule(t, types, imports, exports, 1, code)
+
+	var stdout bytes.Buffer
+	if _, err := Run(context.Background(), path, Config{Stdout: &stdout}); err != nil {
+		t.Fatal("could not run module:", err)
+	}
+	if stdout.String() != "hihi\n\n" {
+		t.Errorf("unexpected output: %q", stdout.String())
+	}
+}
+
+func TestExitCode(t *testing.T) {
+	// A WASI command that exits with exit code 3.
+	types := []byte{2,
+		0x60, 1, 0x7f, 0, // (i32) -> ()
+		0x60, 0, 0, // () -> ()
+	}
+	var imports []byte
+	imports = appendULEB128(imports, 1)
+	imports = appendName(imports, "wasi_snapshot_preview1")
+	imports = appendName(imports, "proc_exit")
+	imports = append(imports, 0x00, 0) // function 0
+	var exports []byte
+	exports = appendULEB128(exports, 1)
+	exports = appendName(exports, "_start")
+	exports = append(exports, 0x00, 1) // function 1
+	path := writeModule(t, types, imports, exports, 1, []byte{0x41, 3, 0x10, 0})
+
+	exitCode, err := Run(context.Background(), path, Config{})
+	if err != nil {
+		t.Fatal("could not run module:", err)
+	}
+	if exitCode != 3 {
+		t.Errorf("expected exit code 3, got %d", exitCode)
+	}
+}
+
+func TestTrapExitCode(t *testing.T) {
+	// A wasm-unknown module that traps in _start, and then returns the exit
+	// code from tinygo_exit_code. Both exports are the same function, which
+	// uses the first byte of memory to know whether it was called before. An
+	// exit code of -1 means the module didn't exit but panicked.
+	for _, code := range []int64{3, -1} {
+		types := []byte{1,
+			0x60, 0, 1, 0x7f, // () -> i32
+		}
+		var imports []byte
+		imports = appendULEB128(imports, 1)
+		imports = appendName(imports, "env")
+		imports = appendName(imports, "memory")
+		imports = append(imports, 0x02, 0x00, 1) // memory with at least 1 page
+		var exports []byte
+		exports = appendULEB128(exports, 2)
+		exports = appendName(exports, "_start")
+		exports = append(exports, 0x00, 0) // function 0
+		exports = appendName(exports, "tinygo_exit_code")
+		exports = append(exports, 0x00, 0) // function 0
+		body := []byte{
+			0x41, 0, 0x2d, 0, 0, // i32.load8_u(0)
+			0x45, 0x04, 0x40, // if i32.eqz
+			0x41, 0, 0x41, 1, 0x3a, 0, 0, // i32.store8(0, 1)
+			0x00,       // unreachable
+			0x0b, 0x41, // end, i32.const code
+		}
+		body = appendSLEB128(body, code)
+		path := writeModule(t, types, imports, exports, 0, body)
+
+		exitCode, err := Run(context.Background(), path, Config{})
+		if code < 0 {
+			if err == nil {
+				t.Errorf("expected the trap to be returned, got exit code %d", exitCode)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatal("could not run module:", err)
+		}
+		if exitCode != int(code) {
+			t.Errorf("expected exit code %d, got %d", code, exitCode)
+		}
+	}
+}
+
+func TestTrapInfo(t *testing.T) {
+	// A module that records a panic with the message "boom" before trapping
+	// in _start. Like in TestTrapExitCode, tinygo_trap_info is the same
+	// function, which returns the address of the record (0) once the first
+	// byte at address 100 is set.
+	types := []byte{1,
+		0x60, 0, 1, 0x7f, // () -> i32
+	}
+	var imports []byte
+	imports = appendULEB128(imports, 1)
+	imports = appendName(imports, "env")
+	imports = appendName(imports, "memory")
+	imports = append(imports, 0x02, 0x00, 1) // memory with at least 1 page
+	var exports []byte
+	exports = appendULEB128(exports, 2)
+	exports = appendName(exports, "_start")
+	exports = append(exports, 0x00, 0) // function 0
+	exports = appendName(exports, "tinygo_trap_info")
+	exports = append(exports, 0x00, 0) // function 0
+	code := []byte{
+		0x41, 0, 0x2d, 0, 100, // i32.load8_u offset=100
+		0x45, 0x04, 0x40, // if i32.eqz
+		0x41, 0, 0x41, 1, 0x3a, 0, 100, // i32.store8 offset=100
+		0x41, 0, 0x41, 1, 0x36, 2, 0, // reason: panic
+		0x41, 0, 0x41, 16, 0x36, 2, 8, // message pointer
+		0x41, 0, 0x41, 4, 0x36, 2, 12, // message length
+	}
+	for i, c := range []byte("boom") {
+		code = append(code, 0x41, 0, 0x41) // i32.const 0, i32.const c
+		code = appendSLEB128(code, int64(c))
+		code = append(code, 0x3a, 0, byte(16+i)) // i32.store8 offset=16+i
+	}
+	code = append(code,
+		0x00,          // unreachable
+		0x0b, 0x41, 0, // end, i32.const 0
+	)
+	path := writeModule(t, types, imports, exports, 0, code)
+
+	_, err := Run(context.Background(), path, Config{})
+	var trapErr *TrapError
+	if !errors.As(err, &trapErr) {
+		t.Fatal("expected a trap error, got:", err)
+	}
+	if trapErr.Reason != TrapPanic || trapErr.Message != "boom" {
+		t.Errorf("unexpected trap: %+v", trapErr)
+	}
+	if !strings.HasPrefix(err.Error(), "panic: boom: ") {
+		t.Errorf("unexpected error message: %s", err)
+	}
+}
+
+func TestHostArgs(t *testing.T) {
+	// A module built with -host-args, that reads the command line arguments
+	// into address 16 and prints them.
+	types := []byte{3,
+		0x60, 1, 0x7e, 1, 0x7f, // (i64) -> i32
+		0x60, 1, 0x7e, 0, // (i64) -> ()
+		0x60, 0, 0, // () -> ()
+	}
+	var imports []byte
+	imports = appendULEB128(imports, 3)
+	imports = appendName(imports, "env")
+	imports = appendName(imports, "memory")
+	imports = append(imports, 0x02, 0x00, 1) // memory with at least 1 page
+	imports = appendName(imports, "env")
+	imports = appendName(imports, "tinygo_args_get")
+	imports = append(imports, 0x00, 0) // function 0
+	imports = appendName(imports, "env")
+	imports = appendName(imports, "ext_misc_print_utf8_version_1")
+	imports = append(imports, 0x00, 1) // function 1
+	var exports []byte
+	exports = appendULEB128(exports, 1)
+	exports = appendName(exports, "_initialize")
+	exports = append(exports, 0x00, 2) // function 2
+
+	buffer := []byte{
+		0x42, 16, // i64.const 16
+		0x20, 0, 0xad, // i64.extend_i32_u(local0)
+		0x42, 32, 0x86, // << 32
+		0x84, // i64.or
+	}
+	code := []byte{0x42, 0, 0x10, 0, 0x21, 0} // local0 = args_get(0)
+	code = append(code, buffer...)
+	code = append(code, 0x10, 0, 0x1a) // args_get(buffer), drop the result
+	code = append(code, buffer...)
+	code = append(code, 0x10, 1) // print_utf8(buffer)
+	path := writeModule(t, types, imports, exports, 2, code)
+
+	var stdout bytes.Buffer
+	_, err := Run(context.Background(), path, Config{Args: []string{"-v", "foo"}, Stdout: &stdout})
+	if err != nil {
+		t.Fatal("could not run module:", err)
+	}
+	if stdout.String() != "test.wasm\x00-v\x00foo\n" {
+		t.Errorf("unexpected output: %q", stdout.String())
+	}
+}
+
+func TestHostInput(t *testing.T) {
+	// A module built with -host-input that prints the first read from the
+	// standard input, and traps unless the next read is the end of the input.
+	types := []byte{3,
+		0x60, 1, 0x7e, 1, 0x7f, // (i64) -> i32
+		0x60, 1, 0x7e, 0, // (i64) -> ()
+		0x60, 0, 0, // () -> ()
+	}
+	var imports []byte
+	imports = appendULEB128(imports, 3)
+	imports = appendName(imports, "env")
+	imports = appendName(imports, "memory")
+	imports = append(imports, 0x02, 0x00, 1) // memory with at least 1 page
+	imports = appendName(imports, "env")
+	imports = appendName(imports, "tinygo_stdin_read")
+	imports = append(imports, 0x00, 0) // function 0
+	imports = appendName(imports, "env")
+	imports = appendName(imports, "ext_misc_print_utf8_version_1")
+	imports = append(imports, 0x00, 1) // function 1
+	var exports []byte
+	exports = appendULEB128(exports, 1)
+	exports = appendName(exports, "_start")
+	exports = append(exports, 0x00, 2) // function 2
+
+	code := []byte{0x42} // i64.const 16 << 32: 16 bytes at address 0
+	code = appendSLEB128(code, 16<<32)
+	code = append(code,
+		0x10, 0, // stdin_read
+		0xad,           // i64.extend_i32_u
+		0x42, 32, 0x86, // << 32
+		0x10, 1, // print_utf8
+	)
+	code = append(code, 0x42) // i64.const 16 << 32
+	code = appendSLEB128(code, 16<<32)
+	code = append(code,
+		0x10, 0, // stdin_read
+		0x41, 0x7f, 0x47, // i32.ne -1
+		0x04, 0x40, 0x00, 0x0b, // if: unreachable
+	)
+	path := writeModule(t, types, imports, exports, 2, code)
+
+	var stdout bytes.Buffer
+	config := Config{Stdin: strings.NewReader("hello"), Stdout: &stdout}
+	if _, err := Run(context.Background(), path, config); err != nil {
+		t.Fatal("could not run module:", err)
+	}
+	if stdout.String() != "hello\n" {
+		t.Errorf("unexpected output: %q", stdout.String())
+	}
+}
+
+func TestChildStorage(t *testing.T) {
+	// A module that stores "v" under key "k" of child storage "c", reads it
+	// back, clears it and reads it again. Values are read as a SCALE encoded
+	// Option<Vec<u8>>, which is printed in hex.
+	types := []byte{5,
+		0x60, 3, 0x7e, 0x7e, 0x7e, 0, // (i64, i64, i64) -> ()
+		0x60, 2, 0x7e, 0x7e, 1, 0x7e, // (i64, i64) -> i64
+		0x60, 2, 0x7e, 0x7e, 0, // (i64, i64) -> ()
+		0x60, 1, 0x7e, 0, // (i64) -> ()
+		0x60, 0, 0, // () -> ()
+	}
+	var imports []byte
+	imports = appendULEB128(imports, 5)
+	imports = appendName(imports, "env")
+	imports = appendName(imports, "memory")
+	imports = append(imports, 0x02, 0x00, 1) // memory with at least 1 page
+	for i, name := range []string{
+		"ext_default_child_storage_set_version_1",
+		"ext_default_child_storage_get_version_1",
+		"ext_default_child_storage_clear_version_1",
+		"ext_misc_print_hex_version_1",
+	} {
+		imports = appendName(imports, "env")
+		imports = appendName(imports, name)
+		imports = append(imports, 0x00, byte(i)) // function i
+	}
+	var exports []byte
+	exports = appendULEB128(exports, 1)
+	exports = appendName(exports, "_initialize")
+	exports = append(exports, 0x00, 4) // function 4
+
+	var code []byte
+	for i, c := range []byte("ckv") {
+		code = append(code, 0x41, byte(i), 0x41) // i32.const i, i32.const c
+		code = appendSLEB128(code, int64(c))
+		code = append(code, 0x3a, 0, 0) // i32.store8
+	}
+	pointerSize := func(ptr int64) []byte {
+		return appendSLEB128([]byte{0x42}, ptr|1<<32) // i64.const ptr with length 1
+	}
+	get := append(append(pointerSize(0), pointerSize(1)...), 0x10, 1, 0x10, 3) // print_hex(get(c, k))
+	code = append(code, pointerSize(0)...)
+	code = append(code, pointerSize(1)...)
+	code = append(code, pointerSize(2)...)
+	code = append(code, 0x10, 0) // set(c, k, v)
+	code = append(code, get...)
+	code = append(code, pointerSize(0)...)
+	code = append(code, pointerSize(1)...)
+	code = append(code, 0x10, 2) // clear(c, k)
+	code = append(code, get...)
+	path := writeModule(t, types, imports, exports, 4, code)
+
+	var stdout bytes.Buffer
+	if _, err := Run(context.Background(), path, Config{Stdout: &stdout}); err != nil {
+		t.Fatal("could not run module:", err)
+	}
+	if stdout.String() != "010476\n00\n" {
+		t.Errorf("unexpected output: %q", stdout.String())
+	}
+}
+
+func appendSLEB128(buf []byte, value int64) []byte {
+	for {
+		b := byte(value & 0x7f)
+		value >>= 7
+		if (value == 0 && b&0x40 == 0) || (value == -1 && b&0x40 != 0) {
+			return append(buf, b)
+		}
+		buf = append(buf, b|0x80)
+	}
+}
+
+func TestBenchmark(t *testing.T) {
+	// A module with a benchmark function that reports a JSON result from the
+	// data section, after timing an empty loop.
+	report := `[{"name":"Empty","n":10,"ns":0,"mallocs":2,"bytes":48}]`
+	types := []byte{2,
+		0x60, 0, 0, // () -> ()
+		0x60, 0, 1, 0x7e, // () -> i64
+	}
+	var imports []byte
+	imports = appendULEB128(imports, 3)
+	imports = appendName(imports, "env")
+	imports = appendName(imports, "memory")
+	imports = append(imports, 0x02, 0x00, 1) // memory with at least 1 page
+	imports = appendName(imports, "env")
+	imports = appendName(imports, "tinygo_benchmark_start")
+	imports = append(imports, 0x00, 0) // function 0
+	imports = appendName(imports, "env")
+	imports = appendName(imports, "tinygo_benchmark_stop")
+	imports = append(imports, 0x00, 1) // function 1
+	var exports []byte
+	exports = appendULEB128(exports, 1)
+	exports = appendName(exports, "bench_empty")
+	exports = append(exports, 0x00, 2) // function 2
+
+	code := []byte{0x10, 0, 0x10, 1, 0x1a} // start(), drop(stop())
+	for i, c := range []byte(report) {
+		code = append(code, 0x41, 0, 0x41) // i32.const 0, i32.const c
+		code = appendSLEB128(code, int64(c))
+		code = append(code, 0x3a, 0) // i32.store8 offset=i
+		code = appendULEB128(code, uint32(i))
+	}
+	code = append(code, 0x42) // i64.const len(report) << 32
+	code = appendSLEB128(code, int64(len(report))<<32)
+	path := writeModule(t, types, imports, exports, 1, code)
+
+	results, err := RunBenchmarks(context.Background(), path, Config{}, regexp.MustCompile("."))
+	if err != nil {
+		t.Fatal("could not run benchmarks:", err)
+	}
+	expected := BenchmarkResult{Name: "Empty", N: 10, Mallocs: 2, AllocBytes: 48}
+	if len(results) != 1 || results[0] != expected {
+		t.Fatalf("unexpected results: %+v", results)
+	}
+	if s := results[0].String(); s != "BenchmarkEmpty\t      10\t         0 ns/op\t       4 B/op\t       0 allocs/op" {
+		t.Errorf("unexpected formatting: %q", s)
+	}
+}
+
+func TestMemStats(t *testing.T) {
+	// A module that exports the memory statistics of a runtime built with
+	// -gc-diff, as an array of 9 uint64 values in memory.
+	values := []uint64{4096, 3072, 1024, 2048, 0, 5000, 30, 10, 512}
+	types := []byte{1,
+		0x60, 0, 1, 0x7e, // () -> i64
+	}
+	var imports []byte
+	imports = appendULEB128(imports, 1)
+	imports = appendName(imports, "env")
+	imports = appendName(imports, "memory")
+	imports = append(imports, 0x02, 0x00, 1) // memory with at least 1 page
+	var exports []byte
+	exports = appendULEB128(exports, 1)
+	exports = appendName(exports, "tinygo_memstats")
+	exports = append(exports, 0x00, 0) // function 0
+
+	var code []byte
+	for i, value := range values {
+		code = append(code, 0x41, 0, 0x42) // i32.const 0, i64.const value
+		code = appendSLEB128(code, int64(value))
+		code = append(code, 0x37, 3) // i64.store align=8 offset=i*8
+		code = appendULEB128(code, uint32(i*8))
+	}
+	code = append(code, 0x42) // i64.const len(values)*8 << 32
+	code = appendSLEB128(code, int64(len(values)*8)<<32)
+	path := writeModule(t, types, imports, exports, 0, code)
+
+	var stats MemStats
+	if _, err := Run(context.Background(), path, Config{MemStats: &stats}); err != nil {
+		t.Fatal("could not run module:", err)
+	}
+	expected := MemStats{Valid: true, Sys: 4096, HeapSys: 3072, HeapIdle: 1024, HeapInuse: 2048, TotalAlloc: 5000, Mallocs: 30, Frees: 10, GCSys: 512}
+	if stats != expected {
+		t.Fatalf("unexpected statistics: %+v", stats)
+	}
+	if err := stats.Check(); err != nil {
+		t.Error("unexpected error:", err)
+	}
+	stats.Frees = 40
+	if err := stats.Check(); err == nil || err.Error() != "more frees (40) than mallocs (30)" {
+		t.Error("unexpected error for invalid statistics:", err)
+	}
+}
+
+func TestInitReport(t *testing.T) {
+	// A module that exports the cost of a single package initializer, like a
+	// runtime built with -report-init.
+	types := []byte{1,
+		0x60, 0, 1, 0x7e, // () -> i64
+	}
+	var imports []byte
+	imports = appendULEB128(imports, 1)
+	imports = appendName(imports, "env")
+	imports = appendName(imports, "memory")
+	imports = append(imports, 0x02, 0x00, 1) // memory with at least 1 page
+	var exports []byte
+	exports = appendULEB128(exports, 1)
+	exports = appendName(exports, "tinygo_init_report")
+	exports = append(exports, 0x00, 0) // function 0
+
+	var code []byte
+	for i, c := range []byte("foo") {
+		code = append(code, 0x41, 0, 0x41) // i32.const 0, i32.const c
+		code = appendSLEB128(code, int64(c))
+		code = append(code, 0x3a, 0) // i32.store8 offset=64+i
+		code = appendULEB128(code, uint32(64+i))
+	}
+	code = append(code, 0x41, 0, 0x41, 0xc0, 0x00, 0x36, 2, 0) // name at address 64
+	code = append(code, 0x41, 0, 0x41, 3, 0x36, 2, 4)          // name length 3
+	for i, value := range []int64{1500, 2, 48} {
+		code = append(code, 0x41, 0, 0x42) // i32.const 0, i64.const value
+		code = appendSLEB128(code, value)
+		code = append(code, 0x37, 3) // i64.store align=8 offset=8+i*8
+		code = appendULEB128(code, uint32(8+i*8))
+	}
+	code = append(code, 0x42) // i64.const 32 << 32
+	code = appendSLEB128(code, 32<<32)
+	path := writeModule(t, types, imports, exports, 0, code)
+
+	var costs []InitCost
+	if _, err := Run(context.Background(), path, Config{InitReport: &costs}); err != nil {
+		t.Fatal("could not run module:", err)
+	}
+	expected := InitCost{Package: "foo", Nanoseconds: 1500, Mallocs: 2, AllocBytes: 48}
+	if len(costs) != 1 || costs[0] != expected {
+		t.Fatalf("unexpected init report: %+v", costs)
+	}
+
+	var buf bytes.Buffer
+	WriteInitReport(&buf, costs)
+	expectedReport := "" +
+		"          ns   allocs      bytes  package initializer\n" +
+		"        1500        2         48  foo\n" +
+		"        1500        2         48  (total of 1 packages initialized at runtime)\n"
+	if buf.String() != expectedReport {
+		t.Errorf("unexpected report:\n%s", buf.String())
+	}
+}
+
+func TestCrashDump(t *testing.T) {
+	// A module that traps right away, with a trap record at address 0 and a
+	// crash dump at address 64 that are set by a data segment. The log has
+	// wrapped around, so the oldest byte is at offset 300 % 256.
+	types := []byte{2,
+		0x60, 0, 0, // () -> ()
+		0x60, 0, 1, 0x7f, // () -> i32
+	}
+	var imports []byte
+	imports = appendULEB128(imports, 1)
+	imports = appendName(imports, "env")
+	imports = appendName(imports, "memory")
+	imports = append(imports, 0x02, 0x00, 1) // memory with at least 1 page
+	var exports []byte
+	exports = appendULEB128(exports, 3)
+	exports = appendName(exports, "_start")
+	exports = append(exports, 0x00, 0) // function 0
+	exports = appendName(exports, "tinygo_trap_info")
+	exports = append(exports, 0x00, 1) // function 1
+	exports = appendName(exports, "tinygo_crash_dump")
+	exports = append(exports, 0x00, 2) // function 2
+	codes := []byte{3,
+		3, 0, 0x00, 0x0b, // unreachable
+		4, 0, 0x41, 0, 0x0b, // i32.const 0
+		5, 0, 0x41, 0xc0, 0x00, 0x0b, // i32.const 64
+	}
+
+	record := []byte{
+		1, 0, 0, 0, // reason: panic
+		0, 0, 0, 0, // aux
+		16, 0, 0, 0, // message pointer
+		4, 0, 0, 0, // message length
+		'b', 'o', 'o', 'm',
+	}
+	record = append(record, make([]byte, 12)...)
+	record = append(record, "Core_version"...)
+	record = append(record, make([]byte, 64-len(record))...)
+	for _, field := range []uint32{1, 0x1000, 0x2000, 0x3000, 0x10000, 100, 5, 2, 32, 12, 300} {
+		var buf [4]byte
+		binary.LittleEndian.PutUint32(buf[:], field)
+		record = append(record, buf[:]...)
+	}
+	log := bytes.Repeat([]byte{'x'}, 256)
+	copy(log[300-256:], "oldest")
+	copy(log, "newest")
+	record = append(record, log...)
+	var data []byte
+	data = appendULEB128(data, 1)
+	data = append(data, 0, 0x41, 0, 0x0b) // memory 0, offset 0
+	data = appendULEB128(data, uint32(len(record)))
+	data = append(data, record...)
+
+	module := []byte{0x00, 'a', 's', 'm', 0x01, 0x00, 0x00, 0x00}
+	module = appendSection(module, 1, types)
+	module = appendSection(module, 2, imports)
+	module = appendSection(module, 3, []byte{3, 0, 1, 1})
+	module = appendSection(module, 7, exports)
+	module = appendSection(module, 10, codes)
+	module = appendSection(module, 11, data)
+	path := filepath.Join(t.TempDir(), "test.wasm")
+	if err := os.WriteFile(path, module, 0o666); err != nil {
+		t.Fatal(err)
+	}
+
+	_, err := Run(context.Background(), path, Config{})
+	var trapErr *TrapError
+	if !errors.As(err, &trapErr) {
+		t.Fatal("expected a trap error, got:", err)
+	}
+	dump := trapErr.CrashDump
+	if dump == nil {
+		t.Fatal("expected a crash dump")
+	}
+	if dump.Export != "Core_version" || dump.SP != 0x1000 || dump.StackTop != 0x2000 || dump.HeapInuse != 100 || dump.Mallocs != 5 || dump.Frees != 2 {
+		t.Errorf("unexpected crash dump: %+v", dump)
+	}
+	expected := append(append([]byte{}, log[300-256:]...), log[:300-256]...)
+	if !bytes.Equal(dump.Log, expected) {
+		t.Errorf("unexpected log: %q", dump.Log)
+	}
+}
+
+func TestHostCallStats(t *testing.T) {
+	// A module that exports the statistics of two imports, like a runtime
+	// built with -host-call-stats. The first import wasn't called.
+	types := []byte{1,
+		0x60, 0, 1, 0x7e, // () -> i64
+	}
+	var imports []byte
+	imports = appendULEB128(imports, 1)
+	imports = appendName(imports, "env")
+	imports = appendName(imports, "memory")
+	imports = append(imports, 0x02, 0x00, 1) // memory with at least 1 page
+	var exports []byte
+	exports = appendULEB128(exports, 1)
+	exports = appendName(exports, "tinygo_host_call_stats")
+	exports = append(exports, 0x00, 0) // function 0
+
+	var code []byte
+	for i, c := range []byte("env.ext_foo") {
+		code = append(code, 0x41, 0, 0x41) // i32.const 0, i32.const c
+		code = appendSLEB128(code, int64(c))
+		code = append(code, 0x3a, 0) // i32.store8 offset=64+i
+		code = appendULEB128(code, uint32(64+i))
+	}
+	code = append(code, 0x41, 0, 0x41, 0xc0, 0x00, 0x36, 2, 24) // name at address 64
+	code = append(code, 0x41, 0, 0x41, 11, 0x36, 2, 28)         // name length 11
+	code = append(code, 0x41, 0, 0x42, 3, 0x37, 3, 32)          // 3 calls
+	code = append(code, 0x41, 0, 0x42, 0xe0, 0x00, 0x37, 3, 40) // 96 bytes
+	code = append(code, 0x42)                                   // i64.const 48 << 32
+	code = appendSLEB128(code, 48<<32)
+	path := writeModule(t, types, imports, exports, 0, code)
+
+	var stats []HostCallStat
+	if _, err := Run(context.Background(), path, Config{HostCallStats: &stats}); err != nil {
+		t.Fatal("could not run module:", err)
+	}
+	expected := HostCallStat{Import: "env.ext_foo", Calls: 3, Bytes: 96}
+	if len(stats) != 1 || stats[0] != expected {
+		t.Fatalf("unexpected host call stats: %+v", stats)
+	}
+
+	var buf bytes.Buffer
+	WriteHostCallStats(&buf, stats)
+	expectedReport := "" +
+		"     calls        bytes  import\n" +
+		"         3           96  env.ext_foo\n" +
+		"         3           96  (total of 1 imports called)\n"
+	if buf.String() != expectedReport {
+		t.Errorf("unexpected report:\n%s", buf.String())
+	}
+}
+
+func TestSelfcheck(t *testing.T) {
+	// A module of which the runtime self-check finds a corrupted heap order
+	// and a dirty redzone.
+	types := []byte{1,
+		0x60, 0, 1, 0x7f, // () -> i32
+	}
+	var exports []byte
+	exports = appendULEB128(exports, 1)
+	exports = appendName(exports, "__runtime_selfcheck")
+	exports = append(exports, 0x00, 0) // function 0
+	code := []byte{0x41}               // i32.const
+	code = appendSLEB128(code, int64(SelfcheckHeapOrder|SelfcheckStack))
+	path := writeModule(t, types, []byte{0}, exports, 0, code)
+
+	_, err := Run(context.Background(), path, Config{})
+	var selfcheckErr *SelfcheckError
+	if !errors.As(err, &selfcheckErr) {
+		t.Fatal("expected a self-check error, got:", err)
+	}
+	if selfcheckErr.Failed != SelfcheckHeapOrder|SelfcheckStack {
+		t.Errorf("unexpected failures: %d", selfcheckErr.Failed)
+	}
+	if err.Error() != "runtime self-check failed: heap order, stack" {
+		t.Errorf("unexpected error message: %s", err)
+	}
+}
+
+func TestHostFunctions(t *testing.T) {
+	// A module that imports its memory and a function from Config.Functions,
+	// which calls back into the "inner" export of the module while _initialize
+	// is running. The inner function sets the first byte of memory.
+	types := []byte{1,
+		0x60, 0, 0, // () -> ()
+	}
+	var imports []byte
+	imports = appendULEB128(imports, 2)
+	imports = appendName(imports, "env")
+	imports = appendName(imports, "memory")
+	imports = append(imports, 0x02, 0x00, 1) // memory with at least 1 page
+	imports = appendName(imports, "env")
+	imports = appendName(imports, "call_inner")
+	imports = append(imports, 0x00, 0) // function 0
+	var exports []byte
+	exports = appendULEB128(exports, 2)
+	exports = appendName(exports, "_initialize")
+	exports = append(exports, 0x00, 1) // function 1
+	exports = appendName(exports, "inner")
+	exports = append(exports, 0x00, 2) // function 2
+	codes := []byte{2,
+		4, 0, 0x10, 0, 0x0b, // call_inner
+		9, 0, 0x41, 0, 0x41, 1, 0x3a, 0, 0, 0x0b, // i32.store8(0, 1)
+	}
+
+	module := []byte{0x00, 'a', 's', 'm', 0x01, 0x00, 0x00, 0x00}
+	module = appendSection(module, 1, types)
+	module = appendSection(module, 2, imports)
+	module = appendSection(module, 3, []byte{2, 0, 0})
+	module = appendSection(module, 7, exports)
+	module = appendSection(module, 10, codes)
+	path := filepath.Join(t.TempDir(), "test.wasm")
+	if err := os.WriteFile(path, module, 0o666); err != nil {
+		t.Fatal(err)
+	}
+
+	var value byte
+	callInner := func(ctx context.Context, mod api.Module, stack []uint64) {
+		if _, err := mod.ExportedFunction("inner").Call(ctx); err != nil {
+			panic(err)
+		}
+		value, _ = mod.Memory().ReadByte(0)
+	}
+	config := Config{Functions: []HostFunction{{Name: "call_inner", Func: callInner}}}
+	if _, err := Run(context.Background(), path, config); err != nil {
+		t.Fatal("could not run module:", err)
+	}
+	if value != 1 {
+		t.Errorf("inner function wasn't called: memory contains %d", value)
+	}
+}